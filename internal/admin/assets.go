@@ -0,0 +1,145 @@
+package admin
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+)
+
+// assetFiles holds the admin UI's scripts and stylesheets, so the
+// dashboard works without network access and the CSP can be limited to
+// 'self'.
+//
+//go:embed assets
+var assetFiles embed.FS
+
+// assetCacheControl lets browsers cache assets until their content, and so
+// their fingerprinted name, changes.
+const assetCacheControl = "private, max-age=31536000, immutable"
+
+// asset is an embedded file served under a fingerprinted name.
+type asset struct {
+	name        string // Fingerprinted name, e.g. "dashboard.3f2a9c1b0d4e.js"
+	data        []byte
+	contentType string
+	etag        string
+}
+
+// assetSet maps embedded assets to their fingerprinted names.
+type assetSet struct {
+	byName map[string]*asset // Keyed by fingerprinted name
+	names  map[string]string // Original name to fingerprinted name
+}
+
+// dashboardAssets holds the embedded admin UI assets.
+var dashboardAssets = mustLoadAssets(assetFiles, "assets")
+
+// loadAssets reads every file in dir and fingerprints it with a hash of
+// its content.
+//
+// Parameters:
+//   - fsys: the file system to read from
+//   - dir: the directory holding the assets
+//
+// Returns the assets, or an error if a file cannot be read.
+func loadAssets(fsys fs.FS, dir string) (*assetSet, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read assets: %w", err)
+	}
+
+	set := &assetSet{
+		byName: make(map[string]*asset),
+		names:  make(map[string]string),
+	}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		data, err := fs.ReadFile(fsys, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read asset %s: %w", entry.Name(), err)
+		}
+		sum := sha256.Sum256(data)
+		hash := hex.EncodeToString(sum[:6])
+		ext := path.Ext(entry.Name())
+		a := &asset{
+			name:        strings.TrimSuffix(entry.Name(), ext) + "." + hash + ext,
+			data:        data,
+			contentType: mime.TypeByExtension(ext),
+			etag:        `"` + hex.EncodeToString(sum[:16]) + `"`,
+		}
+		set.byName[a.name] = a
+		set.names[entry.Name()] = a.name
+	}
+	return set, nil
+}
+
+// mustLoadAssets is like loadAssets but panics on error, since the assets
+// are embedded at build time.
+func mustLoadAssets(fsys fs.FS, dir string) *assetSet {
+	set, err := loadAssets(fsys, dir)
+	if err != nil {
+		panic(err)
+	}
+	return set
+}
+
+// path returns the fingerprinted name of an asset.
+//
+// Parameters:
+//   - name: the asset's original file name, e.g. "dashboard.js"
+//
+// Returns the fingerprinted name. It panics if the asset does not exist,
+// since asset names are fixed at build time.
+func (s *assetSet) path(name string) string {
+	fingerprinted, ok := s.names[name]
+	if !ok {
+		panic("admin: unknown asset " + name)
+	}
+	return fingerprinted
+}
+
+// HandleAsset serves the admin UI's embedded scripts and stylesheets.
+//
+// Assets are requested by their fingerprinted names under the admin path's
+// "/assets/" prefix and may be cached indefinitely. Unknown names, including
+// names from an older build, receive 404 Not Found.
+//
+// Parameters:
+//   - w: the HTTP response writer
+//   - r: the HTTP request
+func (h *Handler) HandleAsset(w http.ResponseWriter, r *http.Request) {
+	h.setSecurityHeaders(w)
+
+	// Validate authentication
+	if !h.auth.IsAuthenticated(r) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	a, ok := dashboardAssets.byName[strings.TrimPrefix(r.URL.Path, h.auth.GetPath()+"/assets/")]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", a.contentType)
+	w.Header().Set("Cache-Control", assetCacheControl)
+	w.Header().Set("ETag", a.etag)
+	http.ServeContent(w, r, a.name, time.Time{}, bytes.NewReader(a.data))
+}
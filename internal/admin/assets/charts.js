@@ -0,0 +1,107 @@
+// Doughnut charts drawn on a canvas, with an HTML legend below it.
+'use strict';
+
+const chartColors = [
+  'rgba(255, 99, 132, 0.8)',
+  'rgba(54, 162, 235, 0.8)',
+  'rgba(255, 206, 86, 0.8)',
+  'rgba(75, 192, 192, 0.8)',
+  'rgba(153, 102, 255, 0.8)',
+  'rgba(255, 159, 64, 0.8)',
+  'rgba(199, 199, 199, 0.8)',
+  'rgba(83, 102, 255, 0.8)',
+];
+
+class DoughnutChart {
+  constructor(canvas) {
+    this.canvas = canvas;
+    this.labels = [];
+    this.data = [];
+    this.segments = [];
+    this.legend = document.createElement('ul');
+    this.legend.className = 'chart-legend';
+    canvas.after(this.legend);
+    canvas.addEventListener('mousemove', (e) => this.hover(e));
+    window.addEventListener('resize', () => this.draw());
+  }
+
+  update(labels, data) {
+    this.labels = labels || [];
+    this.data = data || [];
+    this.draw();
+    this.drawLegend();
+  }
+
+  draw() {
+    const canvas = this.canvas;
+    const ratio = window.devicePixelRatio || 1;
+    const width = canvas.clientWidth;
+    const height = canvas.clientHeight;
+    canvas.width = width * ratio;
+    canvas.height = height * ratio;
+    const ctx = canvas.getContext('2d');
+    ctx.scale(ratio, ratio);
+    ctx.clearRect(0, 0, width, height);
+
+    this.segments = [];
+    this.geometry = null;
+    const total = this.data.reduce((sum, value) => sum + value, 0);
+    if (total === 0) {
+      ctx.fillStyle = '#777';
+      ctx.font = '14px monospace';
+      ctx.textAlign = 'center';
+      ctx.fillText('No data yet', width / 2, height / 2);
+      return;
+    }
+
+    const cx = width / 2;
+    const cy = height / 2;
+    const outer = Math.min(width, height) / 2 - 4;
+    const inner = outer / 2;
+    let start = -Math.PI / 2;
+    this.data.forEach((value, i) => {
+      const end = start + (value / total) * 2 * Math.PI;
+      ctx.beginPath();
+      ctx.arc(cx, cy, outer, start, end);
+      ctx.arc(cx, cy, inner, end, start, true);
+      ctx.closePath();
+      ctx.fillStyle = chartColors[i % chartColors.length];
+      ctx.fill();
+      ctx.strokeStyle = '#fff';
+      ctx.lineWidth = 2;
+      ctx.stroke();
+      this.segments.push({ start: start, end: end, index: i });
+      start = end;
+    });
+    this.geometry = { cx: cx, cy: cy, inner: inner, outer: outer };
+  }
+
+  drawLegend() {
+    this.legend.replaceChildren();
+    this.labels.forEach((label, i) => {
+      const item = document.createElement('li');
+      const swatch = document.createElement('span');
+      swatch.className = 'chart-swatch';
+      swatch.style.backgroundColor = chartColors[i % chartColors.length];
+      item.append(swatch, document.createTextNode(label));
+      this.legend.append(item);
+    });
+  }
+
+  // Show the hovered segment's label and value as the canvas tooltip
+  hover(e) {
+    this.canvas.title = '';
+    if (!this.geometry) return;
+    const rect = this.canvas.getBoundingClientRect();
+    const x = e.clientX - rect.left - this.geometry.cx;
+    const y = e.clientY - rect.top - this.geometry.cy;
+    const distance = Math.sqrt(x * x + y * y);
+    if (distance < this.geometry.inner || distance > this.geometry.outer) return;
+    let angle = Math.atan2(y, x);
+    if (angle < -Math.PI / 2) angle += 2 * Math.PI;
+    const segment = this.segments.find((s) => angle >= s.start && angle < s.end);
+    if (segment) {
+      this.canvas.title = this.labels[segment.index] + ': ' + this.data[segment.index];
+    }
+  }
+}
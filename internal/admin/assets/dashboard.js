@@ -0,0 +1,174 @@
+// Admin overview page: loads the charts and keeps the page up to date from the
+// event stream. Counters, charts, top tables and node status are replaced
+// on each summary, and recorded requests are prepended to the recent
+// requests table unless paused or hidden by the filter.
+'use strict';
+
+const adminPath = document.body.dataset.adminPath;
+const ipChart = new DoughnutChart(document.getElementById('ipChart'));
+const uaChart = new DoughnutChart(document.getElementById('uaChart'));
+// The country and network charts only exist when GeoIP databases are loaded
+const countryCanvas = document.getElementById('countryChart');
+const countryChart = countryCanvas ? new DoughnutChart(countryCanvas) : null;
+const asnCanvas = document.getElementById('asnChart');
+const asnChart = asnCanvas ? new DoughnutChart(asnCanvas) : null;
+const requestTable = document.getElementById('request-table');
+const maxRows = parseInt(requestTable.dataset.maxRows, 10);
+const requestFilter = document.getElementById('request-filter');
+const pauseButton = document.getElementById('pause-button');
+const pausedCount = document.getElementById('paused-count');
+let paused = false;
+let pending = [];
+
+async function loadCharts() {
+  try {
+    const response = await fetch(adminPath + '/data');
+    if (!response.ok) throw new Error('Failed to load chart data');
+    const data = await response.json();
+    ipChart.update(data.topIPs.labels, data.topIPs.data);
+    uaChart.update(data.topUserAgents.labels, data.topUserAgents.data);
+    updateGeoCharts(data);
+  } catch (error) {
+    console.error('Error loading charts:', error);
+  }
+}
+
+function updateGeoCharts(charts) {
+  if (countryChart) countryChart.update(charts.topCountries.labels || [], charts.topCountries.data || []);
+  if (asnChart) asnChart.update(charts.topASNs.labels || [], charts.topASNs.data || []);
+}
+
+function setText(id, value) {
+  const el = document.getElementById(id);
+  if (el) el.textContent = value;
+}
+
+function pad(n) {
+  return String(n).padStart(2, '0');
+}
+
+function formatTime(value) {
+  const d = new Date(value);
+  return d.getFullYear() + '-' + pad(d.getMonth() + 1) + '-' + pad(d.getDate()) + ' ' +
+    pad(d.getHours()) + ':' + pad(d.getMinutes()) + ':' + pad(d.getSeconds());
+}
+
+function ipLink(ip) {
+  return adminPath + '/ips/' + encodeURIComponent(ip);
+}
+
+function userAgentLink(ua) {
+  return adminPath + '/useragents/view?ua=' + encodeURIComponent(ua);
+}
+
+// Cells are filled with textContent, never HTML, since values come from crawlers.
+// links holds a function per column that turns a value into a detail page link.
+function appendRow(tbody, cells, classes, links) {
+  const row = tbody.insertRow();
+  cells.forEach(function (value, i) {
+    const cell = row.insertCell();
+    if (links && links[i]) {
+      const a = document.createElement('a');
+      a.href = links[i](value);
+      a.textContent = value;
+      cell.appendChild(a);
+    } else {
+      cell.textContent = value;
+    }
+    if (classes && classes[i]) cell.className = classes[i];
+  });
+  return row;
+}
+
+function matchesFilter(row) {
+  const filter = requestFilter.value.trim().toLowerCase();
+  return filter === '' || row.textContent.toLowerCase().includes(filter);
+}
+
+function addRequest(req) {
+  const row = appendRow(requestTable, [req.timestamp, req.ip, req.path, req.userAgent], ['', 'ip'],
+    [null, ipLink, null, userAgentLink]);
+  requestTable.prepend(row);
+  row.hidden = !matchesFilter(row);
+  while (requestTable.rows.length > maxRows) requestTable.deleteRow(-1);
+  document.getElementById('no-requests').hidden = true;
+}
+
+function fillTable(id, series, classes, link) {
+  const tbody = document.getElementById(id);
+  if (!tbody) return;
+  tbody.replaceChildren();
+  (series.labels || []).forEach(function (label, i) {
+    appendRow(tbody, [label, series.data[i]], classes, link ? [link] : null);
+  });
+}
+
+function nodeState(node) {
+  if (node.lastError) return 'error';
+  if (node.stale) return 'stale';
+  return 'ok';
+}
+
+function updateNodes(nodes) {
+  const tbody = document.getElementById('node-table');
+  if (!tbody) return;
+  tbody.replaceChildren();
+  (nodes || []).forEach(function (node) {
+    const state = nodeState(node);
+    appendRow(tbody,
+      [node.name, state, formatTime(node.lastSeen), node.received, node.backlog, node.address, node.lastError || ''],
+      ['', 'node-' + state, '', '', '', 'ip']);
+  });
+  document.getElementById('no-nodes').hidden = tbody.rows.length > 0;
+}
+
+requestFilter.addEventListener('input', function () {
+  for (const row of requestTable.rows) row.hidden = !matchesFilter(row);
+});
+
+pauseButton.addEventListener('click', function () {
+  paused = !paused;
+  pauseButton.textContent = paused ? 'Resume' : 'Pause';
+  if (!paused) {
+    pending.forEach(addRequest);
+    pending = [];
+    pausedCount.textContent = '';
+  }
+});
+
+const events = new EventSource(adminPath + '/events');
+events.addEventListener('open', function () {
+  setText('live-status', 'connected');
+});
+events.addEventListener('error', function () {
+  setText('live-status', 'reconnecting');
+});
+events.addEventListener('request', function (e) {
+  const req = JSON.parse(e.data);
+  if (paused) {
+    pending.push(req);
+    if (pending.length > maxRows) pending.shift();
+    pausedCount.textContent = pending.length + ' new while paused';
+    return;
+  }
+  addRequest(req);
+});
+events.addEventListener('summary', function (e) {
+  const summary = JSON.parse(e.data);
+  setText('uptime', summary.uptime);
+  setText('total-requests', summary.totalRequests);
+  setText('new-requests', summary.newRequests > 0 ? '(+' + summary.newRequests + ')' : '');
+  setText('unique-ips', summary.uniqueIPs);
+  setText('unique-uas', summary.uniqueUserAgents);
+  setText('live-status', summary.dropped > 0 ? 'connected, ' + summary.dropped + ' requests skipped' : 'connected');
+  ipChart.update(summary.charts.topIPs.labels, summary.charts.topIPs.data);
+  uaChart.update(summary.charts.topUserAgents.labels, summary.charts.topUserAgents.data);
+  fillTable('ip-table', summary.charts.topIPs, ['ip'], ipLink);
+  fillTable('ua-table', summary.charts.topUserAgents, null, userAgentLink);
+  updateGeoCharts(summary.charts);
+  fillTable('country-table', summary.charts.topCountries);
+  fillTable('asn-table', summary.charts.topASNs);
+  updateNodes(summary.nodes);
+});
+
+loadCharts();
@@ -0,0 +1,133 @@
+package admin
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/rampantspark/gospidertrap/internal/stats"
+)
+
+func TestLoadAssets(t *testing.T) {
+	fsys := fstest.MapFS{
+		"assets/app.js":    {Data: []byte("console.log(1);")},
+		"assets/style.css": {Data: []byte("body {}")},
+		"assets/sub/x.js":  {Data: []byte("ignored")},
+	}
+	set, err := loadAssets(fsys, "assets")
+	if err != nil {
+		t.Fatalf("loadAssets() error = %v", err)
+	}
+	if len(set.byName) != 2 {
+		t.Errorf("loaded %d assets, want 2", len(set.byName))
+	}
+	name := set.path("app.js")
+	if !regexp.MustCompile(`^app\.[0-9a-f]{12}\.js$`).MatchString(name) {
+		t.Errorf("path(app.js) = %q, want a fingerprinted name", name)
+	}
+	if ct := set.byName[name].contentType; !strings.HasPrefix(ct, "text/javascript") {
+		t.Errorf("content type = %q, want text/javascript", ct)
+	}
+
+	// Changing the content changes the name
+	fsys["assets/app.js"] = &fstest.MapFile{Data: []byte("console.log(2);")}
+	changed, err := loadAssets(fsys, "assets")
+	if err != nil {
+		t.Fatalf("loadAssets() error = %v", err)
+	}
+	if changed.path("app.js") == name {
+		t.Errorf("fingerprint did not change with the content")
+	}
+}
+
+func TestHandleAsset(t *testing.T) {
+	h := newTestHandler(t, stats.NewMemoryStore(stats.NewStats()))
+	path := h.GetPath() + "/assets/" + dashboardAssets.path("dashboard.js")
+
+	tests := []struct {
+		name        string
+		path        string
+		auth        bool
+		ifNoneMatch string
+		wantStatus  int
+	}{
+		{"unauthenticated", path, false, "", http.StatusForbidden},
+		{"asset", path, true, "", http.StatusOK},
+		{"not modified", path, true, dashboardAssets.byName[dashboardAssets.path("dashboard.js")].etag, http.StatusNotModified},
+		{"unfingerprinted name", h.GetPath() + "/assets/dashboard.js", true, "", http.StatusNotFound},
+		{"stale fingerprint", h.GetPath() + "/assets/dashboard.000000000000.js", true, "", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.auth {
+				r.Header.Set("Authorization", "Bearer "+h.auth.GetToken())
+			}
+			if tt.ifNoneMatch != "" {
+				r.Header.Set("If-None-Match", tt.ifNoneMatch)
+			}
+			w := httptest.NewRecorder()
+			h.HandleAsset(w, r)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			if tt.wantStatus == http.StatusOK {
+				if cc := w.Header().Get("Cache-Control"); cc != assetCacheControl {
+					t.Errorf("Cache-Control = %q, want %q", cc, assetCacheControl)
+				}
+				if !strings.Contains(w.Body.String(), "EventSource") {
+					t.Errorf("body is not dashboard.js")
+				}
+			}
+		})
+	}
+}
+
+func TestHandleUIUsesEmbeddedAssets(t *testing.T) {
+	h := newTestHandler(t, stats.NewMemoryStore(stats.NewStats()))
+	r := httptest.NewRequest(http.MethodGet, h.GetPath(), nil)
+	r.Header.Set("Authorization", "Bearer "+h.auth.GetToken())
+	w := httptest.NewRecorder()
+	h.HandleUI(w, r)
+	body := w.Body.String()
+
+	csp := w.Header().Get("Content-Security-Policy")
+	if strings.Contains(csp, "http") || strings.Contains(csp, "unsafe-inline") {
+		t.Errorf("CSP = %q, want only 'self' and nonce sources", csp)
+	}
+	nonce := regexp.MustCompile(`script-src 'nonce-([A-Za-z0-9_-]+)'`).FindStringSubmatch(csp)
+	if nonce == nil {
+		t.Fatalf("CSP = %q, want a script nonce", csp)
+	}
+	if strings.Contains(body, "<style") {
+		t.Error("admin UI has inline styles")
+	}
+	scripts := regexp.MustCompile(`<script[^>]*>`).FindAllString(body, -1)
+	if len(scripts) != 2 {
+		t.Errorf("admin UI has %d scripts, want 2", len(scripts))
+	}
+	for _, script := range scripts {
+		if !strings.Contains(script, ` src="`) || !strings.Contains(script, `nonce="`+nonce[1]+`"`) {
+			t.Errorf("script %s is inline or lacks the nonce", script)
+		}
+	}
+
+	// Every referenced asset is served
+	refs := regexp.MustCompile(`(?:src|href)="([^"]+/assets/[^"]+)"`).FindAllStringSubmatch(body, -1)
+	if len(refs) != 3 {
+		t.Fatalf("admin UI references %d assets, want 3", len(refs))
+	}
+	for _, ref := range refs {
+		r := httptest.NewRequest(http.MethodGet, ref[1], nil)
+		r.Header.Set("Authorization", "Bearer "+h.auth.GetToken())
+		w := httptest.NewRecorder()
+		h.HandleAsset(w, r)
+		if w.Code != http.StatusOK {
+			t.Errorf("GET %s = %d, want 200", ref[1], w.Code)
+		}
+	}
+}
@@ -1,11 +1,14 @@
 package admin
 
 import (
+	"crypto/hmac"
 	cryptorand "crypto/rand"
+	"crypto/sha256"
 	"crypto/subtle"
 	"encoding/base64"
 	"fmt"
 	"net/http"
+	"strings"
 )
 
 // Authenticator handles admin authentication with secure token generation.
@@ -24,6 +27,9 @@ const (
 	// Cookie settings
 	cookieName   = "gospidertrap_admin_token"
 	cookieMaxAge = 86400 // 24 hours in seconds
+	// CSRF token form field and header
+	csrfFieldName  = "csrf_token"
+	csrfHeaderName = "X-CSRF-Token"
 )
 
 // NewAuthenticator creates a new authenticator with secure random token and path.
@@ -121,9 +127,12 @@ func (a *Authenticator) ValidateToken(token string) bool {
 	return subtle.ConstantTimeCompare([]byte(token), []byte(a.token)) == 1
 }
 
-// GetTokenFromRequest extracts the admin token from either cookie or query parameter.
+// GetTokenFromRequest extracts the admin token from a cookie, an
+// Authorization bearer header or a query parameter.
 //
-// For backward compatibility, it checks cookies first (preferred), then query parameters.
+// Cookies are checked first (preferred), then the Authorization header for
+// non-browser clients such as firewall automation, then query parameters for
+// backward compatibility.
 //
 // Parameters:
 //   - r: the HTTP request
@@ -134,6 +143,10 @@ func (a *Authenticator) GetTokenFromRequest(r *http.Request) string {
 	if cookie, err := r.Cookie(cookieName); err == nil {
 		return cookie.Value
 	}
+	// Then the Authorization header (e.g. curl -H "Authorization: Bearer TOKEN")
+	if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		return token
+	}
 	// Fallback to query parameter for backward compatibility
 	return r.URL.Query().Get("token")
 }
@@ -168,6 +181,54 @@ func (a *Authenticator) IsAuthenticated(r *http.Request) bool {
 	return a.ValidateToken(token)
 }
 
+// CSRFToken returns the token forms must send with state-changing requests.
+//
+// The token is derived from the admin token, so it changes whenever the
+// admin token does and needs no storage of its own.
+func (a *Authenticator) CSRFToken() string {
+	mac := hmac.New(sha256.New, []byte(a.token))
+	mac.Write([]byte("csrf"))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// ValidateCSRF checks the CSRF token of a state-changing request.
+//
+// Only requests authenticated by the cookie need a token, since browsers
+// attach the cookie to cross-site requests; a bearer header or token
+// parameter already proves the sender knows the admin token. The token is
+// read from the "csrf_token" form field or the X-CSRF-Token header.
+//
+// Parameters:
+//   - r: the HTTP request
+//
+// Returns true if the request may proceed, false otherwise.
+func (a *Authenticator) ValidateCSRF(r *http.Request) bool {
+	if _, err := r.Cookie(cookieName); err != nil {
+		return true
+	}
+	token := r.Header.Get(csrfHeaderName)
+	if token == "" {
+		token = r.PostFormValue(csrfFieldName)
+	}
+	return subtle.ConstantTimeCompare([]byte(token), []byte(a.CSRFToken())) == 1
+}
+
+// ClearCookie removes the admin authentication cookie.
+//
+// Parameters:
+//   - w: the HTTP response writer
+func (a *Authenticator) ClearCookie(w http.ResponseWriter) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     cookieName,
+		Value:    "",
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		SameSite: http.SameSiteStrictMode,
+		Secure:   a.useHTTPS,
+	})
+}
+
 // GetLoginURL returns the login URL with token parameter.
 //
 // Parameters:
@@ -3,6 +3,7 @@ package admin
 import (
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"strings"
 	"testing"
 )
@@ -148,6 +149,23 @@ func TestGetTokenFromRequest(t *testing.T) {
 			},
 			wantToken: "cookietoken",
 		},
+		{
+			name: "token in bearer header",
+			setupReq: func(r *http.Request) {
+				r.Header.Set("Authorization", "Bearer headertoken")
+			},
+			wantToken: "headertoken",
+		},
+		{
+			name: "bearer header takes precedence over query param",
+			setupReq: func(r *http.Request) {
+				q := r.URL.Query()
+				q.Set("token", "queryparam")
+				r.URL.RawQuery = q.Encode()
+				r.Header.Set("Authorization", "Bearer headertoken")
+			},
+			wantToken: "headertoken",
+		},
 		{
 			name:      "no token",
 			setupReq:  func(r *http.Request) {},
@@ -341,6 +359,51 @@ func TestGetAdminURL(t *testing.T) {
 	}
 }
 
+func TestValidateCSRF(t *testing.T) {
+	auth, _ := NewAuthenticator(false)
+	other, _ := NewAuthenticator(false)
+
+	tests := []struct {
+		name   string
+		cookie bool
+		header string
+		form   string
+		want   bool
+	}{
+		{"bearer without token", false, "", "", true},
+		{"cookie without token", true, "", "", false},
+		{"cookie with form token", true, "", auth.CSRFToken(), true},
+		{"cookie with header token", true, auth.CSRFToken(), "", true},
+		{"cookie with wrong token", true, "", other.CSRFToken(), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			if tt.form != "" {
+				form.Set(csrfFieldName, tt.form)
+			}
+			r := httptest.NewRequest(http.MethodPost, "/admin/bans/revoke", strings.NewReader(form.Encode()))
+			r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			if tt.cookie {
+				r.AddCookie(&http.Cookie{Name: cookieName, Value: auth.token})
+			} else {
+				r.Header.Set("Authorization", "Bearer "+auth.token)
+			}
+			if tt.header != "" {
+				r.Header.Set(csrfHeaderName, tt.header)
+			}
+			if got := auth.ValidateCSRF(r); got != tt.want {
+				t.Errorf("ValidateCSRF() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if auth.CSRFToken() == other.CSRFToken() {
+		t.Error("CSRFToken() is the same for different admin tokens")
+	}
+}
+
 func BenchmarkValidateToken(b *testing.B) {
 	auth, _ := NewAuthenticator(false)
 	token := auth.token
@@ -0,0 +1,70 @@
+package admin
+
+import (
+	"net/http"
+
+	"github.com/rampantspark/gospidertrap/internal/bait"
+	"github.com/rampantspark/gospidertrap/internal/stats"
+)
+
+// baitRow is a bait on the bait page with its hits.
+type baitRow struct {
+	Entry   bait.Entry // Catalog entry, with only the name for baits no longer in the catalog
+	Listed  bool       // The bait is in the current catalog
+	Hits    stats.BaitCount
+	Matched bool // The bait has answered requests
+}
+
+// baitData is the data of the bait page.
+type baitData struct {
+	Enabled   bool // Baits are answered
+	Supported bool // The store counts hits by bait
+	Backend   string
+	Baits     []baitRow
+}
+
+// HandleBait handles requests to the admin UI bait page, which lists the
+// scanner baits the trap answers and how many requests each has answered.
+//
+// Baits that answered requests but are no longer in the catalog are listed
+// after the catalog's entries.
+//
+// Parameters:
+//   - w: the HTTP response writer
+//   - r: the HTTP request
+func (h *Handler) HandleBait(w http.ResponseWriter, r *http.Request) {
+	if !h.authorizePage(w, r) {
+		return
+	}
+
+	store := h.statsManager.Store()
+	data := baitData{Enabled: h.baits != nil, Backend: store.Backend()}
+	counts := make(map[string]stats.BaitCount)
+	var order []string
+	if reporter, ok := store.(stats.BaitReporter); ok {
+		list, err := reporter.GetBaitCounts(r.Context())
+		if err != nil {
+			h.logger.Error("Failed to get bait counts", "error", err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		data.Supported = true
+		for _, c := range list {
+			counts[c.Bait] = c
+			order = append(order, c.Bait)
+		}
+	}
+
+	listed := make(map[string]bool)
+	for _, e := range h.baits {
+		c, matched := counts[e.Name]
+		data.Baits = append(data.Baits, baitRow{Entry: e, Listed: true, Hits: c, Matched: matched})
+		listed[e.Name] = true
+	}
+	for _, name := range order {
+		if !listed[name] {
+			data.Baits = append(data.Baits, baitRow{Entry: bait.Entry{Name: name}, Hits: counts[name], Matched: true})
+		}
+	}
+	h.renderPage(w, "bait", "Bait", data)
+}
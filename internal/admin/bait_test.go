@@ -0,0 +1,48 @@
+package admin
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rampantspark/gospidertrap/internal/bait"
+	"github.com/rampantspark/gospidertrap/internal/stats"
+)
+
+func TestHandleBait(t *testing.T) {
+	store := stats.NewMemoryStore(stats.NewStats())
+	now := time.Now()
+	_, err := store.RecordRequests(context.Background(), []stats.RequestInfo{
+		{IP: "192.0.2.1", UserAgent: "zgrab", Path: "/.env", Timestamp: now, Bait: "env"},
+		{IP: "192.0.2.2", UserAgent: "zgrab", Path: "/.env", Timestamp: now, Bait: "env"},
+		{IP: "192.0.2.2", UserAgent: "zgrab", Path: "/old", Timestamp: now, Bait: "retired"},
+		{IP: "192.0.2.3", UserAgent: "Mozilla/5.0", Path: "/page", Timestamp: now},
+	})
+	if err != nil {
+		t.Fatalf("RecordRequests() error = %v", err)
+	}
+	h := newTestHandler(t, store)
+	h.SetBaits(bait.DefaultEntries)
+
+	w := get(t, h, h.HandleBait, h.GetPath()+"/bait")
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusOK)
+	}
+	body := w.Body.String()
+	for _, want := range []string{
+		`<td>/.env, /.env.local`,
+		`<td>env</td>` + "\n" + `<td>2</td><td>2</td>`,
+		`/requests?bait=git-config">git-config</a>`,
+		`<td>0</td><td>0</td><td>Never</td>`,
+		`/requests?bait=retired">retired</a></td>` + "\n" + `<td><em>no longer listed</em>`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("page does not contain %q", want)
+		}
+	}
+	if strings.Contains(body, "Scanner bait is disabled") {
+		t.Error("page says bait is disabled")
+	}
+}
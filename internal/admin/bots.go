@@ -0,0 +1,148 @@
+package admin
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/netip"
+
+	"github.com/rampantspark/gospidertrap/internal/stats"
+)
+
+// maxBotRows limits the verification results the bots page and API load.
+const maxBotRows = 1000
+
+// botStatuses are the statuses the bots page and API filter on.
+var botStatuses = []string{stats.BotVerified, stats.BotSpoofed, stats.BotUnknown}
+
+// botsData is the data of the bots page.
+type botsData struct {
+	Supported  bool
+	Backend    string
+	Status     string   // Status filtered on, empty for all
+	Statuses   []string // Statuses offered as filters
+	Bots       []stats.BotVerification
+	Pagination pagination
+}
+
+// validBotStatus reports whether status is empty or a known status.
+func validBotStatus(status string) bool {
+	if status == "" {
+		return true
+	}
+	for _, s := range botStatuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+// HandleBots handles requests to the admin UI bots page, which lists IPs
+// whose user agent claims to be a search engine crawler and whether
+// reverse DNS verified the claim.
+//
+// The optional "status" query parameter limits the list to verified,
+// spoofed or unknown IPs.
+//
+// Parameters:
+//   - w: the HTTP response writer
+//   - r: the HTTP request
+func (h *Handler) HandleBots(w http.ResponseWriter, r *http.Request) {
+	if !h.authorizePage(w, r) {
+		return
+	}
+
+	store := h.statsManager.Store()
+	data := botsData{Backend: store.Backend(), Status: r.URL.Query().Get("status"), Statuses: botStatuses}
+	if !validBotStatus(data.Status) {
+		http.Error(w, "Invalid status", http.StatusBadRequest)
+		return
+	}
+	if verifications, ok := store.(stats.BotVerificationStore); ok {
+		bots, err := verifications.GetBotVerifications(r.Context(), data.Status, maxBotRows)
+		if err != nil {
+			h.logger.Error("Failed to get bot verifications", "error", err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		number := pageNumber(r)
+		var hasNext bool
+		data.Supported = true
+		data.Bots, hasNext = paginate(bots, number, pageSize)
+		data.Pagination = newPagination(r.URL, number, hasNext)
+	}
+	h.renderPage(w, "bots", "Bots", data)
+}
+
+// HandleBotsAPI handles requests for bot verification results in JSON
+// format.
+//
+// With an "ip" query parameter it returns that IP's result, or 404 Not
+// Found if it has not been checked. Otherwise it returns up to 1000
+// results, most recently checked first, optionally limited to one
+// "status".
+//
+// Parameters:
+//   - w: the HTTP response writer
+//   - r: the HTTP request
+func (h *Handler) HandleBotsAPI(w http.ResponseWriter, r *http.Request) {
+	h.setSecurityHeaders(w)
+	if !h.auth.IsAuthenticated(r) {
+		writeJSONError(w, http.StatusForbidden, "Invalid or missing authentication token")
+		return
+	}
+	verifications, ok := h.statsManager.Store().(stats.BotVerificationStore)
+	if !ok {
+		writeJSONError(w, http.StatusNotImplemented, "Bot verification is not supported by the "+h.statsManager.Store().Backend()+" backend")
+		return
+	}
+
+	query := r.URL.Query()
+	var result any
+	if ip := query.Get("ip"); ip != "" {
+		addr, err := netip.ParseAddr(ip)
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, "Invalid IP address")
+			return
+		}
+		v, err := verifications.GetBotVerification(r.Context(), addr.String())
+		if errors.Is(err, stats.ErrNotFound) {
+			writeJSONError(w, http.StatusNotFound, "IP has not been checked")
+			return
+		}
+		if err != nil {
+			h.logger.Error("Failed to get bot verification", "ip", ip, "error", err)
+			writeJSONError(w, http.StatusInternalServerError, "Internal Server Error")
+			return
+		}
+		result = v
+	} else {
+		status := query.Get("status")
+		if !validBotStatus(status) {
+			writeJSONError(w, http.StatusBadRequest, "Invalid status")
+			return
+		}
+		bots, err := verifications.GetBotVerifications(r.Context(), status, maxBotRows)
+		if err != nil {
+			h.logger.Error("Failed to get bot verifications", "error", err)
+			writeJSONError(w, http.StatusInternalServerError, "Internal Server Error")
+			return
+		}
+		if bots == nil {
+			bots = []stats.BotVerification{}
+		}
+		result = bots
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// writeJSONError writes an error response in the {"error": message} form
+// of the JSON endpoints.
+func writeJSONError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": message})
+}
@@ -0,0 +1,105 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rampantspark/gospidertrap/internal/stats"
+)
+
+// newBotsHandler returns a handler over a memory store holding a verified
+// and a spoofed search engine bot.
+func newBotsHandler(t *testing.T) *Handler {
+	t.Helper()
+	store := stats.NewMemoryStore(stats.NewStats())
+	checked := time.Now().Add(-time.Minute)
+	for _, v := range []stats.BotVerification{
+		{IP: "66.249.66.1", Bot: "Googlebot", Status: stats.BotVerified, Hostname: "crawl-66-249-66-1.googlebot.com", CheckedAt: checked},
+		{IP: "203.0.113.1", Bot: "Bingbot", Status: stats.BotSpoofed, CheckedAt: checked.Add(time.Second)},
+	} {
+		if err := store.SaveBotVerification(context.Background(), v); err != nil {
+			t.Fatalf("SaveBotVerification() error = %v", err)
+		}
+	}
+	return newTestHandler(t, store)
+}
+
+func TestHandleBots(t *testing.T) {
+	h := newBotsHandler(t)
+
+	tests := []struct {
+		name       string
+		query      string
+		wantStatus int
+		wantIPs    []string
+	}{
+		{"all", "", http.StatusOK, []string{"66.249.66.1", "203.0.113.1"}},
+		{"spoofed", "?status=spoofed", http.StatusOK, []string{"203.0.113.1"}},
+		{"unknown", "?status=unknown", http.StatusOK, nil},
+		{"invalid status", "?status=bogus", http.StatusBadRequest, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := get(t, h, h.HandleBots, h.GetPath()+"/bots"+tt.query)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			body := w.Body.String()
+			if rows := strings.Count(body, `<td class="ip">`); rows != len(tt.wantIPs) {
+				t.Errorf("rows = %d, want %d", rows, len(tt.wantIPs))
+			}
+			for _, ip := range tt.wantIPs {
+				if !strings.Contains(body, "/ips/"+ip+`">`) {
+					t.Errorf("page does not link %s", ip)
+				}
+			}
+		})
+	}
+}
+
+func TestHandleBotsAPI(t *testing.T) {
+	h := newBotsHandler(t)
+
+	tests := []struct {
+		name       string
+		query      string
+		wantStatus int
+		wantBody   string
+	}{
+		{"all", "", http.StatusOK, `"ip":"203.0.113.1"`},
+		{"one IP", "?ip=66.249.66.1", http.StatusOK, `"status":"verified"`},
+		{"unchecked IP", "?ip=198.51.100.1", http.StatusNotFound, "has not been checked"},
+		{"invalid IP", "?ip=66.249", http.StatusBadRequest, "Invalid IP address"},
+		{"status", "?status=verified", http.StatusOK, `"hostname":"crawl-66-249-66-1.googlebot.com"`},
+		{"no results", "?status=unknown", http.StatusOK, "[]"},
+		{"invalid status", "?status=bogus", http.StatusBadRequest, "Invalid status"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := get(t, h, h.HandleBotsAPI, h.GetPath()+"/api/bots"+tt.query)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			if !json.Valid(w.Body.Bytes()) || !strings.Contains(w.Body.String(), tt.wantBody) {
+				t.Errorf("body = %s, want JSON containing %s", w.Body.String(), tt.wantBody)
+			}
+		})
+	}
+
+	w := httptest.NewRecorder()
+	h.HandleBotsAPI(w, httptest.NewRequest(http.MethodGet, h.GetPath()+"/api/bots", nil))
+	if w.Code != http.StatusForbidden {
+		t.Errorf("unauthenticated status = %d, want %d", w.Code, http.StatusForbidden)
+	}
+
+	// Hide the memory store's verification methods
+	unsupported := newTestHandler(t, struct{ stats.Store }{stats.NewMemoryStore(stats.NewStats())})
+	if w := get(t, unsupported, unsupported.HandleBotsAPI, h.GetPath()+"/api/bots"); w.Code != http.StatusNotImplemented {
+		t.Errorf("unsupported status = %d, want %d", w.Code, http.StatusNotImplemented)
+	}
+}
@@ -0,0 +1,155 @@
+package admin
+
+import (
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/rampantspark/gospidertrap/internal/stats"
+)
+
+// maxCaptureRows limits the request captures the captures page loads.
+const maxCaptureRows = 1000
+
+// captureMethods are the methods the captures page offers as filters.
+var captureMethods = []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodDelete, http.MethodPatch}
+
+// methodPattern matches a valid method filter.
+var methodPattern = regexp.MustCompile(`^[A-Z]{1,16}$`)
+
+// capturesData is the data of the captures page.
+type capturesData struct {
+	Supported  bool
+	Backend    string
+	Method     string   // Method filtered on, empty for all
+	Methods    []string // Methods offered as filters
+	Captures   []stats.RequestCapture
+	Pagination pagination
+}
+
+// captureData is the data of the capture detail page.
+type captureData struct {
+	Supported bool
+	Backend   string
+	Error     string // Invalid ID, empty otherwise
+	Found     bool
+	Capture   stats.RequestCapture
+	View      string // How the body is shown: "text" or "hex"
+	Binary    bool   // The body is not printable text
+	Body      string // The body, as text or a hex dump
+}
+
+// isPrintable reports whether data is UTF-8 text without control
+// characters other than tabs and line breaks, so that it can be shown as
+// text.
+func isPrintable(data []byte) bool {
+	if !utf8.Valid(data) {
+		return false
+	}
+	for _, r := range string(data) {
+		if unicode.IsControl(r) && r != '\t' && r != '\n' && r != '\r' {
+			return false
+		}
+	}
+	return true
+}
+
+// HandleCaptures handles requests to the admin UI captures page, which
+// lists the methods, query strings and body samples of trap requests,
+// newest first.
+//
+// The optional "method" query parameter limits the list to one method.
+//
+// Parameters:
+//   - w: the HTTP response writer
+//   - r: the HTTP request
+func (h *Handler) HandleCaptures(w http.ResponseWriter, r *http.Request) {
+	if !h.authorizePage(w, r) {
+		return
+	}
+
+	store := h.statsManager.Store()
+	data := capturesData{Backend: store.Backend(), Method: r.URL.Query().Get("method"), Methods: captureMethods}
+	if data.Method != "" && !methodPattern.MatchString(data.Method) {
+		http.Error(w, "Invalid method", http.StatusBadRequest)
+		return
+	}
+	if captures, ok := store.(stats.CaptureStore); ok {
+		list, err := captures.GetCaptures(r.Context(), data.Method, maxCaptureRows)
+		if err != nil {
+			h.logger.Error("Failed to get request captures", "error", err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		number := pageNumber(r)
+		var hasNext bool
+		data.Supported = true
+		data.Captures, hasNext = paginate(list, number, pageSize)
+		data.Pagination = newPagination(r.URL, number, hasNext)
+	}
+	h.renderPage(w, "captures", "Captures", data)
+}
+
+// HandleCaptureDetail handles requests to the capture detail page at
+// <admin path>/captures/<id>, which shows one request capture.
+//
+// The body is shown as text if it is printable and as a hex dump
+// otherwise. The "view" query parameter, "text" or "hex", overrides the
+// choice; binary bodies shown as text have invalid bytes replaced.
+//
+// Parameters:
+//   - w: the HTTP response writer
+//   - r: the HTTP request
+func (h *Handler) HandleCaptureDetail(w http.ResponseWriter, r *http.Request) {
+	if !h.authorizePage(w, r) {
+		return
+	}
+
+	store := h.statsManager.Store()
+	data := captureData{Backend: store.Backend()}
+	id, err := strconv.ParseInt(strings.TrimPrefix(r.URL.Path, h.auth.GetPath()+"/captures/"), 10, 64)
+	if err != nil || id <= 0 {
+		data.Error = "Not a valid capture ID."
+		h.renderPageStatus(w, http.StatusBadRequest, "capture", "Capture", data)
+		return
+	}
+
+	captures, ok := store.(stats.CaptureStore)
+	if !ok {
+		h.renderPage(w, "capture", "Capture", data)
+		return
+	}
+	data.Supported = true
+	data.Capture, err = captures.GetCapture(r.Context(), id)
+	switch {
+	case errors.Is(err, stats.ErrNotFound):
+		h.renderPageStatus(w, http.StatusNotFound, "capture", "Capture", data)
+		return
+	case err != nil:
+		h.logger.Error("Failed to get request capture", "id", id, "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	data.Found = true
+
+	body := data.Capture.Body
+	data.Binary = !isPrintable(body)
+	data.View = r.URL.Query().Get("view")
+	if data.View != "text" && data.View != "hex" {
+		data.View = "text"
+		if data.Binary {
+			data.View = "hex"
+		}
+	}
+	if data.View == "hex" {
+		data.Body = hex.Dump(body)
+	} else {
+		data.Body = strings.ToValidUTF8(string(body), "�")
+	}
+	h.renderPage(w, "capture", "Capture", data)
+}
@@ -0,0 +1,106 @@
+package admin
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rampantspark/gospidertrap/internal/stats"
+)
+
+// newCapturesHandler returns a handler over a memory store holding a text
+// and a binary request capture, with IDs 1 and 2.
+func newCapturesHandler(t *testing.T) *Handler {
+	t.Helper()
+	store := stats.NewMemoryStore(stats.NewStats())
+	now := time.Now()
+	for _, c := range []stats.RequestCapture{
+		{Timestamp: now.Add(-time.Minute), IP: "192.0.2.1", Method: "POST", Path: "/login", Query: "next=<x>",
+			ContentType: "application/json", BodySize: 29, Body: []byte(`{"user":"<script>alert(1)"}`)},
+		{Timestamp: now, IP: "192.0.2.2", Method: "PUT", Path: "/upload", ContentType: "application/octet-stream",
+			BodySize: 9000, Body: []byte("\x00\x01<b>\xff"), Truncated: true},
+	} {
+		if err := store.SaveCapture(context.Background(), c); err != nil {
+			t.Fatalf("SaveCapture() error = %v", err)
+		}
+	}
+	return newTestHandler(t, store)
+}
+
+func TestHandleCaptures(t *testing.T) {
+	h := newCapturesHandler(t)
+
+	tests := []struct {
+		name       string
+		query      string
+		wantStatus int
+		wantIPs    []string
+	}{
+		{"all", "", http.StatusOK, []string{"192.0.2.1", "192.0.2.2"}},
+		{"by method", "?method=PUT", http.StatusOK, []string{"192.0.2.2"}},
+		{"no matches", "?method=DELETE", http.StatusOK, nil},
+		{"invalid method", "?method=<x>", http.StatusBadRequest, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := get(t, h, h.HandleCaptures, h.GetPath()+"/captures"+tt.query)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			body := w.Body.String()
+			if rows := strings.Count(body, `<td class="ip">`); rows != len(tt.wantIPs) {
+				t.Errorf("rows = %d, want %d", rows, len(tt.wantIPs))
+			}
+			for _, ip := range tt.wantIPs {
+				if !strings.Contains(body, "/ips/"+ip+`">`) {
+					t.Errorf("page does not link %s", ip)
+				}
+			}
+		})
+	}
+}
+
+func TestHandleCaptureDetail(t *testing.T) {
+	h := newCapturesHandler(t)
+
+	tests := []struct {
+		name       string
+		path       string
+		wantStatus int
+		want       []string
+		notWant    []string
+	}{
+		{"text body", "/captures/1", http.StatusOK,
+			[]string{`{&#34;user&#34;:&#34;&lt;script&gt;alert(1)&#34;}`, "next=&lt;x&gt;", `<a href="?view=text" class="active">`},
+			[]string{"<script>"}},
+		{"binary body shown as hex", "/captures/2", http.StatusOK,
+			[]string{"00 01 3c 62 3e ff", "Binary data", "sample truncated to 6 bytes"},
+			[]string{"<b>"}},
+		{"binary body as text", "/captures/2?view=text", http.StatusOK,
+			[]string{"&lt;b&gt;", "�"}, []string{"<b>"}},
+		{"text body as hex", "/captures/1?view=hex", http.StatusOK, []string{"7b 22 75 73"}, nil},
+		{"unknown ID", "/captures/99", http.StatusNotFound, []string{"No such capture"}, nil},
+		{"invalid ID", "/captures/abc", http.StatusBadRequest, []string{"Not a valid capture ID"}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := get(t, h, h.HandleCaptureDetail, h.GetPath()+tt.path)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			body := w.Body.String()
+			for _, want := range tt.want {
+				if !strings.Contains(body, want) {
+					t.Errorf("page missing %q", want)
+				}
+			}
+			for _, notWant := range tt.notWant {
+				if strings.Contains(body, notWant) {
+					t.Errorf("page contains unescaped %q", notWant)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,360 @@
+package admin
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/netip"
+	"strings"
+	"time"
+
+	"github.com/rampantspark/gospidertrap/internal/ban"
+	"github.com/rampantspark/gospidertrap/internal/geoip"
+	"github.com/rampantspark/gospidertrap/internal/stats"
+)
+
+// Detail page limits
+const (
+	// User agents, IPs, paths, sessions and recent requests shown on a detail page
+	detailRows = 100
+	// Time allowed for the reverse DNS lookup on the IP detail page
+	reverseDNSTimeout = 2 * time.Second
+	// Reason recorded for bans issued from the admin UI without one
+	defaultBanReason = "Banned from the admin UI"
+)
+
+// banDurations are the ban lengths offered on the IP detail page.
+var banDurations = []struct {
+	Label    string
+	Duration time.Duration
+}{
+	{"1 hour", time.Hour},
+	{"1 day", 24 * time.Hour},
+	{"1 week", 7 * 24 * time.Hour},
+	{"30 days", 30 * 24 * time.Hour},
+}
+
+// timelineView is a stats.Timeline prepared for display.
+type timelineView struct {
+	Interval string // "hour", "day" or "week"
+	Max      int    // Largest bucket, the scale of every bar
+	Buckets  []timelineBucket
+}
+
+// timelineBucket is one row of a timelineView.
+type timelineBucket struct {
+	Label    string
+	Requests int
+}
+
+// newTimelineView formats a timeline's buckets for display. Buckets are
+// aligned to UTC, so they are labelled in UTC.
+func newTimelineView(t stats.Timeline) timelineView {
+	view := timelineView{Interval: "week", Max: t.Max()}
+	layout := "2006-01-02"
+	switch t.Interval {
+	case time.Hour:
+		view.Interval, layout = "hour", "2006-01-02 15:04"
+	case 24 * time.Hour:
+		view.Interval = "day"
+	}
+	for _, b := range t.Buckets {
+		view.Buckets = append(view.Buckets, timelineBucket{Label: b.Start.UTC().Format(layout), Requests: b.Requests})
+	}
+	return view
+}
+
+// ipDetailData is the data of the IP detail page.
+type ipDetailData struct {
+	Supported  bool
+	Backend    string
+	Error      string // Invalid address, empty otherwise
+	IP         string
+	Found      bool // The IP has recorded requests
+	Detail     stats.IPDetail
+	Timeline   timelineView
+	Hostnames  []string // Reverse DNS names, empty if the lookup failed
+	ShowGeo    bool     // Whether GeoIP databases are loaded
+	Geo        geoip.Info
+	Network    string   // Ban key of the IP's autonomous system, empty if unknown
+	Ban        *ban.Ban // Active ban, nil if not banned
+	NetworkBan *ban.Ban // Active ban of the IP's network, nil if not banned
+	Allowed    bool     // The IP is on the ban allowlist
+	Durations  []string // Ban lengths offered, as labels
+	Self       string   // This page, where the ban forms return to
+}
+
+// userAgentDetailData is the data of the user agent detail page.
+type userAgentDetailData struct {
+	Supported bool
+	Backend   string
+	UserAgent string
+	Found     bool // The user agent has recorded requests
+	Detail    stats.UserAgentDetail
+	Timeline  timelineView
+}
+
+// HandleIPDetail handles requests to the IP detail page at
+// <admin path>/ips/<ip>, which shows everything recorded about one IP
+// address along with its reverse DNS names and ban status, and offers ban
+// and allowlist actions.
+//
+// Unknown addresses receive 404 Not Found but keep the actions, so an IP
+// can be banned or allowlisted before it is first seen. Malformed addresses
+// receive 400 Bad Request.
+//
+// Parameters:
+//   - w: the HTTP response writer
+//   - r: the HTTP request
+func (h *Handler) HandleIPDetail(w http.ResponseWriter, r *http.Request) {
+	if !h.authorizePage(w, r) {
+		return
+	}
+
+	store := h.statsManager.Store()
+	data := ipDetailData{Backend: store.Backend()}
+	addr, err := netip.ParseAddr(strings.TrimPrefix(r.URL.Path, h.auth.GetPath()+"/ips/"))
+	if err != nil {
+		data.Error = "Not a valid IP address."
+		h.renderPageStatus(w, http.StatusBadRequest, "ip", "IP Address", data)
+		return
+	}
+	data.IP = addr.String()
+	data.Self = ipDetailPath(h.auth.GetPath(), data.IP)
+	if b, ok := h.bans.Get(data.IP); ok {
+		data.Ban = &b
+	}
+	data.Allowed = h.bans.IsAllowed(data.IP)
+	for _, d := range banDurations {
+		data.Durations = append(data.Durations, d.Label)
+	}
+	data.Hostnames = h.reverseDNS(r.Context(), data.IP)
+	if geo := h.statsManager.GeoIP(); geo != nil {
+		data.ShowGeo = true
+		data.Geo = geo.Lookup(data.IP)
+		if data.Geo.ASN != 0 {
+			data.Network = geoip.ASNKey(data.Geo.ASN)
+			if b, ok := h.bans.Get(data.Network); ok {
+				data.NetworkBan = &b
+			}
+		}
+	}
+
+	status := http.StatusOK
+	if reporter, ok := store.(stats.DetailReporter); ok {
+		data.Supported = true
+		data.Detail, err = reporter.GetIPDetail(r.Context(), data.IP, detailRows)
+		switch {
+		case errors.Is(err, stats.ErrNotFound):
+			status = http.StatusNotFound
+		case err != nil:
+			h.logger.Error("Failed to get IP detail", "ip", data.IP, "error", err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		default:
+			data.Found = true
+			data.Timeline = newTimelineView(data.Detail.Timeline)
+		}
+	}
+
+	h.renderPageStatus(w, status, "ip", data.IP, data)
+}
+
+// HandleUserAgentDetail handles requests to the user agent detail page at
+// <admin path>/useragents/view?ua=<user agent>, which shows the IPs using
+// a user agent and its activity over time. User agents can contain any
+// character, so they are passed in the query rather than the path.
+//
+// Unknown user agents receive 404 Not Found.
+//
+// Parameters:
+//   - w: the HTTP response writer
+//   - r: the HTTP request
+func (h *Handler) HandleUserAgentDetail(w http.ResponseWriter, r *http.Request) {
+	if !h.authorizePage(w, r) {
+		return
+	}
+
+	store := h.statsManager.Store()
+	data := userAgentDetailData{Backend: store.Backend(), UserAgent: r.URL.Query().Get("ua")}
+	status := http.StatusOK
+	if reporter, ok := store.(stats.DetailReporter); ok {
+		data.Supported = true
+		var err error
+		data.Detail, err = reporter.GetUserAgentDetail(r.Context(), data.UserAgent, detailRows)
+		switch {
+		case errors.Is(err, stats.ErrNotFound):
+			status = http.StatusNotFound
+		case err != nil:
+			h.logger.Error("Failed to get user agent detail", "error", err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		default:
+			data.Found = true
+			data.Timeline = newTimelineView(data.Detail.Timeline)
+		}
+	}
+
+	h.renderPageStatus(w, status, "useragent", "User Agent", data)
+}
+
+// reverseDNS looks up the names of ip, giving up after reverseDNSTimeout.
+//
+// Returns the names without their trailing dots, or nil if the lookup
+// failed.
+func (h *Handler) reverseDNS(ctx context.Context, ip string) []string {
+	ctx, cancel := context.WithTimeout(ctx, reverseDNSTimeout)
+	defer cancel()
+
+	names, err := h.lookupAddr(ctx, ip)
+	if err != nil {
+		h.logger.Debug("Reverse DNS lookup failed", "ip", ip, "error", err)
+		return nil
+	}
+	for i, name := range names {
+		names[i] = strings.TrimSuffix(name, ".")
+	}
+	return names
+}
+
+// HandleBan handles requests to ban an IP address or a network from the
+// admin UI.
+//
+// It accepts a POST with the IP address or network key, such as
+// "AS64496", in the "ip" form field, the ban length as one of the offered
+// labels in "duration" and an optional "reason", bans it under the manual
+// rule and redirects back.
+//
+// Parameters:
+//   - w: the HTTP response writer
+//   - r: the HTTP request
+func (h *Handler) HandleBan(w http.ResponseWriter, r *http.Request) {
+	if !h.authorizeAction(w, r) {
+		return
+	}
+
+	key, ok := banKey(r.PostFormValue("ip"))
+	if !ok {
+		http.Error(w, "Invalid IP address or network", http.StatusBadRequest)
+		return
+	}
+	var ttl time.Duration
+	for _, d := range banDurations {
+		if d.Label == r.PostFormValue("duration") {
+			ttl = d.Duration
+		}
+	}
+	if ttl == 0 {
+		http.Error(w, "Invalid ban duration", http.StatusBadRequest)
+		return
+	}
+	reason := strings.TrimSpace(r.PostFormValue("reason"))
+	if reason == "" {
+		reason = defaultBanReason
+	}
+
+	if b, added := h.bans.Add(key, ban.RuleManual, reason, ttl); added {
+		h.logger.Info("IP banned from admin UI", "ip", b.IP, "reason", b.Reason, "expires", b.ExpiresAt)
+	}
+	h.redirectBack(w, r)
+}
+
+// HandleAllow handles requests to add an IP address to the ban allowlist,
+// which also revokes any ban.
+//
+// It accepts a POST with the IP address in the "ip" form field and
+// redirects back.
+//
+// Parameters:
+//   - w: the HTTP response writer
+//   - r: the HTTP request
+func (h *Handler) HandleAllow(w http.ResponseWriter, r *http.Request) {
+	if !h.authorizeAction(w, r) {
+		return
+	}
+
+	addr, err := netip.ParseAddr(r.PostFormValue("ip"))
+	if err != nil {
+		http.Error(w, "Invalid IP address", http.StatusBadRequest)
+		return
+	}
+	if h.bans.Allow(addr.String()) {
+		h.logger.Info("IP added to ban allowlist", "ip", addr.String())
+	}
+	h.redirectBack(w, r)
+}
+
+// HandleDisallow handles requests to remove an IP address from the ban
+// allowlist.
+//
+// It accepts a POST with the IP address in the "ip" form field and
+// redirects back.
+//
+// Parameters:
+//   - w: the HTTP response writer
+//   - r: the HTTP request
+func (h *Handler) HandleDisallow(w http.ResponseWriter, r *http.Request) {
+	if !h.authorizeAction(w, r) {
+		return
+	}
+
+	ip := r.PostFormValue("ip")
+	if h.bans.Disallow(ip) {
+		h.logger.Info("IP removed from ban allowlist", "ip", ip)
+	}
+	h.redirectBack(w, r)
+}
+
+// authorizeAction checks that a form submission is an authenticated POST
+// with a valid CSRF token, and writes an error if not.
+//
+// Returns true if the action may proceed.
+func (h *Handler) authorizeAction(w http.ResponseWriter, r *http.Request) bool {
+	h.setSecurityHeaders(w)
+
+	if !h.auth.IsAuthenticated(r) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return false
+	}
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return false
+	}
+	if !h.auth.ValidateCSRF(r) {
+		http.Error(w, "Invalid CSRF token", http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
+// redirectBack redirects a form submission to the admin page in its "next"
+// field, or to the overview if the field is missing or points anywhere
+// outside the admin UI.
+func (h *Handler) redirectBack(w http.ResponseWriter, r *http.Request) {
+	target := h.auth.GetPath()
+	if next := r.PostFormValue("next"); strings.HasPrefix(next, target+"/") && !strings.ContainsAny(next, "\\\r\n") {
+		target = next
+	}
+	http.Redirect(w, r, target, http.StatusSeeOther)
+}
+
+// banKey validates a ban form's "ip" field, which holds an IP address or
+// an autonomous system key such as "AS64496".
+//
+// Returns the normalised key and true, or false if the value is neither.
+func banKey(value string) (string, bool) {
+	if addr, err := netip.ParseAddr(value); err == nil {
+		return addr.String(), true
+	}
+	asn, ok := geoip.ParseASNKey(value)
+	if !ok {
+		return "", false
+	}
+	return geoip.ASNKey(asn), true
+}
+
+// ipDetailPath returns the path of an IP's detail page.
+func ipDetailPath(adminPath, ip string) string {
+	return adminPath + "/ips/" + ip
+}
@@ -0,0 +1,202 @@
+package admin
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rampantspark/gospidertrap/internal/ban"
+	"github.com/rampantspark/gospidertrap/internal/geoip"
+	"github.com/rampantspark/gospidertrap/internal/stats"
+)
+
+// newDetailHandler returns a handler over a SQLite store holding two
+// requests from 10.0.0.1, with reverse DNS answered locally.
+func newDetailHandler(t *testing.T) *Handler {
+	t.Helper()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	db, err := stats.NewDatabase(filepath.Join(t.TempDir(), "stats.db"), logger)
+	if err != nil {
+		t.Fatalf("NewDatabase() error = %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	h := newTestHandler(t, db)
+	h.lookupAddr = func(ctx context.Context, addr string) ([]string, error) {
+		if addr == "10.0.0.1" {
+			return []string{"crawler.example.com."}, nil
+		}
+		return nil, errors.New("no such host")
+	}
+	record(t, db, []string{"10.0.0.1"}, 2)
+	return h
+}
+
+func TestHandleIPDetail(t *testing.T) {
+	h := newDetailHandler(t)
+
+	tests := []struct {
+		name       string
+		ip         string
+		wantStatus int
+		wantBody   []string
+	}{
+		{"recorded IP", "10.0.0.1", http.StatusOK, []string{
+			"crawler.example.com", "/page-1", "<meter", "Never ban this address",
+			"/useragents/view?ua=%3cscript%3ealert%281%29%3c%2fscript%3e",
+		}},
+		{"unknown IP keeps the actions", "192.0.2.1", http.StatusNotFound, []string{"No requests recorded", "/bans/add"}},
+		{"invalid IP", "not-an-ip", http.StatusBadRequest, []string{"Not a valid IP address"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := get(t, h, h.HandleIPDetail, h.GetPath()+"/ips/"+tt.ip)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			body := w.Body.String()
+			for _, want := range tt.wantBody {
+				if !strings.Contains(body, want) {
+					t.Errorf("page does not contain %q", want)
+				}
+			}
+			if strings.Contains(body, "<script>alert") {
+				t.Error("page contains an unescaped user agent")
+			}
+		})
+	}
+
+	// The ban status reflects the ban list
+	h.bans.Add("10.0.0.1", ban.RuleManual, "testing", time.Hour)
+	if body := get(t, h, h.HandleIPDetail, h.GetPath()+"/ips/10.0.0.1").Body.String(); !strings.Contains(body, "Revoke ban") {
+		t.Error("banned IP does not offer to revoke the ban")
+	}
+
+	// The page shows whether a claimed search engine bot was verified
+	store := h.statsManager.Store().(stats.BotVerificationStore)
+	if err := store.SaveBotVerification(context.Background(), stats.BotVerification{IP: "10.0.0.1", Bot: "Googlebot", Status: stats.BotSpoofed, CheckedAt: time.Now()}); err != nil {
+		t.Fatalf("SaveBotVerification() error = %v", err)
+	}
+	if body := get(t, h, h.HandleIPDetail, h.GetPath()+"/ips/10.0.0.1").Body.String(); !strings.Contains(body, `Googlebot: <span class="bot-spoofed">spoofed</span>`) {
+		t.Error("IP page does not show the bot verification")
+	}
+}
+
+func TestHandleUserAgentDetail(t *testing.T) {
+	h := newDetailHandler(t)
+
+	w := get(t, h, h.HandleUserAgentDetail, h.GetPath()+"/useragents/view?ua="+url.QueryEscape("<script>alert(1)</script>"))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusOK)
+	}
+	if body := w.Body.String(); !strings.Contains(body, `href="`+h.GetPath()+`/ips/10.0.0.1"`) || strings.Contains(body, "<script>alert") {
+		t.Error("page does not link the IP using the user agent, or leaves the user agent unescaped")
+	}
+
+	if w := get(t, h, h.HandleUserAgentDetail, h.GetPath()+"/useragents/view?ua=wget"); w.Code != http.StatusNotFound {
+		t.Errorf("unknown user agent status = %d, want %d", w.Code, http.StatusNotFound)
+	}
+}
+
+// post submits an authenticated form with a valid CSRF token.
+func post(t *testing.T, h *Handler, handler http.HandlerFunc, path string, form url.Values) *httptest.ResponseRecorder {
+	t.Helper()
+	form.Set(csrfFieldName, h.auth.CSRFToken())
+	r := httptest.NewRequest(http.MethodPost, path, strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	r.AddCookie(&http.Cookie{Name: cookieName, Value: h.auth.GetToken()})
+	w := httptest.NewRecorder()
+	handler(w, r)
+	return w
+}
+
+func TestBanActions(t *testing.T) {
+	h := newTestHandler(t, stats.NewMemoryStore(stats.NewStats()))
+	self := h.GetPath() + "/ips/10.0.0.1"
+
+	w := post(t, h, h.HandleBan, h.GetPath()+"/bans/add", url.Values{"ip": {"10.0.0.1"}, "duration": {"1 day"}, "next": {self}})
+	if w.Code != http.StatusSeeOther || w.Header().Get("Location") != self {
+		t.Errorf("ban status = %d to %q, want %d back to the IP page", w.Code, w.Header().Get("Location"), http.StatusSeeOther)
+	}
+	if b, ok := h.bans.Get("10.0.0.1"); !ok || b.Rule != ban.RuleManual || b.Reason != defaultBanReason {
+		t.Errorf("ban = %+v, %v, want a manual ban with the default reason", b, ok)
+	}
+
+	post(t, h, h.HandleAllow, h.GetPath()+"/bans/allow", url.Values{"ip": {"10.0.0.1"}})
+	if h.bans.IsBanned("10.0.0.1") || !h.bans.IsAllowed("10.0.0.1") {
+		t.Error("allowing an IP did not revoke its ban and allowlist it")
+	}
+	post(t, h, h.HandleDisallow, h.GetPath()+"/bans/disallow", url.Values{"ip": {"10.0.0.1"}})
+	if h.bans.IsAllowed("10.0.0.1") {
+		t.Error("IP still allowlisted after disallow")
+	}
+
+	tests := []struct {
+		name       string
+		form       url.Values
+		wantStatus int
+		wantTarget string
+	}{
+		{"unknown duration", url.Values{"ip": {"10.0.0.2"}, "duration": {"1 year"}}, http.StatusBadRequest, ""},
+		{"invalid IP", url.Values{"ip": {"10.0.0"}, "duration": {"1 hour"}}, http.StatusBadRequest, ""},
+		{"network", url.Values{"ip": {"AS64496"}, "duration": {"1 hour"}}, http.StatusSeeOther, h.GetPath()},
+		{"invalid network", url.Values{"ip": {"AS-1"}, "duration": {"1 hour"}}, http.StatusBadRequest, ""},
+		{"redirect outside the admin UI", url.Values{"ip": {"10.0.0.2"}, "duration": {"1 hour"}, "next": {"https://example.com/"}}, http.StatusSeeOther, h.GetPath()},
+		{"protocol-relative redirect", url.Values{"ip": {"10.0.0.2"}, "duration": {"1 hour"}, "next": {"//example.com" + h.GetPath() + "/"}}, http.StatusSeeOther, h.GetPath()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := post(t, h, h.HandleBan, h.GetPath()+"/bans/add", tt.form)
+			if w.Code != tt.wantStatus || w.Header().Get("Location") != tt.wantTarget {
+				t.Errorf("status = %d to %q, want %d to %q", w.Code, w.Header().Get("Location"), tt.wantStatus, tt.wantTarget)
+			}
+		})
+	}
+}
+
+func TestGeoPages(t *testing.T) {
+	h := newTestHandler(t, stats.NewMemoryStore(stats.NewStats()))
+	geo, err := geoip.NewResolver("../geoip/testdata/city.mmdb", "../geoip/testdata/asn.mmdb")
+	if err != nil {
+		t.Fatalf("NewResolver() error = %v", err)
+	}
+	t.Cleanup(func() { geo.Close() })
+	h.statsManager.SetGeoIP(geo)
+	h.lookupAddr = func(ctx context.Context, addr string) ([]string, error) {
+		return nil, errors.New("no such host")
+	}
+	record(t, h.statsManager.Store(), []string{"10.0.0.1", "192.0.2.1"}, 2)
+
+	body := get(t, h, h.HandleUI, h.GetPath()).Body.String()
+	for _, want := range []string{`id="countryChart"`, "<td>US</td>", "<td>AS64497 Example Transit</td>"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("overview does not contain %q", want)
+		}
+	}
+
+	self := h.GetPath() + "/ips/10.0.0.1"
+	body = get(t, h, h.HandleIPDetail, self).Body.String()
+	for _, want := range []string{"<td>US</td>", "<td>AS64496 Example Hosting</td>", "Ban AS64496"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("IP page does not contain %q", want)
+		}
+	}
+
+	post(t, h, h.HandleBan, h.GetPath()+"/bans/add", url.Values{"ip": {"AS64496"}, "duration": {"1 hour"}, "next": {self}})
+	if !h.bans.IsBanned("AS64496") || h.bans.IsBanned("10.0.0.1") {
+		t.Fatal("network ban not recorded under the network key")
+	}
+	if body := get(t, h, h.HandleIPDetail, self).Body.String(); !strings.Contains(body, "Revoke network ban") {
+		t.Error("IP page does not offer to revoke the network ban")
+	}
+	if body := get(t, h, h.HandleUI, h.GetPath()).Body.String(); !strings.Contains(body, `<td class="ip">AS64496</td>`) {
+		t.Error("overview does not list the network ban without an IP link")
+	}
+}
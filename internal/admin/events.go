@@ -0,0 +1,145 @@
+package admin
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/rampantspark/gospidertrap/internal/replication"
+	"github.com/rampantspark/gospidertrap/internal/stats"
+)
+
+// DefaultSummaryInterval is how often the event stream sends a summary.
+const DefaultSummaryInterval = 5 * time.Second
+
+// eventBuffer is the number of request events that can wait for a slow
+// client before further events are dropped.
+const eventBuffer = 256
+
+// requestEvent is the data of a "request" event.
+type requestEvent struct {
+	Timestamp      string `json:"timestamp"`
+	IP             string `json:"ip"`
+	UserAgent      string `json:"userAgent"`
+	Path           string `json:"path"`
+	IPCount        int    `json:"ipCount"`        // Requests from the IP so far
+	UserAgentCount int    `json:"userAgentCount"` // Requests with the user agent so far
+}
+
+// summaryEvent is the data of a "summary" event.
+type summaryEvent struct {
+	Uptime           string                   `json:"uptime"`
+	TotalRequests    int                      `json:"totalRequests"`
+	UniqueIPs        int                      `json:"uniqueIPs"`
+	UniqueUserAgents int                      `json:"uniqueUserAgents"`
+	NewRequests      int                      `json:"newRequests"` // Requests since the previous summary
+	Dropped          int64                    `json:"dropped"`     // Request events this stream missed
+	Charts           stats.ChartData          `json:"charts"`
+	Nodes            []replication.NodeStatus `json:"nodes,omitempty"`
+}
+
+// HandleEvents streams live updates to the admin UI as Server-Sent Events.
+//
+// Every recorded request is sent as a "request" event, and every summary
+// interval a "summary" event carries the totals, the number of requests
+// since the previous summary, the chart data and the replication node
+// status. The stream ends when the client disconnects or the stats hub is
+// closed on shutdown.
+//
+// Parameters:
+//   - w: the HTTP response writer
+//   - r: the HTTP request
+func (h *Handler) HandleEvents(w http.ResponseWriter, r *http.Request) {
+	h.setSecurityHeaders(w)
+
+	// Validate authentication
+	if !h.auth.IsAuthenticated(r) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	// The stream outlives the server's write timeout
+	rc := http.NewResponseController(w)
+	rc.SetWriteDeadline(time.Time{})
+
+	sub := h.statsManager.Hub().Subscribe(eventBuffer)
+	defer sub.Close()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no") // Disable proxy buffering
+	w.WriteHeader(http.StatusOK)
+
+	ctx := r.Context()
+	lastTotal := -1
+	sendSummary := func() error {
+		uptime, totalRequests, uniqueIPs, uniqueUAs := h.statsManager.GetStats(ctx)
+		summary := summaryEvent{
+			Uptime:           uptime.Round(time.Second).String(),
+			TotalRequests:    totalRequests,
+			UniqueIPs:        uniqueIPs,
+			UniqueUserAgents: uniqueUAs,
+			Dropped:          sub.Dropped(),
+			Charts:           h.statsManager.GetChartData(ctx, 10, 50), // top 10 items, max 50 char user agents
+		}
+		if lastTotal >= 0 {
+			summary.NewRequests = max(totalRequests-lastTotal, 0)
+		}
+		lastTotal = totalRequests
+		if h.nodes != nil {
+			summary.Nodes = h.nodes()
+		}
+		return writeEvent(w, "summary", summary)
+	}
+
+	if err := sendSummary(); err != nil {
+		return
+	}
+	if err := rc.Flush(); err != nil {
+		h.logger.Warn("Event stream not supported by response writer", "error", err)
+		return
+	}
+
+	ticker := time.NewTicker(h.summaryInterval)
+	defer ticker.Stop()
+
+	for {
+		var err error
+		select {
+		case <-ctx.Done():
+			return
+		case e, ok := <-sub.Events():
+			if !ok {
+				return // Shutting down
+			}
+			err = writeEvent(w, "request", requestEvent{
+				Timestamp:      e.Request.Timestamp.Format("2006-01-02 15:04:05"),
+				IP:             e.Request.IP,
+				UserAgent:      e.Request.UserAgent,
+				Path:           e.Request.Path,
+				IPCount:        e.Result.IPCount,
+				UserAgentCount: e.Result.UserAgentCount,
+			})
+		case <-ticker.C:
+			err = sendSummary()
+		}
+		if err == nil {
+			err = rc.Flush()
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// writeEvent writes a Server-Sent Event with JSON data.
+func writeEvent(w io.Writer, event string, data any) error {
+	b, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s event: %w", event, err)
+	}
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, b)
+	return err
+}
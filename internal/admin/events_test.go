@@ -0,0 +1,151 @@
+package admin
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rampantspark/gospidertrap/internal/replication"
+	"github.com/rampantspark/gospidertrap/internal/stats"
+)
+
+// readEvent reads the next Server-Sent Event from the stream.
+func readEvent(t *testing.T, r *bufio.Reader) (event, data string) {
+	t.Helper()
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatalf("reading event stream: %v", err)
+		}
+		line = strings.TrimSuffix(line, "\n")
+		switch {
+		case line == "":
+			return event, data
+		case strings.HasPrefix(line, "event: "):
+			event = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			data = strings.TrimPrefix(line, "data: ")
+		}
+	}
+}
+
+func TestHandleEvents(t *testing.T) {
+	h := newTestHandler(t, stats.NewMemoryStore(stats.NewStats()))
+	h.summaryInterval = 50 * time.Millisecond
+	h.SetNodeStatus(func() []replication.NodeStatus {
+		return []replication.NodeStatus{{Name: "trap-b", Received: 7}}
+	})
+	srv := httptest.NewServer(http.HandlerFunc(h.HandleEvents))
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("unauthenticated status = %d, want %d", resp.StatusCode, http.StatusForbidden)
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+	req.Header.Set("Authorization", "Bearer "+h.auth.GetToken())
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("Content-Type = %q, want text/event-stream", ct)
+	}
+	stream := bufio.NewReader(resp.Body)
+
+	// A summary is sent as soon as the stream opens
+	event, data := readEvent(t, stream)
+	var summary summaryEvent
+	if err := json.Unmarshal([]byte(data), &summary); event != "summary" || err != nil {
+		t.Fatalf("first event = %s %s, want a summary", event, data)
+	}
+	if len(summary.Nodes) != 1 || summary.Nodes[0].Received != 7 {
+		t.Errorf("summary nodes = %+v, want trap-b", summary.Nodes)
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/trap", nil)
+	r.RemoteAddr = "10.0.0.1:1234"
+	r.Header.Set("User-Agent", "bot")
+	if err := h.statsManager.RecordRequest(context.Background(), r); err != nil {
+		t.Fatalf("RecordRequest() error = %v", err)
+	}
+
+	// The request arrives as it is recorded and the next summary counts it
+	var gotRequest, gotSummary bool
+	for !gotRequest || !gotSummary {
+		event, data := readEvent(t, stream)
+		switch event {
+		case "request":
+			var e requestEvent
+			if err := json.Unmarshal([]byte(data), &e); err != nil {
+				t.Fatalf("invalid request event %s: %v", data, err)
+			}
+			if e.IP != "10.0.0.1" || e.Path != "/trap" || e.UserAgent != "bot" || e.IPCount != 1 {
+				t.Errorf("request event = %+v", e)
+			}
+			gotRequest = true
+		case "summary":
+			if err := json.Unmarshal([]byte(data), &summary); err != nil {
+				t.Fatalf("invalid summary event %s: %v", data, err)
+			}
+			if summary.TotalRequests == 1 {
+				if summary.NewRequests != 1 || summary.UniqueIPs != 1 || len(summary.Charts.TopIPs.Labels) != 1 {
+					t.Errorf("summary = %+v, want one new request", summary)
+				}
+				gotSummary = true
+			}
+		}
+	}
+
+	// Closing the hub on shutdown ends the stream
+	h.statsManager.Hub().Close()
+	done := make(chan error, 1)
+	go func() {
+		_, err := stream.ReadString(0)
+		done <- err
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("event stream still open after the hub closed")
+	}
+}
+
+func TestHandleUINodes(t *testing.T) {
+	h := newTestHandler(t, stats.NewMemoryStore(stats.NewStats()))
+
+	render := func() string {
+		r := httptest.NewRequest(http.MethodGet, h.GetPath(), nil)
+		r.Header.Set("Authorization", "Bearer "+h.auth.GetToken())
+		w := httptest.NewRecorder()
+		h.HandleUI(w, r)
+		return w.Body.String()
+	}
+
+	if body := render(); strings.Contains(body, "Replication Nodes") {
+		t.Error("node table shown without a collector")
+	}
+
+	h.SetNodeStatus(func() []replication.NodeStatus {
+		return []replication.NodeStatus{
+			{Name: "trap-<b>", LastSeen: time.Now(), Stale: true},
+		}
+	})
+	body := render()
+	for _, want := range []string{"Replication Nodes", "trap-&lt;b&gt;", `class="node-stale"`, `id="live-status"`} {
+		if !strings.Contains(body, want) {
+			t.Errorf("admin UI does not contain %q", want)
+		}
+	}
+}
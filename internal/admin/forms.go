@@ -0,0 +1,52 @@
+package admin
+
+import (
+	"net/http"
+
+	"github.com/rampantspark/gospidertrap/internal/stats"
+)
+
+// maxSubmissionRows limits the form submissions the forms page loads.
+const maxSubmissionRows = 1000
+
+// formsData is the data of the forms page.
+type formsData struct {
+	Supported       bool
+	Backend         string
+	HoneytokensOnly bool
+	Submissions     []stats.FormSubmission
+	Pagination      pagination
+}
+
+// HandleForms handles requests to the admin UI forms page, which lists
+// trap requests that carried a query string or body, newest first, and
+// marks those that submitted a honeytoken.
+//
+// With the query parameter "honeytokens=1" only submissions carrying a
+// honeytoken are listed.
+//
+// Parameters:
+//   - w: the HTTP response writer
+//   - r: the HTTP request
+func (h *Handler) HandleForms(w http.ResponseWriter, r *http.Request) {
+	if !h.authorizePage(w, r) {
+		return
+	}
+
+	store := h.statsManager.Store()
+	data := formsData{Backend: store.Backend(), HoneytokensOnly: r.URL.Query().Get("honeytokens") == "1"}
+	if forms, ok := store.(stats.FormStore); ok {
+		submissions, err := forms.GetFormSubmissions(r.Context(), data.HoneytokensOnly, maxSubmissionRows)
+		if err != nil {
+			h.logger.Error("Failed to get form submissions", "error", err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		number := pageNumber(r)
+		var hasNext bool
+		data.Supported = true
+		data.Submissions, hasNext = paginate(submissions, number, pageSize)
+		data.Pagination = newPagination(r.URL, number, hasNext)
+	}
+	h.renderPage(w, "forms", "Forms", data)
+}
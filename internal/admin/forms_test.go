@@ -0,0 +1,62 @@
+package admin
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rampantspark/gospidertrap/internal/stats"
+)
+
+func TestHandleForms(t *testing.T) {
+	store := stats.NewMemoryStore(stats.NewStats())
+	now := time.Now()
+	for _, sub := range []stats.FormSubmission{
+		{Timestamp: now.Add(-time.Minute), IP: "192.0.2.1", Method: "GET", Path: "/search", Fields: url.Values{"q": {"<script>"}}},
+		{Timestamp: now, IP: "192.0.2.2", Method: "POST", Path: "/login", Fields: url.Values{"email": {"ops.abc@example.com"}},
+			Honeytoken: "ops.abc@example.com", IssuedTo: "192.0.2.1"},
+		{Timestamp: now, IP: "192.0.2.3", Method: "PUT", Path: "/api", Body: `{"a":1}`},
+	} {
+		if err := store.SaveFormSubmission(context.Background(), sub); err != nil {
+			t.Fatalf("SaveFormSubmission() error = %v", err)
+		}
+	}
+	h := newTestHandler(t, store)
+
+	tests := []struct {
+		name    string
+		query   string
+		wantIPs []string
+		want    []string
+	}{
+		{"all", "", []string{"192.0.2.1", "192.0.2.2", "192.0.2.3"}, []string{
+			"&lt;script&gt;", `<span class="honeytoken-reused">issued to`, "{&#34;a&#34;:1}",
+		}},
+		{"honeytokens only", "?honeytokens=1", []string{"192.0.2.2"}, []string{"ops.abc@example.com"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := get(t, h, h.HandleForms, h.GetPath()+"/forms"+tt.query)
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d, want %d", w.Code, http.StatusOK)
+			}
+			body := w.Body.String()
+			if rows := strings.Count(body, `<td class="ip">`); rows != len(tt.wantIPs) {
+				t.Errorf("rows = %d, want %d", rows, len(tt.wantIPs))
+			}
+			for _, ip := range tt.wantIPs {
+				if !strings.Contains(body, "/ips/"+ip+`">`+ip) {
+					t.Errorf("page does not link %s", ip)
+				}
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(body, want) {
+					t.Errorf("page missing %q", want)
+				}
+			}
+		})
+	}
+}
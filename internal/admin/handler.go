@@ -1,39 +1,95 @@
 package admin
 
 import (
-	"crypto/rand"
-	"encoding/base64"
+	"context"
 	"encoding/json"
-	"html"
+	"errors"
 	"io"
 	"log/slog"
+	"net"
 	"net/http"
+	"time"
 
+	"github.com/rampantspark/gospidertrap/internal/bait"
+	"github.com/rampantspark/gospidertrap/internal/ban"
+	"github.com/rampantspark/gospidertrap/internal/replication"
 	"github.com/rampantspark/gospidertrap/internal/stats"
 )
 
 // Handler handles admin UI HTTP requests.
 type Handler struct {
-	auth         *Authenticator
-	statsManager *stats.Manager
-	renderer     *Renderer
-	logger       *slog.Logger
+	auth            *Authenticator
+	statsManager    *stats.Manager
+	bans            *ban.List
+	renderer        *Renderer
+	logger          *slog.Logger
+	loginHooks      []LoginHook
+	nodes           func() []replication.NodeStatus
+	baits           []bait.Entry // Scanner baits answered by the trap (nil if disabled)
+	settings        []SettingsSection
+	summaryInterval time.Duration
+	lookupAddr      func(ctx context.Context, addr string) ([]string, error) // Reverse DNS lookup
 }
 
+// LoginHook is called after every admin login attempt.
+type LoginHook func(r *http.Request, success bool)
+
 // NewHandler creates a new admin handler.
 //
 // Parameters:
 //   - auth: authenticator instance
 //   - statsManager: stats manager for retrieving data
+//   - bans: ban list to display and manage
 //   - logger: structured logger instance
 //
 // Returns a new Handler instance.
-func NewHandler(auth *Authenticator, statsManager *stats.Manager, logger *slog.Logger) *Handler {
+func NewHandler(auth *Authenticator, statsManager *stats.Manager, bans *ban.List, logger *slog.Logger) *Handler {
 	return &Handler{
-		auth:         auth,
-		statsManager: statsManager,
-		renderer:     NewRenderer(auth.GetPath()),
-		logger:       logger,
+		auth:            auth,
+		statsManager:    statsManager,
+		bans:            bans,
+		renderer:        NewRenderer(auth.GetPath()),
+		logger:          logger,
+		summaryInterval: DefaultSummaryInterval,
+		lookupAddr:      net.DefaultResolver.LookupAddr,
+	}
+}
+
+// SetNodeStatus makes the admin UI show the health of replication nodes.
+//
+// SetNodeStatus must be called before the handler starts serving requests.
+//
+// Parameters:
+//   - nodes: returns the current node status, such as Collector.Nodes
+func (h *Handler) SetNodeStatus(nodes func() []replication.NodeStatus) {
+	h.nodes = nodes
+}
+
+// SetBaits makes the bait page list the scanner baits the trap answers.
+//
+// SetBaits must be called before the handler starts serving requests.
+//
+// Parameters:
+//   - entries: the bait catalog's entries
+func (h *Handler) SetBaits(entries []bait.Entry) {
+	h.baits = entries
+}
+
+// AddLoginHook registers a hook that is called after every login attempt.
+//
+// Hooks run synchronously on the request goroutine and must not block.
+// AddLoginHook must be called before the handler starts serving requests.
+//
+// Parameters:
+//   - hook: the function to call
+func (h *Handler) AddLoginHook(hook LoginHook) {
+	h.loginHooks = append(h.loginHooks, hook)
+}
+
+// runLoginHooks calls every registered login hook.
+func (h *Handler) runLoginHooks(r *http.Request, success bool) {
+	for _, hook := range h.loginHooks {
+		hook(r, success)
 	}
 }
 
@@ -61,9 +117,10 @@ func (h *Handler) HandleLogin(w http.ResponseWriter, r *http.Request) {
 			"ip", ip,
 			"path", r.URL.Path,
 			"user_agent", r.Header.Get("User-Agent"))
+		h.runLoginHooks(r, false)
 
-		// Set security headers (no nonce needed for static error page)
-		h.setSecurityHeaders(w, "")
+		// Set security headers
+		h.setSecurityHeaders(w)
 		w.WriteHeader(http.StatusForbidden)
 		w.Header().Set("Content-Type", "text/html")
 		io.WriteString(w, "<!DOCTYPE html>\n<html>\n<head><title>Access Denied</title></head>\n<body>\n<h1>403 Forbidden</h1>\n<p>Invalid or missing authentication token.</p>\n</body>\n</html>")
@@ -75,6 +132,7 @@ func (h *Handler) HandleLogin(w http.ResponseWriter, r *http.Request) {
 	h.logger.Info("Successful admin login",
 		"ip", ip,
 		"user_agent", r.Header.Get("User-Agent"))
+	h.runLoginHooks(r, true)
 
 	// Set authentication cookie
 	h.auth.SetCookie(w)
@@ -95,8 +153,8 @@ func (h *Handler) HandleChartData(w http.ResponseWriter, r *http.Request) {
 
 	// Validate authentication
 	if !h.auth.IsAuthenticated(r) {
-		// Set security headers (no nonce needed for JSON response)
-		h.setSecurityHeaders(w, "")
+		// Set security headers
+		h.setSecurityHeaders(w)
 		w.WriteHeader(http.StatusForbidden)
 		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid or missing authentication token"})
 		return
@@ -110,111 +168,173 @@ func (h *Handler) HandleChartData(w http.ResponseWriter, r *http.Request) {
 	// Get chart data from stats manager, passing context for cancellation support
 	data := h.statsManager.GetChartData(ctx, 10, 50) // top 10 items, max 50 char user agents
 
-	// Set security headers (no nonce needed for JSON response)
-	h.setSecurityHeaders(w, "")
+	// Set security headers
+	h.setSecurityHeaders(w)
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(data)
 }
 
-// HandleUI handles requests to the admin UI endpoint.
+// RequireAuth wraps a handler so that it is only reachable with a valid
+// admin token. Unauthenticated requests receive 403 Forbidden.
 //
-// It validates authentication via cookie or query parameter (for backward compatibility).
-// If authentication fails, it returns a 403 Forbidden response.
-// Otherwise, it displays connection statistics including total requests, IP counts,
-// user agent counts, and recent request history in a formatted HTML page.
+// Parameters:
+//   - next: the handler to protect
+//
+// Returns the wrapped handler.
+func (h *Handler) RequireAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h.setSecurityHeaders(w)
+		if !h.auth.IsAuthenticated(r) {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// HandleRevokeBan handles requests to revoke an active ban.
+//
+// It accepts a POST with the IP address in the "ip" form field, removes the
+// ban and redirects back to the page in the "next" form field, or to the
+// overview.
 //
 // Parameters:
 //   - w: the HTTP response writer
 //   - r: the HTTP request
-func (h *Handler) HandleUI(w http.ResponseWriter, r *http.Request) {
-	ctx := r.Context()
+func (h *Handler) HandleRevokeBan(w http.ResponseWriter, r *http.Request) {
+	if !h.authorizeAction(w, r) {
+		return
+	}
 
-	// Check if context is cancelled
-	if ctx.Err() != nil {
+	ip := r.PostFormValue("ip")
+	if h.bans.Remove(ip) {
+		h.logger.Info("Ban revoked", "ip", ip)
+	}
+
+	h.redirectBack(w, r)
+}
+
+// HandleLogout handles requests to log out of the admin UI.
+//
+// It accepts a POST from the layout's logout form, clears the
+// authentication cookie and redirects to the admin path, which then asks
+// for a login.
+//
+// Parameters:
+//   - w: the HTTP response writer
+//   - r: the HTTP request
+func (h *Handler) HandleLogout(w http.ResponseWriter, r *http.Request) {
+	h.setSecurityHeaders(w)
+
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	// Validate authentication (supports both cookie and query param for backward compatibility)
+	// Validate authentication
 	if !h.auth.IsAuthenticated(r) {
-		// If token is in query param but not in cookie, set cookie for future requests
-		if token := r.URL.Query().Get("token"); token != "" && h.auth.ValidateToken(token) {
-			h.auth.SetCookie(w)
-		} else {
-			w.WriteHeader(http.StatusForbidden)
-			w.Header().Set("Content-Type", "text/html")
-			io.WriteString(w, "<!DOCTYPE html>\n<html>\n<head><title>Access Denied</title></head>\n<body>\n<h1>403 Forbidden</h1>\n<p>Invalid or missing authentication token.</p>\n<p>Use: <a href=\""+html.EscapeString(h.auth.GetPath())+"/login?token=YOUR_TOKEN\">Login</a></p>\n</body>\n</html>")
-			return
-		}
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
 	}
 
-	// Check context again before processing
-	if ctx.Err() != nil {
+	if !h.auth.ValidateCSRF(r) {
+		http.Error(w, "Invalid CSRF token", http.StatusForbidden)
 		return
 	}
 
-	// Get chart data, passing context for cancellation support
-	chartData := h.statsManager.GetChartData(ctx, 10, 50) // top 10 items, max 50 char user agents
+	h.auth.ClearCookie(w)
+	h.logger.Info("Admin logged out", "ip", r.RemoteAddr)
+	http.Redirect(w, r, h.auth.GetPath(), http.StatusSeeOther)
+}
 
-	// Get stats and recent requests, passing context for cancellation support
-	uptime, totalRequests, uniqueIPs, uniqueUAs := h.statsManager.GetStats(ctx)
-	recentRequests := h.statsManager.GetRecentRequests(ctx, 50) // max 50 recent requests
+// HandleImport handles requests to merge data recorded by another instance.
+//
+// It accepts a POST whose body is an NDJSON export, a gzipped NDJSON
+// request log or a SQLite database, with the recording instance's name in
+// the "source" query parameter, and responds with the import result as JSON.
+// Imports are only supported by stores that implement stats.Importer.
+//
+// Parameters:
+//   - w: the HTTP response writer
+//   - r: the HTTP request
+func (h *Handler) HandleImport(w http.ResponseWriter, r *http.Request) {
+	h.setSecurityHeaders(w)
 
-	// Generate a nonce for inline scripts (CSP security)
-	nonce := h.generateNonce()
+	// Validate authentication
+	if !h.auth.IsAuthenticated(r) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
 
-	// Render HTML with nonce
-	html := h.renderer.RenderAdminUI(
-		chartData,
-		uptime,
-		totalRequests,
-		uniqueIPs,
-		uniqueUAs,
-		recentRequests,
-		50,    // max display
-		nonce, // CSP nonce for inline scripts
-	)
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
 
-	// Set security headers before sending response
-	h.setSecurityHeaders(w, nonce)
-	w.Header().Set("Content-Type", "text/html; charset=utf-8")
-	io.WriteString(w, html)
-}
+	if !h.auth.ValidateCSRF(r) {
+		http.Error(w, "Invalid CSRF token", http.StatusForbidden)
+		return
+	}
 
-// generateNonce generates a cryptographically secure nonce for CSP.
-//
-// Returns a base64-encoded random nonce string suitable for CSP directives.
-func (h *Handler) generateNonce() string {
-	// 16 bytes gives us 128 bits of entropy, encoded to 24 base64 characters
-	nonceBytes := make([]byte, 16)
-	if _, err := rand.Read(nonceBytes); err != nil {
-		// If we can't generate a secure nonce, log the error and use a fallback
-		// This should never happen in practice with a properly functioning system
-		h.logger.Error("Failed to generate CSP nonce", "error", err)
-		return ""
+	source := r.URL.Query().Get("source")
+	if source == "" {
+		http.Error(w, "Missing source parameter", http.StatusBadRequest)
+		return
 	}
-	return base64.StdEncoding.EncodeToString(nonceBytes)
+
+	importer, ok := h.statsManager.Store().(stats.Importer)
+	if !ok {
+		http.Error(w, "Import is not supported by the "+h.statsManager.Store().Backend()+" backend", http.StatusNotImplemented)
+		return
+	}
+
+	// Large uploads can take longer than the server's read and write timeouts
+	rc := http.NewResponseController(w)
+	rc.SetReadDeadline(time.Time{})
+	rc.SetWriteDeadline(time.Time{})
+
+	result, err := importer.Import(r.Context(), r.Body, source)
+	response := struct {
+		stats.ImportResult
+		Error string `json:"error,omitempty"`
+	}{ImportResult: result}
+	status := http.StatusOK
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		switch {
+		case errors.As(err, &maxBytesErr):
+			status = http.StatusRequestEntityTooLarge
+		case errors.Is(err, stats.ErrSchemaTooNew), errors.Is(err, stats.ErrSchemaOutdated):
+			status = http.StatusUnprocessableEntity
+		default:
+			status = http.StatusInternalServerError
+		}
+		response.Error = err.Error()
+		h.logger.Warn("Import failed", "source", source, "imported", result.Imported, "error", err)
+	} else {
+		h.logger.Info("Imported requests",
+			"source", source,
+			"imported", result.Imported,
+			"duplicates", result.Duplicates,
+			"invalid", result.Invalid)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(response)
 }
 
 // setSecurityHeaders sets security headers for admin UI responses.
 //
 // This includes CSP headers to prevent XSS attacks and other security headers
-// to harden the admin interface. A nonce can be provided for inline script execution.
-func (h *Handler) setSecurityHeaders(w http.ResponseWriter, nonce string) {
-	// Build script-src directive with nonce if available
-	scriptSrc := "script-src 'self' https://cdn.jsdelivr.net"
-	if nonce != "" {
-		scriptSrc += " 'nonce-" + nonce + "'"
-	}
-
+// to harden the admin interface. Scripts, styles and connections are limited
+// to the server itself, since the admin UI's assets are embedded. Pages
+// replace the CSP with one that also requires their nonce on scripts.
+func (h *Handler) setSecurityHeaders(w http.ResponseWriter) {
 	// Content Security Policy - restrict resource loading
-	// Allow cdn.jsdelivr.net for both script loading and source map connections
-	w.Header().Set("Content-Security-Policy",
-		"default-src 'self'; "+
-			scriptSrc+"; "+
-			"style-src 'self' 'unsafe-inline'; "+
-			"img-src 'self' data:; "+
-			"connect-src 'self' https://cdn.jsdelivr.net; "+
-			"frame-ancestors 'none'")
+	w.Header().Set("Content-Security-Policy", contentSecurityPolicy(""))
 
 	// Prevent page from being displayed in iframe (clickjacking protection)
 	w.Header().Set("X-Frame-Options", "DENY")
@@ -229,11 +349,40 @@ func (h *Handler) setSecurityHeaders(w http.ResponseWriter, nonce string) {
 	w.Header().Set("X-XSS-Protection", "1; mode=block")
 }
 
+// contentSecurityPolicy returns the admin UI's Content Security Policy.
+//
+// Parameters:
+//   - nonce: the nonce scripts must carry, or empty to allow any script
+//     served by the admin UI
+func contentSecurityPolicy(nonce string) string {
+	scriptSrc := "'self'"
+	if nonce != "" {
+		scriptSrc = "'nonce-" + nonce + "'"
+	}
+	return "default-src 'self'; " +
+		"script-src " + scriptSrc + "; " +
+		"style-src 'self'; " +
+		"img-src 'self' data:; " +
+		"connect-src 'self'; " +
+		"base-uri 'none'; " +
+		"form-action 'self'; " +
+		"frame-ancestors 'none'"
+}
+
 // GetPath returns the admin endpoint path.
 func (h *Handler) GetPath() string {
 	return h.auth.GetPath()
 }
 
+// IsAuthenticated reports whether a request carries the admin token, in
+// its cookie, an Authorization header or the token parameter.
+//
+// Parameters:
+//   - r: the HTTP request
+func (h *Handler) IsAuthenticated(r *http.Request) bool {
+	return h.auth.IsAuthenticated(r)
+}
+
 // GetLoginURL returns the login URL.
 //
 // Parameters:
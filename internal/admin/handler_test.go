@@ -0,0 +1,107 @@
+package admin
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/rampantspark/gospidertrap/internal/ban"
+	"github.com/rampantspark/gospidertrap/internal/stats"
+)
+
+// newTestHandler creates a handler backed by store.
+func newTestHandler(t *testing.T, store stats.Store) *Handler {
+	t.Helper()
+	auth, err := NewAuthenticator(false)
+	if err != nil {
+		t.Fatalf("NewAuthenticator() error = %v", err)
+	}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	return NewHandler(auth, stats.NewManager(store, false, logger), ban.NewList(), logger)
+}
+
+func TestHandleImport(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	db, err := stats.NewDatabase(filepath.Join(t.TempDir(), "stats.db"), logger)
+	if err != nil {
+		t.Fatalf("NewDatabase() error = %v", err)
+	}
+	defer db.Close()
+	h := newTestHandler(t, db)
+	memory := newTestHandler(t, stats.NewMemoryStore(stats.NewStats()))
+
+	body := `{"IP":"10.0.0.1","UserAgent":"bot","Path":"/","Timestamp":"2025-06-10T12:00:00Z"}` + "\n"
+	tests := []struct {
+		name       string
+		handler    *Handler
+		method     string
+		query      string
+		auth       bool
+		wantStatus int
+		wantBody   string
+	}{
+		{"unauthenticated", h, http.MethodPost, "?source=trap-b", false, http.StatusForbidden, "Forbidden"},
+		{"wrong method", h, http.MethodGet, "?source=trap-b", true, http.StatusMethodNotAllowed, ""},
+		{"missing source", h, http.MethodPost, "", true, http.StatusBadRequest, "source"},
+		{"unsupported backend", memory, http.MethodPost, "?source=trap-b", true, http.StatusNotImplemented, "memory"},
+		{"import", h, http.MethodPost, "?source=trap-b", true, http.StatusOK, `"imported":1`},
+		{"reimport", h, http.MethodPost, "?source=trap-b", true, http.StatusOK, `"duplicates":1`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, "/admin/import"+tt.query, strings.NewReader(body))
+			if tt.auth {
+				r.Header.Set("Authorization", "Bearer "+tt.handler.auth.GetToken())
+			}
+			w := httptest.NewRecorder()
+			tt.handler.HandleImport(w, r)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+			if !strings.Contains(w.Body.String(), tt.wantBody) {
+				t.Errorf("body = %q, want it to contain %q", w.Body.String(), tt.wantBody)
+			}
+		})
+	}
+
+	summary, err := db.GetSummary(t.Context())
+	if err != nil {
+		t.Fatalf("GetSummary() error = %v", err)
+	}
+	if summary.TotalRequests != 1 {
+		t.Errorf("TotalRequests = %d, want 1", summary.TotalRequests)
+	}
+}
+
+func TestHandleImportTooLarge(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	db, err := stats.NewDatabase(filepath.Join(t.TempDir(), "stats.db"), logger)
+	if err != nil {
+		t.Fatalf("NewDatabase() error = %v", err)
+	}
+	defer db.Close()
+	h := newTestHandler(t, db)
+
+	body := strings.Repeat(`{"IP":"10.0.0.1","UserAgent":"bot","Path":"/","Timestamp":"2025-06-10T12:00:00Z"}`+"\n", 100)
+	r := httptest.NewRequest(http.MethodPost, "/admin/import?source=trap-b", strings.NewReader(body))
+	r.Header.Set("Authorization", "Bearer "+h.auth.GetToken())
+	w := httptest.NewRecorder()
+	r.Body = http.MaxBytesReader(w, r.Body, 1000)
+	h.HandleImport(w, r)
+
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("status = %d, want %d: %s", w.Code, http.StatusRequestEntityTooLarge, w.Body.String())
+	}
+	var response struct {
+		Error string `json:"error"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil || response.Error == "" {
+		t.Errorf("body = %q, want a JSON error", w.Body.String())
+	}
+}
@@ -0,0 +1,315 @@
+package admin
+
+import (
+	"bytes"
+	"context"
+	"html"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/rampantspark/gospidertrap/internal/ban"
+	"github.com/rampantspark/gospidertrap/internal/replication"
+	"github.com/rampantspark/gospidertrap/internal/stats"
+)
+
+// Page sizes and limits
+const (
+	// Rows per page on paginated pages
+	pageSize = 50
+	// Number of recent requests shown on the overview
+	overviewRequests = 50
+	// Window and idle gap used to build the sessions page
+	sessionWindow = 24 * time.Hour
+	sessionGap    = stats.DefaultSessionGap
+	// Highest page number accepted, to bound the rows fetched
+	maxPageNumber = 1000
+	// Length of the per-page CSP nonce
+	nonceLength = 24
+)
+
+// Setting is a configuration value shown on the settings page.
+type Setting struct {
+	Name  string
+	Value string
+}
+
+// SettingsSection is a titled group of settings.
+type SettingsSection struct {
+	Title    string
+	Settings []Setting
+}
+
+// sessionLister is implemented by stores that keep a request log to build
+// sessions from.
+type sessionLister interface {
+	GetSessions(ctx context.Context, since time.Time, gap time.Duration) ([]stats.Session, error)
+}
+
+// overviewData is the data of the overview page.
+type overviewData struct {
+	Uptime           time.Duration
+	TotalRequests    int
+	UniqueIPs        int
+	UniqueUserAgents int
+	Storage          *stats.StorageInfo // Nil in file mode
+	ShowNodes        bool               // Whether this instance is a collector
+	Nodes            []replication.NodeStatus
+	TopIPs           []stats.CountEntry
+	TopUserAgents    []stats.CountEntry
+	ShowGeo          bool // Whether GeoIP databases are loaded
+	TopCountries     []stats.CountEntry
+	TopASNs          []stats.CountEntry
+	Bans             []ban.Ban
+	Allowed          []string // IPs on the ban allowlist
+	Recent           []stats.RequestInfo
+	MaxRows          int // Rows the live feed keeps
+}
+
+// countsData is the data of the IPs and user agents pages.
+type countsData struct {
+	Entries    []stats.CountEntry
+	Pagination pagination
+}
+
+// sessionsData is the data of the sessions page.
+type sessionsData struct {
+	Supported  bool
+	Backend    string
+	Since      time.Duration
+	Gap        time.Duration
+	Sessions   []stats.Session
+	Pagination pagination
+}
+
+// SetSettings sets the configuration shown on the settings page.
+//
+// SetSettings must be called before the handler starts serving requests.
+//
+// Parameters:
+//   - sections: the settings, grouped into titled sections
+func (h *Handler) SetSettings(sections []SettingsSection) {
+	h.settings = sections
+}
+
+// authorizePage checks that a page request is authenticated and writes a
+// 403 page if not. A valid token in the query sets the cookie for later
+// requests.
+//
+// Returns true if the page may be rendered.
+func (h *Handler) authorizePage(w http.ResponseWriter, r *http.Request) bool {
+	// Validate authentication (supports both cookie and query param for backward compatibility)
+	if !h.auth.IsAuthenticated(r) {
+		// If token is in query param but not in cookie, set cookie for future requests
+		if token := r.URL.Query().Get("token"); token != "" && h.auth.ValidateToken(token) {
+			h.auth.SetCookie(w)
+		} else {
+			h.setSecurityHeaders(w)
+			w.Header().Set("Content-Type", "text/html")
+			w.WriteHeader(http.StatusForbidden)
+			io.WriteString(w, "<!DOCTYPE html>\n<html>\n<head><title>Access Denied</title></head>\n<body>\n<h1>403 Forbidden</h1>\n<p>Invalid or missing authentication token.</p>\n<p>Use: <a href=\""+html.EscapeString(h.auth.GetPath())+"/login?token=YOUR_TOKEN\">Login</a></p>\n</body>\n</html>")
+			return false
+		}
+	}
+	return true
+}
+
+// renderPage renders a page with a fresh CSP nonce and writes it.
+//
+// Parameters:
+//   - w: the HTTP response writer
+//   - name: the page name
+//   - title: the page title
+//   - data: the page-specific data
+//   - scripts: embedded scripts the page loads
+func (h *Handler) renderPage(w http.ResponseWriter, name, title string, data any, scripts ...string) {
+	h.renderPageStatus(w, http.StatusOK, name, title, data, scripts...)
+}
+
+// renderPageStatus is like renderPage but responds with status.
+func (h *Handler) renderPageStatus(w http.ResponseWriter, status int, name, title string, data any, scripts ...string) {
+	nonce, err := generateSecureRandomString(nonceLength)
+	if err != nil {
+		h.logger.Error("Failed to generate CSP nonce", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	var buf bytes.Buffer
+	err = h.renderer.Render(&buf, &page{
+		Name:      name,
+		Title:     title,
+		Nonce:     nonce,
+		CSRFToken: h.auth.CSRFToken(),
+		Scripts:   scripts,
+		Data:      data,
+	})
+	if err != nil {
+		h.logger.Error("Failed to render admin page", "page", name, "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	h.setSecurityHeaders(w)
+	w.Header().Set("Content-Security-Policy", contentSecurityPolicy(nonce))
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	buf.WriteTo(w)
+}
+
+// pageNumber returns the page number from the "page" query parameter,
+// defaulting to 1.
+func pageNumber(r *http.Request) int {
+	n, err := strconv.Atoi(r.URL.Query().Get("page"))
+	if err != nil || n < 1 {
+		return 1
+	}
+	return min(n, maxPageNumber)
+}
+
+// countEntries pairs chart labels with their counts.
+func countEntries(labels []string, data []int) []stats.CountEntry {
+	entries := make([]stats.CountEntry, len(labels))
+	for i, label := range labels {
+		entries[i] = stats.CountEntry{Label: label, Count: data[i]}
+	}
+	return entries
+}
+
+// HandleUI handles requests to the admin UI overview page.
+//
+// It validates authentication via cookie or query parameter (for backward compatibility).
+// If authentication fails, it returns a 403 Forbidden response.
+// Otherwise, it displays connection statistics including total requests, IP counts,
+// user agent counts, and recent request history, kept up to date by dashboard.js.
+//
+// Parameters:
+//   - w: the HTTP response writer
+//   - r: the HTTP request
+func (h *Handler) HandleUI(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	// Check if context is cancelled
+	if ctx.Err() != nil {
+		return
+	}
+
+	if !h.authorizePage(w, r) {
+		return
+	}
+
+	// Get chart data, passing context for cancellation support
+	chartData := h.statsManager.GetChartData(ctx, 10, 50) // top 10 items, max 50 char user agents
+
+	uptime, totalRequests, uniqueIPs, uniqueUAs := h.statsManager.GetStats(ctx)
+	data := overviewData{
+		Uptime:           uptime,
+		TotalRequests:    totalRequests,
+		UniqueIPs:        uniqueIPs,
+		UniqueUserAgents: uniqueUAs,
+		ShowNodes:        h.nodes != nil,
+		TopIPs:           countEntries(chartData.TopIPs.Labels, chartData.TopIPs.Data),
+		TopUserAgents:    countEntries(chartData.TopUserAgents.Labels, chartData.TopUserAgents.Data),
+		ShowGeo:          h.statsManager.GeoIP() != nil,
+		TopCountries:     countEntries(chartData.TopCountries.Labels, chartData.TopCountries.Data),
+		TopASNs:          countEntries(chartData.TopASNs.Labels, chartData.TopASNs.Data),
+		Bans:             h.bans.Active(),
+		Allowed:          h.bans.Allowed(),
+		Recent:           h.statsManager.GetRecentRequests(ctx, overviewRequests),
+		MaxRows:          overviewRequests,
+	}
+	if info, ok := h.statsManager.GetStorageInfo(ctx); ok {
+		data.Storage = &info
+	}
+	if h.nodes != nil {
+		data.Nodes = h.nodes()
+	}
+
+	h.renderPage(w, "overview", "Overview", data, "charts.js", "dashboard.js")
+}
+
+// HandleIPs handles requests to the admin UI IPs page, which lists IP
+// addresses by request count.
+//
+// Parameters:
+//   - w: the HTTP response writer
+//   - r: the HTTP request
+func (h *Handler) HandleIPs(w http.ResponseWriter, r *http.Request) {
+	h.handleCounts(w, r, "ips", "IPs", h.statsManager.Store().GetTopIPs)
+}
+
+// HandleUserAgents handles requests to the admin UI user agents page, which
+// lists user agents by request count.
+//
+// Parameters:
+//   - w: the HTTP response writer
+//   - r: the HTTP request
+func (h *Handler) HandleUserAgents(w http.ResponseWriter, r *http.Request) {
+	h.handleCounts(w, r, "useragents", "User Agents", h.statsManager.Store().GetTopUserAgents)
+}
+
+// handleCounts renders a page of a count listing.
+func (h *Handler) handleCounts(w http.ResponseWriter, r *http.Request, name, title string,
+	top func(ctx context.Context, limit int) ([]stats.CountEntry, error)) {
+	if !h.authorizePage(w, r) {
+		return
+	}
+
+	number := pageNumber(r)
+	// Fetch one row past the page to learn whether another page follows
+	entries, err := top(r.Context(), number*pageSize+1)
+	if err != nil {
+		h.logger.Error("Failed to get counts", "page", name, "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	entries, hasNext := paginate(entries, number, pageSize)
+	h.renderPage(w, name, title, countsData{
+		Entries:    entries,
+		Pagination: newPagination(r.URL, number, hasNext),
+	})
+}
+
+// HandleSessions handles requests to the admin UI sessions page, which
+// groups the last day's requests into per-IP sessions. Sessions need a
+// store that keeps a request log.
+//
+// Parameters:
+//   - w: the HTTP response writer
+//   - r: the HTTP request
+func (h *Handler) HandleSessions(w http.ResponseWriter, r *http.Request) {
+	if !h.authorizePage(w, r) {
+		return
+	}
+
+	store := h.statsManager.Store()
+	data := sessionsData{Backend: store.Backend(), Since: sessionWindow, Gap: sessionGap}
+	if lister, ok := store.(sessionLister); ok {
+		sessions, err := lister.GetSessions(r.Context(), time.Now().Add(-sessionWindow), sessionGap)
+		if err != nil {
+			h.logger.Error("Failed to get sessions", "error", err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		number := pageNumber(r)
+		var hasNext bool
+		data.Supported = true
+		data.Sessions, hasNext = paginate(sessions, number, pageSize)
+		data.Pagination = newPagination(r.URL, number, hasNext)
+	}
+	h.renderPage(w, "sessions", "Sessions", data)
+}
+
+// HandleSettings handles requests to the admin UI settings page, which
+// shows the running configuration.
+//
+// Parameters:
+//   - w: the HTTP response writer
+//   - r: the HTTP request
+func (h *Handler) HandleSettings(w http.ResponseWriter, r *http.Request) {
+	if !h.authorizePage(w, r) {
+		return
+	}
+	h.renderPage(w, "settings", "Settings", h.settings)
+}
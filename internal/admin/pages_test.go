@@ -0,0 +1,195 @@
+package admin
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rampantspark/gospidertrap/internal/stats"
+)
+
+// get requests path from h, authenticated with a bearer token.
+func get(t *testing.T, h *Handler, handler http.HandlerFunc, path string) *httptest.ResponseRecorder {
+	t.Helper()
+	r := httptest.NewRequest(http.MethodGet, path, nil)
+	r.Header.Set("Authorization", "Bearer "+h.auth.GetToken())
+	w := httptest.NewRecorder()
+	handler(w, r)
+	return w
+}
+
+// record records requests directly in the store, n per IP.
+func record(t *testing.T, store stats.Store, ips []string, n int) {
+	t.Helper()
+	var reqs []stats.RequestInfo
+	start := time.Now().Add(-time.Hour)
+	for _, ip := range ips {
+		for i := range n {
+			reqs = append(reqs, stats.RequestInfo{
+				IP:        ip,
+				UserAgent: "<script>alert(1)</script>",
+				Path:      fmt.Sprintf("/page-%d", i),
+				Timestamp: start.Add(time.Duration(i) * time.Second),
+			})
+		}
+	}
+	if _, err := store.RecordRequests(context.Background(), reqs); err != nil {
+		t.Fatalf("RecordRequests() error = %v", err)
+	}
+}
+
+func TestParsePages(t *testing.T) {
+	for _, item := range navItems {
+		if _, ok := pageTemplates[item.Name]; !ok {
+			t.Errorf("no template for page %q", item.Name)
+		}
+	}
+
+	var sb strings.Builder
+	if err := NewRenderer("/admin").Render(&sb, &page{Name: "missing"}); err == nil {
+		t.Error("Render() of an unknown page succeeded")
+	}
+}
+
+func TestHandlePages(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	db, err := stats.NewDatabase(filepath.Join(t.TempDir(), "stats.db"), logger)
+	if err != nil {
+		t.Fatalf("NewDatabase() error = %v", err)
+	}
+	defer db.Close()
+	h := newTestHandler(t, db)
+	h.SetSettings([]SettingsSection{{Title: "Server", Settings: []Setting{{Name: "Rate Limiting", Value: "10 req/sec"}}}})
+	record(t, db, []string{"10.0.0.1"}, 2)
+
+	tests := []struct {
+		name     string
+		handler  http.HandlerFunc
+		path     string
+		wantBody string
+	}{
+		{"overview", h.HandleUI, "", `id="live-status"`},
+		{"requests", h.HandleRequests, "/requests", "/page-1"},
+		{"ips", h.HandleIPs, "/ips", "10.0.0.1"},
+		{"user agents", h.HandleUserAgents, "/useragents", "&lt;script&gt;alert(1)&lt;/script&gt;"},
+		{"sessions", h.HandleSessions, "/sessions", "10.0.0.1"},
+		{"bots", h.HandleBots, "/bots", "No search engine bots checked yet"},
+		{"forms", h.HandleForms, "/forms", "No form submissions yet"},
+		{"captures", h.HandleCaptures, "/captures", "No requests captured yet"},
+		{"bait", h.HandleBait, "/bait", "Scanner bait is disabled"},
+		{"settings", h.HandleSettings, "/settings", "10 req/sec"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, h.GetPath()+tt.path, nil)
+			w := httptest.NewRecorder()
+			tt.handler(w, r)
+			if w.Code != http.StatusForbidden {
+				t.Errorf("unauthenticated status = %d, want %d", w.Code, http.StatusForbidden)
+			}
+
+			w = get(t, h, tt.handler, h.GetPath()+tt.path)
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d, want %d", w.Code, http.StatusOK)
+			}
+			body := w.Body.String()
+			if !strings.Contains(body, tt.wantBody) {
+				t.Errorf("page does not contain %q", tt.wantBody)
+			}
+			if strings.Contains(body, "<script>alert") {
+				t.Error("page contains an unescaped user agent")
+			}
+			// The layout marks the current page and carries the CSRF token
+			if !strings.Contains(body, `href="`+h.GetPath()+tt.path+`" class="active"`) {
+				t.Error("current page is not marked in the navigation")
+			}
+			if !strings.Contains(body, `name="csrf_token" value="`+h.auth.CSRFToken()+`"`) {
+				t.Error("logout form lacks the CSRF token")
+			}
+		})
+	}
+}
+
+func TestHandleSessionsMemory(t *testing.T) {
+	h := newTestHandler(t, stats.NewMemoryStore(stats.NewStats()))
+	w := get(t, h, h.HandleSessions, h.GetPath()+"/sessions")
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "memory backend does not keep") {
+		t.Errorf("status = %d, want 200 with a note that sessions are unavailable", w.Code)
+	}
+}
+
+func TestNewPagination(t *testing.T) {
+	u, _ := url.Parse("/admin/ips?q=bot&page=2")
+
+	p := newPagination(u, 2, true)
+	if p.PrevURL != "/admin/ips?q=bot" {
+		t.Errorf("PrevURL = %q, want the first page without a page parameter", p.PrevURL)
+	}
+	if p.NextURL != "/admin/ips?page=3&q=bot" {
+		t.Errorf("NextURL = %q, want page 3 with the query kept", p.NextURL)
+	}
+
+	if p := newPagination(u, 1, false); p.PrevURL != "" || p.NextURL != "" {
+		t.Errorf("pagination = %+v, want no links on a single page", p)
+	}
+}
+
+func TestHandleRevokeBanCSRF(t *testing.T) {
+	h := newTestHandler(t, stats.NewMemoryStore(stats.NewStats()))
+
+	tests := []struct {
+		name       string
+		csrf       string
+		wantStatus int
+		wantBanned bool
+	}{
+		{"missing token", "", http.StatusForbidden, true},
+		{"wrong token", "wrong", http.StatusForbidden, true},
+		{"valid token", h.auth.CSRFToken(), http.StatusSeeOther, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h.bans.Add("10.0.0.1", "manual", "test", time.Hour)
+			form := url.Values{"ip": {"10.0.0.1"}, csrfFieldName: {tt.csrf}}
+			r := httptest.NewRequest(http.MethodPost, h.GetPath()+"/bans/revoke", strings.NewReader(form.Encode()))
+			r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			r.AddCookie(&http.Cookie{Name: cookieName, Value: h.auth.GetToken()})
+			w := httptest.NewRecorder()
+			h.HandleRevokeBan(w, r)
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			if banned := h.bans.IsBanned("10.0.0.1"); banned != tt.wantBanned {
+				t.Errorf("banned = %v, want %v", banned, tt.wantBanned)
+			}
+		})
+	}
+}
+
+func TestHandleLogout(t *testing.T) {
+	h := newTestHandler(t, stats.NewMemoryStore(stats.NewStats()))
+
+	form := url.Values{csrfFieldName: {h.auth.CSRFToken()}}
+	r := httptest.NewRequest(http.MethodPost, h.GetPath()+"/logout", strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	r.AddCookie(&http.Cookie{Name: cookieName, Value: h.auth.GetToken()})
+	w := httptest.NewRecorder()
+	h.HandleLogout(w, r)
+
+	if w.Code != http.StatusSeeOther {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusSeeOther)
+	}
+	cookies := w.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != cookieName || cookies[0].MaxAge >= 0 {
+		t.Errorf("cookies = %+v, want the admin cookie cleared", cookies)
+	}
+}
@@ -1,17 +1,133 @@
 package admin
 
 import (
-	"html"
+	"bytes"
+	"embed"
+	"fmt"
+	"html/template"
+	"io"
+	"io/fs"
+	"net/netip"
+	"net/url"
+	"path"
 	"strconv"
 	"strings"
 	"time"
 
-	"github.com/rampantspark/gospidertrap/internal/stats"
+	"github.com/rampantspark/gospidertrap/internal/replication"
 )
 
+// templateFiles holds the admin UI's page templates. layout.html defines
+// the shared layout and every other file defines the "content" of one page.
+//
+//go:embed templates
+var templateFiles embed.FS
+
+// pageTemplates holds the parsed admin UI pages, keyed by page name.
+var pageTemplates = mustParsePages(templateFiles, "templates")
+
+// navItem is a link in the admin UI's navigation.
+type navItem struct {
+	Name  string // Page name, matching the template file name
+	Title string
+	Path  string // Path under the admin path
+}
+
+// navItems lists the admin UI pages in navigation order.
+var navItems = []navItem{
+	{Name: "overview", Title: "Overview", Path: ""},
+	{Name: "requests", Title: "Requests", Path: "/requests"},
+	{Name: "ips", Title: "IPs", Path: "/ips"},
+	{Name: "useragents", Title: "User Agents", Path: "/useragents"},
+	{Name: "sessions", Title: "Sessions", Path: "/sessions"},
+	{Name: "bots", Title: "Bots", Path: "/bots"},
+	{Name: "forms", Title: "Forms", Path: "/forms"},
+	{Name: "captures", Title: "Captures", Path: "/captures"},
+	{Name: "bait", Title: "Bait", Path: "/bait"},
+	{Name: "settings", Title: "Settings", Path: "/settings"},
+}
+
+// page is the data every admin UI template receives.
+type page struct {
+	Name      string // Page name, selecting the template and the active link
+	Title     string
+	AdminPath string
+	Nonce     string   // CSP nonce for the page's script tags
+	CSRFToken string   // Token for the page's forms
+	Scripts   []string // Embedded scripts to load, in order
+	Nav       []navItem
+	Data      any // Page-specific data
+}
+
+// pagination links a page of results to its neighbours.
+type pagination struct {
+	Number  int    // Current page number, starting at 1
+	PrevURL string // Empty on the first page
+	NextURL string // Empty on the last page
+}
+
+// templateFuncs are the functions available to admin UI templates.
+var templateFuncs = template.FuncMap{
+	"asset":          dashboardAssets.path,
+	"formatTime":     formatTime,
+	"formatBytes":    formatBytes,
+	"formatDuration": formatDuration,
+	"nodeState":      nodeState,
+	"join":           strings.Join,
+	"isIP":           isIP,
+}
+
+// parsePages parses the layout in dir and combines it with every page
+// template.
+//
+// Parameters:
+//   - fsys: the file system to read from
+//   - dir: the directory holding layout.html and the page templates
+//
+// Returns the pages keyed by file name without extension, or an error if a
+// template cannot be parsed.
+func parsePages(fsys fs.FS, dir string) (map[string]*template.Template, error) {
+	layout, err := template.New("layout.html").Funcs(templateFuncs).ParseFS(fsys, path.Join(dir, "layout.html"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse layout: %w", err)
+	}
+
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read templates: %w", err)
+	}
+	pages := make(map[string]*template.Template)
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || name == "layout.html" || path.Ext(name) != ".html" {
+			continue
+		}
+		t, err := layout.Clone()
+		if err != nil {
+			return nil, fmt.Errorf("failed to clone layout: %w", err)
+		}
+		if _, err := t.ParseFS(fsys, path.Join(dir, name)); err != nil {
+			return nil, fmt.Errorf("failed to parse template %s: %w", name, err)
+		}
+		pages[strings.TrimSuffix(name, ".html")] = t
+	}
+	return pages, nil
+}
+
+// mustParsePages is like parsePages but panics on error, since the
+// templates are embedded at build time.
+func mustParsePages(fsys fs.FS, dir string) map[string]*template.Template {
+	pages, err := parsePages(fsys, dir)
+	if err != nil {
+		panic(err)
+	}
+	return pages
+}
+
 // Renderer handles HTML generation for the admin UI.
 type Renderer struct {
 	adminPath string
+	pages     map[string]*template.Template
 }
 
 // NewRenderer creates a new renderer.
@@ -23,257 +139,112 @@ type Renderer struct {
 func NewRenderer(adminPath string) *Renderer {
 	return &Renderer{
 		adminPath: adminPath,
+		pages:     pageTemplates,
 	}
 }
 
-// RenderAdminUI generates the complete admin UI HTML.
+// Render renders a page inside the shared layout.
+//
+// The page is rendered completely before anything is written, so a
+// template error never leaves a partial page.
 //
 // Parameters:
-//   - chartData: data for charts (top IPs and user agents)
-//   - uptime: server uptime duration
-//   - totalRequests: total number of requests
-//   - uniqueIPs: number of unique IP addresses
-//   - uniqueUAs: number of unique user agents
-//   - recentRequests: slice of recent request entries
-//   - maxDisplay: maximum number of recent requests to display
-//   - nonce: CSP nonce for inline scripts (empty string if not using nonces)
+//   - w: the writer to render to
+//   - p: the page to render; AdminPath and Nav are filled in
 //
-// Returns the complete HTML as a string.
-func (r *Renderer) RenderAdminUI(
-	chartData stats.ChartData,
-	uptime time.Duration,
-	totalRequests, uniqueIPs, uniqueUAs int,
-	recentRequests []stats.RequestInfo,
-	maxDisplay int,
-	nonce string,
-) string {
-	var sb strings.Builder
-
-	r.writeHTMLHeader(&sb)
-	r.writeStatsBox(&sb, uptime, totalRequests, uniqueIPs, uniqueUAs)
-	r.writeTopIPsSection(&sb, chartData)
-	r.writeTopUAsSection(&sb, chartData)
-	r.writeRecentRequestsSection(&sb, recentRequests, maxDisplay)
-	r.writeChartScript(&sb, nonce)
-	sb.WriteString("</body>\n</html>")
+// Returns an error if the page does not exist or fails to render.
+func (r *Renderer) Render(w io.Writer, p *page) error {
+	t, ok := r.pages[p.Name]
+	if !ok {
+		return fmt.Errorf("unknown admin page %q", p.Name)
+	}
+	p.AdminPath = r.adminPath
+	p.Nav = navItems
 
-	return sb.String()
+	var buf bytes.Buffer
+	if err := t.ExecuteTemplate(&buf, "layout", p); err != nil {
+		return fmt.Errorf("failed to render %s page: %w", p.Name, err)
+	}
+	_, err := buf.WriteTo(w)
+	return err
 }
 
-// writeHTMLHeader writes the HTML header, styles, and opening body tag.
-func (r *Renderer) writeHTMLHeader(sb *strings.Builder) {
-	sb.WriteString("<!DOCTYPE html>\n<html>\n<head>\n")
-	sb.WriteString("<title>gospidertrap - dashboard</title>\n")
-	sb.WriteString("<style>\n")
-	sb.WriteString("body { font-family: monospace; margin: 20px; background: #f5f5f5; }\n")
-	sb.WriteString("h1 { color: #333; }\n")
-	sb.WriteString(".stat-box { background: white; padding: 15px; margin: 10px 0; border-radius: 5px; box-shadow: 0 2px 4px rgba(0,0,0,0.1); }\n")
-	sb.WriteString("table { width: 100%; border-collapse: collapse; margin-top: 10px; }\n")
-	sb.WriteString("th, td { padding: 8px; text-align: left; border-bottom: 1px solid #ddd; }\n")
-	sb.WriteString("th { background-color: #4CAF50; color: white; }\n")
-	sb.WriteString("tr:hover { background-color: #f5f5f5; }\n")
-	sb.WriteString(".ip { font-family: monospace; }\n")
-	sb.WriteString(".chart-container { position: relative; height: 300px; margin: 20px 0; }\n")
-	sb.WriteString(".charts-grid { display: grid; grid-template-columns: 1fr 1fr; gap: 20px; margin: 20px 0; }\n")
-	sb.WriteString("@media (max-width: 768px) { .charts-grid { grid-template-columns: 1fr; } }\n")
-	sb.WriteString(".chart-table-row { display: grid; grid-template-columns: 1fr 1fr; gap: 20px; margin: 20px 0; }\n")
-	sb.WriteString("@media (max-width: 768px) { .chart-table-row { grid-template-columns: 1fr; } }\n")
-	sb.WriteString("</style>\n")
-	sb.WriteString("<script src=\"https://cdn.jsdelivr.net/npm/chart.js@4.4.0/dist/chart.umd.min.js\"></script>\n")
-	sb.WriteString("</head>\n<body>\n")
-	sb.WriteString("<h1>gospidertrap</h1>\n")
-}
+// newPagination builds the links to the pages around the current one.
+//
+// Parameters:
+//   - u: the current request URL; its query is kept in the links
+//   - number: the current page number, starting at 1
+//   - hasNext: whether there are results after this page
+//
+// Returns the pagination links.
+func newPagination(u *url.URL, number int, hasNext bool) pagination {
+	link := func(n int) string {
+		query := u.Query()
+		if n > 1 {
+			query.Set("page", strconv.Itoa(n))
+		} else {
+			query.Del("page")
+		}
+		return (&url.URL{Path: u.Path, RawQuery: query.Encode()}).String()
+	}
 
-// writeStatsBox writes the overall server statistics box.
-func (r *Renderer) writeStatsBox(sb *strings.Builder, uptime time.Duration, totalRequests, uniqueIPs, uniqueUAs int) {
-	sb.WriteString("<div class=\"stat-box\">\n")
-	sb.WriteString("<h2>Server Statistics</h2>\n")
-	sb.WriteString("<p><strong>Uptime:</strong> " + html.EscapeString(uptime.String()) + "</p>\n")
-	sb.WriteString("<p><strong>Total Requests:</strong> " + strconv.Itoa(totalRequests) + "</p>\n")
-	sb.WriteString("<p><strong>Unique IPs:</strong> " + strconv.Itoa(uniqueIPs) + "</p>\n")
-	sb.WriteString("<p><strong>Unique User Agents:</strong> " + strconv.Itoa(uniqueUAs) + "</p>\n")
-	sb.WriteString("</div>\n")
+	p := pagination{Number: number}
+	if number > 1 {
+		p.PrevURL = link(number - 1)
+	}
+	if hasNext {
+		p.NextURL = link(number + 1)
+	}
+	return p
 }
 
-// writeTopIPsSection writes the top IP addresses chart and table section.
-func (r *Renderer) writeTopIPsSection(sb *strings.Builder, chartData stats.ChartData) {
-	sb.WriteString("<div class=\"chart-table-row\">\n")
-	// Top IP Addresses Chart
-	sb.WriteString("<div class=\"stat-box\">\n")
-	sb.WriteString("<h2>Top IP Addresses</h2>\n")
-	sb.WriteString("<div class=\"chart-container\"><canvas id=\"ipChart\"></canvas></div>\n")
-	sb.WriteString("</div>\n")
-
-	// Top IP Addresses Table
-	if len(chartData.TopIPs.Labels) > 0 {
-		sb.WriteString("<div class=\"stat-box\">\n")
-		sb.WriteString("<h2>Top IP Addresses</h2>\n")
-		sb.WriteString("<table>\n")
-		sb.WriteString("<tr><th>IP Address</th><th>Request Count</th></tr>\n")
-
-		for i := 0; i < len(chartData.TopIPs.Labels); i++ {
-			sb.WriteString("<tr><td class=\"ip\">")
-			sb.WriteString(html.EscapeString(chartData.TopIPs.Labels[i]))
-			sb.WriteString("</td><td>")
-			sb.WriteString(strconv.Itoa(chartData.TopIPs.Data[i]))
-			sb.WriteString("</td></tr>\n")
-		}
-		sb.WriteString("</table>\n")
-		sb.WriteString("</div>\n")
-	}
-	sb.WriteString("</div>\n")
+// paginate returns the items on page number of a list split into pages of
+// size items, and whether later pages exist.
+func paginate[T any](items []T, number, size int) ([]T, bool) {
+	start := min((number-1)*size, len(items))
+	end := min(start+size, len(items))
+	return items[start:end], end < len(items)
 }
 
-// writeTopUAsSection writes the top user agents chart and table section.
-func (r *Renderer) writeTopUAsSection(sb *strings.Builder, chartData stats.ChartData) {
-	sb.WriteString("<div class=\"chart-table-row\">\n")
-	// Top User Agents Chart
-	sb.WriteString("<div class=\"stat-box\">\n")
-	sb.WriteString("<h2>Top User Agents</h2>\n")
-	sb.WriteString("<div class=\"chart-container\"><canvas id=\"uaChart\"></canvas></div>\n")
-	sb.WriteString("</div>\n")
+// formatTime formats a timestamp for display.
+func formatTime(t time.Time) string {
+	return t.Format("2006-01-02 15:04:05")
+}
 
-	// Top User Agents Table
-	if len(chartData.TopUserAgents.Labels) > 0 {
-		sb.WriteString("<div class=\"stat-box\">\n")
-		sb.WriteString("<h2>Top User Agents</h2>\n")
-		sb.WriteString("<table>\n")
-		sb.WriteString("<tr><th>User Agent</th><th>Request Count</th></tr>\n")
+// formatDuration formats a duration to the second for display.
+func formatDuration(d time.Duration) string {
+	return d.Round(time.Second).String()
+}
 
-		for i := 0; i < len(chartData.TopUserAgents.Labels); i++ {
-			sb.WriteString("<tr><td>")
-			sb.WriteString(html.EscapeString(chartData.TopUserAgents.Labels[i]))
-			sb.WriteString("</td><td>")
-			sb.WriteString(strconv.Itoa(chartData.TopUserAgents.Data[i]))
-			sb.WriteString("</td></tr>\n")
-		}
-		sb.WriteString("</table>\n")
-		sb.WriteString("</div>\n")
+// formatBytes formats a byte count using binary units, e.g. "1.5 MB".
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return strconv.FormatInt(n, 10) + " B"
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
 	}
-	sb.WriteString("</div>\n")
+	return strconv.FormatFloat(float64(n)/float64(div), 'f', 1, 64) + " " + string("KMGTPE"[exp]) + "B"
 }
 
-// writeRecentRequestsSection writes the recent requests table section.
-func (r *Renderer) writeRecentRequestsSection(sb *strings.Builder, recentRequests []stats.RequestInfo, maxDisplay int) {
-	sb.WriteString("<div class=\"stat-box\">\n")
-	sb.WriteString("<h2>Recent Requests</h2>\n")
-	if len(recentRequests) > 0 {
-		sb.WriteString("<table>\n")
-		sb.WriteString("<tr><th>Timestamp</th><th>IP Address</th><th>Path</th><th>User Agent</th></tr>\n")
-
-		// Display requests in order (Manager guarantees "most recent first")
-		displayCount := len(recentRequests)
-		if maxDisplay > 0 && maxDisplay < displayCount {
-			displayCount = maxDisplay
-		}
-
-		for i := 0; i < displayCount; i++ {
-			req := recentRequests[i]
-			sb.WriteString("<tr><td>")
-			sb.WriteString(html.EscapeString(req.Timestamp.Format("2006-01-02 15:04:05")))
-			sb.WriteString("</td><td class=\"ip\">")
-			sb.WriteString(html.EscapeString(req.IP))
-			sb.WriteString("</td><td>")
-			sb.WriteString(html.EscapeString(req.Path))
-			sb.WriteString("</td><td>")
-			sb.WriteString(html.EscapeString(req.UserAgent))
-			sb.WriteString("</td></tr>\n")
-		}
-		sb.WriteString("</table>\n")
-	} else {
-		sb.WriteString("<p>No recent requests yet.</p>\n")
+// nodeState returns "error", "stale" or "ok" for a replication node.
+func nodeState(node replication.NodeStatus) string {
+	switch {
+	case node.LastError != "":
+		return "error"
+	case node.Stale:
+		return "stale"
+	default:
+		return "ok"
 	}
-	sb.WriteString("</div>\n")
 }
 
-// writeChartScript writes the JavaScript code for loading and rendering charts.
-//
-// Parameters:
-//   - nonce: CSP nonce for inline script (empty string if not using nonces)
-func (r *Renderer) writeChartScript(sb *strings.Builder, nonce string) {
-	sb.WriteString("<script")
-	if nonce != "" {
-		sb.WriteString(" nonce=\"")
-		sb.WriteString(html.EscapeString(nonce))
-		sb.WriteString("\"")
-	}
-	sb.WriteString(">\n")
-	sb.WriteString("const baseDataUrl = '")
-	sb.WriteString(html.EscapeString(r.adminPath))
-	sb.WriteString("/data';\n")
-	sb.WriteString("let ipChart = null;\n")
-	sb.WriteString("let uaChart = null;\n")
-	sb.WriteString("\n")
-	sb.WriteString("async function loadCharts() {\n")
-	sb.WriteString("  try {\n")
-	sb.WriteString("    const response = await fetch(baseDataUrl);\n")
-	sb.WriteString("    if (!response.ok) throw new Error('Failed to load chart data');\n")
-	sb.WriteString("    const data = await response.json();\n")
-	sb.WriteString("\n")
-	sb.WriteString("    // Top IPs Donut Chart\n")
-	sb.WriteString("    if (!ipChart) {\n")
-	sb.WriteString("      ipChart = new Chart(document.getElementById('ipChart'), {\n")
-	sb.WriteString("      type: 'doughnut',\n")
-	sb.WriteString("      data: {\n")
-	sb.WriteString("        labels: data.topIPs.labels,\n")
-	sb.WriteString("        datasets: [{\n")
-	sb.WriteString("          data: data.topIPs.data,\n")
-	sb.WriteString("          backgroundColor: [\n")
-	sb.WriteString("            'rgba(255, 99, 132, 0.8)',\n")
-	sb.WriteString("            'rgba(54, 162, 235, 0.8)',\n")
-	sb.WriteString("            'rgba(255, 206, 86, 0.8)',\n")
-	sb.WriteString("            'rgba(75, 192, 192, 0.8)',\n")
-	sb.WriteString("            'rgba(153, 102, 255, 0.8)',\n")
-	sb.WriteString("            'rgba(255, 159, 64, 0.8)',\n")
-	sb.WriteString("            'rgba(199, 199, 199, 0.8)',\n")
-	sb.WriteString("            'rgba(83, 102, 255, 0.8)',\n")
-	sb.WriteString("          ]\n")
-	sb.WriteString("        }]\n")
-	sb.WriteString("      },\n")
-	sb.WriteString("      options: {\n")
-	sb.WriteString("        responsive: true,\n")
-	sb.WriteString("        maintainAspectRatio: false,\n")
-	sb.WriteString("        plugins: {\n")
-	sb.WriteString("          legend: { position: 'bottom' }\n")
-	sb.WriteString("        }\n")
-	sb.WriteString("      }\n")
-	sb.WriteString("    });\n")
-	sb.WriteString("    }\n")
-	sb.WriteString("\n")
-	sb.WriteString("    // Top User Agents Donut Chart\n")
-	sb.WriteString("    if (!uaChart) {\n")
-	sb.WriteString("      uaChart = new Chart(document.getElementById('uaChart'), {\n")
-	sb.WriteString("      type: 'doughnut',\n")
-	sb.WriteString("      data: {\n")
-	sb.WriteString("        labels: data.topUserAgents.labels,\n")
-	sb.WriteString("        datasets: [{\n")
-	sb.WriteString("          data: data.topUserAgents.data,\n")
-	sb.WriteString("          backgroundColor: [\n")
-	sb.WriteString("            'rgba(255, 99, 132, 0.8)',\n")
-	sb.WriteString("            'rgba(54, 162, 235, 0.8)',\n")
-	sb.WriteString("            'rgba(255, 206, 86, 0.8)',\n")
-	sb.WriteString("            'rgba(75, 192, 192, 0.8)',\n")
-	sb.WriteString("            'rgba(153, 102, 255, 0.8)',\n")
-	sb.WriteString("            'rgba(255, 159, 64, 0.8)',\n")
-	sb.WriteString("            'rgba(199, 199, 199, 0.8)',\n")
-	sb.WriteString("            'rgba(83, 102, 255, 0.8)'\n")
-	sb.WriteString("          ]\n")
-	sb.WriteString("        }]\n")
-	sb.WriteString("      },\n")
-	sb.WriteString("      options: {\n")
-	sb.WriteString("        responsive: true,\n")
-	sb.WriteString("        maintainAspectRatio: false,\n")
-	sb.WriteString("        plugins: {\n")
-	sb.WriteString("          legend: { position: 'bottom' }\n")
-	sb.WriteString("        }\n")
-	sb.WriteString("      }\n")
-	sb.WriteString("    });\n")
-	sb.WriteString("    }\n")
-	sb.WriteString("  } catch (error) {\n")
-	sb.WriteString("    console.error('Error loading charts:', error);\n")
-	sb.WriteString("  }\n")
-	sb.WriteString("}\n")
-	sb.WriteString("\n")
-	sb.WriteString("loadCharts();\n")
-	sb.WriteString("</script>\n")
+// isIP reports whether a ban key is an IP address rather than a network
+// such as "AS64496", so that only addresses link to the IP detail page.
+func isIP(key string) bool {
+	_, err := netip.ParseAddr(key)
+	return err == nil
 }
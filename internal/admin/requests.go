@@ -0,0 +1,228 @@
+package admin
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/rampantspark/gospidertrap/internal/stats"
+)
+
+// filterTimeLayout is the format of the requests page's time filters, as
+// sent by datetime-local inputs. Times are in the server's time zone.
+const filterTimeLayout = "2006-01-02T15:04"
+
+// requestColumns lists the requests page's sortable columns in display
+// order.
+var requestColumns = []struct {
+	Title string
+	Sort  stats.RequestSort
+}{
+	{"Timestamp", stats.SortTime},
+	{"IP Address", stats.SortIP},
+	{"Path", stats.SortPath},
+	{"User Agent", stats.SortUserAgent},
+}
+
+// requestForm is the requests page's filter and sort state as it appears
+// in the query string.
+type requestForm struct {
+	IP        string // IP address or CIDR network
+	UserAgent string // User agent substring
+	Path      string // Path prefix
+	Since     string // Start of the time range, in filterTimeLayout
+	Until     string // End of the time range, in filterTimeLayout
+	Bait      string // Bait name, stats.BaitAny or stats.BaitNone
+	Sort      string // Sort column
+	Order     string // "asc" or "desc", empty for the column's default
+}
+
+// parseRequestForm reads the requests page's query parameters.
+func parseRequestForm(query url.Values) requestForm {
+	return requestForm{
+		IP:        strings.TrimSpace(query.Get("ip")),
+		UserAgent: query.Get("ua"),
+		Path:      query.Get("path"),
+		Since:     query.Get("since"),
+		Until:     query.Get("until"),
+		Bait:      query.Get("bait"),
+		Sort:      query.Get("sort"),
+		Order:     query.Get("order"),
+	}
+}
+
+// values returns the form as query parameters, leaving out empty fields so
+// that links stay short.
+func (f requestForm) values() url.Values {
+	v := url.Values{}
+	for _, field := range []struct{ name, value string }{
+		{"ip", f.IP}, {"ua", f.UserAgent}, {"path", f.Path},
+		{"since", f.Since}, {"until", f.Until}, {"bait", f.Bait},
+		{"sort", f.Sort}, {"order", f.Order},
+	} {
+		if field.value != "" {
+			v.Set(field.name, field.value)
+		}
+	}
+	return v
+}
+
+// query converts the form into a search. Text columns sort ascending and
+// time sorts newest first unless the order says otherwise.
+//
+// Returns the search, or an error describing the first invalid field.
+func (f requestForm) query() (stats.RequestQuery, error) {
+	q := stats.RequestQuery{Limit: pageSize}
+	var err error
+	if f.IP != "" {
+		if q.IP, err = stats.ParseIPFilter(f.IP); err != nil {
+			return q, err
+		}
+	}
+	q.UserAgent = f.UserAgent
+	q.PathPrefix = f.Path
+	q.Bait = f.Bait
+	if q.Since, err = parseFilterTime(f.Since); err != nil {
+		return q, errors.New("invalid start time " + f.Since)
+	}
+	if q.Until, err = parseFilterTime(f.Until); err != nil {
+		return q, errors.New("invalid end time " + f.Until)
+	}
+	if q.Sort, err = stats.ParseRequestSort(f.Sort); err != nil {
+		return q, err
+	}
+	switch f.Order {
+	case "":
+		q.Ascending = q.Sort != stats.SortTime
+	case "asc":
+		q.Ascending = true
+	case "desc":
+	default:
+		return q, errors.New("invalid order " + f.Order)
+	}
+	return q, nil
+}
+
+// parseFilterTime parses a time filter, returning the zero time for an
+// empty string.
+func parseFilterTime(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	return time.ParseInLocation(filterTimeLayout, s, time.Local)
+}
+
+// sortColumn is a column heading on the requests page, linking to the
+// results sorted by that column.
+type sortColumn struct {
+	Title     string
+	URL       string
+	Active    bool // Results are sorted by this column
+	Ascending bool // Direction of the active sort
+}
+
+// requestsData is the data of the requests page.
+type requestsData struct {
+	Supported bool
+	Backend   string
+	Error     string // Invalid filter, empty if the search ran
+	Form      requestForm
+	Columns   []sortColumn
+	Requests  []stats.RequestInfo
+	Permalink string // These results from the first page
+	FirstURL  string // Empty on the first page
+	NextURL   string // Empty on the last page
+}
+
+// HandleRequests handles requests to the admin UI requests page, an
+// explorer for the request log.
+//
+// Requests can be filtered by IP address or CIDR network ("ip"), user
+// agent substring ("ua"), path prefix ("path"), time range ("since" and
+// "until") and the bait that answered them ("bait": a bait name, "any" or
+// "none"), and sorted by any column ("sort" and "order"). Pages are linked
+// by an opaque cursor ("after"), so a page's URL is a stable permalink.
+// Invalid filters receive 400 Bad Request with the form kept for editing.
+//
+// Parameters:
+//   - w: the HTTP response writer
+//   - r: the HTTP request
+func (h *Handler) HandleRequests(w http.ResponseWriter, r *http.Request) {
+	if !h.authorizePage(w, r) {
+		return
+	}
+
+	store := h.statsManager.Store()
+	form := parseRequestForm(r.URL.Query())
+	base := h.auth.GetPath() + "/requests"
+	data := requestsData{Backend: store.Backend(), Form: form}
+
+	searcher, ok := store.(stats.RequestSearcher)
+	if !ok {
+		h.renderPage(w, "requests", "Requests", data)
+		return
+	}
+	data.Supported = true
+
+	q, err := form.query()
+	if err != nil {
+		data.Error = err.Error()
+		h.renderPageStatus(w, http.StatusBadRequest, "requests", "Requests", data)
+		return
+	}
+	q.After = r.URL.Query().Get("after")
+
+	result, err := searcher.SearchRequests(r.Context(), q)
+	if errors.Is(err, stats.ErrInvalidCursor) {
+		data.Error = "This page link is no longer valid. Start again from the first page."
+		data.Permalink = linkTo(base, form.values())
+		h.renderPageStatus(w, http.StatusBadRequest, "requests", "Requests", data)
+		return
+	}
+	if err != nil {
+		h.logger.Error("Failed to search requests", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	data.Requests = result.Requests
+	data.Permalink = linkTo(base, form.values())
+	if q.After != "" {
+		data.FirstURL = data.Permalink
+	}
+	if result.Next != "" {
+		v := form.values()
+		v.Set("after", result.Next)
+		data.NextURL = linkTo(base, v)
+	}
+	for _, col := range requestColumns {
+		column := sortColumn{Title: col.Title, Active: col.Sort == q.Sort, Ascending: q.Ascending}
+		// Clicking the active column reverses it; other columns start in
+		// their default order
+		sorted := form
+		sorted.Sort, sorted.Order = string(col.Sort), ""
+		if col.Sort == stats.SortTime {
+			sorted.Sort = ""
+		}
+		if column.Active {
+			sorted.Order = "asc"
+			if q.Ascending {
+				sorted.Order = "desc"
+			}
+		}
+		column.URL = linkTo(base, sorted.values())
+		data.Columns = append(data.Columns, column)
+	}
+
+	h.renderPage(w, "requests", "Requests", data)
+}
+
+// linkTo returns a link to path with query parameters.
+func linkTo(path string, query url.Values) string {
+	if len(query) == 0 {
+		return path
+	}
+	return path + "?" + query.Encode()
+}
@@ -0,0 +1,122 @@
+package admin
+
+import (
+	"html"
+	"net/http"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/rampantspark/gospidertrap/internal/stats"
+)
+
+// nextLink returns the requests page's next page link, or "" if it has none.
+func nextLink(body string) string {
+	m := regexp.MustCompile(`<a href="([^"]+)">Next page`).FindStringSubmatch(body)
+	if m == nil {
+		return ""
+	}
+	return html.UnescapeString(m[1])
+}
+
+func TestHandleRequests(t *testing.T) {
+	store := stats.NewMemoryStore(stats.NewStats())
+	h := newTestHandler(t, store)
+	record(t, store, []string{"10.0.0.1", "192.168.1.1"}, pageSize/2)
+
+	tests := []struct {
+		name       string
+		query      string
+		wantStatus int
+		wantRows   int
+		wantNext   bool
+		wantBody   string
+	}{
+		{"first page", "", http.StatusOK, pageSize, false, "Link to these results"},
+		{"network", "?ip=192.168.0.0/16", http.StatusOK, pageSize / 2, false, ""},
+		{"exact IP", "?ip=10.0.0.1", http.StatusOK, pageSize / 2, false, ""},
+		{"user agent", "?ua=ALERT", http.StatusOK, pageSize, false, ""},
+		{"path prefix", "?path=/page-1", http.StatusOK, 22, false, ""}, // page-1 and page-10 to page-19 for each IP
+		{"path prefix is case-sensitive", "?path=/PAGE", http.StatusOK, 0, false, "No matching requests"},
+		{"time range", "?since=2000-01-01T00:00&until=2001-01-01T00:00", http.StatusOK, 0, false, ""},
+		{"sorted", "?sort=ip&order=desc", http.StatusOK, pageSize, false, "&darr;"},
+		{"trap pages", "?bait=none", http.StatusOK, pageSize, false, `<option value="none" selected>`},
+		{"bait", "?bait=env", http.StatusOK, 0, false, `<option value="env" selected>Bait env</option>`},
+		{"invalid IP", "?ip=10.0.0", http.StatusBadRequest, 0, false, "invalid IP address"},
+		{"invalid time", "?since=yesterday", http.StatusBadRequest, 0, false, "invalid start time"},
+		{"invalid sort", "?sort=size", http.StatusBadRequest, 0, false, "unknown sort column"},
+		{"invalid cursor", "?after=!!!", http.StatusBadRequest, 0, false, "no longer valid"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := get(t, h, h.HandleRequests, h.GetPath()+"/requests"+tt.query)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			body := w.Body.String()
+			if rows := strings.Count(body, `<td class="ip">`); rows != tt.wantRows {
+				t.Errorf("rows = %d, want %d", rows, tt.wantRows)
+			}
+			if got := nextLink(body) != ""; got != tt.wantNext {
+				t.Errorf("next link = %v, want %v", got, tt.wantNext)
+			}
+			if !strings.Contains(body, tt.wantBody) {
+				t.Errorf("page does not contain %q", tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestHandleRequestsPagination(t *testing.T) {
+	store := stats.NewMemoryStore(stats.NewStats())
+	h := newTestHandler(t, store)
+	record(t, store, []string{"10.0.0.1", "192.168.1.1"}, pageSize)
+
+	// Filters and sort order carry over to the next page
+	w := get(t, h, h.HandleRequests, h.GetPath()+"/requests?ua=alert&sort=ip")
+	next := nextLink(w.Body.String())
+	if !strings.Contains(next, "ua=alert") || !strings.Contains(next, "sort=ip") || !strings.Contains(next, "after=") {
+		t.Fatalf("next link = %q, want the filter, sort and a cursor", next)
+	}
+	if strings.Contains(w.Body.String(), "First page") {
+		t.Error("first page links to the first page")
+	}
+	if strings.Count(w.Body.String(), "/ips/10.0.0.1\">10.0.0.1</a></td>") != pageSize {
+		t.Error("first page sorted by IP does not hold the lower IP")
+	}
+
+	w = get(t, h, h.HandleRequests, next)
+	body := w.Body.String()
+	if strings.Count(body, "/ips/192.168.1.1\">192.168.1.1</a></td>") != pageSize {
+		t.Error("second page sorted by IP does not hold the higher IP")
+	}
+	if nextLink(body) != "" || !strings.Contains(body, "First page") {
+		t.Error("last page should link to the first page and not onwards")
+	}
+}
+
+func TestHandleRequestsSortLinks(t *testing.T) {
+	h := newTestHandler(t, stats.NewMemoryStore(stats.NewStats()))
+	record(t, h.statsManager.Store(), []string{"10.0.0.1"}, 1)
+
+	body := get(t, h, h.HandleRequests, h.GetPath()+"/requests?ip=10.0.0.1&sort=ip").Body.String()
+	for _, want := range []string{
+		// The active column reverses, others start in their default order
+		`/requests?ip=10.0.0.1&amp;order=desc&amp;sort=ip">IP Address</a> &uarr;`,
+		`/requests?ip=10.0.0.1&amp;sort=path">Path</a>`,
+		`/requests?ip=10.0.0.1">Timestamp</a>`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("page does not contain %q", want)
+		}
+	}
+}
+
+func TestHandleRequestsUnsupported(t *testing.T) {
+	// Hide the memory store's search method
+	h := newTestHandler(t, struct{ stats.Store }{stats.NewMemoryStore(stats.NewStats())})
+	w := get(t, h, h.HandleRequests, h.GetPath()+"/requests")
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "not supported by the memory backend") {
+		t.Errorf("status = %d, want 200 with a note that search is unsupported", w.Code)
+	}
+}
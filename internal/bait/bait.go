@@ -0,0 +1,296 @@
+package bait
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"html/template"
+	"io"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/rampantspark/gospidertrap/internal/content"
+	"github.com/rampantspark/gospidertrap/internal/honeytoken"
+)
+
+// maxEchoedUser limits the length of a submitted user name shown in a
+// failed login message.
+const maxEchoedUser = 64
+
+// Issuer issues honeytokens to clients. honeytoken.Tracker implements it.
+type Issuer interface {
+	// Issue returns a new honeytoken of the given kind issued to ip,
+	// stored so that its later use is detected.
+	Issue(ctx context.Context, ip, source, kind string) string
+}
+
+// Config holds bait settings.
+type Config struct {
+	Domain string // Domain of honeytoken email addresses and fake hosts
+}
+
+// Catalog answers requests for the paths of its entries.
+//
+// Catalog is safe for concurrent use.
+type Catalog struct {
+	entries  []Entry
+	exact    map[string]int // Entry index by lowercase path
+	prefixes []prefix       // Paths ending in "/", longest first
+	config   Config
+	issuer   Issuer // Nil if honeytokens are not stored
+	logger   *slog.Logger
+}
+
+// prefix is a catalog path ending in "/", which answers the paths below it.
+type prefix struct {
+	path  string // Lowercase path
+	entry int    // Entry index
+}
+
+// NewCatalog creates a catalog.
+//
+// Parameters:
+//   - entries: the baits, DefaultEntries or the result of LoadEntries
+//   - config: bait settings; an empty domain uses honeytoken.DefaultDomain
+//   - issuer: stores the honeytokens in responses (nil generates
+//     honeytokens without storing them, so their use is not detected)
+//   - logger: structured logger instance
+//
+// Returns a new Catalog instance, or an error if an entry is invalid.
+func NewCatalog(entries []Entry, config Config, issuer Issuer, logger *slog.Logger) (*Catalog, error) {
+	if err := validate(entries); err != nil {
+		return nil, err
+	}
+	if config.Domain == "" {
+		config.Domain = honeytoken.DefaultDomain
+	}
+
+	c := &Catalog{
+		entries: entries,
+		exact:   make(map[string]int),
+		config:  config,
+		issuer:  issuer,
+		logger:  logger,
+	}
+	for i, e := range entries {
+		for _, p := range e.Paths {
+			p = strings.ToLower(p)
+			c.exact[p] = i
+			if strings.HasSuffix(p, "/") {
+				c.prefixes = append(c.prefixes, prefix{path: p, entry: i})
+			}
+		}
+	}
+	// Longest first, so that the most specific prefix wins
+	sort.SliceStable(c.prefixes, func(i, j int) bool {
+		return len(c.prefixes[i].path) > len(c.prefixes[j].path)
+	})
+	return c, nil
+}
+
+// Entries returns the catalog's baits.
+func (c *Catalog) Entries() []Entry {
+	return c.entries
+}
+
+// Match finds the bait that answers a path. Paths are compared without
+// regard to case, since scanners probe both /phpMyAdmin/ and /phpmyadmin/.
+//
+// Parameters:
+//   - path: the request path
+//
+// Returns the bait, and false if no bait answers the path.
+func (c *Catalog) Match(path string) (Entry, bool) {
+	path = strings.ToLower(path)
+	if i, ok := c.exact[path]; ok {
+		return c.entries[i], true
+	}
+	for _, p := range c.prefixes {
+		if strings.HasPrefix(path, p.path) {
+			return c.entries[p.entry], true
+		}
+	}
+	return Entry{}, false
+}
+
+// Respond writes a bait's fake response.
+//
+// Parameters:
+//   - w: the HTTP response writer
+//   - r: the HTTP request
+//   - entry: the bait, as returned by Match
+//   - ip: the client IP address, which honeytokens are issued to
+func (c *Catalog) Respond(w http.ResponseWriter, r *http.Request, entry Entry, ip string) {
+	c.logger.Debug("Bait requested", "bait", entry.Name, "ip", ip, "path", r.URL.Path)
+
+	switch entry.Response {
+	case ResponseEnv:
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		io.WriteString(w, c.env(r, entry, ip))
+	case ResponseGitConfig:
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		io.WriteString(w, c.gitConfig(r, entry, ip))
+	case ResponseWPLogin:
+		c.login(w, r, wpLoginPage, "log")
+	case ResponsePHPMyAdmin:
+		c.login(w, r, phpMyAdminPage, "pma_username")
+	default:
+		contentType := entry.ContentType
+		if contentType == "" {
+			contentType = "text/plain; charset=utf-8"
+		}
+		w.Header().Set("Content-Type", contentType)
+		if entry.Status != 0 {
+			w.WriteHeader(entry.Status)
+		}
+		io.WriteString(w, entry.Body)
+	}
+}
+
+// issue returns a new honeytoken of the given kind for ip.
+func (c *Catalog) issue(ctx context.Context, ip string, entry Entry, kind string) string {
+	if c.issuer == nil {
+		return honeytoken.Generate(kind, c.config.Domain)
+	}
+	return c.issuer.Issue(ctx, ip, entry.Name, kind)
+}
+
+// randomHex returns n random bytes, hex-encoded.
+func randomHex(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// env returns a Laravel-style .env file whose credentials are honeytokens.
+func (c *Catalog) env(r *http.Request, entry Entry, ip string) string {
+	ctx := r.Context()
+	key := make([]byte, 32)
+	rand.Read(key)
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "APP_NAME=Laravel\nAPP_ENV=production\nAPP_KEY=base64:%s\nAPP_DEBUG=false\nAPP_URL=https://%s\n\n",
+		base64.StdEncoding.EncodeToString(key), r.Host)
+	sb.WriteString("LOG_CHANNEL=stack\nLOG_LEVEL=error\n\n")
+	fmt.Fprintf(&sb, "DB_CONNECTION=mysql\nDB_HOST=127.0.0.1\nDB_PORT=3306\nDB_DATABASE=production\nDB_USERNAME=app\nDB_PASSWORD=%s\n\n",
+		c.issue(ctx, ip, entry, content.TokenPassword))
+	sb.WriteString("REDIS_HOST=127.0.0.1\nREDIS_PASSWORD=null\nREDIS_PORT=6379\n\n")
+	fmt.Fprintf(&sb, "MAIL_MAILER=smtp\nMAIL_HOST=smtp.%s\nMAIL_PORT=587\nMAIL_USERNAME=%s\nMAIL_PASSWORD=%s\nMAIL_ENCRYPTION=tls\n\n",
+		c.config.Domain, c.issue(ctx, ip, entry, content.TokenEmail), c.issue(ctx, ip, entry, content.TokenPassword))
+	fmt.Fprintf(&sb, "STRIPE_KEY=pk_live_%s\nSTRIPE_SECRET=%s\n", randomHex(12), c.issue(ctx, ip, entry, content.TokenAPIKey))
+	return sb.String()
+}
+
+// gitConfig returns a git config whose remote URL holds honeytoken
+// credentials.
+func (c *Catalog) gitConfig(r *http.Request, entry Entry, ip string) string {
+	password := c.issue(r.Context(), ip, entry, content.TokenPassword)
+	return "[core]\n" +
+		"\trepositoryformatversion = 0\n" +
+		"\tfilemode = true\n" +
+		"\tbare = false\n" +
+		"\tlogallrefupdates = true\n" +
+		"[remote \"origin\"]\n" +
+		"\turl = https://deploy:" + password + "@git." + c.config.Domain + "/web/site.git\n" +
+		"\tfetch = +refs/heads/*:refs/remotes/origin/*\n" +
+		"[branch \"main\"]\n" +
+		"\tremote = origin\n" +
+		"\tmerge = refs/heads/main\n"
+}
+
+// loginData is the data of a fake login page.
+type loginData struct {
+	Site   string // Host the page claims to belong to
+	Action string // Path the form posts to
+	User   string // Submitted user name, shown after a failed login
+	Error  bool   // A login was submitted and "failed"
+	Token  string // Random form token
+}
+
+// login writes a fake login page. A POST gets the page back with the
+// application's failed login message, so the scanner keeps guessing.
+func (c *Catalog) login(w http.ResponseWriter, r *http.Request, page *template.Template, userField string) {
+	data := loginData{Site: r.Host, Action: r.URL.Path, Token: randomHex(16)}
+	if r.Method == http.MethodPost {
+		data.Error = true
+		data.User = r.PostFormValue(userField)
+		if len(data.User) > maxEchoedUser {
+			data.User = data.User[:maxEchoedUser]
+		}
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := page.Execute(w, data); err != nil {
+		c.logger.Debug("Failed to write bait page", "error", err)
+	}
+}
+
+// wpLoginPage imitates the WordPress login page.
+var wpLoginPage = template.Must(template.New("wp-login").Parse(`<!DOCTYPE html>
+<html lang="en-US">
+<head>
+<meta http-equiv="Content-Type" content="text/html; charset=UTF-8">
+<title>Log In &lsaquo; {{.Site}} &#8212; WordPress</title>
+<meta name="robots" content="max-image-preview:large, noindex, noarchive">
+<link rel="stylesheet" id="login-css" href="/wp-admin/css/login.min.css?ver=6.5.3" media="all">
+<meta name="viewport" content="width=device-width">
+</head>
+<body class="login no-js login-action-login wp-core-ui locale-en-us">
+<div id="login">
+<h1><a href="https://wordpress.org/">Powered by WordPress</a></h1>
+{{- if .Error}}
+<div id="login_error" class="notice notice-error"><p><strong>Error:</strong> The password you entered for the username <strong>{{.User}}</strong> is incorrect. <a href="{{.Action}}?action=lostpassword">Lost your password?</a></p></div>
+{{- end}}
+<form name="loginform" id="loginform" action="{{.Action}}" method="post">
+<p><label for="user_login">Username or Email Address</label>
+<input type="text" name="log" id="user_login" class="input" value="{{.User}}" size="20" autocapitalize="off" autocomplete="username" required="required"></p>
+<div class="user-pass-wrap"><label for="user_pass">Password</label>
+<div class="wp-pwd"><input type="password" name="pwd" id="user_pass" class="input password-input" value="" size="20" autocomplete="current-password" spellcheck="false" required="required"></div></div>
+<p class="forgetmenot"><input name="rememberme" type="checkbox" id="rememberme" value="forever"> <label for="rememberme">Remember Me</label></p>
+<p class="submit"><input type="submit" name="wp-submit" id="wp-submit" class="button button-primary button-large" value="Log In">
+<input type="hidden" name="redirect_to" value="/wp-admin/">
+<input type="hidden" name="testcookie" value="1"></p>
+</form>
+<p id="nav"><a href="{{.Action}}?action=lostpassword">Lost your password?</a></p>
+</div>
+</body>
+</html>
+`))
+
+// phpMyAdminPage imitates the phpMyAdmin login page.
+var phpMyAdminPage = template.Must(template.New("phpmyadmin").Parse(`<!DOCTYPE HTML>
+<html lang="en" dir="ltr">
+<head>
+<meta charset="utf-8">
+<meta name="referrer" content="no-referrer">
+<meta name="robots" content="noindex,nofollow">
+<title>phpMyAdmin</title>
+<link rel="stylesheet" type="text/css" href="./themes/pmahomme/css/theme.css?v=5.2.1">
+</head>
+<body id="loginform">
+<div class="container">
+<a href="./url.php?url=https%3A%2F%2Fwww.phpmyadmin.net%2F" target="_blank" rel="noopener noreferrer" class="logo"><img src="./themes/pmahomme/img/logo_right.png" id="imLogo" name="imLogo" alt="phpMyAdmin" border="0"></a>
+<h1>Welcome to <bdo dir="ltr" lang="en">phpMyAdmin</bdo></h1>
+{{- if .Error}}
+<div class="alert alert-danger" role="alert">mysqli::real_connect(): (HY000/1045): Access denied for user '{{.User}}'@'localhost' (using password: YES)</div>
+{{- end}}
+<form method="post" id="login_form" action="{{.Action}}" name="login_form" class="disableAjax">
+<fieldset class="pma-fieldset">
+<legend>Log in</legend>
+<div class="item"><label for="input_username">Username:</label> <input type="text" name="pma_username" id="input_username" value="{{.User}}" size="24" class="textfield" autocomplete="username"></div>
+<div class="item"><label for="input_password">Password:</label> <input type="password" name="pma_password" id="input_password" value="" size="24" class="textfield" autocomplete="current-password"></div>
+<input type="hidden" name="server" value="1">
+</fieldset>
+<fieldset class="pma-fieldset tblFooters">
+<input class="btn btn-primary" value="Log in" type="submit" id="input_go">
+<input type="hidden" name="route" value="/">
+<input type="hidden" name="token" value="{{.Token}}">
+</fieldset>
+</form>
+</div>
+</body>
+</html>
+`))
@@ -0,0 +1,146 @@
+// Package bait answers the paths vulnerability scanners probe, such as
+// /.env, /.git/config and /wp-login.php, with plausible fake responses
+// instead of a trap page.
+//
+// Credentials in the fake responses are honeytokens issued to the client,
+// so a scanner that later tries them against the trap's login forms, or
+// passes them on to another IP, gives itself away. Each request a bait
+// answers is recorded with the bait's name, which separates scanner
+// activity from crawler activity in the stats.
+package bait
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/rampantspark/gospidertrap/internal/stats"
+)
+
+// Responses a bait can give.
+const (
+	ResponseEnv        = "env"        // A Laravel-style .env file full of honeytokens
+	ResponseGitConfig  = "git-config" // A git config with honeytoken credentials in the remote URL
+	ResponseWPLogin    = "wp-login"   // A WordPress login page
+	ResponsePHPMyAdmin = "phpmyadmin" // A phpMyAdmin login page
+	ResponseStatic     = "static"     // The entry's own status, content type and body
+)
+
+// Responses lists every response a bait can give.
+var Responses = []string{ResponseEnv, ResponseGitConfig, ResponseWPLogin, ResponsePHPMyAdmin, ResponseStatic}
+
+// Entry is a bait in the catalog.
+type Entry struct {
+	Name        string   `json:"name"`                  // Name the bait's hits are tagged with
+	Paths       []string `json:"paths"`                 // Paths answered; one ending in "/" also answers the paths below it
+	Response    string   `json:"response"`              // One of Responses
+	Status      int      `json:"status,omitempty"`      // Status of a static response (0 for 200)
+	ContentType string   `json:"contentType,omitempty"` // Content type of a static response (empty for plain text)
+	Body        string   `json:"body,omitempty"`        // Body of a static response
+}
+
+// DefaultEntries is the built-in catalog, covering paths commonly probed by
+// vulnerability scanners.
+var DefaultEntries = []Entry{
+	{
+		Name:     "env",
+		Paths:    []string{"/.env", "/.env.local", "/.env.production", "/.env.backup", "/app/.env", "/api/.env", "/laravel/.env"},
+		Response: ResponseEnv,
+	},
+	{
+		Name:     "git-config",
+		Paths:    []string{"/.git/config"},
+		Response: ResponseGitConfig,
+	},
+	{
+		Name:     "git-head",
+		Paths:    []string{"/.git/HEAD"},
+		Response: ResponseStatic,
+		Body:     "ref: refs/heads/main\n",
+	},
+	{
+		Name:     "wp-login",
+		Paths:    []string{"/wp-login.php", "/wp-admin/"},
+		Response: ResponseWPLogin,
+	},
+	{
+		Name:     "phpmyadmin",
+		Paths:    []string{"/phpmyadmin/", "/pma/", "/myadmin/", "/dbadmin/"},
+		Response: ResponsePHPMyAdmin,
+	},
+}
+
+// namePattern matches a valid bait name.
+var namePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,31}$`)
+
+// LoadEntries reads a catalog from a JSON file holding an array of entries.
+//
+// Parameters:
+//   - path: the catalog file
+//
+// Returns the entries, or an error if the file cannot be read or an entry
+// is invalid.
+func LoadEntries(path string) ([]Entry, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read bait catalog: %w", err)
+	}
+	var entries []Entry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("failed to parse bait catalog %s: %w", path, err)
+	}
+	if err := validate(entries); err != nil {
+		return nil, fmt.Errorf("invalid bait catalog %s: %w", path, err)
+	}
+	return entries, nil
+}
+
+// validate checks that a catalog has at least one entry, that names are
+// unique and usable as stats tags, and that every path is answered by only
+// one entry.
+func validate(entries []Entry) error {
+	if len(entries) == 0 {
+		return fmt.Errorf("no baits listed")
+	}
+	names := make(map[string]bool)
+	paths := make(map[string]string)
+	for _, e := range entries {
+		switch {
+		case !namePattern.MatchString(e.Name):
+			return fmt.Errorf("invalid bait name %q (lowercase letters, digits, - and _, up to 32 characters)", e.Name)
+		case e.Name == stats.BaitAny || e.Name == stats.BaitNone:
+			return fmt.Errorf("bait name %q is reserved", e.Name)
+		case names[e.Name]:
+			return fmt.Errorf("duplicate bait name %q", e.Name)
+		case len(e.Paths) == 0:
+			return fmt.Errorf("bait %q has no paths", e.Name)
+		}
+		names[e.Name] = true
+
+		known := false
+		for _, r := range Responses {
+			known = known || e.Response == r
+		}
+		if !known {
+			return fmt.Errorf("bait %q has unknown response %q (supported: %s)", e.Name, e.Response, strings.Join(Responses, ", "))
+		}
+		if e.Status != 0 && (e.Status < 200 || e.Status > 599 || http.StatusText(e.Status) == "") {
+			return fmt.Errorf("bait %q has invalid status %d", e.Name, e.Status)
+		}
+
+		for _, p := range e.Paths {
+			if !strings.HasPrefix(p, "/") || p == "/" {
+				return fmt.Errorf("bait %q path %q must start with / and not be the root", e.Name, p)
+			}
+			key := strings.ToLower(p)
+			if other, ok := paths[key]; ok {
+				return fmt.Errorf("path %q is listed by baits %q and %q", p, other, e.Name)
+			}
+			paths[key] = e.Name
+		}
+	}
+	return nil
+}
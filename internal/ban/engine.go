@@ -0,0 +1,308 @@
+package ban
+
+import (
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+const (
+	// maxTrackedIPs limits the number of per-IP counters held by the engine
+	// to prevent memory exhaustion during floods from many source IPs.
+	maxTrackedIPs = 10000
+)
+
+// RuleKind identifies which per-IP signal a rule watches.
+type RuleKind int
+
+const (
+	// KindTrapHits fires when an IP requests more than Threshold trap pages
+	// within Window.
+	KindTrapHits RuleKind = iota
+	// KindRateLimited fires when an IP is rejected by the rate limiter more
+	// than Threshold times within Window.
+	KindRateLimited
+	// KindKnownScraper fires on the first trap hit whose User-Agent matches
+	// a known scraper signature.
+	KindKnownScraper
+)
+
+// Rule describes a condition that bans an IP when it is met.
+type Rule struct {
+	Name      string        // Rule name recorded on each ban it issues
+	Kind      RuleKind      // Signal the rule watches
+	Threshold int           // Number of events that must be exceeded (ignored for KindKnownScraper)
+	Window    time.Duration // Sliding window the events are counted over (ignored for KindKnownScraper)
+	TTL       time.Duration // Duration of bans issued by this rule
+}
+
+// counters holds the recent event timestamps for a single IP.
+type counters struct {
+	hits     []time.Time
+	limited  []time.Time
+	lastSeen time.Time
+}
+
+// Engine watches per-IP counters and bans IPs that trip a rule.
+//
+// SetKeyFunc can group IPs, such as by autonomous system, so that events
+// from the group are counted together and a fired rule bans the whole
+// group under its key.
+//
+// Engine is safe for concurrent use.
+type Engine struct {
+	list     *List
+	rules    []Rule
+	key      func(ip string) string // Maps an IP to its counters' and ban's key (nil for per-IP)
+	counters map[string]*counters
+	mu       sync.Mutex
+	now      func() time.Time
+	logger   *slog.Logger
+	cleanup  *time.Ticker
+	stopChan chan struct{}
+}
+
+// NewEngine creates a new rules engine.
+//
+// Parameters:
+//   - list: the ban list that fired rules add to
+//   - rules: the rules to evaluate (may be empty to disable auto-banning)
+//   - logger: structured logger instance
+//
+// Returns a new Engine instance. Call Stop when the engine is no longer needed.
+func NewEngine(list *List, rules []Rule, logger *slog.Logger) *Engine {
+	e := &Engine{
+		list:     list,
+		rules:    rules,
+		counters: make(map[string]*counters),
+		now:      time.Now,
+		logger:   logger,
+		stopChan: make(chan struct{}),
+	}
+
+	// Start cleanup goroutine to remove idle counters
+	e.cleanup = time.NewTicker(time.Minute)
+	go e.cleanupRoutine()
+
+	return e
+}
+
+// Rules returns the rules the engine evaluates.
+func (e *Engine) Rules() []Rule {
+	return e.rules
+}
+
+// SetKeyFunc makes the engine count events and issue bans under key(ip)
+// instead of the IP itself. Allowlisted IPs are still ignored.
+//
+// SetKeyFunc must be called before the engine observes any events.
+//
+// Parameters:
+//   - key: returns the key of an IP address, e.g. its autonomous system
+func (e *Engine) SetKeyFunc(key func(ip string) string) {
+	e.key = key
+}
+
+// subject returns the key events from ip are counted under, or false if
+// the IP is allowlisted or it or its key is already banned.
+func (e *Engine) subject(ip string) (string, bool) {
+	if len(e.rules) == 0 || e.list.IsAllowed(ip) || e.list.IsBanned(ip) {
+		return "", false
+	}
+	if e.key == nil {
+		return ip, true
+	}
+	key := e.key(ip)
+	return key, !e.list.IsBanned(key)
+}
+
+// ObserveHit records a trap page request and evaluates hit-based rules.
+// Banned and allowlisted IPs are ignored.
+//
+// Parameters:
+//   - ip: the client IP address
+//   - userAgent: the client User-Agent header
+func (e *Engine) ObserveHit(ip, userAgent string) {
+	key, ok := e.subject(ip)
+	if !ok {
+		return
+	}
+
+	for _, rule := range e.rules {
+		if rule.Kind != KindKnownScraper {
+			continue
+		}
+		if signature, ok := ClassifyUserAgent(userAgent); ok {
+			e.fire(key, rule, fmt.Sprintf("user agent matches known scraper %q", signature))
+			return
+		}
+	}
+
+	e.observe(key, KindTrapHits)
+}
+
+// ObserveRateLimited records a rate-limit rejection and evaluates
+// rate-limit rules.
+//
+// Parameters:
+//   - ip: the client IP address
+func (e *Engine) ObserveRateLimited(ip string) {
+	key, ok := e.subject(ip)
+	if !ok {
+		return
+	}
+	e.observe(key, KindRateLimited)
+}
+
+// observe appends an event of the given kind for ip and fires the first rule
+// of that kind whose threshold is exceeded.
+func (e *Engine) observe(ip string, kind RuleKind) {
+	now := e.now()
+
+	e.mu.Lock()
+	c, exists := e.counters[ip]
+	if !exists {
+		if len(e.counters) >= maxTrackedIPs {
+			e.mu.Unlock()
+			return
+		}
+		c = &counters{}
+		e.counters[ip] = c
+	}
+	c.lastSeen = now
+
+	events := &c.hits
+	if kind == KindRateLimited {
+		events = &c.limited
+	}
+	*events = append(*events, now)
+
+	var fired *Rule
+	var count int
+	maxWindow := time.Duration(0)
+	maxThreshold := 0
+	for i := range e.rules {
+		rule := &e.rules[i]
+		if rule.Kind != kind {
+			continue
+		}
+		if rule.Window > maxWindow {
+			maxWindow = rule.Window
+		}
+		if rule.Threshold > maxThreshold {
+			maxThreshold = rule.Threshold
+		}
+		if fired != nil {
+			continue
+		}
+		if n := countSince(*events, now.Add(-rule.Window)); n > rule.Threshold {
+			fired = rule
+			count = n
+		}
+	}
+
+	// Drop events that no rule can see any more, and bound the slice so a
+	// single noisy IP cannot grow it without limit.
+	*events = trimEvents(*events, now.Add(-maxWindow), maxThreshold+1)
+
+	if fired != nil {
+		delete(e.counters, ip)
+	}
+	e.mu.Unlock()
+
+	if fired != nil {
+		var reason string
+		switch kind {
+		case KindRateLimited:
+			reason = fmt.Sprintf("%d rate-limited requests in %s", count, fired.Window)
+		default:
+			reason = fmt.Sprintf("%d trap pages in %s", count, fired.Window)
+		}
+		e.fire(ip, *fired, reason)
+	}
+}
+
+// fire adds a ban for ip on behalf of rule.
+func (e *Engine) fire(ip string, rule Rule, reason string) {
+	if _, added := e.list.Add(ip, rule.Name, reason, rule.TTL); added {
+		e.logger.Warn("IP banned",
+			"ip", ip,
+			"rule", rule.Name,
+			"reason", reason,
+			"ttl", rule.TTL.String())
+	}
+}
+
+// countSince returns the number of events at or after since.
+//
+// Events are stored in chronological order, so the scan stops at the first
+// event inside the window.
+func countSince(events []time.Time, since time.Time) int {
+	for i, t := range events {
+		if !t.Before(since) {
+			return len(events) - i
+		}
+	}
+	return 0
+}
+
+// trimEvents removes events before since and keeps at most max of the most
+// recent events.
+func trimEvents(events []time.Time, since time.Time, max int) []time.Time {
+	start := len(events) - countSince(events, since)
+	if len(events)-start > max {
+		start = len(events) - max
+	}
+	if start == 0 {
+		return events
+	}
+	return append(events[:0], events[start:]...)
+}
+
+// cleanupRoutine periodically removes counters for idle IPs.
+func (e *Engine) cleanupRoutine() {
+	for {
+		select {
+		case <-e.cleanup.C:
+			e.cleanupOldEntries()
+		case <-e.stopChan:
+			return
+		}
+	}
+}
+
+// cleanupOldEntries removes counters that have not seen an event within the
+// longest rule window.
+func (e *Engine) cleanupOldEntries() {
+	var maxWindow time.Duration
+	for _, rule := range e.rules {
+		if rule.Window > maxWindow {
+			maxWindow = rule.Window
+		}
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	cutoff := e.now().Add(-maxWindow)
+	for ip, c := range e.counters {
+		if c.lastSeen.Before(cutoff) {
+			delete(e.counters, ip)
+		}
+	}
+}
+
+// Stop stops the cleanup goroutine.
+//
+// Should be called when shutting down the server.
+// Safe to call multiple times.
+func (e *Engine) Stop() {
+	e.cleanup.Stop()
+	select {
+	case <-e.stopChan:
+		// Already closed
+	default:
+		close(e.stopChan)
+	}
+}
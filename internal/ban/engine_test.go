@@ -0,0 +1,289 @@
+package ban
+
+import (
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+// fakeClock returns a controllable time source for tests.
+func fakeClock(start time.Time) (func() time.Time, func(time.Duration)) {
+	now := start
+	return func() time.Time { return now }, func(d time.Duration) { now = now.Add(d) }
+}
+
+func newTestEngine(t *testing.T, rules []Rule) (*Engine, *List, func(time.Duration)) {
+	t.Helper()
+	clock, advance := fakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+
+	list := NewList()
+	list.now = clock
+	t.Cleanup(list.Stop)
+
+	engine := NewEngine(list, rules, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	engine.now = clock
+	t.Cleanup(engine.Stop)
+
+	return engine, list, advance
+}
+
+func TestEngine_TrapHits(t *testing.T) {
+	engine, list, advance := newTestEngine(t, []Rule{
+		{Name: "trap-hits", Kind: KindTrapHits, Threshold: 3, Window: time.Minute, TTL: time.Hour},
+	})
+
+	ip := "192.168.1.1"
+	for i := 0; i < 3; i++ {
+		engine.ObserveHit(ip, "Mozilla/5.0")
+		advance(time.Second)
+	}
+	if list.IsBanned(ip) {
+		t.Fatal("IP banned at threshold, should only be banned when threshold is exceeded")
+	}
+
+	engine.ObserveHit(ip, "Mozilla/5.0")
+	b, banned := list.Get(ip)
+	if !banned {
+		t.Fatal("IP not banned after exceeding threshold")
+	}
+	if b.Rule != "trap-hits" {
+		t.Errorf("Rule = %q, want %q", b.Rule, "trap-hits")
+	}
+	if b.Reason == "" {
+		t.Error("Reason is empty")
+	}
+}
+
+func TestEngine_TrapHitsWindowExpires(t *testing.T) {
+	engine, list, advance := newTestEngine(t, []Rule{
+		{Name: "trap-hits", Kind: KindTrapHits, Threshold: 2, Window: time.Minute, TTL: time.Hour},
+	})
+
+	ip := "192.168.1.1"
+	// Spread hits out so no window ever contains more than two
+	for i := 0; i < 10; i++ {
+		engine.ObserveHit(ip, "Mozilla/5.0")
+		advance(31 * time.Second)
+	}
+	if list.IsBanned(ip) {
+		t.Error("IP banned although hits never exceeded the threshold within the window")
+	}
+}
+
+func TestEngine_AllowlistedIP(t *testing.T) {
+	engine, list, _ := newTestEngine(t, []Rule{
+		{Name: "trap-hits", Kind: KindTrapHits, Threshold: 2, Window: time.Minute, TTL: time.Hour},
+		{Name: "known-scraper", Kind: KindKnownScraper, TTL: time.Hour},
+	})
+
+	ip := "192.168.1.1"
+	list.Allow(ip)
+	for i := 0; i < 5; i++ {
+		engine.ObserveHit(ip, "python-requests/2.31")
+	}
+	if list.IsBanned(ip) {
+		t.Error("allowlisted IP was banned")
+	}
+}
+
+func TestEngine_KeyFunc(t *testing.T) {
+	engine, list, _ := newTestEngine(t, []Rule{
+		{Name: "trap-hits", Kind: KindTrapHits, Threshold: 2, Window: time.Minute, TTL: time.Hour},
+	})
+	engine.SetKeyFunc(func(ip string) string { return "AS64496" })
+
+	allowed := "10.0.0.9"
+	list.Allow(allowed)
+	for i := 0; i < 5; i++ {
+		engine.ObserveHit(allowed, "Mozilla/5.0")
+	}
+	if list.IsBanned("AS64496") {
+		t.Fatal("network banned for hits from an allowlisted IP")
+	}
+
+	// Hits from different addresses in the network count together
+	for _, ip := range []string{"10.0.0.1", "10.0.0.2", "10.0.0.3"} {
+		engine.ObserveHit(ip, "Mozilla/5.0")
+	}
+	if !list.IsBanned("AS64496") {
+		t.Fatal("network not banned after its addresses exceeded the threshold")
+	}
+	if list.IsBanned("10.0.0.3") {
+		t.Error("ban issued for the IP instead of its network")
+	}
+}
+
+func TestEngine_RateLimited(t *testing.T) {
+	engine, list, _ := newTestEngine(t, []Rule{
+		{Name: "rate-limited", Kind: KindRateLimited, Threshold: 2, Window: time.Minute, TTL: time.Hour},
+	})
+
+	ip := "10.0.0.1"
+	// Trap hits must not count towards rate-limit rules
+	for i := 0; i < 5; i++ {
+		engine.ObserveHit(ip, "Mozilla/5.0")
+	}
+	if list.IsBanned(ip) {
+		t.Fatal("IP banned by trap hits with only a rate-limit rule configured")
+	}
+
+	for i := 0; i < 3; i++ {
+		engine.ObserveRateLimited(ip)
+	}
+	b, banned := list.Get(ip)
+	if !banned {
+		t.Fatal("IP not banned after exceeding rate-limit threshold")
+	}
+	if b.Rule != "rate-limited" {
+		t.Errorf("Rule = %q, want %q", b.Rule, "rate-limited")
+	}
+}
+
+func TestEngine_KnownScraper(t *testing.T) {
+	engine, list, _ := newTestEngine(t, []Rule{
+		{Name: "known-scraper", Kind: KindKnownScraper, TTL: time.Hour},
+	})
+
+	engine.ObserveHit("10.0.0.1", "Mozilla/5.0 (Windows NT 10.0; Win64; x64)")
+	if list.IsBanned("10.0.0.1") {
+		t.Error("browser user agent was banned")
+	}
+
+	engine.ObserveHit("10.0.0.2", "Scrapy/2.11.0 (+https://scrapy.org)")
+	b, banned := list.Get("10.0.0.2")
+	if !banned {
+		t.Fatal("known scraper was not banned")
+	}
+	if b.Rule != "known-scraper" {
+		t.Errorf("Rule = %q, want %q", b.Rule, "known-scraper")
+	}
+}
+
+func TestEngine_NoRules(t *testing.T) {
+	engine, list, _ := newTestEngine(t, nil)
+
+	for i := 0; i < 100; i++ {
+		engine.ObserveHit("10.0.0.1", "python-requests/2.31")
+		engine.ObserveRateLimited("10.0.0.1")
+	}
+	if list.Count() != 0 {
+		t.Errorf("Count() = %d, want 0 with no rules", list.Count())
+	}
+	if len(engine.counters) != 0 {
+		t.Errorf("engine tracked %d IPs with no rules", len(engine.counters))
+	}
+}
+
+func TestEngine_CleanupOldEntries(t *testing.T) {
+	engine, _, advance := newTestEngine(t, []Rule{
+		{Name: "trap-hits", Kind: KindTrapHits, Threshold: 100, Window: time.Minute, TTL: time.Hour},
+	})
+
+	engine.ObserveHit("10.0.0.1", "Mozilla/5.0")
+	advance(2 * time.Minute)
+	engine.ObserveHit("10.0.0.2", "Mozilla/5.0")
+
+	engine.cleanupOldEntries()
+
+	if _, exists := engine.counters["10.0.0.1"]; exists {
+		t.Error("idle IP counter was not cleaned up")
+	}
+	if _, exists := engine.counters["10.0.0.2"]; !exists {
+		t.Error("active IP counter was cleaned up")
+	}
+}
+
+func TestList_ExpiryAndRevoke(t *testing.T) {
+	clock, advance := fakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	list := NewList()
+	list.now = clock
+	defer list.Stop()
+
+	if _, added := list.Add("10.0.0.1", RuleManual, "test", time.Minute); !added {
+		t.Fatal("Add() returned false for new ban")
+	}
+	if _, added := list.Add("10.0.0.1", RuleManual, "shorter", time.Second); added {
+		t.Error("Add() replaced a ban with a shorter one")
+	}
+	if _, added := list.Add("10.0.0.2", RuleManual, "test", time.Hour); !added {
+		t.Fatal("Add() returned false for new ban")
+	}
+
+	if got := len(list.Active()); got != 2 {
+		t.Fatalf("len(Active()) = %d, want 2", got)
+	}
+
+	advance(2 * time.Minute)
+	if list.IsBanned("10.0.0.1") {
+		t.Error("ban still active after TTL")
+	}
+	list.removeExpired()
+	if list.Count() != 1 {
+		t.Errorf("Count() = %d after removeExpired, want 1", list.Count())
+	}
+
+	if !list.Remove("10.0.0.2") {
+		t.Error("Remove() returned false for active ban")
+	}
+	if list.IsBanned("10.0.0.2") {
+		t.Error("ban still active after Remove")
+	}
+	if list.Remove("10.0.0.2") {
+		t.Error("Remove() returned true for missing ban")
+	}
+}
+
+func TestList_Allowlist(t *testing.T) {
+	list := NewList()
+	defer list.Stop()
+
+	list.Add("10.0.0.1", RuleManual, "test", time.Hour)
+	if !list.Allow("10.0.0.1") {
+		t.Error("Allow() returned false for a new address")
+	}
+	if list.IsBanned("10.0.0.1") {
+		t.Error("Allow() did not revoke the existing ban")
+	}
+	if list.Allow("10.0.0.1") {
+		t.Error("Allow() returned true for an allowlisted address")
+	}
+	if _, added := list.Add("10.0.0.1", "trap-hits", "test", time.Hour); added {
+		t.Error("Add() banned an allowlisted address")
+	}
+	if got := list.Allowed(); len(got) != 1 || got[0] != "10.0.0.1" {
+		t.Errorf("Allowed() = %v, want [10.0.0.1]", got)
+	}
+
+	if !list.Disallow("10.0.0.1") {
+		t.Error("Disallow() returned false for an allowlisted address")
+	}
+	if list.IsAllowed("10.0.0.1") || list.Disallow("10.0.0.1") {
+		t.Error("address still allowlisted after Disallow")
+	}
+	if _, added := list.Add("10.0.0.1", "trap-hits", "test", time.Hour); !added {
+		t.Error("Add() refused an address removed from the allowlist")
+	}
+}
+
+func TestClassifyUserAgent(t *testing.T) {
+	tests := []struct {
+		userAgent string
+		want      bool
+	}{
+		{"Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36", false},
+		{"python-requests/2.31.0", true},
+		{"Wget/1.21.4", true},
+		{"curl/8.4.0", true},
+		{"Mozilla/5.0 (compatible; AhrefsBot/7.0; +http://ahrefs.com/robot/)", true},
+		{"", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.userAgent, func(t *testing.T) {
+			if _, got := ClassifyUserAgent(tt.userAgent); got != tt.want {
+				t.Errorf("ClassifyUserAgent(%q) = %v, want %v", tt.userAgent, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,279 @@
+// Package ban provides a TTL-based ban list and a rules engine that bans
+// clients whose behaviour crosses configured thresholds.
+package ban
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// maxBans limits the number of active bans to prevent memory exhaustion
+	// when a large botnet trips the rules at once.
+	maxBans = 10000
+
+	// RuleManual is the rule name recorded for bans issued from the admin UI.
+	RuleManual = "manual"
+)
+
+// Ban describes a single banned IP address, or a banned group of
+// addresses such as an autonomous system ("AS64496").
+type Ban struct {
+	IP        string    `json:"ip"`        // Banned client IP address, or the key of a group of addresses
+	Rule      string    `json:"rule"`      // Name of the rule that issued the ban
+	Reason    string    `json:"reason"`    // Human-readable description of why the rule fired
+	CreatedAt time.Time `json:"createdAt"` // When the ban was issued
+	ExpiresAt time.Time `json:"expiresAt"` // When the ban expires
+}
+
+// List holds the set of currently banned IP addresses and the allowlist of
+// addresses that are never banned.
+//
+// Bans expire after their TTL and are removed by a background cleanup
+// goroutine. List is safe for concurrent use.
+type List struct {
+	bans     map[string]Ban
+	allowed  map[string]bool
+	mu       sync.RWMutex
+	now      func() time.Time
+	hooks    []func(Ban)
+	cleanup  *time.Ticker
+	stopChan chan struct{}
+}
+
+// NewList creates a new, empty ban list.
+//
+// Returns a new List instance. Call Stop when the list is no longer needed.
+func NewList() *List {
+	l := &List{
+		bans:     make(map[string]Ban),
+		allowed:  make(map[string]bool),
+		now:      time.Now,
+		stopChan: make(chan struct{}),
+	}
+
+	// Start cleanup goroutine to remove expired bans
+	l.cleanup = time.NewTicker(time.Minute)
+	go l.cleanupRoutine()
+
+	return l
+}
+
+// Add bans an IP address for the given duration.
+//
+// If the IP is already banned, the existing ban is replaced only when the new
+// ban expires later, so a short ban never shortens a longer one.
+//
+// Parameters:
+//   - ip: the client IP address to ban
+//   - rule: name of the rule that issued the ban
+//   - reason: human-readable description of why the ban was issued
+//   - ttl: how long the ban lasts
+//
+// Returns the resulting ban and true if a new ban was recorded, or false if
+// the IP is allowlisted, was already banned for longer or the list is full.
+func (l *List) Add(ip, rule, reason string, ttl time.Duration) (Ban, bool) {
+	now := l.now()
+	b := Ban{
+		IP:        ip,
+		Rule:      rule,
+		Reason:    reason,
+		CreatedAt: now,
+		ExpiresAt: now.Add(ttl),
+	}
+
+	l.mu.Lock()
+	if l.allowed[ip] {
+		l.mu.Unlock()
+		return Ban{}, false
+	}
+	if existing, exists := l.bans[ip]; exists && now.Before(existing.ExpiresAt) {
+		if !b.ExpiresAt.After(existing.ExpiresAt) {
+			l.mu.Unlock()
+			return existing, false
+		}
+	} else if len(l.bans) >= maxBans {
+		l.mu.Unlock()
+		return Ban{}, false
+	}
+	l.bans[ip] = b
+	l.mu.Unlock()
+
+	for _, hook := range l.hooks {
+		hook(b)
+	}
+	return b, true
+}
+
+// AddBanHook registers a hook that is called after every new ban.
+//
+// Hooks run synchronously on the goroutine that issued the ban and must not
+// block. AddBanHook must be called before bans are issued.
+//
+// Parameters:
+//   - hook: the function to call
+func (l *List) AddBanHook(hook func(Ban)) {
+	l.hooks = append(l.hooks, hook)
+}
+
+// Remove revokes the ban for an IP address.
+//
+// Parameters:
+//   - ip: the client IP address to unban
+//
+// Returns true if a ban was removed.
+func (l *List) Remove(ip string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if _, exists := l.bans[ip]; !exists {
+		return false
+	}
+	delete(l.bans, ip)
+	return true
+}
+
+// Allow adds an IP address to the allowlist and revokes any ban for it.
+// Allowlisted addresses are never banned until removed with Disallow.
+//
+// Parameters:
+//   - ip: the client IP address to allow
+//
+// Returns true if the IP was not already allowlisted.
+func (l *List) Allow(ip string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	delete(l.bans, ip)
+	if l.allowed[ip] {
+		return false
+	}
+	l.allowed[ip] = true
+	return true
+}
+
+// Disallow removes an IP address from the allowlist.
+//
+// Parameters:
+//   - ip: the client IP address
+//
+// Returns true if the IP was allowlisted.
+func (l *List) Disallow(ip string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if !l.allowed[ip] {
+		return false
+	}
+	delete(l.allowed, ip)
+	return true
+}
+
+// IsAllowed reports whether an IP address is on the allowlist.
+func (l *List) IsAllowed(ip string) bool {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.allowed[ip]
+}
+
+// Allowed returns the allowlisted IP addresses, sorted.
+func (l *List) Allowed() []string {
+	l.mu.RLock()
+	result := make([]string, 0, len(l.allowed))
+	for ip := range l.allowed {
+		result = append(result, ip)
+	}
+	l.mu.RUnlock()
+
+	sort.Strings(result)
+	return result
+}
+
+// Get returns the active ban for an IP address.
+//
+// Parameters:
+//   - ip: the client IP address
+//
+// Returns the ban and true if the IP is currently banned.
+func (l *List) Get(ip string) (Ban, bool) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	b, exists := l.bans[ip]
+	if !exists || !l.now().Before(b.ExpiresAt) {
+		return Ban{}, false
+	}
+	return b, true
+}
+
+// IsBanned reports whether an IP address is currently banned.
+func (l *List) IsBanned(ip string) bool {
+	_, banned := l.Get(ip)
+	return banned
+}
+
+// Active returns all unexpired bans, most recently issued first.
+func (l *List) Active() []Ban {
+	l.mu.RLock()
+	now := l.now()
+	result := make([]Ban, 0, len(l.bans))
+	for _, b := range l.bans {
+		if now.Before(b.ExpiresAt) {
+			result = append(result, b)
+		}
+	}
+	l.mu.RUnlock()
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].CreatedAt.After(result[j].CreatedAt)
+	})
+	return result
+}
+
+// Count returns the number of bans currently held, including any expired
+// bans not yet removed by cleanup.
+func (l *List) Count() int {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return len(l.bans)
+}
+
+// cleanupRoutine periodically removes expired bans.
+func (l *List) cleanupRoutine() {
+	for {
+		select {
+		case <-l.cleanup.C:
+			l.removeExpired()
+		case <-l.stopChan:
+			return
+		}
+	}
+}
+
+// removeExpired deletes every ban whose TTL has elapsed.
+func (l *List) removeExpired() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	for ip, b := range l.bans {
+		if !now.Before(b.ExpiresAt) {
+			delete(l.bans, ip)
+		}
+	}
+}
+
+// Stop stops the cleanup goroutine.
+//
+// Should be called when shutting down the server.
+// Safe to call multiple times.
+func (l *List) Stop() {
+	l.cleanup.Stop()
+	select {
+	case <-l.stopChan:
+		// Already closed
+	default:
+		close(l.stopChan)
+	}
+}
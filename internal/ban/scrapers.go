@@ -0,0 +1,56 @@
+package ban
+
+import "strings"
+
+// knownScrapers lists lowercase User-Agent substrings that identify common
+// scraping libraries, site copiers and vulnerability scanners.
+var knownScrapers = []string{
+	"scrapy",
+	"python-requests",
+	"python-urllib",
+	"aiohttp",
+	"httpx",
+	"go-http-client",
+	"okhttp",
+	"java/",
+	"libwww-perl",
+	"curl/",
+	"wget/",
+	"httrack",
+	"webcopier",
+	"webzip",
+	"sitesucker",
+	"teleport pro",
+	"nikto",
+	"sqlmap",
+	"nmap scripting engine",
+	"masscan",
+	"zgrab",
+	"nuclei",
+	"dirbuster",
+	"gobuster",
+	"wpscan",
+	"petalbot",
+	"mj12bot",
+	"dotbot",
+	"semrushbot",
+	"ahrefsbot",
+	"bytespider",
+}
+
+// ClassifyUserAgent checks a User-Agent string against the known scraper list.
+//
+// Parameters:
+//   - userAgent: the client User-Agent header
+//
+// Returns the matched signature and true if the User-Agent belongs to a
+// known scraper.
+func ClassifyUserAgent(userAgent string) (string, bool) {
+	ua := strings.ToLower(userAgent)
+	for _, signature := range knownScrapers {
+		if strings.Contains(ua, signature) {
+			return signature, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,78 @@
+// Package botverify checks whether clients whose user agent claims to be a
+// major search engine crawler really are one.
+//
+// Search engines publish the reverse DNS domains of their crawlers. A
+// genuine crawler's IP has a reverse DNS name in one of those domains, and
+// the name resolves back to the IP (forward-confirmed reverse DNS).
+// Scrapers can copy the user agent but not the DNS records.
+package botverify
+
+import "strings"
+
+// Bot is a search engine crawler that can be verified by reverse DNS.
+type Bot struct {
+	Name    string   // Display name, e.g. "Googlebot"
+	Tokens  []string // Lowercase user agent substrings that claim to be this crawler
+	Domains []string // Domains the crawler's reverse DNS names belong to
+}
+
+// Bots are the crawlers that are verified. The domains are those the
+// search engines document for verifying their crawlers.
+var Bots = []Bot{
+	{
+		Name:    "Googlebot",
+		Tokens:  []string{"googlebot", "adsbot-google", "mediapartners-google", "google-inspectiontool", "storebot-google"},
+		Domains: []string{"googlebot.com", "google.com", "googleusercontent.com"},
+	},
+	{
+		Name:    "Bingbot",
+		Tokens:  []string{"bingbot", "bingpreview", "adidxbot"},
+		Domains: []string{"search.msn.com"},
+	},
+	{
+		Name:    "Applebot",
+		Tokens:  []string{"applebot"},
+		Domains: []string{"applebot.apple.com"},
+	},
+	{
+		Name:    "YandexBot",
+		Tokens:  []string{"yandexbot", "yandeximages", "yandexmobilebot"},
+		Domains: []string{"yandex.ru", "yandex.net", "yandex.com"},
+	},
+	{
+		Name:    "Baiduspider",
+		Tokens:  []string{"baiduspider"},
+		Domains: []string{"crawl.baidu.com", "crawl.baidu.jp"},
+	},
+}
+
+// Claimed returns the crawler a user agent claims to be.
+//
+// Parameters:
+//   - userAgent: the client User-Agent header
+//
+// Returns the crawler and true, or false if the user agent does not claim
+// to be a known crawler.
+func Claimed(userAgent string) (Bot, bool) {
+	ua := strings.ToLower(userAgent)
+	for _, bot := range Bots {
+		for _, token := range bot.Tokens {
+			if strings.Contains(ua, token) {
+				return bot, true
+			}
+		}
+	}
+	return Bot{}, false
+}
+
+// owns reports whether a reverse DNS name, with or without the trailing
+// dot, is in one of the crawler's domains.
+func (b Bot) owns(hostname string) bool {
+	hostname = strings.ToLower(strings.TrimSuffix(hostname, "."))
+	for _, domain := range b.Domains {
+		if strings.HasSuffix(hostname, "."+domain) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,325 @@
+package botverify
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net"
+	"net/netip"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rampantspark/gospidertrap/internal/stats"
+)
+
+// Default verifier settings.
+const (
+	DefaultTTL       = 24 * time.Hour
+	DefaultQueueSize = 256
+	DefaultWorkers   = 2
+	DefaultTimeout   = 5 * time.Second
+)
+
+const (
+	// unknownTTL caps how long a failed check is cached, so that IPs are
+	// checked again soon after a DNS outage.
+	unknownTTL = 10 * time.Minute
+	// maxTracked limits the IPs whose next check time is held in memory.
+	// Beyond it, the store is consulted for every request.
+	maxTracked = 10000
+)
+
+// Resolver performs the DNS lookups. *net.Resolver implements it; tests
+// use a fake.
+type Resolver interface {
+	// LookupAddr returns the reverse DNS names of an IP address.
+	LookupAddr(ctx context.Context, addr string) ([]string, error)
+	// LookupHost returns the IP addresses of a host name.
+	LookupHost(ctx context.Context, host string) ([]string, error)
+}
+
+// Config holds verifier settings.
+type Config struct {
+	TTL       time.Duration // How long a result is used before the IP is checked again
+	QueueSize int           // Maximum number of IPs waiting to be checked
+	Workers   int           // Number of concurrent checks
+	Timeout   time.Duration // Timeout for the lookups of one check
+}
+
+// Verify checks whether an IP belongs to a crawler using forward-confirmed
+// reverse DNS.
+//
+// An IP is verified if one of its reverse DNS names is in the crawler's
+// domains and resolves back to the IP. It is spoofed if it has no such
+// name, and unknown if a lookup failed for a reason other than the name not
+// existing.
+//
+// Parameters:
+//   - ctx: context for cancellation and timeout control
+//   - resolver: the DNS resolver
+//   - ip: the IP address
+//   - bot: the crawler the IP claims to be
+//
+// Returns the status, one of stats.BotVerified, stats.BotSpoofed or
+// stats.BotUnknown, and the reverse DNS name that decided it (empty if
+// there was none).
+func Verify(ctx context.Context, resolver Resolver, ip string, bot Bot) (string, string) {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return stats.BotSpoofed, ""
+	}
+	addr = addr.Unmap()
+
+	names, err := resolver.LookupAddr(ctx, ip)
+	if err != nil {
+		if isNotFound(err) {
+			return stats.BotSpoofed, ""
+		}
+		return stats.BotUnknown, ""
+	}
+
+	failed := false
+	for _, name := range names {
+		if !bot.owns(name) {
+			continue
+		}
+		hostname := strings.TrimSuffix(name, ".")
+		addrs, err := resolver.LookupHost(ctx, hostname)
+		if err != nil {
+			failed = failed || !isNotFound(err)
+			continue
+		}
+		for _, a := range addrs {
+			if resolved, err := netip.ParseAddr(a); err == nil && resolved.Unmap() == addr {
+				return stats.BotVerified, hostname
+			}
+		}
+	}
+
+	hostname := ""
+	if len(names) > 0 {
+		hostname = strings.TrimSuffix(names[0], ".")
+	}
+	if failed {
+		return stats.BotUnknown, hostname
+	}
+	return stats.BotSpoofed, hostname
+}
+
+// isNotFound reports whether a lookup failed because the name does not
+// exist, rather than because DNS could not be reached.
+func isNotFound(err error) bool {
+	var dnsErr *net.DNSError
+	return errors.As(err, &dnsErr) && dnsErr.IsNotFound
+}
+
+// check is an IP waiting to be verified.
+type check struct {
+	ip  string
+	bot Bot
+}
+
+// Verifier checks IPs that claim to be search engine crawlers in the
+// background and stores the results.
+//
+// Verifier is safe for concurrent use.
+type Verifier struct {
+	config   Config
+	resolver Resolver
+	store    stats.BotVerificationStore
+	logger   *slog.Logger
+	now      func() time.Time
+	queue    chan check
+	mu       sync.Mutex
+	due      map[string]time.Time // When each queued or checked IP is next due for a check
+	dropped  atomic.Int64
+	stopChan chan struct{}
+	stopOnce sync.Once
+	wg       sync.WaitGroup
+}
+
+// NewVerifier creates a verifier and starts its workers.
+//
+// Zero values in config are replaced with the package defaults.
+//
+// Parameters:
+//   - config: verifier settings
+//   - resolver: the DNS resolver, such as net.DefaultResolver
+//   - store: where results are cached and looked up
+//   - logger: structured logger instance
+//
+// Returns a new Verifier instance. Call Stop when it is no longer needed.
+func NewVerifier(config Config, resolver Resolver, store stats.BotVerificationStore, logger *slog.Logger) *Verifier {
+	if config.TTL <= 0 {
+		config.TTL = DefaultTTL
+	}
+	if config.QueueSize <= 0 {
+		config.QueueSize = DefaultQueueSize
+	}
+	if config.Workers <= 0 {
+		config.Workers = DefaultWorkers
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = DefaultTimeout
+	}
+
+	v := &Verifier{
+		config:   config,
+		resolver: resolver,
+		store:    store,
+		logger:   logger,
+		now:      time.Now,
+		queue:    make(chan check, config.QueueSize),
+		due:      make(map[string]time.Time),
+		stopChan: make(chan struct{}),
+	}
+	for i := 0; i < config.Workers; i++ {
+		v.wg.Add(1)
+		go v.run()
+	}
+	return v
+}
+
+// Observe queues an IP for verification without blocking if its user agent
+// claims to be a known crawler and it has not been checked within the TTL.
+// If the queue is full the IP is dropped and checked on a later request.
+//
+// Parameters:
+//   - ip: the client IP address
+//   - userAgent: the client User-Agent header
+//
+// Returns true if the IP was queued.
+func (v *Verifier) Observe(ip, userAgent string) bool {
+	bot, ok := Claimed(userAgent)
+	if !ok {
+		return false
+	}
+
+	select {
+	case <-v.stopChan:
+		return false
+	default:
+	}
+
+	now := v.now()
+	v.mu.Lock()
+	if due, tracked := v.due[ip]; tracked && now.Before(due) {
+		v.mu.Unlock()
+		return false
+	}
+	// Hold off further requests while this check is queued
+	v.track(ip, now.Add(v.config.Timeout+unknownTTL))
+	v.mu.Unlock()
+
+	select {
+	case v.queue <- check{ip: ip, bot: bot}:
+		return true
+	default:
+		v.mu.Lock()
+		delete(v.due, ip)
+		v.mu.Unlock()
+		if v.dropped.Add(1) == 1 || v.dropped.Load()%100 == 0 {
+			v.logger.Warn("Bot verification queue full, dropping checks", "dropped", v.dropped.Load())
+		}
+		return false
+	}
+}
+
+// RecordHook returns a stats record hook that observes every recorded
+// request.
+//
+// Returns a hook for stats.Manager.AddRecordHook.
+func (v *Verifier) RecordHook() stats.RecordHook {
+	return func(req stats.RequestInfo, result stats.RecordResult) {
+		v.Observe(req.IP, req.UserAgent)
+	}
+}
+
+// Dropped returns the number of checks dropped because the queue was full.
+func (v *Verifier) Dropped() int64 {
+	return v.dropped.Load()
+}
+
+// track records when an IP is next due for a check. The caller must hold
+// the lock. Once maxTracked IPs are held, IPs no longer waiting are
+// forgotten, and new IPs are not tracked if none are.
+func (v *Verifier) track(ip string, due time.Time) {
+	if _, tracked := v.due[ip]; !tracked && len(v.due) >= maxTracked {
+		now := v.now()
+		for other, otherDue := range v.due {
+			if !now.Before(otherDue) {
+				delete(v.due, other)
+			}
+		}
+		if len(v.due) >= maxTracked {
+			return
+		}
+	}
+	v.due[ip] = due
+}
+
+// run checks queued IPs until the verifier is stopped.
+func (v *Verifier) run() {
+	defer v.wg.Done()
+	for {
+		select {
+		case c := <-v.queue:
+			v.verify(c)
+		case <-v.stopChan:
+			return
+		}
+	}
+}
+
+// verify checks an IP unless the store holds a result within the TTL, and
+// stores the result.
+func (v *Verifier) verify(c check) {
+	ctx, cancel := context.WithTimeout(context.Background(), v.config.Timeout)
+	defer cancel()
+
+	result, err := v.store.GetBotVerification(ctx, c.ip)
+	stored := err == nil && result.Bot == c.bot.Name && v.now().Before(v.expiry(result))
+	if !stored {
+		status, hostname := Verify(ctx, v.resolver, c.ip, c.bot)
+		result = stats.BotVerification{IP: c.ip, Bot: c.bot.Name, Status: status, Hostname: hostname, CheckedAt: v.now()}
+		if status == stats.BotSpoofed {
+			v.logger.Info("Spoofed search engine crawler", "ip", c.ip, "bot", c.bot.Name, "hostname", hostname)
+		} else {
+			v.logger.Debug("Search engine crawler checked", "ip", c.ip, "bot", c.bot.Name, "status", status, "hostname", hostname)
+		}
+	}
+
+	// Track the result before storing it, so that once it can be read the
+	// IP is not queued again within the TTL
+	v.mu.Lock()
+	v.track(c.ip, v.expiry(result))
+	v.mu.Unlock()
+
+	if !stored {
+		if err := v.store.SaveBotVerification(context.Background(), result); err != nil {
+			v.logger.Warn("Failed to save bot verification", "ip", c.ip, "error", err)
+		}
+	}
+}
+
+// expiry returns when a result should be checked again.
+func (v *Verifier) expiry(result stats.BotVerification) time.Time {
+	ttl := v.config.TTL
+	if result.Status == stats.BotUnknown && ttl > unknownTTL {
+		ttl = unknownTTL
+	}
+	return result.CheckedAt.Add(ttl)
+}
+
+// Stop stops the workers after any checks in progress finish. Queued
+// checks are discarded.
+//
+// Safe to call multiple times.
+func (v *Verifier) Stop() {
+	v.stopOnce.Do(func() {
+		close(v.stopChan)
+	})
+	v.wg.Wait()
+}
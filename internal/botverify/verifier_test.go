@@ -0,0 +1,206 @@
+package botverify
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rampantspark/gospidertrap/internal/stats"
+)
+
+// fakeResolver answers lookups from maps. Names missing from the maps do
+// not exist, and names in fail return a temporary error.
+type fakeResolver struct {
+	mu      sync.Mutex
+	ptr     map[string][]string
+	hosts   map[string][]string
+	fail    map[string]bool
+	lookups int
+}
+
+func (r *fakeResolver) LookupAddr(ctx context.Context, addr string) ([]string, error) {
+	return r.lookup(r.ptr, addr)
+}
+
+func (r *fakeResolver) LookupHost(ctx context.Context, host string) ([]string, error) {
+	return r.lookup(r.hosts, host)
+}
+
+func (r *fakeResolver) lookup(records map[string][]string, name string) ([]string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.lookups++
+	if r.fail[name] {
+		return nil, &net.DNSError{Err: "server misbehaving", Name: name, IsTemporary: true}
+	}
+	if answers, ok := records[name]; ok {
+		return answers, nil
+	}
+	return nil, &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+}
+
+func (r *fakeResolver) count() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.lookups
+}
+
+func newFakeResolver() *fakeResolver {
+	return &fakeResolver{
+		ptr: map[string][]string{
+			"66.249.66.1":       {"crawl-66-249-66-1.googlebot.com."},
+			"192.0.2.1":         {"crawl-192-0-2-1.googlebot.com."}, // Forward lookup does not match
+			"192.0.2.2":         {"host.evilgooglebot.com."},
+			"192.0.2.3":         {"crawl.googlebot.com."}, // Forward lookup fails
+			"157.55.39.1":       {"msnbot-157-55-39-1.search.msn.com."},
+			"2001:4860:4801::1": {"crawl-2001-4860-4801--1.googlebot.com."},
+		},
+		hosts: map[string][]string{
+			"crawl-66-249-66-1.googlebot.com":       {"66.249.66.1"},
+			"crawl-192-0-2-1.googlebot.com":         {"198.51.100.1"},
+			"host.evilgooglebot.com":                {"192.0.2.2"},
+			"msnbot-157-55-39-1.search.msn.com":     {"157.55.39.1"},
+			"crawl-2001-4860-4801--1.googlebot.com": {"2001:4860:4801:0:0:0:0:1"},
+		},
+		fail: map[string]bool{"192.0.2.9": true, "crawl.googlebot.com": true},
+	}
+}
+
+func TestClaimed(t *testing.T) {
+	tests := []struct {
+		userAgent string
+		want      string
+	}{
+		{"Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)", "Googlebot"},
+		{"Mozilla/5.0 (compatible; bingbot/2.0; +http://www.bing.com/bingbot.htm)", "Bingbot"},
+		{"AdsBot-Google (+http://www.google.com/adsbot.html)", "Googlebot"},
+		{"Mozilla/5.0 (compatible; YandexBot/3.0; +http://yandex.com/bots)", "YandexBot"},
+		{"Mozilla/5.0 (X11; Linux x86_64) Chrome/120.0 Safari/537.36", ""},
+		{"python-requests/2.31", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.userAgent, func(t *testing.T) {
+			bot, ok := Claimed(tt.userAgent)
+			if bot.Name != tt.want || ok != (tt.want != "") {
+				t.Errorf("Claimed() = %q, %v, want %q", bot.Name, ok, tt.want)
+			}
+		})
+	}
+}
+
+func TestVerify(t *testing.T) {
+	google, _ := Claimed("Googlebot")
+	bing, _ := Claimed("bingbot")
+	resolver := newFakeResolver()
+
+	tests := []struct {
+		name         string
+		ip           string
+		bot          Bot
+		wantStatus   string
+		wantHostname string
+	}{
+		{"forward-confirmed", "66.249.66.1", google, stats.BotVerified, "crawl-66-249-66-1.googlebot.com"},
+		{"IPv6", "2001:4860:4801::1", google, stats.BotVerified, "crawl-2001-4860-4801--1.googlebot.com"},
+		{"other crawler", "157.55.39.1", bing, stats.BotVerified, "msnbot-157-55-39-1.search.msn.com"},
+		{"claims the wrong crawler", "157.55.39.1", google, stats.BotSpoofed, "msnbot-157-55-39-1.search.msn.com"},
+		{"no reverse DNS", "203.0.113.1", google, stats.BotSpoofed, ""},
+		{"forward lookup mismatch", "192.0.2.1", google, stats.BotSpoofed, "crawl-192-0-2-1.googlebot.com"},
+		{"lookalike domain", "192.0.2.2", google, stats.BotSpoofed, "host.evilgooglebot.com"},
+		{"reverse lookup fails", "192.0.2.9", google, stats.BotUnknown, ""},
+		{"forward lookup fails", "192.0.2.3", google, stats.BotUnknown, "crawl.googlebot.com"},
+		{"invalid IP", "not-an-ip", google, stats.BotSpoofed, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, hostname := Verify(context.Background(), resolver, tt.ip, tt.bot)
+			if status != tt.wantStatus || hostname != tt.wantHostname {
+				t.Errorf("Verify() = %q, %q, want %q, %q", status, hostname, tt.wantStatus, tt.wantHostname)
+			}
+		})
+	}
+}
+
+// waitFor polls the store until it holds a result for ip.
+func waitFor(t *testing.T, store stats.BotVerificationStore, ip string) stats.BotVerification {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		v, err := store.GetBotVerification(context.Background(), ip)
+		if err == nil {
+			return v
+		}
+		if !errors.Is(err, stats.ErrNotFound) || time.Now().After(deadline) {
+			t.Fatalf("GetBotVerification(%s) error = %v", ip, err)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestVerifier(t *testing.T) {
+	resolver := newFakeResolver()
+	store := stats.NewMemoryStore(stats.NewStats())
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	v := NewVerifier(Config{TTL: time.Hour}, resolver, store, logger)
+	defer v.Stop()
+
+	if v.Observe("66.249.66.1", "Mozilla/5.0 (X11; Linux x86_64)") {
+		t.Error("IP queued for a user agent that claims no crawler")
+	}
+	if !v.Observe("66.249.66.1", "Googlebot/2.1") {
+		t.Fatal("claimed crawler not queued")
+	}
+	if got := waitFor(t, store, "66.249.66.1"); got.Status != stats.BotVerified || got.Bot != "Googlebot" {
+		t.Errorf("result = %+v, want a verified Googlebot", got)
+	}
+	if v.Observe("66.249.66.1", "Googlebot/2.1") {
+		t.Error("IP queued again within the TTL")
+	}
+
+	hook := v.RecordHook()
+	hook(stats.RequestInfo{IP: "203.0.113.1", UserAgent: "bingbot/2.0"}, stats.RecordResult{})
+	if got := waitFor(t, store, "203.0.113.1"); got.Status != stats.BotSpoofed {
+		t.Errorf("result = %+v, want spoofed", got)
+	}
+
+	// A new verifier uses stored results within the TTL instead of looking up again
+	lookups := resolver.count()
+	restarted := NewVerifier(Config{TTL: time.Hour}, resolver, store, logger)
+	defer restarted.Stop()
+	google, _ := Claimed("Googlebot/2.1")
+	restarted.verify(check{ip: "66.249.66.1", bot: google})
+	if resolver.count() != lookups {
+		t.Errorf("lookups = %d, want %d: stored result not reused", resolver.count(), lookups)
+	}
+}
+
+func TestVerifierRechecksAfterTTL(t *testing.T) {
+	resolver := newFakeResolver()
+	store := stats.NewMemoryStore(stats.NewStats())
+	v := NewVerifier(Config{TTL: time.Hour}, resolver, store, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	defer v.Stop()
+
+	now := time.Date(2025, 6, 10, 12, 0, 0, 0, time.UTC)
+	v.now = func() time.Time { return now }
+
+	// A failed check is retried sooner than the TTL
+	v.Observe("192.0.2.9", "Googlebot/2.1")
+	if got := waitFor(t, store, "192.0.2.9"); got.Status != stats.BotUnknown {
+		t.Fatalf("result = %+v, want unknown", got)
+	}
+	if v.Observe("192.0.2.9", "Googlebot/2.1") {
+		t.Error("unknown result rechecked immediately")
+	}
+
+	v.mu.Lock()
+	now = now.Add(unknownTTL)
+	v.mu.Unlock()
+	if !v.Observe("192.0.2.9", "Googlebot/2.1") {
+		t.Error("unknown result not rechecked after the retry interval")
+	}
+}
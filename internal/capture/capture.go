@@ -0,0 +1,170 @@
+// Package capture records the method, query string and a redacted, size
+// capped sample of the body of trap requests, which the request log does
+// not keep. Vulnerability scanners put their payloads there.
+package capture
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"time"
+
+	"github.com/rampantspark/gospidertrap/internal/stats"
+)
+
+// DefaultMaxBodySize is the default size of stored body samples in bytes.
+const DefaultMaxBodySize = 4096
+
+// DefaultRedactPattern matches the values of password, secret, token and
+// API key parameters in query strings, form bodies, JSON and headers-like
+// text. Its first group is the value that is redacted.
+const DefaultRedactPattern = `(?i)(?:pass(?:word|wd)?|pwd|secret|token|api[_-]?key|authorization)["']?\s*[=:]\s*["']?([^&"'\s,;}]+)`
+
+// Redacted replaces redacted text.
+const Redacted = "[REDACTED]"
+
+// Config holds capture settings.
+type Config struct {
+	MaxBodySize int              // Largest body sample stored, in bytes
+	Redact      []*regexp.Regexp // Patterns whose matches are replaced with Redacted
+}
+
+// ParseRedactPatterns compiles redaction patterns.
+//
+// Parameters:
+//   - patterns: regular expressions in RE2 syntax
+//
+// Returns the compiled patterns, or an error naming the first invalid one.
+func ParseRedactPatterns(patterns []string) ([]*regexp.Regexp, error) {
+	compiled := make([]*regexp.Regexp, 0, len(patterns))
+	for _, p := range patterns {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, fmt.Errorf("invalid redaction pattern %q: %w", p, err)
+		}
+		compiled = append(compiled, re)
+	}
+	return compiled, nil
+}
+
+// Redact replaces each match of the patterns in data with Redacted. If a
+// pattern has a capturing group, only the text of its first group is
+// replaced, so that for example a parameter name is kept and its value
+// redacted.
+//
+// Parameters:
+//   - data: the text or binary data
+//   - patterns: the redaction patterns
+//
+// Returns the redacted data, which is data itself if nothing matched.
+func Redact(data []byte, patterns []*regexp.Regexp) []byte {
+	for _, re := range patterns {
+		matches := re.FindAllSubmatchIndex(data, -1)
+		if matches == nil {
+			continue
+		}
+		var out bytes.Buffer
+		last := 0
+		for _, m := range matches {
+			start, end := m[0], m[1]
+			if len(m) >= 4 && m[2] >= 0 {
+				start, end = m[2], m[3]
+			}
+			out.Write(data[last:start])
+			out.WriteString(Redacted)
+			last = end
+		}
+		out.Write(data[last:])
+		data = out.Bytes()
+	}
+	return data
+}
+
+// Capturer records trap requests in a capture store.
+//
+// Capturer is safe for concurrent use.
+type Capturer struct {
+	config Config
+	store  stats.CaptureStore
+	logger *slog.Logger
+	now    func() time.Time
+}
+
+// NewCapturer creates a capturer.
+//
+// Parameters:
+//   - config: capture settings; a MaxBodySize of zero or less uses
+//     DefaultMaxBodySize
+//   - store: where captures are stored
+//   - logger: structured logger instance
+//
+// Returns a new Capturer instance.
+func NewCapturer(config Config, store stats.CaptureStore, logger *slog.Logger) *Capturer {
+	if config.MaxBodySize <= 0 {
+		config.MaxBodySize = DefaultMaxBodySize
+	}
+	return &Capturer{
+		config: config,
+		store:  store,
+		logger: logger,
+		now:    time.Now,
+	}
+}
+
+// Capture records a request if it has a query string, a body, or a method
+// other than GET and HEAD.
+//
+// The body is read up to the request body limit set by the server, and
+// r.Body is replaced with the bytes read so that later handlers can still
+// read it. The query string and body are redacted before the body is cut
+// to the maximum sample size.
+//
+// Parameters:
+//   - ctx: context for cancellation and timeout control
+//   - r: the trap request
+//   - ip: the client IP address
+//
+// Returns the recorded capture and true, or false if the request was not
+// recorded.
+func (c *Capturer) Capture(ctx context.Context, r *http.Request, ip string) (stats.RequestCapture, bool) {
+	hasBody := r.Body != nil && r.Body != http.NoBody && r.ContentLength != 0
+	if r.URL.RawQuery == "" && !hasBody && (r.Method == http.MethodGet || r.Method == http.MethodHead) {
+		return stats.RequestCapture{}, false
+	}
+
+	rc := stats.RequestCapture{
+		Timestamp:   c.now(),
+		IP:          ip,
+		UserAgent:   r.Header.Get("User-Agent"),
+		Method:      r.Method,
+		Path:        r.URL.Path,
+		Query:       string(Redact([]byte(r.URL.RawQuery), c.config.Redact)),
+		ContentType: r.Header.Get("Content-Type"),
+	}
+	if hasBody {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			// The body exceeded the server's limit or the client went away
+			c.logger.Debug("Failed to read request body", "ip", ip, "read", len(body), "error", err)
+			rc.Truncated = true
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		rc.BodySize = int64(len(body))
+		sample := Redact(body, c.config.Redact)
+		if len(sample) > c.config.MaxBodySize {
+			sample = sample[:c.config.MaxBodySize]
+			rc.Truncated = true
+		}
+		rc.Body = bytes.Clone(sample)
+	}
+
+	if err := c.store.SaveCapture(ctx, rc); err != nil {
+		c.logger.Warn("Failed to save request capture", "ip", ip, "error", err)
+	}
+	return rc, true
+}
@@ -0,0 +1,118 @@
+package capture
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/rampantspark/gospidertrap/internal/stats"
+)
+
+func TestRedact(t *testing.T) {
+	defaults := []*regexp.Regexp{regexp.MustCompile(DefaultRedactPattern)}
+
+	tests := []struct {
+		name     string
+		input    string
+		patterns []*regexp.Regexp
+		want     string
+	}{
+		{"query string", "user=bob&password=hunter2&x=1", defaults, "user=bob&password=[REDACTED]&x=1"},
+		{"JSON", `{"user":"bob","api_key":"abc123"}`, defaults, `{"user":"bob","api_key":"[REDACTED]"}`},
+		{"header-like", "Authorization: Bearer", defaults, "Authorization: [REDACTED]"},
+		{"nothing to redact", "q=hello", defaults, "q=hello"},
+		{"pattern without group", "card 4111111111111111 ok", []*regexp.Regexp{regexp.MustCompile(`\d{16}`)}, "card [REDACTED] ok"},
+		{"several patterns", "pwd=a&ssn=123-45-6789", []*regexp.Regexp{defaults[0], regexp.MustCompile(`\d{3}-\d{2}-\d{4}`)}, "pwd=[REDACTED]&ssn=[REDACTED]"},
+		{"no patterns", "password=x", nil, "password=x"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := string(Redact([]byte(tt.input), tt.patterns)); got != tt.want {
+				t.Errorf("Redact() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseRedactPatterns(t *testing.T) {
+	if _, err := ParseRedactPatterns([]string{DefaultRedactPattern, `\d+`}); err != nil {
+		t.Errorf("ParseRedactPatterns() error = %v", err)
+	}
+	if _, err := ParseRedactPatterns([]string{"("}); err == nil {
+		t.Error("ParseRedactPatterns() accepted an invalid pattern")
+	}
+}
+
+func TestCapture(t *testing.T) {
+	store := stats.NewMemoryStore(stats.NewStats())
+	patterns, _ := ParseRedactPatterns([]string{DefaultRedactPattern})
+	c := NewCapturer(Config{MaxBodySize: 16, Redact: patterns}, store, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	tests := []struct {
+		name          string
+		method        string
+		target        string
+		body          string
+		wantCaptured  bool
+		wantQuery     string
+		wantBody      string
+		wantSize      int64
+		wantTruncated bool
+	}{
+		{"plain GET", "GET", "/page", "", false, "", "", 0, false},
+		{"query string", "GET", "/search?q=1&token=abc", "", true, "q=1&token=[REDACTED]", "", 0, false},
+		{"DELETE without body", "DELETE", "/users/1", "", true, "", "", 0, false},
+		{"small body", "POST", "/login", "pass=x", true, "", "pass=[REDACTED]", 6, false},
+		{"large body", "PUT", "/upload", strings.Repeat("z", 40), true, "", strings.Repeat("z", 16), 40, true},
+		{"binary body", "POST", "/bin", "\x00\x01\xff", true, "", "\x00\x01\xff", 3, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var body io.Reader
+			if tt.body != "" {
+				body = strings.NewReader(tt.body)
+			}
+			r := httptest.NewRequest(tt.method, tt.target, body)
+
+			got, captured := c.Capture(context.Background(), r, "192.0.2.1")
+			if captured != tt.wantCaptured {
+				t.Fatalf("Capture() captured = %v, want %v", captured, tt.wantCaptured)
+			}
+			if !captured {
+				return
+			}
+			if got.Method != tt.method || got.Query != tt.wantQuery || string(got.Body) != tt.wantBody ||
+				got.BodySize != tt.wantSize || got.Truncated != tt.wantTruncated {
+				t.Errorf("Capture() = %+v, want query %q body %q size %d truncated %v",
+					got, tt.wantQuery, tt.wantBody, tt.wantSize, tt.wantTruncated)
+			}
+
+			// The full, unredacted body is left for later readers
+			if rest, _ := io.ReadAll(r.Body); string(rest) != tt.body {
+				t.Errorf("body left = %q, want %q", rest, tt.body)
+			}
+		})
+	}
+
+	saved, _ := store.GetCaptures(context.Background(), "", 10)
+	if len(saved) != 5 {
+		t.Errorf("stored %d captures, want 5", len(saved))
+	}
+}
+
+func TestCaptureOverLimit(t *testing.T) {
+	store := stats.NewMemoryStore(stats.NewStats())
+	c := NewCapturer(Config{}, store, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	r := httptest.NewRequest("POST", "/", strings.NewReader(strings.Repeat("a", 100)))
+	r.Body = http.MaxBytesReader(httptest.NewRecorder(), r.Body, 10)
+
+	got, _ := c.Capture(context.Background(), r, "192.0.2.1")
+	if got.BodySize != 10 || !got.Truncated {
+		t.Errorf("Capture() = size %d truncated %v, want 10 bytes truncated", got.BodySize, got.Truncated)
+	}
+}
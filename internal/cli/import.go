@@ -0,0 +1,113 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/signal"
+	"strconv"
+
+	"github.com/rampantspark/gospidertrap/internal/stats"
+)
+
+// importedFile is the import result for one input file.
+type importedFile struct {
+	File string `json:"file"`
+	stats.ImportResult
+}
+
+// RunImport runs "gospidertrap import -source NAME [flags] FILE...".
+//
+// Each file may be an NDJSON export, a gzipped file mode request log or
+// another instance's SQLite database; "-" reads from stdin. The database is
+// opened for writing and migrated like the server does, so the import
+// should not run while the server is writing to it; use the admin import
+// endpoint instead.
+//
+// Parameters:
+//   - args: the arguments after "import"
+//   - stdin: read for the file "-"
+//   - stdout: where results are written
+//   - stderr: where usage and errors are written
+//
+// Returns the process exit code: 0 on success, 1 if an import failed,
+// 2 on a usage error.
+func RunImport(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: gospidertrap import -source NAME [flags] FILE...")
+		fmt.Fprintln(stderr)
+		fmt.Fprintln(stderr, "Merge NDJSON exports, gzipped request logs or SQLite databases from another")
+		fmt.Fprintln(stderr, "instance into the stats database. Requests imported before are skipped.")
+		fmt.Fprintln(stderr)
+		fmt.Fprintln(stderr, "Flags:")
+		fs.PrintDefaults()
+	}
+	dbPath := fs.String("db-path", DefaultDBPath, "Path to SQLite database file")
+	source := fs.String("source", "", "Name of the instance that recorded the data (required)")
+	asJSON := fs.Bool("json", false, "Print JSON instead of a table")
+
+	files, err := parseInterspersed(fs, args)
+	if err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return 2
+	}
+	if *source == "" || len(files) == 0 {
+		fmt.Fprintln(stderr, "Error: -source and at least one file are required")
+		fmt.Fprintln(stderr)
+		fs.Usage()
+		return 2
+	}
+
+	logger := slog.New(slog.NewTextHandler(stderr, &slog.HandlerOptions{Level: slog.LevelWarn}))
+	db, err := stats.NewDatabase(*dbPath, logger)
+	if err != nil {
+		fmt.Fprintf(stderr, "Error: %v\n", err)
+		return 1
+	}
+	defer db.Close()
+
+	// Stop between batches on Ctrl-C; completed batches stay imported
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	var results []importedFile
+	var importErr error
+	for _, file := range files {
+		var result stats.ImportResult
+		if file == "-" {
+			result, err = db.Import(ctx, stdin, *source)
+		} else {
+			result, err = db.ImportFile(ctx, file, *source)
+		}
+		results = append(results, importedFile{file, result})
+		if err != nil {
+			importErr = fmt.Errorf("%s: %w", file, err)
+			break
+		}
+	}
+
+	out := &output{w: stdout, json: *asJSON}
+	rows := make([][]string, len(results))
+	for i, r := range results {
+		rows[i] = []string{
+			r.File, strconv.Itoa(r.Imported), strconv.Itoa(r.Duplicates), strconv.Itoa(r.Invalid),
+		}
+	}
+	if err := out.print(results, []string{"FILE", "IMPORTED", "DUPLICATES", "INVALID"}, rows); err != nil {
+		fmt.Fprintf(stderr, "Error: %v\n", err)
+		return 1
+	}
+	if importErr != nil {
+		fmt.Fprintf(stderr, "Error: %v\n", importErr)
+		return 1
+	}
+	return 0
+}
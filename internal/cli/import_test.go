@@ -0,0 +1,63 @@
+package cli
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRunImport(t *testing.T) {
+	otherPath := newTestDB(t)
+	dbPath := filepath.Join(t.TempDir(), "stats.db")
+
+	ndjson := filepath.Join(t.TempDir(), "export.ndjson")
+	line := `{"IP":"10.0.0.9","UserAgent":"bot","Path":"/x","Timestamp":"2025-06-10T12:00:00Z"}` + "\n"
+	if err := os.WriteFile(ndjson, []byte(line+"not json\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		args     []string
+		stdin    string
+		wantCode int
+		want     []string // Substrings of stdout, with runs of spaces collapsed
+		wantErr  string   // Substring of stderr
+	}{
+		{"database", []string{"-source", "trap-b", otherPath}, "", 0, []string{"FILE IMPORTED DUPLICATES INVALID", otherPath + " 5 0 0"}, ""},
+		{"database again", []string{otherPath, "-source", "trap-b"}, "", 0, []string{otherPath + " 0 5 0"}, ""},
+		{"ndjson and stdin", []string{"-source", "trap-c", ndjson, "-"}, line, 0, []string{"export.ndjson 1 0 1", "- 0 1 0"}, ""},
+		{"missing source", []string{otherPath}, "", 2, nil, "-source"},
+		{"missing file", []string{"-source", "trap-b", ndjson + ".missing"}, "", 1, nil, "failed to open import file"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			args := append([]string{"-db-path", dbPath}, tt.args...)
+			code := RunImport(args, strings.NewReader(tt.stdin), &stdout, &stderr)
+			if code != tt.wantCode {
+				t.Fatalf("RunImport() = %d, want %d\nstdout:\n%s\nstderr:\n%s", code, tt.wantCode, stdout.String(), stderr.String())
+			}
+			got := strings.Join(strings.Fields(stdout.String()), " ")
+			for _, want := range tt.want {
+				if !strings.Contains(got, want) {
+					t.Errorf("stdout missing %q:\n%s", want, stdout.String())
+				}
+			}
+			if !strings.Contains(stderr.String(), tt.wantErr) {
+				t.Errorf("stderr missing %q:\n%s", tt.wantErr, stderr.String())
+			}
+		})
+	}
+
+	// The merged database has the other instance's requests plus the NDJSON line
+	var stdout, stderr bytes.Buffer
+	if code := RunStats([]string{"summary", "-db-path", dbPath}, &stdout, &stderr); code != 0 {
+		t.Fatalf("RunStats() = %d, stderr:\n%s", code, stderr.String())
+	}
+	if got := strings.Join(strings.Fields(stdout.String()), " "); !strings.Contains(got, "Total requests 6") {
+		t.Errorf("summary after import:\n%s", stdout.String())
+	}
+}
@@ -0,0 +1,509 @@
+// Package cli implements gospidertrap's offline subcommands.
+package cli
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/rampantspark/gospidertrap/internal/stats"
+)
+
+// DefaultDBPath is the database the stats subcommands open by default,
+// matching the server's default.
+const DefaultDBPath = "data/stats.db"
+
+// timeFormat is used for timestamps in table output.
+const timeFormat = "2006-01-02 15:04:05"
+
+// statsCommand is one "stats" subcommand.
+type statsCommand struct {
+	name    string
+	args    string // Positional argument synopsis
+	summary string
+
+	// setup registers the command's flags and returns the function that
+	// runs it once flags are parsed.
+	setup func(fs *flag.FlagSet) func(ctx context.Context, db *stats.Database, args []string, out *output) error
+}
+
+// statsCommands lists the subcommands in the order they are shown in usage.
+var statsCommands = []statsCommand{
+	{"summary", "", "Show totals, database size and schema version", setupSummary},
+	{"top-ips", "", "Show the IP addresses with the most requests", setupTopIPs},
+	{"top-countries", "", "Show the countries with the most requests (needs -geoip-city)", setupTopGeo("countries", "COUNTRY", (*stats.Database).GetTopCountries)},
+	{"top-asns", "", "Show the autonomous systems with the most requests (needs -geoip-asn)", setupTopGeo("autonomous systems", "NETWORK", (*stats.Database).GetTopASNs)},
+	{"ip", "ADDRESS", "Show everything recorded about one IP address", setupIP},
+	{"sessions", "", "Group requests into per-IP sessions", setupSessions},
+	{"bots", "", "Show IPs claiming to be search engine crawlers and whether reverse DNS verified them", setupBots},
+	{"export", "", "Write the request log as NDJSON or CSV", setupExport},
+}
+
+// RunStats runs "gospidertrap stats COMMAND [flags]".
+//
+// The database is opened read-only, so the commands can run while the
+// server is using it.
+//
+// Parameters:
+//   - args: the arguments after "stats"
+//   - stdout: where results are written
+//   - stderr: where usage and errors are written
+//
+// Returns the process exit code: 0 on success, 1 if the command failed,
+// 2 on a usage error.
+func RunStats(args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 || args[0] == "help" || args[0] == "-h" || args[0] == "--help" {
+		printStatsUsage(stderr)
+		if len(args) == 0 {
+			return 2
+		}
+		return 0
+	}
+
+	var cmd *statsCommand
+	for i := range statsCommands {
+		if statsCommands[i].name == args[0] {
+			cmd = &statsCommands[i]
+		}
+	}
+	if cmd == nil {
+		fmt.Fprintf(stderr, "unknown stats command: %s\n\n", args[0])
+		printStatsUsage(stderr)
+		return 2
+	}
+
+	fs := flag.NewFlagSet("stats "+cmd.name, flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(stderr, "Usage: gospidertrap stats %s [flags] %s\n\n%s.\n\nFlags:\n", cmd.name, cmd.args, cmd.summary)
+		fs.PrintDefaults()
+	}
+	dbPath := fs.String("db-path", DefaultDBPath, "Path to SQLite database file")
+	asJSON := fs.Bool("json", false, "Print JSON instead of a table")
+	run := cmd.setup(fs)
+
+	positional, err := parseInterspersed(fs, args[1:])
+	if err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return 2
+	}
+
+	logger := slog.New(slog.NewTextHandler(stderr, &slog.HandlerOptions{Level: slog.LevelWarn}))
+	db, err := stats.OpenDatabaseReadOnly(*dbPath, logger)
+	if err != nil {
+		fmt.Fprintf(stderr, "Error: %v\n", err)
+		return 1
+	}
+	defer db.Close()
+
+	// Stop long-running queries on Ctrl-C
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	out := &output{w: stdout, json: *asJSON}
+	if err := run(ctx, db, positional, out); err != nil {
+		var usageErr usageError
+		if errors.As(err, &usageErr) {
+			fmt.Fprintf(stderr, "Error: %v\n\n", err)
+			fs.Usage()
+			return 2
+		}
+		fmt.Fprintf(stderr, "Error: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// printStatsUsage lists the stats subcommands.
+func printStatsUsage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: gospidertrap stats COMMAND [flags]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Query the stats database read-only; safe to run while the server is running.")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Commands:")
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, cmd := range statsCommands {
+		fmt.Fprintf(tw, "  %s %s\t%s\n", cmd.name, cmd.args, cmd.summary)
+	}
+	tw.Flush()
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Run 'gospidertrap stats COMMAND -h' for a command's flags.")
+}
+
+// parseInterspersed parses flags that may appear before or after
+// positional arguments, which the flag package does not allow by itself.
+//
+// Returns the positional arguments in order.
+func parseInterspersed(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		if fs.NArg() == 0 {
+			return positional, nil
+		}
+		positional = append(positional, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+}
+
+// usageError reports invalid command arguments.
+type usageError string
+
+func (e usageError) Error() string { return string(e) }
+
+// requireArgs checks the number of positional arguments.
+func requireArgs(args []string, n int) error {
+	if len(args) != n {
+		return usageError(fmt.Sprintf("expected %d argument(s), got %d", n, len(args)))
+	}
+	return nil
+}
+
+// sinceTime converts a -since duration into a start time (zero for none).
+func sinceTime(since time.Duration) time.Time {
+	if since <= 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(-since)
+}
+
+// output writes command results as a table or JSON.
+type output struct {
+	w    io.Writer
+	json bool
+}
+
+// print writes v as indented JSON, or headers and rows as an aligned table.
+func (o *output) print(v any, headers []string, rows [][]string) error {
+	if o.json {
+		enc := json.NewEncoder(o.w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+	}
+
+	tw := tabwriter.NewWriter(o.w, 0, 0, 2, ' ', 0)
+	if len(headers) > 0 {
+		fmt.Fprintln(tw, strings.Join(headers, "\t"))
+	}
+	for _, row := range rows {
+		fmt.Fprintln(tw, strings.Join(row, "\t"))
+	}
+	return tw.Flush()
+}
+
+// section writes a titled table, separated from the previous one by a
+// blank line. It does nothing in JSON mode.
+func (o *output) section(title string, headers []string, rows [][]string) error {
+	if o.json {
+		return nil
+	}
+	fmt.Fprintf(o.w, "\n%s\n", title)
+	if len(rows) == 0 {
+		fmt.Fprintln(o.w, "  (none)")
+		return nil
+	}
+	return o.print(nil, headers, rows)
+}
+
+// formatTime formats a timestamp for table output.
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.Local().Format(timeFormat)
+}
+
+// countRows converts count entries to table rows.
+func countRows(entries []stats.CountEntry) [][]string {
+	rows := make([][]string, len(entries))
+	for i, entry := range entries {
+		rows[i] = []string{strconv.Itoa(entry.Count), entry.Label}
+	}
+	return rows
+}
+
+// setupSummary registers the summary command.
+func setupSummary(fs *flag.FlagSet) func(context.Context, *stats.Database, []string, *output) error {
+	return func(ctx context.Context, db *stats.Database, args []string, out *output) error {
+		if err := requireArgs(args, 0); err != nil {
+			return err
+		}
+
+		summary, err := db.GetSummary(ctx)
+		if err != nil {
+			return err
+		}
+		storage, err := db.GetStorageInfo(ctx)
+		if err != nil {
+			return err
+		}
+		version, err := db.SchemaVersion(ctx)
+		if err != nil {
+			return err
+		}
+
+		result := struct {
+			StartTime        time.Time `json:"startTime"`
+			TotalRequests    int       `json:"totalRequests"`
+			UniqueIPs        int       `json:"uniqueIPs"`
+			UniqueUserAgents int       `json:"uniqueUserAgents"`
+			RequestLogRows   int       `json:"requestLogRows"`
+			OldestRecord     time.Time `json:"oldestRecord"`
+			RolledUp         int       `json:"rolledUp"`
+			SizeBytes        int64     `json:"sizeBytes"`
+			SchemaVersion    int       `json:"schemaVersion"`
+		}{
+			summary.StartTime, summary.TotalRequests, summary.UniqueIPs, summary.UniqueUserAgents,
+			storage.RequestLogRows, storage.OldestRecord, storage.RolledUp, storage.SizeBytes, version,
+		}
+		return out.print(result, nil, [][]string{
+			{"Collecting since", formatTime(result.StartTime)},
+			{"Total requests", strconv.Itoa(result.TotalRequests)},
+			{"Unique IPs", strconv.Itoa(result.UniqueIPs)},
+			{"Unique user agents", strconv.Itoa(result.UniqueUserAgents)},
+			{"Request log rows", strconv.Itoa(result.RequestLogRows)},
+			{"Oldest logged request", formatTime(result.OldestRecord)},
+			{"Pruned into daily totals", strconv.Itoa(result.RolledUp)},
+			{"Database size", strconv.FormatInt(result.SizeBytes, 10) + " bytes"},
+			{"Schema version", strconv.Itoa(result.SchemaVersion)},
+		})
+	}
+}
+
+// setupTopIPs registers the top-ips command.
+func setupTopIPs(fs *flag.FlagSet) func(context.Context, *stats.Database, []string, *output) error {
+	since := fs.Duration("since", 0, "Only count requests in this window, e.g. 24h (default: all time)")
+	limit := fs.Int("limit", 10, "Number of IPs to show")
+
+	return func(ctx context.Context, db *stats.Database, args []string, out *output) error {
+		if err := requireArgs(args, 0); err != nil {
+			return err
+		}
+
+		var entries []stats.CountEntry
+		var err error
+		if *since > 0 {
+			entries, err = db.GetTopIPsSince(ctx, sinceTime(*since), *limit)
+		} else {
+			entries, err = db.GetTopIPs(ctx, *limit)
+		}
+		if err != nil {
+			return err
+		}
+		if entries == nil {
+			entries = []stats.CountEntry{}
+		}
+		return out.print(entries, []string{"REQUESTS", "IP"}, countRows(entries))
+	}
+}
+
+// setupTopGeo returns the setup of a command listing the top entries of a
+// GeoIP annotation of ip_counts, which the server fills in when started
+// with GeoIP databases.
+//
+// Parameters:
+//   - what: the entries, for the flag help
+//   - column: the heading of the label column
+//   - top: the query returning the entries
+func setupTopGeo(what, column string, top func(*stats.Database, context.Context, int) ([]stats.CountEntry, error)) func(fs *flag.FlagSet) func(context.Context, *stats.Database, []string, *output) error {
+	return func(fs *flag.FlagSet) func(context.Context, *stats.Database, []string, *output) error {
+		limit := fs.Int("limit", 10, "Number of "+what+" to show")
+
+		return func(ctx context.Context, db *stats.Database, args []string, out *output) error {
+			if err := requireArgs(args, 0); err != nil {
+				return err
+			}
+			entries, err := top(db, ctx, *limit)
+			if err != nil {
+				return err
+			}
+			if entries == nil {
+				entries = []stats.CountEntry{}
+			}
+			return out.print(entries, []string{"REQUESTS", column}, countRows(entries))
+		}
+	}
+}
+
+// setupIP registers the ip command.
+func setupIP(fs *flag.FlagSet) func(context.Context, *stats.Database, []string, *output) error {
+	limit := fs.Int("limit", 10, "Number of user agents, paths, sessions and recent requests to show")
+
+	return func(ctx context.Context, db *stats.Database, args []string, out *output) error {
+		if err := requireArgs(args, 1); err != nil {
+			return err
+		}
+
+		detail, err := db.GetIPDetail(ctx, args[0], *limit)
+		if err != nil {
+			return err
+		}
+		if out.json {
+			return out.print(detail, nil, nil)
+		}
+
+		err = out.print(nil, nil, [][]string{
+			{"IP", detail.IP},
+			{"Requests", strconv.Itoa(detail.Count)},
+			{"First seen", formatTime(detail.FirstSeen)},
+			{"Last seen", formatTime(detail.LastSeen)},
+			{"Country", detail.Geo.Country},
+			{"Network", detail.Geo.Network()},
+			{"Claims to be", botSummary(detail.Bot)},
+			{"In request log", strconv.Itoa(detail.Logged)},
+		})
+		if err != nil {
+			return err
+		}
+		if err := out.section("User agents", []string{"REQUESTS", "USER AGENT"}, countRows(detail.UserAgents)); err != nil {
+			return err
+		}
+		if err := out.section("Paths", []string{"REQUESTS", "PATH"}, countRows(detail.Paths)); err != nil {
+			return err
+		}
+		sessions := make([][]string, len(detail.Sessions))
+		for i, session := range detail.Sessions {
+			sessions[i] = []string{formatTime(session.Start), session.Duration().Round(time.Second).String(), strconv.Itoa(session.Requests), strconv.Itoa(session.Paths)}
+		}
+		if err := out.section("Sessions", []string{"START", "DURATION", "REQUESTS", "PATHS"}, sessions); err != nil {
+			return err
+		}
+		recent := make([][]string, len(detail.Recent))
+		for i, req := range detail.Recent {
+			recent[i] = []string{formatTime(req.Timestamp), req.Path, req.UserAgent}
+		}
+		return out.section("Recent requests", []string{"TIME", "PATH", "USER AGENT"}, recent)
+	}
+}
+
+// botSummary describes a bot verification like "Googlebot (verified,
+// crawl-66-249-66-1.googlebot.com)", or returns "" if there is none.
+func botSummary(v *stats.BotVerification) string {
+	if v == nil {
+		return ""
+	}
+	if v.Hostname == "" {
+		return fmt.Sprintf("%s (%s)", v.Bot, v.Status)
+	}
+	return fmt.Sprintf("%s (%s, %s)", v.Bot, v.Status, v.Hostname)
+}
+
+// setupBots registers the bots command.
+func setupBots(fs *flag.FlagSet) func(context.Context, *stats.Database, []string, *output) error {
+	status := fs.String("status", "", "Only show IPs with this status: verified, spoofed or unknown")
+	limit := fs.Int("limit", 50, "Number of IPs to show")
+
+	return func(ctx context.Context, db *stats.Database, args []string, out *output) error {
+		if err := requireArgs(args, 0); err != nil {
+			return err
+		}
+		switch *status {
+		case "", stats.BotVerified, stats.BotSpoofed, stats.BotUnknown:
+		default:
+			return usageError("-status must be verified, spoofed or unknown")
+		}
+
+		bots, err := db.GetBotVerifications(ctx, *status, *limit)
+		if err != nil {
+			return err
+		}
+		if bots == nil {
+			bots = []stats.BotVerification{}
+		}
+		rows := make([][]string, len(bots))
+		for i, v := range bots {
+			rows[i] = []string{formatTime(v.CheckedAt), v.IP, v.Bot, v.Status, v.Hostname}
+		}
+		return out.print(bots, []string{"CHECKED", "IP", "CLAIMS", "STATUS", "REVERSE DNS"}, rows)
+	}
+}
+
+// setupSessions registers the sessions command.
+func setupSessions(fs *flag.FlagSet) func(context.Context, *stats.Database, []string, *output) error {
+	since := fs.Duration("since", 24*time.Hour, "Only include requests in this window (0 for all)")
+	gap := fs.Duration("gap", stats.DefaultSessionGap, "Idle time after which a new session starts")
+	minRequests := fs.Int("min-requests", 1, "Only show sessions with at least this many requests")
+	limit := fs.Int("limit", 50, "Number of sessions to show (0 for all)")
+
+	return func(ctx context.Context, db *stats.Database, args []string, out *output) error {
+		if err := requireArgs(args, 0); err != nil {
+			return err
+		}
+		if *gap <= 0 {
+			return usageError("-gap must be positive")
+		}
+
+		all, err := db.GetSessions(ctx, sinceTime(*since), *gap)
+		if err != nil {
+			return err
+		}
+		sessions := []stats.Session{}
+		for _, session := range all {
+			if session.Requests < *minRequests {
+				continue
+			}
+			if *limit > 0 && len(sessions) >= *limit {
+				break
+			}
+			sessions = append(sessions, session)
+		}
+
+		rows := make([][]string, len(sessions))
+		for i, s := range sessions {
+			rows[i] = []string{
+				s.IP, formatTime(s.Start), s.Duration().Round(time.Second).String(),
+				strconv.Itoa(s.Requests), strconv.Itoa(s.Paths), strings.Join(s.UserAgents, ", "),
+			}
+		}
+		return out.print(sessions, []string{"IP", "START", "DURATION", "REQUESTS", "PATHS", "USER AGENTS"}, rows)
+	}
+}
+
+// setupExport registers the export command.
+func setupExport(fs *flag.FlagSet) func(context.Context, *stats.Database, []string, *output) error {
+	since := fs.Duration("since", 0, "Only export requests in this window (default: all)")
+	format := fs.String("format", "ndjson", "Output format: ndjson or csv")
+
+	return func(ctx context.Context, db *stats.Database, args []string, out *output) error {
+		if err := requireArgs(args, 0); err != nil {
+			return err
+		}
+
+		switch *format {
+		case "ndjson":
+			// Same encoding as the file mode request log
+			enc := json.NewEncoder(out.w)
+			return db.ExportRequests(ctx, sinceTime(*since), func(req stats.RequestInfo) error {
+				return enc.Encode(req)
+			})
+		case "csv":
+			w := csv.NewWriter(out.w)
+			w.Write([]string{"timestamp", "ip", "user_agent", "path", "source", "bait"})
+			err := db.ExportRequests(ctx, sinceTime(*since), func(req stats.RequestInfo) error {
+				return w.Write([]string{req.Timestamp.UTC().Format(time.RFC3339Nano), req.IP, req.UserAgent, req.Path, req.Source, req.Bait})
+			})
+			w.Flush()
+			if err != nil {
+				return err
+			}
+			return w.Error()
+		default:
+			return usageError(fmt.Sprintf("unknown format: %s (expected ndjson or csv)", *format))
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rampantspark/gospidertrap/internal/geoip"
+	"github.com/rampantspark/gospidertrap/internal/stats"
+)
+
+// newTestDB creates a database with a few recorded requests and returns
+// its path. The database stays open for writing, like a running server.
+func newTestDB(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "stats.db")
+	db, err := stats.NewDatabase(path, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err != nil {
+		t.Fatalf("NewDatabase() error = %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	geo, err := geoip.NewResolver("../geoip/testdata/city.mmdb", "../geoip/testdata/asn.mmdb")
+	if err != nil {
+		t.Fatalf("NewResolver() error = %v", err)
+	}
+	t.Cleanup(func() { geo.Close() })
+	db.SetGeoIP(geo)
+
+	now := time.Now()
+	_, err = db.RecordRequests(context.Background(), []stats.RequestInfo{
+		{IP: "10.0.0.1", UserAgent: "bot", Path: "/a", Timestamp: now.Add(-48 * time.Hour)},
+		{IP: "10.0.0.1", UserAgent: "bot", Path: "/b", Timestamp: now.Add(-47 * time.Hour)},
+		{IP: "10.0.0.1", UserAgent: "bot", Path: "/c", Timestamp: now.Add(-46 * time.Hour)},
+		{IP: "10.0.0.2", UserAgent: "curl", Path: "/a", Timestamp: now.Add(-time.Hour)},
+		{IP: "10.0.0.2", UserAgent: "curl", Path: "/d", Timestamp: now.Add(-time.Minute)},
+	})
+	if err != nil {
+		t.Fatalf("RecordRequests() error = %v", err)
+	}
+	err = db.SaveBotVerification(context.Background(), stats.BotVerification{
+		IP: "10.0.0.1", Bot: "Googlebot", Status: stats.BotSpoofed, CheckedAt: now.Add(-46 * time.Hour),
+	})
+	if err != nil {
+		t.Fatalf("SaveBotVerification() error = %v", err)
+	}
+	return path
+}
+
+func TestRunStats(t *testing.T) {
+	dbPath := newTestDB(t)
+
+	tests := []struct {
+		name     string
+		args     []string
+		wantCode int
+		want     []string // Substrings of stdout
+		wantErr  string   // Substring of stderr
+	}{
+		{"summary", []string{"summary"}, 0, []string{"Total requests", "5", "Schema version"}, ""},
+		{"top ips", []string{"top-ips"}, 0, []string{"REQUESTS  IP", "3         10.0.0.1", "2         10.0.0.2"}, ""},
+		{"top ips since", []string{"top-ips", "--since", "24h"}, 0, []string{"2         10.0.0.2"}, ""},
+		{"top countries", []string{"top-countries"}, 0, []string{"REQUESTS  COUNTRY", "5         US"}, ""},
+		{"top asns", []string{"top-asns"}, 0, []string{"5         AS64496 Example Hosting"}, ""},
+		{"ip with trailing flag", []string{"ip", "10.0.0.2", "-limit", "1"}, 0, []string{"Requests        2", "/d", "AS64496 Example Hosting"}, ""},
+		{"ip claiming to be a crawler", []string{"ip", "10.0.0.1"}, 0, []string{"Claims to be    Googlebot (spoofed)"}, ""},
+		{"bots", []string{"bots"}, 0, []string{"CHECKED", "10.0.0.1", "Googlebot", "spoofed"}, ""},
+		{"bots by status", []string{"bots", "-status", "verified"}, 0, []string{"CHECKED"}, ""},
+		{"bots with invalid status", []string{"bots", "-status", "fake"}, 2, nil, "-status must be"},
+		{"unknown ip", []string{"ip", "10.9.9.9"}, 1, nil, "not found"},
+		{"ip without address", []string{"ip"}, 2, nil, "expected 1 argument"},
+		{"sessions", []string{"sessions", "-since", "0"}, 0, []string{"10.0.0.2", "10.0.0.1"}, ""},
+		{"export csv", []string{"export", "-format", "csv", "-since", "2h"}, 0, []string{"timestamp,ip,user_agent,path,source,bait", "10.0.0.2,curl,/d,,"}, ""},
+		{"bad format", []string{"export", "-format", "xml"}, 2, nil, "unknown format"},
+		{"unknown command", []string{"frobnicate"}, 2, nil, "unknown stats command"},
+		{"missing database", []string{"summary", "-db-path", dbPath + ".missing"}, 1, nil, "failed to open database"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			// A -db-path in the case's own arguments comes later and wins
+			args := append([]string{tt.args[0], "-db-path", dbPath}, tt.args[1:]...)
+			code := RunStats(args, &stdout, &stderr)
+			if code != tt.wantCode {
+				t.Fatalf("RunStats() = %d, want %d\nstdout:\n%s\nstderr:\n%s", code, tt.wantCode, stdout.String(), stderr.String())
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(stdout.String(), want) {
+					t.Errorf("stdout missing %q:\n%s", want, stdout.String())
+				}
+			}
+			if !strings.Contains(stderr.String(), tt.wantErr) {
+				t.Errorf("stderr missing %q:\n%s", tt.wantErr, stderr.String())
+			}
+		})
+	}
+}
+
+func TestRunStatsJSON(t *testing.T) {
+	dbPath := newTestDB(t)
+
+	var stdout, stderr bytes.Buffer
+	if code := RunStats([]string{"top-ips", "-json", "-db-path", dbPath}, &stdout, &stderr); code != 0 {
+		t.Fatalf("RunStats() = %d, stderr:\n%s", code, stderr.String())
+	}
+	var entries []stats.CountEntry
+	if err := json.Unmarshal(stdout.Bytes(), &entries); err != nil {
+		t.Fatalf("output is not JSON: %v\n%s", err, stdout.String())
+	}
+	if len(entries) != 2 || entries[0] != (stats.CountEntry{Label: "10.0.0.1", Count: 3}) {
+		t.Errorf("entries = %v, want 10.0.0.1 first with 3 requests", entries)
+	}
+
+	// Export is NDJSON in the request log's own encoding
+	stdout.Reset()
+	if code := RunStats([]string{"export", "-db-path", dbPath}, &stdout, &stderr); code != 0 {
+		t.Fatalf("RunStats() = %d, stderr:\n%s", code, stderr.String())
+	}
+	lines := strings.Split(strings.TrimSpace(stdout.String()), "\n")
+	if len(lines) != 5 {
+		t.Fatalf("exported %d lines, want 5", len(lines))
+	}
+	var first stats.RequestInfo
+	if err := json.Unmarshal([]byte(lines[0]), &first); err != nil || first.Path != "/a" || first.IP != "10.0.0.1" {
+		t.Errorf("first exported line = %s (error %v), want the oldest request", lines[0], err)
+	}
+}
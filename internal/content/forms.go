@@ -0,0 +1,93 @@
+package content
+
+import (
+	"fmt"
+	"strings"
+)
+
+// FormKind names a kind of trap form.
+type FormKind string
+
+// Supported form kinds.
+const (
+	FormLogin   FormKind = "login"
+	FormSearch  FormKind = "search"
+	FormContact FormKind = "contact"
+	FormComment FormKind = "comment"
+)
+
+// FormKinds lists every supported form kind.
+var FormKinds = []FormKind{FormLogin, FormSearch, FormContact, FormComment}
+
+// Kinds of honeytoken a form field can be prefilled with.
+const (
+	TokenEmail    = "email"
+	TokenPassword = "password"
+	TokenAPIKey   = "api_key"
+)
+
+// Field is an input of a trap form.
+type Field struct {
+	Name  string // Input name
+	Type  string // Input type: text, email, password, hidden or textarea
+	Label string // Placeholder text (empty for hidden inputs)
+	Token string // Kind of honeytoken the field can be prefilled with (empty for none)
+}
+
+// Form describes a trap form.
+type Form struct {
+	Kind   FormKind
+	Method string // "get" or "post"
+	Button string // Submit button text
+	Fields []Field
+}
+
+// Forms holds the layout of each form kind.
+var Forms = map[FormKind]Form{
+	FormLogin: {Kind: FormLogin, Method: "post", Button: "Log in", Fields: []Field{
+		{Name: "email", Type: "email", Label: "Email", Token: TokenEmail},
+		{Name: "password", Type: "password", Label: "Password", Token: TokenPassword},
+	}},
+	FormSearch: {Kind: FormSearch, Method: "get", Button: "Search", Fields: []Field{
+		{Name: "q", Type: "text", Label: "Search"},
+	}},
+	FormContact: {Kind: FormContact, Method: "post", Button: "Send", Fields: []Field{
+		{Name: "name", Type: "text", Label: "Name"},
+		{Name: "email", Type: "email", Label: "Email", Token: TokenEmail},
+		{Name: "message", Type: "textarea", Label: "Message"},
+		{Name: "api_key", Type: "hidden", Token: TokenAPIKey},
+	}},
+	FormComment: {Kind: FormComment, Method: "post", Button: "Post comment", Fields: []Field{
+		{Name: "author", Type: "text", Label: "Name"},
+		{Name: "email", Type: "email", Label: "Email", Token: TokenEmail},
+		{Name: "comment", Type: "textarea", Label: "Comment"},
+	}},
+}
+
+// ParseFormKinds parses a comma-separated list of form kinds.
+//
+// Parameters:
+//   - list: form kind names, e.g. "login,search"
+//
+// Returns the form kinds, or an error naming an unsupported kind.
+func ParseFormKinds(list string) ([]FormKind, error) {
+	var kinds []FormKind
+	for _, name := range strings.Split(list, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if _, ok := Forms[FormKind(name)]; !ok {
+			return nil, fmt.Errorf("unknown form %q (supported: login, search, contact, comment)", name)
+		}
+		kinds = append(kinds, FormKind(name))
+	}
+	if len(kinds) == 0 {
+		return nil, fmt.Errorf("no forms listed")
+	}
+	return kinds, nil
+}
+
+// Prefill returns the values to prefill a form's fields with, by field
+// name, or nil to leave them empty.
+type Prefill func(form Form) map[string]string
@@ -21,6 +21,7 @@ type Generator struct {
 	webpages     []string       // Wordlist entries to use for link generation
 	htmlTemplate string         // HTML template file content (optional)
 	endpoint     string         // Form submission endpoint (optional)
+	forms        []FormKind     // Kinds of form to pick from when an endpoint is set
 	random       *random.Source // Random number generator
 }
 
@@ -38,10 +39,20 @@ func NewGenerator(webpages []string, htmlTemplate, endpoint string, random *rand
 		webpages:     webpages,
 		htmlTemplate: htmlTemplate,
 		endpoint:     endpoint,
+		forms:        FormKinds,
 		random:       random,
 	}
 }
 
+// SetForms sets the kinds of form that generated pages pick from. It must
+// be called before serving. By default every kind is used.
+//
+// Parameters:
+//   - kinds: the form kinds (must not be empty)
+func (g *Generator) SetForms(kinds []FormKind) {
+	g.forms = kinds
+}
+
 // GeneratePage generates an HTML page with random links.
 //
 // If an HTML template is configured, it replaces all href attributes in <a> tags
@@ -51,10 +62,21 @@ func NewGenerator(webpages []string, htmlTemplate, endpoint string, random *rand
 //
 // Returns the generated HTML as a string.
 func (g *Generator) GeneratePage() string {
+	return g.GeneratePageWith(nil)
+}
+
+// GeneratePageWith generates an HTML page like GeneratePage, prefilling
+// the form's fields with values from prefill.
+//
+// Parameters:
+//   - prefill: returns the values for the chosen form (nil leaves fields empty)
+//
+// Returns the generated HTML as a string.
+func (g *Generator) GeneratePageWith(prefill Prefill) string {
 	if g.htmlTemplate != "" {
 		return g.ReplaceLinksInHTML(g.htmlTemplate)
 	}
-	return g.GenerateNewPage()
+	return g.newPage(prefill)
 }
 
 // GenerateNewPage creates a new HTML page from scratch with random links.
@@ -66,6 +88,12 @@ func (g *Generator) GeneratePage() string {
 //
 // Returns the complete HTML page as a string.
 func (g *Generator) GenerateNewPage() string {
+	return g.newPage(nil)
+}
+
+// newPage creates a new HTML page, prefilling the form with values from
+// prefill if it is not nil.
+func (g *Generator) newPage(prefill Prefill) string {
 	var sb strings.Builder
 	sb.WriteString("<html>\n<body>\n")
 
@@ -76,9 +104,14 @@ func (g *Generator) GenerateNewPage() string {
 		WriteLink(&sb, link)
 	}
 
-	// Add form if endpoint is configured
-	if g.endpoint != "" {
-		WriteForm(&sb, g.endpoint)
+	// Add a randomly chosen form if endpoint is configured
+	if g.endpoint != "" && len(g.forms) > 0 {
+		form := Forms[g.forms[g.random.Intn(len(g.forms))]]
+		var values map[string]string
+		if prefill != nil {
+			values = prefill(form)
+		}
+		WriteForm(&sb, g.endpoint, form, values)
 	}
 
 	sb.WriteString("</body>\n</html>")
@@ -216,25 +216,34 @@ func TestWriteLink(t *testing.T) {
 
 func TestWriteForm(t *testing.T) {
 	tests := []struct {
-		name     string
-		endpoint string
+		name       string
+		endpoint   string
+		form       FormKind
+		values     map[string]string
+		wantMethod string
+		want       []string
 	}{
-		{"simple endpoint", "/submit"},
-		{"endpoint with query", "/submit?id=1"},
-		{"endpoint with special chars", "/submit?test=<>&"},
+		{"simple endpoint", "/submit", FormSearch, nil, "get", []string{`name="q"`}},
+		{"endpoint with query", "/submit?id=1", FormSearch, nil, "get", []string{`action="/submit?id=1"`}},
+		{"endpoint with special chars", "/submit?test=<>&", FormSearch, nil, "get", []string{`action="/submit?test=&lt;&gt;&amp;"`}},
+		{"login", "/submit", FormLogin, nil, "post", []string{`type="email" name="email"`, `type="password" name="password"`}},
+		{"prefilled", "/submit", FormLogin, map[string]string{"email": "a.b@example.com", "password": `p"w`}, "post",
+			[]string{`value="a.b@example.com"`, `value="p&#34;w"`}},
+		{"textarea and hidden field", "/submit", FormContact, map[string]string{"api_key": "sk_live_x"}, "post",
+			[]string{`<textarea name="message"`, `type="hidden" name="api_key" value="sk_live_x"`}},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			var sb strings.Builder
-			WriteForm(&sb, tt.endpoint)
+			WriteForm(&sb, tt.endpoint, Forms[tt.form], tt.values)
 			result := sb.String()
 
 			if !strings.Contains(result, `action="`) {
 				t.Error("Form missing action attribute")
 			}
-			if !strings.Contains(result, `method="get"`) {
-				t.Error("Form missing GET method")
+			if !strings.Contains(result, `method="`+tt.wantMethod+`"`) {
+				t.Errorf("Form missing %s method", tt.wantMethod)
 			}
 			if !strings.Contains(result, "<form") {
 				t.Error("Form missing form tag")
@@ -248,6 +257,11 @@ func TestWriteForm(t *testing.T) {
 			if !strings.Contains(result, `<button`) {
 				t.Error("Form missing submit button")
 			}
+			for _, want := range tt.want {
+				if !strings.Contains(result, want) {
+					t.Errorf("Form missing %q:\n%s", want, result)
+				}
+			}
 			// Should escape special characters
 			if tt.endpoint == "/submit?test=<>&" && strings.Contains(result, "<>&") {
 				t.Error("WriteForm should escape special characters")
@@ -256,6 +270,53 @@ func TestWriteForm(t *testing.T) {
 	}
 }
 
+func TestGeneratePageWith(t *testing.T) {
+	gen := NewGenerator([]string{"page1"}, "", "/submit", random.NewSource("abc", 42))
+	gen.SetForms([]FormKind{FormLogin})
+
+	var prefilled FormKind
+	page := gen.GeneratePageWith(func(form Form) map[string]string {
+		prefilled = form.Kind
+		return map[string]string{"email": "token@example.com"}
+	})
+	if prefilled != FormLogin {
+		t.Errorf("prefill called for %q, want %q", prefilled, FormLogin)
+	}
+	if !strings.Contains(page, `class="login-form"`) || !strings.Contains(page, `value="token@example.com"`) {
+		t.Errorf("page missing prefilled login form:\n%s", page)
+	}
+}
+
+func TestParseFormKinds(t *testing.T) {
+	tests := []struct {
+		list    string
+		want    []FormKind
+		wantErr bool
+	}{
+		{"login", []FormKind{FormLogin}, false},
+		{"login, search,comment", []FormKind{FormLogin, FormSearch, FormComment}, false},
+		{"contact,", []FormKind{FormContact}, false},
+		{"signup", nil, true},
+		{"", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.list, func(t *testing.T) {
+			got, err := ParseFormKinds(tt.list)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseFormKinds() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("ParseFormKinds() = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("ParseFormKinds() = %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
 func TestMultipleGenerations(t *testing.T) {
 	// Test that crypto/rand produces valid but different pages each time
 	// (Not deterministic like math/rand with seed)
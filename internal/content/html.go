@@ -24,19 +24,51 @@ func WriteLink(sb *strings.Builder, address string) {
 
 // WriteForm writes an HTML form element to the string builder.
 //
-// The form uses the provided endpoint as its action URL and submits via GET method.
-// The endpoint is HTML-escaped to prevent injection attacks.
-// The form includes a text input field and a submit button.
+// The form uses the provided endpoint as its action URL and the form's
+// method. The endpoint and values are HTML-escaped to prevent injection
+// attacks. Each field is rendered as an input, or a textarea, prefilled
+// with its entry in values, followed by a submit button.
 //
 // Parameters:
 //   - sb: the string builder to write to
 //   - endpoint: the form action URL (will be HTML-escaped)
-func WriteForm(sb *strings.Builder, endpoint string) {
-	escapedEndpoint := html.EscapeString(endpoint)
+//   - form: the form layout
+//   - values: values to prefill fields with, by field name (can be nil)
+func WriteForm(sb *strings.Builder, endpoint string, form Form, values map[string]string) {
 	sb.WriteString(`<form action="`)
-	sb.WriteString(escapedEndpoint)
-	sb.WriteString(`" method="get">
-		<input type="text" name="param">
-		<button type="submit">Submit</button>
-		</form>`)
+	sb.WriteString(html.EscapeString(endpoint))
+	sb.WriteString(`" method="`)
+	sb.WriteString(form.Method)
+	sb.WriteString(`" class="`)
+	sb.WriteString(string(form.Kind))
+	sb.WriteString("-form\">\n")
+	for _, field := range form.Fields {
+		value := html.EscapeString(values[field.Name])
+		if field.Type == "textarea" {
+			sb.WriteString(`		<textarea name="`)
+			sb.WriteString(field.Name)
+			sb.WriteString(`" placeholder="`)
+			sb.WriteString(field.Label)
+			sb.WriteString(`">`)
+			sb.WriteString(value)
+			sb.WriteString("</textarea>\n")
+			continue
+		}
+		sb.WriteString(`		<input type="`)
+		sb.WriteString(field.Type)
+		sb.WriteString(`" name="`)
+		sb.WriteString(field.Name)
+		if field.Label != "" {
+			sb.WriteString(`" placeholder="`)
+			sb.WriteString(field.Label)
+		}
+		if value != "" {
+			sb.WriteString(`" value="`)
+			sb.WriteString(value)
+		}
+		sb.WriteString("\">\n")
+	}
+	sb.WriteString(`		<button type="submit">`)
+	sb.WriteString(form.Button)
+	sb.WriteString("</button>\n		</form>")
 }
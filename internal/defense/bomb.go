@@ -0,0 +1,104 @@
+package defense
+
+import (
+	"compress/gzip"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// bombPrefix opens the page a decompression bomb inflates to; the rest of
+// the page is whitespace, which compresses about a thousandfold.
+const bombPrefix = "<!DOCTYPE html>\n<html><head><title>Loading</title></head><body>\n"
+
+// BombPath returns the file the decompression bomb of the given size is
+// generated into.
+//
+// Parameters:
+//   - dataDir: the data directory
+//   - size: the decompressed size in bytes
+func BombPath(dataDir string, size int64) string {
+	return filepath.Join(dataDir, fmt.Sprintf("bomb-%d.html.gz", size))
+}
+
+// loadBomb returns the gzip-compressed decompression bomb at path,
+// generating it first if the file is missing or was generated for a
+// different size.
+//
+// Parameters:
+//   - path: the bomb file
+//   - size: the decompressed size in bytes
+//
+// Returns the compressed bomb, whether it was generated, or an error if it
+// could not be read or written.
+func loadBomb(path string, size int64) ([]byte, bool, error) {
+	data, err := os.ReadFile(path)
+	if err == nil && validBomb(data, size) {
+		return data, false, nil
+	}
+	if err != nil && !os.IsNotExist(err) {
+		return nil, false, fmt.Errorf("failed to read decompression bomb: %w", err)
+	}
+
+	if err := generateBomb(path, size); err != nil {
+		return nil, false, err
+	}
+	data, err = os.ReadFile(path)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to read decompression bomb: %w", err)
+	}
+	return data, true, nil
+}
+
+// validBomb reports whether data looks like a gzip stream that inflates to
+// size bytes, going by its header and the size recorded in its trailer.
+func validBomb(data []byte, size int64) bool {
+	if len(data) < 18 || data[0] != 0x1f || data[1] != 0x8b {
+		return false
+	}
+	return binary.LittleEndian.Uint32(data[len(data)-4:]) == uint32(size)
+}
+
+// generateBomb writes a gzip stream that inflates to size bytes of HTML to
+// path. The file is written under a temporary name and renamed into place,
+// so an interrupted run never leaves a truncated bomb behind.
+func generateBomb(path string, size int64) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".bomb-*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create decompression bomb: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	// Runs of one byte compress to the same size at every level, but the
+	// fastest level generates a 1 GiB bomb in well under a second where the
+	// best takes several
+	zw, err := gzip.NewWriterLevel(tmp, gzip.BestSpeed)
+	if err != nil {
+		return fmt.Errorf("failed to create decompression bomb: %w", err)
+	}
+	prefix := bombPrefix[:min(int64(len(bombPrefix)), size)]
+	if _, err := zw.Write([]byte(prefix)); err != nil {
+		return fmt.Errorf("failed to write decompression bomb: %w", err)
+	}
+	filler := []byte(strings.Repeat(" ", 64<<10))
+	for remaining := size - int64(len(prefix)); remaining > 0; {
+		n := min(remaining, int64(len(filler)))
+		if _, err := zw.Write(filler[:n]); err != nil {
+			return fmt.Errorf("failed to write decompression bomb: %w", err)
+		}
+		remaining -= n
+	}
+	if err := zw.Close(); err != nil {
+		return fmt.Errorf("failed to write decompression bomb: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write decompression bomb: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to save decompression bomb: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,346 @@
+// Package defense answers clients banned for abuse with responses that cost
+// them far more than they cost the trap: a pre-generated gzip decompression
+// bomb, or an endless stream of HTML.
+//
+// Which banned clients get which response is chosen per ban rule; clients
+// banned by any other rule are still rejected outright. Every response is
+// bounded by hard caps on concurrency, duration, size and the bandwidth all
+// defense responses share, so a flood of abusive clients cannot turn the
+// defense against the trap itself.
+package defense
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/time/rate"
+
+	"github.com/rampantspark/gospidertrap/internal/ban"
+	"github.com/rampantspark/gospidertrap/internal/metrics"
+)
+
+// Actions a ban rule can be given.
+const (
+	ActionBlock  = "block"  // Reject with 403 Forbidden, as for any other ban
+	ActionBomb   = "bomb"   // Serve the gzip decompression bomb
+	ActionStream = "stream" // Stream HTML until a cap is reached
+)
+
+// Actions lists every action a ban rule can be given.
+var Actions = []string{ActionBlock, ActionBomb, ActionStream}
+
+const (
+	// DefaultBombSize is the default decompressed size of the bomb (1 GiB,
+	// about 1 MiB compressed).
+	DefaultBombSize = 1 << 30
+
+	// MaxBombSize limits the decompressed size of the bomb, which bounds
+	// the time spent generating it and the memory holding it compressed.
+	MaxBombSize = 16 << 30
+
+	// DefaultStreamSize is the default number of bytes a stream sends.
+	DefaultStreamSize = 64 << 20
+
+	// DefaultMaxDuration is the default time a defense response is held open.
+	DefaultMaxDuration = 5 * time.Minute
+
+	// DefaultBandwidth is the default number of bytes per second shared by
+	// all defense responses.
+	DefaultBandwidth = 1 << 20
+
+	// DefaultMaxActive is the default number of defense responses served at
+	// once.
+	DefaultMaxActive = 16
+
+	// chunkSize is the most bytes written between bandwidth checks.
+	chunkSize = 32 << 10
+)
+
+// Config holds the defense settings.
+type Config struct {
+	Actions     map[string]string // Action for bans issued by each rule; rules not listed are blocked
+	BombSize    int64             // Decompressed size of the bomb in bytes
+	StreamSize  int64             // Most bytes a stream sends
+	MaxDuration time.Duration     // Longest a defense response is held open
+	Bandwidth   int               // Bytes per second shared by all defense responses
+	MaxActive   int               // Most defense responses served at once; further clients are blocked
+}
+
+// ParseAction parses a RULE=ACTION pair naming the action for bans issued
+// by a rule.
+//
+// Parameters:
+//   - spec: the pair, such as "known-scraper=bomb"
+//
+// Returns the rule and action, or an error if the pair is malformed or the
+// action is unknown.
+func ParseAction(spec string) (string, string, error) {
+	rule, action, ok := strings.Cut(spec, "=")
+	rule, action = strings.TrimSpace(rule), strings.ToLower(strings.TrimSpace(action))
+	if !ok || rule == "" {
+		return "", "", fmt.Errorf("invalid defense %q, want RULE=ACTION", spec)
+	}
+	for _, a := range Actions {
+		if action == a {
+			return rule, action, nil
+		}
+	}
+	return "", "", fmt.Errorf("unknown defense action %q (supported: %s)", action, strings.Join(Actions, ", "))
+}
+
+// Defender serves defense responses to banned clients.
+//
+// Defender is safe for concurrent use.
+type Defender struct {
+	config    Config
+	bans      *ban.List
+	networkOf func(ip string) string // Maps an IP to its network's ban key (nil if networks are not banned)
+	bomb      []byte                 // Gzip-compressed bomb (nil if no rule serves it)
+	chunk     []byte                 // Block of HTML a stream repeats
+	limiter   *rate.Limiter          // Bandwidth shared by all defense responses
+	slots     chan struct{}          // Holds a token per defense response being served
+	logger    *slog.Logger
+
+	served *metrics.CounterVec // Defense responses served, by action (nil if not instrumented)
+	active *metrics.Gauge      // Defense responses being served (nil if not instrumented)
+}
+
+// NewDefender creates a defender, generating the decompression bomb into the
+// data directory if a rule serves it and it has not been generated before.
+//
+// Parameters:
+//   - config: the defense settings
+//   - dataDir: the directory the bomb is generated into
+//   - bans: the ban list banned clients are looked up in
+//   - networkOf: returns the network key of an IP, such as its autonomous system (can be nil)
+//   - logger: structured logger instance
+//
+// Returns the defender, or an error if the settings are invalid or the bomb
+// cannot be generated.
+func NewDefender(config Config, dataDir string, bans *ban.List, networkOf func(ip string) string, logger *slog.Logger) (*Defender, error) {
+	switch {
+	case config.BombSize <= 0 || config.BombSize > MaxBombSize:
+		return nil, fmt.Errorf("bomb size must be between 1 byte and %d MiB", MaxBombSize>>20)
+	case config.StreamSize <= 0:
+		return nil, fmt.Errorf("stream size must be positive")
+	case config.MaxDuration <= 0:
+		return nil, fmt.Errorf("maximum duration must be positive")
+	case config.Bandwidth <= 0:
+		return nil, fmt.Errorf("bandwidth must be positive")
+	case config.MaxActive <= 0:
+		return nil, fmt.Errorf("maximum active responses must be positive")
+	}
+
+	d := &Defender{
+		config:    config,
+		bans:      bans,
+		networkOf: networkOf,
+		chunk:     streamChunk(),
+		limiter:   rate.NewLimiter(rate.Limit(config.Bandwidth), chunkSize),
+		slots:     make(chan struct{}, config.MaxActive),
+		logger:    logger,
+	}
+	for _, action := range config.Actions {
+		if action != ActionBomb || d.bomb != nil {
+			continue
+		}
+		path := BombPath(dataDir, config.BombSize)
+		start := time.Now()
+		bomb, generated, err := loadBomb(path, config.BombSize)
+		if err != nil {
+			return nil, err
+		}
+		if generated {
+			logger.Info("Generated decompression bomb",
+				"path", path,
+				"size", config.BombSize,
+				"compressed", len(bomb),
+				"duration", time.Since(start).Round(time.Millisecond).String())
+		}
+		d.bomb = bomb
+	}
+	return d, nil
+}
+
+// Instrument sets the metrics updated by the defender.
+//
+// Parameters:
+//   - served: counter of defense responses, by action
+//   - active: gauge of defense responses being served
+func (d *Defender) Instrument(served *metrics.CounterVec, active *metrics.Gauge) {
+	d.served = served
+	d.active = active
+}
+
+// Action returns the action for a ban.
+//
+// Parameters:
+//   - b: the ban
+//
+// Returns the action configured for the ban's rule, or ActionBlock.
+func (d *Defender) Action(b ban.Ban) string {
+	if action, ok := d.config.Actions[b.Rule]; ok {
+		return action
+	}
+	return ActionBlock
+}
+
+// Diverts reports whether a banned client is answered by the defender
+// rather than rejected.
+//
+// Parameters:
+//   - b: the client's ban
+func (d *Defender) Diverts(b ban.Ban) bool {
+	return d.Action(b) != ActionBlock
+}
+
+// Serve answers a banned client with the defense response for its ban.
+//
+// Clients that do not accept gzip are streamed HTML instead of the bomb,
+// and clients arriving while the maximum number of responses is being
+// served are rejected with 403 Forbidden.
+//
+// Parameters:
+//   - w: the HTTP response writer
+//   - r: the HTTP request
+//   - ip: the client IP address
+//
+// Returns false without writing a response if the client is not banned or
+// its ban is simply blocked.
+func (d *Defender) Serve(w http.ResponseWriter, r *http.Request, ip string) bool {
+	b, banned := d.lookup(ip)
+	if !banned {
+		return false
+	}
+	action := d.Action(b)
+	if action == ActionBlock {
+		return false
+	}
+	if action == ActionBomb && !acceptsGzip(r) {
+		action = ActionStream
+	}
+
+	select {
+	case d.slots <- struct{}{}:
+		defer func() { <-d.slots }()
+	default:
+		d.served.WithLabelValues(ActionBlock).Inc()
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return true
+	}
+	d.served.WithLabelValues(action).Inc()
+	d.active.Inc()
+	defer d.active.Dec()
+
+	ctx, cancel := context.WithTimeout(r.Context(), d.config.MaxDuration)
+	defer cancel()
+	rc := http.NewResponseController(w)
+	rc.SetWriteDeadline(time.Now().Add(d.config.MaxDuration))
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	if action == ActionBomb {
+		w.Header().Set("Content-Encoding", "gzip")
+		w.Header().Set("Content-Length", fmt.Sprint(len(d.bomb)))
+	}
+	w.WriteHeader(http.StatusOK)
+	if r.Method == http.MethodHead {
+		return true
+	}
+
+	start := time.Now()
+	var sent int64
+	var err error
+	if action == ActionBomb {
+		sent, err = d.send(ctx, w, rc, d.bomb)
+	} else {
+		sent, err = d.stream(ctx, w, rc)
+	}
+	d.logger.Debug("Served defense response",
+		"ip", ip,
+		"rule", b.Rule,
+		"action", action,
+		"bytes", sent,
+		"duration", time.Since(start).Round(time.Millisecond).String(),
+		"error", err)
+	return true
+}
+
+// lookup returns the ban for ip, or for its network if the network is
+// banned and ip is not allowlisted.
+func (d *Defender) lookup(ip string) (ban.Ban, bool) {
+	if b, banned := d.bans.Get(ip); banned {
+		return b, true
+	}
+	if d.networkOf != nil && !d.bans.IsAllowed(ip) {
+		if network := d.networkOf(ip); network != ip {
+			return d.bans.Get(network)
+		}
+	}
+	return ban.Ban{}, false
+}
+
+// stream writes an HTML page that never ends until the stream size is sent,
+// the maximum duration passes or the client disconnects.
+func (d *Defender) stream(ctx context.Context, w http.ResponseWriter, rc *http.ResponseController) (int64, error) {
+	sent, err := d.send(ctx, w, rc, []byte(bombPrefix))
+	for err == nil && sent < d.config.StreamSize {
+		var n int64
+		n, err = d.send(ctx, w, rc, d.chunk[:min(int64(len(d.chunk)), d.config.StreamSize-sent)])
+		sent += n
+	}
+	return sent, err
+}
+
+// send writes p in chunks, waiting for the shared bandwidth before each one
+// and flushing it to the client.
+func (d *Defender) send(ctx context.Context, w http.ResponseWriter, rc *http.ResponseController, p []byte) (int64, error) {
+	var sent int64
+	for len(p) > 0 {
+		n := min(len(p), chunkSize)
+		if err := d.limiter.WaitN(ctx, n); err != nil {
+			return sent, err
+		}
+		if _, err := w.Write(p[:n]); err != nil {
+			return sent, err
+		}
+		if err := rc.Flush(); err != nil {
+			return sent, err
+		}
+		sent += int64(n)
+		p = p[n:]
+	}
+	return sent, nil
+}
+
+// acceptsGzip reports whether the request's Accept-Encoding lists gzip.
+func acceptsGzip(r *http.Request) bool {
+	for _, part := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		coding, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if !strings.EqualFold(strings.TrimSpace(coding), "gzip") {
+			continue
+		}
+		name, value, _ := strings.Cut(strings.TrimSpace(params), "=")
+		if strings.EqualFold(strings.TrimSpace(name), "q") {
+			q, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+			return err == nil && q > 0
+		}
+		return true
+	}
+	return false
+}
+
+// streamChunk builds the block of HTML a stream repeats: endlessly nested
+// results, each linking to a further page.
+func streamChunk() []byte {
+	var b strings.Builder
+	for i := 1; b.Len() < 4<<10; i++ {
+		fmt.Fprintf(&b, "<div class=\"result\"><p>Loading more results&hellip;</p><a href=\"?page=%d\">Page %d</a>\n", i, i)
+	}
+	return []byte(b.String())
+}
@@ -0,0 +1,188 @@
+package defense
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rampantspark/gospidertrap/internal/ban"
+)
+
+func newTestDefender(t *testing.T, actions map[string]string, streamSize int64, maxActive int) (*Defender, *ban.List) {
+	t.Helper()
+	list := ban.NewList()
+	t.Cleanup(list.Stop)
+	d, err := NewDefender(Config{
+		Actions:     actions,
+		BombSize:    1 << 20,
+		StreamSize:  streamSize,
+		MaxDuration: 10 * time.Second,
+		Bandwidth:   64 << 20,
+		MaxActive:   maxActive,
+	}, t.TempDir(), list, nil, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err != nil {
+		t.Fatalf("NewDefender() error = %v", err)
+	}
+	return d, list
+}
+
+func TestParseAction(t *testing.T) {
+	tests := []struct {
+		spec       string
+		wantRule   string
+		wantAction string
+		wantErr    bool
+	}{
+		{"known-scraper=bomb", "known-scraper", ActionBomb, false},
+		{" trap-hits = Stream ", "trap-hits", ActionStream, false},
+		{"manual=block", "manual", ActionBlock, false},
+		{"known-scraper", "", "", true},
+		{"=bomb", "", "", true},
+		{"trap-hits=tarpit", "", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			rule, action, err := ParseAction(tt.spec)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseAction() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if rule != tt.wantRule || action != tt.wantAction {
+				t.Errorf("ParseAction() = %q, %q, want %q, %q", rule, action, tt.wantRule, tt.wantAction)
+			}
+		})
+	}
+}
+
+func TestLoadBomb(t *testing.T) {
+	path := BombPath(t.TempDir(), 1<<20)
+	bomb, generated, err := loadBomb(path, 1<<20)
+	if err != nil || !generated {
+		t.Fatalf("loadBomb() = generated %v, error %v", generated, err)
+	}
+	if len(bomb) > 4<<10 {
+		t.Errorf("bomb compressed to %d bytes, want under 4 KiB", len(bomb))
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(bomb))
+	if err != nil {
+		t.Fatal(err)
+	}
+	page, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page) != 1<<20 || !strings.HasPrefix(string(page), bombPrefix) {
+		t.Errorf("bomb inflates to %d bytes starting %q", len(page), page[:min(len(page), 32)])
+	}
+
+	// The existing bomb is reused, and regenerated once it no longer matches
+	if _, generated, _ := loadBomb(path, 1<<20); generated {
+		t.Error("loadBomb() regenerated an existing bomb")
+	}
+	if err := os.WriteFile(path, []byte("truncated"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, generated, _ := loadBomb(path, 1<<20); !generated {
+		t.Error("loadBomb() reused an invalid bomb")
+	}
+}
+
+func TestServe(t *testing.T) {
+	d, list := newTestDefender(t, map[string]string{
+		"known-scraper": ActionBomb,
+		"trap-hits":     ActionStream,
+	}, 100<<10, 2)
+	list.Add("192.0.2.1", "known-scraper", "scraper", time.Hour)
+	list.Add("192.0.2.2", "trap-hits", "too many hits", time.Hour)
+	list.Add("192.0.2.3", "rate-limited", "too many 429s", time.Hour)
+
+	tests := []struct {
+		name         string
+		ip           string
+		encoding     string
+		wantServed   bool
+		wantEncoding string
+		wantSize     int // Decompressed body size
+	}{
+		{"bomb", "192.0.2.1", "gzip, deflate, br", true, "gzip", 1 << 20},
+		{"bomb without gzip streams", "192.0.2.1", "br, gzip;q=0", true, "", 100 << 10},
+		{"stream", "192.0.2.2", "gzip", true, "", 100 << 10},
+		{"blocked rule", "192.0.2.3", "gzip", false, "", 0},
+		{"not banned", "192.0.2.4", "gzip", false, "", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.Header.Set("Accept-Encoding", tt.encoding)
+			w := httptest.NewRecorder()
+			if served := d.Serve(w, r, tt.ip); served != tt.wantServed {
+				t.Fatalf("Serve() = %v, want %v", served, tt.wantServed)
+			}
+			if !tt.wantServed {
+				if w.Body.Len() != 0 {
+					t.Errorf("Serve() wrote %d bytes for a client it did not serve", w.Body.Len())
+				}
+				return
+			}
+
+			if got := w.Header().Get("Content-Encoding"); got != tt.wantEncoding {
+				t.Errorf("Content-Encoding = %q, want %q", got, tt.wantEncoding)
+			}
+			body := w.Body.Bytes()
+			if tt.wantEncoding == "gzip" {
+				zr, err := gzip.NewReader(bytes.NewReader(body))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if body, err = io.ReadAll(zr); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if len(body) != tt.wantSize {
+				t.Errorf("body is %d bytes, want %d", len(body), tt.wantSize)
+			}
+			if !strings.HasPrefix(string(body), "<!DOCTYPE html>") {
+				t.Errorf("body starts %q, want an HTML page", body[:min(len(body), 32)])
+			}
+		})
+	}
+}
+
+func TestServeMaxActive(t *testing.T) {
+	d, list := newTestDefender(t, map[string]string{"manual": ActionStream}, 1<<20, 1)
+	list.Add("192.0.2.1", ban.RuleManual, "abusive", time.Hour)
+
+	// Hold the only slot, so the next client is blocked
+	d.slots <- struct{}{}
+	w := httptest.NewRecorder()
+	if !d.Serve(w, httptest.NewRequest(http.MethodGet, "/", nil), "192.0.2.1") {
+		t.Fatal("Serve() did not answer a diverted client")
+	}
+	if w.Code != http.StatusForbidden {
+		t.Errorf("status = %d, want %d once the maximum is reached", w.Code, http.StatusForbidden)
+	}
+	<-d.slots
+}
+
+func TestDiverts(t *testing.T) {
+	d, _ := newTestDefender(t, map[string]string{"known-scraper": ActionBomb, "manual": ActionBlock}, 1<<20, 1)
+	tests := []struct {
+		rule string
+		want bool
+	}{
+		{"known-scraper", true},
+		{ban.RuleManual, false},
+		{"trap-hits", false},
+	}
+	for _, tt := range tests {
+		if got := d.Diverts(ban.Ban{Rule: tt.rule}); got != tt.want {
+			t.Errorf("Diverts(%q) = %v, want %v", tt.rule, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,338 @@
+package export
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/netip"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/rampantspark/gospidertrap/internal/ban"
+	"github.com/rampantspark/gospidertrap/internal/geoip"
+	"github.com/rampantspark/gospidertrap/internal/stats"
+)
+
+// Filter selects which trapped IPs are exported.
+//
+// Banned IPs are always exported regardless of the filter.
+type Filter struct {
+	MinHits int           // Minimum number of trap requests (0 or 1 exports every trapped IP)
+	MaxAge  time.Duration // Maximum time since the IP was last seen (0 for no limit)
+}
+
+// Exporter builds firewall exports from the ban list and stats and keeps
+// export files in a directory up to date.
+type Exporter struct {
+	bans     *ban.List
+	stats    *stats.Manager
+	dir      string
+	filter   Filter
+	interval time.Duration
+	logger   *slog.Logger
+	stopChan chan struct{}
+	done     chan struct{}
+
+	prefixes func(asn uint) ([]netip.Prefix, error) // Nil unless SetNetworkPrefixes was called
+	skipped  sync.Map                               // Ban keys already logged as not exported
+}
+
+// NewExporter creates a new exporter.
+//
+// Parameters:
+//   - bans: the ban list (banned IPs are always exported)
+//   - statsManager: stats manager for looking up trapped IPs
+//   - dir: directory to write export files to (empty to disable file output)
+//   - filter: default filter for trapped IPs
+//   - interval: how often export files are rewritten
+//   - logger: structured logger instance
+//
+// Returns a new Exporter instance.
+func NewExporter(bans *ban.List, statsManager *stats.Manager, dir string, filter Filter, interval time.Duration, logger *slog.Logger) *Exporter {
+	return &Exporter{
+		bans:     bans,
+		stats:    statsManager,
+		dir:      dir,
+		filter:   filter,
+		interval: interval,
+		logger:   logger,
+		stopChan: make(chan struct{}),
+	}
+}
+
+// SetNetworkPrefixes makes the exporter expand bans on an autonomous
+// system, such as "AS64496", into the networks announced by it. Without
+// it, network bans are left out of exports.
+//
+// SetNetworkPrefixes must be called before Start.
+//
+// Parameters:
+//   - prefixes: returns the networks of an autonomous system
+func (e *Exporter) SetNetworkPrefixes(prefixes func(asn uint) ([]netip.Prefix, error)) {
+	e.prefixes = prefixes
+}
+
+// Filter returns the default filter applied to trapped IPs.
+func (e *Exporter) Filter() Filter {
+	return e.filter
+}
+
+// Entries collects the addresses to export.
+//
+// Active bans are always included, with bans on an autonomous system
+// expanded into its networks. Bans that cannot be expanded are skipped and
+// logged once. Trapped IPs are included when they match the filter and are
+// not allowlisted.
+//
+// Parameters:
+//   - ctx: context for cancellation and timeout control
+//   - filter: filter for trapped IPs
+//
+// Returns entries ordered with banned addresses first, then by last seen time.
+func (e *Exporter) Entries(ctx context.Context, filter Filter) []Entry {
+	byPrefix := make(map[netip.Prefix]*Entry)
+
+	for _, b := range e.bans.Active() {
+		prefixes, err := e.banPrefixes(b.IP)
+		if err != nil {
+			if _, logged := e.skipped.LoadOrStore(b.IP, true); !logged {
+				e.logger.Warn("Ban left out of firewall exports", "key", b.IP, "error", err)
+			}
+			continue
+		}
+		for _, p := range prefixes {
+			byPrefix[p] = &Entry{
+				Prefix:   p,
+				LastSeen: b.CreatedAt,
+				Banned:   true,
+				Reason:   b.Rule + ": " + b.Reason,
+			}
+		}
+	}
+
+	var since time.Time
+	if filter.MaxAge > 0 {
+		since = time.Now().Add(-filter.MaxAge)
+	}
+	minHits := filter.MinHits
+	if minHits < 1 {
+		minHits = 1
+	}
+
+	for _, activity := range e.stats.GetIPActivity(ctx, minHits, since) {
+		if e.bans.IsAllowed(activity.IP) {
+			continue
+		}
+		addr, err := netip.ParseAddr(activity.IP)
+		if err != nil {
+			continue
+		}
+		p := prefix(addr.Unmap())
+		if existing, ok := byPrefix[p]; ok {
+			existing.Hits = activity.Count
+			if activity.LastSeen.After(existing.LastSeen) {
+				existing.LastSeen = activity.LastSeen
+			}
+			continue
+		}
+		byPrefix[p] = &Entry{
+			Prefix:   p,
+			Hits:     activity.Count,
+			LastSeen: activity.LastSeen,
+			Reason:   "trapped",
+		}
+	}
+
+	entries := make([]Entry, 0, len(byPrefix))
+	for _, entry := range byPrefix {
+		entries = append(entries, *entry)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Banned != entries[j].Banned {
+			return entries[i].Banned
+		}
+		if !entries[i].LastSeen.Equal(entries[j].LastSeen) {
+			return entries[i].LastSeen.After(entries[j].LastSeen)
+		}
+		if entries[i].Prefix.Addr() != entries[j].Prefix.Addr() {
+			return entries[i].Prefix.Addr().Less(entries[j].Prefix.Addr())
+		}
+		return entries[i].Prefix.Bits() < entries[j].Prefix.Bits()
+	})
+	return entries
+}
+
+// banPrefixes returns the prefixes a ban key covers: a single-host prefix
+// for an IP address, or the networks of an autonomous system.
+func (e *Exporter) banPrefixes(key string) ([]netip.Prefix, error) {
+	if addr, err := netip.ParseAddr(key); err == nil {
+		return []netip.Prefix{prefix(addr.Unmap())}, nil
+	}
+	asn, ok := geoip.ParseASNKey(key)
+	if !ok {
+		return nil, fmt.Errorf("not an IP address or autonomous system")
+	}
+	if e.prefixes == nil {
+		return nil, fmt.Errorf("network bans need a GeoIP ASN database")
+	}
+	prefixes, err := e.prefixes(asn)
+	if err != nil {
+		return nil, err
+	}
+	if len(prefixes) == 0 {
+		return nil, fmt.Errorf("no networks known for the autonomous system")
+	}
+	return prefixes, nil
+}
+
+// WriteFiles writes every export format to the export directory.
+//
+// Each file is written to a temporary file and renamed into place, so
+// readers never observe a partially written export.
+//
+// Parameters:
+//   - ctx: context for cancellation and timeout control
+//
+// Returns an error if any file cannot be written.
+func (e *Exporter) WriteFiles(ctx context.Context) error {
+	if e.dir == "" {
+		return nil // File output disabled
+	}
+
+	entries := e.Entries(ctx, e.filter)
+	for _, format := range Formats {
+		var buf bytes.Buffer
+		if err := Write(&buf, format, entries); err != nil {
+			return fmt.Errorf("failed to render %s export: %w", format, err)
+		}
+		if err := writeFileAtomic(filepath.Join(e.dir, format.FileName()), buf.Bytes()); err != nil {
+			return fmt.Errorf("failed to write %s export: %w", format, err)
+		}
+	}
+	return nil
+}
+
+// writeFileAtomic writes data to a temporary file in the same directory and
+// renames it over filename.
+func writeFileAtomic(filename string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(filename), "."+filepath.Base(filename)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	tmpName := tmp.Name()
+	defer os.Remove(tmpName) // No-op after a successful rename
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	// #nosec G302 -- export files are meant to be read by firewall tooling
+	if err := os.Chmod(tmpName, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmpName, filename)
+}
+
+// Start starts a goroutine that rewrites the export files every interval.
+//
+// Does nothing if file output is disabled or the interval is not positive.
+func (e *Exporter) Start() {
+	if e.dir == "" || e.interval <= 0 {
+		return
+	}
+
+	e.done = make(chan struct{})
+	go func() {
+		defer close(e.done)
+
+		ticker := time.NewTicker(e.interval)
+		defer ticker.Stop()
+
+		for {
+			if err := e.WriteFiles(context.Background()); err != nil {
+				e.logger.Warn("Failed to write firewall exports", "error", err)
+			}
+			select {
+			case <-ticker.C:
+			case <-e.stopChan:
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops the periodic export goroutine and waits for it to exit.
+//
+// Safe to call multiple times, and before Start.
+func (e *Exporter) Stop() {
+	select {
+	case <-e.stopChan:
+		// Already closed
+	default:
+		close(e.stopChan)
+	}
+	if e.done != nil {
+		<-e.done
+	}
+}
+
+// ServeHTTP serves an export over HTTP.
+//
+// The format is taken from the last path segment (for example
+// ".../export/nftables"). The "min_hits" and "max_age" query parameters
+// override the default filter; max_age is a Go duration such as "24h".
+//
+// Parameters:
+//   - w: the HTTP response writer
+//   - r: the HTTP request
+func (e *Exporter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	format, ok := ParseFormat(path.Base(r.URL.Path))
+	if !ok {
+		http.Error(w, "Unknown export format", http.StatusNotFound)
+		return
+	}
+
+	filter := e.filter
+	query := r.URL.Query()
+	if v := query.Get("min_hits"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			http.Error(w, "Invalid min_hits", http.StatusBadRequest)
+			return
+		}
+		filter.MinHits = n
+	}
+	if v := query.Get("max_age"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 {
+			http.Error(w, "Invalid max_age", http.StatusBadRequest)
+			return
+		}
+		filter.MaxAge = d
+	}
+
+	var buf bytes.Buffer
+	if err := Write(&buf, format, e.Entries(r.Context(), filter)); err != nil {
+		e.logger.Warn("Failed to render firewall export", "format", format, "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", format.ContentType())
+	w.Header().Set("Cache-Control", "no-store")
+	w.Write(buf.Bytes())
+}
@@ -0,0 +1,247 @@
+package export
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rampantspark/gospidertrap/internal/ban"
+	"github.com/rampantspark/gospidertrap/internal/geoip"
+	"github.com/rampantspark/gospidertrap/internal/stats"
+)
+
+func testEntries() []Entry {
+	seen := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	return []Entry{
+		{Prefix: netip.MustParsePrefix("192.0.2.1/32"), Hits: 7, LastSeen: seen, Banned: true, Reason: "trap-hits: 7 trap pages in 1m0s"},
+		{Prefix: netip.MustParsePrefix("2001:db8::1/128"), Hits: 3, LastSeen: seen, Reason: "trapped"},
+		{Prefix: netip.MustParsePrefix("198.51.100.0/24"), LastSeen: seen, Banned: true, Reason: "manual: hosting network"},
+	}
+}
+
+func TestWrite_Formats(t *testing.T) {
+	tests := []struct {
+		format Format
+		want   []string
+	}{
+		{FormatNFTables, []string{
+			"set gospidertrap_v4 {", "type ipv4_addr", "192.0.2.1/32,", "198.51.100.0/24",
+			"set gospidertrap_v6 {", "type ipv6_addr", "2001:db8::1/128",
+		}},
+		{FormatIPSet, []string{
+			"create gospidertrap_v4 hash:net family inet -exist",
+			"add gospidertrap_v4 192.0.2.1/32 -exist",
+			"add gospidertrap_v4 198.51.100.0/24 -exist",
+			"create gospidertrap_v6 hash:net family inet6 -exist",
+			"add gospidertrap_v6 2001:db8::1/128 -exist",
+		}},
+		{FormatCIDR, []string{"192.0.2.1/32\n2001:db8::1/128\n198.51.100.0/24\n"}},
+		{FormatFail2Ban, []string{
+			"2024-01-02T03:04:05Z gospidertrap: banned ip=192.0.2.1 hits=7 ",
+			"2024-01-02T03:04:05Z gospidertrap: trapped ip=2001:db8::1 hits=3 ",
+			"2024-01-02T03:04:05Z gospidertrap: banned ip=198.51.100.0/24 hits=0 ",
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.format), func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := Write(&buf, tt.format, testEntries()); err != nil {
+				t.Fatalf("Write() error = %v", err)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(buf.String(), want) {
+					t.Errorf("output missing %q:\n%s", want, buf.String())
+				}
+			}
+		})
+	}
+}
+
+func TestWrite_NFTablesEmptySet(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Write(&buf, FormatNFTables, nil); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	if strings.Contains(buf.String(), "elements") {
+		t.Errorf("empty nftables set should not have an elements line:\n%s", buf.String())
+	}
+}
+
+func TestWrite_UnknownFormat(t *testing.T) {
+	if err := Write(io.Discard, Format("pf"), testEntries()); err == nil {
+		t.Error("Write() with unknown format should return error")
+	}
+}
+
+func newTestExporter(t *testing.T, dir string) (*Exporter, *ban.List, *stats.Manager) {
+	t.Helper()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	bans := ban.NewList()
+	t.Cleanup(bans.Stop)
+	manager := stats.NewManager(stats.NewMemoryStore(stats.NewStats()), false, logger)
+
+	return NewExporter(bans, manager, dir, Filter{MinHits: 2}, time.Minute, logger), bans, manager
+}
+
+func recordHits(t *testing.T, manager *stats.Manager, ip string, n int) {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		req := httptest.NewRequest("GET", "/page", nil)
+		req.RemoteAddr = ip + ":1234"
+		if err := manager.RecordRequest(context.Background(), req); err != nil {
+			t.Fatalf("RecordRequest() error = %v", err)
+		}
+	}
+}
+
+func TestExporter_Entries(t *testing.T) {
+	exporter, bans, manager := newTestExporter(t, "")
+
+	recordHits(t, manager, "192.0.2.10", 5)
+	recordHits(t, manager, "192.0.2.11", 1) // Below MinHits
+	recordHits(t, manager, "192.0.2.12", 5)
+	bans.Allow("192.0.2.12")
+	bans.Add("198.51.100.1", ban.RuleManual, "test", time.Hour)
+	bans.Add("not-an-ip", ban.RuleManual, "test", time.Hour)
+
+	entries := exporter.Entries(context.Background(), exporter.Filter())
+	if len(entries) != 2 {
+		t.Fatalf("len(Entries()) = %d, want 2: %+v", len(entries), entries)
+	}
+	if !entries[0].Banned || entries[0].Prefix.String() != "198.51.100.1/32" {
+		t.Errorf("entries[0] = %+v, want banned 198.51.100.1 first", entries[0])
+	}
+	if entries[1].Prefix.String() != "192.0.2.10/32" || entries[1].Hits != 5 {
+		t.Errorf("entries[1] = %+v, want 192.0.2.10 with 5 hits", entries[1])
+	}
+
+	// A tiny max age excludes trapped IPs but never banned ones
+	time.Sleep(5 * time.Millisecond)
+	entries = exporter.Entries(context.Background(), Filter{MaxAge: time.Millisecond})
+	if len(entries) != 1 || !entries[0].Banned {
+		t.Errorf("Entries() with max age = %+v, want only the banned IP", entries)
+	}
+}
+
+func TestExporter_EntriesNetworkBans(t *testing.T) {
+	exporter, bans, manager := newTestExporter(t, "")
+	var logs bytes.Buffer
+	exporter.logger = slog.New(slog.NewTextHandler(&logs, nil))
+
+	geo, err := geoip.NewResolver("", "../geoip/testdata/asn.mmdb")
+	if err != nil {
+		t.Fatalf("NewResolver() error = %v", err)
+	}
+	t.Cleanup(func() { geo.Close() })
+
+	recordHits(t, manager, "10.0.0.7", 3) // Trapped inside a banned network
+	bans.Add(geoip.ASNKey(64496), "trap-hits", "too many hits", time.Hour)
+	bans.Add(geoip.ASNKey(64498), ban.RuleManual, "test", time.Hour)
+	bans.Add(geoip.ASNKey(64499), ban.RuleManual, "unknown network", time.Hour)
+
+	// Without the ASN database network bans cannot be expanded
+	entries := exporter.Entries(context.Background(), exporter.Filter())
+	if len(entries) != 1 || entries[0].Banned {
+		t.Errorf("Entries() without ASN database = %+v, want only the trapped IP", entries)
+	}
+
+	exporter.SetNetworkPrefixes(geo.Prefixes)
+	var got []string
+	for _, e := range exporter.Entries(context.Background(), exporter.Filter()) {
+		got = append(got, fmt.Sprintf("%s banned=%v", e.Prefix, e.Banned))
+	}
+	slices.Sort(got) // Bans issued in the same clock tick may come in either order
+	want := []string{"10.0.0.0/23 banned=true", "10.0.0.7/32 banned=false", "2001:db8::/32 banned=true"}
+	if !slices.Equal(got, want) {
+		t.Errorf("Entries() = %v, want %v", got, want)
+	}
+
+	var buf bytes.Buffer
+	if err := Write(&buf, FormatIPSet, exporter.Entries(context.Background(), exporter.Filter())); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	for _, want := range []string{"add gospidertrap_v4 10.0.0.0/23 -exist", "add gospidertrap_v6 2001:db8::/32 -exist"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("ipset export missing %q:\n%s", want, buf.String())
+		}
+	}
+
+	// Skipped bans are logged once each, however often exports are built
+	for _, key := range []string{"AS64496", "AS64498", "AS64499"} {
+		if n := strings.Count(logs.String(), "key="+key); n != 1 {
+			t.Errorf("%s logged %d times, want once:\n%s", key, n, logs.String())
+		}
+	}
+}
+
+func TestExporter_WriteFiles(t *testing.T) {
+	dir := t.TempDir()
+	exporter, bans, _ := newTestExporter(t, dir)
+	bans.Add("192.0.2.1", ban.RuleManual, "test", time.Hour)
+
+	if err := exporter.WriteFiles(context.Background()); err != nil {
+		t.Fatalf("WriteFiles() error = %v", err)
+	}
+
+	for _, format := range Formats {
+		data, err := os.ReadFile(filepath.Join(dir, format.FileName()))
+		if err != nil {
+			t.Errorf("%s export not written: %v", format, err)
+			continue
+		}
+		if !strings.Contains(string(data), "192.0.2.1") {
+			t.Errorf("%s export missing banned IP:\n%s", format, data)
+		}
+	}
+
+	// No temporary files should be left behind
+	files, _ := os.ReadDir(dir)
+	if len(files) != len(Formats) {
+		t.Errorf("export directory has %d files, want %d", len(files), len(Formats))
+	}
+}
+
+func TestExporter_ServeHTTP(t *testing.T) {
+	exporter, _, manager := newTestExporter(t, "")
+	recordHits(t, manager, "192.0.2.10", 1)
+
+	tests := []struct {
+		name       string
+		target     string
+		wantStatus int
+		wantBody   string
+	}{
+		{"default filter excludes single hit", "/admin/export/cidr", http.StatusOK, ""},
+		{"min_hits override", "/admin/export/cidr?min_hits=1", http.StatusOK, "192.0.2.10/32\n"},
+		{"unknown format", "/admin/export/pf", http.StatusNotFound, ""},
+		{"invalid min_hits", "/admin/export/cidr?min_hits=x", http.StatusBadRequest, ""},
+		{"invalid max_age", "/admin/export/cidr?max_age=soon", http.StatusBadRequest, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			exporter.ServeHTTP(rec, httptest.NewRequest("GET", tt.target, nil))
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if tt.wantStatus == http.StatusOK && rec.Body.String() != tt.wantBody {
+				t.Errorf("body = %q, want %q", rec.Body.String(), tt.wantBody)
+			}
+		})
+	}
+}
@@ -0,0 +1,206 @@
+// Package export renders banned and trapped IP addresses in firewall formats.
+//
+// Supported formats are nftables set definitions, ipset restore files,
+// plain CIDR lists and fail2ban-compatible log lines.
+package export
+
+import (
+	"fmt"
+	"io"
+	"net/netip"
+	"strings"
+	"time"
+)
+
+// Format identifies a firewall export format.
+type Format string
+
+// Supported export formats.
+const (
+	FormatNFTables Format = "nftables"
+	FormatIPSet    Format = "ipset"
+	FormatCIDR     Format = "cidr"
+	FormatFail2Ban Format = "fail2ban"
+)
+
+// Formats lists every supported export format.
+var Formats = []Format{FormatNFTables, FormatIPSet, FormatCIDR, FormatFail2Ban}
+
+// Set names used in nftables and ipset output.
+const (
+	setNameV4 = "gospidertrap_v4"
+	setNameV6 = "gospidertrap_v6"
+)
+
+// Entry is a single IP address or network to export.
+type Entry struct {
+	Prefix   netip.Prefix // Client IP address as a single-host prefix, or a banned network
+	Hits     int          // Number of trap requests seen from the address
+	LastSeen time.Time    // Time of the most recent request or ban
+	Banned   bool         // Whether the address is on the ban list
+	Reason   string       // Ban reason, or "trapped" for unbanned addresses
+}
+
+// ParseFormat parses a format name.
+//
+// Returns the format and true if the name is a supported format.
+func ParseFormat(name string) (Format, bool) {
+	for _, f := range Formats {
+		if string(f) == name {
+			return f, true
+		}
+	}
+	return "", false
+}
+
+// FileName returns the name of the file the format is written to.
+func (f Format) FileName() string {
+	switch f {
+	case FormatNFTables:
+		return "firewall.nft"
+	case FormatIPSet:
+		return "firewall.ipset"
+	case FormatCIDR:
+		return "firewall.txt"
+	case FormatFail2Ban:
+		return "fail2ban.log"
+	default:
+		return string(f)
+	}
+}
+
+// ContentType returns the MIME type used when serving the format over HTTP.
+func (f Format) ContentType() string {
+	return "text/plain; charset=utf-8"
+}
+
+// Write renders entries in the given format.
+//
+// Parameters:
+//   - w: destination writer
+//   - format: the export format
+//   - entries: the addresses to export
+//
+// Returns an error if the format is unknown or writing fails.
+func Write(w io.Writer, format Format, entries []Entry) error {
+	switch format {
+	case FormatNFTables:
+		return writeNFTables(w, entries)
+	case FormatIPSet:
+		return writeIPSet(w, entries)
+	case FormatCIDR:
+		return writeCIDR(w, entries)
+	case FormatFail2Ban:
+		return writeFail2Ban(w, entries)
+	default:
+		return fmt.Errorf("unknown export format: %s", format)
+	}
+}
+
+// prefix returns the single-host CIDR prefix for an address.
+func prefix(addr netip.Addr) netip.Prefix {
+	return netip.PrefixFrom(addr, addr.BitLen())
+}
+
+// splitFamilies separates IPv4 and IPv6 entries.
+func splitFamilies(entries []Entry) (v4, v6 []Entry) {
+	for _, e := range entries {
+		if e.Prefix.Addr().Is4() {
+			v4 = append(v4, e)
+		} else {
+			v6 = append(v6, e)
+		}
+	}
+	return v4, v6
+}
+
+// writeNFTables writes nftables set definitions suitable for inclusion in a
+// table with "include". Empty sets are written without an elements line,
+// since nftables rejects an empty element list.
+func writeNFTables(w io.Writer, entries []Entry) error {
+	v4, v6 := splitFamilies(entries)
+
+	var sb strings.Builder
+	sb.WriteString("# Generated by gospidertrap. Include inside a table definition.\n")
+	writeNFTSet(&sb, setNameV4, "ipv4_addr", v4)
+	writeNFTSet(&sb, setNameV6, "ipv6_addr", v6)
+
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+// writeNFTSet writes a single nftables set block.
+func writeNFTSet(sb *strings.Builder, name, addrType string, entries []Entry) {
+	sb.WriteString("set " + name + " {\n")
+	sb.WriteString("\ttype " + addrType + "\n")
+	sb.WriteString("\tflags interval\n")
+	if len(entries) > 0 {
+		sb.WriteString("\telements = {\n")
+		for i, e := range entries {
+			sb.WriteString("\t\t")
+			sb.WriteString(e.Prefix.String())
+			if i < len(entries)-1 {
+				sb.WriteString(",")
+			}
+			sb.WriteString("\n")
+		}
+		sb.WriteString("\t}\n")
+	}
+	sb.WriteString("}\n")
+}
+
+// writeIPSet writes an ipset restore file ("ipset restore < file").
+func writeIPSet(w io.Writer, entries []Entry) error {
+	v4, v6 := splitFamilies(entries)
+
+	var sb strings.Builder
+	sb.WriteString("# Generated by gospidertrap. Load with: ipset restore -exist < firewall.ipset\n")
+	writeIPSetSet(&sb, setNameV4, "inet", v4)
+	writeIPSetSet(&sb, setNameV6, "inet6", v6)
+
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+// writeIPSetSet writes the create and add lines for a single ipset.
+func writeIPSetSet(sb *strings.Builder, name, family string, entries []Entry) {
+	sb.WriteString("create " + name + " hash:net family " + family + " -exist\n")
+	sb.WriteString("flush " + name + "\n")
+	for _, e := range entries {
+		sb.WriteString("add " + name + " " + e.Prefix.String() + " -exist\n")
+	}
+}
+
+// writeCIDR writes one CIDR prefix per line.
+func writeCIDR(w io.Writer, entries []Entry) error {
+	var sb strings.Builder
+	for _, e := range entries {
+		sb.WriteString(e.Prefix.String())
+		sb.WriteString("\n")
+	}
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+// writeFail2Ban writes one log line per address, timestamped with the last
+// time it was seen. Banned networks are written in CIDR notation, which
+// fail2ban 0.10 and later match with the <SUBNET> tag:
+//
+//	failregex = gospidertrap: (?:banned|trapped) ip=<SUBNET>\s
+func writeFail2Ban(w io.Writer, entries []Entry) error {
+	var sb strings.Builder
+	for _, e := range entries {
+		action := "trapped"
+		if e.Banned {
+			action = "banned"
+		}
+		var host fmt.Stringer = e.Prefix
+		if e.Prefix.IsSingleIP() {
+			host = e.Prefix.Addr()
+		}
+		fmt.Fprintf(&sb, "%s gospidertrap: %s ip=%s hits=%d reason=%q\n",
+			e.LastSeen.UTC().Format(time.RFC3339), action, host, e.Hits, e.Reason)
+	}
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
@@ -0,0 +1,235 @@
+// Package geoip looks up the country and network of IP addresses in
+// offline MaxMind-format databases, such as GeoLite2 City and ASN.
+package geoip
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/oschwald/maxminddb-golang"
+)
+
+// Info is what the databases know about an IP address. Zero fields are
+// unknown.
+type Info struct {
+	Country string `json:"country,omitempty"` // ISO 3166-1 alpha-2 code, e.g. "US"
+	ASN     uint   `json:"asn,omitempty"`     // Autonomous system number
+	Org     string `json:"org,omitempty"`     // Autonomous system organization
+}
+
+// Network returns the autonomous system as "AS64496 Example Org", or an
+// empty string if it is unknown.
+func (i Info) Network() string {
+	if i.ASN == 0 {
+		return ""
+	}
+	label := ASNKey(i.ASN)
+	if i.Org != "" {
+		label += " " + i.Org
+	}
+	return label
+}
+
+// ASNKey returns the key identifying an autonomous system, e.g. "AS64496".
+// Bans and rate limits keyed by network use it in place of an IP address.
+func ASNKey(asn uint) string {
+	return "AS" + strconv.FormatUint(uint64(asn), 10)
+}
+
+// ParseASNKey parses a key returned by ASNKey.
+//
+// Returns the autonomous system number and true, or false if key is not an
+// ASN key.
+func ParseASNKey(key string) (uint, bool) {
+	digits, ok := strings.CutPrefix(key, "AS")
+	if !ok {
+		return 0, false
+	}
+	asn, err := strconv.ParseUint(digits, 10, 32)
+	if err != nil || asn == 0 {
+		return 0, false
+	}
+	return uint(asn), true
+}
+
+// cityRecord holds the fields read from City and Country databases.
+type cityRecord struct {
+	Country struct {
+		ISOCode string `maxminddb:"iso_code"`
+	} `maxminddb:"country"`
+}
+
+// asnRecord holds the fields read from ASN databases.
+type asnRecord struct {
+	Number uint   `maxminddb:"autonomous_system_number"`
+	Org    string `maxminddb:"autonomous_system_organization"`
+}
+
+// Resolver looks up IP addresses in a country database, an ASN database or
+// both. It is safe for concurrent use.
+type Resolver struct {
+	city *maxminddb.Reader // Nil without a country database
+	asn  *maxminddb.Reader // Nil without an ASN database
+
+	mu       sync.Mutex
+	prefixes map[uint][]netip.Prefix // Prefixes by ASN, filled by Prefixes
+}
+
+// NewResolver opens the databases. Either path may be empty, but not both.
+//
+// Parameters:
+//   - cityPath: path to a City or Country database, e.g. GeoLite2-City.mmdb
+//   - asnPath: path to an ASN database, e.g. GeoLite2-ASN.mmdb
+//
+// Returns the resolver, or an error if a database cannot be opened or is
+// of the wrong type.
+func NewResolver(cityPath, asnPath string) (*Resolver, error) {
+	if cityPath == "" && asnPath == "" {
+		return nil, fmt.Errorf("no GeoIP database given")
+	}
+
+	r := &Resolver{}
+	var err error
+	if cityPath != "" {
+		if r.city, err = openDatabase(cityPath, "City", "Country"); err != nil {
+			return nil, err
+		}
+	}
+	if asnPath != "" {
+		if r.asn, err = openDatabase(asnPath, "ASN"); err != nil {
+			r.Close()
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// openDatabase opens a database and checks that its type contains one of
+// kinds, which catches databases passed to the wrong flag.
+func openDatabase(path string, kinds ...string) (*maxminddb.Reader, error) {
+	reader, err := maxminddb.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open GeoIP database %s: %w", path, err)
+	}
+	for _, kind := range kinds {
+		if strings.Contains(reader.Metadata.DatabaseType, kind) {
+			return reader, nil
+		}
+	}
+	reader.Close()
+	return nil, fmt.Errorf("GeoIP database %s is a %s database, want %s", path, reader.Metadata.DatabaseType, strings.Join(kinds, " or "))
+}
+
+// HasASN reports whether the resolver has an ASN database.
+func (r *Resolver) HasASN() bool {
+	return r != nil && r.asn != nil
+}
+
+// Lookup returns what the databases know about an IP address. Addresses
+// that do not parse or are not in the databases return a zero Info.
+//
+// Parameters:
+//   - ip: the IP address
+//
+// Returns the country and autonomous system, where known.
+func (r *Resolver) Lookup(ip string) Info {
+	var info Info
+	addr := net.ParseIP(ip)
+	if r == nil || addr == nil {
+		return info
+	}
+	if r.city != nil {
+		var record cityRecord
+		if err := r.city.Lookup(addr, &record); err == nil {
+			info.Country = record.Country.ISOCode
+		}
+	}
+	if r.asn != nil {
+		var record asnRecord
+		if err := r.asn.Lookup(addr, &record); err == nil {
+			info.ASN, info.Org = record.Number, record.Org
+		}
+	}
+	return info
+}
+
+// NetworkKey returns the ASN key of an IP address's autonomous system, or
+// the address itself if the autonomous system is unknown, so that clients
+// outside the ASN database are still told apart.
+//
+// Parameters:
+//   - ip: the IP address
+//
+// Returns a key such as "AS64496", or ip.
+func (r *Resolver) NetworkKey(ip string) string {
+	if asn := r.Lookup(ip).ASN; asn != 0 {
+		return ASNKey(asn)
+	}
+	return ip
+}
+
+// Prefixes returns the networks the ASN database assigns to an autonomous
+// system. The database is walked once per autonomous system and the result
+// is cached, since the databases do not change while they are open.
+//
+// Parameters:
+//   - asn: the autonomous system number
+//
+// Returns the prefixes, IPv4 first, or an error if there is no ASN
+// database or it cannot be read.
+func (r *Resolver) Prefixes(asn uint) ([]netip.Prefix, error) {
+	if !r.HasASN() {
+		return nil, fmt.Errorf("no GeoIP ASN database")
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if prefixes, ok := r.prefixes[asn]; ok {
+		return prefixes, nil
+	}
+
+	var prefixes []netip.Prefix
+	networks := r.asn.Networks(maxminddb.SkipAliasedNetworks)
+	for networks.Next() {
+		var record asnRecord
+		network, err := networks.Network(&record)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read GeoIP ASN database: %w", err)
+		}
+		if record.Number != asn {
+			continue
+		}
+		addr, ok := netip.AddrFromSlice(network.IP)
+		if !ok {
+			continue
+		}
+		bits, _ := network.Mask.Size()
+		prefixes = append(prefixes, netip.PrefixFrom(addr, bits))
+	}
+	if err := networks.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read GeoIP ASN database: %w", err)
+	}
+
+	if r.prefixes == nil {
+		r.prefixes = make(map[uint][]netip.Prefix)
+	}
+	r.prefixes[asn] = prefixes
+	return prefixes, nil
+}
+
+// Close closes the databases.
+func (r *Resolver) Close() error {
+	var err error
+	for _, reader := range []*maxminddb.Reader{r.city, r.asn} {
+		if reader != nil {
+			if closeErr := reader.Close(); closeErr != nil && err == nil {
+				err = closeErr
+			}
+		}
+	}
+	return err
+}
@@ -0,0 +1,146 @@
+package geoip
+
+import (
+	"fmt"
+	"testing"
+)
+
+// newTestResolver opens the fixture databases, which cover 10.0.0.0/24,
+// 10.0.1.0/24 and 192.0.2.0/24 and 2001:db8::/32.
+func newTestResolver(t *testing.T, cityPath, asnPath string) *Resolver {
+	t.Helper()
+	r, err := NewResolver(cityPath, asnPath)
+	if err != nil {
+		t.Fatalf("NewResolver() error = %v", err)
+	}
+	t.Cleanup(func() { r.Close() })
+	return r
+}
+
+func TestLookup(t *testing.T) {
+	r := newTestResolver(t, "testdata/city.mmdb", "testdata/asn.mmdb")
+
+	tests := []struct {
+		ip          string
+		want        Info
+		wantNetwork string
+		wantKey     string
+	}{
+		{"10.0.0.1", Info{"US", 64496, "Example Hosting"}, "AS64496 Example Hosting", "AS64496"},
+		{"192.0.2.200", Info{"DE", 64497, "Example Transit"}, "AS64497 Example Transit", "AS64497"},
+		{"2001:db8::1", Info{"JP", 64498, "Example IPv6"}, "AS64498 Example IPv6", "AS64498"},
+		{"198.51.100.1", Info{}, "", "198.51.100.1"},
+		{"not-an-ip", Info{}, "", "not-an-ip"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.ip, func(t *testing.T) {
+			got := r.Lookup(tt.ip)
+			if got != tt.want {
+				t.Errorf("Lookup() = %+v, want %+v", got, tt.want)
+			}
+			if got.Network() != tt.wantNetwork {
+				t.Errorf("Network() = %q, want %q", got.Network(), tt.wantNetwork)
+			}
+			if key := r.NetworkKey(tt.ip); key != tt.wantKey {
+				t.Errorf("NetworkKey() = %q, want %q", key, tt.wantKey)
+			}
+		})
+	}
+}
+
+func TestParseASNKey(t *testing.T) {
+	tests := []struct {
+		key    string
+		want   uint
+		wantOK bool
+	}{
+		{"AS64496", 64496, true},
+		{ASNKey(4200000000), 4200000000, true},
+		{"AS0", 0, false},
+		{"AS", 0, false},
+		{"as64496", 0, false},
+		{"AS-1", 0, false},
+		{"AS99999999999", 0, false},
+		{"192.0.2.1", 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			got, ok := ParseASNKey(tt.key)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("ParseASNKey() = %d, %v, want %d, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestPrefixes(t *testing.T) {
+	r := newTestResolver(t, "", "testdata/asn.mmdb")
+
+	tests := []struct {
+		asn  uint
+		want string
+	}{
+		{64496, "[10.0.0.0/23]"},
+		{64497, "[192.0.2.0/24]"},
+		{64498, "[2001:db8::/32]"},
+		{64499, "[]"},
+	}
+	for _, tt := range tests {
+		t.Run(ASNKey(tt.asn), func(t *testing.T) {
+			// The second call is answered from the cache
+			for range 2 {
+				got, err := r.Prefixes(tt.asn)
+				if err != nil {
+					t.Fatalf("Prefixes() error = %v", err)
+				}
+				if fmt.Sprint(got) != tt.want {
+					t.Errorf("Prefixes() = %v, want %s", got, tt.want)
+				}
+			}
+		})
+	}
+
+	city := newTestResolver(t, "testdata/city.mmdb", "")
+	if _, err := city.Prefixes(64496); err == nil {
+		t.Error("Prefixes() without an ASN database succeeded")
+	}
+}
+
+func TestNewResolverPartial(t *testing.T) {
+	city := newTestResolver(t, "testdata/city.mmdb", "")
+	if got := city.Lookup("10.0.0.1"); got != (Info{Country: "US"}) || city.HasASN() {
+		t.Errorf("country-only Lookup() = %+v, HasASN() = %v, want only the country", got, city.HasASN())
+	}
+
+	asn := newTestResolver(t, "", "testdata/asn.mmdb")
+	if got := asn.Lookup("10.0.0.1"); got.Country != "" || got.ASN != 64496 || !asn.HasASN() {
+		t.Errorf("ASN-only Lookup() = %+v, want only the autonomous system", got)
+	}
+
+	var none *Resolver
+	if got := none.Lookup("10.0.0.1"); got != (Info{}) || none.HasASN() {
+		t.Errorf("nil resolver Lookup() = %+v, want nothing", got)
+	}
+}
+
+func TestNewResolverErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		cityPath string
+		asnPath  string
+	}{
+		{"no databases", "", ""},
+		{"missing file", "testdata/missing.mmdb", ""},
+		{"not a database", "testdata/generate.go", ""},
+		{"ASN database as city", "testdata/asn.mmdb", ""},
+		{"city database as ASN", "", "testdata/city.mmdb"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if r, err := NewResolver(tt.cityPath, tt.asnPath); err == nil {
+				r.Close()
+				t.Error("NewResolver() succeeded")
+			}
+		})
+	}
+}
@@ -0,0 +1,80 @@
+//go:build ignore
+
+// generate writes the fixture databases in this directory. It needs
+// github.com/maxmind/mmdbwriter, which gospidertrap does not depend on, so
+// run it from a scratch module:
+//
+//	go mod init gen && go get github.com/maxmind/mmdbwriter && go run generate.go
+package main
+
+import (
+	"log"
+	"net"
+	"os"
+
+	"github.com/maxmind/mmdbwriter"
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+)
+
+// network is one fixture network and what the databases say about it.
+type network struct {
+	cidr    string
+	country string
+	name    string
+	asn     uint32
+	org     string
+}
+
+var networks = []network{
+	{"10.0.0.0/24", "US", "United States", 64496, "Example Hosting"},
+	{"10.0.1.0/24", "US", "United States", 64496, "Example Hosting"},
+	{"192.0.2.0/24", "DE", "Germany", 64497, "Example Transit"},
+	{"2001:db8::/32", "JP", "Japan", 64498, "Example IPv6"},
+}
+
+func main() {
+	write("GeoLite2-City", "city.mmdb", func(n network) mmdbtype.DataType {
+		return mmdbtype.Map{
+			"country": mmdbtype.Map{
+				"iso_code": mmdbtype.String(n.country),
+				"names":    mmdbtype.Map{"en": mmdbtype.String(n.name)},
+			},
+		}
+	})
+	write("GeoLite2-ASN", "asn.mmdb", func(n network) mmdbtype.DataType {
+		return mmdbtype.Map{
+			"autonomous_system_number":       mmdbtype.Uint32(n.asn),
+			"autonomous_system_organization": mmdbtype.String(n.org),
+		}
+	})
+}
+
+// write creates a database of the given type holding record(n) for every
+// fixture network.
+func write(databaseType, path string, record func(network) mmdbtype.DataType) {
+	tree, err := mmdbwriter.New(mmdbwriter.Options{
+		DatabaseType:            databaseType,
+		IncludeReservedNetworks: true,
+		RecordSize:              24,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, n := range networks {
+		_, ipNet, err := net.ParseCIDR(n.cidr)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := tree.Insert(ipNet, record(n)); err != nil {
+			log.Fatal(err)
+		}
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer f.Close()
+	if _, err := tree.WriteTo(f); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,77 @@
+package handler
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// maxPageAge is how far before startup the Last-Modified times of trap
+// pages are spread.
+const maxPageAge = 180 * 24 * time.Hour
+
+// validators derives the ETag and Last-Modified time of trap pages from
+// their URL, so every URL keeps the same validators however its page is
+// generated, the way a CMS page does until it is edited.
+//
+// The key is random per process, so validators change on restart like
+// those of a redeployed site.
+type validators struct {
+	key   []byte    // HMAC key the validators are derived with
+	epoch time.Time // Latest Last-Modified time, the startup time
+}
+
+// newValidators creates validators with a random key.
+func newValidators() *validators {
+	key := make([]byte, 32)
+	rand.Read(key)
+	return &validators{key: key, epoch: time.Now().UTC().Truncate(time.Second)}
+}
+
+// For returns the validators of the page at a URL.
+//
+// Parameters:
+//   - uri: the request URI, path and query string
+//
+// Returns a weak ETag, weak since the page is regenerated on every request,
+// and the Last-Modified time.
+func (v *validators) For(uri string) (string, time.Time) {
+	mac := hmac.New(sha256.New, v.key)
+	mac.Write([]byte(uri))
+	sum := mac.Sum(nil)
+
+	age := time.Duration(binary.BigEndian.Uint64(sum[8:16])%uint64(maxPageAge/time.Second)) * time.Second
+	return `W/"` + hex.EncodeToString(sum[:8]) + `"`, v.epoch.Add(-age)
+}
+
+// notModified reports whether a conditional GET or HEAD can be answered
+// with 304 Not Modified.
+//
+// If-None-Match takes precedence over If-Modified-Since and is compared
+// weakly, as RFC 9110 requires for both.
+//
+// Parameters:
+//   - r: the HTTP request
+//   - etag: the page's ETag
+//   - lastModified: the page's Last-Modified time
+func notModified(r *http.Request, etag string, lastModified time.Time) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+	if match := r.Header.Get("If-None-Match"); match != "" {
+		for _, candidate := range strings.Split(match, ",") {
+			candidate = strings.TrimSpace(candidate)
+			if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+				return true
+			}
+		}
+		return false
+	}
+	since, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	return err == nil && !lastModified.After(since)
+}
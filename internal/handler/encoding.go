@@ -0,0 +1,166 @@
+package handler
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+// Content codings trap pages can be compressed with.
+const (
+	EncodingZstd   = "zstd"
+	EncodingBrotli = "br"
+	EncodingGzip   = "gzip"
+)
+
+// Encodings lists every supported content coding, in the default order of
+// preference.
+var Encodings = []string{EncodingZstd, EncodingBrotli, EncodingGzip}
+
+const (
+	// minCompressSize is the smallest page worth compressing; below it the
+	// coding's framing outweighs the savings.
+	minCompressSize = 128
+
+	// brotliLevel trades ratio for speed the way web servers compressing on
+	// the fly do; brotli's highest levels are meant for static assets.
+	brotliLevel = 5
+)
+
+var (
+	gzipWriters = sync.Pool{New: func() any {
+		zw, _ := gzip.NewWriterLevel(nil, gzip.DefaultCompression)
+		return zw
+	}}
+	brotliWriters = sync.Pool{New: func() any {
+		return brotli.NewWriterLevel(nil, brotliLevel)
+	}}
+	// zstdEncoder is shared, since EncodeAll is safe for concurrent use
+	zstdEncoder, _ = zstd.NewWriter(nil,
+		zstd.WithEncoderLevel(zstd.SpeedDefault),
+		zstd.WithEncoderConcurrency(1))
+)
+
+// ParseEncodings parses a comma-separated list of content codings to offer,
+// in order of preference.
+//
+// Parameters:
+//   - list: the codings, such as "zstd,br,gzip", or "none" to disable compression
+//
+// Returns the codings, or an error if one is unknown or listed twice.
+func ParseEncodings(list string) ([]string, error) {
+	if strings.TrimSpace(list) == "none" {
+		return nil, nil
+	}
+	var encodings []string
+	for _, name := range strings.Split(list, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		known := false
+		for _, e := range Encodings {
+			known = known || name == e
+		}
+		if !known {
+			return nil, fmt.Errorf("unknown encoding %q (supported: %s, or none)", name, strings.Join(Encodings, ", "))
+		}
+		for _, e := range encodings {
+			if name == e {
+				return nil, fmt.Errorf("encoding %q listed twice", name)
+			}
+		}
+		encodings = append(encodings, name)
+	}
+	return encodings, nil
+}
+
+// negotiate picks the content coding for a response from an
+// Accept-Encoding header.
+//
+// The coding the client gives the highest quality wins, with ties going to
+// the earliest in offered; "*" stands for any coding the client does not
+// list.
+//
+// Parameters:
+//   - header: the Accept-Encoding header
+//   - offered: the codings the server offers, in order of preference
+//
+// Returns the coding, or "" to send the response uncompressed.
+func negotiate(header string, offered []string) string {
+	if header == "" || len(offered) == 0 {
+		return ""
+	}
+	qualities := make(map[string]float64)
+	wildcard := -1.0
+	for _, part := range strings.Split(header, ",") {
+		coding, params, _ := strings.Cut(part, ";")
+		coding = strings.ToLower(strings.TrimSpace(coding))
+		q := 1.0
+		if name, value, ok := strings.Cut(params, "="); ok && strings.EqualFold(strings.TrimSpace(name), "q") {
+			parsed, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+		if coding == "*" {
+			wildcard = q
+		} else if coding != "" {
+			qualities[coding] = q
+		}
+	}
+
+	best, bestQ := "", 0.0
+	for _, coding := range offered {
+		q, listed := qualities[coding]
+		if !listed {
+			q = wildcard
+		}
+		if q > bestQ {
+			best, bestQ = coding, q
+		}
+	}
+	return best
+}
+
+// encode compresses p with a content coding.
+//
+// Parameters:
+//   - coding: one of Encodings
+//   - p: the data to compress
+//
+// Returns the compressed data, or an error if compression fails.
+func encode(coding string, p []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	switch coding {
+	case EncodingZstd:
+		return zstdEncoder.EncodeAll(p, make([]byte, 0, len(p)/2)), nil
+	case EncodingBrotli:
+		bw := brotliWriters.Get().(*brotli.Writer)
+		defer brotliWriters.Put(bw)
+		bw.Reset(&buf)
+		if _, err := bw.Write(p); err != nil {
+			return nil, fmt.Errorf("failed to compress with brotli: %w", err)
+		}
+		if err := bw.Close(); err != nil {
+			return nil, fmt.Errorf("failed to compress with brotli: %w", err)
+		}
+	case EncodingGzip:
+		zw := gzipWriters.Get().(*gzip.Writer)
+		defer gzipWriters.Put(zw)
+		zw.Reset(&buf)
+		if _, err := zw.Write(p); err != nil {
+			return nil, fmt.Errorf("failed to compress with gzip: %w", err)
+		}
+		if err := zw.Close(); err != nil {
+			return nil, fmt.Errorf("failed to compress with gzip: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("unknown encoding %q", coding)
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,122 @@
+package handler
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+func TestParseEncodings(t *testing.T) {
+	tests := []struct {
+		list    string
+		want    []string
+		wantErr bool
+	}{
+		{"zstd,br,gzip", []string{"zstd", "br", "gzip"}, false},
+		{" GZIP , br", []string{"gzip", "br"}, false},
+		{"none", nil, false},
+		{"deflate", nil, true},
+		{"gzip,gzip", nil, true},
+		{"", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.list, func(t *testing.T) {
+			got, err := ParseEncodings(tt.list)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseEncodings() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("ParseEncodings() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNegotiate(t *testing.T) {
+	tests := []struct {
+		name    string
+		header  string
+		offered []string
+		want    string
+	}{
+		{"browser", "gzip, deflate, br, zstd", Encodings, "zstd"},
+		{"server preference", "gzip, br", Encodings, "br"},
+		{"client quality", "zstd;q=0.5, gzip", Encodings, "gzip"},
+		{"refused", "gzip;q=0", Encodings, ""},
+		{"wildcard", "*", Encodings, "zstd"},
+		{"wildcard with refusal", "zstd;q=0, *;q=0.8", Encodings, "br"},
+		{"unsupported", "deflate, compress", Encodings, ""},
+		{"identity", "identity", Encodings, ""},
+		{"none offered", "gzip", nil, ""},
+		{"not offered", "zstd", []string{"gzip"}, ""},
+		{"no header", "", Encodings, ""},
+		{"malformed quality", "br;q=high, gzip", Encodings, "gzip"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := negotiate(tt.header, tt.offered); got != tt.want {
+				t.Errorf("negotiate(%q) = %q, want %q", tt.header, got, tt.want)
+			}
+		})
+	}
+}
+
+// decode decompresses data compressed with a content coding.
+func decode(t *testing.T, coding string, data []byte) []byte {
+	t.Helper()
+	var r io.Reader
+	switch coding {
+	case EncodingGzip:
+		zr, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		r = zr
+	case EncodingBrotli:
+		r = brotli.NewReader(bytes.NewReader(data))
+	case EncodingZstd:
+		zr, err := zstd.NewReader(bytes.NewReader(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer zr.Close()
+		r = zr
+	default:
+		return data
+	}
+	decoded, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("failed to decode %s: %v", coding, err)
+	}
+	return decoded
+}
+
+func TestEncode(t *testing.T) {
+	page := []byte(strings.Repeat(`<a href="/archive/2024/spring-collection">Spring collection</a>`+"\n", 50))
+	for _, coding := range Encodings {
+		t.Run(coding, func(t *testing.T) {
+			// Encode twice to exercise the pooled writers
+			for range 2 {
+				encoded, err := encode(coding, page)
+				if err != nil {
+					t.Fatalf("encode() error = %v", err)
+				}
+				if len(encoded) >= len(page)/4 {
+					t.Errorf("encoded %d bytes to %d", len(page), len(encoded))
+				}
+				if got := decode(t, coding, encoded); !bytes.Equal(got, page) {
+					t.Errorf("round trip changed the page")
+				}
+			}
+		})
+	}
+
+	if _, err := encode("deflate", page); err == nil {
+		t.Error("encode() accepted an unknown coding")
+	}
+}
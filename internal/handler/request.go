@@ -1,13 +1,21 @@
 package handler
 
 import (
-	"io"
 	"log/slog"
 	"net/http"
+	"strconv"
 	"time"
 
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/rampantspark/gospidertrap/internal/bait"
+	"github.com/rampantspark/gospidertrap/internal/capture"
 	"github.com/rampantspark/gospidertrap/internal/content"
+	"github.com/rampantspark/gospidertrap/internal/defense"
+	"github.com/rampantspark/gospidertrap/internal/honeytoken"
+	"github.com/rampantspark/gospidertrap/internal/metrics"
 	"github.com/rampantspark/gospidertrap/internal/stats"
+	"github.com/rampantspark/gospidertrap/internal/tracing"
 )
 
 // RequestHandler handles HTTP requests by generating and serving HTML pages.
@@ -19,6 +27,16 @@ type RequestHandler struct {
 	stats   *stats.Manager
 	logger  *slog.Logger
 	delay   time.Duration
+	forms   *honeytoken.Tracker // Captures submissions and issues honeytokens (nil if disabled)
+	capture *capture.Capturer   // Records methods, query strings and bodies (nil if disabled)
+	bait    *bait.Catalog       // Answers vulnerability scanner probes (nil if disabled)
+	defense *defense.Defender   // Answers clients banned for abuse (nil if disabled)
+
+	encodings  []string    // Content codings offered for trap pages, in order of preference (nil for none)
+	validators *validators // Derives the ETag and Last-Modified time of trap pages
+
+	generation *metrics.Histogram // Page generation latency (nil if not instrumented)
+	active     *metrics.Gauge     // Requests currently held in the delay (nil if not instrumented)
 }
 
 // New creates a new request handler.
@@ -32,19 +50,87 @@ type RequestHandler struct {
 // Returns a new RequestHandler instance.
 func New(content *content.Generator, stats *stats.Manager, logger *slog.Logger, delay time.Duration) *RequestHandler {
 	return &RequestHandler{
-		content: content,
-		stats:   stats,
-		logger:  logger,
-		delay:   delay,
+		content:    content,
+		stats:      stats,
+		logger:     logger,
+		delay:      delay,
+		encodings:  Encodings,
+		validators: newValidators(),
 	}
 }
 
+// Instrument sets the metrics updated by the handler.
+//
+// Parameters:
+//   - generation: histogram of page generation latency
+//   - active: gauge of requests currently held in the response delay
+func (h *RequestHandler) Instrument(generation *metrics.Histogram, active *metrics.Gauge) {
+	h.generation = generation
+	h.active = active
+}
+
+// SetForms sets the tracker that captures form submissions and prefills
+// trap forms with honeytokens. It must be called before serving.
+//
+// Parameters:
+//   - tracker: the form tracker
+func (h *RequestHandler) SetForms(tracker *honeytoken.Tracker) {
+	h.forms = tracker
+}
+
+// SetCapture sets the capturer that records the method, query string and
+// body of trap requests. It must be called before serving.
+//
+// Parameters:
+//   - capturer: the request capturer
+func (h *RequestHandler) SetCapture(capturer *capture.Capturer) {
+	h.capture = capturer
+}
+
+// SetBait sets the catalog of scanner baits, whose paths are answered with
+// fake responses instead of trap pages. It must be called before serving.
+//
+// Parameters:
+//   - catalog: the bait catalog
+func (h *RequestHandler) SetBait(catalog *bait.Catalog) {
+	h.bait = catalog
+}
+
+// SetCompression sets the content codings trap pages are compressed with,
+// negotiated from the request's Accept-Encoding. By default every coding in
+// Encodings is offered. It must be called before serving.
+//
+// Parameters:
+//   - encodings: the codings in order of preference (nil disables compression)
+func (h *RequestHandler) SetCompression(encodings []string) {
+	h.encodings = encodings
+}
+
+// SetDefense sets the defender that answers banned clients whose ban rule
+// has a defense action. It must be called before serving.
+//
+// Parameters:
+//   - defender: the defender
+func (h *RequestHandler) SetDefense(defender *defense.Defender) {
+	h.defense = defender
+}
+
 // Handle handles an HTTP request by recording stats, adding delay, and serving content.
 //
-// The method:
-//  1. Records request statistics using the stats manager
+// Banned clients let through for a defense response are answered by the
+// defender straight away, without being recorded or delayed. Otherwise the
+// method:
+//  1. Records request statistics using the stats manager, tagged with the
+//     bait that answers the path if any, and the method, query string and
+//     body if a capturer or form tracker is set
 //  2. Adds a configurable delay to simulate real-world response times
-//  3. Generates and serves an HTML page with random links
+//  3. Serves the bait's fake response, or answers a conditional GET whose
+//     validators match with 304 Not Modified, or generates an HTML page with
+//     random links and serves it compressed as Accept-Encoding allows
+//
+// Trap pages carry an ETag and Last-Modified time derived from their URL, so
+// a URL revalidates like an unchanged CMS page even though its links are
+// regenerated on every request.
 //
 // The delay respects context cancellation, so if the client disconnects or
 // the request times out, the response is not written.
@@ -55,19 +141,53 @@ func New(content *content.Generator, stats *stats.Manager, logger *slog.Logger,
 func (h *RequestHandler) Handle(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 
+	if h.defense != nil && h.defense.Serve(w, r, h.stats.GetClientIP(r)) {
+		return
+	}
+
+	var entry bait.Entry
+	var isBait bool
+	if h.bait != nil {
+		entry, isBait = h.bait.Match(r.URL.Path)
+	}
+
 	// Record request statistics, passing context for cancellation support
-	if err := h.stats.RecordRequest(ctx, r); err != nil {
+	var err error
+	if isBait {
+		err = h.stats.RecordBaitRequest(ctx, r, entry.Name)
+	} else {
+		err = h.stats.RecordRequest(ctx, r)
+	}
+	if err != nil {
 		h.logger.Warn("Failed to record request", "error", err)
 	}
+	if h.capture != nil {
+		// Captures first, since it leaves the body readable for the form tracker
+		h.capture.Capture(ctx, r, h.stats.GetClientIP(r))
+	}
+	var prefill content.Prefill
+	if h.forms != nil {
+		ip := h.stats.GetClientIP(r)
+		h.forms.Capture(ctx, r, ip)
+		if !isBait {
+			prefill = h.forms.Prefill(ctx, ip)
+		}
+	}
 
 	// Add delay to simulate real-world response times, respecting context cancellation
+	h.active.Inc()
+	_, delaySpan := tracing.Start(ctx, "handler.delay")
 	select {
 	case <-ctx.Done():
 		// Request was cancelled or timed out
+		h.active.Dec()
+		tracing.End(delaySpan, ctx.Err())
 		h.logger.Warn("Request cancelled or timed out", "path", r.URL.Path, "error", ctx.Err())
 		return
 	case <-time.After(h.delay):
 		// Delay completed
+		h.active.Dec()
+		delaySpan.End()
 	}
 
 	// Check context again before writing response
@@ -75,6 +195,48 @@ func (h *RequestHandler) Handle(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	w.Header().Set("Content-Type", "text/html")
-	io.WriteString(w, h.content.GeneratePage())
+	if isBait {
+		h.bait.Respond(w, r, entry, h.stats.GetClientIP(r))
+		return
+	}
+
+	etag, lastModified := h.validators.For(r.URL.RequestURI())
+	header := w.Header()
+	header.Set("ETag", etag)
+	header.Set("Last-Modified", lastModified.Format(http.TimeFormat))
+	header.Set("Cache-Control", "no-cache")
+	if len(h.encodings) > 0 {
+		header.Set("Vary", "Accept-Encoding")
+	}
+	if notModified(r, etag, lastModified) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	_, genSpan := tracing.Start(ctx, "content.GeneratePage")
+	start := time.Now()
+	page := h.content.GeneratePageWith(prefill)
+	h.generation.ObserveDuration(time.Since(start))
+	genSpan.SetAttributes(attribute.Int("content.page_bytes", len(page)))
+	genSpan.End()
+
+	h.writePage(w, r, []byte(page))
+}
+
+// writePage writes a trap page, compressed with the coding negotiated from
+// the request's Accept-Encoding if the page is large enough to benefit.
+func (h *RequestHandler) writePage(w http.ResponseWriter, r *http.Request, page []byte) {
+	header := w.Header()
+	header.Set("Content-Type", "text/html; charset=utf-8")
+	if coding := negotiate(r.Header.Get("Accept-Encoding"), h.encodings); coding != "" && len(page) >= minCompressSize {
+		encoded, err := encode(coding, page)
+		if err != nil {
+			h.logger.Warn("Failed to compress page", "encoding", coding, "error", err)
+		} else {
+			header.Set("Content-Encoding", coding)
+			page = encoded
+		}
+	}
+	header.Set("Content-Length", strconv.Itoa(len(page)))
+	w.Write(page)
 }
@@ -0,0 +1,145 @@
+package handler
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rampantspark/gospidertrap/internal/content"
+	"github.com/rampantspark/gospidertrap/internal/random"
+	"github.com/rampantspark/gospidertrap/internal/stats"
+)
+
+func newTestHandler(t *testing.T) *RequestHandler {
+	t.Helper()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	wordlist := []string{"/products/spring-collection/linen-shirts", "/blog/2024/05/notes-from-the-workshop", "/about/visit-our-showroom"}
+	generator := content.NewGenerator(wordlist, "", "", random.NewSource("abcdefghijklmnopqrstuvwxyz", 0))
+	manager := stats.NewManager(stats.NewMemoryStore(stats.NewStats()), false, logger)
+	return New(generator, manager, logger, 0)
+}
+
+func TestHandle_Compression(t *testing.T) {
+	tests := []struct {
+		name         string
+		encodings    []string
+		accept       string
+		wantEncoding string
+	}{
+		{"zstd", Encodings, "gzip, deflate, br, zstd", EncodingZstd},
+		{"brotli", Encodings, "gzip, br", EncodingBrotli},
+		{"gzip", Encodings, "gzip", EncodingGzip},
+		{"uncompressed", Encodings, "", ""},
+		{"disabled", nil, "gzip, br, zstd", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newTestHandler(t)
+			h.SetCompression(tt.encodings)
+			r := httptest.NewRequest(http.MethodGet, "/products/spring", nil)
+			r.Header.Set("Accept-Encoding", tt.accept)
+			w := httptest.NewRecorder()
+			h.Handle(w, r)
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d, want %d", w.Code, http.StatusOK)
+			}
+			header := w.Header()
+			if got := header.Get("Content-Type"); got != "text/html; charset=utf-8" {
+				t.Errorf("Content-Type = %q", got)
+			}
+			if got := header.Get("Content-Encoding"); got != tt.wantEncoding {
+				t.Errorf("Content-Encoding = %q, want %q", got, tt.wantEncoding)
+			}
+			wantVary := ""
+			if tt.encodings != nil {
+				wantVary = "Accept-Encoding"
+			}
+			if got := header.Get("Vary"); got != wantVary {
+				t.Errorf("Vary = %q, want %q", got, wantVary)
+			}
+			if got := header.Get("Content-Length"); got != strconv.Itoa(w.Body.Len()) {
+				t.Errorf("Content-Length = %q, body is %d bytes", got, w.Body.Len())
+			}
+			if page := decode(t, tt.wantEncoding, w.Body.Bytes()); !strings.HasPrefix(string(page), "<html>") {
+				t.Errorf("page starts %q", page[:min(len(page), 32)])
+			}
+		})
+	}
+}
+
+func TestHandle_ConditionalGet(t *testing.T) {
+	h := newTestHandler(t)
+	get := func(path string, header http.Header) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, path, nil)
+		for name, values := range header {
+			r.Header[name] = values
+		}
+		w := httptest.NewRecorder()
+		h.Handle(w, r)
+		return w
+	}
+
+	first := get("/blog/hello-world", nil)
+	etag, lastModified := first.Header().Get("ETag"), first.Header().Get("Last-Modified")
+	if !strings.HasPrefix(etag, `W/"`) || lastModified == "" {
+		t.Fatalf("validators = %q, %q", etag, lastModified)
+	}
+	modified, err := http.ParseTime(lastModified)
+	if err != nil {
+		t.Fatalf("Last-Modified %q: %v", lastModified, err)
+	}
+	if age := time.Since(modified); age < 0 || age > maxPageAge+time.Minute {
+		t.Errorf("Last-Modified is %s old", age)
+	}
+
+	// The same URL keeps its validators though its page is regenerated
+	again := get("/blog/hello-world", nil)
+	if again.Header().Get("ETag") != etag || again.Header().Get("Last-Modified") != lastModified {
+		t.Error("validators changed between requests for the same URL")
+	}
+	if other := get("/blog/other-post", nil); other.Header().Get("ETag") == etag {
+		t.Error("different URLs share an ETag")
+	}
+
+	tests := []struct {
+		name       string
+		header     http.Header
+		wantStatus int
+	}{
+		{"matching etag", http.Header{"If-None-Match": {etag}}, http.StatusNotModified},
+		{"strong form of etag", http.Header{"If-None-Match": {strings.TrimPrefix(etag, "W/")}}, http.StatusNotModified},
+		{"etag in list", http.Header{"If-None-Match": {`"other", ` + etag}}, http.StatusNotModified},
+		{"wildcard", http.Header{"If-None-Match": {"*"}}, http.StatusNotModified},
+		{"stale etag", http.Header{"If-None-Match": {`W/"0000000000000000"`}}, http.StatusOK},
+		{"stale etag overrides date", http.Header{"If-None-Match": {`W/"0"`}, "If-Modified-Since": {lastModified}}, http.StatusOK},
+		{"not modified since", http.Header{"If-Modified-Since": {lastModified}}, http.StatusNotModified},
+		{"modified since", http.Header{"If-Modified-Since": {modified.Add(-time.Second).Format(http.TimeFormat)}}, http.StatusOK},
+		{"bad date", http.Header{"If-Modified-Since": {"yesterday"}}, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := get("/blog/hello-world", tt.header)
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			if tt.wantStatus == http.StatusNotModified && (w.Body.Len() != 0 || w.Header().Get("ETag") != etag) {
+				t.Errorf("304 has a %d byte body and ETag %q", w.Body.Len(), w.Header().Get("ETag"))
+			}
+		})
+	}
+
+	// Form submissions are never answered with 304
+	r := httptest.NewRequest(http.MethodPost, "/blog/hello-world", strings.NewReader("q=x"))
+	r.Header.Set("If-None-Match", "*")
+	w := httptest.NewRecorder()
+	h.Handle(w, r)
+	if w.Code != http.StatusOK {
+		t.Errorf("POST status = %d, want %d", w.Code, http.StatusOK)
+	}
+}
@@ -0,0 +1,307 @@
+// Package honeytoken captures trap form submissions and issues unique fake
+// credentials, honeytokens, that trap forms are prefilled with.
+//
+// Every page gets fresh values, recorded with the IP they were issued to.
+// A client submitting its own honeytokens shows that it fills in forms. A
+// different IP submitting them shows that the harvested values were passed
+// on or replayed, which raises an alert.
+package honeytoken
+
+import (
+	"context"
+	"crypto/rand"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/rampantspark/gospidertrap/internal/content"
+	"github.com/rampantspark/gospidertrap/internal/stats"
+)
+
+// DefaultDomain is the domain of honeytoken email addresses.
+const DefaultDomain = "example.com"
+
+const (
+	// maxLookups limits the submitted values looked up as honeytokens.
+	maxLookups = 100
+	// minTokenLength and maxTokenLength bound the length of submitted
+	// values worth looking up; every honeytoken is within them.
+	minTokenLength = 16
+	maxTokenLength = 256
+)
+
+// Alphabets of the random parts of honeytokens.
+const (
+	lowerAlphabet = "abcdefghijklmnopqrstuvwxyz0123456789"
+	mixedAlphabet = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789"
+)
+
+// names are the local parts honeytoken email addresses start with.
+var names = []string{"admin", "billing", "info", "j.smith", "m.garcia", "ops", "sales", "support", "webmaster"}
+
+// Config holds tracker settings.
+type Config struct {
+	Issue  bool   // Prefill trap forms with honeytokens
+	Domain string // Domain of honeytoken email addresses
+}
+
+// Use is a honeytoken submitted by an IP other than the one it was issued to.
+type Use struct {
+	Token      stats.Honeytoken     // The honeytoken, with the IP it was issued to
+	Submission stats.FormSubmission // The request that submitted it
+}
+
+// UseHook is called when a honeytoken is submitted by a different IP.
+type UseHook func(Use)
+
+// Tracker captures form submissions and issues honeytokens.
+//
+// Tracker is safe for concurrent use once serving has started.
+type Tracker struct {
+	config Config
+	store  stats.FormStore
+	logger *slog.Logger
+	now    func() time.Time
+	hooks  []UseHook
+}
+
+// NewTracker creates a tracker.
+//
+// Parameters:
+//   - config: tracker settings; an empty domain uses DefaultDomain
+//   - store: where honeytokens and submissions are stored and looked up
+//   - logger: structured logger instance
+//
+// Returns a new Tracker instance.
+func NewTracker(config Config, store stats.FormStore, logger *slog.Logger) *Tracker {
+	if config.Domain == "" {
+		config.Domain = DefaultDomain
+	}
+	return &Tracker{
+		config: config,
+		store:  store,
+		logger: logger,
+		now:    time.Now,
+	}
+}
+
+// AddUseHook registers a function called when a honeytoken is submitted by
+// an IP other than the one it was issued to. Hooks must be added before
+// serving.
+//
+// Parameters:
+//   - hook: the function to call
+func (t *Tracker) AddUseHook(hook UseHook) {
+	t.hooks = append(t.hooks, hook)
+}
+
+// Prefill returns a content.Prefill that fills a form's honeytoken fields
+// with new values issued to ip.
+//
+// Parameters:
+//   - ctx: context for cancellation and timeout control
+//   - ip: the client the page is generated for
+//
+// Returns the prefill function, or nil if honeytokens are not issued.
+func (t *Tracker) Prefill(ctx context.Context, ip string) content.Prefill {
+	if !t.config.Issue {
+		return nil
+	}
+	return func(form content.Form) map[string]string {
+		values := make(map[string]string)
+		var tokens []stats.Honeytoken
+		for _, field := range form.Fields {
+			if field.Token == "" {
+				continue
+			}
+			value := Generate(field.Token, t.config.Domain)
+			values[field.Name] = value
+			tokens = append(tokens, stats.Honeytoken{
+				Value:    value,
+				Kind:     field.Token,
+				IP:       ip,
+				Form:     string(form.Kind),
+				IssuedAt: t.now(),
+			})
+		}
+		if err := t.store.SaveHoneytokens(ctx, tokens); err != nil {
+			t.logger.Warn("Failed to save honeytokens", "ip", ip, "error", err)
+		}
+		return values
+	}
+}
+
+// Issue returns a new honeytoken issued to ip outside of a trap form, such
+// as in a scanner bait, and stores it so that its later use is detected.
+// Unlike Prefill, Issue does not depend on Config.Issue.
+//
+// Parameters:
+//   - ctx: context for cancellation and timeout control
+//   - ip: the client the honeytoken is issued to
+//   - source: where the honeytoken appears, stored in place of a form kind
+//   - kind: the kind of honeytoken, one of the content.Token* constants
+//
+// Returns the honeytoken.
+func (t *Tracker) Issue(ctx context.Context, ip, source, kind string) string {
+	value := Generate(kind, t.config.Domain)
+	token := stats.Honeytoken{Value: value, Kind: kind, IP: ip, Form: source, IssuedAt: t.now()}
+	if err := t.store.SaveHoneytokens(ctx, []stats.Honeytoken{token}); err != nil {
+		t.logger.Warn("Failed to save honeytokens", "ip", ip, "error", err)
+	}
+	return value
+}
+
+// Generate returns a new honeytoken value without storing it.
+//
+// Parameters:
+//   - kind: the kind of honeytoken, one of the content.Token* constants;
+//     unknown kinds generate a password
+//   - domain: domain of email addresses
+//
+// Returns the value.
+func Generate(kind, domain string) string {
+	switch kind {
+	case content.TokenEmail:
+		return names[randomIndex(len(names))] + "." + randomString(lowerAlphabet, 12) + "@" + domain
+	case content.TokenAPIKey:
+		return "sk_live_" + randomString(mixedAlphabet, 32)
+	default:
+		return randomString(mixedAlphabet, 18)
+	}
+}
+
+// randomIndex returns a random index below n, which must be at most 256.
+func randomIndex(n int) int {
+	var b [1]byte
+	rand.Read(b[:])
+	return int(b[0]) % n
+}
+
+// randomString returns n characters chosen at random from alphabet.
+func randomString(alphabet string, n int) string {
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = alphabet[randomIndex(len(alphabet))]
+	}
+	return string(b)
+}
+
+// Capture records the query string and body of a trap request, if it has
+// either, and checks the submitted values for honeytokens.
+//
+// Form-encoded bodies are parsed into fields along with the query string;
+// other bodies are kept as text. Bodies are read up to the request body
+// limit set by the server, so a truncated body is recorded as far as it
+// was read.
+//
+// Parameters:
+//   - ctx: context for cancellation and timeout control
+//   - r: the trap request
+//   - ip: the client IP address
+//
+// Returns the recorded submission and true, or false if the request had
+// neither a query string nor a body.
+func (t *Tracker) Capture(ctx context.Context, r *http.Request, ip string) (stats.FormSubmission, bool) {
+	hasBody := r.Body != nil && r.Body != http.NoBody && r.ContentLength != 0
+	if r.URL.RawQuery == "" && !hasBody {
+		return stats.FormSubmission{}, false
+	}
+
+	sub := stats.FormSubmission{
+		Timestamp: t.now(),
+		IP:        ip,
+		UserAgent: r.Header.Get("User-Agent"),
+		Method:    r.Method,
+		Path:      r.URL.Path,
+	}
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType == "application/x-www-form-urlencoded" {
+		if err := r.ParseForm(); err != nil {
+			t.logger.Debug("Failed to parse form submission", "ip", ip, "error", err)
+		}
+		sub.Fields = r.Form
+	} else {
+		sub.Fields = r.URL.Query()
+		if hasBody {
+			body, err := io.ReadAll(r.Body)
+			if err != nil {
+				t.logger.Debug("Failed to read request body", "ip", ip, "read", len(body), "error", err)
+			}
+			sub.Body = strings.ToValidUTF8(string(body), "�")
+		}
+	}
+	if len(sub.Fields) == 0 && sub.Body == "" {
+		return stats.FormSubmission{}, false
+	}
+
+	t.checkHoneytokens(ctx, &sub)
+	if err := t.store.SaveFormSubmission(ctx, sub); err != nil {
+		t.logger.Warn("Failed to save form submission", "ip", ip, "error", err)
+	}
+	return sub, true
+}
+
+// isTokenSeparator reports whether r cannot be part of a honeytoken, so
+// that tokens can be picked out of bodies that are not form-encoded.
+func isTokenSeparator(r rune) bool {
+	return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("._@+-", r))
+}
+
+// checkHoneytokens looks up the submitted values, and the words of a body
+// that is not form-encoded, and marks the submission
+// with the honeytoken found, preferring one issued to a different IP, and
+// reports that use to the hooks.
+func (t *Tracker) checkHoneytokens(ctx context.Context, sub *stats.FormSubmission) {
+	var values []string
+	seen := make(map[string]bool)
+	add := func(v string) {
+		v = strings.TrimSpace(v)
+		if len(v) < minTokenLength || len(v) > maxTokenLength || seen[v] || len(values) >= maxLookups {
+			return
+		}
+		seen[v] = true
+		values = append(values, v)
+	}
+	for _, fieldValues := range sub.Fields {
+		for _, v := range fieldValues {
+			add(v)
+		}
+	}
+	for _, word := range strings.FieldsFunc(sub.Body, isTokenSeparator) {
+		add(word)
+	}
+	tokens, err := t.store.FindHoneytokens(ctx, values)
+	if err != nil {
+		t.logger.Warn("Failed to look up honeytokens", "ip", sub.IP, "error", err)
+		return
+	}
+
+	var match *stats.Honeytoken
+	for i := range tokens {
+		if match == nil || tokens[i].IP != sub.IP {
+			match = &tokens[i]
+		}
+		if match.IP != sub.IP {
+			break
+		}
+	}
+	if match == nil {
+		return
+	}
+	sub.Honeytoken = match.Value
+	sub.IssuedTo = match.IP
+	if !sub.Reused() {
+		t.logger.Info("Honeytoken submitted", "ip", sub.IP, "kind", match.Kind, "path", sub.Path)
+		return
+	}
+
+	t.logger.Warn("Honeytoken used by a different IP",
+		"ip", sub.IP, "issued_to", match.IP, "kind", match.Kind, "form", match.Form,
+		"issued_at", match.IssuedAt, "path", sub.Path)
+	for _, hook := range t.hooks {
+		hook(Use{Token: *match, Submission: *sub})
+	}
+}
@@ -0,0 +1,161 @@
+package honeytoken
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/rampantspark/gospidertrap/internal/content"
+	"github.com/rampantspark/gospidertrap/internal/stats"
+)
+
+func newTestTracker(t *testing.T, config Config) (*Tracker, *stats.MemoryStore) {
+	t.Helper()
+	store := stats.NewMemoryStore(stats.NewStats())
+	return NewTracker(config, store, slog.New(slog.NewTextHandler(io.Discard, nil))), store
+}
+
+func TestPrefill(t *testing.T) {
+	tracker, store := newTestTracker(t, Config{Issue: true, Domain: "trap.example"})
+	ctx := context.Background()
+
+	prefill := tracker.Prefill(ctx, "192.0.2.1")
+	first := prefill(content.Forms[content.FormContact])
+	second := prefill(content.Forms[content.FormContact])
+
+	if !strings.HasSuffix(first["email"], "@trap.example") {
+		t.Errorf("email = %q, want an address at trap.example", first["email"])
+	}
+	if !strings.HasPrefix(first["api_key"], "sk_live_") {
+		t.Errorf("api_key = %q, want an sk_live_ key", first["api_key"])
+	}
+	if _, ok := first["message"]; ok {
+		t.Error("field without a honeytoken prefilled")
+	}
+	if first["email"] == second["email"] || first["api_key"] == second["api_key"] {
+		t.Error("honeytokens repeated across pages")
+	}
+
+	found, err := store.FindHoneytokens(ctx, []string{first["email"], first["api_key"], second["email"]})
+	if err != nil {
+		t.Fatalf("FindHoneytokens() error = %v", err)
+	}
+	if len(found) != 3 {
+		t.Fatalf("FindHoneytokens() = %+v, want 3 issued tokens", found)
+	}
+	for _, token := range found {
+		if token.IP != "192.0.2.1" || token.Form != "contact" {
+			t.Errorf("token = %+v, want issued to 192.0.2.1 in the contact form", token)
+		}
+	}
+
+	disabled, _ := newTestTracker(t, Config{})
+	if disabled.Prefill(ctx, "192.0.2.1") != nil {
+		t.Error("Prefill() returned a function with honeytokens disabled")
+	}
+}
+
+func TestIssue(t *testing.T) {
+	// Issue does not depend on prefilling forms being enabled
+	tracker, store := newTestTracker(t, Config{})
+	ctx := context.Background()
+
+	value := tracker.Issue(ctx, "192.0.2.1", "env", content.TokenAPIKey)
+	if !strings.HasPrefix(value, "sk_live_") {
+		t.Errorf("Issue() = %q, want an sk_live_ key", value)
+	}
+	found, err := store.FindHoneytokens(ctx, []string{value})
+	if err != nil {
+		t.Fatalf("FindHoneytokens() error = %v", err)
+	}
+	if len(found) != 1 || found[0].IP != "192.0.2.1" || found[0].Form != "env" || found[0].Kind != content.TokenAPIKey {
+		t.Errorf("FindHoneytokens() = %+v, want the issued key", found)
+	}
+}
+
+func TestCapture(t *testing.T) {
+	tracker, store := newTestTracker(t, Config{Issue: true})
+	ctx := context.Background()
+	var uses []Use
+	tracker.AddUseHook(func(use Use) { uses = append(uses, use) })
+
+	values := tracker.Prefill(ctx, "192.0.2.1")(content.Forms[content.FormLogin])
+	email, password := values["email"], values["password"]
+
+	tests := []struct {
+		name           string
+		method         string
+		target         string
+		contentType    string
+		body           string
+		ip             string
+		wantCaptured   bool
+		wantField      string
+		wantBody       string
+		wantHoneytoken string
+		wantUses       int
+	}{
+		{"plain GET", "GET", "/page", "", "", "192.0.2.9", false, "", "", "", 0},
+		{"query string", "GET", "/search?q=admin", "", "", "192.0.2.9", true, "q", "", "", 0},
+		{"form body", "POST", "/login", "application/x-www-form-urlencoded", "email=x%40y.z&remember=1", "192.0.2.9", true, "remember", "", "", 0},
+		{"own honeytoken", "POST", "/login", "application/x-www-form-urlencoded", "email=" + email, "192.0.2.1", true, "email", "", email, 0},
+		{"reused honeytoken", "POST", "/login?next=/", "application/x-www-form-urlencoded; charset=utf-8", "password=" + password, "198.51.100.7", true, "next", "", password, 1},
+		{"honeytoken in JSON body", "PUT", "/api/login", "application/json", `{"user":"` + email + `"}`, "198.51.100.8", true, "", `{"user":"` + email + `"}`, email, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var body io.Reader
+			if tt.body != "" {
+				body = strings.NewReader(tt.body)
+			}
+			r := httptest.NewRequest(tt.method, tt.target, body)
+			if tt.contentType != "" {
+				r.Header.Set("Content-Type", tt.contentType)
+			}
+
+			sub, captured := tracker.Capture(ctx, r, tt.ip)
+			if captured != tt.wantCaptured {
+				t.Fatalf("Capture() captured = %v, want %v", captured, tt.wantCaptured)
+			}
+			if !captured {
+				return
+			}
+			if tt.wantField != "" && sub.Fields.Get(tt.wantField) == "" {
+				t.Errorf("Fields = %v, missing %q", sub.Fields, tt.wantField)
+			}
+			if sub.Body != tt.wantBody {
+				t.Errorf("Body = %q, want %q", sub.Body, tt.wantBody)
+			}
+			if sub.Honeytoken != tt.wantHoneytoken {
+				t.Errorf("Honeytoken = %q, want %q", sub.Honeytoken, tt.wantHoneytoken)
+			}
+			if len(uses) != tt.wantUses {
+				t.Errorf("hook called %d times, want %d", len(uses), tt.wantUses)
+			}
+		})
+	}
+
+	if uses[0].Token.IP != "192.0.2.1" || uses[0].Submission.IP != "198.51.100.7" || uses[0].Submission.Method != http.MethodPost {
+		t.Errorf("use = %+v, want the token issued to 192.0.2.1 submitted by 198.51.100.7", uses[0])
+	}
+	saved, _ := store.GetFormSubmissions(ctx, true, 10)
+	if len(saved) != 3 {
+		t.Errorf("stored %d submissions with honeytokens, want 3", len(saved))
+	}
+}
+
+func TestCaptureTruncatedBody(t *testing.T) {
+	tracker, _ := newTestTracker(t, Config{})
+	r := httptest.NewRequest("POST", "/upload", strings.NewReader(strings.Repeat("a", 100)))
+	r.Header.Set("Content-Type", "text/plain")
+	r.Body = http.MaxBytesReader(httptest.NewRecorder(), r.Body, 10)
+
+	sub, captured := tracker.Capture(context.Background(), r, "192.0.2.1")
+	if !captured || sub.Body != strings.Repeat("a", 10) {
+		t.Errorf("Capture() = %q, %v, want the first 10 bytes", sub.Body, captured)
+	}
+}
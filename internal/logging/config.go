@@ -0,0 +1,101 @@
+package logging
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+)
+
+// Format identifies a log output format.
+type Format string
+
+// Supported log formats.
+const (
+	FormatHuman Format = "human" // Human-readable lines with level markers and no timestamps
+	FormatText  Format = "text"  // logfmt-style key=value lines (slog.TextHandler)
+	FormatJSON  Format = "json"  // One JSON object per line (slog.JSONHandler)
+)
+
+// ParseFormat parses a log format name.
+//
+// Returns the format or an error if the name is unknown.
+func ParseFormat(name string) (Format, error) {
+	switch f := Format(strings.ToLower(strings.TrimSpace(name))); f {
+	case FormatHuman, FormatText, FormatJSON:
+		return f, nil
+	default:
+		return "", fmt.Errorf("unknown log format: %s (expected human, text or json)", name)
+	}
+}
+
+// ParseLevel parses a log level name (debug, info, warn or error).
+//
+// Returns the level or an error if the name is unknown.
+func ParseLevel(name string) (slog.Level, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(strings.TrimSpace(name))); err != nil {
+		return 0, fmt.Errorf("unknown log level: %s (expected debug, info, warn or error)", name)
+	}
+	return level, nil
+}
+
+// NewHandler creates a log handler for the given format and level.
+//
+// The human format omits timestamps and replaces levels with short markers
+// for interactive use. The text and JSON formats keep timestamps so logs
+// can be ingested by log collectors.
+//
+// Parameters:
+//   - w: destination for log output
+//   - format: output format
+//   - level: minimum level to log
+//
+// Returns the configured handler.
+func NewHandler(w io.Writer, format Format, level slog.Leveler) slog.Handler {
+	switch format {
+	case FormatJSON:
+		return slog.NewJSONHandler(w, &slog.HandlerOptions{Level: level})
+	case FormatText:
+		return slog.NewTextHandler(w, &slog.HandlerOptions{Level: level})
+	default:
+		return NewHumanReadableHandler(w, &slog.HandlerOptions{
+			Level:       level,
+			ReplaceAttr: humanReplaceAttr,
+		})
+	}
+}
+
+// humanReplaceAttr removes timestamps and replaces levels with short markers.
+func humanReplaceAttr(groups []string, a slog.Attr) slog.Attr {
+	// Built-in attributes are only passed with no open groups
+	if len(groups) > 0 {
+		return a
+	}
+	// Remove time attribute
+	if a.Key == slog.TimeKey {
+		return slog.Attr{}
+	}
+	// Replace level attribute with custom values
+	if a.Key == slog.LevelKey {
+		level, ok := a.Value.Any().(slog.Level)
+		if !ok {
+			return a
+		}
+		var levelStr string
+		switch level {
+		case slog.LevelDebug:
+			levelStr = "[%]"
+		case slog.LevelInfo:
+			levelStr = "[*]"
+		case slog.LevelWarn:
+			levelStr = "[?]"
+		case slog.LevelError:
+			levelStr = "[!]"
+		default:
+			levelStr = level.String()
+		}
+		return slog.Attr{Key: slog.LevelKey, Value: slog.StringValue(levelStr)}
+	}
+	return a
+}
@@ -7,12 +7,20 @@ import (
 	"io"
 	"log/slog"
 	"strings"
+	"sync"
 )
 
 // HumanReadableHandler is a custom slog handler that formats logs in a human-readable way.
+//
+// Attributes added with WithAttrs and groups opened with WithGroup are
+// carried by the returned child handler; grouped keys are written with
+// dot-separated prefixes (for example "db.path=stats.db").
 type HumanReadableHandler struct {
 	writer io.Writer
+	mu     *sync.Mutex // Shared by child handlers so lines are not interleaved
 	opts   slog.HandlerOptions
+	attrs  []slog.Attr // Pre-formatted attributes, already qualified by group
+	groups []string    // Open groups for attributes added later
 }
 
 // NewHumanReadableHandler creates a new human-readable log handler.
@@ -22,13 +30,18 @@ func NewHumanReadableHandler(w io.Writer, opts *slog.HandlerOptions) *HumanReada
 	}
 	return &HumanReadableHandler{
 		writer: w,
+		mu:     &sync.Mutex{},
 		opts:   *opts,
 	}
 }
 
 // Enabled reports whether the handler handles records at the given level.
 func (h *HumanReadableHandler) Enabled(ctx context.Context, level slog.Level) bool {
-	return level >= h.opts.Level.Level()
+	minLevel := slog.LevelInfo
+	if h.opts.Level != nil {
+		minLevel = h.opts.Level.Level()
+	}
+	return level >= minLevel
 }
 
 // Handle formats and writes the log record.
@@ -40,23 +53,22 @@ func (h *HumanReadableHandler) Handle(ctx context.Context, r slog.Record) error
 		slog.Any("level", r.Level),
 		slog.String("msg", r.Message),
 	}
+	for i := range attrs {
+		attrs[i] = h.replace(nil, attrs[i])
+	}
 
-	// Add all other attributes from the record
+	// Add attributes carried by this handler, then those from the record
+	attrs = append(attrs, h.attrs...)
 	r.Attrs(func(a slog.Attr) bool {
-		attrs = append(attrs, a)
+		attrs = h.appendAttr(attrs, h.groups, a)
 		return true
 	})
 
-	// Apply ReplaceAttr to all attributes (if provided)
+	// Only include non-empty attributes (empty key means removed)
 	var filteredAttrs []slog.Attr
 	for _, a := range attrs {
-		replaced := a
-		if h.opts.ReplaceAttr != nil {
-			replaced = h.opts.ReplaceAttr(nil, a)
-		}
-		// Only include non-empty attributes (empty key means removed)
-		if replaced.Key != "" {
-			filteredAttrs = append(filteredAttrs, replaced)
+		if a.Key != "" {
+			filteredAttrs = append(filteredAttrs, a)
 		}
 	}
 
@@ -113,19 +125,71 @@ func (h *HumanReadableHandler) Handle(ctx context.Context, r slog.Record) error
 	}
 	buf.WriteString("\n")
 
+	h.mu.Lock()
+	defer h.mu.Unlock()
 	_, err := h.writer.Write([]byte(buf.String()))
 	return err
 }
 
-// WithAttrs returns a new handler with the given attributes.
+// replace applies the ReplaceAttr option (if provided) to an attribute.
+func (h *HumanReadableHandler) replace(groups []string, a slog.Attr) slog.Attr {
+	if h.opts.ReplaceAttr == nil {
+		return a
+	}
+	return h.opts.ReplaceAttr(groups, a)
+}
+
+// appendAttr resolves an attribute, applies ReplaceAttr and appends it to
+// attrs with its key qualified by the open groups. Group attributes are
+// flattened into one attribute per member.
+func (h *HumanReadableHandler) appendAttr(attrs []slog.Attr, groups []string, a slog.Attr) []slog.Attr {
+	a.Value = a.Value.Resolve()
+	if a.Value.Kind() == slog.KindGroup {
+		members := a.Value.Group()
+		if len(members) == 0 {
+			return attrs
+		}
+		// An inline group (empty key) adds its members to the current group
+		if a.Key != "" {
+			groups = append(groups[:len(groups):len(groups)], a.Key)
+		}
+		for _, member := range members {
+			attrs = h.appendAttr(attrs, groups, member)
+		}
+		return attrs
+	}
+
+	a = h.replace(groups, a)
+	if a.Key == "" {
+		return attrs
+	}
+	if len(groups) > 0 {
+		a.Key = strings.Join(groups, ".") + "." + a.Key
+	}
+	return append(attrs, a)
+}
+
+// WithAttrs returns a new handler that adds the given attributes to every
+// record, qualified by any groups opened on this handler.
 func (h *HumanReadableHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
-	// For simplicity, we'll just return the same handler
-	// In a more complex implementation, we could store these attributes
-	return h
+	if len(attrs) == 0 {
+		return h
+	}
+	child := *h
+	child.attrs = h.attrs[:len(h.attrs):len(h.attrs)]
+	for _, a := range attrs {
+		child.attrs = h.appendAttr(child.attrs, h.groups, a)
+	}
+	return &child
 }
 
-// WithGroup returns a new handler with the given group name.
+// WithGroup returns a new handler that qualifies attributes added later
+// with the given group name.
 func (h *HumanReadableHandler) WithGroup(name string) slog.Handler {
-	// For simplicity, we'll just return the same handler
-	return h
+	if name == "" {
+		return h
+	}
+	child := *h
+	child.groups = append(h.groups[:len(h.groups):len(h.groups)], name)
+	return &child
 }
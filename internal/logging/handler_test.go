@@ -0,0 +1,73 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestHumanReadableHandler_WithAttrsAndGroup(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(NewHandler(&buf, FormatHuman, slog.LevelInfo))
+
+	child := logger.With("component", "db").WithGroup("query").With("table", "ip_counts")
+	child.Info("Slow query", "ms", 12, slog.Group("args", "limit", 10))
+
+	want := "Slow query (level=[*], component=db, query.table=ip_counts, query.ms=12, query.args.limit=10)\n"
+	if got := buf.String(); got != want {
+		t.Errorf("output = %q, want %q", got, want)
+	}
+
+	// The parent logger must not inherit the child's context
+	buf.Reset()
+	logger.Info("Plain")
+	if got := buf.String(); got != "Plain (level=[*])\n" {
+		t.Errorf("parent output = %q", got)
+	}
+}
+
+func TestHumanReadableHandler_Level(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(NewHandler(&buf, FormatHuman, slog.LevelWarn))
+
+	logger.Info("hidden")
+	logger.Warn("shown")
+	if got := buf.String(); got != "shown (level=[?])\n" {
+		t.Errorf("output = %q", got)
+	}
+}
+
+func TestNewHandler_JSONKeepsTimestamp(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(NewHandler(&buf, FormatJSON, slog.LevelDebug))
+	logger.Debug("hello", "ip", "192.0.2.1")
+
+	var entry map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("output is not JSON: %v", err)
+	}
+	if entry["time"] == nil || entry["level"] != "DEBUG" || entry["ip"] != "192.0.2.1" {
+		t.Errorf("entry = %v", entry)
+	}
+}
+
+func TestParseFormatAndLevel(t *testing.T) {
+	if f, err := ParseFormat("JSON"); err != nil || f != FormatJSON {
+		t.Errorf("ParseFormat(JSON) = %q, %v", f, err)
+	}
+	if _, err := ParseFormat("xml"); err == nil {
+		t.Error("ParseFormat(xml) succeeded, want error")
+	}
+
+	tests := map[string]slog.Level{"debug": slog.LevelDebug, "INFO": slog.LevelInfo, "warn": slog.LevelWarn, "error": slog.LevelError}
+	for name, want := range tests {
+		if got, err := ParseLevel(name); err != nil || got != want {
+			t.Errorf("ParseLevel(%q) = %v, %v; want %v", name, got, err, want)
+		}
+	}
+	if _, err := ParseLevel("verbose"); err == nil || !strings.Contains(err.Error(), "verbose") {
+		t.Errorf("ParseLevel(verbose) error = %v", err)
+	}
+}
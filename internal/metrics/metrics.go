@@ -0,0 +1,62 @@
+package metrics
+
+// Route types used for the route label of the requests counter.
+const (
+	RouteTrap        = "trap"        // Generated trap pages
+	RouteAdmin       = "admin"       // Admin UI, API and exports
+	RouteMetrics     = "metrics"     // The metrics endpoint itself
+	RouteReplication = "replication" // Pushes from trap nodes to a collector
+)
+
+// Metrics holds the gospidertrap metric series.
+//
+// A nil *Metrics is not valid, but every series it holds is nil-safe, so
+// components can be handed individual series unconditionally.
+type Metrics struct {
+	Registry *Registry
+
+	Requests          *CounterVec // Requests served, by route type and status code
+	GenerationSeconds *Histogram  // Time spent generating trap pages
+	TarpitActive      *Gauge      // Connections currently held in the response delay
+	RateLimited       *Counter    // Requests rejected by the rate limiter
+	DBWriteSeconds    *Histogram  // Latency of database request writes
+	DBWriteErrors     *Counter    // Failed database request writes
+	StatsDropped      *Counter    // Requests dropped because the stats queue was full
+	LoginFailures     *Counter    // Failed admin login attempts
+	DefenseResponses  *CounterVec // Defense responses served to banned clients, by action
+	DefenseActive     *Gauge      // Defense responses currently being served
+}
+
+// New creates the gospidertrap metric series in a new registry.
+//
+// Scrape-time gauges (limiter entries, unique IPs and user agents, stats
+// queue length) are registered separately with Registry.NewGaugeFunc
+// because they read from other components.
+//
+// Returns the metrics.
+func New() *Metrics {
+	r := NewRegistry()
+	return &Metrics{
+		Registry: r,
+		Requests: r.NewCounterVec("gospidertrap_requests_total",
+			"Requests served, by route type and HTTP status code.", "route", "status"),
+		GenerationSeconds: r.NewHistogram("gospidertrap_page_generation_seconds",
+			"Time spent generating trap pages.", DefBuckets),
+		TarpitActive: r.NewGauge("gospidertrap_tarpit_active_connections",
+			"Connections currently held open by the trap response delay."),
+		RateLimited: r.NewCounter("gospidertrap_rate_limited_total",
+			"Requests rejected by the per-IP rate limiter."),
+		DBWriteSeconds: r.NewHistogram("gospidertrap_db_write_seconds",
+			"Latency of database request writes.", DefBuckets),
+		DBWriteErrors: r.NewCounter("gospidertrap_db_write_errors_total",
+			"Database request writes that failed."),
+		StatsDropped: r.NewCounter("gospidertrap_stats_dropped_total",
+			"Requests not recorded because the asynchronous stats queue was full."),
+		LoginFailures: r.NewCounter("gospidertrap_admin_login_failures_total",
+			"Failed admin login attempts."),
+		DefenseResponses: r.NewCounterVec("gospidertrap_defense_responses_total",
+			"Decompression bombs and endless streams served to banned clients, by action.", "action"),
+		DefenseActive: r.NewGauge("gospidertrap_defense_active_responses",
+			"Defense responses currently being served to banned clients."),
+	}
+}
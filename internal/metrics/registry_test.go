@@ -0,0 +1,103 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRegistry_Write(t *testing.T) {
+	r := NewRegistry()
+	requests := r.NewCounterVec("test_requests_total", "Requests served.", "route", "status")
+	active := r.NewGauge("test_active", "Active things.")
+	r.NewGaugeFunc("test_func", "Computed.", func() float64 { return 42 })
+	latency := r.NewHistogram("test_seconds", "Latency.", []float64{0.1, 1})
+
+	requests.WithLabelValues("trap", "200").Inc()
+	requests.WithLabelValues("trap", "200").Inc()
+	requests.WithLabelValues("admin", `4"0"4`).Inc()
+	active.Inc()
+	active.Inc()
+	active.Dec()
+	latency.Observe(0.05)
+	latency.Observe(0.5)
+	latency.Observe(5)
+
+	var b strings.Builder
+	if err := r.Write(&b); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+
+	want := `# HELP test_requests_total Requests served.
+# TYPE test_requests_total counter
+test_requests_total{route="admin",status="4\"0\"4"} 1
+test_requests_total{route="trap",status="200"} 2
+# HELP test_active Active things.
+# TYPE test_active gauge
+test_active 1
+# HELP test_func Computed.
+# TYPE test_func gauge
+test_func 42
+# HELP test_seconds Latency.
+# TYPE test_seconds histogram
+test_seconds_bucket{le="0.1"} 1
+test_seconds_bucket{le="1"} 2
+test_seconds_bucket{le="+Inf"} 3
+test_seconds_sum 5.55
+test_seconds_count 3
+`
+	if got := b.String(); got != want {
+		t.Errorf("Write() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestNilMetricsAreNoOps(t *testing.T) {
+	var c *Counter
+	var g *Gauge
+	var h *Histogram
+	var v *CounterVec
+	c.Inc()
+	g.Inc()
+	g.Dec()
+	h.Observe(1)
+	v.WithLabelValues("a").Inc()
+	if c.Value() != 0 || g.Value() != 0 || h.Count() != 0 {
+		t.Error("nil metrics reported non-zero values")
+	}
+}
+
+func TestRegistry_HandlerAuth(t *testing.T) {
+	r := NewRegistry()
+	r.NewCounter("test_total", "Test.").Inc()
+
+	tests := []struct {
+		name   string
+		token  string
+		header string
+		want   int
+	}{
+		{"no token configured", "", "", http.StatusOK},
+		{"missing header", "secret", "", http.StatusUnauthorized},
+		{"wrong token", "secret", "Bearer nope", http.StatusUnauthorized},
+		{"correct token", "secret", "Bearer secret", http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			rec := httptest.NewRecorder()
+			r.Handler(tt.token).ServeHTTP(rec, req)
+
+			if rec.Code != tt.want {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.want)
+			}
+			if tt.want == http.StatusOK && !strings.Contains(rec.Body.String(), "test_total 1") {
+				t.Errorf("body missing sample: %s", rec.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,57 @@
+package middleware
+
+import (
+	"net/http"
+
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/rampantspark/gospidertrap/internal/ban"
+)
+
+// BlockBanned creates a middleware that rejects requests from banned IP
+// addresses and, if networkOf is set, from banned networks. Allowlisted IPs
+// are not blocked by a network ban.
+//
+// Banned requests for which divert reports true are passed on instead, so
+// the trap handler can answer them with a defense response. divert must
+// only report true for requests routed to the trap handler; every other
+// route keeps rejecting banned clients.
+//
+// Requests for which exempt reports true are never blocked, so an operator
+// banned by an automatic rule can still reach the admin UI to revoke it.
+//
+// Parameters:
+//   - bans: the ban list to check
+//   - getIP: function to extract IP from request
+//   - networkOf: returns the network key of an IP, such as its autonomous system (can be nil)
+//   - divert: reports whether a banned request is passed on rather than rejected (can be nil)
+//   - exempt: reports whether a request bypasses the ban list (can be nil)
+//
+// Returns a middleware function that wraps an http.Handler.
+func BlockBanned(bans *ban.List, getIP func(*http.Request) string, networkOf func(ip string) string, divert func(*http.Request, ban.Ban) bool, exempt func(*http.Request) bool) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ip := getIP(r)
+			b, banned := bans.Get(ip)
+			if !banned && networkOf != nil && bans.Count() > 0 && !bans.IsAllowed(ip) {
+				if network := networkOf(ip); network != ip {
+					b, banned = bans.Get(network)
+				}
+			}
+			if banned && exempt != nil && exempt(r) {
+				trace.SpanFromContext(r.Context()).AddEvent("banned request exempted")
+				banned = false
+			}
+			if banned && divert != nil && divert(r, b) {
+				trace.SpanFromContext(r.Context()).AddEvent("request diverted to defense response")
+				banned = false
+			}
+			if banned {
+				trace.SpanFromContext(r.Context()).AddEvent("request blocked by ban list")
+				http.Error(w, "Forbidden", http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
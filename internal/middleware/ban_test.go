@@ -0,0 +1,77 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rampantspark/gospidertrap/internal/ban"
+)
+
+// newBanTestHandler wraps a mux with an admin area, metrics and a trap
+// handler in BlockBanned, diverting known-scraper bans on trap routes and
+// exempting admin requests with the token the way the server does.
+func newBanTestHandler(t *testing.T) (http.Handler, *ban.List) {
+	t.Helper()
+	bans := ban.NewList()
+	t.Cleanup(bans.Stop)
+
+	mux := http.NewServeMux()
+	for _, pattern := range []string{"/admin/", "/metrics", "/"} {
+		mux.HandleFunc(pattern, func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		})
+	}
+	trap := func(r *http.Request) bool {
+		return !strings.HasPrefix(r.URL.Path, "/admin") && r.URL.Path != "/metrics"
+	}
+	divert := func(r *http.Request, b ban.Ban) bool {
+		return trap(r) && b.Rule == "known-scraper"
+	}
+	exempt := func(r *http.Request) bool {
+		return strings.HasPrefix(r.URL.Path, "/admin") && r.Header.Get("Authorization") == "Bearer secret"
+	}
+	getIP := func(r *http.Request) string { return r.Header.Get("X-Test-IP") }
+	return BlockBanned(bans, getIP, nil, divert, exempt)(mux), bans
+}
+
+func TestBlockBanned(t *testing.T) {
+	handler, bans := newBanTestHandler(t)
+	bans.Add("192.0.2.1", "known-scraper", "scraper user agent", time.Hour)
+	bans.Add("192.0.2.2", "trap-hits", "too many hits", time.Hour)
+
+	tests := []struct {
+		name       string
+		ip         string
+		path       string
+		token      string
+		wantStatus int
+	}{
+		{"diverted on trap page", "192.0.2.1", "/products/spring", "", http.StatusOK},
+		{"diverted on admin", "192.0.2.1", "/admin/login", "", http.StatusForbidden},
+		{"diverted on admin export", "192.0.2.1", "/admin/export/nftables", "", http.StatusForbidden},
+		{"diverted on metrics", "192.0.2.1", "/metrics", "", http.StatusForbidden},
+		{"blocked on trap page", "192.0.2.2", "/products/spring", "", http.StatusForbidden},
+		{"not banned", "192.0.2.3", "/admin/login", "", http.StatusOK},
+		{"banned admin with token", "192.0.2.2", "/admin/bans/revoke", "secret", http.StatusOK},
+		{"diverted admin with token", "192.0.2.1", "/admin/", "secret", http.StatusOK},
+		{"banned admin with wrong token", "192.0.2.2", "/admin/bans/revoke", "guess", http.StatusForbidden},
+		{"token outside admin", "192.0.2.2", "/metrics", "secret", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			r.Header.Set("X-Test-IP", tt.ip)
+			if tt.token != "" {
+				r.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+		})
+	}
+}
@@ -13,10 +13,22 @@ import (
 //
 // Returns a middleware function that wraps an http.Handler.
 func LimitRequestBody(maxBytes int64) func(http.Handler) http.Handler {
+	return LimitRequestBodyFunc(func(*http.Request) int64 { return maxBytes })
+}
+
+// LimitRequestBodyFunc creates a middleware that enforces a request body
+// size limit chosen per request, so that upload endpoints can accept more
+// than the rest of the server.
+//
+// Parameters:
+//   - maxBytes: returns the maximum allowed request body size in bytes for a request
+//
+// Returns a middleware function that wraps an http.Handler.
+func LimitRequestBodyFunc(maxBytes func(r *http.Request) int64) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			// Wrap the request body with a size limit
-			r.Body = http.MaxBytesReader(w, r.Body, maxBytes)
+			r.Body = http.MaxBytesReader(w, r.Body, maxBytes(r))
 			next.ServeHTTP(w, r)
 		})
 	}
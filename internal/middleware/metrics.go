@@ -0,0 +1,54 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/rampantspark/gospidertrap/internal/metrics"
+)
+
+// CountRequests creates a middleware that counts responses by route type
+// and status code.
+//
+// Parameters:
+//   - requests: counter family with route and status labels
+//   - classify: function returning the route type of a request
+//
+// Returns a middleware function that wraps an http.Handler.
+func CountRequests(requests *metrics.CounterVec, classify func(*http.Request) string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+			next.ServeHTTP(sw, r)
+			requests.WithLabelValues(classify(r), strconv.Itoa(sw.status)).Inc()
+		})
+	}
+}
+
+// statusWriter records the status code written through a ResponseWriter.
+type statusWriter struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+// WriteHeader records the status code and passes it on.
+func (w *statusWriter) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.status = status
+		w.wroteHeader = true
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// Write marks the header as written and passes the data on.
+func (w *statusWriter) Write(p []byte) (int, error) {
+	w.wroteHeader = true
+	return w.ResponseWriter.Write(p)
+}
+
+// Unwrap returns the underlying ResponseWriter so http.ResponseController
+// can reach optional interfaces such as http.Flusher.
+func (w *statusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
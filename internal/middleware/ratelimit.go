@@ -3,6 +3,8 @@ package middleware
 import (
 	"net/http"
 
+	"go.opentelemetry.io/otel/trace"
+
 	"github.com/rampantspark/gospidertrap/internal/ratelimit"
 )
 
@@ -11,13 +13,18 @@ import (
 // Parameters:
 //   - limiter: the rate limiter instance
 //   - getIP: function to extract IP from request
+//   - onLimited: optional callback invoked with the IP of each rejected request (can be nil)
 //
 // Returns a middleware function that wraps an http.Handler.
-func RateLimit(limiter *ratelimit.Limiter, getIP func(*http.Request) string) func(http.Handler) http.Handler {
+func RateLimit(limiter *ratelimit.Limiter, getIP func(*http.Request) string, onLimited func(ip string)) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			ip := getIP(r)
 			if !limiter.Allow(ip) {
+				trace.SpanFromContext(r.Context()).AddEvent("request rejected by rate limiter")
+				if onLimited != nil {
+					onLimited(ip)
+				}
 				http.Error(w, "Too Many Requests", http.StatusTooManyRequests)
 				return
 			}
@@ -0,0 +1,46 @@
+package middleware
+
+import (
+	"net/http"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/rampantspark/gospidertrap/internal/tracing"
+)
+
+// Trace creates a middleware that starts a server span for each request.
+//
+// Incoming trace context headers are ignored: every request starts a new
+// trace, since clients of a spider trap are untrusted. The span carries the
+// client IP class, path depth and response status rather than the raw IP
+// and path.
+//
+// Parameters:
+//   - getIP: function to extract IP from request
+//
+// Returns a middleware function that wraps an http.Handler.
+func Trace(getIP func(*http.Request) string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx, span := tracing.Start(r.Context(), "HTTP "+tracing.Method(r.Method),
+				trace.WithNewRoot(),
+				trace.WithSpanKind(trace.SpanKindServer),
+				trace.WithAttributes(tracing.RequestAttributes(getIP(r), r.Method, r.URL.Path)...))
+			defer span.End()
+			if !span.IsRecording() {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+			next.ServeHTTP(sw, r.WithContext(ctx))
+
+			span.SetAttributes(attribute.Int("http.response.status_code", sw.status))
+			if sw.status >= http.StatusInternalServerError {
+				span.SetStatus(codes.Error, http.StatusText(sw.status))
+			}
+		})
+	}
+}
@@ -0,0 +1,81 @@
+// Package notify delivers trap events to outbound webhooks.
+//
+// Events are queued in a bounded buffer and delivered by a background worker
+// with HMAC signing and retries with exponential backoff. Payloads can be
+// rendered as generic JSON or in Slack and Discord incoming-webhook formats.
+package notify
+
+import (
+	"fmt"
+	"time"
+)
+
+// EventType identifies the kind of event being delivered.
+type EventType string
+
+// Supported event types.
+const (
+	EventNewIP        EventType = "new_ip"
+	EventNewUserAgent EventType = "new_user_agent"
+	EventIPThreshold  EventType = "ip_threshold"
+	EventBan          EventType = "ban"
+	EventAdminLogin   EventType = "admin_login"
+	EventHoneytoken   EventType = "honeytoken"
+)
+
+// EventTypes lists every supported event type.
+var EventTypes = []EventType{EventNewIP, EventNewUserAgent, EventIPThreshold, EventBan, EventAdminLogin, EventHoneytoken}
+
+// ParseEventType parses an event type name.
+//
+// Returns the event type and true if the name is a supported event type.
+func ParseEventType(name string) (EventType, bool) {
+	for _, t := range EventTypes {
+		if string(t) == name {
+			return t, true
+		}
+	}
+	return "", false
+}
+
+// Event is a single notification.
+type Event struct {
+	Type      EventType `json:"type"`                // Kind of event
+	Time      time.Time `json:"time"`                // When the event happened
+	IP        string    `json:"ip,omitempty"`        // Client IP address
+	Network   string    `json:"network,omitempty"`   // Banned autonomous system, e.g. "AS64496" (ban events for networks)
+	UserAgent string    `json:"userAgent,omitempty"` // Client User-Agent header
+	Path      string    `json:"path,omitempty"`      // Requested path
+	Count     int       `json:"count,omitempty"`     // Request count (ip_threshold events)
+	Rule      string    `json:"rule,omitempty"`      // Ban rule (ban events)
+	Reason    string    `json:"reason,omitempty"`    // Ban reason (ban events)
+	Success   *bool     `json:"success,omitempty"`   // Login outcome (admin_login events)
+	IssuedTo  string    `json:"issuedTo,omitempty"`  // IP the honeytoken was issued to (honeytoken events)
+}
+
+// Summary returns a one-line human-readable description of the event,
+// used for chat-style payloads.
+func (e Event) Summary() string {
+	switch e.Type {
+	case EventNewIP:
+		return fmt.Sprintf("New IP seen: %s requested %s (%s)", e.IP, e.Path, e.UserAgent)
+	case EventNewUserAgent:
+		return fmt.Sprintf("New user agent seen: %q from %s", e.UserAgent, e.IP)
+	case EventIPThreshold:
+		return fmt.Sprintf("IP %s crossed %d requests", e.IP, e.Count)
+	case EventBan:
+		if e.Network != "" {
+			return fmt.Sprintf("Network %s banned by rule %s: %s", e.Network, e.Rule, e.Reason)
+		}
+		return fmt.Sprintf("IP %s banned by rule %s: %s", e.IP, e.Rule, e.Reason)
+	case EventAdminLogin:
+		if e.Success != nil && !*e.Success {
+			return fmt.Sprintf("Failed admin login from %s (%s)", e.IP, e.UserAgent)
+		}
+		return fmt.Sprintf("Admin login from %s (%s)", e.IP, e.UserAgent)
+	case EventHoneytoken:
+		return fmt.Sprintf("Honeytoken issued to %s was submitted by %s to %s", e.IssuedTo, e.IP, e.Path)
+	default:
+		return string(e.Type)
+	}
+}
@@ -0,0 +1,71 @@
+package notify
+
+import (
+	"net/http"
+
+	"github.com/rampantspark/gospidertrap/internal/ban"
+	"github.com/rampantspark/gospidertrap/internal/geoip"
+	"github.com/rampantspark/gospidertrap/internal/honeytoken"
+	"github.com/rampantspark/gospidertrap/internal/stats"
+)
+
+// RecordHook returns a stats record hook that emits new_ip, new_user_agent
+// and ip_threshold events.
+//
+// Parameters:
+//   - thresholds: request counts at which an ip_threshold event is emitted
+//
+// Returns a hook for stats.Manager.AddRecordHook.
+func (n *Notifier) RecordHook(thresholds []int) stats.RecordHook {
+	crossed := make(map[int]bool, len(thresholds))
+	for _, t := range thresholds {
+		crossed[t] = true
+	}
+
+	return func(req stats.RequestInfo, result stats.RecordResult) {
+		if result.IPCount == 1 {
+			n.Notify(Event{Type: EventNewIP, Time: req.Timestamp, IP: req.IP, UserAgent: req.UserAgent, Path: req.Path})
+		}
+		if result.UserAgentCount == 1 {
+			n.Notify(Event{Type: EventNewUserAgent, Time: req.Timestamp, IP: req.IP, UserAgent: req.UserAgent, Path: req.Path})
+		}
+		if crossed[result.IPCount] {
+			n.Notify(Event{Type: EventIPThreshold, Time: req.Timestamp, IP: req.IP, UserAgent: req.UserAgent, Count: result.IPCount})
+		}
+	}
+}
+
+// BanHook returns a ban list hook that emits ban events. Bans on an
+// autonomous system carry its key in Network instead of IP.
+func (n *Notifier) BanHook() func(ban.Ban) {
+	return func(b ban.Ban) {
+		e := Event{Type: EventBan, Time: b.CreatedAt, Rule: b.Rule, Reason: b.Reason}
+		if _, isNetwork := geoip.ParseASNKey(b.IP); isNetwork {
+			e.Network = b.IP
+		} else {
+			e.IP = b.IP
+		}
+		n.Notify(e)
+	}
+}
+
+// LoginHook returns an admin login hook that emits admin_login events.
+//
+// Parameters:
+//   - getIP: function to extract the client IP from a request
+//
+// Returns a hook for admin.Handler.AddLoginHook.
+func (n *Notifier) LoginHook(getIP func(*http.Request) string) func(*http.Request, bool) {
+	return func(r *http.Request, success bool) {
+		n.Notify(Event{Type: EventAdminLogin, IP: getIP(r), UserAgent: r.Header.Get("User-Agent"), Success: &success})
+	}
+}
+
+// HoneytokenHook returns a honeytoken use hook that emits honeytoken
+// events.
+func (n *Notifier) HoneytokenHook() honeytoken.UseHook {
+	return func(use honeytoken.Use) {
+		sub := use.Submission
+		n.Notify(Event{Type: EventHoneytoken, Time: sub.Timestamp, IP: sub.IP, UserAgent: sub.UserAgent, Path: sub.Path, IssuedTo: use.Token.IP})
+	}
+}
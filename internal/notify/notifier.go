@@ -0,0 +1,325 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Payload formats for webhook targets.
+const (
+	FormatGeneric = "generic"
+	FormatSlack   = "slack"
+	FormatDiscord = "discord"
+)
+
+// HTTP headers set on every webhook request.
+const (
+	HeaderEvent     = "X-Gospidertrap-Event"
+	HeaderTimestamp = "X-Gospidertrap-Timestamp"
+	HeaderSignature = "X-Gospidertrap-Signature"
+)
+
+// Default delivery settings.
+const (
+	DefaultQueueSize  = 256
+	DefaultMaxRetries = 3
+	DefaultBackoff    = time.Second
+	DefaultTimeout    = 5 * time.Second
+)
+
+// Target is a single webhook destination.
+type Target struct {
+	URL    string // Webhook URL
+	Format string // Payload format: generic, slack or discord
+}
+
+// ParseTarget parses a target in the form "[format=]url".
+//
+// Without a format prefix the generic format is used.
+//
+// Returns the target or an error if the format is unknown or the URL is not
+// an http(s) URL.
+func ParseTarget(s string) (Target, error) {
+	target := Target{URL: s, Format: FormatGeneric}
+	if format, url, ok := strings.Cut(s, "="); ok && !strings.Contains(format, "/") {
+		target = Target{URL: url, Format: format}
+	}
+
+	switch target.Format {
+	case FormatGeneric, FormatSlack, FormatDiscord:
+	default:
+		return Target{}, fmt.Errorf("unknown webhook format: %s", target.Format)
+	}
+	if !strings.HasPrefix(target.URL, "http://") && !strings.HasPrefix(target.URL, "https://") {
+		return Target{}, fmt.Errorf("webhook URL must use http or https: %s", target.URL)
+	}
+	return target, nil
+}
+
+// Config holds notifier settings.
+type Config struct {
+	Targets    []Target      // Webhook destinations
+	Secret     string        // HMAC-SHA256 signing secret (empty disables signing)
+	Events     []EventType   // Event types to deliver (empty delivers all)
+	QueueSize  int           // Maximum number of queued events
+	MaxRetries int           // Retries per target after the first attempt
+	Backoff    time.Duration // Delay before the first retry, doubled for each further retry
+	Timeout    time.Duration // Timeout for each HTTP request
+}
+
+// Notifier queues events and delivers them to webhook targets.
+//
+// Notifier is safe for concurrent use.
+type Notifier struct {
+	config   Config
+	events   map[EventType]bool
+	client   *http.Client
+	queue    chan Event
+	logger   *slog.Logger
+	dropped  atomic.Int64
+	failed   atomic.Int64
+	stopChan chan struct{}
+	stopOnce sync.Once
+	done     chan struct{}
+}
+
+// New creates a notifier and starts its delivery worker.
+//
+// Zero values in config are replaced with the package defaults.
+//
+// Parameters:
+//   - config: notifier settings
+//   - logger: structured logger instance
+//
+// Returns a new Notifier instance. Call Stop when it is no longer needed.
+func New(config Config, logger *slog.Logger) *Notifier {
+	if config.QueueSize <= 0 {
+		config.QueueSize = DefaultQueueSize
+	}
+	if config.MaxRetries < 0 {
+		config.MaxRetries = 0
+	}
+	if config.Backoff <= 0 {
+		config.Backoff = DefaultBackoff
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = DefaultTimeout
+	}
+
+	events := make(map[EventType]bool)
+	for _, t := range config.Events {
+		events[t] = true
+	}
+
+	n := &Notifier{
+		config:   config,
+		events:   events,
+		client:   &http.Client{Timeout: config.Timeout},
+		queue:    make(chan Event, config.QueueSize),
+		logger:   logger,
+		stopChan: make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	go n.run()
+	return n
+}
+
+// Enabled reports whether events of the given type are delivered.
+func (n *Notifier) Enabled(t EventType) bool {
+	if n == nil || len(n.config.Targets) == 0 {
+		return false
+	}
+	return len(n.events) == 0 || n.events[t]
+}
+
+// Notify queues an event for delivery without blocking.
+//
+// Events of disabled types are ignored. If the queue is full the event is
+// dropped and counted.
+//
+// Parameters:
+//   - e: the event to deliver (a zero Time is set to now)
+//
+// Returns true if the event was queued.
+func (n *Notifier) Notify(e Event) bool {
+	if !n.Enabled(e.Type) {
+		return false
+	}
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+
+	select {
+	case <-n.stopChan:
+		return false
+	default:
+	}
+
+	select {
+	case n.queue <- e:
+		return true
+	default:
+		if n.dropped.Add(1) == 1 || n.dropped.Load()%100 == 0 {
+			n.logger.Warn("Webhook queue full, dropping events", "dropped", n.dropped.Load())
+		}
+		return false
+	}
+}
+
+// Dropped returns the number of events dropped because the queue was full.
+func (n *Notifier) Dropped() int64 {
+	return n.dropped.Load()
+}
+
+// Failed returns the number of deliveries that failed after all retries.
+func (n *Notifier) Failed() int64 {
+	return n.failed.Load()
+}
+
+// run delivers queued events until the notifier is stopped.
+func (n *Notifier) run() {
+	defer close(n.done)
+	for {
+		select {
+		case e := <-n.queue:
+			for _, target := range n.config.Targets {
+				n.deliver(target, e)
+			}
+		case <-n.stopChan:
+			if remaining := len(n.queue); remaining > 0 {
+				n.logger.Debug("Discarding queued webhook events on shutdown", "count", remaining)
+			}
+			return
+		}
+	}
+}
+
+// deliver sends an event to a target, retrying with exponential backoff on
+// network errors, 429 and 5xx responses.
+func (n *Notifier) deliver(target Target, e Event) {
+	body, err := renderPayload(target.Format, e)
+	if err != nil {
+		n.logger.Warn("Failed to render webhook payload", "format", target.Format, "error", err)
+		n.failed.Add(1)
+		return
+	}
+
+	backoff := n.config.Backoff
+	for attempt := 0; ; attempt++ {
+		retry, err := n.send(target, e, body)
+		if err == nil {
+			return
+		}
+		if !retry || attempt >= n.config.MaxRetries {
+			n.logger.Warn("Webhook delivery failed",
+				"url", target.URL,
+				"event", e.Type,
+				"attempts", attempt+1,
+				"error", err)
+			n.failed.Add(1)
+			return
+		}
+
+		select {
+		case <-time.After(backoff):
+			backoff *= 2
+		case <-n.stopChan:
+			n.failed.Add(1)
+			return
+		}
+	}
+}
+
+// send performs a single webhook request.
+//
+// Returns whether the request may be retried and any error.
+func (n *Notifier) send(target Target, e Event, body []byte) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), n.config.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "gospidertrap-webhook")
+	req.Header.Set(HeaderEvent, string(e.Type))
+	req.Header.Set(HeaderTimestamp, timestamp)
+	if n.config.Secret != "" {
+		req.Header.Set(HeaderSignature, Sign(n.config.Secret, timestamp, body))
+	}
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	resp.Body.Close()
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return false, nil
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return true, fmt.Errorf("unexpected status: %s", resp.Status)
+	default:
+		return false, fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+}
+
+// Sign computes the signature header value for a webhook body.
+//
+// The signature is the hex-encoded HMAC-SHA256 of the timestamp header value,
+// a period and the raw request body, prefixed with "sha256=". Receivers should
+// recompute it and reject requests with stale timestamps.
+//
+// Parameters:
+//   - secret: the shared signing secret
+//   - timestamp: the X-Gospidertrap-Timestamp header value
+//   - body: the raw request body
+//
+// Returns the signature header value.
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// renderPayload renders an event in the given payload format.
+func renderPayload(format string, e Event) ([]byte, error) {
+	switch format {
+	case FormatSlack:
+		return json.Marshal(map[string]string{"text": "gospidertrap: " + e.Summary()})
+	case FormatDiscord:
+		return json.Marshal(map[string]string{"content": "gospidertrap: " + e.Summary()})
+	default:
+		return json.Marshal(e)
+	}
+}
+
+// Stop stops the delivery worker and waits for it to exit.
+//
+// The event being delivered is finished without further retries; events
+// still queued are discarded. Safe to call multiple times.
+func (n *Notifier) Stop() {
+	n.stopOnce.Do(func() {
+		close(n.stopChan)
+	})
+	<-n.done
+}
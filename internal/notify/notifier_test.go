@@ -0,0 +1,326 @@
+package notify
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/rampantspark/gospidertrap/internal/ban"
+	"github.com/rampantspark/gospidertrap/internal/honeytoken"
+	"github.com/rampantspark/gospidertrap/internal/stats"
+)
+
+// receiver is a webhook endpoint that records the requests it receives.
+type receiver struct {
+	mu       sync.Mutex
+	bodies   [][]byte
+	headers  []http.Header
+	failures atomic.Int32 // Number of requests to answer with 500 before succeeding
+	received chan struct{}
+}
+
+func newReceiver(t *testing.T) (*receiver, *httptest.Server) {
+	t.Helper()
+	rcv := &receiver{received: make(chan struct{}, 100)}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if rcv.failures.Add(-1) >= 0 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		rcv.mu.Lock()
+		rcv.bodies = append(rcv.bodies, body)
+		rcv.headers = append(rcv.headers, r.Header.Clone())
+		rcv.mu.Unlock()
+		rcv.received <- struct{}{}
+	}))
+	t.Cleanup(srv.Close)
+	return rcv, srv
+}
+
+func (rcv *receiver) wait(t *testing.T, n int) {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		select {
+		case <-rcv.received:
+		case <-time.After(2 * time.Second):
+			t.Fatalf("timed out waiting for webhook %d of %d", i+1, n)
+		}
+	}
+}
+
+func testLogger() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, nil))
+}
+
+func TestNotifier_GenericPayloadAndSignature(t *testing.T) {
+	rcv, srv := newReceiver(t)
+	n := New(Config{
+		Targets: []Target{{URL: srv.URL, Format: FormatGeneric}},
+		Secret:  "s3cret",
+	}, testLogger())
+	defer n.Stop()
+
+	if !n.Notify(Event{Type: EventBan, IP: "192.0.2.1", Rule: "trap-hits", Reason: "too many"}) {
+		t.Fatal("Notify() returned false")
+	}
+	rcv.wait(t, 1)
+
+	rcv.mu.Lock()
+	defer rcv.mu.Unlock()
+
+	var got Event
+	if err := json.Unmarshal(rcv.bodies[0], &got); err != nil {
+		t.Fatalf("payload is not valid JSON: %v", err)
+	}
+	if got.Type != EventBan || got.IP != "192.0.2.1" || got.Rule != "trap-hits" {
+		t.Errorf("payload = %+v", got)
+	}
+	if got.Time.IsZero() {
+		t.Error("payload time is zero")
+	}
+
+	h := rcv.headers[0]
+	if h.Get(HeaderEvent) != string(EventBan) {
+		t.Errorf("%s = %q, want %q", HeaderEvent, h.Get(HeaderEvent), EventBan)
+	}
+	want := Sign("s3cret", h.Get(HeaderTimestamp), rcv.bodies[0])
+	if h.Get(HeaderSignature) != want {
+		t.Errorf("%s = %q, want %q", HeaderSignature, h.Get(HeaderSignature), want)
+	}
+}
+
+func TestNotifier_ChatFormats(t *testing.T) {
+	tests := []struct {
+		format string
+		key    string
+	}{
+		{FormatSlack, "text"},
+		{FormatDiscord, "content"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			rcv, srv := newReceiver(t)
+			n := New(Config{Targets: []Target{{URL: srv.URL, Format: tt.format}}}, testLogger())
+			defer n.Stop()
+
+			n.Notify(Event{Type: EventNewIP, IP: "192.0.2.1", Path: "/x", UserAgent: "curl/8.0"})
+			rcv.wait(t, 1)
+
+			rcv.mu.Lock()
+			defer rcv.mu.Unlock()
+			var payload map[string]string
+			if err := json.Unmarshal(rcv.bodies[0], &payload); err != nil {
+				t.Fatalf("payload is not valid JSON: %v", err)
+			}
+			if payload[tt.key] == "" {
+				t.Errorf("payload missing %q: %s", tt.key, rcv.bodies[0])
+			}
+			if rcv.headers[0].Get(HeaderSignature) != "" {
+				t.Error("signature set without a secret")
+			}
+		})
+	}
+}
+
+func TestNotifier_RetriesWithBackoff(t *testing.T) {
+	rcv, srv := newReceiver(t)
+	rcv.failures.Store(2)
+
+	n := New(Config{
+		Targets:    []Target{{URL: srv.URL, Format: FormatGeneric}},
+		MaxRetries: 3,
+		Backoff:    time.Millisecond,
+	}, testLogger())
+	defer n.Stop()
+
+	n.Notify(Event{Type: EventNewIP, IP: "192.0.2.1"})
+	rcv.wait(t, 1)
+
+	if n.Failed() != 0 {
+		t.Errorf("Failed() = %d, want 0", n.Failed())
+	}
+}
+
+func TestNotifier_GivesUpAfterMaxRetries(t *testing.T) {
+	rcv, srv := newReceiver(t)
+	rcv.failures.Store(100)
+
+	n := New(Config{
+		Targets:    []Target{{URL: srv.URL, Format: FormatGeneric}},
+		MaxRetries: 2,
+		Backoff:    time.Millisecond,
+	}, testLogger())
+	defer n.Stop()
+
+	n.Notify(Event{Type: EventNewIP, IP: "192.0.2.1"})
+
+	deadline := time.Now().Add(2 * time.Second)
+	for n.Failed() == 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if n.Failed() != 1 {
+		t.Fatalf("Failed() = %d, want 1", n.Failed())
+	}
+	if attempts := 100 - rcv.failures.Load(); attempts != 3 {
+		t.Errorf("attempts = %d, want 3 (1 + 2 retries)", attempts)
+	}
+}
+
+func TestNotifier_BoundedQueueDrops(t *testing.T) {
+	block := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-block
+	}))
+	defer srv.Close()
+
+	n := New(Config{
+		Targets:   []Target{{URL: srv.URL, Format: FormatGeneric}},
+		QueueSize: 2,
+	}, testLogger())
+	defer n.Stop()
+	defer close(block)
+
+	// The worker takes at most one event off the queue while blocked, so at
+	// most three of these can be accepted.
+	accepted := 0
+	for i := 0; i < 10; i++ {
+		if n.Notify(Event{Type: EventNewIP}) {
+			accepted++
+		}
+	}
+	if accepted > 3 {
+		t.Errorf("accepted %d events with queue size 2", accepted)
+	}
+	if n.Dropped() != int64(10-accepted) {
+		t.Errorf("Dropped() = %d, want %d", n.Dropped(), 10-accepted)
+	}
+}
+
+func TestNotifier_EventFilter(t *testing.T) {
+	rcv, srv := newReceiver(t)
+	n := New(Config{
+		Targets: []Target{{URL: srv.URL, Format: FormatGeneric}},
+		Events:  []EventType{EventBan},
+	}, testLogger())
+	defer n.Stop()
+
+	if n.Notify(Event{Type: EventNewIP}) {
+		t.Error("Notify() queued a filtered event type")
+	}
+	if !n.Notify(Event{Type: EventBan}) {
+		t.Error("Notify() did not queue an enabled event type")
+	}
+	rcv.wait(t, 1)
+}
+
+func TestNotifier_RecordHook(t *testing.T) {
+	rcv, srv := newReceiver(t)
+	n := New(Config{Targets: []Target{{URL: srv.URL, Format: FormatGeneric}}}, testLogger())
+	defer n.Stop()
+
+	hook := n.RecordHook([]int{3})
+	req := stats.RequestInfo{IP: "192.0.2.1", UserAgent: "curl/8.0", Path: "/", Timestamp: time.Now()}
+
+	hook(req, stats.RecordResult{IPCount: 1, UserAgentCount: 1}) // new_ip + new_user_agent
+	hook(req, stats.RecordResult{IPCount: 2, UserAgentCount: 2}) // nothing
+	hook(req, stats.RecordResult{IPCount: 3, UserAgentCount: 3}) // ip_threshold
+	rcv.wait(t, 3)
+
+	rcv.mu.Lock()
+	defer rcv.mu.Unlock()
+	var types []EventType
+	for _, body := range rcv.bodies {
+		var e Event
+		json.Unmarshal(body, &e)
+		types = append(types, e.Type)
+	}
+	want := []EventType{EventNewIP, EventNewUserAgent, EventIPThreshold}
+	for i := range want {
+		if i >= len(types) || types[i] != want[i] {
+			t.Fatalf("event types = %v, want %v", types, want)
+		}
+	}
+}
+
+func TestNotifier_BanHook(t *testing.T) {
+	rcv, srv := newReceiver(t)
+	n := New(Config{Targets: []Target{{URL: srv.URL, Format: FormatGeneric}}}, testLogger())
+	defer n.Stop()
+
+	n.BanHook()(ban.Ban{IP: "192.0.2.1", Rule: "trap-hits", Reason: "too many", CreatedAt: time.Now()})
+	n.BanHook()(ban.Ban{IP: "AS64496", Rule: "trap-hits", Reason: "too many", CreatedAt: time.Now()})
+	rcv.wait(t, 2)
+
+	rcv.mu.Lock()
+	defer rcv.mu.Unlock()
+	want := []struct{ ip, network, summary string }{
+		{"192.0.2.1", "", "IP 192.0.2.1 banned by rule trap-hits: too many"},
+		{"", "AS64496", "Network AS64496 banned by rule trap-hits: too many"},
+	}
+	for i, w := range want {
+		var e Event
+		json.Unmarshal(rcv.bodies[i], &e)
+		if e.IP != w.ip || e.Network != w.network || e.Summary() != w.summary {
+			t.Errorf("event %d = %+v (%q), want ip %q, network %q", i, e, e.Summary(), w.ip, w.network)
+		}
+	}
+}
+
+func TestNotifier_HoneytokenHook(t *testing.T) {
+	rcv, srv := newReceiver(t)
+	n := New(Config{Targets: []Target{{URL: srv.URL, Format: FormatGeneric}}}, testLogger())
+	defer n.Stop()
+
+	n.HoneytokenHook()(honeytoken.Use{
+		Token:      stats.Honeytoken{Value: "ops.abc@example.com", IP: "192.0.2.1"},
+		Submission: stats.FormSubmission{IP: "198.51.100.7", Path: "/login", Timestamp: time.Now()},
+	})
+	rcv.wait(t, 1)
+
+	rcv.mu.Lock()
+	defer rcv.mu.Unlock()
+	var e Event
+	json.Unmarshal(rcv.bodies[0], &e)
+	if e.Type != EventHoneytoken || e.IP != "198.51.100.7" || e.IssuedTo != "192.0.2.1" || e.Path != "/login" {
+		t.Errorf("event = %+v, want a honeytoken event for 198.51.100.7", e)
+	}
+	if want := "Honeytoken issued to 192.0.2.1 was submitted by 198.51.100.7 to /login"; e.Summary() != want {
+		t.Errorf("Summary() = %q, want %q", e.Summary(), want)
+	}
+}
+
+func TestParseTarget(t *testing.T) {
+	tests := []struct {
+		input      string
+		wantFormat string
+		wantURL    string
+		wantErr    bool
+	}{
+		{"https://example.com/hook", FormatGeneric, "https://example.com/hook", false},
+		{"https://example.com/hook?a=b", FormatGeneric, "https://example.com/hook?a=b", false},
+		{"slack=https://hooks.slack.com/services/x", FormatSlack, "https://hooks.slack.com/services/x", false},
+		{"discord=https://discord.com/api/webhooks/x", FormatDiscord, "https://discord.com/api/webhooks/x", false},
+		{"teams=https://example.com/hook", "", "", true},
+		{"ftp://example.com/hook", "", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParseTarget(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseTarget() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && (got.Format != tt.wantFormat || got.URL != tt.wantURL) {
+				t.Errorf("ParseTarget() = %+v, want format %q url %q", got, tt.wantFormat, tt.wantURL)
+			}
+		})
+	}
+}
@@ -14,11 +14,15 @@ const (
 )
 
 // Limiter provides per-IP rate limiting.
+//
+// By default every IP has its own limit. SetKeyFunc can group IPs, such as
+// by autonomous system, so that the group shares one limit.
 type Limiter struct {
 	limiters map[string]*rate.Limiter
 	mu       sync.RWMutex
 	rate     rate.Limit
 	burst    int
+	key      func(ip string) string // Maps an IP to its limit's key (nil for per-IP limits)
 	cleanup  *time.Ticker
 	stopChan chan struct{}
 }
@@ -45,6 +49,16 @@ func NewLimiter(requestsPerSecond int, burst int) *Limiter {
 	return l
 }
 
+// SetKeyFunc makes IPs that key maps to the same key share one limit.
+//
+// SetKeyFunc must be called before the limiter is used.
+//
+// Parameters:
+//   - key: returns the key of an IP address, e.g. its autonomous system
+func (l *Limiter) SetKeyFunc(key func(ip string) string) {
+	l.key = key
+}
+
 // Allow checks if a request from the given IP should be allowed.
 //
 // Parameters:
@@ -52,6 +66,10 @@ func NewLimiter(requestsPerSecond int, burst int) *Limiter {
 //
 // Returns true if the request is allowed, false if rate limited.
 func (l *Limiter) Allow(ip string) bool {
+	if l.key != nil {
+		ip = l.key(ip)
+	}
+
 	l.mu.Lock()
 	limiter, exists := l.limiters[ip]
 	if !exists {
@@ -1,6 +1,8 @@
 package ratelimit
 
 import (
+	"fmt"
+	"strings"
 	"sync"
 	"testing"
 	"time"
@@ -65,6 +67,31 @@ func TestAllow_MultipleIPs(t *testing.T) {
 	}
 }
 
+func TestAllow_KeyFunc(t *testing.T) {
+	limiter := NewLimiter(10, 5)
+	defer limiter.Stop()
+
+	// Addresses in 10.0.0.0/8 share one limit
+	limiter.SetKeyFunc(func(ip string) string {
+		if strings.HasPrefix(ip, "10.") {
+			return "AS64496"
+		}
+		return ip
+	})
+
+	for i := 0; i < 5; i++ {
+		if !limiter.Allow(fmt.Sprintf("10.0.0.%d", i)) {
+			t.Errorf("request %d denied, should be allowed (within shared burst)", i)
+		}
+	}
+	if limiter.Allow("10.0.0.99") {
+		t.Error("request after shared burst should be denied")
+	}
+	if !limiter.Allow("192.168.1.1") {
+		t.Error("IP outside the group should have its own limit")
+	}
+}
+
 func TestAllow_RateRefill(t *testing.T) {
 	// High rate so tokens refill quickly
 	limiter := NewLimiter(100, 1)
@@ -0,0 +1,169 @@
+package replication
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"log/slog"
+	"net"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rampantspark/gospidertrap/internal/stats"
+)
+
+// MaxPushBytes is the largest push body the collector accepts.
+const MaxPushBytes = 16 << 20
+
+// DefaultStaleAfter is how long a node may go without pushing before the
+// collector reports it as stale.
+const DefaultStaleAfter = time.Minute
+
+// validNodeName matches node names accepted by the collector.
+var validNodeName = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// ValidNodeName reports whether name is accepted as a node name: 1 to 64
+// letters, digits, dots, dashes or underscores.
+func ValidNodeName(name string) bool {
+	return validNodeName.MatchString(name)
+}
+
+// NodeStatus describes a node that has pushed to the collector since it
+// started.
+type NodeStatus struct {
+	Name       string    `json:"name"`
+	Address    string    `json:"address"`             // Remote address of the last push
+	LastSeen   time.Time `json:"lastSeen"`            // Time of the last push
+	Received   int       `json:"received"`            // Requests imported from the node
+	Duplicates int       `json:"duplicates"`          // Requests resent after an unacknowledged push
+	Backlog    int       `json:"backlog"`             // Requests the node reported still waiting to be sent
+	LastError  string    `json:"lastError,omitempty"` // Error from the last push, if it failed
+	Stale      bool      `json:"stale"`               // No push within the stale threshold
+}
+
+// Collector receives pushes from trap nodes and imports them into the
+// local store, tagged with the node name.
+//
+// Collector implements http.Handler and is safe for concurrent use.
+type Collector struct {
+	importer   stats.Importer
+	token      string
+	staleAfter time.Duration
+	logger     *slog.Logger
+	mu         sync.Mutex
+	nodes      map[string]*NodeStatus
+	now        func() time.Time
+}
+
+// NewCollector creates a collector.
+//
+// Parameters:
+//   - importer: the store pushed requests are imported into
+//   - token: shared secret nodes must send as a bearer token
+//   - staleAfter: how long a node may go without pushing before it is
+//     reported as stale (0 uses DefaultStaleAfter)
+//   - logger: structured logger instance
+//
+// Returns a new Collector instance.
+func NewCollector(importer stats.Importer, token string, staleAfter time.Duration, logger *slog.Logger) *Collector {
+	if staleAfter <= 0 {
+		staleAfter = DefaultStaleAfter
+	}
+	return &Collector{
+		importer:   importer,
+		token:      token,
+		staleAfter: staleAfter,
+		logger:     logger,
+		nodes:      make(map[string]*NodeStatus),
+		now:        time.Now,
+	}
+}
+
+// ServeHTTP handles a push from a node.
+//
+// It responds with 401 Unauthorized for a missing or wrong token, 400 Bad
+// Request for an invalid node name, and otherwise with the import result
+// as JSON.
+//
+// Parameters:
+//   - w: the HTTP response writer
+//   - r: the HTTP request
+func (c *Collector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(c.token)) != 1 {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	node := r.Header.Get(HeaderNode)
+	if !ValidNodeName(node) {
+		http.Error(w, "Invalid node name", http.StatusBadRequest)
+		return
+	}
+	backlog, _ := strconv.Atoi(r.Header.Get(HeaderBacklog))
+
+	result, err := c.importer.Import(r.Context(), http.MaxBytesReader(w, r.Body, MaxPushBytes), node)
+
+	address, _, splitErr := net.SplitHostPort(r.RemoteAddr)
+	if splitErr != nil {
+		address = r.RemoteAddr
+	}
+	c.mu.Lock()
+	status, ok := c.nodes[node]
+	if !ok {
+		status = &NodeStatus{Name: node}
+		c.nodes[node] = status
+		c.logger.Info("Replication node connected", "node", node, "address", address)
+	}
+	status.Address = address
+	status.LastSeen = c.now()
+	status.Received += result.Imported
+	status.Duplicates += result.Duplicates
+	status.Backlog = backlog
+	status.LastError = ""
+	if err != nil {
+		status.LastError = err.Error()
+	}
+	c.mu.Unlock()
+
+	response := struct {
+		stats.ImportResult
+		Error string `json:"error,omitempty"`
+	}{ImportResult: result}
+	statusCode := http.StatusOK
+	if err != nil {
+		c.logger.Warn("Failed to import pushed requests", "node", node, "imported", result.Imported, "error", err)
+		response.Error = err.Error()
+		statusCode = http.StatusInternalServerError
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(response)
+}
+
+// Nodes returns the status of every node that has pushed since the
+// collector started, sorted by name.
+func (c *Collector) Nodes() []NodeStatus {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now()
+	nodes := make([]NodeStatus, 0, len(c.nodes))
+	for _, status := range c.nodes {
+		node := *status
+		node.Stale = now.Sub(node.LastSeen) > c.staleAfter
+		nodes = append(nodes, node)
+	}
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].Name < nodes[j].Name })
+	return nodes
+}
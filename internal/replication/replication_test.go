@@ -0,0 +1,272 @@
+package replication
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/rampantspark/gospidertrap/internal/stats"
+)
+
+const testToken = "secret"
+
+// instance is an in-process trap instance with its own database.
+type instance struct {
+	db *stats.Database
+}
+
+func newInstance(t *testing.T) *instance {
+	t.Helper()
+	db, err := stats.NewDatabase(filepath.Join(t.TempDir(), "stats.db"), slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err != nil {
+		t.Fatalf("NewDatabase() error = %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return &instance{db: db}
+}
+
+// record records n requests from ip.
+func (i *instance) record(t *testing.T, ip string, n int) {
+	t.Helper()
+	reqs := make([]stats.RequestInfo, n)
+	for j := range reqs {
+		reqs[j] = stats.RequestInfo{IP: ip, UserAgent: "bot", Path: "/" + strings.Repeat("a", j+1), Timestamp: time.Now()}
+	}
+	if _, err := i.db.RecordRequests(context.Background(), reqs); err != nil {
+		t.Fatalf("RecordRequests() error = %v", err)
+	}
+}
+
+// total returns the instance's total request count.
+func (i *instance) total(t *testing.T) int {
+	t.Helper()
+	summary, err := i.db.GetSummary(context.Background())
+	if err != nil {
+		t.Fatalf("GetSummary() error = %v", err)
+	}
+	return summary.TotalRequests
+}
+
+// startCollector serves a collector backed by c, with a switch to make it
+// unreachable.
+func startCollector(t *testing.T, c *instance) (*Collector, *httptest.Server, *atomic.Bool) {
+	t.Helper()
+	collector := NewCollector(c.db, testToken, time.Minute, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	down := &atomic.Bool{}
+	mux := http.NewServeMux()
+	mux.Handle(PushPath, collector)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if down.Load() {
+			http.Error(w, "Service Unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		mux.ServeHTTP(w, r)
+	}))
+	t.Cleanup(srv.Close)
+	return collector, srv, down
+}
+
+func TestReplication(t *testing.T) {
+	ctx := context.Background()
+	node := newInstance(t)
+	collectorInstance := newInstance(t)
+	collector, srv, down := startCollector(t, collectorInstance)
+
+	sender := NewSender(node.db, SenderConfig{
+		URL:       srv.URL,
+		Token:     testToken,
+		Node:      "trap-a",
+		BatchSize: 2,
+	}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	// A node with nothing to send still reports in
+	if sent, err := sender.Push(ctx); err != nil || sent != 0 {
+		t.Fatalf("Push() = %d, %v, want 0, nil", sent, err)
+	}
+	if nodes := collector.Nodes(); len(nodes) != 1 || nodes[0].Name != "trap-a" {
+		t.Fatalf("Nodes() = %+v, want trap-a after a heartbeat", nodes)
+	}
+
+	node.record(t, "10.0.0.1", 3)
+	if sent, err := sender.Push(ctx); err != nil || sent != 3 {
+		t.Fatalf("Push() = %d, %v, want 3 sent in two batches", sent, err)
+	}
+	if got := collectorInstance.total(t); got != 3 {
+		t.Errorf("collector total = %d, want 3", got)
+	}
+
+	// Requests recorded while the collector is down stay buffered on the node
+	down.Store(true)
+	node.record(t, "10.0.0.2", 2)
+	if _, err := sender.Push(ctx); err == nil {
+		t.Fatal("Push() to an unreachable collector succeeded")
+	}
+	cursor, err := node.db.ReplicationCursor(ctx, srv.URL)
+	if err != nil {
+		t.Fatalf("ReplicationCursor() error = %v", err)
+	}
+	if backlog, _ := node.db.CountRequestsAfter(ctx, cursor); backlog != 2 {
+		t.Errorf("backlog = %d, want 2", backlog)
+	}
+
+	down.Store(false)
+	if sent, err := sender.Push(ctx); err != nil || sent != 2 {
+		t.Fatalf("Push() after recovery = %d, %v, want 2", sent, err)
+	}
+	if got := collectorInstance.total(t); got != 5 {
+		t.Errorf("collector total = %d, want 5", got)
+	}
+
+	nodes := collector.Nodes()
+	if len(nodes) != 1 {
+		t.Fatalf("Nodes() = %+v, want one node", nodes)
+	}
+	if n := nodes[0]; n.Received != 5 || n.Backlog != 0 || n.LastError != "" || n.Stale || n.Address != "127.0.0.1" {
+		t.Errorf("node status = %+v, want 5 received, no backlog, healthy, from 127.0.0.1", n)
+	}
+
+	// Pushed requests are tagged with the node name
+	err = collectorInstance.db.ExportRequests(ctx, time.Time{}, func(req stats.RequestInfo) error {
+		if req.Source != "trap-a" {
+			t.Errorf("request %s has source %q, want trap-a", req.Path, req.Source)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ExportRequests() error = %v", err)
+	}
+}
+
+func TestReplicationResendsUnacknowledged(t *testing.T) {
+	ctx := context.Background()
+	node := newInstance(t)
+	collectorInstance := newInstance(t)
+	collector := NewCollector(collectorInstance.db, testToken, time.Minute, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	// The first push is imported but its acknowledgement is lost
+	var pushes atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if pushes.Add(1) == 1 {
+			collector.ServeHTTP(httptest.NewRecorder(), r)
+			http.Error(w, "Bad Gateway", http.StatusBadGateway)
+			return
+		}
+		collector.ServeHTTP(w, r)
+	}))
+	defer srv.Close()
+
+	sender := NewSender(node.db, SenderConfig{URL: srv.URL, Token: testToken, Node: "trap-a"},
+		slog.New(slog.NewTextHandler(io.Discard, nil)))
+	node.record(t, "10.0.0.1", 3)
+
+	if _, err := sender.Push(ctx); err == nil {
+		t.Fatal("Push() with a lost acknowledgement succeeded")
+	}
+	if sent, err := sender.Push(ctx); err != nil || sent != 3 {
+		t.Fatalf("Push() = %d, %v, want the 3 requests resent", sent, err)
+	}
+	if got := collectorInstance.total(t); got != 3 {
+		t.Errorf("collector total = %d, want 3 without duplicates", got)
+	}
+	if n := collector.Nodes()[0]; n.Received != 3 || n.Duplicates != 3 {
+		t.Errorf("node status = %+v, want 3 received and 3 duplicates", n)
+	}
+}
+
+func TestSenderStopPushesRemaining(t *testing.T) {
+	node := newInstance(t)
+	collectorInstance := newInstance(t)
+	_, srv, _ := startCollector(t, collectorInstance)
+
+	sender := NewSender(node.db, SenderConfig{URL: srv.URL, Token: testToken, Node: "trap-a", Interval: time.Hour},
+		slog.New(slog.NewTextHandler(io.Discard, nil)))
+	sender.Start()
+
+	// The cursor exists from Start, so retention holds unsent requests
+	if _, err := node.db.ReplicationCursor(context.Background(), srv.URL); err != nil {
+		t.Fatalf("ReplicationCursor() error = %v", err)
+	}
+
+	node.record(t, "10.0.0.1", 2)
+	sender.Stop()
+	sender.Stop() // Stopping twice is harmless
+
+	if got := collectorInstance.total(t); got != 2 {
+		t.Errorf("collector total = %d, want 2 after the final push", got)
+	}
+}
+
+func TestCollectorRejects(t *testing.T) {
+	collectorInstance := newInstance(t)
+	collector := NewCollector(collectorInstance.db, testToken, time.Minute, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	tests := []struct {
+		name       string
+		method     string
+		auth       string
+		node       string
+		wantStatus int
+	}{
+		{"wrong method", http.MethodGet, "Bearer " + testToken, "trap-a", http.StatusMethodNotAllowed},
+		{"no token", http.MethodPost, "", "trap-a", http.StatusUnauthorized},
+		{"wrong token", http.MethodPost, "Bearer wrong", "trap-a", http.StatusUnauthorized},
+		{"missing node", http.MethodPost, "Bearer " + testToken, "", http.StatusBadRequest},
+		{"invalid node", http.MethodPost, "Bearer " + testToken, "trap a/../b", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, PushPath, strings.NewReader(""))
+			if tt.auth != "" {
+				r.Header.Set("Authorization", tt.auth)
+			}
+			r.Header.Set(HeaderNode, tt.node)
+			w := httptest.NewRecorder()
+			collector.ServeHTTP(w, r)
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+		})
+	}
+	if nodes := collector.Nodes(); len(nodes) != 0 {
+		t.Errorf("Nodes() = %+v, want none after rejected pushes", nodes)
+	}
+}
+
+func TestCollectorStaleNodes(t *testing.T) {
+	collectorInstance := newInstance(t)
+	collector := NewCollector(collectorInstance.db, testToken, time.Minute, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	now := time.Now()
+	collector.now = func() time.Time { return now }
+
+	for _, node := range []string{"trap-b", "trap-a"} {
+		r := httptest.NewRequest(http.MethodPost, PushPath, strings.NewReader(""))
+		r.Header.Set("Authorization", "Bearer "+testToken)
+		r.Header.Set(HeaderNode, node)
+		r.Header.Set(HeaderBacklog, "42")
+		w := httptest.NewRecorder()
+		collector.ServeHTTP(w, r)
+		if w.Code != http.StatusOK {
+			t.Fatalf("status = %d, want 200: %s", w.Code, w.Body.String())
+		}
+		now = now.Add(30 * time.Second)
+	}
+
+	now = now.Add(15 * time.Second)
+	nodes := collector.Nodes()
+	if len(nodes) != 2 || nodes[0].Name != "trap-a" || nodes[1].Name != "trap-b" {
+		t.Fatalf("Nodes() = %+v, want trap-a and trap-b sorted by name", nodes)
+	}
+	if nodes[0].Stale || !nodes[1].Stale {
+		t.Errorf("stale = %v, %v, want only trap-b stale", nodes[0].Stale, nodes[1].Stale)
+	}
+	if nodes[0].Backlog != 42 {
+		t.Errorf("Backlog = %d, want 42", nodes[0].Backlog)
+	}
+}
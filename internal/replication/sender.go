@@ -0,0 +1,244 @@
+// Package replication streams recorded requests from trap nodes to a
+// collector node.
+//
+// Nodes push their request log to the collector over HTTP: each push is a
+// POST to PushPath with the requests as NDJSON, authenticated with a shared
+// bearer token, and names the node in the X-Gospidertrap-Node header. The
+// collector imports the requests tagged with the node name. Pushes are
+// idempotent because the collector skips requests it has already imported,
+// so a node resends a batch until it is acknowledged.
+//
+// Nodes keep a cursor in their SQLite database that marks the last request
+// the collector acknowledged. While the collector is unreachable, requests
+// stay buffered in the database and are sent once it is back.
+package replication
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rampantspark/gospidertrap/internal/stats"
+)
+
+// PushPath is the path of the collector's push endpoint.
+const PushPath = "/replicate"
+
+// Headers sent with every push.
+const (
+	HeaderNode    = "X-Gospidertrap-Node"    // Name of the pushing node
+	HeaderBacklog = "X-Gospidertrap-Backlog" // Requests still waiting on the node after this push
+)
+
+// Default sender settings.
+const (
+	DefaultInterval  = 5 * time.Second
+	DefaultBatchSize = 500
+	DefaultTimeout   = 10 * time.Second
+)
+
+// SenderConfig holds settings for pushing requests to a collector.
+type SenderConfig struct {
+	URL       string        // Base URL of the collector, e.g. "https://collector:8000"
+	Token     string        // Shared secret sent as a bearer token
+	Node      string        // Name of this node
+	Interval  time.Duration // How often to push; an empty push doubles as a heartbeat
+	BatchSize int           // Maximum requests per push
+	Timeout   time.Duration // Timeout for each push
+}
+
+// Sender periodically pushes new requests from a node's database to the
+// collector.
+type Sender struct {
+	db       *stats.Database
+	config   SenderConfig
+	pushURL  string
+	client   *http.Client
+	logger   *slog.Logger
+	mu       sync.Mutex // Serializes pushes
+	failing  bool
+	stopChan chan struct{}
+	stopOnce sync.Once
+	done     chan struct{}
+}
+
+// NewSender creates a sender. Call Start to begin pushing.
+//
+// Zero values in config are replaced with the package defaults.
+//
+// Parameters:
+//   - db: the node's database
+//   - config: collector address and push settings
+//   - logger: structured logger instance
+//
+// Returns a new Sender instance.
+func NewSender(db *stats.Database, config SenderConfig, logger *slog.Logger) *Sender {
+	if config.Interval <= 0 {
+		config.Interval = DefaultInterval
+	}
+	if config.BatchSize <= 0 {
+		config.BatchSize = DefaultBatchSize
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = DefaultTimeout
+	}
+	return &Sender{
+		db:       db,
+		config:   config,
+		pushURL:  strings.TrimSuffix(config.URL, "/") + PushPath,
+		client:   &http.Client{Timeout: config.Timeout},
+		logger:   logger,
+		stopChan: make(chan struct{}),
+	}
+}
+
+// Start pushes immediately and then every config.Interval until Stop is
+// called.
+//
+// The node's cursor is created before Start returns, so retention keeps
+// unsent requests from then on even if the collector was never reached.
+func (s *Sender) Start() {
+	ctx := context.Background()
+	cursor, err := s.db.ReplicationCursor(ctx, s.config.URL)
+	if err == nil {
+		err = s.db.SetReplicationCursor(ctx, s.config.URL, cursor)
+	}
+	if err != nil {
+		s.logger.Warn("Failed to initialize replication cursor", "collector", s.config.URL, "error", err)
+	}
+
+	s.done = make(chan struct{})
+	go func() {
+		defer close(s.done)
+
+		ticker := time.NewTicker(s.config.Interval)
+		defer ticker.Stop()
+
+		for {
+			s.pushAndLog(context.Background())
+			select {
+			case <-ticker.C:
+			case <-s.stopChan:
+				// Deliver what was recorded since the last push
+				ctx, cancel := context.WithTimeout(context.Background(), s.config.Timeout)
+				s.pushAndLog(ctx)
+				cancel()
+				return
+			}
+		}
+	}()
+}
+
+// pushAndLog pushes and logs when delivery starts failing or recovers.
+func (s *Sender) pushAndLog(ctx context.Context) {
+	sent, err := s.Push(ctx)
+	if err != nil {
+		if !s.failing {
+			s.logger.Warn("Replication to collector failed, buffering requests locally",
+				"collector", s.config.URL, "error", err)
+		}
+		s.failing = true
+		return
+	}
+	if s.failing {
+		s.logger.Info("Replication to collector recovered", "collector", s.config.URL, "sent", sent)
+	} else if sent > 0 {
+		s.logger.Debug("Replicated requests", "collector", s.config.URL, "sent", sent)
+	}
+	s.failing = false
+}
+
+// Push sends every request after the node's cursor to the collector, in
+// batches of config.BatchSize. At least one push is made, so a node with
+// nothing to send still reports in.
+//
+// Parameters:
+//   - ctx: context for cancellation and timeout control
+//
+// Returns the number of requests acknowledged by the collector, or an
+// error if reading the database or a push fails. Batches acknowledged
+// before the error are not sent again.
+func (s *Sender) Push(ctx context.Context) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cursor, err := s.db.ReplicationCursor(ctx, s.config.URL)
+	if err != nil {
+		return 0, err
+	}
+	pending, err := s.db.CountRequestsAfter(ctx, cursor)
+	if err != nil {
+		return 0, err
+	}
+
+	sent := 0
+	for {
+		reqs, lastID, err := s.db.RequestsAfter(ctx, cursor, s.config.BatchSize)
+		if err != nil {
+			return sent, err
+		}
+		pending = max(pending-len(reqs), 0)
+		if err := s.post(ctx, reqs, pending); err != nil {
+			return sent, err
+		}
+		if lastID != cursor {
+			if err := s.db.SetReplicationCursor(ctx, s.config.URL, lastID); err != nil {
+				return sent, err
+			}
+			cursor = lastID
+		}
+		sent += len(reqs)
+		if len(reqs) < s.config.BatchSize {
+			return sent, nil
+		}
+	}
+}
+
+// post sends one batch of requests to the collector.
+func (s *Sender) post(ctx context.Context, reqs []stats.RequestInfo, backlog int) error {
+	var body bytes.Buffer
+	enc := json.NewEncoder(&body)
+	for _, req := range reqs {
+		if err := enc.Encode(req); err != nil {
+			return fmt.Errorf("failed to encode request: %w", err)
+		}
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, s.pushURL, &body)
+	if err != nil {
+		return fmt.Errorf("failed to create push request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/x-ndjson")
+	httpReq.Header.Set("Authorization", "Bearer "+s.config.Token)
+	httpReq.Header.Set(HeaderNode, s.config.Node)
+	httpReq.Header.Set(HeaderBacklog, strconv.Itoa(backlog))
+
+	resp, err := s.client.Do(httpReq)
+	if err != nil {
+		return fmt.Errorf("failed to push to collector: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("collector returned %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	io.Copy(io.Discard, resp.Body)
+	return nil
+}
+
+// Stop makes a final push of requests recorded since the last one, stops
+// the push goroutine and waits for it to exit.
+func (s *Sender) Stop() {
+	s.stopOnce.Do(func() { close(s.stopChan) })
+	if s.done != nil {
+		<-s.done
+	}
+}
@@ -0,0 +1,198 @@
+// Package siem forwards trap events to SIEM systems over syslog.
+//
+// Events are framed as RFC 5424 syslog messages and sent over UDP, TCP or
+// TLS. The message body is either a plain syslog message with structured
+// data, an ArcSight CEF event or a QRadar LEEF event. Events are queued in
+// a bounded buffer and sent by a background worker so trapped requests are
+// never slowed down by a slow or unreachable collector.
+package siem
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// EventType identifies the kind of event being forwarded.
+type EventType string
+
+// Supported event types.
+const (
+	EventRequest EventType = "request" // A request to a trap page
+	EventBan     EventType = "ban"     // An IP address was banned
+)
+
+// EventTypes lists every supported event type.
+var EventTypes = []EventType{EventRequest, EventBan}
+
+// Event is a single trap event.
+type Event struct {
+	Type      EventType // Kind of event
+	Time      time.Time // When the event happened
+	IP        string    // Client IP address
+	Network   string    // Banned autonomous system, e.g. "AS64496" (ban events for networks)
+	UserAgent string    // Client User-Agent header (request events)
+	Path      string    // Requested path (request events)
+	Count     int       // Total requests from the IP (request events, 0 if unknown)
+	Bait      string    // Scanner bait that answered the request (request events, empty for trap pages)
+	Rule      string    // Ban rule (ban events)
+	Reason    string    // Ban reason (ban events)
+}
+
+// name returns a short human-readable name for the event type.
+func (e Event) name() string {
+	switch e.Type {
+	case EventRequest:
+		return "Spider trap request"
+	case EventBan:
+		if e.Network != "" {
+			return "Network banned"
+		}
+		return "IP address banned"
+	default:
+		return string(e.Type)
+	}
+}
+
+// summary returns a one-line human-readable description of the event.
+func (e Event) summary() string {
+	switch e.Type {
+	case EventRequest:
+		return fmt.Sprintf("Trap request from %s for %s (%s)", e.IP, e.Path, e.UserAgent)
+	case EventBan:
+		if e.Network != "" {
+			return fmt.Sprintf("Network %s banned by rule %s: %s", e.Network, e.Rule, e.Reason)
+		}
+		return fmt.Sprintf("IP %s banned by rule %s: %s", e.IP, e.Rule, e.Reason)
+	default:
+		return string(e.Type)
+	}
+}
+
+// Facility is a syslog facility code.
+type Facility int
+
+// facilityNames maps syslog facility names to their RFC 5424 codes.
+var facilityNames = map[string]Facility{
+	"kern": 0, "user": 1, "mail": 2, "daemon": 3,
+	"auth": 4, "syslog": 5, "lpr": 6, "news": 7,
+	"uucp": 8, "cron": 9, "authpriv": 10, "ftp": 11,
+	"ntp": 12, "audit": 13, "alert": 14, "clock": 15,
+	"local0": 16, "local1": 17, "local2": 18, "local3": 19,
+	"local4": 20, "local5": 21, "local6": 22, "local7": 23,
+}
+
+// ParseFacility parses a syslog facility name such as "local0" or "auth".
+//
+// Returns the facility or an error if the name is unknown.
+func ParseFacility(name string) (Facility, error) {
+	f, ok := facilityNames[strings.ToLower(strings.TrimSpace(name))]
+	if !ok {
+		return 0, fmt.Errorf("unknown syslog facility: %s", name)
+	}
+	return f, nil
+}
+
+// Severity is a syslog severity level.
+type Severity int
+
+// Syslog severity levels as defined by RFC 5424.
+const (
+	SeverityEmergency Severity = iota
+	SeverityAlert
+	SeverityCritical
+	SeverityError
+	SeverityWarning
+	SeverityNotice
+	SeverityInfo
+	SeverityDebug
+)
+
+// severityNames maps syslog severity names to their levels.
+var severityNames = map[string]Severity{
+	"emerg":     SeverityEmergency,
+	"emergency": SeverityEmergency,
+	"alert":     SeverityAlert,
+	"crit":      SeverityCritical,
+	"critical":  SeverityCritical,
+	"err":       SeverityError,
+	"error":     SeverityError,
+	"warning":   SeverityWarning,
+	"warn":      SeverityWarning,
+	"notice":    SeverityNotice,
+	"info":      SeverityInfo,
+	"debug":     SeverityDebug,
+}
+
+// ParseSeverity parses a syslog severity name such as "warning" or "info".
+//
+// Returns the severity or an error if the name is unknown.
+func ParseSeverity(name string) (Severity, error) {
+	s, ok := severityNames[strings.ToLower(strings.TrimSpace(name))]
+	if !ok {
+		return 0, fmt.Errorf("unknown syslog severity: %s", name)
+	}
+	return s, nil
+}
+
+// DefaultSeverities returns the default severity for each event type.
+func DefaultSeverities() map[EventType]Severity {
+	return map[EventType]Severity{
+		EventRequest: SeverityInfo,
+		EventBan:     SeverityWarning,
+	}
+}
+
+// ParseSeverities parses a severity mapping in the form
+// "request=info,ban=warning".
+//
+// Event types not listed keep their default severity.
+//
+// Returns the complete mapping or an error if an event type or severity is
+// unknown.
+func ParseSeverities(s string) (map[EventType]Severity, error) {
+	severities := DefaultSeverities()
+	for _, pair := range strings.Split(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		name, level, ok := strings.Cut(pair, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid severity mapping: %s (expected EVENT=SEVERITY)", pair)
+		}
+		eventType := EventType(strings.TrimSpace(name))
+		if _, known := severities[eventType]; !known {
+			return nil, fmt.Errorf("unknown syslog event type: %s", name)
+		}
+		severity, err := ParseSeverity(level)
+		if err != nil {
+			return nil, err
+		}
+		severities[eventType] = severity
+	}
+	return severities, nil
+}
+
+// cefSeverity converts a syslog severity to the 0-10 scale used by CEF and
+// LEEF, where 10 is the most severe.
+func cefSeverity(s Severity) int {
+	switch s {
+	case SeverityEmergency:
+		return 10
+	case SeverityAlert:
+		return 9
+	case SeverityCritical:
+		return 8
+	case SeverityError:
+		return 7
+	case SeverityWarning:
+		return 6
+	case SeverityNotice:
+		return 4
+	case SeverityInfo:
+		return 3
+	default:
+		return 1
+	}
+}
@@ -0,0 +1,215 @@
+package siem
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Message formats for syslog targets.
+const (
+	FormatSyslog = "syslog" // Plain RFC 5424 message with structured data
+	FormatCEF    = "cef"    // ArcSight Common Event Format
+	FormatLEEF   = "leef"   // QRadar Log Event Extended Format
+)
+
+// Product identification used in syslog, CEF and LEEF headers.
+const (
+	appName       = "gospidertrap"
+	deviceVendor  = "rampantspark"
+	deviceProduct = "gospidertrap"
+	deviceVersion = "1.0"
+
+	// sdID is the structured data ID for plain syslog messages. 32473 is the
+	// private enterprise number reserved for documentation (RFC 5612).
+	sdID = "gospidertrap@32473"
+)
+
+// header holds the RFC 5424 header fields shared by every message.
+type header struct {
+	facility Facility
+	hostname string
+	procID   string
+}
+
+// formatMessage renders an event as a complete RFC 5424 syslog message.
+//
+// Parameters:
+//   - h: the syslog header fields
+//   - format: the message body format (syslog, cef or leef)
+//   - severity: the syslog severity for the event
+//   - e: the event to render
+//
+// Returns the message without transport framing.
+func formatMessage(h header, format string, severity Severity, e Event) string {
+	var sd, msg string
+	switch format {
+	case FormatCEF:
+		sd, msg = "-", formatCEF(severity, e)
+	case FormatLEEF:
+		sd, msg = "-", formatLEEF(severity, e)
+	default:
+		sd, msg = structuredData(e), e.summary()
+	}
+
+	pri := int(h.facility)*8 + int(severity)
+	return fmt.Sprintf("<%d>1 %s %s %s %s %s %s %s",
+		pri,
+		e.Time.Format("2006-01-02T15:04:05.000000Z07:00"),
+		headerField(h.hostname, 255),
+		appName,
+		headerField(h.procID, 128),
+		headerField(string(e.Type), 32),
+		sd,
+		msg)
+}
+
+// headerField sanitizes an RFC 5424 header field, which must be printable
+// US-ASCII without spaces, returning "-" (the nil value) if empty.
+func headerField(s string, maxLen int) string {
+	s = strings.Map(func(r rune) rune {
+		if r < 33 || r > 126 {
+			return -1
+		}
+		return r
+	}, s)
+	if len(s) > maxLen {
+		s = s[:maxLen]
+	}
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// structuredData renders the event fields as an RFC 5424 SD-ELEMENT.
+func structuredData(e Event) string {
+	var b strings.Builder
+	b.WriteString("[" + sdID)
+	param := func(name, value string) {
+		if value == "" {
+			return
+		}
+		b.WriteString(" " + name + `="` + sdEscaper.Replace(value) + `"`)
+	}
+	param("ip", e.IP)
+	param("network", e.Network)
+	param("path", e.Path)
+	param("userAgent", e.UserAgent)
+	if e.Count > 0 {
+		param("count", strconv.Itoa(e.Count))
+	}
+	param("bait", e.Bait)
+	param("rule", e.Rule)
+	param("reason", e.Reason)
+	b.WriteString("]")
+	return b.String()
+}
+
+// sdEscaper escapes the characters RFC 5424 requires in SD-PARAM values.
+var sdEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, `]`, `\]`)
+
+// formatCEF renders an event as an ArcSight CEF record.
+func formatCEF(severity Severity, e Event) string {
+	ext := []string{"rt=" + strconv.FormatInt(e.Time.UnixMilli(), 10)}
+	if e.IP != "" {
+		ext = append(ext, "src="+cefValue(e.IP))
+	}
+	if e.Network != "" {
+		ext = append(ext, "cs3Label=network", "cs3="+cefValue(e.Network))
+	}
+	switch e.Type {
+	case EventRequest:
+		ext = append(ext,
+			"request="+cefValue(e.Path),
+			"requestClientApplication="+cefValue(e.UserAgent))
+		if e.Count > 0 {
+			ext = append(ext, "cnt="+strconv.Itoa(e.Count))
+		}
+		if e.Bait != "" {
+			ext = append(ext, "cs2Label=bait", "cs2="+cefValue(e.Bait))
+		}
+	case EventBan:
+		ext = append(ext,
+			"act=block",
+			"cs1Label=rule", "cs1="+cefValue(e.Rule),
+			"reason="+cefValue(e.Reason))
+	}
+
+	return fmt.Sprintf("CEF:0|%s|%s|%s|%s|%s|%d|%s",
+		cefHeader(deviceVendor),
+		cefHeader(deviceProduct),
+		cefHeader(deviceVersion),
+		cefHeader(string(e.Type)),
+		cefHeader(e.name()),
+		cefSeverity(severity),
+		strings.Join(ext, " "))
+}
+
+// cefHeaderEscaper escapes pipes and backslashes in CEF header fields.
+var cefHeaderEscaper = strings.NewReplacer(`\`, `\\`, `|`, `\|`, "\r", " ", "\n", " ")
+
+// cefValueEscaper escapes equals signs, backslashes and newlines in CEF
+// extension values.
+var cefValueEscaper = strings.NewReplacer(`\`, `\\`, `=`, `\=`, "\r", `\r`, "\n", `\n`)
+
+func cefHeader(s string) string { return cefHeaderEscaper.Replace(s) }
+func cefValue(s string) string  { return cefValueEscaper.Replace(s) }
+
+// formatLEEF renders an event as a QRadar LEEF 1.0 record with
+// tab-separated attributes.
+func formatLEEF(severity Severity, e Event) string {
+	attrs := []string{
+		"devTime=" + e.Time.UTC().Format("Jan 02 2006 15:04:05.000 UTC"),
+		"devTimeFormat=MMM dd yyyy HH:mm:ss.SSS z",
+		"sev=" + strconv.Itoa(cefSeverity(severity)),
+		"cat=" + string(e.Type),
+	}
+	if e.IP != "" {
+		attrs = append(attrs, "src="+leefValue(e.IP))
+	}
+	if e.Network != "" {
+		attrs = append(attrs, "network="+leefValue(e.Network))
+	}
+	switch e.Type {
+	case EventRequest:
+		attrs = append(attrs,
+			"url="+leefValue(e.Path),
+			"userAgent="+leefValue(e.UserAgent))
+		if e.Count > 0 {
+			attrs = append(attrs, "count="+strconv.Itoa(e.Count))
+		}
+		if e.Bait != "" {
+			attrs = append(attrs, "bait="+leefValue(e.Bait))
+		}
+	case EventBan:
+		attrs = append(attrs,
+			"action=block",
+			"rule="+leefValue(e.Rule),
+			"reason="+leefValue(e.Reason))
+	}
+
+	return fmt.Sprintf("LEEF:1.0|%s|%s|%s|%s|%s",
+		cefHeader(deviceVendor),
+		cefHeader(deviceProduct),
+		cefHeader(deviceVersion),
+		cefHeader(string(e.Type)),
+		strings.Join(attrs, "\t"))
+}
+
+// leefValueEscaper replaces the attribute delimiter and line breaks in LEEF
+// attribute values.
+var leefValueEscaper = strings.NewReplacer("\t", " ", "\r", " ", "\n", " ")
+
+func leefValue(s string) string { return leefValueEscaper.Replace(s) }
+
+// frame applies transport framing to a message.
+//
+// Stream transports (TCP and TLS) use octet counting as described in
+// RFC 6587 and RFC 5425; datagrams carry one unframed message each.
+func frame(network, msg string) []byte {
+	if network == "udp" {
+		return []byte(msg)
+	}
+	return []byte(strconv.Itoa(len(msg)) + " " + msg)
+}
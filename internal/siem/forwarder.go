@@ -0,0 +1,291 @@
+package siem
+
+import (
+	"crypto/tls"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Default delivery settings.
+const (
+	DefaultQueueSize = 1024
+	DefaultTimeout   = 5 * time.Second
+)
+
+// Target is a single syslog collector.
+type Target struct {
+	Network string // Transport: udp, tcp or tls
+	Address string // Collector address as host:port
+	Format  string // Message format: syslog, cef or leef
+}
+
+// String returns the target in the form accepted by ParseTarget.
+func (t Target) String() string {
+	return t.Format + "=" + t.Network + "://" + t.Address
+}
+
+// ParseTarget parses a target in the form "[format=]network://host:port",
+// for example "udp://siem.example.com:514" or "cef=tls://siem:6514".
+//
+// Without a format prefix the plain syslog format is used.
+//
+// Returns the target or an error if the format or transport is unknown or
+// the address has no port.
+func ParseTarget(s string) (Target, error) {
+	format := FormatSyslog
+	if prefix, rest, ok := strings.Cut(s, "="); ok && !strings.Contains(prefix, "/") {
+		format, s = prefix, rest
+	}
+	switch format {
+	case FormatSyslog, FormatCEF, FormatLEEF:
+	default:
+		return Target{}, fmt.Errorf("unknown syslog format: %s", format)
+	}
+
+	u, err := url.Parse(s)
+	if err != nil {
+		return Target{}, fmt.Errorf("invalid syslog target: %w", err)
+	}
+	switch u.Scheme {
+	case "udp", "tcp", "tls":
+	default:
+		return Target{}, fmt.Errorf("syslog target must use udp, tcp or tls: %s", s)
+	}
+	if u.Hostname() == "" || u.Port() == "" {
+		return Target{}, fmt.Errorf("syslog target must include host and port: %s", s)
+	}
+
+	return Target{Network: u.Scheme, Address: u.Host, Format: format}, nil
+}
+
+// Config holds forwarder settings.
+type Config struct {
+	Targets    []Target               // Syslog collectors
+	Facility   Facility               // Syslog facility for every message
+	Severities map[EventType]Severity // Severity per event type (nil uses DefaultSeverities)
+	TLSConfig  *tls.Config            // TLS settings for tls targets (nil uses system roots)
+	QueueSize  int                    // Maximum number of queued events
+	Timeout    time.Duration          // Timeout for connecting and writing
+}
+
+// Forwarder queues events and sends them to syslog collectors.
+//
+// Stream connections are opened on first use and re-established after a
+// write error. Forwarder is safe for concurrent use.
+type Forwarder struct {
+	config   Config
+	header   header
+	conns    []*connection
+	queue    chan Event
+	logger   *slog.Logger
+	dropped  atomic.Int64
+	failed   atomic.Int64
+	stopChan chan struct{}
+	stopOnce sync.Once
+	done     chan struct{}
+}
+
+// New creates a forwarder and starts its delivery worker.
+//
+// Zero values in config are replaced with the package defaults.
+//
+// Parameters:
+//   - config: forwarder settings
+//   - logger: structured logger instance
+//
+// Returns a new Forwarder instance. Call Stop when it is no longer needed.
+func New(config Config, logger *slog.Logger) *Forwarder {
+	if config.Severities == nil {
+		config.Severities = DefaultSeverities()
+	}
+	if config.QueueSize <= 0 {
+		config.QueueSize = DefaultQueueSize
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = DefaultTimeout
+	}
+
+	hostname, _ := os.Hostname()
+	f := &Forwarder{
+		config: config,
+		header: header{
+			facility: config.Facility,
+			hostname: hostname,
+			procID:   strconv.Itoa(os.Getpid()),
+		},
+		conns:    make([]*connection, len(config.Targets)),
+		queue:    make(chan Event, config.QueueSize),
+		logger:   logger,
+		stopChan: make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	go f.run()
+	return f
+}
+
+// Enabled reports whether the forwarder has any targets.
+func (f *Forwarder) Enabled() bool {
+	return f != nil && len(f.config.Targets) > 0
+}
+
+// Send queues an event for delivery without blocking.
+//
+// If the queue is full the event is dropped and counted.
+//
+// Parameters:
+//   - e: the event to send (a zero Time is set to now)
+//
+// Returns true if the event was queued.
+func (f *Forwarder) Send(e Event) bool {
+	if !f.Enabled() {
+		return false
+	}
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+
+	select {
+	case <-f.stopChan:
+		return false
+	default:
+	}
+
+	select {
+	case f.queue <- e:
+		return true
+	default:
+		if f.dropped.Add(1) == 1 || f.dropped.Load()%1000 == 0 {
+			f.logger.Warn("Syslog queue full, dropping events", "dropped", f.dropped.Load())
+		}
+		return false
+	}
+}
+
+// Dropped returns the number of events dropped because the queue was full.
+func (f *Forwarder) Dropped() int64 {
+	return f.dropped.Load()
+}
+
+// Failed returns the number of messages that could not be sent.
+func (f *Forwarder) Failed() int64 {
+	return f.failed.Load()
+}
+
+// run sends queued events until the forwarder is stopped.
+func (f *Forwarder) run() {
+	defer close(f.done)
+	defer f.closeConns()
+	for {
+		select {
+		case e := <-f.queue:
+			severity := f.config.Severities[e.Type]
+			for i, target := range f.config.Targets {
+				msg := formatMessage(f.header, target.Format, severity, e)
+				if err := f.write(i, frame(target.Network, msg)); err != nil {
+					f.logger.Warn("Failed to send syslog message", "target", target.String(), "error", err)
+					f.failed.Add(1)
+				}
+			}
+		case <-f.stopChan:
+			return
+		}
+	}
+}
+
+// write sends a framed message to the target at index i, reconnecting once
+// if the existing connection has failed.
+func (f *Forwarder) write(i int, msg []byte) error {
+	var err error
+	for attempt := 0; attempt < 2; attempt++ {
+		if f.conns[i] != nil && f.conns[i].closed.Load() {
+			f.conns[i].Close()
+			f.conns[i] = nil
+		}
+		if f.conns[i] == nil {
+			if f.conns[i], err = f.dial(f.config.Targets[i]); err != nil {
+				return err
+			}
+		}
+
+		f.conns[i].SetWriteDeadline(time.Now().Add(f.config.Timeout))
+		if _, err = f.conns[i].Write(msg); err == nil {
+			return nil
+		}
+		f.conns[i].Close()
+		f.conns[i] = nil
+	}
+	return fmt.Errorf("failed to write message: %w", err)
+}
+
+// connection is an open connection to a target.
+type connection struct {
+	net.Conn
+	closed atomic.Bool // Set when the peer closes a stream connection
+}
+
+// watch reads from a stream connection until it fails and then marks it
+// closed. Collectors never send data, so this only detects disconnects that
+// would otherwise swallow the next write.
+func (c *connection) watch() {
+	io.Copy(io.Discard, c.Conn)
+	c.closed.Store(true)
+}
+
+// dial opens a connection to a target.
+func (f *Forwarder) dial(target Target) (*connection, error) {
+	dialer := &net.Dialer{Timeout: f.config.Timeout}
+	var conn net.Conn
+	var err error
+	switch target.Network {
+	case "tls":
+		tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+		if f.config.TLSConfig != nil {
+			tlsConfig = f.config.TLSConfig.Clone()
+		}
+		if tlsConfig.ServerName == "" {
+			tlsConfig.ServerName, _, _ = net.SplitHostPort(target.Address)
+		}
+		conn, err = tls.DialWithDialer(dialer, "tcp", target.Address, tlsConfig)
+	default:
+		conn, err = dialer.Dial(target.Network, target.Address)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect: %w", err)
+	}
+
+	c := &connection{Conn: conn}
+	if target.Network != "udp" {
+		go c.watch()
+	}
+	return c, nil
+}
+
+// closeConns closes every open connection.
+func (f *Forwarder) closeConns() {
+	for i, conn := range f.conns {
+		if conn != nil {
+			conn.Close()
+			f.conns[i] = nil
+		}
+	}
+}
+
+// Stop stops the delivery worker, closes connections and waits for the
+// worker to exit.
+//
+// Events still queued are discarded. Safe to call multiple times.
+func (f *Forwarder) Stop() {
+	f.stopOnce.Do(func() {
+		close(f.stopChan)
+	})
+	<-f.done
+}
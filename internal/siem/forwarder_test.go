@@ -0,0 +1,225 @@
+package siem
+
+import (
+	"bufio"
+	"io"
+	"log/slog"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testLogger() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, nil))
+}
+
+var testTime = time.Date(2026, 1, 2, 3, 4, 5, 6000, time.UTC)
+
+func TestFormatMessage_Syslog(t *testing.T) {
+	h := header{facility: 16, hostname: "trap host", procID: "42"}
+	e := Event{Type: EventRequest, Time: testTime, IP: "192.0.2.1", Path: "/a", UserAgent: `curl "8"]`, Count: 3}
+
+	got := formatMessage(h, FormatSyslog, SeverityInfo, e)
+	want := `<134>1 2026-01-02T03:04:05.000006Z traphost gospidertrap 42 request ` +
+		`[gospidertrap@32473 ip="192.0.2.1" path="/a" userAgent="curl \"8\"\]" count="3"] ` +
+		`Trap request from 192.0.2.1 for /a (curl "8"])`
+	if got != want {
+		t.Errorf("formatMessage() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestFormatMessage_CEF(t *testing.T) {
+	h := header{facility: 4, hostname: "trap", procID: "1"}
+	e := Event{Type: EventBan, Time: testTime, IP: "192.0.2.1", Rule: "trap-hits", Reason: "a=b\nc"}
+
+	got := formatMessage(h, FormatCEF, SeverityWarning, e)
+	want := `<36>1 2026-01-02T03:04:05.000006Z trap gospidertrap 1 ban - ` +
+		`CEF:0|rampantspark|gospidertrap|1.0|ban|IP address banned|6|` +
+		`rt=1767323045000 src=192.0.2.1 act=block cs1Label=rule cs1=trap-hits reason=a\=b\nc`
+	if got != want {
+		t.Errorf("formatMessage() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestFormatMessage_LEEF(t *testing.T) {
+	e := Event{Type: EventRequest, Time: testTime, IP: "192.0.2.1", Path: "/a", UserAgent: "bot\tv1"}
+
+	got := formatLEEF(SeverityNotice, e)
+	want := "LEEF:1.0|rampantspark|gospidertrap|1.0|request|" +
+		"devTime=Jan 02 2026 03:04:05.000 UTC\tdevTimeFormat=MMM dd yyyy HH:mm:ss.SSS z\t" +
+		"sev=4\tcat=request\tsrc=192.0.2.1\turl=/a\tuserAgent=bot v1"
+	if got != want {
+		t.Errorf("formatLEEF() =\n%q\nwant\n%q", got, want)
+	}
+}
+
+func TestFormatMessage_NetworkBan(t *testing.T) {
+	h := header{facility: 4, hostname: "trap", procID: "1"}
+	e := Event{Type: EventBan, Time: testTime, Network: "AS64496", Rule: "trap-hits", Reason: "too many"}
+
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{FormatSyslog, `[gospidertrap@32473 network="AS64496" rule="trap-hits" reason="too many"] Network AS64496 banned by rule trap-hits: too many`},
+		{FormatCEF, `|ban|Network banned|6|rt=1767323045000 cs3Label=network cs3=AS64496 act=block cs1Label=rule cs1=trap-hits reason=too many`},
+		{FormatLEEF, "cat=ban\tnetwork=AS64496\taction=block\trule=trap-hits\treason=too many"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			got := formatMessage(h, tt.format, SeverityWarning, e)
+			if !strings.HasSuffix(got, tt.want) {
+				t.Errorf("formatMessage() =\n%q\nwant suffix\n%q", got, tt.want)
+			}
+			if strings.Contains(got, "src=") || strings.Contains(got, "ip=") {
+				t.Errorf("formatMessage() = %q, want no IP for a network ban", got)
+			}
+		})
+	}
+}
+
+func TestFormatMessage_Bait(t *testing.T) {
+	e := Event{Type: EventRequest, Time: testTime, IP: "192.0.2.1", Path: "/.env", UserAgent: "zgrab", Count: 1, Bait: "env"}
+
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{FormatSyslog, ` count="1" bait="env"]`},
+		{FormatCEF, ` cnt=1 cs2Label=bait cs2=env`},
+		{FormatLEEF, "\tcount=1\tbait=env"},
+	}
+	for _, tt := range tests {
+		got := formatMessage(header{facility: 16, hostname: "trap", procID: "1"}, tt.format, SeverityInfo, e)
+		if !strings.Contains(got, tt.want) {
+			t.Errorf("formatMessage(%s) = %q, want it to contain %q", tt.format, got, tt.want)
+		}
+	}
+}
+
+func TestParseTarget(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    Target
+		wantErr bool
+	}{
+		{"udp://127.0.0.1:514", Target{Network: "udp", Address: "127.0.0.1:514", Format: FormatSyslog}, false},
+		{"cef=tcp://siem.example.com:1514", Target{Network: "tcp", Address: "siem.example.com:1514", Format: FormatCEF}, false},
+		{"leef=tls://[2001:db8::1]:6514", Target{Network: "tls", Address: "[2001:db8::1]:6514", Format: FormatLEEF}, false},
+		{"json=udp://127.0.0.1:514", Target{}, true},
+		{"http://127.0.0.1:514", Target{}, true},
+		{"udp://127.0.0.1", Target{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParseTarget(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseTarget() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseTarget() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseSeverities(t *testing.T) {
+	got, err := ParseSeverities("request=notice, ban=crit")
+	if err != nil {
+		t.Fatalf("ParseSeverities() error = %v", err)
+	}
+	if got[EventRequest] != SeverityNotice || got[EventBan] != SeverityCritical {
+		t.Errorf("ParseSeverities() = %v", got)
+	}
+
+	got, err = ParseSeverities("")
+	if err != nil || got[EventRequest] != SeverityInfo || got[EventBan] != SeverityWarning {
+		t.Errorf("ParseSeverities(\"\") = %v, %v; want defaults", got, err)
+	}
+
+	for _, input := range []string{"request", "login=info", "ban=loud"} {
+		if _, err := ParseSeverities(input); err == nil {
+			t.Errorf("ParseSeverities(%q) succeeded, want error", input)
+		}
+	}
+}
+
+func TestForwarder_UDP(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	defer pc.Close()
+
+	f := New(Config{
+		Targets:  []Target{{Network: "udp", Address: pc.LocalAddr().String(), Format: FormatCEF}},
+		Facility: 16,
+	}, testLogger())
+	defer f.Stop()
+
+	f.Send(Event{Type: EventRequest, IP: "192.0.2.1", Path: "/x"})
+
+	pc.SetReadDeadline(time.Now().Add(2 * time.Second))
+	buf := make([]byte, 4096)
+	n, _, err := pc.ReadFrom(buf)
+	if err != nil {
+		t.Fatalf("failed to read datagram: %v", err)
+	}
+	msg := string(buf[:n])
+	if !strings.HasPrefix(msg, "<134>1 ") || !strings.Contains(msg, " CEF:0|") {
+		t.Errorf("unexpected datagram: %s", msg)
+	}
+}
+
+func TestForwarder_TCPOctetCountingAndReconnect(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	defer ln.Close()
+
+	messages := make(chan string, 10)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			r := bufio.NewReader(conn)
+			length, err := r.ReadString(' ')
+			if err != nil {
+				conn.Close()
+				continue
+			}
+			n, _ := strconv.Atoi(strings.TrimSpace(length))
+			msg := make([]byte, n)
+			io.ReadFull(r, msg)
+			messages <- string(msg)
+			// Drop the connection after each message to force a reconnect.
+			conn.Close()
+		}
+	}()
+
+	f := New(Config{
+		Targets: []Target{{Network: "tcp", Address: ln.Addr().String(), Format: FormatSyslog}},
+	}, testLogger())
+	defer f.Stop()
+
+	for i := 0; i < 2; i++ {
+		f.Send(Event{Type: EventBan, IP: "192.0.2.1", Rule: "manual"})
+		select {
+		case msg := <-messages:
+			if !strings.Contains(msg, ` ban [gospidertrap@32473 ip="192.0.2.1" rule="manual"]`) {
+				t.Errorf("unexpected message: %s", msg)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("timed out waiting for message %d", i+1)
+		}
+		// Give the listener time to close the connection so the next write
+		// observes the failure.
+		time.Sleep(50 * time.Millisecond)
+	}
+}
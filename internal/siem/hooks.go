@@ -0,0 +1,38 @@
+package siem
+
+import (
+	"github.com/rampantspark/gospidertrap/internal/ban"
+	"github.com/rampantspark/gospidertrap/internal/geoip"
+	"github.com/rampantspark/gospidertrap/internal/stats"
+)
+
+// RecordHook returns a stats record hook that forwards every trapped request.
+//
+// Returns a hook for stats.Manager.AddRecordHook.
+func (f *Forwarder) RecordHook() stats.RecordHook {
+	return func(req stats.RequestInfo, result stats.RecordResult) {
+		f.Send(Event{
+			Type:      EventRequest,
+			Time:      req.Timestamp,
+			IP:        req.IP,
+			UserAgent: req.UserAgent,
+			Path:      req.Path,
+			Count:     result.IPCount,
+			Bait:      req.Bait,
+		})
+	}
+}
+
+// BanHook returns a ban list hook that forwards ban events. Bans on an
+// autonomous system carry its key in Network instead of IP.
+func (f *Forwarder) BanHook() func(ban.Ban) {
+	return func(b ban.Ban) {
+		e := Event{Type: EventBan, Time: b.CreatedAt, Rule: b.Rule, Reason: b.Reason}
+		if _, isNetwork := geoip.ParseASNKey(b.IP); isNetwork {
+			e.Network = b.IP
+		} else {
+			e.IP = b.IP
+		}
+		f.Send(e)
+	}
+}
@@ -0,0 +1,117 @@
+package stats
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Special values of RequestFilter.Bait.
+const (
+	// BaitAny matches requests answered by any bait.
+	BaitAny = "any"
+	// BaitNone matches requests answered by a trap page.
+	BaitNone = "none"
+)
+
+// BaitCount summarizes the requests answered by one bait.
+type BaitCount struct {
+	Bait     string    `json:"bait"`
+	Hits     int       `json:"hits"`     // Requests answered by the bait
+	IPs      int       `json:"ips"`      // Distinct IPs among them
+	LastSeen time.Time `json:"lastSeen"` // Time of the most recent one
+}
+
+// BaitReporter is implemented by stores that can count requests by the
+// bait that answered them.
+type BaitReporter interface {
+	// GetBaitCounts returns the hits of every bait that has answered a
+	// request, most hits first.
+	GetBaitCounts(ctx context.Context) ([]BaitCount, error)
+}
+
+// GetBaitCounts counts the requests in the request log by the bait that
+// answered them. Requests pruned by retention are not counted.
+//
+// Parameters:
+//   - ctx: context for cancellation and timeout control
+//
+// Returns the counts, most hits first, or an error if the query fails.
+func (d *Database) GetBaitCounts(ctx context.Context) ([]BaitCount, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	rows, err := d.db.QueryContext(ctx, `
+		SELECT b.bait, b.hits, b.ips, r.timestamp
+		FROM (
+			SELECT bait, COUNT(*) AS hits, COUNT(DISTINCT ip) AS ips, MAX(id) AS last_id
+			FROM request_log
+			WHERE bait != ''
+			GROUP BY bait
+		) b
+		JOIN request_log r ON r.id = b.last_id
+		ORDER BY b.hits DESC, b.bait
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query bait counts: %w", err)
+	}
+	defer rows.Close()
+
+	var result []BaitCount
+	for rows.Next() {
+		var c BaitCount
+		if err := rows.Scan(&c.Bait, &c.Hits, &c.IPs, &c.LastSeen); err != nil {
+			return nil, fmt.Errorf("failed to scan bait count: %w", err)
+		}
+		result = append(result, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating bait counts: %w", err)
+	}
+	return result, nil
+}
+
+// GetBaitCounts counts the retained recent requests by the bait that
+// answered them.
+//
+// Parameters:
+//   - ctx: context for cancellation and timeout control
+//
+// Returns the counts, most hits first; the memory store never fails.
+func (s *MemoryStore) GetBaitCounts(ctx context.Context) ([]BaitCount, error) {
+	s.stats.Mu.RLock()
+	defer s.stats.Mu.RUnlock()
+
+	counts := make(map[string]*BaitCount)
+	ips := make(map[string]map[string]bool)
+	for _, req := range s.stats.RecentRequests {
+		if req.Bait == "" {
+			continue
+		}
+		c, ok := counts[req.Bait]
+		if !ok {
+			c = &BaitCount{Bait: req.Bait}
+			counts[req.Bait] = c
+			ips[req.Bait] = make(map[string]bool)
+		}
+		c.Hits++
+		ips[req.Bait][req.IP] = true
+		if req.Timestamp.After(c.LastSeen) {
+			c.LastSeen = req.Timestamp
+		}
+	}
+
+	result := make([]BaitCount, 0, len(counts))
+	for name, c := range counts {
+		c.IPs = len(ips[name])
+		result = append(result, *c)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Hits != result[j].Hits {
+			return result[i].Hits > result[j].Hits
+		}
+		return result[i].Bait < result[j].Bait
+	})
+	return result, nil
+}
@@ -0,0 +1,78 @@
+package stats
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestBaitCounts(t *testing.T) {
+	now := time.Date(2025, 6, 10, 12, 0, 0, 0, time.UTC)
+	type baitStore interface {
+		Store
+		BaitReporter
+		RequestSearcher
+	}
+	backends := map[string]baitStore{
+		"sqlite": newTestDatabase(t),
+		"memory": NewMemoryStore(NewStats()),
+	}
+
+	for backend, store := range backends {
+		t.Run(backend, func(t *testing.T) {
+			ctx := context.Background()
+			_, err := store.RecordRequests(ctx, []RequestInfo{
+				{IP: "192.0.2.1", UserAgent: "Mozilla/5.0", Path: "/page", Timestamp: now},
+				{IP: "192.0.2.2", UserAgent: "zgrab", Path: "/.env", Timestamp: now.Add(time.Second), Bait: "env"},
+				{IP: "192.0.2.3", UserAgent: "zgrab", Path: "/.env.local", Timestamp: now.Add(2 * time.Second), Bait: "env"},
+				{IP: "192.0.2.2", UserAgent: "zgrab", Path: "/.env", Timestamp: now.Add(3 * time.Second), Bait: "env"},
+				{IP: "192.0.2.2", UserAgent: "zgrab", Path: "/.git/config", Timestamp: now.Add(4 * time.Second), Bait: "git-config"},
+			})
+			if err != nil {
+				t.Fatalf("RecordRequests() error = %v", err)
+			}
+
+			counts, err := store.GetBaitCounts(ctx)
+			if err != nil {
+				t.Fatalf("GetBaitCounts() error = %v", err)
+			}
+			want := []BaitCount{
+				{Bait: "env", Hits: 3, IPs: 2, LastSeen: now.Add(3 * time.Second)},
+				{Bait: "git-config", Hits: 1, IPs: 1, LastSeen: now.Add(4 * time.Second)},
+			}
+			if len(counts) != len(want) {
+				t.Fatalf("GetBaitCounts() = %+v, want %+v", counts, want)
+			}
+			for i := range want {
+				if counts[i].Bait != want[i].Bait || counts[i].Hits != want[i].Hits || counts[i].IPs != want[i].IPs || !counts[i].LastSeen.Equal(want[i].LastSeen) {
+					t.Errorf("GetBaitCounts()[%d] = %+v, want %+v", i, counts[i], want[i])
+				}
+			}
+
+			tests := []struct {
+				bait string
+				want int
+			}{
+				{"", 5},
+				{BaitAny, 4},
+				{BaitNone, 1},
+				{"git-config", 1},
+				{"missing", 0},
+			}
+			for _, tt := range tests {
+				page, err := store.SearchRequests(ctx, RequestQuery{RequestFilter: RequestFilter{Bait: tt.bait}})
+				if err != nil {
+					t.Fatalf("SearchRequests(bait %q) error = %v", tt.bait, err)
+				}
+				if len(page.Requests) != tt.want {
+					t.Errorf("SearchRequests(bait %q) returned %d requests, want %d", tt.bait, len(page.Requests), tt.want)
+				}
+				for _, req := range page.Requests {
+					if tt.bait == "git-config" && req.Bait != "git-config" {
+						t.Errorf("SearchRequests(bait %q) returned %+v", tt.bait, req)
+					}
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,204 @@
+package stats
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Outcomes of verifying a client that claims to be a search engine crawler.
+const (
+	// BotVerified means the IP's reverse DNS name belongs to the search
+	// engine and resolves back to the IP.
+	BotVerified = "verified"
+	// BotSpoofed means the IP has no reverse DNS name belonging to the
+	// search engine, or the name does not resolve back to it.
+	BotSpoofed = "spoofed"
+	// BotUnknown means the lookups failed, so the claim could not be checked.
+	BotUnknown = "unknown"
+)
+
+// BotVerification is the result of checking an IP whose user agent claims
+// to be a search engine crawler.
+type BotVerification struct {
+	IP        string    `json:"ip"`
+	Bot       string    `json:"bot"`      // Crawler the user agent claims to be, e.g. "Googlebot"
+	Status    string    `json:"status"`   // BotVerified, BotSpoofed or BotUnknown
+	Hostname  string    `json:"hostname"` // Reverse DNS name checked, empty if there was none
+	CheckedAt time.Time `json:"checkedAt"`
+}
+
+// BotVerificationStore is implemented by stores that keep the results of
+// bot verification.
+type BotVerificationStore interface {
+	// GetBotVerification returns the latest result for an IP, or an error
+	// wrapping ErrNotFound if it has not been checked.
+	GetBotVerification(ctx context.Context, ip string) (BotVerification, error)
+	// SaveBotVerification stores a result, replacing any earlier one for
+	// the IP.
+	SaveBotVerification(ctx context.Context, v BotVerification) error
+	// GetBotVerifications returns up to limit results, most recently
+	// checked first, optionally only those with the given status.
+	GetBotVerifications(ctx context.Context, status string, limit int) ([]BotVerification, error)
+}
+
+// GetBotVerification returns the latest bot verification of an IP address.
+//
+// Parameters:
+//   - ctx: context for cancellation and timeout control
+//   - ip: the IP address
+//
+// Returns the result, or an error wrapping ErrNotFound if the IP has not
+// been checked.
+func (d *Database) GetBotVerification(ctx context.Context, ip string) (BotVerification, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	v := BotVerification{IP: ip}
+	err := d.db.QueryRowContext(ctx, `
+		SELECT bot, status, hostname, checked_at FROM bot_verifications WHERE ip = ?
+	`, ip).Scan(&v.Bot, &v.Status, &v.Hostname, &v.CheckedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return BotVerification{}, fmt.Errorf("IP %s: %w", ip, ErrNotFound)
+	}
+	if err != nil {
+		return BotVerification{}, fmt.Errorf("failed to get bot verification: %w", err)
+	}
+	return v, nil
+}
+
+// SaveBotVerification stores a bot verification, replacing any earlier
+// result for the IP.
+//
+// Parameters:
+//   - ctx: context for cancellation and timeout control
+//   - v: the result
+//
+// Returns an error if the write fails.
+func (d *Database) SaveBotVerification(ctx context.Context, v BotVerification) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	_, err := d.db.ExecContext(ctx, `
+		INSERT INTO bot_verifications (ip, bot, status, hostname, checked_at) VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(ip) DO UPDATE SET
+			bot = excluded.bot,
+			status = excluded.status,
+			hostname = excluded.hostname,
+			checked_at = excluded.checked_at
+	`, v.IP, v.Bot, v.Status, v.Hostname, v.CheckedAt)
+	if err != nil {
+		return fmt.Errorf("failed to save bot verification: %w", err)
+	}
+	return nil
+}
+
+// GetBotVerifications returns stored bot verifications, most recently
+// checked first.
+//
+// Parameters:
+//   - ctx: context for cancellation and timeout control
+//   - status: only return results with this status (empty for all)
+//   - limit: maximum number of results to return
+//
+// Returns the results, or an error if the query fails.
+func (d *Database) GetBotVerifications(ctx context.Context, status string, limit int) ([]BotVerification, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	rows, err := d.db.QueryContext(ctx, `
+		SELECT ip, bot, status, hostname, checked_at FROM bot_verifications
+		WHERE ? = '' OR status = ?
+		ORDER BY checked_at DESC, ip
+		LIMIT ?
+	`, status, status, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query bot verifications: %w", err)
+	}
+	defer rows.Close()
+
+	var result []BotVerification
+	for rows.Next() {
+		var v BotVerification
+		if err := rows.Scan(&v.IP, &v.Bot, &v.Status, &v.Hostname, &v.CheckedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan bot verification: %w", err)
+		}
+		result = append(result, v)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating bot verifications: %w", err)
+	}
+	return result, nil
+}
+
+// GetBotVerification returns the latest bot verification of an IP address.
+//
+// Parameters:
+//   - ctx: context for cancellation and timeout control
+//   - ip: the IP address
+//
+// Returns the result, or an error wrapping ErrNotFound if the IP has not
+// been checked.
+func (s *MemoryStore) GetBotVerification(ctx context.Context, ip string) (BotVerification, error) {
+	s.botsMu.RLock()
+	defer s.botsMu.RUnlock()
+
+	v, ok := s.bots[ip]
+	if !ok {
+		return BotVerification{}, fmt.Errorf("IP %s: %w", ip, ErrNotFound)
+	}
+	return v, nil
+}
+
+// SaveBotVerification stores a bot verification, replacing any earlier
+// result for the IP. Results for new IPs are dropped once maxTrackedIPs
+// are held.
+//
+// Parameters:
+//   - ctx: context for cancellation and timeout control
+//   - v: the result
+//
+// Returns nil; the memory store never fails.
+func (s *MemoryStore) SaveBotVerification(ctx context.Context, v BotVerification) error {
+	s.botsMu.Lock()
+	defer s.botsMu.Unlock()
+
+	if _, exists := s.bots[v.IP]; exists || len(s.bots) < maxTrackedIPs {
+		s.bots[v.IP] = v
+	}
+	return nil
+}
+
+// GetBotVerifications returns stored bot verifications, most recently
+// checked first.
+//
+// Parameters:
+//   - ctx: context for cancellation and timeout control
+//   - status: only return results with this status (empty for all)
+//   - limit: maximum number of results to return
+//
+// Returns the results; the memory store never fails.
+func (s *MemoryStore) GetBotVerifications(ctx context.Context, status string, limit int) ([]BotVerification, error) {
+	s.botsMu.RLock()
+	result := make([]BotVerification, 0, len(s.bots))
+	for _, v := range s.bots {
+		if status == "" || v.Status == status {
+			result = append(result, v)
+		}
+	}
+	s.botsMu.RUnlock()
+
+	sort.Slice(result, func(i, j int) bool {
+		if !result[i].CheckedAt.Equal(result[j].CheckedAt) {
+			return result[i].CheckedAt.After(result[j].CheckedAt)
+		}
+		return result[i].IP < result[j].IP
+	})
+	if len(result) > limit {
+		result = result[:limit]
+	}
+	return result, nil
+}
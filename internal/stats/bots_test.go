@@ -0,0 +1,71 @@
+package stats
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestBotVerificationStore(t *testing.T) {
+	checked := time.Date(2025, 6, 10, 12, 0, 0, 0, time.UTC)
+	backends := map[string]BotVerificationStore{
+		"sqlite": newTestDatabase(t),
+		"memory": NewMemoryStore(NewStats()),
+	}
+
+	for backend, store := range backends {
+		t.Run(backend, func(t *testing.T) {
+			ctx := context.Background()
+			if _, err := store.GetBotVerification(ctx, "66.249.66.1"); !errors.Is(err, ErrNotFound) {
+				t.Fatalf("GetBotVerification() error = %v, want ErrNotFound", err)
+			}
+
+			for _, v := range []BotVerification{
+				{IP: "66.249.66.1", Bot: "Googlebot", Status: BotUnknown, CheckedAt: checked},
+				{IP: "203.0.113.1", Bot: "Bingbot", Status: BotSpoofed, CheckedAt: checked.Add(time.Minute)},
+				// Replaces the earlier result
+				{IP: "66.249.66.1", Bot: "Googlebot", Status: BotVerified, Hostname: "crawl-66-249-66-1.googlebot.com", CheckedAt: checked.Add(2 * time.Minute)},
+			} {
+				if err := store.SaveBotVerification(ctx, v); err != nil {
+					t.Fatalf("SaveBotVerification() error = %v", err)
+				}
+			}
+
+			got, err := store.GetBotVerification(ctx, "66.249.66.1")
+			if err != nil {
+				t.Fatalf("GetBotVerification() error = %v", err)
+			}
+			if got.Status != BotVerified || got.Hostname != "crawl-66-249-66-1.googlebot.com" || !got.CheckedAt.Equal(checked.Add(2*time.Minute)) {
+				t.Errorf("GetBotVerification() = %+v, want the latest result", got)
+			}
+
+			all, err := store.GetBotVerifications(ctx, "", 10)
+			if err != nil {
+				t.Fatalf("GetBotVerifications() error = %v", err)
+			}
+			if len(all) != 2 || all[0].IP != "66.249.66.1" || all[1].IP != "203.0.113.1" {
+				t.Errorf("GetBotVerifications() = %+v, want both IPs, most recently checked first", all)
+			}
+			spoofed, err := store.GetBotVerifications(ctx, BotSpoofed, 10)
+			if err != nil {
+				t.Fatalf("GetBotVerifications() error = %v", err)
+			}
+			if len(spoofed) != 1 || spoofed[0].IP != "203.0.113.1" {
+				t.Errorf("GetBotVerifications(spoofed) = %+v, want 203.0.113.1", spoofed)
+			}
+
+			// IP details include the result
+			if _, err := store.(Store).RecordRequests(ctx, []RequestInfo{{IP: "66.249.66.1", UserAgent: "Googlebot/2.1", Path: "/", Timestamp: checked}}); err != nil {
+				t.Fatalf("RecordRequests() error = %v", err)
+			}
+			detail, err := store.(DetailReporter).GetIPDetail(ctx, "66.249.66.1", 10)
+			if err != nil {
+				t.Fatalf("GetIPDetail() error = %v", err)
+			}
+			if detail.Bot == nil || detail.Bot.Status != BotVerified || detail.Bot.Bot != "Googlebot" {
+				t.Errorf("GetIPDetail().Bot = %+v, want the verified result", detail.Bot)
+			}
+		})
+	}
+}
@@ -0,0 +1,228 @@
+package stats
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// maxRecentCaptures is the number of request captures the memory store keeps.
+const maxRecentCaptures = 100
+
+// RequestCapture is the method, query string and body sample of a trap
+// request.
+type RequestCapture struct {
+	ID          int64     `json:"id"`
+	Timestamp   time.Time `json:"timestamp"`
+	IP          string    `json:"ip"`
+	UserAgent   string    `json:"userAgent"`
+	Method      string    `json:"method"`
+	Path        string    `json:"path"`
+	Query       string    `json:"query"`       // Raw query string, redacted
+	ContentType string    `json:"contentType"` // Content-Type header of the body
+	BodySize    int64     `json:"bodySize"`    // Bytes of body received
+	Body        []byte    `json:"body"`        // Redacted body sample
+	Truncated   bool      `json:"truncated"`   // Whether Body is shorter than the body received
+}
+
+// CaptureStore is implemented by stores that keep request captures.
+type CaptureStore interface {
+	// SaveCapture stores a request capture.
+	SaveCapture(ctx context.Context, c RequestCapture) error
+	// GetCapture returns a request capture, or an error wrapping
+	// ErrNotFound if there is none with the ID.
+	GetCapture(ctx context.Context, id int64) (RequestCapture, error)
+	// GetCaptures returns up to limit request captures, newest first,
+	// optionally only those with the given method.
+	GetCaptures(ctx context.Context, method string, limit int) ([]RequestCapture, error)
+}
+
+// captureColumns are the request_captures columns scanned by scanCapture.
+const captureColumns = `id, timestamp, ip, user_agent, method, path, query, content_type, body_size, body, truncated`
+
+// scanCapture scans a request_captures row selected with captureColumns.
+func scanCapture(scan func(dest ...any) error) (RequestCapture, error) {
+	var c RequestCapture
+	err := scan(&c.ID, &c.Timestamp, &c.IP, &c.UserAgent, &c.Method, &c.Path, &c.Query, &c.ContentType, &c.BodySize, &c.Body, &c.Truncated)
+	return c, err
+}
+
+// SaveCapture stores a request capture.
+//
+// Parameters:
+//   - ctx: context for cancellation and timeout control
+//   - c: the capture; its ID is ignored
+//
+// Returns an error if the write fails.
+func (d *Database) SaveCapture(ctx context.Context, c RequestCapture) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if c.Body == nil {
+		c.Body = []byte{}
+	}
+	_, err := d.db.ExecContext(ctx, `
+		INSERT INTO request_captures (timestamp, ip, user_agent, method, path, query, content_type, body_size, body, truncated)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, c.Timestamp, c.IP, c.UserAgent, c.Method, c.Path, c.Query, c.ContentType, c.BodySize, c.Body, c.Truncated)
+	if err != nil {
+		return fmt.Errorf("failed to save request capture: %w", err)
+	}
+	return nil
+}
+
+// GetCapture returns a request capture by ID.
+//
+// Parameters:
+//   - ctx: context for cancellation and timeout control
+//   - id: the capture ID
+//
+// Returns the capture, or an error wrapping ErrNotFound if there is none
+// with the ID.
+func (d *Database) GetCapture(ctx context.Context, id int64) (RequestCapture, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	row := d.db.QueryRowContext(ctx, `SELECT `+captureColumns+` FROM request_captures WHERE id = ?`, id)
+	c, err := scanCapture(row.Scan)
+	if errors.Is(err, sql.ErrNoRows) {
+		return RequestCapture{}, fmt.Errorf("capture %d: %w", id, ErrNotFound)
+	}
+	if err != nil {
+		return RequestCapture{}, fmt.Errorf("failed to get request capture: %w", err)
+	}
+	return c, nil
+}
+
+// GetCaptures returns stored request captures, newest first.
+//
+// Parameters:
+//   - ctx: context for cancellation and timeout control
+//   - method: only return captures with this method (empty for all)
+//   - limit: maximum number of captures to return
+//
+// Returns the captures, or an error if the query fails.
+func (d *Database) GetCaptures(ctx context.Context, method string, limit int) ([]RequestCapture, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	rows, err := d.db.QueryContext(ctx, `
+		SELECT `+captureColumns+` FROM request_captures
+		WHERE ? = '' OR method = ?
+		ORDER BY timestamp DESC, id DESC
+		LIMIT ?
+	`, method, method, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query request captures: %w", err)
+	}
+	defer rows.Close()
+
+	var result []RequestCapture
+	for rows.Next() {
+		c, err := scanCapture(rows.Scan)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan request capture: %w", err)
+		}
+		result = append(result, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating request captures: %w", err)
+	}
+	return result, nil
+}
+
+// PruneCaptures deletes request captures recorded before cutoff.
+//
+// Parameters:
+//   - ctx: context for cancellation and timeout control
+//   - cutoff: captures older than this are deleted
+//
+// Returns the number of captures deleted, or an error if the delete fails.
+func (d *Database) PruneCaptures(ctx context.Context, cutoff time.Time) (int, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	result, err := d.db.ExecContext(ctx, `DELETE FROM request_captures WHERE timestamp < ?`, cutoff)
+	if err != nil {
+		return 0, fmt.Errorf("failed to prune request captures: %w", err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to count pruned request captures: %w", err)
+	}
+	return int(n), nil
+}
+
+// SaveCapture stores a request capture, keeping only the latest
+// maxRecentCaptures.
+//
+// Parameters:
+//   - ctx: context for cancellation and timeout control
+//   - c: the capture; its ID is assigned by the store
+//
+// Returns nil; the memory store never fails.
+func (s *MemoryStore) SaveCapture(ctx context.Context, c RequestCapture) error {
+	s.capturesMu.Lock()
+	defer s.capturesMu.Unlock()
+
+	s.captureID++
+	c.ID = s.captureID
+	s.captures = append(s.captures, c)
+	if len(s.captures) > maxRecentCaptures {
+		s.captures = s.captures[1:]
+	}
+	return nil
+}
+
+// GetCapture returns a request capture by ID.
+//
+// Parameters:
+//   - ctx: context for cancellation and timeout control
+//   - id: the capture ID
+//
+// Returns the capture, or an error wrapping ErrNotFound if there is none
+// with the ID or it is no longer kept.
+func (s *MemoryStore) GetCapture(ctx context.Context, id int64) (RequestCapture, error) {
+	s.capturesMu.RLock()
+	defer s.capturesMu.RUnlock()
+
+	for _, c := range s.captures {
+		if c.ID == id {
+			return c, nil
+		}
+	}
+	return RequestCapture{}, fmt.Errorf("capture %d: %w", id, ErrNotFound)
+}
+
+// GetCaptures returns stored request captures, newest first.
+//
+// Parameters:
+//   - ctx: context for cancellation and timeout control
+//   - method: only return captures with this method (empty for all)
+//   - limit: maximum number of captures to return
+//
+// Returns the captures; the memory store never fails.
+func (s *MemoryStore) GetCaptures(ctx context.Context, method string, limit int) ([]RequestCapture, error) {
+	s.capturesMu.RLock()
+	result := make([]RequestCapture, 0, len(s.captures))
+	for _, c := range s.captures {
+		if method == "" || c.Method == method {
+			result = append(result, c)
+		}
+	}
+	s.capturesMu.RUnlock()
+
+	sort.SliceStable(result, func(i, j int) bool {
+		if !result[i].Timestamp.Equal(result[j].Timestamp) {
+			return result[i].Timestamp.After(result[j].Timestamp)
+		}
+		return result[i].ID > result[j].ID
+	})
+	if len(result) > limit {
+		result = result[:limit]
+	}
+	return result, nil
+}
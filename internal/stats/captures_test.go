@@ -0,0 +1,77 @@
+package stats
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCaptureStore(t *testing.T) {
+	now := time.Date(2025, 6, 10, 12, 0, 0, 0, time.UTC)
+	backends := map[string]CaptureStore{
+		"sqlite": newTestDatabase(t),
+		"memory": NewMemoryStore(NewStats()),
+	}
+
+	for backend, store := range backends {
+		t.Run(backend, func(t *testing.T) {
+			ctx := context.Background()
+			for _, c := range []RequestCapture{
+				{Timestamp: now, IP: "192.0.2.1", UserAgent: "sqlmap", Method: "GET", Path: "/item", Query: "id=1'--"},
+				{Timestamp: now.Add(time.Minute), IP: "192.0.2.2", UserAgent: "curl", Method: "POST", Path: "/upload",
+					ContentType: "application/octet-stream", BodySize: 5000, Body: []byte{0x00, 0xff, 'a'}, Truncated: true},
+			} {
+				if err := store.SaveCapture(ctx, c); err != nil {
+					t.Fatalf("SaveCapture() error = %v", err)
+				}
+			}
+
+			all, err := store.GetCaptures(ctx, "", 10)
+			if err != nil {
+				t.Fatalf("GetCaptures() error = %v", err)
+			}
+			if len(all) != 2 || all[0].Method != "POST" || all[1].Query != "id=1'--" {
+				t.Fatalf("GetCaptures() = %+v, want both captures newest first", all)
+			}
+
+			got, err := store.GetCapture(ctx, all[0].ID)
+			if err != nil {
+				t.Fatalf("GetCapture() error = %v", err)
+			}
+			if string(got.Body) != "\x00\xffa" || got.BodySize != 5000 || !got.Truncated || got.ContentType != "application/octet-stream" {
+				t.Errorf("GetCapture() = %+v, want the binary upload", got)
+			}
+			if _, err := store.GetCapture(ctx, 999); !errors.Is(err, ErrNotFound) {
+				t.Errorf("GetCapture(999) error = %v, want ErrNotFound", err)
+			}
+
+			gets, err := store.GetCaptures(ctx, "GET", 10)
+			if err != nil {
+				t.Fatalf("GetCaptures(GET) error = %v", err)
+			}
+			if len(gets) != 1 || gets[0].IP != "192.0.2.1" || len(gets[0].Body) != 0 {
+				t.Errorf("GetCaptures(GET) = %+v, want the GET capture", gets)
+			}
+		})
+	}
+}
+
+func TestPruneCaptures(t *testing.T) {
+	db := newTestDatabase(t)
+	ctx := context.Background()
+	now := time.Now()
+	for _, ts := range []time.Time{now.Add(-48 * time.Hour), now} {
+		if err := db.SaveCapture(ctx, RequestCapture{Timestamp: ts, IP: "192.0.2.1", Method: "POST", Path: "/"}); err != nil {
+			t.Fatalf("SaveCapture() error = %v", err)
+		}
+	}
+
+	pruned, err := db.PruneCaptures(ctx, now.Add(-24*time.Hour))
+	if err != nil || pruned != 1 {
+		t.Errorf("PruneCaptures() = %d, %v, want 1", pruned, err)
+	}
+	if left, _ := db.GetCaptures(ctx, "", 10); len(left) != 1 {
+		t.Errorf("%d captures left, want 1", len(left))
+	}
+}
@@ -8,70 +8,43 @@ import (
 	"sync"
 	"time"
 
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	_ "modernc.org/sqlite" // Pure Go SQLite driver
+
+	"github.com/rampantspark/gospidertrap/internal/geoip"
+	"github.com/rampantspark/gospidertrap/internal/metrics"
+	"github.com/rampantspark/gospidertrap/internal/tracing"
 )
 
 // Database handles SQLite persistence for statistics and request logs.
+// It implements Store and StorageReporter.
 type Database struct {
 	db     *sql.DB
 	mu     sync.RWMutex
+	path   string
 	logger *slog.Logger
+
+	writeLatency *metrics.Histogram // RecordRequest latency (nil if not instrumented)
+	writeErrors  *metrics.Counter   // RecordRequest failures (nil if not instrumented)
+	geo          *geoip.Resolver    // Annotates ip_counts (nil without GeoIP databases)
 }
 
 // CountEntry represents a label and count pair in sorted order.
 type CountEntry struct {
-	Label string
-	Count int
+	Label string `json:"label"`
+	Count int    `json:"count"`
 }
 
-// Schema for the database tables
-const schema = `
--- Stats table (replaces stats.json)
-CREATE TABLE IF NOT EXISTS stats (
-    id INTEGER PRIMARY KEY CHECK (id = 1),
-    start_time TIMESTAMP NOT NULL,
-    total_requests INTEGER NOT NULL DEFAULT 0,
-    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
-);
-
--- IP tracking (replaces IPCounts map)
-CREATE TABLE IF NOT EXISTS ip_counts (
-    ip TEXT PRIMARY KEY CHECK(length(ip) <= 45 AND length(ip) > 0),
-    count INTEGER NOT NULL DEFAULT 1 CHECK(count > 0),
-    first_seen TIMESTAMP NOT NULL,
-    last_seen TIMESTAMP NOT NULL
-);
-CREATE INDEX IF NOT EXISTS idx_ip_count ON ip_counts(count DESC);
-
--- User agent tracking (replaces UserAgents map)
-CREATE TABLE IF NOT EXISTS user_agent_counts (
-    user_agent TEXT PRIMARY KEY CHECK(length(user_agent) <= 512 AND length(user_agent) > 0),
-    count INTEGER NOT NULL DEFAULT 1 CHECK(count > 0),
-    first_seen TIMESTAMP NOT NULL,
-    last_seen TIMESTAMP NOT NULL
-);
-CREATE INDEX IF NOT EXISTS idx_ua_count ON user_agent_counts(count DESC);
-
--- Request log (replaces requests.ndjson)
-CREATE TABLE IF NOT EXISTS request_log (
-    id INTEGER PRIMARY KEY AUTOINCREMENT,
-    ip TEXT NOT NULL CHECK(length(ip) <= 45 AND length(ip) > 0),
-    user_agent TEXT CHECK(user_agent IS NULL OR length(user_agent) <= 512),
-    path TEXT CHECK(path IS NULL OR length(path) <= 2048),
-    timestamp TIMESTAMP DEFAULT CURRENT_TIMESTAMP
-);
-CREATE INDEX IF NOT EXISTS idx_request_timestamp ON request_log(timestamp DESC);
-CREATE INDEX IF NOT EXISTS idx_request_ip ON request_log(ip);
-`
-
-// NewDatabase creates a new database connection and initializes the schema.
+// NewDatabase creates a new database connection and migrates the schema
+// to the latest version.
 //
 // Parameters:
 //   - dbPath: path to the SQLite database file
 //   - logger: structured logger instance
 //
-// Returns a new Database instance or an error if initialization fails.
+// Returns a new Database instance or an error if initialization fails. The
+// error wraps ErrSchemaTooNew if a newer binary has migrated the database.
 func NewDatabase(dbPath string, logger *slog.Logger) (*Database, error) {
 	db, err := sql.Open("sqlite", dbPath)
 	if err != nil {
@@ -103,10 +76,15 @@ func NewDatabase(dbPath string, logger *slog.Logger) (*Database, error) {
 		return nil, fmt.Errorf("failed to set synchronous mode: %w", err)
 	}
 
-	// Execute schema
-	if _, err := db.Exec(schema); err != nil {
+	// Apply pending schema migrations
+	migrations, err := loadMigrations(migrationFiles, sqliteDialect.migrationsDir)
+	if err != nil {
 		db.Close()
-		return nil, fmt.Errorf("failed to create schema: %w", err)
+		return nil, err
+	}
+	if err := migrate(context.Background(), db, sqliteDialect, migrations, logger); err != nil {
+		db.Close()
+		return nil, err
 	}
 
 	// Initialize stats row if it doesn't exist
@@ -123,10 +101,21 @@ func NewDatabase(dbPath string, logger *slog.Logger) (*Database, error) {
 
 	return &Database{
 		db:     db,
+		path:   dbPath,
 		logger: logger,
 	}, nil
 }
 
+// Instrument sets the metrics updated by RecordRequest and RecordRequests.
+//
+// Parameters:
+//   - latency: histogram of write transaction latency, including time waiting for the lock
+//   - errors: counter of failed write transactions
+func (d *Database) Instrument(latency *metrics.Histogram, errors *metrics.Counter) {
+	d.writeLatency = latency
+	d.writeErrors = errors
+}
+
 // RecordRequest records a request in the database.
 //
 // This updates the request log, IP counts, user agent counts, and total request count.
@@ -136,68 +125,183 @@ func NewDatabase(dbPath string, logger *slog.Logger) (*Database, error) {
 //   - ctx: context for cancellation and timeout control
 //   - req: the request information to record
 //
-// Returns an error if the database operation fails or context is cancelled.
-func (d *Database) RecordRequest(ctx context.Context, req RequestInfo) error {
+// Returns the updated IP and user agent counts, or an error if the database
+// operation fails or context is cancelled.
+func (d *Database) RecordRequest(ctx context.Context, req RequestInfo) (RecordResult, error) {
+	results, err := d.RecordRequests(ctx, []RequestInfo{req})
+	if err != nil {
+		return RecordResult{}, err
+	}
+	return results[0], nil
+}
+
+// RecordRequests records a batch of requests in a single transaction.
+//
+// Requests are applied in order, so each result reflects the counts after
+// that request and any earlier requests in the batch. Either every request
+// is recorded or none are.
+//
+// Parameters:
+//   - ctx: context for cancellation and timeout control
+//   - reqs: the requests to record
+//
+// Returns one result per request, or an error if the database operation
+// fails or context is cancelled.
+func (d *Database) RecordRequests(ctx context.Context, reqs []RequestInfo) ([]RecordResult, error) {
+	if len(reqs) == 0 {
+		return nil, nil
+	}
+
+	ctx, span := tracing.Start(ctx, "stats.Database.RecordRequests",
+		trace.WithAttributes(attribute.Int("db.operation.batch.size", len(reqs))))
+	start := time.Now()
+	results, err := d.recordRequests(ctx, reqs)
+	d.writeLatency.ObserveDuration(time.Since(start))
+	if err != nil {
+		d.writeErrors.Inc()
+	}
+	tracing.End(span, err)
+	return results, err
+}
+
+// recordRequests performs the RecordRequests transaction.
+//
+// Each statement is prepared once and executed for every request in the
+// batch, with one span per statement covering the whole batch.
+func (d *Database) recordRequests(ctx context.Context, reqs []RequestInfo) ([]RecordResult, error) {
+	// Trace time spent waiting for the write lock separately from the SQL
+	_, lockSpan := tracing.Start(ctx, "stats.Database lock wait")
 	d.mu.Lock()
+	lockSpan.End()
 	defer d.mu.Unlock()
 
+	results := make([]RecordResult, len(reqs))
+
+	span := startStatementSpan(ctx, "sqlite", "BEGIN", "")
 	tx, err := d.db.BeginTx(ctx, nil)
+	tracing.End(span, err)
 	if err != nil {
-		return fmt.Errorf("failed to begin transaction: %w", err)
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
 	}
 	defer tx.Rollback()
 
-	// Insert request log entry
-	_, err = tx.ExecContext(ctx, `
-		INSERT INTO request_log (ip, user_agent, path, timestamp)
-		VALUES (?, ?, ?, ?)
-	`, req.IP, req.UserAgent, req.Path, req.Timestamp)
+	// Insert request log entries
+	span = startStatementSpan(ctx, "sqlite", "INSERT", "request_log")
+	err = execEach(ctx, tx, `
+		INSERT INTO request_log (ip, user_agent, path, timestamp, timestamp_ms, bait)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`, len(reqs), func(i int, stmt *sql.Stmt) error {
+		_, err := stmt.ExecContext(ctx, reqs[i].IP, reqs[i].UserAgent, reqs[i].Path, reqs[i].Timestamp, reqs[i].Timestamp.UnixMilli(), reqs[i].Bait)
+		return err
+	})
+	tracing.End(span, err)
 	if err != nil {
-		return fmt.Errorf("failed to insert request log: %w", err)
+		return nil, fmt.Errorf("failed to insert request log: %w", err)
 	}
 
-	// Update IP count
-	_, err = tx.ExecContext(ctx, `
-		INSERT INTO ip_counts (ip, count, first_seen, last_seen)
-		VALUES (?, 1, ?, ?)
+	// Update IP counts
+	span = startStatementSpan(ctx, "sqlite", "UPSERT", "ip_counts")
+	err = execEach(ctx, tx, `
+		INSERT INTO ip_counts (ip, count, first_seen, last_seen, last_seen_ms, country, asn, as_org)
+		VALUES (?, 1, ?, ?, ?, ?, ?, ?)
 		ON CONFLICT(ip) DO UPDATE SET
 			count = count + 1,
-			last_seen = ?
-	`, req.IP, req.Timestamp, req.Timestamp, req.Timestamp)
+			last_seen = excluded.last_seen,
+			last_seen_ms = excluded.last_seen_ms,
+			country = COALESCE(excluded.country, country),
+			asn = COALESCE(excluded.asn, asn),
+			as_org = COALESCE(excluded.as_org, as_org)
+		RETURNING count
+	`, len(reqs), func(i int, stmt *sql.Stmt) error {
+		country, asn, org := geoColumns(d.geo.Lookup(reqs[i].IP))
+		return stmt.QueryRowContext(ctx, reqs[i].IP, reqs[i].Timestamp, reqs[i].Timestamp, reqs[i].Timestamp.UnixMilli(), country, asn, org).Scan(&results[i].IPCount)
+	})
+	tracing.End(span, err)
 	if err != nil {
-		return fmt.Errorf("failed to update IP count: %w", err)
+		return nil, fmt.Errorf("failed to update IP count: %w", err)
 	}
 
-	// Update user agent count
-	_, err = tx.ExecContext(ctx, `
-		INSERT INTO user_agent_counts (user_agent, count, first_seen, last_seen)
-		VALUES (?, 1, ?, ?)
+	// Update user agent counts
+	span = startStatementSpan(ctx, "sqlite", "UPSERT", "user_agent_counts")
+	err = execEach(ctx, tx, `
+		INSERT INTO user_agent_counts (user_agent, count, first_seen, last_seen, last_seen_ms)
+		VALUES (?, 1, ?, ?, ?)
 		ON CONFLICT(user_agent) DO UPDATE SET
 			count = count + 1,
-			last_seen = ?
-	`, req.UserAgent, req.Timestamp, req.Timestamp, req.Timestamp)
+			last_seen = excluded.last_seen,
+			last_seen_ms = excluded.last_seen_ms
+		RETURNING count
+	`, len(reqs), func(i int, stmt *sql.Stmt) error {
+		return stmt.QueryRowContext(ctx, reqs[i].UserAgent, reqs[i].Timestamp, reqs[i].Timestamp, reqs[i].Timestamp.UnixMilli()).Scan(&results[i].UserAgentCount)
+	})
+	tracing.End(span, err)
 	if err != nil {
-		return fmt.Errorf("failed to update user agent count: %w", err)
+		return nil, fmt.Errorf("failed to update user agent count: %w", err)
 	}
 
 	// Increment total requests
+	span = startStatementSpan(ctx, "sqlite", "UPDATE", "stats")
 	_, err = tx.ExecContext(ctx, `
 		UPDATE stats
-		SET total_requests = total_requests + 1,
+		SET total_requests = total_requests + ?,
 		    updated_at = ?
 		WHERE id = 1
-	`, time.Now())
+	`, len(reqs), time.Now())
+	tracing.End(span, err)
 	if err != nil {
-		return fmt.Errorf("failed to update total requests: %w", err)
+		return nil, fmt.Errorf("failed to update total requests: %w", err)
 	}
 
-	if err := tx.Commit(); err != nil {
-		return fmt.Errorf("failed to commit transaction: %w", err)
+	span = startStatementSpan(ctx, "sqlite", "COMMIT", "")
+	err = tx.Commit()
+	tracing.End(span, err)
+	if err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
 	}
 
+	return results, nil
+}
+
+// execEach prepares a statement in a transaction and calls fn for each of
+// n rows, stopping at the first error.
+func execEach(ctx context.Context, tx *sql.Tx, query string, n int, fn func(i int, stmt *sql.Stmt) error) error {
+	stmt, err := tx.PrepareContext(ctx, query)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for i := 0; i < n; i++ {
+		if err := fn(i, stmt); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
+// startStatementSpan starts a client span for a single SQL statement.
+//
+// Parameters:
+//   - ctx: parent context
+//   - system: database system, e.g. sqlite or postgresql
+//   - operation: SQL operation, e.g. INSERT
+//   - table: table the statement targets (empty for transaction control)
+//
+// Returns the span, which the caller must end.
+func startStatementSpan(ctx context.Context, system, operation, table string) trace.Span {
+	name := system + " " + operation
+	attrs := []attribute.KeyValue{
+		attribute.String("db.system.name", system),
+		attribute.String("db.operation.name", operation),
+	}
+	if table != "" {
+		name += " " + table
+		attrs = append(attrs, attribute.String("db.collection.name", table))
+	}
+	_, span := tracing.Start(ctx, name, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attrs...))
+	return span
+}
+
 // GetStats retrieves the current statistics.
 //
 // Parameters:
@@ -272,6 +376,35 @@ func (d *Database) GetStats(ctx context.Context) (*Stats, error) {
 	return stats, nil
 }
 
+// Backend returns "sqlite".
+func (d *Database) Backend() string {
+	return "sqlite"
+}
+
+// GetSummary returns the overall request totals.
+//
+// Parameters:
+//   - ctx: context for cancellation and timeout control
+//
+// Returns the summary or an error if the query fails.
+func (d *Database) GetSummary(ctx context.Context) (Summary, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	var summary Summary
+	err := d.db.QueryRowContext(ctx, `
+		SELECT start_time, total_requests,
+		       (SELECT COUNT(*) FROM ip_counts),
+		       (SELECT COUNT(*) FROM user_agent_counts)
+		FROM stats
+		WHERE id = 1
+	`).Scan(&summary.StartTime, &summary.TotalRequests, &summary.UniqueIPs, &summary.UniqueUserAgents)
+	if err != nil {
+		return Summary{}, fmt.Errorf("failed to get summary: %w", err)
+	}
+	return summary, nil
+}
+
 // GetRecentRequests retrieves the most recent N requests from the database.
 //
 // Parameters:
@@ -284,9 +417,9 @@ func (d *Database) GetRecentRequests(ctx context.Context, limit int) ([]RequestI
 	defer d.mu.RUnlock()
 
 	rows, err := d.db.QueryContext(ctx, `
-		SELECT ip, user_agent, path, timestamp
+		SELECT ip, user_agent, path, timestamp, bait
 		FROM request_log
-		ORDER BY timestamp DESC
+		ORDER BY timestamp_ms DESC, id DESC
 		LIMIT ?
 	`, limit)
 	if err != nil {
@@ -297,7 +430,7 @@ func (d *Database) GetRecentRequests(ctx context.Context, limit int) ([]RequestI
 	var requests []RequestInfo
 	for rows.Next() {
 		var req RequestInfo
-		if err := rows.Scan(&req.IP, &req.UserAgent, &req.Path, &req.Timestamp); err != nil {
+		if err := rows.Scan(&req.IP, &req.UserAgent, &req.Path, &req.Timestamp, &req.Bait); err != nil {
 			return nil, fmt.Errorf("failed to scan request: %w", err)
 		}
 		requests = append(requests, req)
@@ -383,6 +516,46 @@ func (d *Database) GetTopUserAgents(ctx context.Context, limit int) ([]CountEntr
 	return result, nil
 }
 
+// GetIPActivity retrieves per-IP activity filtered by hit count and recency.
+//
+// Parameters:
+//   - ctx: context for cancellation and timeout control
+//   - minHits: minimum number of requests an IP must have made
+//   - since: only include IPs seen at or after this time (zero for no limit)
+//
+// Returns a slice of IPActivity ordered by last seen time (most recent first).
+func (d *Database) GetIPActivity(ctx context.Context, minHits int, since time.Time) ([]IPActivity, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	rows, err := d.db.QueryContext(ctx, `
+		SELECT ip, count, first_seen, last_seen
+		FROM ip_counts
+		WHERE count >= ? AND last_seen_ms >= ?
+		ORDER BY last_seen_ms DESC
+	`, minHits, since.UnixMilli())
+	if err != nil {
+		return nil, fmt.Errorf("failed to query IP activity: %w", err)
+	}
+	defer rows.Close()
+
+	var result []IPActivity
+	for rows.Next() {
+		var entry IPActivity
+		if err := rows.Scan(&entry.IP, &entry.Count, &entry.FirstSeen, &entry.LastSeen); err != nil {
+			return nil, fmt.Errorf("failed to scan IP activity: %w", err)
+		}
+		if entry.LastSeen.Before(since) {
+			continue // Within the millisecond since falls in
+		}
+		result = append(result, entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating IP activity: %w", err)
+	}
+	return result, nil
+}
+
 // Close closes the database connection.
 //
 // Returns an error if the close operation fails.
@@ -0,0 +1,284 @@
+package stats
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"time"
+)
+
+// DefaultSessionGap is the idle time after which an IP's next request
+// starts a new session.
+const DefaultSessionGap = 30 * time.Minute
+
+// maxTimelineBuckets bounds the length of a Timeline. Longer histories keep
+// only their most recent buckets.
+const maxTimelineBuckets = 90
+
+// timelineIntervals are the bucket widths a Timeline can use, narrowest
+// first.
+var timelineIntervals = []time.Duration{time.Hour, 24 * time.Hour, 7 * 24 * time.Hour}
+
+// TimelineBucket is the number of requests in one interval of a Timeline.
+type TimelineBucket struct {
+	Start    time.Time `json:"start"`
+	Requests int       `json:"requests"`
+}
+
+// Timeline is a histogram of requests over time, with an empty bucket for
+// every interval without requests.
+type Timeline struct {
+	Interval time.Duration    `json:"interval"` // Width of each bucket
+	Buckets  []TimelineBucket `json:"buckets"`  // Buckets, oldest first
+}
+
+// Max returns the largest bucket's request count.
+func (t Timeline) Max() int {
+	highest := 0
+	for _, b := range t.Buckets {
+		highest = max(highest, b.Requests)
+	}
+	return highest
+}
+
+// newTimeline buckets requests by time, using the narrowest interval that
+// fits their span in maxTimelineBuckets.
+//
+// Parameters:
+//   - reqs: the requests, in any order
+//
+// Returns the timeline, or an empty timeline if there are no requests.
+func newTimeline(reqs []RequestInfo) Timeline {
+	if len(reqs) == 0 {
+		return Timeline{}
+	}
+	first, last := reqs[0].Timestamp, reqs[0].Timestamp
+	for _, req := range reqs {
+		if req.Timestamp.Before(first) {
+			first = req.Timestamp
+		}
+		if req.Timestamp.After(last) {
+			last = req.Timestamp
+		}
+	}
+
+	interval := timelineIntervals[len(timelineIntervals)-1]
+	for _, candidate := range timelineIntervals {
+		if last.Sub(first)/candidate < maxTimelineBuckets {
+			interval = candidate
+			break
+		}
+	}
+
+	start := first.UTC().Truncate(interval)
+	end := last.UTC().Truncate(interval)
+	if int(end.Sub(start)/interval) >= maxTimelineBuckets {
+		start = end.Add(-time.Duration(maxTimelineBuckets-1) * interval)
+	}
+	t := Timeline{Interval: interval}
+	for at := start; !at.After(end); at = at.Add(interval) {
+		t.Buckets = append(t.Buckets, TimelineBucket{Start: at})
+	}
+	for _, req := range reqs {
+		i := int(req.Timestamp.UTC().Sub(start) / interval)
+		if req.Timestamp.Before(start) || i >= len(t.Buckets) {
+			continue
+		}
+		t.Buckets[i].Requests++
+	}
+	return t
+}
+
+// fill sets the parts of the detail derived from the IP's logged requests.
+//
+// Parameters:
+//   - reqs: the logged requests, newest first
+//   - limit: maximum number of user agents, paths, sessions and recent requests
+func (d *IPDetail) fill(reqs []RequestInfo, limit int) {
+	userAgents := make(map[string]int)
+	paths := make(map[string]int)
+	for _, req := range reqs {
+		userAgents[req.UserAgent]++
+		paths[req.Path]++
+	}
+	d.Logged = len(reqs)
+	d.UserAgents = topCounts(userAgents, limit)
+	d.Paths = topCounts(paths, limit)
+	d.Timeline = newTimeline(reqs)
+	d.Recent = reqs[:min(limit, len(reqs))]
+
+	sorted := slices.Clone(reqs)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Timestamp.Before(sorted[j].Timestamp)
+	})
+	d.Sessions = groupSessions(d.IP, sorted, DefaultSessionGap)
+	slices.Reverse(d.Sessions)
+	d.Sessions = d.Sessions[:min(limit, len(d.Sessions))]
+}
+
+// UserAgentDetail describes everything recorded about one user agent.
+type UserAgentDetail struct {
+	UserAgent string        `json:"userAgent"`
+	Count     int           `json:"count"`     // All-time request count
+	FirstSeen time.Time     `json:"firstSeen"` // Time of the first request
+	LastSeen  time.Time     `json:"lastSeen"`  // Time of the most recent request
+	Logged    int           `json:"logged"`    // Requests still in the request log
+	IPs       []CountEntry  `json:"ips"`       // IPs in the request log, most requests first
+	Paths     []CountEntry  `json:"paths"`     // Paths in the request log, most requested first
+	Timeline  Timeline      `json:"timeline"`  // Requests in the request log over time
+	Recent    []RequestInfo `json:"recent"`    // Most recent requests, newest first
+}
+
+// fill sets the parts of the detail derived from the user agent's logged
+// requests.
+//
+// Parameters:
+//   - reqs: the logged requests, newest first
+//   - limit: maximum number of IPs, paths and recent requests
+func (d *UserAgentDetail) fill(reqs []RequestInfo, limit int) {
+	ips := make(map[string]int)
+	paths := make(map[string]int)
+	for _, req := range reqs {
+		ips[req.IP]++
+		paths[req.Path]++
+	}
+	d.Logged = len(reqs)
+	d.IPs = topCounts(ips, limit)
+	d.Paths = topCounts(paths, limit)
+	d.Timeline = newTimeline(reqs)
+	d.Recent = reqs[:min(limit, len(reqs))]
+}
+
+// DetailReporter is implemented by stores that can describe a single IP
+// address or user agent.
+type DetailReporter interface {
+	GetIPDetail(ctx context.Context, ip string, limit int) (IPDetail, error)
+	GetUserAgentDetail(ctx context.Context, userAgent string, limit int) (UserAgentDetail, error)
+}
+
+// GetUserAgentDetail retrieves the counts and logged requests for one user
+// agent.
+//
+// Parameters:
+//   - ctx: context for cancellation and timeout control
+//   - userAgent: the user agent
+//   - limit: maximum number of IPs, paths and recent requests to return
+//
+// Returns the detail, or an error wrapping ErrNotFound if the user agent has
+// never been seen.
+func (d *Database) GetUserAgentDetail(ctx context.Context, userAgent string, limit int) (UserAgentDetail, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	detail := UserAgentDetail{UserAgent: userAgent}
+	err := d.db.QueryRowContext(ctx, `
+		SELECT count, first_seen, last_seen
+		FROM user_agent_counts
+		WHERE user_agent = ?
+	`, userAgent).Scan(&detail.Count, &detail.FirstSeen, &detail.LastSeen)
+	if errors.Is(err, sql.ErrNoRows) {
+		return UserAgentDetail{}, fmt.Errorf("user agent %q: %w", userAgent, ErrNotFound)
+	}
+	if err != nil {
+		return UserAgentDetail{}, fmt.Errorf("failed to query user agent: %w", err)
+	}
+
+	rows, err := d.db.QueryContext(ctx, `
+		SELECT ip, COALESCE(path, ''), timestamp
+		FROM request_log
+		WHERE COALESCE(user_agent, '') = ?
+		ORDER BY id DESC
+	`, userAgent)
+	if err != nil {
+		return UserAgentDetail{}, fmt.Errorf("failed to query user agent requests: %w", err)
+	}
+	defer rows.Close()
+
+	var reqs []RequestInfo
+	for rows.Next() {
+		req := RequestInfo{UserAgent: userAgent}
+		if err := rows.Scan(&req.IP, &req.Path, &req.Timestamp); err != nil {
+			return UserAgentDetail{}, fmt.Errorf("failed to scan request: %w", err)
+		}
+		reqs = append(reqs, req)
+	}
+	if err := rows.Err(); err != nil {
+		return UserAgentDetail{}, fmt.Errorf("error iterating user agent requests: %w", err)
+	}
+
+	detail.fill(reqs, limit)
+	return detail, nil
+}
+
+// GetIPDetail describes one IP address from its count and the retained
+// recent requests.
+//
+// First-seen times are not tracked in memory, so FirstSeen is always zero.
+//
+// Parameters:
+//   - ctx: context for cancellation and timeout control
+//   - ip: the IP address
+//   - limit: maximum number of user agents, paths, sessions and recent requests to return
+//
+// Returns the detail, or an error wrapping ErrNotFound if the IP has never
+// been seen.
+func (s *MemoryStore) GetIPDetail(ctx context.Context, ip string, limit int) (IPDetail, error) {
+	s.stats.Mu.RLock()
+	defer s.stats.Mu.RUnlock()
+
+	count, ok := s.stats.IPCounts[ip]
+	if !ok {
+		return IPDetail{}, fmt.Errorf("IP %s: %w", ip, ErrNotFound)
+	}
+	detail := IPDetail{IP: ip, Count: count, LastSeen: s.stats.IPLastSeen[ip], Geo: s.geo.Lookup(ip)}
+	if v, err := s.GetBotVerification(ctx, ip); err == nil {
+		detail.Bot = &v
+	}
+	detail.fill(s.recentMatching(func(req RequestInfo) bool { return req.IP == ip }), limit)
+	return detail, nil
+}
+
+// GetUserAgentDetail describes one user agent from its count and the
+// retained recent requests.
+//
+// First- and last-seen times are not tracked in memory for user agents, so
+// LastSeen is the time of the most recent retained request.
+//
+// Parameters:
+//   - ctx: context for cancellation and timeout control
+//   - userAgent: the user agent
+//   - limit: maximum number of IPs, paths and recent requests to return
+//
+// Returns the detail, or an error wrapping ErrNotFound if the user agent has
+// never been seen.
+func (s *MemoryStore) GetUserAgentDetail(ctx context.Context, userAgent string, limit int) (UserAgentDetail, error) {
+	s.stats.Mu.RLock()
+	defer s.stats.Mu.RUnlock()
+
+	count, ok := s.stats.UserAgents[userAgent]
+	if !ok {
+		return UserAgentDetail{}, fmt.Errorf("user agent %q: %w", userAgent, ErrNotFound)
+	}
+	detail := UserAgentDetail{UserAgent: userAgent, Count: count}
+	reqs := s.recentMatching(func(req RequestInfo) bool { return req.UserAgent == userAgent })
+	if len(reqs) > 0 {
+		detail.LastSeen = reqs[0].Timestamp
+	}
+	detail.fill(reqs, limit)
+	return detail, nil
+}
+
+// recentMatching returns the retained recent requests for which match
+// returns true, newest first. The caller must hold s.stats.Mu.
+func (s *MemoryStore) recentMatching(match func(RequestInfo) bool) []RequestInfo {
+	var result []RequestInfo
+	for i := len(s.stats.RecentRequests) - 1; i >= 0; i-- {
+		if req := s.stats.RecentRequests[i]; match(req) {
+			result = append(result, req)
+		}
+	}
+	return result
+}
@@ -0,0 +1,113 @@
+package stats
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestNewTimeline(t *testing.T) {
+	start := time.Date(2025, 6, 10, 12, 30, 0, 0, time.UTC)
+	at := func(offsets ...time.Duration) []RequestInfo {
+		var reqs []RequestInfo
+		for _, offset := range offsets {
+			reqs = append(reqs, RequestInfo{Timestamp: start.Add(offset)})
+		}
+		return reqs
+	}
+
+	tests := []struct {
+		name         string
+		reqs         []RequestInfo
+		wantInterval time.Duration
+		wantBuckets  int
+		wantFirst    int // Requests in the first bucket
+		wantMax      int
+	}{
+		{"empty", nil, 0, 0, 0, 0},
+		{"hours with a gap", at(0, 10*time.Minute, 3*time.Hour), time.Hour, 4, 2, 2},
+		{"days", at(0, 10*24*time.Hour, 10*24*time.Hour+time.Minute), 24 * time.Hour, 11, 1, 2},
+		{"weeks", at(0, 200*24*time.Hour), 7 * 24 * time.Hour, 29, 1, 1},
+		{"capped to the most recent buckets", at(0, 1000*24*time.Hour), 7 * 24 * time.Hour, maxTimelineBuckets, 0, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := newTimeline(tt.reqs)
+			if got.Interval != tt.wantInterval || len(got.Buckets) != tt.wantBuckets {
+				t.Fatalf("interval %s with %d buckets, want %s with %d", got.Interval, len(got.Buckets), tt.wantInterval, tt.wantBuckets)
+			}
+			if len(got.Buckets) > 0 && got.Buckets[0].Requests != tt.wantFirst {
+				t.Errorf("first bucket has %d requests, want %d", got.Buckets[0].Requests, tt.wantFirst)
+			}
+			if got.Max() != tt.wantMax {
+				t.Errorf("Max() = %d, want %d", got.Max(), tt.wantMax)
+			}
+		})
+	}
+}
+
+// detailBackends returns a SQLite and an in-memory store holding the same
+// requests.
+func detailBackends(t *testing.T, reqs []RequestInfo) map[string]DetailReporter {
+	t.Helper()
+	db := newTestDatabase(t)
+	seedRequests(t, db, reqs)
+	memory := NewMemoryStore(NewStats())
+	if _, err := memory.RecordRequests(context.Background(), reqs); err != nil {
+		t.Fatalf("RecordRequests() error = %v", err)
+	}
+	return map[string]DetailReporter{"sqlite": db, "memory": memory}
+}
+
+func TestDetailReporter(t *testing.T) {
+	start := time.Now().Add(-3 * time.Hour)
+	reqs := []RequestInfo{
+		{IP: "10.0.0.1", UserAgent: "bot", Path: "/a", Timestamp: start},
+		{IP: "10.0.0.1", UserAgent: "bot", Path: "/b", Timestamp: start.Add(5 * time.Minute)},
+		{IP: "10.0.0.2", UserAgent: "bot", Path: "/a", Timestamp: start.Add(10 * time.Minute)},
+		{IP: "10.0.0.1", UserAgent: "curl", Path: "/a", Timestamp: start.Add(2 * time.Hour)},
+	}
+
+	for backend, reporter := range detailBackends(t, reqs) {
+		t.Run(backend, func(t *testing.T) {
+			ctx := context.Background()
+
+			ip, err := reporter.GetIPDetail(ctx, "10.0.0.1", 10)
+			if err != nil {
+				t.Fatalf("GetIPDetail() error = %v", err)
+			}
+			if ip.Count != 3 || ip.Logged != 3 || !ip.LastSeen.Equal(start.Add(2*time.Hour)) {
+				t.Errorf("Count, Logged, LastSeen = %d, %d, %s, want 3, 3 and the last request", ip.Count, ip.Logged, ip.LastSeen)
+			}
+			// An idle gap of two hours splits the requests into two sessions
+			if len(ip.Sessions) != 2 || ip.Sessions[0].Requests != 1 || ip.Sessions[1].Requests != 2 {
+				t.Errorf("Sessions = %+v, want the newest with 1 request, then one with 2", ip.Sessions)
+			}
+			if ip.Timeline.Interval != time.Hour || len(ip.Timeline.Buckets) != 3 {
+				t.Errorf("Timeline = %+v, want 3 hourly buckets", ip.Timeline)
+			}
+
+			ua, err := reporter.GetUserAgentDetail(ctx, "bot", 10)
+			if err != nil {
+				t.Fatalf("GetUserAgentDetail() error = %v", err)
+			}
+			if ua.Count != 3 || ua.Logged != 3 {
+				t.Errorf("Count, Logged = %d, %d, want 3, 3", ua.Count, ua.Logged)
+			}
+			if len(ua.IPs) != 2 || ua.IPs[0] != (CountEntry{"10.0.0.1", 2}) {
+				t.Errorf("IPs = %v, want 10.0.0.1 first with 2 requests", ua.IPs)
+			}
+			if len(ua.Recent) != 3 || ua.Recent[0].IP != "10.0.0.2" {
+				t.Errorf("Recent = %v, want 3 requests, newest first", ua.Recent)
+			}
+
+			if _, err := reporter.GetIPDetail(ctx, "10.9.9.9", 10); !errors.Is(err, ErrNotFound) {
+				t.Errorf("GetIPDetail() of an unknown IP error = %v, want ErrNotFound", err)
+			}
+			if _, err := reporter.GetUserAgentDetail(ctx, "wget", 10); !errors.Is(err, ErrNotFound) {
+				t.Errorf("GetUserAgentDetail() of an unknown user agent error = %v, want ErrNotFound", err)
+			}
+		})
+	}
+}
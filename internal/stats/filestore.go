@@ -0,0 +1,642 @@
+package stats
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// File store file names.
+const (
+	requestLogFileName = "requests.ndjson"
+	snapshotFileName   = "stats.json"
+	archivePattern     = "requests-*.ndjson" // Rotated request logs awaiting compression
+)
+
+// DefaultSnapshotInterval is how often the file store writes its stats
+// snapshot when no interval is configured.
+const DefaultSnapshotInterval = 5 * time.Minute
+
+// FileStoreConfig holds settings for file-based persistence.
+type FileStoreConfig struct {
+	Dir              string        // Data directory holding the snapshot and request logs
+	SnapshotInterval time.Duration // How often to write the stats snapshot (0 uses DefaultSnapshotInterval)
+	RotateSize       int64         // Rotate the request log before it grows past this many bytes (0 disables)
+	RotateDaily      bool          // Rotate the request log when the UTC day of the requests changes
+	MaxArchives      int           // Number of compressed request logs to keep (0 keeps all)
+}
+
+// FileStore is a Store that keeps statistics in memory and persists them to
+// a data directory.
+//
+// Every request is appended to requests.ndjson and synced before it is
+// counted, so the log is a write-ahead log for the in-memory counts. The
+// counts are periodically written to stats.json together with the log
+// offset they include; on startup the snapshot is loaded and the rest of
+// the log is replayed, so a crash loses nothing that was recorded.
+//
+// Rotated logs are renamed to requests-TIMESTAMP.ndjson and compressed
+// with gzip in the background.
+type FileStore struct {
+	*MemoryStore
+	stats  *Stats
+	config FileStoreConfig
+	logger *slog.Logger
+
+	mu      sync.Mutex // Serializes log appends, rotation and snapshots
+	log     *os.File
+	logSize int64
+	logDay  string // UTC day of the newest logged request
+	closed  bool
+
+	compressing sync.WaitGroup
+	stopChan    chan struct{}
+	done        chan struct{}
+}
+
+// NewFileStore loads the snapshot and request log from a data directory and
+// starts writing periodic snapshots.
+//
+// Parameters:
+//   - config: data directory and rotation settings
+//   - logger: structured logger instance
+//
+// Returns a new FileStore or an error if the directory or request log
+// cannot be opened. Call Close to write a final snapshot.
+func NewFileStore(config FileStoreConfig, logger *slog.Logger) (*FileStore, error) {
+	if config.SnapshotInterval <= 0 {
+		config.SnapshotInterval = DefaultSnapshotInterval
+	}
+	if err := os.MkdirAll(config.Dir, 0750); err != nil {
+		return nil, fmt.Errorf("failed to create data directory: %w", err)
+	}
+
+	stats := NewStats()
+	s := &FileStore{
+		MemoryStore: NewMemoryStore(stats),
+		stats:       stats,
+		config:      config,
+		logger:      logger,
+		stopChan:    make(chan struct{}),
+		done:        make(chan struct{}),
+	}
+
+	offset, err := s.loadSnapshot()
+	if err != nil {
+		// Rebuild the counts from the whole log instead
+		logger.Warn("Failed to load stats snapshot, replaying request log", "error", err)
+		s.resetStats()
+		offset = 0
+	}
+	if err := s.recover(offset); err != nil {
+		return nil, err
+	}
+	if err := s.openLog(); err != nil {
+		return nil, err
+	}
+	s.compressLeftovers()
+
+	go s.run()
+	return s, nil
+}
+
+// Backend returns "file".
+func (s *FileStore) Backend() string {
+	return "file"
+}
+
+// path returns the path of a file in the data directory.
+func (s *FileStore) path(name string) string {
+	return filepath.Join(s.config.Dir, name)
+}
+
+// resetStats discards any partially restored counts.
+func (s *FileStore) resetStats() {
+	s.stats.Mu.Lock()
+	defer s.stats.Mu.Unlock()
+	s.stats.TotalRequests = 0
+	s.stats.IPCounts = make(map[string]int)
+	s.stats.IPLastSeen = make(map[string]time.Time)
+	s.stats.UserAgents = make(map[string]int)
+	s.stats.RecentRequests = make([]RequestInfo, 0)
+}
+
+// loadSnapshot restores the counts from stats.json.
+//
+// Returns the request log offset to replay from: 0 if there is no snapshot,
+// or -1 if the snapshot predates replay support and already includes the
+// whole log.
+func (s *FileStore) loadSnapshot() (int64, error) {
+	// #nosec G304 -- fixed file name inside the configured data directory
+	data, err := os.ReadFile(s.path(snapshotFileName))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return 0, nil
+		}
+		return 0, fmt.Errorf("failed to read stats file: %w", err)
+	}
+
+	var persisted PersistedStats
+	if err := json.Unmarshal(data, &persisted); err != nil {
+		return 0, fmt.Errorf("failed to unmarshal stats: %w", err)
+	}
+
+	s.stats.Mu.Lock()
+	defer s.stats.Mu.Unlock()
+
+	// Restore stats, but keep the current StartTime for this session
+	s.stats.TotalRequests = persisted.TotalRequests
+	if persisted.IPCounts != nil {
+		s.stats.IPCounts = persisted.IPCounts
+	}
+	if persisted.IPLastSeen != nil {
+		s.stats.IPLastSeen = persisted.IPLastSeen
+	}
+	if persisted.UserAgents != nil {
+		s.stats.UserAgents = persisted.UserAgents
+	}
+
+	// Restore recent requests, but limit to MaxRecentRequests
+	recent := persisted.RecentRequests
+	if len(recent) > s.stats.MaxRecentRequests {
+		recent = recent[len(recent)-s.stats.MaxRecentRequests:]
+	}
+	s.stats.RecentRequests = append(s.stats.RecentRequests[:0], recent...)
+
+	if persisted.LogOffset == nil {
+		return -1, nil
+	}
+	return *persisted.LogOffset, nil
+}
+
+// recover replays the request log from offset and truncates a torn final
+// line left by a crash mid-write.
+//
+// An offset past the end of the log means the log was rotated after the
+// snapshot was written, so the whole new log is replayed.
+func (s *FileStore) recover(offset int64) error {
+	logPath := s.path(requestLogFileName)
+	// #nosec G304 -- fixed file name inside the configured data directory
+	file, err := os.Open(logPath)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return fmt.Errorf("failed to open request log: %w", err)
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to stat request log: %w", err)
+	}
+	size := info.Size()
+	switch {
+	case offset < 0:
+		offset = size
+	case offset > size:
+		offset = 0
+	}
+
+	end, replayed, err := s.replay(file, offset)
+	if err != nil {
+		return err
+	}
+	if replayed > 0 {
+		s.logger.Info("Replayed request log", "requests", replayed)
+	}
+
+	if end < size {
+		s.logger.Warn("Truncating incomplete request log entry", "bytes", size-end)
+		if err := os.Truncate(logPath, end); err != nil {
+			return fmt.Errorf("failed to truncate request log: %w", err)
+		}
+	}
+	return nil
+}
+
+// replay counts every complete line of the request log after offset.
+//
+// Returns the offset just past the last complete line and the number of
+// requests replayed.
+func (s *FileStore) replay(file *os.File, offset int64) (int64, int, error) {
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		return 0, 0, fmt.Errorf("failed to seek request log: %w", err)
+	}
+
+	s.stats.Mu.Lock()
+	defer s.stats.Mu.Unlock()
+
+	reader := bufio.NewReader(file)
+	end := offset
+	replayed := 0
+	for {
+		line, err := reader.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			// A line without a newline was torn by a crash and is not counted
+			return end, replayed, nil
+		}
+		if err != nil {
+			return 0, 0, fmt.Errorf("failed to read request log: %w", err)
+		}
+
+		var req RequestInfo
+		if err := json.Unmarshal(line, &req); err != nil {
+			s.logger.Warn("Skipping malformed request log entry", "offset", end, "error", err)
+		} else {
+			s.record(req)
+			replayed++
+		}
+		end += int64(len(line))
+	}
+}
+
+// openLog opens the request log for appending.
+func (s *FileStore) openLog() error {
+	// #nosec G304 -- fixed file name inside the configured data directory
+	file, err := os.OpenFile(s.path(requestLogFileName), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open request log: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("failed to stat request log: %w", err)
+	}
+	s.log = file
+	s.logSize = info.Size()
+	s.logDay = ""
+	if s.logSize > 0 {
+		s.logDay = info.ModTime().UTC().Format(rollupDayFormat)
+	}
+	return nil
+}
+
+// RecordRequests appends a batch of requests to the request log, syncs it,
+// and then counts the requests in memory.
+//
+// Parameters:
+//   - ctx: context for cancellation and timeout control
+//   - reqs: the requests to record
+//
+// Returns one result per request, or an error if the log cannot be written.
+// Requests that could not be logged are not counted.
+func (s *FileStore) RecordRequests(ctx context.Context, reqs []RequestInfo) ([]RecordResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	results := make([]RecordResult, 0, len(reqs))
+	var buf []byte
+	start := 0
+	for i, req := range reqs {
+		line, err := json.Marshal(req)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal request: %w", err)
+		}
+		line = append(line, '\n')
+
+		day := req.Timestamp.UTC().Format(rollupDayFormat)
+		if s.shouldRotate(day, int64(len(buf)), int64(len(line))) {
+			written, err := s.append(ctx, reqs[start:i], buf)
+			if err != nil {
+				return nil, err
+			}
+			results = append(results, written...)
+			start, buf = i, buf[:0]
+
+			if err := s.rotate(); err != nil {
+				s.logger.Warn("Failed to rotate request log", "error", err)
+			}
+		}
+
+		buf = append(buf, line...)
+		if day > s.logDay {
+			s.logDay = day
+		}
+	}
+
+	written, err := s.append(ctx, reqs[start:], buf)
+	if err != nil {
+		return nil, err
+	}
+	return append(results, written...), nil
+}
+
+// shouldRotate reports whether the log must be rotated before a line is
+// added after pending unwritten bytes. Must be called with s.mu held.
+func (s *FileStore) shouldRotate(day string, pending, lineSize int64) bool {
+	size := s.logSize + pending
+	if size == 0 {
+		return false
+	}
+	if s.config.RotateDaily && s.logDay != "" && day > s.logDay {
+		return true
+	}
+	return s.config.RotateSize > 0 && size+lineSize > s.config.RotateSize
+}
+
+// append writes and syncs log lines, then counts their requests. Must be
+// called with s.mu held.
+func (s *FileStore) append(ctx context.Context, reqs []RequestInfo, lines []byte) ([]RecordResult, error) {
+	if len(reqs) == 0 {
+		return nil, nil
+	}
+	if s.log == nil {
+		return nil, fmt.Errorf("failed to write request log: %w", os.ErrClosed)
+	}
+
+	n, err := s.log.Write(lines)
+	if err == nil {
+		err = s.log.Sync()
+	}
+	if err != nil {
+		// Drop the partial write so the next append starts on a line boundary
+		if n > 0 {
+			s.log.Truncate(s.logSize)
+		}
+		return nil, fmt.Errorf("failed to write request log: %w", err)
+	}
+	s.logSize += int64(n)
+
+	return s.MemoryStore.RecordRequests(ctx, reqs)
+}
+
+// rotate archives the current request log and starts a new one. Must be
+// called with s.mu held.
+//
+// A snapshot is written before and after the log is renamed, so at every
+// point a crash leaves a snapshot whose offset matches the current log.
+func (s *FileStore) rotate() error {
+	if err := s.snapshot(); err != nil {
+		return err
+	}
+
+	archive := s.archivePath(time.Now())
+	closeErr := s.log.Close()
+	s.log = nil
+	renameErr := os.Rename(s.path(requestLogFileName), archive)
+
+	// Reopen the log whether or not the rename worked, so recording continues
+	if err := s.openLog(); err != nil {
+		return err
+	}
+	if closeErr != nil {
+		return fmt.Errorf("failed to close request log: %w", closeErr)
+	}
+	if renameErr != nil {
+		return fmt.Errorf("failed to rename request log: %w", renameErr)
+	}
+	if err := s.snapshot(); err != nil {
+		return err
+	}
+
+	s.logger.Debug("Rotated request log", "archive", archive)
+	s.compressing.Add(1)
+	go s.compress(archive)
+	return nil
+}
+
+// archivePath returns an unused path for a rotated request log.
+func (s *FileStore) archivePath(now time.Time) string {
+	stamp := now.UTC().Format("20060102T150405Z")
+	name := s.path(fmt.Sprintf("requests-%s.ndjson", stamp))
+	for n := 1; fileExists(name) || fileExists(name+".gz"); n++ {
+		name = s.path(fmt.Sprintf("requests-%s-%d.ndjson", stamp, n))
+	}
+	return name
+}
+
+// compress gzips a rotated request log and removes the original, then
+// removes archives beyond the configured limit.
+func (s *FileStore) compress(path string) {
+	defer s.compressing.Done()
+
+	if err := gzipFile(path); err != nil {
+		s.logger.Warn("Failed to compress request log", "file", path, "error", err)
+		return
+	}
+	s.pruneArchives()
+}
+
+// compressLeftovers compresses rotated logs that a previous run did not get
+// to, and removes temporary files from interrupted compressions.
+func (s *FileStore) compressLeftovers() {
+	temps, _ := filepath.Glob(s.path(archivePattern + ".gz.tmp"))
+	for _, temp := range temps {
+		os.Remove(temp)
+	}
+
+	leftovers, _ := filepath.Glob(s.path(archivePattern))
+	for _, path := range leftovers {
+		s.compressing.Add(1)
+		go s.compress(path)
+	}
+}
+
+// pruneArchives removes the oldest compressed request logs beyond
+// MaxArchives.
+func (s *FileStore) pruneArchives() {
+	if s.config.MaxArchives <= 0 {
+		return
+	}
+
+	paths, err := filepath.Glob(s.path(archivePattern + ".gz"))
+	if err != nil || len(paths) <= s.config.MaxArchives {
+		return
+	}
+
+	type archive struct {
+		path    string
+		modTime time.Time
+	}
+	archives := make([]archive, 0, len(paths))
+	for _, path := range paths {
+		if info, err := os.Stat(path); err == nil {
+			archives = append(archives, archive{path: path, modTime: info.ModTime()})
+		}
+	}
+	sort.Slice(archives, func(i, j int) bool {
+		if !archives[i].modTime.Equal(archives[j].modTime) {
+			return archives[i].modTime.Before(archives[j].modTime)
+		}
+		return archives[i].path < archives[j].path
+	})
+
+	for len(archives) > s.config.MaxArchives {
+		if err := os.Remove(archives[0].path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			s.logger.Warn("Failed to remove old request log", "file", archives[0].path, "error", err)
+		}
+		archives = archives[1:]
+	}
+}
+
+// gzipFile compresses path to path.gz and removes path.
+func gzipFile(path string) error {
+	// #nosec G304 -- rotated log inside the configured data directory
+	src, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open file: %w", err)
+	}
+	defer src.Close()
+	info, err := src.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to stat file: %w", err)
+	}
+
+	temp := path + ".gz.tmp"
+	// #nosec G304 -- rotated log inside the configured data directory
+	dst, err := os.OpenFile(temp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to create compressed file: %w", err)
+	}
+
+	gz := gzip.NewWriter(dst)
+	_, err = io.Copy(gz, src)
+	if err == nil {
+		err = gz.Close()
+	}
+	if err == nil {
+		err = dst.Sync()
+	}
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(temp, path+".gz")
+	}
+	if err != nil {
+		os.Remove(temp)
+		return fmt.Errorf("failed to compress file: %w", err)
+	}
+
+	// Keep the log's modification time so archives prune in rotation order
+	os.Chtimes(path+".gz", info.ModTime(), info.ModTime())
+
+	src.Close()
+	if err := os.Remove(path); err != nil {
+		return fmt.Errorf("failed to remove uncompressed file: %w", err)
+	}
+	return nil
+}
+
+// snapshot writes the counts and the log offset they include to stats.json.
+// Must be called with s.mu held.
+func (s *FileStore) snapshot() error {
+	offset := s.logSize
+
+	s.stats.Mu.RLock()
+	data, err := json.MarshalIndent(PersistedStats{
+		StartTime:      s.stats.StartTime,
+		TotalRequests:  s.stats.TotalRequests,
+		IPCounts:       s.stats.IPCounts,
+		IPLastSeen:     s.stats.IPLastSeen,
+		UserAgents:     s.stats.UserAgents,
+		RecentRequests: s.stats.RecentRequests,
+		LogOffset:      &offset,
+	}, "", "  ")
+	s.stats.Mu.RUnlock()
+	if err != nil {
+		return fmt.Errorf("failed to marshal stats: %w", err)
+	}
+
+	if err := writeFileAtomic(s.path(snapshotFileName), data); err != nil {
+		return fmt.Errorf("failed to write stats file: %w", err)
+	}
+	return nil
+}
+
+// writeFileAtomic replaces a file with data.
+//
+// The data is written to a temporary file in the same directory, synced,
+// and renamed over the target, so readers see either the old or the new
+// contents and never a partial write.
+func writeFileAtomic(path string, data []byte) error {
+	dir := filepath.Dir(path)
+	temp, err := os.CreateTemp(dir, "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	_, err = temp.Write(data)
+	if err == nil {
+		err = temp.Sync()
+	}
+	if closeErr := temp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(temp.Name(), path)
+	}
+	if err != nil {
+		os.Remove(temp.Name())
+		return err
+	}
+
+	// Persist the rename itself; not every platform supports syncing a directory
+	if d, err := os.Open(dir); err == nil {
+		d.Sync()
+		d.Close()
+	}
+	return nil
+}
+
+// run writes a snapshot every SnapshotInterval until Close is called.
+func (s *FileStore) run() {
+	defer close(s.done)
+
+	ticker := time.NewTicker(s.config.SnapshotInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.mu.Lock()
+			err := s.snapshot()
+			s.mu.Unlock()
+			if err != nil {
+				s.logger.Warn("Failed to save stats snapshot", "error", err)
+			}
+		case <-s.stopChan:
+			return
+		}
+	}
+}
+
+// Close writes a final snapshot, closes the request log, and waits for
+// background compression to finish. It is safe to call more than once.
+//
+// Returns an error if the snapshot or the log cannot be written.
+func (s *FileStore) Close() error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil
+	}
+	s.closed = true
+	s.mu.Unlock()
+
+	close(s.stopChan)
+	<-s.done
+
+	s.mu.Lock()
+	err := s.snapshot()
+	if s.log != nil {
+		if closeErr := s.log.Close(); err == nil {
+			err = closeErr
+		}
+		s.log = nil
+	}
+	s.mu.Unlock()
+
+	s.compressing.Wait()
+	return err
+}
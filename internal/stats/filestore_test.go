@@ -0,0 +1,273 @@
+package stats
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestFileStore opens a file store in dir.
+func newTestFileStore(t *testing.T, config FileStoreConfig) *FileStore {
+	t.Helper()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	s, err := NewFileStore(config, logger)
+	if err != nil {
+		t.Fatalf("NewFileStore() error = %v", err)
+	}
+	return s
+}
+
+// crash abandons a file store without a final snapshot, as if the process
+// had been killed.
+func crash(s *FileStore) {
+	close(s.stopChan)
+	<-s.done
+	s.log.Close()
+	s.compressing.Wait()
+}
+
+// recordAll records requests one batch at a time.
+func recordAll(t *testing.T, s *FileStore, reqs ...RequestInfo) []RecordResult {
+	t.Helper()
+	results, err := s.RecordRequests(context.Background(), reqs)
+	if err != nil {
+		t.Fatalf("RecordRequests() error = %v", err)
+	}
+	return results
+}
+
+// checkSummary compares a store's total and unique counts.
+func checkSummary(t *testing.T, s Store, total, ips, userAgents int) {
+	t.Helper()
+	summary, err := s.GetSummary(context.Background())
+	if err != nil {
+		t.Fatalf("GetSummary() error = %v", err)
+	}
+	if summary.TotalRequests != total || summary.UniqueIPs != ips || summary.UniqueUserAgents != userAgents {
+		t.Errorf("summary = %d requests, %d IPs, %d user agents; want %d, %d, %d",
+			summary.TotalRequests, summary.UniqueIPs, summary.UniqueUserAgents, total, ips, userAgents)
+	}
+}
+
+func TestFileStoreRecovery(t *testing.T) {
+	now := time.Date(2025, 6, 10, 12, 0, 0, 0, time.UTC)
+	req := func(ip string) RequestInfo {
+		return RequestInfo{IP: ip, UserAgent: "bot", Path: "/", Timestamp: now}
+	}
+
+	tests := []struct {
+		name      string
+		snapshot  bool   // Write a snapshot after the first two requests
+		tornTail  string // Bytes appended to the log after the crash
+		wantTotal int
+	}{
+		{"replay whole log", false, "", 3},
+		{"replay after snapshot", true, "", 3},
+		{"torn final line", true, `{"IP":"10.0.0.9","UserAg`, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			s := newTestFileStore(t, FileStoreConfig{Dir: dir, SnapshotInterval: time.Hour})
+			recordAll(t, s, req("10.0.0.1"), req("10.0.0.2"))
+			if tt.snapshot {
+				s.mu.Lock()
+				if err := s.snapshot(); err != nil {
+					t.Fatalf("snapshot() error = %v", err)
+				}
+				s.mu.Unlock()
+			}
+			recordAll(t, s, req("10.0.0.1"))
+			crash(s)
+
+			logPath := filepath.Join(dir, requestLogFileName)
+			if tt.tornTail != "" {
+				f, err := os.OpenFile(logPath, os.O_APPEND|os.O_WRONLY, 0600)
+				if err != nil {
+					t.Fatal(err)
+				}
+				f.WriteString(tt.tornTail)
+				f.Close()
+			}
+
+			s = newTestFileStore(t, FileStoreConfig{Dir: dir, SnapshotInterval: time.Hour})
+			checkSummary(t, s, tt.wantTotal, 2, 1)
+
+			// New entries must start on a line of their own
+			results := recordAll(t, s, req("10.0.0.1"))
+			if results[0].IPCount != 3 {
+				t.Errorf("IPCount = %d, want 3", results[0].IPCount)
+			}
+			if err := s.Close(); err != nil {
+				t.Fatalf("Close() error = %v", err)
+			}
+
+			data, err := os.ReadFile(logPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if lines := strings.Count(string(data), "\n"); lines != 4 || !strings.HasSuffix(string(data), "\n") {
+				t.Errorf("request log has %d complete lines, want 4:\n%s", lines, data)
+			}
+		})
+	}
+}
+
+func TestFileStoreSnapshotOnClose(t *testing.T) {
+	dir := t.TempDir()
+	s := newTestFileStore(t, FileStoreConfig{Dir: dir, SnapshotInterval: time.Hour})
+	recordAll(t, s,
+		RequestInfo{IP: "10.0.0.1", UserAgent: "a", Path: "/", Timestamp: time.Now()},
+		RequestInfo{IP: "10.0.0.2", UserAgent: "b", Path: "/", Timestamp: time.Now()},
+	)
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	// Only the snapshot and log remain; no temporary files
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	if strings.Join(names, ",") != "requests.ndjson,stats.json" {
+		t.Errorf("data directory = %v, want [requests.ndjson stats.json]", names)
+	}
+
+	// Reopening must not count the logged requests a second time
+	s = newTestFileStore(t, FileStoreConfig{Dir: dir, SnapshotInterval: time.Hour})
+	defer s.Close()
+	checkSummary(t, s, 2, 2, 2)
+}
+
+func TestFileStoreLegacySnapshot(t *testing.T) {
+	dir := t.TempDir()
+
+	// Written before snapshots recorded their log offset; the log is
+	// already included in the counts
+	legacy := `{"startTime":"2025-01-01T00:00:00Z","totalRequests":2,"ipCounts":{"10.0.0.1":2},"userAgents":{"bot":2},"recentRequests":[]}`
+	if err := os.WriteFile(filepath.Join(dir, snapshotFileName), []byte(legacy), 0600); err != nil {
+		t.Fatal(err)
+	}
+	line := `{"IP":"10.0.0.1","UserAgent":"bot","Path":"/","Timestamp":"2025-01-01T00:00:00Z"}` + "\n"
+	if err := os.WriteFile(filepath.Join(dir, requestLogFileName), []byte(line+line), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	s := newTestFileStore(t, FileStoreConfig{Dir: dir, SnapshotInterval: time.Hour})
+	defer s.Close()
+	checkSummary(t, s, 2, 1, 1)
+}
+
+func TestFileStoreRotation(t *testing.T) {
+	day := time.Date(2025, 6, 10, 23, 59, 0, 0, time.UTC)
+	req := func(ts time.Time) RequestInfo {
+		return RequestInfo{IP: "10.0.0.1", UserAgent: "bot", Path: "/", Timestamp: ts}
+	}
+
+	tests := []struct {
+		name         string
+		config       FileStoreConfig
+		reqs         []RequestInfo
+		wantArchives int
+	}{
+		{
+			name:         "by size",
+			config:       FileStoreConfig{RotateSize: 200},
+			reqs:         []RequestInfo{req(day), req(day), req(day), req(day), req(day)},
+			wantArchives: 2,
+		},
+		{
+			name:         "by day",
+			config:       FileStoreConfig{RotateDaily: true},
+			reqs:         []RequestInfo{req(day), req(day), req(day.Add(2 * time.Minute)), req(day.Add(48 * time.Hour))},
+			wantArchives: 2,
+		},
+		{
+			name:         "keep limit",
+			config:       FileStoreConfig{RotateSize: 100, MaxArchives: 1},
+			reqs:         []RequestInfo{req(day), req(day), req(day), req(day)},
+			wantArchives: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			tt.config.Dir = dir
+			tt.config.SnapshotInterval = time.Hour
+
+			s := newTestFileStore(t, tt.config)
+			results := recordAll(t, s, tt.reqs...)
+			if got := results[len(results)-1].IPCount; got != len(tt.reqs) {
+				t.Errorf("last IPCount = %d, want %d", got, len(tt.reqs))
+			}
+			if err := s.Close(); err != nil {
+				t.Fatalf("Close() error = %v", err)
+			}
+
+			archives, err := filepath.Glob(filepath.Join(dir, "requests-*.ndjson.gz"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(archives) != tt.wantArchives {
+				t.Fatalf("got %d archives, want %d", len(archives), tt.wantArchives)
+			}
+			if leftovers, _ := filepath.Glob(filepath.Join(dir, "requests-*.ndjson")); len(leftovers) != 0 {
+				t.Errorf("uncompressed archives left behind: %v", leftovers)
+			}
+
+			// Every line is either archived or in the current log
+			lines := countLines(t, filepath.Join(dir, requestLogFileName), false)
+			for _, archive := range archives {
+				lines += countLines(t, archive, true)
+			}
+			if tt.config.MaxArchives == 0 && lines != len(tt.reqs) {
+				t.Errorf("logged %d lines, want %d", lines, len(tt.reqs))
+			}
+
+			s = newTestFileStore(t, tt.config)
+			defer s.Close()
+			checkSummary(t, s, len(tt.reqs), 1, 1)
+		})
+	}
+}
+
+// countLines counts the lines of a plain or gzipped file.
+func countLines(t *testing.T, path string, gzipped bool) int {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+	if gzipped {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			t.Fatalf("gzip.NewReader(%s) error = %v", path, err)
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	n := 0
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		n++
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
@@ -0,0 +1,333 @@
+package stats
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Limits of the memory store's form data.
+const (
+	maxTrackedHoneytokens = 10000 // Maximum number of issued honeytokens to remember
+	maxRecentSubmissions  = 100   // Maximum number of form submissions to keep
+)
+
+// Honeytoken is a unique fake credential embedded in a trap form, such as
+// an email address or API key. A client submitting it reveals that it
+// harvested the form, and a different client submitting it reveals that the
+// value was passed on.
+type Honeytoken struct {
+	Value    string    `json:"value"`
+	Kind     string    `json:"kind"` // Kind of credential, e.g. "email"
+	IP       string    `json:"ip"`   // Client the token was issued to
+	Form     string    `json:"form"` // Form the token was embedded in
+	IssuedAt time.Time `json:"issuedAt"`
+}
+
+// FormSubmission is a trap request that carried form fields or a body.
+type FormSubmission struct {
+	ID         int64      `json:"id"`
+	Timestamp  time.Time  `json:"timestamp"`
+	IP         string     `json:"ip"`
+	UserAgent  string     `json:"userAgent"`
+	Method     string     `json:"method"`
+	Path       string     `json:"path"`
+	Fields     url.Values `json:"fields"`               // Query and form-encoded body fields
+	Body       string     `json:"body,omitempty"`       // Body that is not form-encoded
+	Honeytoken string     `json:"honeytoken,omitempty"` // Honeytoken found in the fields, if any
+	IssuedTo   string     `json:"issuedTo,omitempty"`   // IP the honeytoken was issued to
+}
+
+// Reused reports whether the submission carried a honeytoken issued to a
+// different IP.
+func (s FormSubmission) Reused() bool {
+	return s.IssuedTo != "" && s.IssuedTo != s.IP
+}
+
+// FormStore is implemented by stores that keep issued honeytokens and form
+// submissions.
+type FormStore interface {
+	// SaveHoneytokens stores newly issued honeytokens.
+	SaveHoneytokens(ctx context.Context, tokens []Honeytoken) error
+	// FindHoneytokens returns the issued honeytokens among values.
+	FindHoneytokens(ctx context.Context, values []string) ([]Honeytoken, error)
+	// SaveFormSubmission stores a form submission.
+	SaveFormSubmission(ctx context.Context, s FormSubmission) error
+	// GetFormSubmissions returns up to limit submissions, newest first,
+	// optionally only those carrying a honeytoken.
+	GetFormSubmissions(ctx context.Context, honeytokensOnly bool, limit int) ([]FormSubmission, error)
+}
+
+// SaveHoneytokens stores newly issued honeytokens in one transaction.
+//
+// Parameters:
+//   - ctx: context for cancellation and timeout control
+//   - tokens: the honeytokens
+//
+// Returns an error if the write fails.
+func (d *Database) SaveHoneytokens(ctx context.Context, tokens []Honeytoken) error {
+	if len(tokens) == 0 {
+		return nil
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	tx, err := d.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, t := range tokens {
+		_, err := tx.ExecContext(ctx, `
+			INSERT OR IGNORE INTO honeytokens (value, kind, ip, form, issued_at) VALUES (?, ?, ?, ?, ?)
+		`, t.Value, t.Kind, t.IP, t.Form, t.IssuedAt)
+		if err != nil {
+			return fmt.Errorf("failed to save honeytoken: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// FindHoneytokens returns the issued honeytokens among values.
+//
+// Parameters:
+//   - ctx: context for cancellation and timeout control
+//   - values: submitted values to look up
+//
+// Returns the honeytokens found, in no particular order, or an error if the
+// query fails.
+func (d *Database) FindHoneytokens(ctx context.Context, values []string) ([]Honeytoken, error) {
+	if len(values) == 0 {
+		return nil, nil
+	}
+
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	args := make([]any, len(values))
+	for i, v := range values {
+		args[i] = v
+	}
+	rows, err := d.db.QueryContext(ctx, `
+		SELECT value, kind, ip, form, issued_at FROM honeytokens
+		WHERE value IN (?`+strings.Repeat(", ?", len(values)-1)+`)
+	`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query honeytokens: %w", err)
+	}
+	defer rows.Close()
+
+	var result []Honeytoken
+	for rows.Next() {
+		var t Honeytoken
+		if err := rows.Scan(&t.Value, &t.Kind, &t.IP, &t.Form, &t.IssuedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan honeytoken: %w", err)
+		}
+		result = append(result, t)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating honeytokens: %w", err)
+	}
+	return result, nil
+}
+
+// SaveFormSubmission stores a form submission.
+//
+// Parameters:
+//   - ctx: context for cancellation and timeout control
+//   - s: the submission; its ID is ignored
+//
+// Returns an error if the write fails.
+func (d *Database) SaveFormSubmission(ctx context.Context, s FormSubmission) error {
+	fields, err := json.Marshal(s.Fields)
+	if err != nil {
+		return fmt.Errorf("failed to encode form fields: %w", err)
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	_, err = d.db.ExecContext(ctx, `
+		INSERT INTO form_submissions (timestamp, ip, user_agent, method, path, fields, body, honeytoken, issued_to)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, s.Timestamp, s.IP, s.UserAgent, s.Method, s.Path, string(fields), s.Body, s.Honeytoken, s.IssuedTo)
+	if err != nil {
+		return fmt.Errorf("failed to save form submission: %w", err)
+	}
+	return nil
+}
+
+// GetFormSubmissions returns stored form submissions, newest first.
+//
+// Parameters:
+//   - ctx: context for cancellation and timeout control
+//   - honeytokensOnly: only return submissions carrying a honeytoken
+//   - limit: maximum number of submissions to return
+//
+// Returns the submissions, or an error if the query fails.
+func (d *Database) GetFormSubmissions(ctx context.Context, honeytokensOnly bool, limit int) ([]FormSubmission, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	query := `
+		SELECT id, timestamp, ip, user_agent, method, path, fields, body, honeytoken, issued_to
+		FROM form_submissions`
+	if honeytokensOnly {
+		query += ` WHERE honeytoken != ''`
+	}
+	rows, err := d.db.QueryContext(ctx, query+` ORDER BY timestamp DESC, id DESC LIMIT ?`, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query form submissions: %w", err)
+	}
+	defer rows.Close()
+
+	var result []FormSubmission
+	for rows.Next() {
+		var s FormSubmission
+		var fields string
+		if err := rows.Scan(&s.ID, &s.Timestamp, &s.IP, &s.UserAgent, &s.Method, &s.Path, &fields, &s.Body, &s.Honeytoken, &s.IssuedTo); err != nil {
+			return nil, fmt.Errorf("failed to scan form submission: %w", err)
+		}
+		if err := json.Unmarshal([]byte(fields), &s.Fields); err != nil {
+			return nil, fmt.Errorf("failed to decode form fields: %w", err)
+		}
+		result = append(result, s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating form submissions: %w", err)
+	}
+	return result, nil
+}
+
+// PruneFormData deletes honeytokens issued and form submissions received
+// before cutoff.
+//
+// Parameters:
+//   - ctx: context for cancellation and timeout control
+//   - cutoff: rows older than this are deleted
+//
+// Returns the number of rows deleted, or an error if a delete fails.
+func (d *Database) PruneFormData(ctx context.Context, cutoff time.Time) (int, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	pruned := 0
+	for _, query := range []string{
+		`DELETE FROM honeytokens WHERE issued_at < ?`,
+		`DELETE FROM form_submissions WHERE timestamp < ?`,
+	} {
+		result, err := d.db.ExecContext(ctx, query, cutoff)
+		if err != nil {
+			return pruned, fmt.Errorf("failed to prune form data: %w", err)
+		}
+		n, err := result.RowsAffected()
+		if err != nil {
+			return pruned, fmt.Errorf("failed to count pruned form data: %w", err)
+		}
+		pruned += int(n)
+	}
+	return pruned, nil
+}
+
+// SaveHoneytokens stores newly issued honeytokens. Tokens are dropped once
+// maxTrackedHoneytokens are held.
+//
+// Parameters:
+//   - ctx: context for cancellation and timeout control
+//   - tokens: the honeytokens
+//
+// Returns nil; the memory store never fails.
+func (s *MemoryStore) SaveHoneytokens(ctx context.Context, tokens []Honeytoken) error {
+	s.formsMu.Lock()
+	defer s.formsMu.Unlock()
+
+	for _, t := range tokens {
+		if len(s.honeytokens) >= maxTrackedHoneytokens {
+			break
+		}
+		if _, exists := s.honeytokens[t.Value]; !exists {
+			s.honeytokens[t.Value] = t
+		}
+	}
+	return nil
+}
+
+// FindHoneytokens returns the issued honeytokens among values.
+//
+// Parameters:
+//   - ctx: context for cancellation and timeout control
+//   - values: submitted values to look up
+//
+// Returns the honeytokens found; the memory store never fails.
+func (s *MemoryStore) FindHoneytokens(ctx context.Context, values []string) ([]Honeytoken, error) {
+	s.formsMu.RLock()
+	defer s.formsMu.RUnlock()
+
+	var result []Honeytoken
+	for _, v := range values {
+		if t, ok := s.honeytokens[v]; ok {
+			result = append(result, t)
+		}
+	}
+	return result, nil
+}
+
+// SaveFormSubmission stores a form submission, keeping only the latest
+// maxRecentSubmissions.
+//
+// Parameters:
+//   - ctx: context for cancellation and timeout control
+//   - sub: the submission; its ID is assigned by the store
+//
+// Returns nil; the memory store never fails.
+func (s *MemoryStore) SaveFormSubmission(ctx context.Context, sub FormSubmission) error {
+	s.formsMu.Lock()
+	defer s.formsMu.Unlock()
+
+	s.submissionID++
+	sub.ID = s.submissionID
+	s.submissions = append(s.submissions, sub)
+	if len(s.submissions) > maxRecentSubmissions {
+		s.submissions = s.submissions[1:]
+	}
+	return nil
+}
+
+// GetFormSubmissions returns stored form submissions, newest first.
+//
+// Parameters:
+//   - ctx: context for cancellation and timeout control
+//   - honeytokensOnly: only return submissions carrying a honeytoken
+//   - limit: maximum number of submissions to return
+//
+// Returns the submissions; the memory store never fails.
+func (s *MemoryStore) GetFormSubmissions(ctx context.Context, honeytokensOnly bool, limit int) ([]FormSubmission, error) {
+	s.formsMu.RLock()
+	result := make([]FormSubmission, 0, len(s.submissions))
+	for _, sub := range s.submissions {
+		if !honeytokensOnly || sub.Honeytoken != "" {
+			result = append(result, sub)
+		}
+	}
+	s.formsMu.RUnlock()
+
+	sort.SliceStable(result, func(i, j int) bool {
+		if !result[i].Timestamp.Equal(result[j].Timestamp) {
+			return result[i].Timestamp.After(result[j].Timestamp)
+		}
+		return result[i].ID > result[j].ID
+	})
+	if len(result) > limit {
+		result = result[:limit]
+	}
+	return result, nil
+}
@@ -0,0 +1,103 @@
+package stats
+
+import (
+	"context"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestFormStore(t *testing.T) {
+	issued := time.Date(2025, 6, 10, 12, 0, 0, 0, time.UTC)
+	backends := map[string]FormStore{
+		"sqlite": newTestDatabase(t),
+		"memory": NewMemoryStore(NewStats()),
+	}
+
+	for backend, store := range backends {
+		t.Run(backend, func(t *testing.T) {
+			ctx := context.Background()
+			err := store.SaveHoneytokens(ctx, []Honeytoken{
+				{Value: "ops.abc123@example.com", Kind: "email", IP: "192.0.2.1", Form: "login", IssuedAt: issued},
+				{Value: "sk_live_abc123", Kind: "api_key", IP: "192.0.2.1", Form: "contact", IssuedAt: issued},
+			})
+			if err != nil {
+				t.Fatalf("SaveHoneytokens() error = %v", err)
+			}
+
+			found, err := store.FindHoneytokens(ctx, []string{"nothing", "sk_live_abc123"})
+			if err != nil {
+				t.Fatalf("FindHoneytokens() error = %v", err)
+			}
+			if len(found) != 1 || found[0].Kind != "api_key" || found[0].IP != "192.0.2.1" || !found[0].IssuedAt.Equal(issued) {
+				t.Errorf("FindHoneytokens() = %+v, want the API key", found)
+			}
+			if found, err := store.FindHoneytokens(ctx, nil); err != nil || len(found) != 0 {
+				t.Errorf("FindHoneytokens(nil) = %+v, %v, want nothing", found, err)
+			}
+
+			for _, sub := range []FormSubmission{
+				{Timestamp: issued, IP: "192.0.2.1", UserAgent: "curl", Method: "GET", Path: "/search", Fields: url.Values{"q": {"a", "b"}}},
+				{Timestamp: issued.Add(time.Minute), IP: "192.0.2.2", UserAgent: "curl", Method: "POST", Path: "/login",
+					Fields: url.Values{"email": {"ops.abc123@example.com"}}, Honeytoken: "ops.abc123@example.com", IssuedTo: "192.0.2.1"},
+				{Timestamp: issued.Add(2 * time.Minute), IP: "192.0.2.3", UserAgent: "curl", Method: "PUT", Path: "/api", Body: "{}"},
+			} {
+				if err := store.SaveFormSubmission(ctx, sub); err != nil {
+					t.Fatalf("SaveFormSubmission() error = %v", err)
+				}
+			}
+
+			all, err := store.GetFormSubmissions(ctx, false, 10)
+			if err != nil {
+				t.Fatalf("GetFormSubmissions() error = %v", err)
+			}
+			if len(all) != 3 || all[0].Path != "/api" || all[0].Body != "{}" || all[2].Fields.Get("q") != "a" || len(all[2].Fields["q"]) != 2 {
+				t.Errorf("GetFormSubmissions() = %+v, want three submissions newest first", all)
+			}
+			if all[0].ID == all[1].ID {
+				t.Errorf("submissions share ID %d", all[0].ID)
+			}
+
+			tokens, err := store.GetFormSubmissions(ctx, true, 10)
+			if err != nil {
+				t.Fatalf("GetFormSubmissions(honeytokensOnly) error = %v", err)
+			}
+			if len(tokens) != 1 || !tokens[0].Reused() || tokens[0].IssuedTo != "192.0.2.1" {
+				t.Errorf("GetFormSubmissions(honeytokensOnly) = %+v, want the reused honeytoken", tokens)
+			}
+
+			if limited, _ := store.GetFormSubmissions(ctx, false, 1); len(limited) != 1 {
+				t.Errorf("GetFormSubmissions(limit 1) returned %d submissions", len(limited))
+			}
+		})
+	}
+}
+
+func TestPruneFormData(t *testing.T) {
+	db := newTestDatabase(t)
+	ctx := context.Background()
+	now := time.Now()
+
+	err := db.SaveHoneytokens(ctx, []Honeytoken{
+		{Value: "old-token-value-1", Kind: "password", IP: "192.0.2.1", Form: "login", IssuedAt: now.Add(-48 * time.Hour)},
+		{Value: "new-token-value-1", Kind: "password", IP: "192.0.2.1", Form: "login", IssuedAt: now},
+	})
+	if err != nil {
+		t.Fatalf("SaveHoneytokens() error = %v", err)
+	}
+	if err := db.SaveFormSubmission(ctx, FormSubmission{Timestamp: now.Add(-48 * time.Hour), IP: "192.0.2.1", Method: "GET", Path: "/"}); err != nil {
+		t.Fatalf("SaveFormSubmission() error = %v", err)
+	}
+
+	pruned, err := db.PruneFormData(ctx, now.Add(-24*time.Hour))
+	if err != nil {
+		t.Fatalf("PruneFormData() error = %v", err)
+	}
+	if pruned != 2 {
+		t.Errorf("PruneFormData() = %d, want 2", pruned)
+	}
+	found, _ := db.FindHoneytokens(ctx, []string{"old-token-value-1", "new-token-value-1"})
+	if len(found) != 1 || found[0].Value != "new-token-value-1" {
+		t.Errorf("FindHoneytokens() after pruning = %+v, want only the new token", found)
+	}
+}
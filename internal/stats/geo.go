@@ -0,0 +1,207 @@
+package stats
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/rampantspark/gospidertrap/internal/geoip"
+)
+
+// GeoReporter is implemented by stores that can annotate IP addresses with
+// their country and autonomous system from offline GeoIP databases.
+type GeoReporter interface {
+	// SetGeoIP sets the databases used to annotate IPs. It must be called
+	// before the store starts recording requests.
+	SetGeoIP(geo *geoip.Resolver)
+	// GetTopCountries returns up to limit countries by request count, as
+	// ISO codes, highest first.
+	GetTopCountries(ctx context.Context, limit int) ([]CountEntry, error)
+	// GetTopASNs returns up to limit autonomous systems by request count,
+	// labelled like "AS64496 Example Org", highest first.
+	GetTopASNs(ctx context.Context, limit int) ([]CountEntry, error)
+}
+
+// geoColumns converts an Info into the ip_counts country, asn and as_org
+// values, using NULL for unknown fields so that an upsert keeps what an
+// earlier lookup found.
+func geoColumns(info geoip.Info) (country, asn, org any) {
+	if info.Country != "" {
+		country = info.Country
+	}
+	if info.ASN != 0 {
+		asn = int64(info.ASN)
+	}
+	if info.Org != "" {
+		org = info.Org
+	}
+	return country, asn, org
+}
+
+// SetGeoIP sets the databases used to annotate ip_counts with each IP's
+// country and autonomous system as requests are recorded. Call
+// AnnotateIPs to annotate IPs recorded earlier.
+//
+// SetGeoIP must be called before the database starts recording requests.
+//
+// Parameters:
+//   - geo: the GeoIP databases
+func (d *Database) SetGeoIP(geo *geoip.Resolver) {
+	d.geo = geo
+}
+
+// AnnotateIPs looks up every IP in ip_counts that has no country or
+// autonomous system yet, such as IPs recorded before a GeoIP database was
+// configured or imported from another instance.
+//
+// Parameters:
+//   - ctx: context for cancellation and timeout control
+//
+// Returns the number of IPs annotated.
+func (d *Database) AnnotateIPs(ctx context.Context) (int, error) {
+	if d.geo == nil {
+		return 0, nil
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	rows, err := d.db.QueryContext(ctx, `SELECT ip FROM ip_counts WHERE country IS NULL AND asn IS NULL`)
+	if err != nil {
+		return 0, fmt.Errorf("failed to query unannotated IPs: %w", err)
+	}
+	var ips []string
+	for rows.Next() {
+		var ip string
+		if err := rows.Scan(&ip); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan IP: %w", err)
+		}
+		ips = append(ips, ip)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("error iterating unannotated IPs: %w", err)
+	}
+
+	tx, err := d.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	annotated := 0
+	err = execEach(ctx, tx, `
+		UPDATE ip_counts SET country = ?, asn = ?, as_org = ? WHERE ip = ?
+	`, len(ips), func(i int, stmt *sql.Stmt) error {
+		info := d.geo.Lookup(ips[i])
+		if info == (geoip.Info{}) {
+			return nil
+		}
+		country, asn, org := geoColumns(info)
+		annotated++
+		_, err := stmt.ExecContext(ctx, country, asn, org, ips[i])
+		return err
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to annotate IPs: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return annotated, nil
+}
+
+// GetTopCountries returns up to limit countries by request count, highest
+// first. IPs without a known country are left out.
+func (d *Database) GetTopCountries(ctx context.Context, limit int) ([]CountEntry, error) {
+	return d.queryCounts(ctx, "countries", `
+		SELECT country, SUM(count) AS total
+		FROM ip_counts
+		WHERE country IS NOT NULL
+		GROUP BY country
+		ORDER BY total DESC, country
+		LIMIT ?
+	`, limit)
+}
+
+// GetTopASNs returns up to limit autonomous systems by request count,
+// highest first. IPs without a known autonomous system are left out.
+func (d *Database) GetTopASNs(ctx context.Context, limit int) ([]CountEntry, error) {
+	return d.queryCounts(ctx, "autonomous systems", `
+		SELECT 'AS' || asn || COALESCE(' ' || MAX(as_org), ''), SUM(count) AS total
+		FROM ip_counts
+		WHERE asn IS NOT NULL
+		GROUP BY asn
+		ORDER BY total DESC, asn
+		LIMIT ?
+	`, limit)
+}
+
+// queryCounts runs a query returning label and count rows.
+func (d *Database) queryCounts(ctx context.Context, what, query string, args ...any) ([]CountEntry, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	rows, err := d.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query top %s: %w", what, err)
+	}
+	defer rows.Close()
+
+	var result []CountEntry
+	for rows.Next() {
+		var entry CountEntry
+		if err := rows.Scan(&entry.Label, &entry.Count); err != nil {
+			return nil, fmt.Errorf("failed to scan count: %w", err)
+		}
+		result = append(result, entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating %s: %w", what, err)
+	}
+	return result, nil
+}
+
+// SetGeoIP sets the databases used for the country and autonomous system
+// reports. IPs are looked up when a report is requested, since counts are
+// kept per IP.
+//
+// SetGeoIP must be called before the store starts recording requests.
+//
+// Parameters:
+//   - geo: the GeoIP databases
+func (s *MemoryStore) SetGeoIP(geo *geoip.Resolver) {
+	s.geo = geo
+}
+
+// GetTopCountries returns up to limit countries by request count, highest
+// first. IPs without a known country are left out.
+func (s *MemoryStore) GetTopCountries(ctx context.Context, limit int) ([]CountEntry, error) {
+	return s.geoCounts(limit, func(info geoip.Info) string { return info.Country }), nil
+}
+
+// GetTopASNs returns up to limit autonomous systems by request count,
+// highest first. IPs without a known autonomous system are left out.
+func (s *MemoryStore) GetTopASNs(ctx context.Context, limit int) ([]CountEntry, error) {
+	return s.geoCounts(limit, geoip.Info.Network), nil
+}
+
+// geoCounts sums the tracked IP counts by a label derived from each IP's
+// GeoIP information, skipping IPs whose label is empty.
+func (s *MemoryStore) geoCounts(limit int, label func(geoip.Info) string) []CountEntry {
+	if s.geo == nil {
+		return nil
+	}
+
+	s.stats.Mu.RLock()
+	defer s.stats.Mu.RUnlock()
+
+	counts := make(map[string]int)
+	for ip, count := range s.stats.IPCounts {
+		if l := label(s.geo.Lookup(ip)); l != "" {
+			counts[l] += count
+		}
+	}
+	return topCounts(counts, limit)
+}
@@ -0,0 +1,128 @@
+package stats
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/rampantspark/gospidertrap/internal/geoip"
+)
+
+// newTestGeo opens the fixture GeoIP databases, which place 10.0.0.0/24 in
+// the US on AS64496 and 192.0.2.0/24 in Germany on AS64497.
+func newTestGeo(t *testing.T) *geoip.Resolver {
+	t.Helper()
+	geo, err := geoip.NewResolver("../geoip/testdata/city.mmdb", "../geoip/testdata/asn.mmdb")
+	if err != nil {
+		t.Fatalf("NewResolver() error = %v", err)
+	}
+	t.Cleanup(func() { geo.Close() })
+	return geo
+}
+
+func TestGeoReporter(t *testing.T) {
+	now := time.Now()
+	reqs := []RequestInfo{
+		{IP: "10.0.0.1", UserAgent: "bot", Path: "/a", Timestamp: now},
+		{IP: "10.0.0.2", UserAgent: "bot", Path: "/a", Timestamp: now},
+		{IP: "192.0.2.1", UserAgent: "bot", Path: "/a", Timestamp: now},
+		{IP: "192.0.2.1", UserAgent: "bot", Path: "/b", Timestamp: now},
+		{IP: "192.0.2.1", UserAgent: "bot", Path: "/c", Timestamp: now},
+		{IP: "198.51.100.1", UserAgent: "bot", Path: "/a", Timestamp: now}, // Not in the databases
+	}
+	backends := map[string]GeoReporter{
+		"sqlite": newTestDatabase(t),
+		"memory": NewMemoryStore(NewStats()),
+	}
+
+	for backend, reporter := range backends {
+		t.Run(backend, func(t *testing.T) {
+			ctx := context.Background()
+			reporter.SetGeoIP(newTestGeo(t))
+			if _, err := reporter.(Store).RecordRequests(ctx, reqs); err != nil {
+				t.Fatalf("RecordRequests() error = %v", err)
+			}
+
+			countries, err := reporter.GetTopCountries(ctx, 10)
+			if err != nil {
+				t.Fatalf("GetTopCountries() error = %v", err)
+			}
+			if want := []CountEntry{{"DE", 3}, {"US", 2}}; !slices.Equal(countries, want) {
+				t.Errorf("GetTopCountries() = %v, want %v", countries, want)
+			}
+
+			asns, err := reporter.GetTopASNs(ctx, 1)
+			if err != nil {
+				t.Fatalf("GetTopASNs() error = %v", err)
+			}
+			if want := []CountEntry{{"AS64497 Example Transit", 3}}; !slices.Equal(asns, want) {
+				t.Errorf("GetTopASNs() = %v, want %v", asns, want)
+			}
+
+			detail, err := reporter.(DetailReporter).GetIPDetail(ctx, "10.0.0.1", 10)
+			if err != nil {
+				t.Fatalf("GetIPDetail() error = %v", err)
+			}
+			if want := (geoip.Info{Country: "US", ASN: 64496, Org: "Example Hosting"}); detail.Geo != want {
+				t.Errorf("Geo = %+v, want %+v", detail.Geo, want)
+			}
+		})
+	}
+}
+
+func TestAnnotateIPs(t *testing.T) {
+	db := newTestDatabase(t)
+	ctx := context.Background()
+	seedRequests(t, db, []RequestInfo{
+		{IP: "10.0.0.1", UserAgent: "bot", Path: "/a", Timestamp: time.Now()},
+		{IP: "198.51.100.1", UserAgent: "bot", Path: "/a", Timestamp: time.Now()},
+	})
+
+	// Without databases nothing is looked up
+	if n, err := db.AnnotateIPs(ctx); n != 0 || err != nil {
+		t.Errorf("AnnotateIPs() without GeoIP = %d, %v, want 0, nil", n, err)
+	}
+
+	db.SetGeoIP(newTestGeo(t))
+	n, err := db.AnnotateIPs(ctx)
+	if err != nil {
+		t.Fatalf("AnnotateIPs() error = %v", err)
+	}
+	if n != 1 {
+		t.Errorf("AnnotateIPs() = %d, want 1 (the other IP is not in the databases)", n)
+	}
+	if countries, _ := db.GetTopCountries(ctx, 10); !slices.Equal(countries, []CountEntry{{"US", 1}}) {
+		t.Errorf("GetTopCountries() after annotating = %v, want US", countries)
+	}
+}
+
+func TestManagerGeoCharts(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	store := NewMemoryStore(NewStats())
+	m := NewManager(store, false, logger)
+
+	// Without databases the geo charts stay empty
+	if data := m.GetChartData(context.Background(), 10, 50); len(data.TopCountries.Labels) != 0 {
+		t.Errorf("TopCountries without GeoIP = %v, want empty", data.TopCountries.Labels)
+	}
+
+	if !m.SetGeoIP(newTestGeo(t)) || m.GeoIP() == nil {
+		t.Fatal("SetGeoIP() on a memory store returned false")
+	}
+	if _, err := store.RecordRequests(context.Background(), []RequestInfo{{IP: "192.0.2.1", UserAgent: "bot", Path: "/", Timestamp: time.Now()}}); err != nil {
+		t.Fatalf("RecordRequests() error = %v", err)
+	}
+	data := m.GetChartData(context.Background(), 10, 50)
+	if !slices.Equal(data.TopCountries.Labels, []string{"DE"}) || !slices.Equal(data.TopASNs.Labels, []string{"AS64497 Example Transit"}) {
+		t.Errorf("geo charts = %v and %v, want DE and AS64497", data.TopCountries.Labels, data.TopASNs.Labels)
+	}
+
+	// Stores without GeoIP support are left alone
+	unsupported := NewManager(struct{ Store }{store}, false, logger)
+	if unsupported.SetGeoIP(newTestGeo(t)) || unsupported.GeoIP() != nil {
+		t.Error("SetGeoIP() succeeded on a store without GeoIP support")
+	}
+}
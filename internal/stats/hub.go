@@ -0,0 +1,116 @@
+package stats
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// Event is a recorded request delivered to live subscribers.
+type Event struct {
+	Request RequestInfo
+	Result  RecordResult
+}
+
+// Hub fans recorded requests out to live subscribers, such as admin
+// dashboards.
+//
+// Publishing never blocks: a subscriber whose buffer is full misses the
+// event, and the miss is counted on its Subscription.
+type Hub struct {
+	mu     sync.Mutex
+	subs   map[*Subscription]struct{}
+	closed bool
+}
+
+// Subscription receives events published to a Hub.
+type Subscription struct {
+	hub     *Hub
+	events  chan Event
+	dropped atomic.Int64
+	once    sync.Once
+}
+
+// NewHub creates a hub with no subscribers.
+//
+// Returns a new Hub instance.
+func NewHub() *Hub {
+	return &Hub{subs: make(map[*Subscription]struct{})}
+}
+
+// Subscribe registers a new subscriber.
+//
+// Parameters:
+//   - buffer: number of events that can wait for the subscriber before
+//     further events are dropped
+//
+// Returns the subscription, which the caller must close. If the hub has
+// been closed, the subscription's channel is already closed.
+func (h *Hub) Subscribe(buffer int) *Subscription {
+	s := &Subscription{hub: h, events: make(chan Event, buffer)}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		s.once.Do(func() { close(s.events) })
+		return s
+	}
+	h.subs[s] = struct{}{}
+	return s
+}
+
+// Publish delivers a recorded request to every subscriber. Its signature
+// matches RecordHook.
+//
+// Parameters:
+//   - req: the recorded request
+//   - result: the counts after the request was recorded
+func (h *Hub) Publish(req RequestInfo, result RecordResult) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for s := range h.subs {
+		select {
+		case s.events <- Event{Request: req, Result: result}:
+		default:
+			s.dropped.Add(1)
+		}
+	}
+}
+
+// Subscribers returns the number of active subscriptions.
+func (h *Hub) Subscribers() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.subs)
+}
+
+// Close closes every subscription's channel so subscribers can finish,
+// for example before the HTTP server shuts down. Later publishes are
+// discarded.
+func (h *Hub) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.closed = true
+	for s := range h.subs {
+		delete(h.subs, s)
+		s.once.Do(func() { close(s.events) })
+	}
+}
+
+// Events returns the channel events are delivered on. It is closed when
+// the subscription or the hub is closed.
+func (s *Subscription) Events() <-chan Event {
+	return s.events
+}
+
+// Dropped returns the number of events missed because the buffer was full.
+func (s *Subscription) Dropped() int64 {
+	return s.dropped.Load()
+}
+
+// Close unsubscribes and closes the events channel.
+func (s *Subscription) Close() {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+	delete(s.hub.subs, s)
+	s.once.Do(func() { close(s.events) })
+}
@@ -0,0 +1,68 @@
+package stats
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHub(t *testing.T) {
+	hub := NewHub()
+	fast := hub.Subscribe(4)
+	slow := hub.Subscribe(1)
+
+	for i := 1; i <= 3; i++ {
+		hub.Publish(RequestInfo{IP: "10.0.0.1"}, RecordResult{IPCount: i})
+	}
+
+	for i := 1; i <= 3; i++ {
+		if e := <-fast.Events(); e.Result.IPCount != i {
+			t.Errorf("event %d has IPCount %d", i, e.Result.IPCount)
+		}
+	}
+	if fast.Dropped() != 0 {
+		t.Errorf("fast subscriber dropped %d events, want 0", fast.Dropped())
+	}
+	// A full buffer drops events instead of blocking the publisher
+	if e := <-slow.Events(); e.Result.IPCount != 1 {
+		t.Errorf("slow subscriber got IPCount %d, want the first event", e.Result.IPCount)
+	}
+	if slow.Dropped() != 2 {
+		t.Errorf("slow subscriber dropped %d events, want 2", slow.Dropped())
+	}
+
+	slow.Close()
+	slow.Close() // Closing twice is harmless
+	if hub.Subscribers() != 1 {
+		t.Errorf("Subscribers() = %d, want 1", hub.Subscribers())
+	}
+
+	hub.Close()
+	if _, ok := <-fast.Events(); ok {
+		t.Error("events channel still open after the hub closed")
+	}
+	fast.Close()
+	hub.Publish(RequestInfo{}, RecordResult{}) // Discarded after Close
+	if _, ok := <-hub.Subscribe(1).Events(); ok {
+		t.Error("subscribing to a closed hub returned an open channel")
+	}
+}
+
+func TestManagerPublishesRecordedRequests(t *testing.T) {
+	m := NewManager(NewMemoryStore(NewStats()), false, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	sub := m.Hub().Subscribe(1)
+	defer sub.Close()
+
+	r := httptest.NewRequest("GET", "/trap", nil)
+	r.RemoteAddr = "10.0.0.1:1234"
+	if err := m.RecordRequest(context.Background(), r); err != nil {
+		t.Fatalf("RecordRequest() error = %v", err)
+	}
+
+	e := <-sub.Events()
+	if e.Request.Path != "/trap" || e.Request.IP != "10.0.0.1" || e.Result.IPCount != 1 {
+		t.Errorf("event = %+v, want the recorded request", e)
+	}
+}
@@ -0,0 +1,489 @@
+package stats
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// importBatchSize is the number of requests merged per transaction.
+const importBatchSize = 1000
+
+// errNoImportSource is returned when an import does not name its source.
+var errNoImportSource = errors.New("import source must not be empty")
+
+// sqliteMagic is the header every SQLite database file starts with.
+var sqliteMagic = []byte("SQLite format 3\x00")
+
+// ImportResult reports the outcome of an import.
+type ImportResult struct {
+	Imported   int `json:"imported"`   // Requests added to the request log
+	Duplicates int `json:"duplicates"` // Requests skipped because they were imported or recorded here before
+	Invalid    int `json:"invalid"`    // Lines or requests that could not be imported
+}
+
+// add accumulates another result into r.
+func (r *ImportResult) add(other ImportResult) {
+	r.Imported += other.Imported
+	r.Duplicates += other.Duplicates
+	r.Invalid += other.Invalid
+}
+
+// countUpdate accumulates the imported requests for one IP or user agent.
+type countUpdate struct {
+	count     int
+	firstSeen time.Time
+	lastSeen  time.Time
+}
+
+// observeCount adds a request at ts to the update for key.
+func observeCount(counts map[string]*countUpdate, key string, ts time.Time) {
+	c, ok := counts[key]
+	if !ok {
+		counts[key] = &countUpdate{count: 1, firstSeen: ts, lastSeen: ts}
+		return
+	}
+	if ts.Before(c.firstSeen) {
+		c.firstSeen = ts
+	}
+	if ts.After(c.lastSeen) {
+		c.lastSeen = ts
+	}
+	c.count++
+}
+
+// importKey identifies a request by its contents, so the same request
+// imported twice, possibly through different instances, is stored once.
+func importKey(req RequestInfo) string {
+	h := sha256.New()
+	for _, field := range []string{req.IP, req.UserAgent, req.Path, req.Timestamp.UTC().Format(time.RFC3339Nano)} {
+		h.Write([]byte(field))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// validImport reports whether an imported request fits the request_log
+// constraints.
+func validImport(req RequestInfo) bool {
+	return req.IP != "" && len(req.IP) <= maxIPLength &&
+		len(req.UserAgent) <= maxUserAgentLength &&
+		len(req.Path) <= maxPathLength &&
+		!req.Timestamp.IsZero()
+}
+
+// ImportRequests merges requests recorded by another instance into the
+// database in a single transaction.
+//
+// Requests without a source are tagged with source. Requests that were
+// imported before are skipped, as are requests this instance recorded
+// itself, which come back when its data is imported into another instance
+// and exported from there. For each new request the IP and user agent
+// counts are incremented and their first and last seen times widened to
+// include it, and the stats start time is moved back if the request is
+// older. Record hooks are not run for imported requests.
+//
+// Parameters:
+//   - ctx: context for cancellation and timeout control
+//   - source: name of the instance that recorded the requests
+//   - reqs: the requests to import
+//
+// Returns the number of requests imported, skipped as duplicates and
+// rejected as invalid, or an error if the transaction fails.
+func (d *Database) ImportRequests(ctx context.Context, source string, reqs []RequestInfo) (ImportResult, error) {
+	if source == "" {
+		return ImportResult{}, errNoImportSource
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	tx, err := d.db.BeginTx(ctx, nil)
+	if err != nil {
+		return ImportResult{}, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Locally recorded rows have no import key, so they are matched by
+	// contents. The range allows for backfilled timestamp_ms values, which
+	// are rounded rather than truncated.
+	local, err := tx.PrepareContext(ctx, `
+		SELECT timestamp
+		FROM request_log
+		WHERE timestamp_ms BETWEEN ? AND ? AND ip = ? AND COALESCE(user_agent, '') = ? AND COALESCE(path, '') = ? AND source = ''
+	`)
+	if err != nil {
+		return ImportResult{}, fmt.Errorf("failed to prepare local request lookup: %w", err)
+	}
+	defer local.Close()
+
+	var result ImportResult
+	var earliest time.Time
+	ips := make(map[string]*countUpdate)
+	userAgents := make(map[string]*countUpdate)
+	err = execEach(ctx, tx, `
+		INSERT OR IGNORE INTO request_log (ip, user_agent, path, timestamp, timestamp_ms, source, bait, import_key)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`, len(reqs), func(i int, stmt *sql.Stmt) error {
+		req := reqs[i]
+		if !validImport(req) {
+			result.Invalid++
+			return nil
+		}
+		if req.UserAgent == "" {
+			req.UserAgent = "Unknown"
+		}
+		if req.Source == "" {
+			req.Source = source
+		}
+		if recorded, err := recordedLocally(ctx, local, req); err != nil {
+			return err
+		} else if recorded {
+			result.Duplicates++
+			return nil
+		}
+
+		res, err := stmt.ExecContext(ctx, req.IP, req.UserAgent, req.Path, req.Timestamp, req.Timestamp.UnixMilli(), req.Source, req.Bait, importKey(req))
+		if err != nil {
+			return err
+		}
+		if n, err := res.RowsAffected(); err != nil {
+			return err
+		} else if n == 0 {
+			result.Duplicates++
+			return nil
+		}
+
+		result.Imported++
+		if earliest.IsZero() || req.Timestamp.Before(earliest) {
+			earliest = req.Timestamp
+		}
+		observeCount(ips, req.IP, req.Timestamp)
+		observeCount(userAgents, req.UserAgent, req.Timestamp)
+		return nil
+	})
+	if err != nil {
+		return ImportResult{}, fmt.Errorf("failed to insert imported requests: %w", err)
+	}
+	if result.Imported == 0 {
+		return result, nil
+	}
+
+	if err := mergeCounts(ctx, tx, "ip_counts", "ip", ips); err != nil {
+		return ImportResult{}, fmt.Errorf("failed to update IP counts: %w", err)
+	}
+	if err := mergeCounts(ctx, tx, "user_agent_counts", "user_agent", userAgents); err != nil {
+		return ImportResult{}, fmt.Errorf("failed to update user agent counts: %w", err)
+	}
+
+	// The start time moves back if an imported request predates it
+	var startTime time.Time
+	if err := tx.QueryRowContext(ctx, "SELECT start_time FROM stats WHERE id = 1").Scan(&startTime); err != nil {
+		return ImportResult{}, fmt.Errorf("failed to query stats: %w", err)
+	}
+	if earliest.Before(startTime) {
+		startTime = earliest
+	}
+	_, err = tx.ExecContext(ctx, `
+		UPDATE stats
+		SET total_requests = total_requests + ?,
+		    start_time = ?,
+		    updated_at = ?
+		WHERE id = 1
+	`, result.Imported, startTime, time.Now())
+	if err != nil {
+		return ImportResult{}, fmt.Errorf("failed to update total requests: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return ImportResult{}, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return result, nil
+}
+
+// recordedLocally reports whether req matches a request this instance
+// recorded itself.
+//
+// Parameters:
+//   - ctx: context for cancellation and timeout control
+//   - stmt: the local request lookup prepared by ImportRequests
+//   - req: the imported request
+func recordedLocally(ctx context.Context, stmt *sql.Stmt, req RequestInfo) (bool, error) {
+	ms := req.Timestamp.UnixMilli()
+	rows, err := stmt.QueryContext(ctx, ms-1, ms+1, req.IP, req.UserAgent, req.Path)
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var ts time.Time
+		if err := rows.Scan(&ts); err != nil {
+			return false, err
+		}
+		if ts.Equal(req.Timestamp) {
+			return true, nil
+		}
+	}
+	return false, rows.Err()
+}
+
+// mergeCounts adds imported requests to ip_counts or user_agent_counts,
+// widening each row's first_seen and last_seen to include them.
+//
+// Parameters:
+//   - ctx: context for cancellation and timeout control
+//   - tx: the import transaction
+//   - table: ip_counts or user_agent_counts
+//   - column: the table's key column
+//   - counts: imported requests per key
+func mergeCounts(ctx context.Context, tx *sql.Tx, table, column string, counts map[string]*countUpdate) error {
+	// #nosec G201 -- table and column are constants chosen by ImportRequests
+	query := fmt.Sprintf("SELECT first_seen, last_seen FROM %s WHERE %s = ?", table, column)
+	insert := fmt.Sprintf("INSERT INTO %s (%s, count, first_seen, last_seen, last_seen_ms) VALUES (?, ?, ?, ?, ?)", table, column)
+	update := fmt.Sprintf("UPDATE %s SET count = count + ?, first_seen = ?, last_seen = ?, last_seen_ms = ? WHERE %s = ?", table, column)
+
+	for key, c := range counts {
+		var firstSeen, lastSeen time.Time
+		err := tx.QueryRowContext(ctx, query, key).Scan(&firstSeen, &lastSeen)
+		if errors.Is(err, sql.ErrNoRows) {
+			if _, err := tx.ExecContext(ctx, insert, key, c.count, c.firstSeen, c.lastSeen, c.lastSeen.UnixMilli()); err != nil {
+				return err
+			}
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		if c.firstSeen.Before(firstSeen) {
+			firstSeen = c.firstSeen
+		}
+		if c.lastSeen.After(lastSeen) {
+			lastSeen = c.lastSeen
+		}
+		if _, err := tx.ExecContext(ctx, update, c.count, firstSeen, lastSeen, lastSeen.UnixMilli(), key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// importBatcher collects requests and imports them in batches.
+type importBatcher struct {
+	ctx    context.Context
+	db     *Database
+	source string
+	batch  []RequestInfo
+	result ImportResult
+}
+
+// add queues a request, importing the batch once it is full.
+func (b *importBatcher) add(req RequestInfo) error {
+	b.batch = append(b.batch, req)
+	if len(b.batch) < importBatchSize {
+		return nil
+	}
+	return b.flush()
+}
+
+// flush imports the queued requests.
+func (b *importBatcher) flush() error {
+	if len(b.batch) == 0 {
+		return nil
+	}
+	result, err := b.db.ImportRequests(b.ctx, b.source, b.batch)
+	if err != nil {
+		return err
+	}
+	b.result.add(result)
+	b.batch = b.batch[:0]
+	return nil
+}
+
+// ImportNDJSON merges requests from NDJSON, one RequestInfo per line, as
+// written by the "stats export" command and by file mode's request log.
+// Gzipped input, such as a rotated file mode log, is decompressed.
+//
+// Lines that cannot be parsed are counted as invalid. Requests are
+// imported in batches, each in its own transaction.
+//
+// Parameters:
+//   - ctx: context for cancellation and timeout control
+//   - r: the NDJSON input
+//   - source: name of the instance that recorded the requests
+//
+// Returns the combined result of the batches. On error, the result covers
+// the batches imported before the failure.
+func (d *Database) ImportNDJSON(ctx context.Context, r io.Reader, source string) (ImportResult, error) {
+	if source == "" {
+		return ImportResult{}, errNoImportSource
+	}
+
+	br := bufio.NewReader(r)
+	if header, _ := br.Peek(2); bytes.Equal(header, []byte{0x1f, 0x8b}) {
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return ImportResult{}, fmt.Errorf("failed to read gzip header: %w", err)
+		}
+		defer gz.Close()
+		r = gz
+	} else {
+		r = br
+	}
+
+	b := &importBatcher{ctx: ctx, db: d, source: source}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var req RequestInfo
+		if err := json.Unmarshal(line, &req); err != nil {
+			b.result.Invalid++
+			continue
+		}
+		if err := b.add(req); err != nil {
+			return b.result, err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return b.result, fmt.Errorf("failed to read NDJSON: %w", err)
+	}
+	if err := b.flush(); err != nil {
+		return b.result, err
+	}
+	return b.result, nil
+}
+
+// ImportDatabase merges the request log of another instance's SQLite
+// database.
+//
+// The other database is opened read-only and must be at the same schema
+// version. Only requests still in its request log are imported; requests
+// it has already pruned into daily totals are not.
+//
+// Parameters:
+//   - ctx: context for cancellation and timeout control
+//   - path: path to the other database file
+//   - source: name of the instance that recorded the requests
+//
+// Returns the combined result of the batches. On error, the result covers
+// the batches imported before the failure.
+func (d *Database) ImportDatabase(ctx context.Context, path, source string) (ImportResult, error) {
+	if source == "" {
+		return ImportResult{}, errNoImportSource
+	}
+	if sameFile(path, d.path) {
+		return ImportResult{}, errors.New("cannot import a database into itself")
+	}
+
+	src, err := OpenDatabaseReadOnly(path, d.logger)
+	if err != nil {
+		return ImportResult{}, err
+	}
+	defer src.Close()
+
+	b := &importBatcher{ctx: ctx, db: d, source: source}
+	if err := src.ExportRequests(ctx, time.Time{}, b.add); err != nil {
+		return b.result, err
+	}
+	if err := b.flush(); err != nil {
+		return b.result, err
+	}
+	return b.result, nil
+}
+
+// Import merges an NDJSON export, a gzipped NDJSON request log or a SQLite
+// database read from r. It implements Importer.
+//
+// A SQLite database is copied to a temporary file next to this database
+// before it is opened.
+//
+// Parameters:
+//   - ctx: context for cancellation and timeout control
+//   - r: the data to import
+//   - source: name of the instance that recorded the requests
+//
+// Returns the import result, or an error if the data cannot be read or
+// imported.
+func (d *Database) Import(ctx context.Context, r io.Reader, source string) (ImportResult, error) {
+	br := bufio.NewReader(r)
+	if header, _ := br.Peek(len(sqliteMagic)); !bytes.Equal(header, sqliteMagic) {
+		return d.ImportNDJSON(ctx, br, source)
+	}
+	if source == "" {
+		return ImportResult{}, errNoImportSource
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(d.path), "import-*.db")
+	if err != nil {
+		return ImportResult{}, fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	defer func() {
+		// Opening the copy may create WAL and shared memory files beside it
+		for _, suffix := range []string{"", "-wal", "-shm"} {
+			os.Remove(tmp.Name() + suffix)
+		}
+	}()
+
+	_, err = io.Copy(tmp, br)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return ImportResult{}, fmt.Errorf("failed to store uploaded database: %w", err)
+	}
+	return d.ImportDatabase(ctx, tmp.Name(), source)
+}
+
+// ImportFile merges an NDJSON export, a gzipped NDJSON request log or a
+// SQLite database file.
+//
+// Parameters:
+//   - ctx: context for cancellation and timeout control
+//   - path: the file to import
+//   - source: name of the instance that recorded the requests
+//
+// Returns the import result, or an error if the file cannot be read or
+// imported.
+func (d *Database) ImportFile(ctx context.Context, path, source string) (ImportResult, error) {
+	// #nosec G304 -- path is chosen by the operator running the import
+	f, err := os.Open(path)
+	if err != nil {
+		return ImportResult{}, fmt.Errorf("failed to open import file: %w", err)
+	}
+	defer f.Close()
+
+	header := make([]byte, len(sqliteMagic))
+	n, _ := io.ReadFull(f, header)
+	if bytes.Equal(header[:n], sqliteMagic) {
+		return d.ImportDatabase(ctx, path, source)
+	}
+	return d.ImportNDJSON(ctx, io.MultiReader(bytes.NewReader(header[:n]), f), source)
+}
+
+// sameFile reports whether two paths refer to the same existing file.
+func sameFile(a, b string) bool {
+	infoA, err := os.Stat(a)
+	if err != nil {
+		return false
+	}
+	infoB, err := os.Stat(b)
+	if err != nil {
+		return false
+	}
+	return os.SameFile(infoA, infoB)
+}
@@ -0,0 +1,331 @@
+package stats
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestImportRequests(t *testing.T) {
+	db := newTestDatabase(t)
+	ctx := context.Background()
+	now := time.Now().Truncate(time.Second)
+	seedRequests(t, db, []RequestInfo{
+		{IP: "10.0.0.1", UserAgent: "bot", Path: "/", Timestamp: now},
+	})
+
+	older := now.Add(-48 * time.Hour)
+	newer := now.Add(time.Hour)
+	reqs := []RequestInfo{
+		{IP: "10.0.0.1", UserAgent: "bot", Path: "/a", Timestamp: older},
+		{IP: "10.0.0.1", UserAgent: "bot", Path: "/b", Timestamp: newer},
+		{IP: "10.0.0.2", UserAgent: "", Path: "/a", Timestamp: now, Source: "trap-c"},
+		{IP: "10.0.0.2", UserAgent: "", Path: "/a", Timestamp: now, Source: "trap-c"}, // Duplicate within the batch
+		{IP: "", UserAgent: "bot", Path: "/", Timestamp: now},                         // Invalid
+		{IP: "10.0.0.3", UserAgent: "bot", Path: "/"},                                 // Invalid
+	}
+	result, err := db.ImportRequests(ctx, "trap-b", reqs)
+	if err != nil {
+		t.Fatalf("ImportRequests() error = %v", err)
+	}
+	if want := (ImportResult{Imported: 3, Duplicates: 1, Invalid: 2}); result != want {
+		t.Errorf("ImportRequests() = %+v, want %+v", result, want)
+	}
+
+	// Importing the same requests again adds nothing
+	result, err = db.ImportRequests(ctx, "trap-b", reqs)
+	if err != nil {
+		t.Fatalf("ImportRequests() error = %v", err)
+	}
+	if want := (ImportResult{Duplicates: 4, Invalid: 2}); result != want {
+		t.Errorf("second ImportRequests() = %+v, want %+v", result, want)
+	}
+
+	summary, err := db.GetSummary(ctx)
+	if err != nil {
+		t.Fatalf("GetSummary() error = %v", err)
+	}
+	if summary.TotalRequests != 4 || summary.UniqueIPs != 2 || summary.UniqueUserAgents != 2 {
+		t.Errorf("summary = %+v, want 4 requests, 2 IPs, 2 user agents", summary)
+	}
+	if !summary.StartTime.Equal(older) {
+		t.Errorf("StartTime = %v, want the oldest imported request %v", summary.StartTime, older)
+	}
+
+	detail, err := db.GetIPDetail(ctx, "10.0.0.1", 10)
+	if err != nil {
+		t.Fatalf("GetIPDetail() error = %v", err)
+	}
+	if detail.Count != 3 || !detail.FirstSeen.Equal(older) || !detail.LastSeen.Equal(newer) {
+		t.Errorf("10.0.0.1 = %d requests from %v to %v, want 3 from %v to %v",
+			detail.Count, detail.FirstSeen, detail.LastSeen, older, newer)
+	}
+
+	sources := make(map[string]string)
+	err = db.ExportRequests(ctx, time.Time{}, func(req RequestInfo) error {
+		sources[req.Path+" "+req.IP] = req.Source
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ExportRequests() error = %v", err)
+	}
+	for key, want := range map[string]string{"/ 10.0.0.1": "", "/a 10.0.0.1": "trap-b", "/a 10.0.0.2": "trap-c"} {
+		if sources[key] != want {
+			t.Errorf("source of %s = %q, want %q", key, sources[key], want)
+		}
+	}
+
+	if _, err := db.ImportRequests(ctx, "", reqs); err == nil {
+		t.Error("ImportRequests() without a source succeeded")
+	}
+}
+
+func TestImportedRequestsInWindows(t *testing.T) {
+	db := newTestDatabase(t)
+	ctx := context.Background()
+	now := time.Now()
+	seedRequests(t, db, []RequestInfo{
+		{IP: "10.0.0.1", UserAgent: "bot", Path: "/", Timestamp: now.Add(-time.Hour)},
+	})
+
+	// Imported after the local request but older than the window, so out
+	// of time order
+	_, err := db.ImportRequests(ctx, "trap-b", []RequestInfo{
+		{IP: "10.0.0.2", UserAgent: "bot", Path: "/", Timestamp: now.Add(-72 * time.Hour)},
+	})
+	if err != nil {
+		t.Fatalf("ImportRequests() error = %v", err)
+	}
+	seedRequests(t, db, []RequestInfo{
+		{IP: "10.0.0.3", UserAgent: "bot", Path: "/", Timestamp: now},
+	})
+
+	got, err := db.GetTopIPsSince(ctx, now.Add(-24*time.Hour), 10)
+	if err != nil {
+		t.Fatalf("GetTopIPsSince() error = %v", err)
+	}
+	if len(got) != 2 {
+		t.Errorf("GetTopIPsSince() = %v, want both local requests", got)
+	}
+
+	// The imported request is pruned by age though newer rows precede it
+	pruned, err := db.PruneRequestLog(ctx, RetentionPolicy{MaxAge: 24 * time.Hour}, now)
+	if err != nil {
+		t.Fatalf("PruneRequestLog() error = %v", err)
+	}
+	if pruned != 1 {
+		t.Errorf("PruneRequestLog() = %d, want the imported request", pruned)
+	}
+}
+
+func TestImportedRequestsInRecent(t *testing.T) {
+	db := newTestDatabase(t)
+	ctx := context.Background()
+	now := time.Now().In(time.FixedZone("CET", 3600))
+	seedRequests(t, db, []RequestInfo{
+		{IP: "10.0.0.1", UserAgent: "bot", Path: "/", Timestamp: now.Add(-time.Hour)},
+		{IP: "10.0.0.3", UserAgent: "bot", Path: "/", Timestamp: now},
+	})
+
+	// UTC times are stored as RFC 3339, which sorts after Go's layout as text
+	_, err := db.ImportRequests(ctx, "trap-b", []RequestInfo{
+		{IP: "10.0.0.2", UserAgent: "bot", Path: "/", Timestamp: now.Add(-30 * time.Minute).UTC()},
+	})
+	if err != nil {
+		t.Fatalf("ImportRequests() error = %v", err)
+	}
+
+	recent, err := db.GetRecentRequests(ctx, 3)
+	if err != nil {
+		t.Fatalf("GetRecentRequests() error = %v", err)
+	}
+	var ips []string
+	for _, req := range recent {
+		ips = append(ips, req.IP)
+	}
+	if got := strings.Join(ips, ","); got != "10.0.0.3,10.0.0.2,10.0.0.1" {
+		t.Errorf("GetRecentRequests() = %s, want newest first", got)
+	}
+}
+
+func TestImportRoundTrip(t *testing.T) {
+	a, b := newTestDatabase(t), newTestDatabase(t)
+	ctx := context.Background()
+	now := time.Now()
+	seedRequests(t, a, []RequestInfo{
+		{IP: "10.0.0.1", UserAgent: "bot", Path: "/", Timestamp: now.Add(-time.Minute)},
+		{IP: "10.0.0.2", UserAgent: "bot", Path: "/a", Timestamp: now},
+	})
+	seedRequests(t, b, []RequestInfo{
+		{IP: "10.0.0.3", UserAgent: "bot", Path: "/", Timestamp: now},
+	})
+	export := func(db *Database) []RequestInfo {
+		var reqs []RequestInfo
+		if err := db.ExportRequests(ctx, time.Time{}, func(req RequestInfo) error {
+			reqs = append(reqs, req)
+			return nil
+		}); err != nil {
+			t.Fatalf("ExportRequests() error = %v", err)
+		}
+		return reqs
+	}
+
+	// A's requests travel to B and come back tagged with A's name
+	if _, err := b.ImportRequests(ctx, "trap-a", export(a)); err != nil {
+		t.Fatalf("ImportRequests() into B error = %v", err)
+	}
+	result, err := a.ImportRequests(ctx, "trap-b", export(b))
+	if err != nil {
+		t.Fatalf("ImportRequests() into A error = %v", err)
+	}
+	if want := (ImportResult{Imported: 1, Duplicates: 2}); result != want {
+		t.Errorf("ImportRequests() = %+v, want %+v", result, want)
+	}
+
+	s, err := a.GetStats(ctx)
+	if err != nil {
+		t.Fatalf("GetStats() error = %v", err)
+	}
+	if s.TotalRequests != 3 || s.IPCounts["10.0.0.1"] != 1 || s.UserAgents["bot"] != 3 {
+		t.Errorf("A has %d requests, %d from 10.0.0.1, %d from bot, want 3, 1, 3",
+			s.TotalRequests, s.IPCounts["10.0.0.1"], s.UserAgents["bot"])
+	}
+}
+
+// exportNDJSON encodes requests one per line.
+func exportNDJSON(t *testing.T, reqs []RequestInfo) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, req := range reqs {
+		if err := enc.Encode(req); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return buf.Bytes()
+}
+
+func TestImport(t *testing.T) {
+	now := time.Now()
+	reqs := []RequestInfo{
+		{IP: "10.0.0.1", UserAgent: "bot", Path: "/a", Timestamp: now.Add(-time.Minute)},
+		{IP: "10.0.0.2", UserAgent: "curl", Path: "/b", Timestamp: now},
+	}
+	ndjson := exportNDJSON(t, reqs)
+
+	var gzipped bytes.Buffer
+	gz := gzip.NewWriter(&gzipped)
+	gz.Write(ndjson)
+	gz.Close()
+
+	// Another instance's database with the same requests
+	other := newTestDatabase(t)
+	seedRequests(t, other, reqs)
+	if _, err := other.db.Exec("PRAGMA wal_checkpoint(TRUNCATE)"); err != nil {
+		t.Fatal(err)
+	}
+	otherData, err := os.ReadFile(other.path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		data []byte
+		want ImportResult
+	}{
+		{"ndjson", ndjson, ImportResult{Imported: 2}},
+		{"ndjson with a torn line", append(append([]byte{}, ndjson...), `{"IP":"10.0.0.3","Us`...), ImportResult{Imported: 2, Invalid: 1}},
+		{"gzipped ndjson", gzipped.Bytes(), ImportResult{Imported: 2}},
+		{"sqlite", otherData, ImportResult{Imported: 2}},
+		{"not ndjson", []byte("hello\nworld\n"), ImportResult{Invalid: 2}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newTestDatabase(t)
+			result, err := db.Import(context.Background(), bytes.NewReader(tt.data), "trap-b")
+			if err != nil {
+				t.Fatalf("Import() error = %v", err)
+			}
+			if result != tt.want {
+				t.Errorf("Import() = %+v, want %+v", result, tt.want)
+			}
+
+			// No temporary copies are left next to the database
+			matches, _ := filepath.Glob(filepath.Join(filepath.Dir(db.path), "import-*"))
+			if len(matches) != 0 {
+				t.Errorf("temporary files left behind: %v", matches)
+			}
+		})
+	}
+}
+
+func TestImportFile(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+
+	other := newTestDatabase(t)
+	seedRequests(t, other, []RequestInfo{
+		{IP: "10.0.0.1", UserAgent: "bot", Path: "/a", Timestamp: now.Add(-time.Minute)},
+		{IP: "10.0.0.2", UserAgent: "curl", Path: "/b", Timestamp: now},
+	})
+
+	db := newTestDatabase(t)
+	result, err := db.ImportFile(ctx, other.path, "trap-b")
+	if err != nil {
+		t.Fatalf("ImportFile() error = %v", err)
+	}
+	if result.Imported != 2 {
+		t.Errorf("Imported = %d, want 2", result.Imported)
+	}
+
+	// An NDJSON export of the merged database overlaps the first import
+	export := filepath.Join(t.TempDir(), "export.ndjson")
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	if err := db.ExportRequests(ctx, time.Time{}, func(req RequestInfo) error { return enc.Encode(req) }); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(export, buf.Bytes(), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), `"Source":"trap-b"`) {
+		t.Errorf("export does not keep the source:\n%s", buf.String())
+	}
+
+	third := newTestDatabase(t)
+	if _, err := third.ImportFile(ctx, other.path, "trap-b"); err != nil {
+		t.Fatalf("ImportFile() error = %v", err)
+	}
+	result, err = third.ImportFile(ctx, export, "trap-a")
+	if err != nil {
+		t.Fatalf("ImportFile() error = %v", err)
+	}
+	if result.Duplicates != 2 || result.Imported != 0 {
+		t.Errorf("ImportFile() = %+v, want the 2 requests skipped as duplicates", result)
+	}
+
+	if _, err := db.ImportFile(ctx, db.path, "self"); err == nil {
+		t.Error("ImportFile() of the database itself succeeded")
+	}
+}
+
+func TestImportDatabaseRefusesOutdatedSchema(t *testing.T) {
+	other := newTestDatabase(t)
+	if _, err := other.db.Exec("DELETE FROM schema_migrations WHERE version > 1"); err != nil {
+		t.Fatal(err)
+	}
+
+	db := newTestDatabase(t)
+	_, err := db.ImportDatabase(context.Background(), other.path, "trap-b")
+	if !errors.Is(err, ErrSchemaOutdated) {
+		t.Errorf("ImportDatabase() error = %v, want ErrSchemaOutdated", err)
+	}
+}
@@ -2,104 +2,190 @@ package stats
 
 import (
 	"context"
+	"errors"
 	"log/slog"
 	"net/http"
 	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/rampantspark/gospidertrap/internal/geoip"
+	"github.com/rampantspark/gospidertrap/internal/tracing"
 )
 
 // Manager provides a unified interface for statistics tracking.
 //
-// It supports both database and file-based backends and handles
-// IP resolution, request recording, and statistics retrieval.
+// It records requests into a Store and handles IP resolution, record hooks,
+// live event publishing and asynchronous batched recording.
 type Manager struct {
-	db         *Database
-	stats      *Stats
+	store      Store
 	ipResolver *IPResolver
 	logger     *slog.Logger
+	hooks      []RecordHook
+	recorder   *Recorder
+	hub        *Hub
+	geo        *geoip.Resolver // Nil without GeoIP databases or when the store cannot use them
 }
 
 // NewManager creates a new stats manager.
 //
 // Parameters:
-//   - db: optional database instance (nil for file-based mode)
-//   - stats: optional in-memory stats (nil for database mode)
+//   - store: the storage backend
 //   - trustProxy: whether to trust proxy headers for IP resolution
 //   - logger: structured logger instance
 //
 // Returns a new Manager instance.
-func NewManager(db *Database, stats *Stats, trustProxy bool, logger *slog.Logger) *Manager {
+func NewManager(store Store, trustProxy bool, logger *slog.Logger) *Manager {
 	return &Manager{
-		db:         db,
-		stats:      stats,
+		store:      store,
 		ipResolver: NewIPResolver(trustProxy),
 		logger:     logger,
+		hub:        NewHub(),
+	}
+}
+
+// Store returns the manager's storage backend.
+func (m *Manager) Store() Store {
+	return m.store
+}
+
+// Hub returns the hub that every recorded request is published to.
+func (m *Manager) Hub() *Hub {
+	return m.hub
+}
+
+// SetGeoIP makes the store annotate IPs with their country and autonomous
+// system, and adds the country and autonomous system charts to GetChartData.
+//
+// SetGeoIP must be called before the manager starts recording requests.
+//
+// Parameters:
+//   - geo: the GeoIP databases
+//
+// Returns false if the store does not support GeoIP annotation.
+func (m *Manager) SetGeoIP(geo *geoip.Resolver) bool {
+	reporter, ok := m.store.(GeoReporter)
+	if !ok {
+		return false
 	}
+	reporter.SetGeoIP(geo)
+	m.geo = geo
+	return true
+}
+
+// GeoIP returns the GeoIP databases set with SetGeoIP, or nil.
+func (m *Manager) GeoIP() *geoip.Resolver {
+	return m.geo
+}
+
+// AddRecordHook registers a hook that is called after every successfully
+// recorded request.
+//
+// Hooks must not block. They run on the request goroutine, or on the
+// recorder's writer goroutine once StartRecorder has been called.
+// AddRecordHook must be called before the manager starts recording requests.
+//
+// Parameters:
+//   - hook: the function to call
+func (m *Manager) AddRecordHook(hook RecordHook) {
+	m.hooks = append(m.hooks, hook)
+}
+
+// StartRecorder switches recording to asynchronous batched writes.
+//
+// After this call RecordRequest queues requests instead of writing them
+// directly.
+//
+// Parameters:
+//   - config: queue and batching settings
+//
+// Returns the recorder. The caller must call Recorder.Stop on shutdown to
+// flush queued requests.
+func (m *Manager) StartRecorder(config RecorderConfig) *Recorder {
+	m.recorder = NewRecorder(m.store, config, m.runHooks, m.logger)
+	return m.recorder
 }
 
 // RecordRequest records a request in the statistics.
 //
-// Uses database if configured, otherwise falls back to in-memory stats.
+// Registered record hooks are called after the request is recorded.
+// With an asynchronous recorder, the request is queued and hooks run once
+// its batch is written; a request dropped because the queue is full is not
+// reported as an error.
 //
 // Parameters:
 //   - ctx: context for cancellation and timeout control
 //   - r: the HTTP request
 //
-// Returns an error if database recording fails (file mode never returns error).
+// Returns an error if recording fails.
 func (m *Manager) RecordRequest(ctx context.Context, r *http.Request) error {
+	return m.record(ctx, r, "")
+}
+
+// RecordBaitRequest records a request answered by a scanner bait, tagged
+// with the bait's name, as RecordRequest does for trap pages.
+//
+// Parameters:
+//   - ctx: context for cancellation and timeout control
+//   - r: the HTTP request
+//   - bait: name of the bait that answers the request
+//
+// Returns an error if recording fails.
+func (m *Manager) RecordBaitRequest(ctx context.Context, r *http.Request, bait string) error {
+	return m.record(ctx, r, bait)
+}
+
+// record records a request, tagged with the bait that answers it, if any.
+func (m *Manager) record(ctx context.Context, r *http.Request, bait string) (err error) {
+	ctx, span := tracing.Start(ctx, "stats.RecordRequest",
+		trace.WithAttributes(attribute.String("stats.backend", m.store.Backend())))
+	defer func() { tracing.End(span, err) }()
+
 	ip := m.ipResolver.GetClientIP(r)
 	userAgent := r.Header.Get("User-Agent")
 	if userAgent == "" {
 		userAgent = "Unknown"
 	}
-	path := r.URL.Path
-	now := time.Now()
 
+	// Oversized values are truncated so they fit the request_log
+	// constraints, which would otherwise reject the whole batch
 	reqInfo := RequestInfo{
-		IP:        ip,
-		UserAgent: userAgent,
-		Path:      path,
-		Timestamp: now,
+		IP:        truncate(ip, maxIPLength),
+		UserAgent: truncate(userAgent, maxUserAgentLength),
+		Path:      truncate(r.URL.Path, maxPathLength),
+		Timestamp: time.Now(),
+		Bait:      bait,
 	}
 
-	// Use database if configured
-	if m.db != nil {
-		if err := m.db.RecordRequest(ctx, reqInfo); err != nil {
-			m.logger.Warn("Failed to record request in database", "error", err)
+	if m.recorder != nil {
+		err := m.recorder.Enqueue(ctx, reqInfo)
+		switch {
+		case err == nil, errors.Is(err, ErrQueueFull):
+			span.SetAttributes(attribute.Bool("stats.queued", err == nil))
+			return nil
+		case !errors.Is(err, ErrRecorderStopped):
 			return err
 		}
-		return nil
+		// The recorder has been stopped during shutdown, so write directly
 	}
 
-	// Fall back to in-memory stats (file mode)
-	// Note: File persistence is handled separately by the caller
-	m.stats.Mu.Lock()
-	defer m.stats.Mu.Unlock()
-
-	m.stats.TotalRequests++
-
-	// Only track new IPs if we haven't reached the limit
-	if _, exists := m.stats.IPCounts[ip]; exists {
-		m.stats.IPCounts[ip]++
-	} else if len(m.stats.IPCounts) < maxTrackedIPs {
-		m.stats.IPCounts[ip] = 1
+	results, err := m.store.RecordRequests(ctx, []RequestInfo{reqInfo})
+	if err != nil {
+		m.logger.Warn("Failed to record request", "backend", m.store.Backend(), "error", err)
+		return err
 	}
+	m.runHooks(reqInfo, results[0])
+	return nil
+}
 
-	// Only track new user agents if we haven't reached the limit
-	if _, exists := m.stats.UserAgents[userAgent]; exists {
-		m.stats.UserAgents[userAgent]++
-	} else if len(m.stats.UserAgents) < maxTrackedUserAgents {
-		m.stats.UserAgents[userAgent] = 1
-	}
-
-	// Add to recent requests
-	m.stats.RecentRequests = append(m.stats.RecentRequests, reqInfo)
-
-	// Keep only the most recent requests
-	if len(m.stats.RecentRequests) > m.stats.MaxRecentRequests {
-		m.stats.RecentRequests = m.stats.RecentRequests[1:]
+// runHooks calls every registered record hook and publishes the request
+// to the hub.
+func (m *Manager) runHooks(reqInfo RequestInfo, result RecordResult) {
+	for _, hook := range m.hooks {
+		hook(reqInfo, result)
 	}
-
-	return nil
+	m.hub.Publish(reqInfo, result)
 }
 
 // GetChartData retrieves chart data for the admin UI.
@@ -113,106 +199,51 @@ func (m *Manager) RecordRequest(ctx context.Context, r *http.Request) error {
 func (m *Manager) GetChartData(ctx context.Context, topItemsCount, maxUserAgentLength int) ChartData {
 	var data ChartData
 
-	// Use database if configured
-	if m.db != nil {
-		topIPs, err := m.db.GetTopIPs(ctx, topItemsCount)
-		if err != nil {
-			m.logger.Warn("Failed to get top IPs from database", "error", err)
-			return data
-		}
-
-		topUAs, err := m.db.GetTopUserAgents(ctx, topItemsCount)
-		if err != nil {
-			m.logger.Warn("Failed to get top user agents from database", "error", err)
-			return data
-		}
-
-		// Convert to chart data (preserves order from database)
-		for _, entry := range topIPs {
-			data.TopIPs.Labels = append(data.TopIPs.Labels, entry.Label)
-			data.TopIPs.Data = append(data.TopIPs.Data, entry.Count)
-		}
-
-		for _, entry := range topUAs {
-			// Truncate long user agents for display
-			displayUA := entry.Label
-			if len(entry.Label) > maxUserAgentLength {
-				displayUA = entry.Label[:maxUserAgentLength-3] + "..."
-			}
-			data.TopUserAgents.Labels = append(data.TopUserAgents.Labels, displayUA)
-			data.TopUserAgents.Data = append(data.TopUserAgents.Data, entry.Count)
-		}
-
+	topIPs, err := m.store.GetTopIPs(ctx, topItemsCount)
+	if err != nil {
+		m.logger.Warn("Failed to get top IPs", "error", err)
 		return data
 	}
 
-	// Fall back to in-memory stats (file mode)
-	return m.getChartDataFromMemory(topItemsCount, maxUserAgentLength)
-}
-
-// getChartDataFromMemory retrieves chart data from in-memory stats.
-func (m *Manager) getChartDataFromMemory(topItemsCount, maxUserAgentLength int) ChartData {
-	m.stats.Mu.RLock()
-	defer m.stats.Mu.RUnlock()
-
-	var data ChartData
-
-	// Get top IPs
-	type countPair struct {
-		label string
-		count int
-	}
-
-	ipList := make([]countPair, 0, len(m.stats.IPCounts))
-	for ip, count := range m.stats.IPCounts {
-		ipList = append(ipList, countPair{label: ip, count: count})
-	}
-	// Sort by count descending
-	for i := 0; i < len(ipList); i++ {
-		for j := i + 1; j < len(ipList); j++ {
-			if ipList[j].count > ipList[i].count {
-				ipList[i], ipList[j] = ipList[j], ipList[i]
-			}
-		}
+	topUAs, err := m.store.GetTopUserAgents(ctx, topItemsCount)
+	if err != nil {
+		m.logger.Warn("Failed to get top user agents", "error", err)
+		return data
 	}
 
-	// Take top N
-	maxIPs := topItemsCount
-	if len(ipList) < maxIPs {
-		maxIPs = len(ipList)
-	}
-	for i := 0; i < maxIPs; i++ {
-		data.TopIPs.Labels = append(data.TopIPs.Labels, ipList[i].label)
-		data.TopIPs.Data = append(data.TopIPs.Data, ipList[i].count)
+	// Convert to chart data (preserves order from the store)
+	for _, entry := range topIPs {
+		data.TopIPs.Labels = append(data.TopIPs.Labels, entry.Label)
+		data.TopIPs.Data = append(data.TopIPs.Data, entry.Count)
 	}
 
-	// Get top user agents
-	uaList := make([]countPair, 0, len(m.stats.UserAgents))
-	for ua, count := range m.stats.UserAgents {
-		uaList = append(uaList, countPair{label: ua, count: count})
-	}
-	// Sort by count descending
-	for i := 0; i < len(uaList); i++ {
-		for j := i + 1; j < len(uaList); j++ {
-			if uaList[j].count > uaList[i].count {
-				uaList[i], uaList[j] = uaList[j], uaList[i]
-			}
+	if reporter, ok := m.store.(GeoReporter); ok && m.geo != nil {
+		countries, err := reporter.GetTopCountries(ctx, topItemsCount)
+		if err != nil {
+			m.logger.Warn("Failed to get top countries", "error", err)
+		}
+		for _, entry := range countries {
+			data.TopCountries.Labels = append(data.TopCountries.Labels, entry.Label)
+			data.TopCountries.Data = append(data.TopCountries.Data, entry.Count)
+		}
+		asns, err := reporter.GetTopASNs(ctx, topItemsCount)
+		if err != nil {
+			m.logger.Warn("Failed to get top autonomous systems", "error", err)
+		}
+		for _, entry := range asns {
+			data.TopASNs.Labels = append(data.TopASNs.Labels, entry.Label)
+			data.TopASNs.Data = append(data.TopASNs.Data, entry.Count)
 		}
 	}
 
-	// Take top N
-	maxUAs := topItemsCount
-	if len(uaList) < maxUAs {
-		maxUAs = len(uaList)
-	}
-	for i := 0; i < maxUAs; i++ {
-		ua := uaList[i].label
+	for _, entry := range topUAs {
 		// Truncate long user agents for display
-		if len(ua) > maxUserAgentLength {
-			ua = ua[:maxUserAgentLength-3] + "..."
+		displayUA := entry.Label
+		if len(entry.Label) > maxUserAgentLength {
+			displayUA = entry.Label[:maxUserAgentLength-3] + "..."
 		}
-		data.TopUserAgents.Labels = append(data.TopUserAgents.Labels, ua)
-		data.TopUserAgents.Data = append(data.TopUserAgents.Data, uaList[i].count)
+		data.TopUserAgents.Labels = append(data.TopUserAgents.Labels, displayUA)
+		data.TopUserAgents.Data = append(data.TopUserAgents.Data, entry.Count)
 	}
 
 	return data
@@ -229,20 +260,50 @@ func (m *Manager) getChartDataFromMemory(topItemsCount, maxUserAgentLength int)
 //   - uniqueIPs: number of unique IP addresses
 //   - uniqueUAs: number of unique user agents
 func (m *Manager) GetStats(ctx context.Context) (uptime time.Duration, totalRequests, uniqueIPs, uniqueUAs int) {
-	if m.db != nil {
-		dbStats, err := m.db.GetStats(ctx)
-		if err != nil {
-			m.logger.Warn("Failed to get stats from database", "error", err)
-			return 0, 0, 0, 0
-		}
-		return time.Since(dbStats.StartTime), dbStats.TotalRequests, len(dbStats.IPCounts), len(dbStats.UserAgents)
+	summary, err := m.store.GetSummary(ctx)
+	if err != nil {
+		m.logger.Warn("Failed to get stats", "error", err)
+		return 0, 0, 0, 0
 	}
+	return time.Since(summary.StartTime), summary.TotalRequests, summary.UniqueIPs, summary.UniqueUserAgents
+}
 
-	// Fall back to in-memory stats
-	m.stats.Mu.RLock()
-	defer m.stats.Mu.RUnlock()
+// GetUniqueCounts returns the number of distinct IP addresses and user
+// agents seen.
+//
+// This is cheap enough to call on every metrics scrape.
+//
+// Parameters:
+//   - ctx: context for cancellation and timeout control
+//
+// Returns the unique IP and user agent counts (zero if the query fails).
+func (m *Manager) GetUniqueCounts(ctx context.Context) (ips, userAgents int) {
+	summary, err := m.store.GetSummary(ctx)
+	if err != nil {
+		m.logger.Warn("Failed to get unique counts", "error", err)
+		return 0, 0
+	}
+	return summary.UniqueIPs, summary.UniqueUserAgents
+}
 
-	return time.Since(m.stats.StartTime), m.stats.TotalRequests, len(m.stats.IPCounts), len(m.stats.UserAgents)
+// GetStorageInfo reports the database size and the extent of the request log.
+//
+// Parameters:
+//   - ctx: context for cancellation and timeout control
+//
+// Returns the storage info, and false if the store cannot report its size
+// (file mode) or the query fails.
+func (m *Manager) GetStorageInfo(ctx context.Context) (StorageInfo, bool) {
+	reporter, ok := m.store.(StorageReporter)
+	if !ok {
+		return StorageInfo{}, false
+	}
+	info, err := reporter.GetStorageInfo(ctx)
+	if err != nil {
+		m.logger.Warn("Failed to get database storage info", "error", err)
+		return StorageInfo{}, false
+	}
+	return info, true
 }
 
 // GetRecentRequests retrieves recent request log entries.
@@ -253,38 +314,29 @@ func (m *Manager) GetStats(ctx context.Context) (uptime time.Duration, totalRequ
 //
 // Returns a slice of RequestInfo ordered by timestamp (most recent first).
 func (m *Manager) GetRecentRequests(ctx context.Context, limit int) []RequestInfo {
-	if m.db != nil {
-		// Database mode: already returns DESC order (most recent first)
-		requests, err := m.db.GetRecentRequests(ctx, limit)
-		if err != nil {
-			m.logger.Warn("Failed to get recent requests from database", "error", err)
-			return nil
-		}
-		return requests
-	}
-
-	// File mode: in-memory stats stored chronologically (oldest first)
-	// Need to reverse to match the "most recent first" contract
-	m.stats.Mu.RLock()
-	defer m.stats.Mu.RUnlock()
-
-	total := len(m.stats.RecentRequests)
-	if total == 0 {
+	requests, err := m.store.GetRecentRequests(ctx, limit)
+	if err != nil {
+		m.logger.Warn("Failed to get recent requests", "error", err)
 		return nil
 	}
+	return requests
+}
 
-	// Limit to requested amount
-	count := total
-	if limit > 0 && limit < total {
-		count = limit
-	}
-
-	// Create result in reverse order (most recent first)
-	result := make([]RequestInfo, count)
-	for i := 0; i < count; i++ {
-		result[i] = m.stats.RecentRequests[total-1-i]
+// GetIPActivity retrieves per-IP activity filtered by hit count and recency.
+//
+// Parameters:
+//   - ctx: context for cancellation and timeout control
+//   - minHits: minimum number of requests an IP must have made
+//   - since: only include IPs seen at or after this time (zero for no limit)
+//
+// Returns a slice of IPActivity ordered by last seen time (most recent first).
+func (m *Manager) GetIPActivity(ctx context.Context, minHits int, since time.Time) []IPActivity {
+	activity, err := m.store.GetIPActivity(ctx, minHits, since)
+	if err != nil {
+		m.logger.Warn("Failed to get IP activity", "error", err)
+		return nil
 	}
-	return result
+	return activity
 }
 
 // GetClientIP extracts the client IP from a request.
@@ -298,9 +350,3 @@ func (m *Manager) GetRecentRequests(ctx context.Context, limit int) []RequestInf
 func (m *Manager) GetClientIP(r *http.Request) string {
 	return m.ipResolver.GetClientIP(r)
 }
-
-// Memory limit constants (only used in file mode)
-const (
-	maxTrackedIPs        = 10000 // Maximum number of unique IP addresses to track
-	maxTrackedUserAgents = 1000  // Maximum number of unique user agents to track
-)
@@ -0,0 +1,204 @@
+package stats
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/rampantspark/gospidertrap/internal/geoip"
+)
+
+// Memory limit constants (only used in file mode)
+const (
+	maxTrackedIPs        = 10000 // Maximum number of unique IP addresses to track
+	maxTrackedUserAgents = 1000  // Maximum number of unique user agents to track
+)
+
+// MemoryStore is a Store backed by in-memory Stats.
+//
+// It is used on its own when persistence is disabled, and holds the counts
+// for FileStore in file mode.
+type MemoryStore struct {
+	stats  *Stats
+	geo    *geoip.Resolver            // Looks up IPs for the geo reports (nil without GeoIP databases)
+	bots   map[string]BotVerification // Bot verification results by IP
+	botsMu sync.RWMutex
+
+	honeytokens  map[string]Honeytoken // Issued honeytokens by value
+	submissions  []FormSubmission      // Latest form submissions, oldest first
+	submissionID int64                 // ID of the latest form submission
+	formsMu      sync.RWMutex
+
+	captures   []RequestCapture // Latest request captures, oldest first
+	captureID  int64            // ID of the latest request capture
+	capturesMu sync.RWMutex
+}
+
+// NewMemoryStore creates a store that records into stats.
+//
+// Parameters:
+//   - stats: the in-memory stats to update
+//
+// Returns a new MemoryStore.
+func NewMemoryStore(stats *Stats) *MemoryStore {
+	return &MemoryStore{
+		stats:       stats,
+		bots:        make(map[string]BotVerification),
+		honeytokens: make(map[string]Honeytoken),
+	}
+}
+
+// Backend returns "memory".
+func (s *MemoryStore) Backend() string {
+	return "memory"
+}
+
+// RecordRequests records a batch of requests. It never fails.
+func (s *MemoryStore) RecordRequests(ctx context.Context, reqs []RequestInfo) ([]RecordResult, error) {
+	s.stats.Mu.Lock()
+	defer s.stats.Mu.Unlock()
+
+	results := make([]RecordResult, len(reqs))
+	for i, req := range reqs {
+		results[i] = s.record(req)
+	}
+	return results, nil
+}
+
+// record records one request. The caller must hold the stats lock.
+func (s *MemoryStore) record(reqInfo RequestInfo) RecordResult {
+	var result RecordResult
+	ip := reqInfo.IP
+	userAgent := reqInfo.UserAgent
+
+	s.stats.TotalRequests++
+
+	// Only track new IPs if we haven't reached the limit
+	if _, exists := s.stats.IPCounts[ip]; exists {
+		s.stats.IPCounts[ip]++
+		s.stats.IPLastSeen[ip] = reqInfo.Timestamp
+		result.IPCount = s.stats.IPCounts[ip]
+	} else if len(s.stats.IPCounts) < maxTrackedIPs {
+		s.stats.IPCounts[ip] = 1
+		s.stats.IPLastSeen[ip] = reqInfo.Timestamp
+		result.IPCount = 1
+	}
+
+	// Only track new user agents if we haven't reached the limit
+	if _, exists := s.stats.UserAgents[userAgent]; exists {
+		s.stats.UserAgents[userAgent]++
+		result.UserAgentCount = s.stats.UserAgents[userAgent]
+	} else if len(s.stats.UserAgents) < maxTrackedUserAgents {
+		s.stats.UserAgents[userAgent] = 1
+		result.UserAgentCount = 1
+	}
+
+	// Add to recent requests
+	s.stats.RecentRequests = append(s.stats.RecentRequests, reqInfo)
+
+	// Keep only the most recent requests
+	if len(s.stats.RecentRequests) > s.stats.MaxRecentRequests {
+		s.stats.RecentRequests = s.stats.RecentRequests[1:]
+	}
+
+	return result
+}
+
+// GetSummary returns the overall request totals.
+func (s *MemoryStore) GetSummary(ctx context.Context) (Summary, error) {
+	s.stats.Mu.RLock()
+	defer s.stats.Mu.RUnlock()
+
+	return Summary{
+		StartTime:        s.stats.StartTime,
+		TotalRequests:    s.stats.TotalRequests,
+		UniqueIPs:        len(s.stats.IPCounts),
+		UniqueUserAgents: len(s.stats.UserAgents),
+	}, nil
+}
+
+// GetTopIPs returns up to limit IP addresses by request count, highest first.
+func (s *MemoryStore) GetTopIPs(ctx context.Context, limit int) ([]CountEntry, error) {
+	s.stats.Mu.RLock()
+	defer s.stats.Mu.RUnlock()
+	return topCounts(s.stats.IPCounts, limit), nil
+}
+
+// GetTopUserAgents returns up to limit user agents by request count, highest first.
+func (s *MemoryStore) GetTopUserAgents(ctx context.Context, limit int) ([]CountEntry, error) {
+	s.stats.Mu.RLock()
+	defer s.stats.Mu.RUnlock()
+	return topCounts(s.stats.UserAgents, limit), nil
+}
+
+// topCounts returns the limit highest counts, breaking ties by label.
+func topCounts(counts map[string]int, limit int) []CountEntry {
+	entries := make([]CountEntry, 0, len(counts))
+	for label, count := range counts {
+		entries = append(entries, CountEntry{Label: label, Count: count})
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Count != entries[j].Count {
+			return entries[i].Count > entries[j].Count
+		}
+		return entries[i].Label < entries[j].Label
+	})
+	if limit >= 0 && len(entries) > limit {
+		entries = entries[:limit]
+	}
+	return entries
+}
+
+// GetRecentRequests returns up to limit requests, most recent first.
+// A limit of zero or less returns every retained request.
+func (s *MemoryStore) GetRecentRequests(ctx context.Context, limit int) ([]RequestInfo, error) {
+	// Requests are stored chronologically (oldest first), so reverse them
+	s.stats.Mu.RLock()
+	defer s.stats.Mu.RUnlock()
+
+	total := len(s.stats.RecentRequests)
+	if total == 0 {
+		return nil, nil
+	}
+
+	// Limit to requested amount
+	count := total
+	if limit > 0 && limit < total {
+		count = limit
+	}
+
+	// Create result in reverse order (most recent first)
+	result := make([]RequestInfo, count)
+	for i := 0; i < count; i++ {
+		result[i] = s.stats.RecentRequests[total-1-i]
+	}
+	return result, nil
+}
+
+// GetIPActivity returns IPs with at least minHits requests that were seen
+// at or after since (zero for no limit), most recently seen first.
+//
+// First-seen times are not tracked in memory, so FirstSeen is always zero.
+func (s *MemoryStore) GetIPActivity(ctx context.Context, minHits int, since time.Time) ([]IPActivity, error) {
+	s.stats.Mu.RLock()
+	defer s.stats.Mu.RUnlock()
+
+	result := make([]IPActivity, 0, len(s.stats.IPCounts))
+	for ip, count := range s.stats.IPCounts {
+		lastSeen := s.stats.IPLastSeen[ip]
+		if count < minHits || (!since.IsZero() && lastSeen.Before(since)) {
+			continue
+		}
+		result = append(result, IPActivity{IP: ip, Count: count, LastSeen: lastSeen})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].LastSeen.After(result[j].LastSeen)
+	})
+	return result, nil
+}
+
+// Close does nothing; the caller owns the underlying Stats.
+func (s *MemoryStore) Close() error {
+	return nil
+}
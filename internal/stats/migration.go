@@ -140,7 +140,7 @@ func migrateRequestsNDJSON(ctx context.Context, db *Database, filename string, l
 			continue
 		}
 
-		if err := db.RecordRequest(ctx, req); err != nil {
+		if _, err := db.RecordRequest(ctx, req); err != nil {
 			logger.Warn("Failed to import request", "error", err)
 			continue
 		}
@@ -0,0 +1,35 @@
+-- Initial PostgreSQL schema.
+
+CREATE TABLE stats (
+    id INTEGER PRIMARY KEY CHECK (id = 1),
+    start_time TIMESTAMPTZ NOT NULL,
+    total_requests BIGINT NOT NULL DEFAULT 0,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE TABLE ip_counts (
+    ip TEXT PRIMARY KEY CHECK (length(ip) <= 45 AND length(ip) > 0),
+    count BIGINT NOT NULL DEFAULT 1 CHECK (count > 0),
+    first_seen TIMESTAMPTZ NOT NULL,
+    last_seen TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX idx_ip_count ON ip_counts (count DESC);
+
+CREATE TABLE user_agent_counts (
+    user_agent TEXT PRIMARY KEY CHECK (length(user_agent) <= 512 AND length(user_agent) > 0),
+    count BIGINT NOT NULL DEFAULT 1 CHECK (count > 0),
+    first_seen TIMESTAMPTZ NOT NULL,
+    last_seen TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX idx_ua_count ON user_agent_counts (count DESC);
+
+CREATE TABLE request_log (
+    id BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    ip TEXT NOT NULL CHECK (length(ip) <= 45 AND length(ip) > 0),
+    user_agent TEXT CHECK (user_agent IS NULL OR length(user_agent) <= 512),
+    path TEXT CHECK (path IS NULL OR length(path) <= 2048),
+    timestamp TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+CREATE INDEX idx_request_timestamp ON request_log (timestamp DESC);
+CREATE INDEX idx_request_ip ON request_log (ip);
@@ -0,0 +1,42 @@
+-- Initial schema.
+--
+-- Uses IF NOT EXISTS so that databases created before schema_migrations
+-- existed are adopted without changes.
+
+-- Stats table (replaces stats.json)
+CREATE TABLE IF NOT EXISTS stats (
+    id INTEGER PRIMARY KEY CHECK (id = 1),
+    start_time TIMESTAMP NOT NULL,
+    total_requests INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- IP tracking (replaces IPCounts map)
+CREATE TABLE IF NOT EXISTS ip_counts (
+    ip TEXT PRIMARY KEY CHECK(length(ip) <= 45 AND length(ip) > 0),
+    count INTEGER NOT NULL DEFAULT 1 CHECK(count > 0),
+    first_seen TIMESTAMP NOT NULL,
+    last_seen TIMESTAMP NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_ip_count ON ip_counts(count DESC);
+
+-- User agent tracking (replaces UserAgents map)
+CREATE TABLE IF NOT EXISTS user_agent_counts (
+    user_agent TEXT PRIMARY KEY CHECK(length(user_agent) <= 512 AND length(user_agent) > 0),
+    count INTEGER NOT NULL DEFAULT 1 CHECK(count > 0),
+    first_seen TIMESTAMP NOT NULL,
+    last_seen TIMESTAMP NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_ua_count ON user_agent_counts(count DESC);
+
+-- Request log (replaces requests.ndjson)
+CREATE TABLE IF NOT EXISTS request_log (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    ip TEXT NOT NULL CHECK(length(ip) <= 45 AND length(ip) > 0),
+    user_agent TEXT CHECK(user_agent IS NULL OR length(user_agent) <= 512),
+    path TEXT CHECK(path IS NULL OR length(path) <= 2048),
+    timestamp TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS idx_request_timestamp ON request_log(timestamp DESC);
+CREATE INDEX IF NOT EXISTS idx_request_ip ON request_log(ip);
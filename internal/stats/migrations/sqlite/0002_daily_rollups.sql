@@ -0,0 +1,21 @@
+-- Daily aggregates for retention pruning.
+--
+-- Uses IF NOT EXISTS because these tables were briefly part of the
+-- unversioned schema.
+
+CREATE TABLE IF NOT EXISTS daily_counts (
+    day TEXT PRIMARY KEY,
+    requests INTEGER NOT NULL CHECK(requests > 0)
+);
+CREATE TABLE IF NOT EXISTS daily_ip_counts (
+    day TEXT NOT NULL,
+    ip TEXT NOT NULL,
+    requests INTEGER NOT NULL CHECK(requests > 0),
+    PRIMARY KEY (day, ip)
+);
+CREATE TABLE IF NOT EXISTS daily_user_agent_counts (
+    day TEXT NOT NULL,
+    user_agent TEXT NOT NULL,
+    requests INTEGER NOT NULL CHECK(requests > 0),
+    PRIMARY KEY (day, user_agent)
+);
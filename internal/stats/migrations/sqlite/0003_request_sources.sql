@@ -0,0 +1,10 @@
+-- Source instance tagging and deduplication for imported requests.
+--
+-- Requests recorded locally have an empty source and no import key.
+-- Imported requests carry the name of the instance that recorded them and
+-- a key derived from their contents, so importing the same data twice
+-- adds it only once.
+
+ALTER TABLE request_log ADD COLUMN source TEXT NOT NULL DEFAULT '';
+ALTER TABLE request_log ADD COLUMN import_key TEXT;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_request_import_key ON request_log(import_key) WHERE import_key IS NOT NULL;
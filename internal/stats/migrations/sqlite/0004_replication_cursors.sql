@@ -0,0 +1,11 @@
+-- Replication progress towards collector nodes.
+--
+-- Each row holds the id of the last request_log row a collector has
+-- acknowledged. Retention does not prune rows past the lowest cursor, so
+-- requests recorded while a collector is unreachable stay buffered until
+-- they are delivered.
+
+CREATE TABLE IF NOT EXISTS replication_cursors (
+    target TEXT PRIMARY KEY,
+    last_id INTEGER NOT NULL
+);
@@ -0,0 +1,8 @@
+-- Country and autonomous system of each IP, from the offline GeoIP
+-- databases. NULL when no database was loaded or the IP is not in it.
+
+ALTER TABLE ip_counts ADD COLUMN country TEXT;
+ALTER TABLE ip_counts ADD COLUMN asn INTEGER;
+ALTER TABLE ip_counts ADD COLUMN as_org TEXT;
+CREATE INDEX IF NOT EXISTS idx_ip_country ON ip_counts(country) WHERE country IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_ip_asn ON ip_counts(asn) WHERE asn IS NOT NULL;
@@ -0,0 +1,12 @@
+-- Reverse DNS verification of clients claiming to be search engine
+-- crawlers. One row per IP, replaced when it is checked again after the
+-- verifier's TTL.
+
+CREATE TABLE IF NOT EXISTS bot_verifications (
+    ip TEXT PRIMARY KEY CHECK(length(ip) <= 45 AND length(ip) > 0),
+    bot TEXT NOT NULL,
+    status TEXT NOT NULL CHECK(status IN ('verified', 'spoofed', 'unknown')),
+    hostname TEXT NOT NULL DEFAULT '',
+    checked_at TIMESTAMP NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_bot_checked_at ON bot_verifications(checked_at DESC);
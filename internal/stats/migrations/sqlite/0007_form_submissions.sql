@@ -0,0 +1,26 @@
+-- Honeytoken credentials embedded in trap forms, and the form fields and
+-- bodies clients submit.
+
+CREATE TABLE IF NOT EXISTS honeytokens (
+    value TEXT PRIMARY KEY CHECK(length(value) > 0),
+    kind TEXT NOT NULL,
+    ip TEXT NOT NULL CHECK(length(ip) <= 45 AND length(ip) > 0),
+    form TEXT NOT NULL,
+    issued_at TIMESTAMP NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_honeytokens_issued_at ON honeytokens(issued_at);
+
+CREATE TABLE IF NOT EXISTS form_submissions (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    timestamp TIMESTAMP NOT NULL,
+    ip TEXT NOT NULL CHECK(length(ip) <= 45 AND length(ip) > 0),
+    user_agent TEXT NOT NULL,
+    method TEXT NOT NULL,
+    path TEXT NOT NULL,
+    fields TEXT NOT NULL DEFAULT '{}',
+    body TEXT NOT NULL DEFAULT '',
+    honeytoken TEXT NOT NULL DEFAULT '',
+    issued_to TEXT NOT NULL DEFAULT ''
+);
+CREATE INDEX IF NOT EXISTS idx_form_submissions_timestamp ON form_submissions(timestamp DESC);
+CREATE INDEX IF NOT EXISTS idx_form_submissions_honeytoken ON form_submissions(timestamp DESC) WHERE honeytoken != '';
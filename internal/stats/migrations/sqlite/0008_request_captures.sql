@@ -0,0 +1,18 @@
+-- Methods, query strings and redacted body samples of trap requests,
+-- recorded when request capture is enabled. The request log keeps only
+-- the path.
+
+CREATE TABLE IF NOT EXISTS request_captures (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    timestamp TIMESTAMP NOT NULL,
+    ip TEXT NOT NULL CHECK(length(ip) <= 45 AND length(ip) > 0),
+    user_agent TEXT NOT NULL,
+    method TEXT NOT NULL,
+    path TEXT NOT NULL,
+    query TEXT NOT NULL DEFAULT '',
+    content_type TEXT NOT NULL DEFAULT '',
+    body_size INTEGER NOT NULL DEFAULT 0,
+    body BLOB NOT NULL DEFAULT x'',
+    truncated INTEGER NOT NULL DEFAULT 0
+);
+CREATE INDEX IF NOT EXISTS idx_request_captures_timestamp ON request_captures(timestamp DESC);
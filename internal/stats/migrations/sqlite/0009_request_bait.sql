@@ -0,0 +1,5 @@
+-- Decoy tagging. Requests answered by a vulnerability scanner bait, such as
+-- a fake .env file, carry the bait's name; trap pages have an empty bait.
+
+ALTER TABLE request_log ADD COLUMN bait TEXT NOT NULL DEFAULT '';
+CREATE INDEX IF NOT EXISTS idx_request_bait ON request_log(bait) WHERE bait != '';
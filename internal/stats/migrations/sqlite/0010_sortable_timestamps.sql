@@ -0,0 +1,37 @@
+-- Sortable timestamps. The driver stores times as text, either RFC 3339 or
+-- Go's "2006-01-02 15:04:05.999999999 -0700 MST" layout, neither of which
+-- sorts or compares in time order. Each timestamp gets a companion column
+-- holding Unix time in milliseconds, so time ranges are filtered in SQL.
+--
+-- Existing rows are backfilled, to the nearest millisecond, by rewriting
+-- Go's layout as one SQLite's date functions accept: the zone abbreviation
+-- and any monotonic clock reading are dropped and the offset gains a colon.
+-- Values that still cannot be parsed become 0.
+
+ALTER TABLE request_log ADD COLUMN timestamp_ms INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE ip_counts ADD COLUMN last_seen_ms INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE user_agent_counts ADD COLUMN last_seen_ms INTEGER NOT NULL DEFAULT 0;
+
+UPDATE request_log SET timestamp_ms = COALESCE(CAST(round(unixepoch(
+    CASE WHEN instr(substr(timestamp, 12), ' ') = 0 THEN timestamp
+    ELSE substr(timestamp, 1, 10 + instr(substr(timestamp, 12), ' '))
+        || substr(timestamp, 12 + instr(substr(timestamp, 12), ' '), 3) || ':'
+        || substr(timestamp, 15 + instr(substr(timestamp, 12), ' '), 2)
+    END, 'subsec') * 1000) AS INTEGER), 0);
+
+UPDATE ip_counts SET last_seen_ms = COALESCE(CAST(round(unixepoch(
+    CASE WHEN instr(substr(last_seen, 12), ' ') = 0 THEN last_seen
+    ELSE substr(last_seen, 1, 10 + instr(substr(last_seen, 12), ' '))
+        || substr(last_seen, 12 + instr(substr(last_seen, 12), ' '), 3) || ':'
+        || substr(last_seen, 15 + instr(substr(last_seen, 12), ' '), 2)
+    END, 'subsec') * 1000) AS INTEGER), 0);
+
+UPDATE user_agent_counts SET last_seen_ms = COALESCE(CAST(round(unixepoch(
+    CASE WHEN instr(substr(last_seen, 12), ' ') = 0 THEN last_seen
+    ELSE substr(last_seen, 1, 10 + instr(substr(last_seen, 12), ' '))
+        || substr(last_seen, 12 + instr(substr(last_seen, 12), ' '), 3) || ':'
+        || substr(last_seen, 15 + instr(substr(last_seen, 12), ' '), 2)
+    END, 'subsec') * 1000) AS INTEGER), 0);
+
+CREATE INDEX IF NOT EXISTS idx_request_timestamp_ms ON request_log(timestamp_ms);
+CREATE INDEX IF NOT EXISTS idx_ip_last_seen_ms ON ip_counts(last_seen_ms);
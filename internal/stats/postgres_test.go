@@ -0,0 +1,181 @@
+package stats
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"log/slog"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+// passThroughConverter accepts array arguments, which the default sqlmock
+// converter rejects but pgx sends as PostgreSQL arrays.
+type passThroughConverter struct{}
+
+func (passThroughConverter) ConvertValue(v any) (driver.Value, error) {
+	if dv, err := driver.DefaultParameterConverter.ConvertValue(v); err == nil {
+		return dv, nil
+	}
+	return v, nil
+}
+
+// newMockPostgres returns a sqlmock database and its mock controller.
+func newMockPostgres(t *testing.T) (*PostgresStore, sqlmock.Sqlmock) {
+	t.Helper()
+	db, mock, err := sqlmock.New(sqlmock.ValueConverterOption(passThroughConverter{}))
+	if err != nil {
+		t.Fatalf("sqlmock.New() error = %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return &PostgresStore{db: db, logger: slog.New(slog.NewTextHandler(io.Discard, nil))}, mock
+}
+
+// expectMigration sets up the expectations for opening a database at the
+// given schema version.
+func expectMigration(mock sqlmock.Sqlmock, current int) {
+	mock.ExpectPing()
+	mock.ExpectExec(regexp.QuoteMeta("SELECT pg_advisory_lock($1)")).
+		WithArgs(postgresMigrationLock).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("CREATE TABLE IF NOT EXISTS schema_migrations").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT COALESCE(MAX(version), 0) FROM schema_migrations")).
+		WillReturnRows(sqlmock.NewRows([]string{"version"}).AddRow(current))
+}
+
+func TestNewPostgresStoreMigrates(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.MonitorPingsOption(true))
+	if err != nil {
+		t.Fatalf("sqlmock.New() error = %v", err)
+	}
+	defer db.Close()
+
+	expectMigration(mock, 0)
+	mock.ExpectBegin()
+	mock.ExpectExec("CREATE TABLE stats").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("INSERT INTO schema_migrations").
+		WithArgs(1, "0001_initial", sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	mock.ExpectExec(regexp.QuoteMeta("SELECT pg_advisory_unlock($1)")).
+		WithArgs(postgresMigrationLock).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("INSERT INTO stats").WillReturnResult(sqlmock.NewResult(0, 1))
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	if _, err := newPostgresStore(context.Background(), db, logger); err != nil {
+		t.Fatalf("newPostgresStore() error = %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestNewPostgresStoreRefusesNewerSchema(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.MonitorPingsOption(true))
+	if err != nil {
+		t.Fatalf("sqlmock.New() error = %v", err)
+	}
+	defer db.Close()
+
+	expectMigration(mock, 999)
+	mock.ExpectExec(regexp.QuoteMeta("SELECT pg_advisory_unlock($1)")).
+		WithArgs(postgresMigrationLock).WillReturnResult(sqlmock.NewResult(0, 0))
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	_, err = newPostgresStore(context.Background(), db, logger)
+	if !errors.Is(err, ErrSchemaTooNew) {
+		t.Fatalf("newPostgresStore() error = %v, want ErrSchemaTooNew", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestPostgresRecordRequests(t *testing.T) {
+	store, mock := newMockPostgres(t)
+
+	now := time.Now()
+	reqs := []RequestInfo{
+		{IP: "10.0.0.2", UserAgent: "bot", Path: "/a", Timestamp: now},
+		{IP: "10.0.0.1", UserAgent: "bot", Path: "/b", Timestamp: now.Add(time.Second)},
+		{IP: "10.0.0.2", UserAgent: "curl", Path: "/c", Timestamp: now.Add(2 * time.Second)},
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO request_log").
+		WithArgs(
+			[]string{"10.0.0.2", "10.0.0.1", "10.0.0.2"},
+			[]string{"bot", "bot", "curl"},
+			[]string{"/a", "/b", "/c"},
+			[]time.Time{reqs[0].Timestamp, reqs[1].Timestamp, reqs[2].Timestamp},
+		).
+		WillReturnResult(sqlmock.NewResult(0, 3))
+	// 10.0.0.2 had 5 requests before this batch, 10.0.0.1 is new
+	mock.ExpectQuery("INSERT INTO ip_counts").
+		WithArgs(
+			[]string{"10.0.0.1", "10.0.0.2"},
+			[]int64{1, 2},
+			[]time.Time{reqs[1].Timestamp, reqs[0].Timestamp},
+			[]time.Time{reqs[1].Timestamp, reqs[2].Timestamp},
+		).
+		WillReturnRows(sqlmock.NewRows([]string{"ip", "count"}).AddRow("10.0.0.1", 1).AddRow("10.0.0.2", 7))
+	mock.ExpectQuery("INSERT INTO user_agent_counts").
+		WillReturnRows(sqlmock.NewRows([]string{"user_agent", "count"}).AddRow("bot", 2).AddRow("curl", 10))
+	mock.ExpectExec("UPDATE stats").WithArgs(3, sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	results, err := store.RecordRequests(context.Background(), reqs)
+	if err != nil {
+		t.Fatalf("RecordRequests() error = %v", err)
+	}
+
+	want := []RecordResult{
+		{IPCount: 6, UserAgentCount: 1},
+		{IPCount: 1, UserAgentCount: 2},
+		{IPCount: 7, UserAgentCount: 10},
+	}
+	for i := range want {
+		if results[i] != want[i] {
+			t.Errorf("results[%d] = %+v, want %+v", i, results[i], want[i])
+		}
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestPostgresRecordRequestsRollsBack(t *testing.T) {
+	store, mock := newMockPostgres(t)
+
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO request_log").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("INSERT INTO ip_counts").WillReturnError(errors.New("connection reset"))
+	mock.ExpectRollback()
+
+	reqs := []RequestInfo{{IP: "10.0.0.1", UserAgent: "bot", Path: "/", Timestamp: time.Now()}}
+	if _, err := store.RecordRequests(context.Background(), reqs); err == nil {
+		t.Fatal("RecordRequests() error = nil, want error")
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestPostgresGetSummary(t *testing.T) {
+	store, mock := newMockPostgres(t)
+
+	start := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	mock.ExpectQuery("SELECT start_time, total_requests").
+		WillReturnRows(sqlmock.NewRows([]string{"start_time", "total_requests", "ips", "uas"}).AddRow(start, 42, 7, 3))
+
+	summary, err := store.GetSummary(context.Background())
+	if err != nil {
+		t.Fatalf("GetSummary() error = %v", err)
+	}
+	want := Summary{StartTime: start, TotalRequests: 42, UniqueIPs: 7, UniqueUserAgents: 3}
+	if summary != want {
+		t.Errorf("GetSummary() = %+v, want %+v", summary, want)
+	}
+}
@@ -0,0 +1,359 @@
+package stats
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rampantspark/gospidertrap/internal/geoip"
+)
+
+// ErrNotFound is returned when a queried IP address or user agent has no
+// recorded requests.
+var ErrNotFound = errors.New("not found")
+
+// ErrSchemaOutdated is returned when a database opened read-only has
+// pending migrations, which cannot be applied without write access.
+var ErrSchemaOutdated = errors.New("database schema is older than this version of gospidertrap")
+
+// OpenDatabaseReadOnly opens an existing database for queries only.
+//
+// The database is never written or migrated, so it is safe to open while
+// the server is running; WAL mode lets readers proceed alongside the
+// server's writes.
+//
+// Parameters:
+//   - dbPath: path to the SQLite database file
+//   - logger: structured logger instance
+//
+// Returns the database, or an error if it does not exist or its schema
+// version differs from this binary's. The error wraps ErrSchemaTooNew or
+// ErrSchemaOutdated on a version mismatch.
+func OpenDatabaseReadOnly(dbPath string, logger *slog.Logger) (*Database, error) {
+	absPath, err := filepath.Abs(dbPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve database path: %w", err)
+	}
+	if _, err := os.Stat(absPath); err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+
+	dsn := url.URL{
+		Scheme:   "file",
+		Path:     filepath.ToSlash(absPath),
+		RawQuery: "mode=ro&_pragma=busy_timeout(5000)",
+	}
+	db, err := sql.Open("sqlite", dsn.String())
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+
+	migrations, err := loadMigrations(migrationFiles, sqliteDialect.migrationsDir)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	latest := migrations[len(migrations)-1].version
+
+	// A database created before versioning has no schema_migrations table
+	var current int
+	err = db.QueryRow("SELECT COALESCE(MAX(version), 0) FROM schema_migrations").Scan(&current)
+	if err != nil && !isNoSuchTable(err) {
+		db.Close()
+		return nil, fmt.Errorf("failed to read schema version: %w", err)
+	}
+	switch {
+	case current > latest:
+		db.Close()
+		return nil, fmt.Errorf("%w: database is at version %d, latest known is %d", ErrSchemaTooNew, current, latest)
+	case current < latest:
+		db.Close()
+		return nil, fmt.Errorf("%w: database is at version %d, expected %d (start the server once to migrate it)", ErrSchemaOutdated, current, latest)
+	}
+
+	return &Database{db: db, path: dbPath, logger: logger}, nil
+}
+
+// isNoSuchTable reports whether err is SQLite's missing table error.
+func isNoSuchTable(err error) bool {
+	return strings.Contains(err.Error(), "no such table")
+}
+
+// scanRequestsSince calls fn for every request_log row at or after since,
+// newest first. A zero since visits every row. The caller must hold d.mu.
+func (d *Database) scanRequestsSince(ctx context.Context, since time.Time, fn func(RequestInfo)) error {
+	rows, err := d.db.QueryContext(ctx, `
+		SELECT ip, COALESCE(user_agent, ''), COALESCE(path, ''), timestamp, source, bait
+		FROM request_log
+		WHERE timestamp_ms >= ?
+		ORDER BY timestamp_ms DESC, id DESC
+	`, since.UnixMilli())
+	if err != nil {
+		return fmt.Errorf("failed to query request log: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var req RequestInfo
+		if err := rows.Scan(&req.IP, &req.UserAgent, &req.Path, &req.Timestamp, &req.Source, &req.Bait); err != nil {
+			return fmt.Errorf("failed to scan request: %w", err)
+		}
+		if req.Timestamp.Before(since) {
+			continue // Within the millisecond since falls in
+		}
+		fn(req)
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error iterating request log: %w", err)
+	}
+	return nil
+}
+
+// GetTopIPsSince counts the requests in the request log at or after since
+// and returns the top IP addresses.
+//
+// Unlike GetTopIPs this only sees requests still in the request log, not
+// ones removed by retention pruning.
+//
+// Parameters:
+//   - ctx: context for cancellation and timeout control
+//   - since: start of the window
+//   - limit: maximum number of IPs to retrieve
+//
+// Returns a slice of CountEntry ordered by count (highest first).
+func (d *Database) GetTopIPsSince(ctx context.Context, since time.Time, limit int) ([]CountEntry, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	counts := make(map[string]int)
+	err := d.scanRequestsSince(ctx, since, func(req RequestInfo) {
+		counts[req.IP]++
+	})
+	if err != nil {
+		return nil, err
+	}
+	return topCounts(counts, limit), nil
+}
+
+// IPDetail describes everything recorded about one IP address.
+type IPDetail struct {
+	IP         string           `json:"ip"`
+	Count      int              `json:"count"`         // All-time request count
+	FirstSeen  time.Time        `json:"firstSeen"`     // Time of the first request
+	LastSeen   time.Time        `json:"lastSeen"`      // Time of the most recent request
+	Geo        geoip.Info       `json:"geo"`           // Country and autonomous system, where known
+	Bot        *BotVerification `json:"bot,omitempty"` // Search engine crawler verification, nil if the IP has not claimed to be one
+	Logged     int              `json:"logged"`        // Requests still in the request log
+	UserAgents []CountEntry     `json:"userAgents"`    // User agents in the request log, most used first
+	Paths      []CountEntry     `json:"paths"`         // Paths in the request log, most requested first
+	Timeline   Timeline         `json:"timeline"`      // Requests in the request log over time
+	Sessions   []Session        `json:"sessions"`      // Sessions in the request log, most recent first
+	Recent     []RequestInfo    `json:"recent"`        // Most recent requests, newest first
+}
+
+// GetIPDetail retrieves the counts and logged requests for one IP address.
+// Sessions are split at DefaultSessionGap.
+//
+// Parameters:
+//   - ctx: context for cancellation and timeout control
+//   - ip: the IP address
+//   - limit: maximum number of user agents, paths, sessions and recent requests to return
+//
+// Returns the detail, or an error wrapping ErrNotFound if the IP has never
+// been seen.
+func (d *Database) GetIPDetail(ctx context.Context, ip string, limit int) (IPDetail, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	detail := IPDetail{IP: ip}
+	var country, org, bot, botStatus, botHostname sql.NullString
+	var asn sql.NullInt64
+	var botCheckedAt sql.NullTime
+	err := d.db.QueryRowContext(ctx, `
+		SELECT c.count, c.first_seen, c.last_seen, c.country, c.asn, c.as_org,
+			b.bot, b.status, b.hostname, b.checked_at
+		FROM ip_counts c
+		LEFT JOIN bot_verifications b ON b.ip = c.ip
+		WHERE c.ip = ?
+	`, ip).Scan(&detail.Count, &detail.FirstSeen, &detail.LastSeen, &country, &asn, &org,
+		&bot, &botStatus, &botHostname, &botCheckedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return IPDetail{}, fmt.Errorf("IP %s: %w", ip, ErrNotFound)
+	}
+	if err != nil {
+		return IPDetail{}, fmt.Errorf("failed to query IP: %w", err)
+	}
+	detail.Geo = geoip.Info{Country: country.String, ASN: uint(asn.Int64), Org: org.String}
+	if bot.Valid {
+		detail.Bot = &BotVerification{IP: ip, Bot: bot.String, Status: botStatus.String, Hostname: botHostname.String, CheckedAt: botCheckedAt.Time}
+	}
+
+	rows, err := d.db.QueryContext(ctx, `
+		SELECT COALESCE(user_agent, ''), COALESCE(path, ''), timestamp
+		FROM request_log
+		WHERE ip = ?
+		ORDER BY id DESC
+	`, ip)
+	if err != nil {
+		return IPDetail{}, fmt.Errorf("failed to query IP requests: %w", err)
+	}
+	defer rows.Close()
+
+	var reqs []RequestInfo
+	for rows.Next() {
+		req := RequestInfo{IP: ip}
+		if err := rows.Scan(&req.UserAgent, &req.Path, &req.Timestamp); err != nil {
+			return IPDetail{}, fmt.Errorf("failed to scan request: %w", err)
+		}
+		reqs = append(reqs, req)
+	}
+	if err := rows.Err(); err != nil {
+		return IPDetail{}, fmt.Errorf("error iterating IP requests: %w", err)
+	}
+
+	detail.fill(reqs, limit)
+	return detail, nil
+}
+
+// Session is a run of requests from one IP address with no gap longer than
+// the session timeout.
+type Session struct {
+	IP         string    `json:"ip"`
+	Start      time.Time `json:"start"`
+	End        time.Time `json:"end"`
+	Requests   int       `json:"requests"`
+	Paths      int       `json:"paths"`      // Number of distinct paths requested
+	UserAgents []string  `json:"userAgents"` // Distinct user agents, in order of first use
+}
+
+// Duration returns the time between the session's first and last request.
+func (s Session) Duration() time.Duration {
+	return s.End.Sub(s.Start)
+}
+
+// GetSessions groups the request log into per-IP sessions.
+//
+// Parameters:
+//   - ctx: context for cancellation and timeout control
+//   - since: only include requests at or after this time (zero for no limit)
+//   - gap: idle time after which an IP's next request starts a new session
+//
+// Returns the sessions ordered by start time (most recent first).
+func (d *Database) GetSessions(ctx context.Context, since time.Time, gap time.Duration) ([]Session, error) {
+	d.mu.RLock()
+	byIP := make(map[string][]RequestInfo)
+	err := d.scanRequestsSince(ctx, since, func(req RequestInfo) {
+		byIP[req.IP] = append(byIP[req.IP], req)
+	})
+	d.mu.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+
+	var sessions []Session
+	for ip, reqs := range byIP {
+		sort.Slice(reqs, func(i, j int) bool {
+			return reqs[i].Timestamp.Before(reqs[j].Timestamp)
+		})
+		sessions = append(sessions, groupSessions(ip, reqs, gap)...)
+	}
+
+	sort.Slice(sessions, func(i, j int) bool {
+		if !sessions[i].Start.Equal(sessions[j].Start) {
+			return sessions[i].Start.After(sessions[j].Start)
+		}
+		return sessions[i].IP < sessions[j].IP
+	})
+	return sessions, nil
+}
+
+// groupSessions splits one IP address's requests into sessions.
+//
+// Parameters:
+//   - ip: the IP address the requests came from
+//   - reqs: the requests, oldest first
+//   - gap: idle time after which the next request starts a new session
+//
+// Returns the sessions, oldest first.
+func groupSessions(ip string, reqs []RequestInfo, gap time.Duration) []Session {
+	var sessions []Session
+	var paths map[string]bool
+	for _, req := range reqs {
+		if len(sessions) == 0 || req.Timestamp.Sub(sessions[len(sessions)-1].End) > gap {
+			sessions = append(sessions, Session{IP: ip, Start: req.Timestamp})
+			paths = make(map[string]bool)
+		}
+		current := &sessions[len(sessions)-1]
+		current.End = req.Timestamp
+		current.Requests++
+		if !paths[req.Path] {
+			paths[req.Path] = true
+			current.Paths++
+		}
+		if !containsString(current.UserAgents, req.UserAgent) {
+			current.UserAgents = append(current.UserAgents, req.UserAgent)
+		}
+	}
+	return sessions
+}
+
+// containsString reports whether list contains s.
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// ExportRequests calls fn for every request in the request log at or after
+// since (zero for no limit), in the order they were recorded or imported.
+// Imported requests keep their source.
+//
+// Parameters:
+//   - ctx: context for cancellation and timeout control
+//   - since: start of the window
+//   - fn: called for each request; returning an error stops the export
+//
+// Returns the first error from the query or from fn.
+func (d *Database) ExportRequests(ctx context.Context, since time.Time, fn func(RequestInfo) error) error {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	rows, err := d.db.QueryContext(ctx, `
+		SELECT ip, COALESCE(user_agent, ''), COALESCE(path, ''), timestamp, source, bait
+		FROM request_log
+		WHERE timestamp_ms >= ?
+		ORDER BY id
+	`, since.UnixMilli())
+	if err != nil {
+		return fmt.Errorf("failed to query request log: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var req RequestInfo
+		if err := rows.Scan(&req.IP, &req.UserAgent, &req.Path, &req.Timestamp, &req.Source, &req.Bait); err != nil {
+			return fmt.Errorf("failed to scan request: %w", err)
+		}
+		if req.Timestamp.Before(since) {
+			continue
+		}
+		if err := fn(req); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error iterating request log: %w", err)
+	}
+	return nil
+}
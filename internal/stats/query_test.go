@@ -0,0 +1,176 @@
+package stats
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+func TestOpenDatabaseReadOnly(t *testing.T) {
+	db := newTestDatabase(t)
+	ctx := context.Background()
+	now := time.Now()
+	if _, err := db.RecordRequests(ctx, []RequestInfo{{IP: "10.0.0.1", UserAgent: "bot", Path: "/", Timestamp: now}}); err != nil {
+		t.Fatalf("RecordRequests() error = %v", err)
+	}
+
+	// The writer stays open, as it would with the server running
+	ro, err := OpenDatabaseReadOnly(db.path, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err != nil {
+		t.Fatalf("OpenDatabaseReadOnly() error = %v", err)
+	}
+	defer ro.Close()
+
+	summary, err := ro.GetSummary(ctx)
+	if err != nil {
+		t.Fatalf("GetSummary() error = %v", err)
+	}
+	if summary.TotalRequests != 1 {
+		t.Errorf("TotalRequests = %d, want 1", summary.TotalRequests)
+	}
+	if _, err := ro.RecordRequests(ctx, []RequestInfo{{IP: "10.0.0.2", UserAgent: "bot", Path: "/", Timestamp: now}}); err == nil {
+		t.Error("RecordRequests() on a read-only database succeeded")
+	}
+
+	if _, err := OpenDatabaseReadOnly(db.path+".missing", nil); err == nil {
+		t.Error("OpenDatabaseReadOnly() of a missing file succeeded")
+	}
+}
+
+func TestOpenDatabaseReadOnlyRefusesOutdatedSchema(t *testing.T) {
+	db := newTestDatabase(t)
+	if _, err := db.db.Exec("DELETE FROM schema_migrations WHERE version > 1"); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := OpenDatabaseReadOnly(db.path, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if !errors.Is(err, ErrSchemaOutdated) {
+		t.Errorf("OpenDatabaseReadOnly() error = %v, want ErrSchemaOutdated", err)
+	}
+}
+
+// seedRequests records requests in one batch.
+func seedRequests(t *testing.T, db *Database, reqs []RequestInfo) {
+	t.Helper()
+	if _, err := db.RecordRequests(context.Background(), reqs); err != nil {
+		t.Fatalf("RecordRequests() error = %v", err)
+	}
+}
+
+func TestGetTopIPsSince(t *testing.T) {
+	db := newTestDatabase(t)
+	now := time.Now()
+	at := func(ip string, ago time.Duration) RequestInfo {
+		return RequestInfo{IP: ip, UserAgent: "bot", Path: "/", Timestamp: now.Add(-ago)}
+	}
+	seedRequests(t, db, []RequestInfo{
+		at("10.0.0.1", 48*time.Hour), at("10.0.0.1", 47*time.Hour), at("10.0.0.1", 46*time.Hour),
+		at("10.0.0.2", 2*time.Hour), at("10.0.0.2", time.Hour),
+		at("10.0.0.3", time.Minute),
+	})
+
+	tests := []struct {
+		name  string
+		since time.Time
+		want  []CountEntry
+	}{
+		{"all", time.Time{}, []CountEntry{{"10.0.0.1", 3}, {"10.0.0.2", 2}, {"10.0.0.3", 1}}},
+		{"last day", now.Add(-24 * time.Hour), []CountEntry{{"10.0.0.2", 2}, {"10.0.0.3", 1}}},
+		{"last 90 minutes", now.Add(-90 * time.Minute), []CountEntry{{"10.0.0.2", 1}, {"10.0.0.3", 1}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := db.GetTopIPsSince(context.Background(), tt.since, 10)
+			if err != nil {
+				t.Fatalf("GetTopIPsSince() error = %v", err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("GetTopIPsSince() = %v, want %v", got, tt.want)
+			}
+			for i := range tt.want {
+				if got[i] != tt.want[i] {
+					t.Errorf("entry %d = %v, want %v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestGetIPDetail(t *testing.T) {
+	db := newTestDatabase(t)
+	ctx := context.Background()
+	now := time.Now()
+	seedRequests(t, db, []RequestInfo{
+		{IP: "10.0.0.1", UserAgent: "bot", Path: "/a", Timestamp: now.Add(-3 * time.Minute)},
+		{IP: "10.0.0.1", UserAgent: "bot", Path: "/b", Timestamp: now.Add(-2 * time.Minute)},
+		{IP: "10.0.0.1", UserAgent: "curl", Path: "/a", Timestamp: now.Add(-time.Minute)},
+		{IP: "10.0.0.2", UserAgent: "bot", Path: "/a", Timestamp: now},
+	})
+
+	detail, err := db.GetIPDetail(ctx, "10.0.0.1", 2)
+	if err != nil {
+		t.Fatalf("GetIPDetail() error = %v", err)
+	}
+	if detail.Count != 3 || detail.Logged != 3 {
+		t.Errorf("Count, Logged = %d, %d, want 3, 3", detail.Count, detail.Logged)
+	}
+	if len(detail.Recent) != 2 || detail.Recent[0].UserAgent != "curl" {
+		t.Errorf("Recent = %v, want the 2 newest requests, newest first", detail.Recent)
+	}
+	if len(detail.Paths) != 2 || detail.Paths[0] != (CountEntry{"/a", 2}) {
+		t.Errorf("Paths = %v, want /a first with 2 requests", detail.Paths)
+	}
+	if len(detail.UserAgents) != 2 || detail.UserAgents[0] != (CountEntry{"bot", 2}) {
+		t.Errorf("UserAgents = %v, want bot first with 2 requests", detail.UserAgents)
+	}
+
+	if _, err := db.GetIPDetail(ctx, "10.9.9.9", 2); !errors.Is(err, ErrNotFound) {
+		t.Errorf("GetIPDetail() of an unknown IP error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestGetSessions(t *testing.T) {
+	db := newTestDatabase(t)
+	start := time.Now().Add(-3 * time.Hour)
+	at := func(ip, path string, offset time.Duration) RequestInfo {
+		return RequestInfo{IP: ip, UserAgent: "bot", Path: path, Timestamp: start.Add(offset)}
+	}
+	seedRequests(t, db, []RequestInfo{
+		// Two sessions from 10.0.0.1 separated by an hour of silence
+		at("10.0.0.1", "/a", 0),
+		at("10.0.0.1", "/b", 5*time.Minute),
+		at("10.0.0.1", "/a", 10*time.Minute),
+		at("10.0.0.1", "/c", 70*time.Minute),
+		// One session from 10.0.0.2
+		at("10.0.0.2", "/a", 30*time.Minute),
+	})
+
+	sessions, err := db.GetSessions(context.Background(), time.Time{}, 30*time.Minute)
+	if err != nil {
+		t.Fatalf("GetSessions() error = %v", err)
+	}
+
+	want := []struct {
+		ip       string
+		requests int
+		paths    int
+		duration time.Duration
+	}{
+		{"10.0.0.1", 1, 1, 0},
+		{"10.0.0.2", 1, 1, 0},
+		{"10.0.0.1", 3, 2, 10 * time.Minute},
+	}
+	if len(sessions) != len(want) {
+		t.Fatalf("got %d sessions, want %d: %+v", len(sessions), len(want), sessions)
+	}
+	for i, w := range want {
+		s := sessions[i]
+		if s.IP != w.ip || s.Requests != w.requests || s.Paths != w.paths || s.Duration() != w.duration {
+			t.Errorf("session %d = %s %d requests %d paths %s, want %s %d requests %d paths %s",
+				i, s.IP, s.Requests, s.Paths, s.Duration(), w.ip, w.requests, w.paths, w.duration)
+		}
+	}
+}
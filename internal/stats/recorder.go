@@ -0,0 +1,273 @@
+package stats
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/rampantspark/gospidertrap/internal/metrics"
+)
+
+// OverflowPolicy controls what Recorder.Enqueue does when the queue is full.
+type OverflowPolicy string
+
+// Supported overflow policies.
+const (
+	OverflowBlock OverflowPolicy = "block" // Wait for space in the queue
+	OverflowDrop  OverflowPolicy = "drop"  // Discard the request and count it as dropped
+)
+
+// ParseOverflowPolicy parses an overflow policy name.
+//
+// Returns the policy, or an error if the name is not block or drop.
+func ParseOverflowPolicy(name string) (OverflowPolicy, error) {
+	switch policy := OverflowPolicy(name); policy {
+	case OverflowBlock, OverflowDrop:
+		return policy, nil
+	default:
+		return "", fmt.Errorf("unknown overflow policy: %s (expected block or drop)", name)
+	}
+}
+
+// Recorder errors.
+var (
+	ErrQueueFull       = errors.New("stats queue full")
+	ErrRecorderStopped = errors.New("stats recorder stopped")
+)
+
+// Default recorder settings.
+const (
+	DefaultQueueSize     = 4096
+	DefaultBatchSize     = 256
+	DefaultFlushInterval = 500 * time.Millisecond
+)
+
+// batchTimeout bounds how long a single batch write may take.
+const batchTimeout = 30 * time.Second
+
+// RecorderConfig holds settings for asynchronous request recording.
+type RecorderConfig struct {
+	QueueSize     int            // Maximum number of queued requests
+	BatchSize     int            // Maximum number of requests written per transaction
+	FlushInterval time.Duration  // Maximum time a queued request waits before being written
+	Overflow      OverflowPolicy // What to do when the queue is full
+}
+
+// withDefaults returns the config with zero values replaced by defaults.
+func (c RecorderConfig) withDefaults() RecorderConfig {
+	if c.QueueSize <= 0 {
+		c.QueueSize = DefaultQueueSize
+	}
+	if c.BatchSize <= 0 {
+		c.BatchSize = DefaultBatchSize
+	}
+	if c.FlushInterval <= 0 {
+		c.FlushInterval = DefaultFlushInterval
+	}
+	if c.Overflow == "" {
+		c.Overflow = OverflowBlock
+	}
+	return c
+}
+
+// Recorder writes requests to a Store in batches from a background goroutine.
+//
+// Requests are queued by Enqueue and written when a full batch has
+// accumulated or the flush interval elapses, whichever comes first.
+type Recorder struct {
+	store    Store
+	config   RecorderConfig
+	onWrite  RecordHook
+	logger   *slog.Logger
+	queue    chan RequestInfo
+	mu       sync.RWMutex // Held for reading while enqueuing, for writing while stopping
+	stopped  bool
+	stopChan chan struct{}
+	done     chan struct{}
+
+	droppedMu    sync.Mutex
+	dropped      int64
+	droppedSince int64 // Drops since the last warning was logged
+	droppedTotal *metrics.Counter
+}
+
+// NewRecorder creates a recorder and starts its writer goroutine.
+//
+// Parameters:
+//   - store: the store to write to
+//   - config: queue and batching settings (zero values use defaults)
+//   - onWrite: optional function called for each request after its batch is committed
+//   - logger: structured logger instance
+//
+// Returns a new Recorder. Call Stop to flush queued requests and stop the writer.
+func NewRecorder(store Store, config RecorderConfig, onWrite RecordHook, logger *slog.Logger) *Recorder {
+	config = config.withDefaults()
+	r := &Recorder{
+		store:    store,
+		config:   config,
+		onWrite:  onWrite,
+		logger:   logger,
+		queue:    make(chan RequestInfo, config.QueueSize),
+		stopChan: make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	go r.run()
+	return r
+}
+
+// Instrument sets the counter incremented for every dropped request.
+func (r *Recorder) Instrument(dropped *metrics.Counter) {
+	r.droppedTotal = dropped
+}
+
+// Enqueue queues a request for writing.
+//
+// With the block policy, Enqueue waits for space in the queue until ctx is
+// done. With the drop policy, a request that does not fit is discarded.
+//
+// Parameters:
+//   - ctx: context bounding the wait for queue space
+//   - req: the request to record
+//
+// Returns ErrQueueFull if the request was dropped, ErrRecorderStopped if the
+// recorder has been stopped, or the context error if ctx ended while waiting.
+func (r *Recorder) Enqueue(ctx context.Context, req RequestInfo) error {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if r.stopped {
+		return ErrRecorderStopped
+	}
+
+	if r.config.Overflow == OverflowDrop {
+		select {
+		case r.queue <- req:
+			return nil
+		default:
+			r.drop()
+			return ErrQueueFull
+		}
+	}
+
+	select {
+	case r.queue <- req:
+		return nil
+	case <-ctx.Done():
+		r.drop()
+		return ctx.Err()
+	}
+}
+
+// drop counts a request that could not be queued.
+func (r *Recorder) drop() {
+	r.droppedMu.Lock()
+	r.dropped++
+	r.droppedSince++
+	r.droppedMu.Unlock()
+	r.droppedTotal.Inc()
+}
+
+// Dropped returns the number of requests dropped since the recorder started,
+// including those in batches that failed to write.
+func (r *Recorder) Dropped() int64 {
+	r.droppedMu.Lock()
+	defer r.droppedMu.Unlock()
+	return r.dropped
+}
+
+// QueueLength returns the number of requests waiting to be written.
+func (r *Recorder) QueueLength() int {
+	return len(r.queue)
+}
+
+// Stop stops accepting requests, writes everything still queued, and waits
+// for the writer goroutine to exit. It is safe to call more than once.
+func (r *Recorder) Stop() {
+	r.mu.Lock()
+	alreadyStopped := r.stopped
+	r.stopped = true
+	r.mu.Unlock()
+
+	if !alreadyStopped {
+		close(r.stopChan)
+	}
+	<-r.done
+}
+
+// run is the writer goroutine.
+func (r *Recorder) run() {
+	defer close(r.done)
+
+	ticker := time.NewTicker(r.config.FlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]RequestInfo, 0, r.config.BatchSize)
+	for {
+		select {
+		case req := <-r.queue:
+			batch = append(batch, req)
+			if len(batch) >= r.config.BatchSize {
+				batch = r.flush(batch)
+			}
+		case <-ticker.C:
+			batch = r.flush(batch)
+		case <-r.stopChan:
+			// No more enqueues can start, so drain what is left
+			for {
+				select {
+				case req := <-r.queue:
+					batch = append(batch, req)
+					if len(batch) >= r.config.BatchSize {
+						batch = r.flush(batch)
+					}
+				default:
+					r.flush(batch)
+					return
+				}
+			}
+		}
+	}
+}
+
+// flush writes a batch and returns the emptied slice for reuse.
+func (r *Recorder) flush(batch []RequestInfo) []RequestInfo {
+	r.logDropped()
+	if len(batch) == 0 {
+		return batch
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), batchTimeout)
+	results, err := r.store.RecordRequests(ctx, batch)
+	cancel()
+	if err != nil {
+		r.logger.Warn("Failed to record request batch", "backend", r.store.Backend(), "requests", len(batch), "error", err)
+		// The failure is logged above, so only the counts are updated
+		r.droppedMu.Lock()
+		r.dropped += int64(len(batch))
+		r.droppedMu.Unlock()
+		r.droppedTotal.Add(uint64(len(batch)))
+		return batch[:0]
+	}
+
+	if r.onWrite != nil {
+		for i, req := range batch {
+			r.onWrite(req, results[i])
+		}
+	}
+	return batch[:0]
+}
+
+// logDropped logs a warning if requests were dropped since the last check,
+// so a flood of drops produces at most one line per flush.
+func (r *Recorder) logDropped() {
+	r.droppedMu.Lock()
+	since := r.droppedSince
+	r.droppedSince = 0
+	r.droppedMu.Unlock()
+
+	if since > 0 {
+		r.logger.Warn("Stats queue full, dropped requests", "dropped", since, "queue_size", r.config.QueueSize)
+	}
+}
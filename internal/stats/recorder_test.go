@@ -0,0 +1,189 @@
+package stats
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+func newTestDatabase(t *testing.T) *Database {
+	t.Helper()
+	db, err := NewDatabase(filepath.Join(t.TempDir(), "stats.db"), slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err != nil {
+		t.Fatalf("NewDatabase() error = %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestRecordRequestsBatch(t *testing.T) {
+	db := newTestDatabase(t)
+	now := time.Now()
+	reqs := []RequestInfo{
+		{IP: "10.0.0.1", UserAgent: "a", Path: "/1", Timestamp: now},
+		{IP: "10.0.0.2", UserAgent: "a", Path: "/2", Timestamp: now},
+		{IP: "10.0.0.1", UserAgent: "b", Path: "/3", Timestamp: now},
+	}
+
+	results, err := db.RecordRequests(context.Background(), reqs)
+	if err != nil {
+		t.Fatalf("RecordRequests() error = %v", err)
+	}
+	want := []RecordResult{{1, 1}, {1, 2}, {2, 1}}
+	for i, got := range results {
+		if got != want[i] {
+			t.Errorf("result[%d] = %+v, want %+v", i, got, want[i])
+		}
+	}
+
+	s, err := db.GetStats(context.Background())
+	if err != nil {
+		t.Fatalf("GetStats() error = %v", err)
+	}
+	if s.TotalRequests != 3 || len(s.IPCounts) != 2 || len(s.UserAgents) != 2 {
+		t.Errorf("GetStats() = %d total, %d IPs, %d UAs, want 3, 2, 2", s.TotalRequests, len(s.IPCounts), len(s.UserAgents))
+	}
+}
+
+func TestRecorder(t *testing.T) {
+	tests := []struct {
+		name       string
+		config     RecorderConfig
+		requests   int
+		beforeStop int // Requests written by the size or time trigger alone
+	}{
+		{"size trigger", RecorderConfig{BatchSize: 5, FlushInterval: time.Hour}, 12, 10},
+		{"time trigger", RecorderConfig{BatchSize: 100, FlushInterval: 10 * time.Millisecond}, 3, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newTestDatabase(t)
+			var mu sync.Mutex
+			var written []RequestInfo
+			r := NewRecorder(db, tt.config, func(req RequestInfo, result RecordResult) {
+				mu.Lock()
+				written = append(written, req)
+				mu.Unlock()
+			}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+			for i := 0; i < tt.requests; i++ {
+				if err := r.Enqueue(context.Background(), RequestInfo{IP: "10.0.0.1", UserAgent: "a", Timestamp: time.Now()}); err != nil {
+					t.Fatalf("Enqueue() error = %v", err)
+				}
+			}
+
+			// Whole batches (or the ticker) write without waiting for Stop
+			deadline := time.Now().Add(5 * time.Second)
+			for {
+				mu.Lock()
+				n := len(written)
+				mu.Unlock()
+				if n >= tt.beforeStop {
+					break
+				}
+				if time.Now().After(deadline) {
+					t.Fatalf("only %d requests written before Stop", n)
+				}
+				time.Sleep(5 * time.Millisecond)
+			}
+
+			r.Stop()
+			if len(written) != tt.requests {
+				t.Errorf("written %d requests after Stop, want %d", len(written), tt.requests)
+			}
+			if err := r.Enqueue(context.Background(), RequestInfo{}); !errors.Is(err, ErrRecorderStopped) {
+				t.Errorf("Enqueue() after Stop error = %v, want ErrRecorderStopped", err)
+			}
+		})
+	}
+}
+
+func TestRecorderOverflow(t *testing.T) {
+	db := newTestDatabase(t)
+
+	// Hold the database lock so the writer cannot drain the queue
+	db.mu.Lock()
+	r := NewRecorder(db, RecorderConfig{QueueSize: 2, BatchSize: 1, FlushInterval: time.Hour, Overflow: OverflowDrop},
+		nil, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	var dropped int
+	for i := 0; i < 10; i++ {
+		err := r.Enqueue(context.Background(), RequestInfo{IP: "10.0.0.1", UserAgent: "a", Timestamp: time.Now()})
+		if errors.Is(err, ErrQueueFull) {
+			dropped++
+		} else if err != nil {
+			t.Fatalf("Enqueue() error = %v", err)
+		}
+	}
+	if dropped == 0 || int64(dropped) != r.Dropped() {
+		t.Errorf("dropped %d requests, Dropped() = %d, want equal and non-zero", dropped, r.Dropped())
+	}
+
+	// With the block policy, a full queue waits until the context ends
+	blocking := NewRecorder(db, RecorderConfig{QueueSize: 1, BatchSize: 1, FlushInterval: time.Hour},
+		nil, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	var err error
+	for i := 0; i < 3 && err == nil; i++ {
+		err = blocking.Enqueue(ctx, RequestInfo{IP: "10.0.0.1", UserAgent: "a", Timestamp: time.Now()})
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("blocking Enqueue() error = %v, want context.DeadlineExceeded", err)
+	}
+
+	db.mu.Unlock()
+	r.Stop()
+	blocking.Stop()
+}
+
+func TestRecorderOversizedRequest(t *testing.T) {
+	db := newTestDatabase(t)
+	m := NewManager(db, false, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	r := m.StartRecorder(RecorderConfig{BatchSize: 10, FlushInterval: time.Hour})
+
+	// An oversized user agent or path must not fail the batch it shares
+	oversized := httptest.NewRequest(http.MethodGet, "/"+strings.Repeat("p", 3000), nil)
+	oversized.Header.Set("User-Agent", strings.Repeat("é", 600))
+	for _, req := range []*http.Request{httptest.NewRequest(http.MethodGet, "/ok", nil), oversized} {
+		if err := m.RecordRequest(context.Background(), req); err != nil {
+			t.Fatalf("RecordRequest() error = %v", err)
+		}
+	}
+	r.Stop()
+
+	s, err := db.GetStats(context.Background())
+	if err != nil {
+		t.Fatalf("GetStats() error = %v", err)
+	}
+	if s.TotalRequests != 2 || r.Dropped() != 0 {
+		t.Errorf("recorded %d requests, dropped %d, want 2 and 0", s.TotalRequests, r.Dropped())
+	}
+	for ua := range s.UserAgents {
+		if len(ua) > maxUserAgentLength || !utf8.ValidString(ua) {
+			t.Errorf("stored user agent of %d bytes, valid UTF-8 %v", len(ua), utf8.ValidString(ua))
+		}
+	}
+
+	// A batch the store rejects is counted as dropped
+	failing := NewRecorder(db, RecorderConfig{BatchSize: 10, FlushInterval: time.Hour},
+		nil, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	for _, ip := range []string{"10.0.0.1", ""} {
+		if err := failing.Enqueue(context.Background(), RequestInfo{IP: ip, UserAgent: "a", Timestamp: time.Now()}); err != nil {
+			t.Fatalf("Enqueue() error = %v", err)
+		}
+	}
+	failing.Stop()
+	if failing.Dropped() != 2 {
+		t.Errorf("Dropped() = %d after a failed batch, want 2", failing.Dropped())
+	}
+}
@@ -0,0 +1,136 @@
+package stats
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// RequestsAfter returns up to limit request_log rows recorded or imported
+// after the row with the given id, in id order.
+//
+// Parameters:
+//   - ctx: context for cancellation and timeout control
+//   - afterID: id of the last row already read (0 to start at the beginning)
+//   - limit: maximum number of rows to return
+//
+// Returns the requests and the id of the last one returned, which is
+// afterID if there are none, or an error if the query fails.
+func (d *Database) RequestsAfter(ctx context.Context, afterID int64, limit int) ([]RequestInfo, int64, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	rows, err := d.db.QueryContext(ctx, `
+		SELECT id, ip, COALESCE(user_agent, ''), COALESCE(path, ''), timestamp, source, bait
+		FROM request_log
+		WHERE id > ?
+		ORDER BY id
+		LIMIT ?
+	`, afterID, limit)
+	if err != nil {
+		return nil, afterID, fmt.Errorf("failed to query request log: %w", err)
+	}
+	defer rows.Close()
+
+	lastID := afterID
+	var reqs []RequestInfo
+	for rows.Next() {
+		var req RequestInfo
+		if err := rows.Scan(&lastID, &req.IP, &req.UserAgent, &req.Path, &req.Timestamp, &req.Source, &req.Bait); err != nil {
+			return nil, afterID, fmt.Errorf("failed to scan request: %w", err)
+		}
+		reqs = append(reqs, req)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, afterID, fmt.Errorf("error iterating request log: %w", err)
+	}
+	return reqs, lastID, nil
+}
+
+// CountRequestsAfter returns the number of request_log rows after the row
+// with the given id.
+//
+// Parameters:
+//   - ctx: context for cancellation and timeout control
+//   - afterID: id of the last row already read
+//
+// Returns the count, or an error if the query fails.
+func (d *Database) CountRequestsAfter(ctx context.Context, afterID int64) (int, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	var count int
+	err := d.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM request_log WHERE id > ?", afterID).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count request log: %w", err)
+	}
+	return count, nil
+}
+
+// ReplicationCursor returns the id of the last request_log row delivered
+// to a replication target.
+//
+// Parameters:
+//   - ctx: context for cancellation and timeout control
+//   - target: the replication target, such as the collector URL
+//
+// Returns the id, 0 if nothing has been delivered yet, or an error if the
+// query fails.
+func (d *Database) ReplicationCursor(ctx context.Context, target string) (int64, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	var lastID int64
+	err := d.db.QueryRowContext(ctx, "SELECT last_id FROM replication_cursors WHERE target = ?", target).Scan(&lastID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to get replication cursor: %w", err)
+	}
+	return lastID, nil
+}
+
+// SetReplicationCursor records that request_log rows up to and including
+// lastID have been delivered to a replication target. Retention does not
+// prune rows past the lowest cursor.
+//
+// Parameters:
+//   - ctx: context for cancellation and timeout control
+//   - target: the replication target, such as the collector URL
+//   - lastID: id of the last delivered row
+//
+// Returns an error if the update fails.
+func (d *Database) SetReplicationCursor(ctx context.Context, target string, lastID int64) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	_, err := d.db.ExecContext(ctx, `
+		INSERT INTO replication_cursors (target, last_id) VALUES (?, ?)
+		ON CONFLICT(target) DO UPDATE SET last_id = excluded.last_id
+	`, target, lastID)
+	if err != nil {
+		return fmt.Errorf("failed to set replication cursor: %w", err)
+	}
+	return nil
+}
+
+// ClearReplicationCursors removes the cursors of all replication targets
+// except keep, so that targets no longer replicated to stop holding back
+// retention.
+//
+// Parameters:
+//   - ctx: context for cancellation and timeout control
+//   - keep: the target whose cursor is kept (empty to remove all)
+//
+// Returns an error if the delete fails.
+func (d *Database) ClearReplicationCursors(ctx context.Context, keep string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if _, err := d.db.ExecContext(ctx, "DELETE FROM replication_cursors WHERE target != ?", keep); err != nil {
+		return fmt.Errorf("failed to clear replication cursors: %w", err)
+	}
+	return nil
+}
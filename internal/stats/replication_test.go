@@ -0,0 +1,73 @@
+package stats
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestRequestsAfter(t *testing.T) {
+	db := newTestDatabase(t)
+	ctx := context.Background()
+	now := time.Now()
+	seedRequests(t, db, []RequestInfo{
+		{IP: "10.0.0.1", UserAgent: "bot", Path: "/1", Timestamp: now},
+		{IP: "10.0.0.1", UserAgent: "bot", Path: "/2", Timestamp: now},
+		{IP: "10.0.0.1", UserAgent: "bot", Path: "/3", Timestamp: now},
+	})
+	if _, err := db.ImportRequests(ctx, "trap-b", []RequestInfo{
+		{IP: "10.0.0.2", UserAgent: "bot", Path: "/4", Timestamp: now},
+	}); err != nil {
+		t.Fatalf("ImportRequests() error = %v", err)
+	}
+
+	var paths []string
+	var cursor int64
+	for {
+		reqs, lastID, err := db.RequestsAfter(ctx, cursor, 3)
+		if err != nil {
+			t.Fatalf("RequestsAfter() error = %v", err)
+		}
+		for _, req := range reqs {
+			paths = append(paths, req.Source+req.Path)
+		}
+		if len(reqs) == 0 {
+			if lastID != cursor {
+				t.Errorf("RequestsAfter() past the end returned id %d, want %d", lastID, cursor)
+			}
+			break
+		}
+		cursor = lastID
+	}
+	if got, want := len(paths), 4; got != want {
+		t.Fatalf("read %d requests, want %d: %v", got, want, paths)
+	}
+	if paths[0] != "/1" || paths[3] != "trap-b/4" {
+		t.Errorf("requests = %v, want /1 first and the imported trap-b/4 last", paths)
+	}
+
+	count, err := db.CountRequestsAfter(ctx, 1)
+	if err != nil {
+		t.Fatalf("CountRequestsAfter() error = %v", err)
+	}
+	if count != 3 {
+		t.Errorf("CountRequestsAfter(1) = %d, want 3", count)
+	}
+}
+
+func TestReplicationCursor(t *testing.T) {
+	db := newTestDatabase(t)
+	ctx := context.Background()
+
+	if cursor, err := db.ReplicationCursor(ctx, "https://collector"); err != nil || cursor != 0 {
+		t.Fatalf("ReplicationCursor() = %d, %v, want 0 before any push", cursor, err)
+	}
+	for _, id := range []int64{5, 9} {
+		if err := db.SetReplicationCursor(ctx, "https://collector", id); err != nil {
+			t.Fatalf("SetReplicationCursor() error = %v", err)
+		}
+		if cursor, _ := db.ReplicationCursor(ctx, "https://collector"); cursor != id {
+			t.Errorf("ReplicationCursor() = %d, want %d", cursor, id)
+		}
+	}
+}
@@ -0,0 +1,412 @@
+package stats
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"math"
+	"os"
+	"time"
+)
+
+// rollupDayFormat is the UTC date format used for the day column of the
+// daily rollup tables.
+const rollupDayFormat = "2006-01-02"
+
+// DefaultPruneBatchSize is the number of request_log rows deleted per transaction.
+const DefaultPruneBatchSize = 1000
+
+// RetentionPolicy limits how much of the request log is kept.
+//
+// A row is pruned if it is older than MaxAge or if more than MaxRows newer
+// rows exist. A zero MaxAge or MaxRows disables that limit.
+type RetentionPolicy struct {
+	MaxAge    time.Duration // Maximum age of request_log rows (0 for no limit)
+	MaxRows   int           // Maximum number of request_log rows (0 for no limit)
+	BatchSize int           // Rows deleted per transaction (0 uses DefaultPruneBatchSize)
+}
+
+// Enabled reports whether the policy limits the request log at all.
+func (p RetentionPolicy) Enabled() bool {
+	return p.MaxAge > 0 || p.MaxRows > 0
+}
+
+// StorageInfo describes the size and contents of the database.
+type StorageInfo struct {
+	SizeBytes      int64     // Database file size, including the WAL file
+	RequestLogRows int       // Rows currently in request_log
+	OldestRecord   time.Time // Timestamp of the oldest request_log row (zero if empty)
+	RolledUp       int       // Requests pruned from request_log and kept only as daily counts
+}
+
+// logRow is a request_log row considered for pruning.
+type logRow struct {
+	id        int64
+	ip        string
+	userAgent string
+	timestamp time.Time
+	ms        int64 // timestamp_ms, the order rows are pruned in
+}
+
+// PruneRequestLog deletes request_log rows outside the retention policy.
+//
+// Rows are deleted oldest first, in batches of policy.BatchSize, each in
+// its own transaction so request writes can proceed between batches.
+// Before deletion, each batch is added to the daily_counts,
+// daily_ip_counts and daily_user_agent_counts tables. The all-time totals
+// in stats, ip_counts and user_agent_counts are not affected.
+//
+// Parameters:
+//   - ctx: context for cancellation and timeout control
+//   - policy: the retention policy to apply
+//   - now: the current time, used for the age limit
+//
+// Returns the number of rows deleted, or an error if a batch fails. Rows
+// deleted by earlier batches stay deleted.
+func (d *Database) PruneRequestLog(ctx context.Context, policy RetentionPolicy, now time.Time) (int, error) {
+	if !policy.Enabled() {
+		return 0, nil
+	}
+	batchSize := policy.BatchSize
+	if batchSize <= 0 {
+		batchSize = DefaultPruneBatchSize
+	}
+
+	var cutoff time.Time
+	if policy.MaxAge > 0 {
+		cutoff = now.Add(-policy.MaxAge)
+	}
+
+	pruned := 0
+	for {
+		if err := ctx.Err(); err != nil {
+			return pruned, err
+		}
+		n, err := d.pruneBatch(ctx, policy.MaxRows, cutoff, batchSize)
+		pruned += n
+		if err != nil {
+			return pruned, err
+		}
+		if n < batchSize {
+			return pruned, nil
+		}
+	}
+}
+
+// pruneBatch rolls up and deletes up to batchSize of the oldest rows that
+// are over maxRows or older than cutoff. Rows not yet delivered to every
+// replication target are kept.
+//
+// Returns the number of rows deleted.
+func (d *Database) pruneBatch(ctx context.Context, maxRows int, cutoff time.Time, batchSize int) (int, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	tx, err := d.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Rows beyond maxRows are pruned regardless of age
+	excess := 0
+	if maxRows > 0 {
+		var total int
+		if err := tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM request_log").Scan(&total); err != nil {
+			return 0, fmt.Errorf("failed to count request log: %w", err)
+		}
+		excess = total - maxRows
+	}
+
+	// Rows after the lowest replication cursor are still waiting to be sent
+	hold := int64(math.MaxInt64)
+	var minCursor sql.NullInt64
+	if err := tx.QueryRowContext(ctx, "SELECT MIN(last_id) FROM replication_cursors").Scan(&minCursor); err != nil {
+		return 0, fmt.Errorf("failed to get replication cursors: %w", err)
+	}
+	if minCursor.Valid {
+		hold = minCursor.Int64
+	}
+
+	rows, err := tx.QueryContext(ctx, `
+		SELECT id, ip, COALESCE(user_agent, ''), timestamp, timestamp_ms
+		FROM request_log
+		WHERE id <= ?
+		ORDER BY timestamp_ms, id
+		LIMIT ?
+	`, hold, batchSize)
+	if err != nil {
+		return 0, fmt.Errorf("failed to query request log: %w", err)
+	}
+	var batch []logRow
+	for rows.Next() {
+		var row logRow
+		if err := rows.Scan(&row.id, &row.ip, &row.userAgent, &row.timestamp, &row.ms); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan request log row: %w", err)
+		}
+		if len(batch) >= excess && (cutoff.IsZero() || !row.timestamp.Before(cutoff)) {
+			break
+		}
+		batch = append(batch, row)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("error iterating request log: %w", err)
+	}
+	if len(batch) == 0 {
+		return 0, nil
+	}
+
+	if err := rollup(ctx, tx, batch); err != nil {
+		return 0, err
+	}
+
+	last := batch[len(batch)-1]
+	if _, err := tx.ExecContext(ctx, `
+		DELETE FROM request_log
+		WHERE id <= ? AND (timestamp_ms < ? OR (timestamp_ms = ? AND id <= ?))
+	`, hold, last.ms, last.ms, last.id); err != nil {
+		return 0, fmt.Errorf("failed to delete request log rows: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return len(batch), nil
+}
+
+// rollup adds request_log rows to the daily aggregate tables.
+func rollup(ctx context.Context, tx *sql.Tx, batch []logRow) error {
+	type dayKey struct{ day, value string }
+	days := make(map[string]int)
+	ips := make(map[dayKey]int)
+	userAgents := make(map[dayKey]int)
+	for _, row := range batch {
+		day := row.timestamp.UTC().Format(rollupDayFormat)
+		days[day]++
+		ips[dayKey{day, row.ip}]++
+		if row.userAgent != "" {
+			userAgents[dayKey{day, row.userAgent}]++
+		}
+	}
+
+	for day, n := range days {
+		if _, err := tx.ExecContext(ctx, `
+			INSERT INTO daily_counts (day, requests) VALUES (?, ?)
+			ON CONFLICT(day) DO UPDATE SET requests = requests + excluded.requests
+		`, day, n); err != nil {
+			return fmt.Errorf("failed to roll up daily counts: %w", err)
+		}
+	}
+	for key, n := range ips {
+		if _, err := tx.ExecContext(ctx, `
+			INSERT INTO daily_ip_counts (day, ip, requests) VALUES (?, ?, ?)
+			ON CONFLICT(day, ip) DO UPDATE SET requests = requests + excluded.requests
+		`, key.day, key.value, n); err != nil {
+			return fmt.Errorf("failed to roll up daily IP counts: %w", err)
+		}
+	}
+	for key, n := range userAgents {
+		if _, err := tx.ExecContext(ctx, `
+			INSERT INTO daily_user_agent_counts (day, user_agent, requests) VALUES (?, ?, ?)
+			ON CONFLICT(day, user_agent) DO UPDATE SET requests = requests + excluded.requests
+		`, key.day, key.value, n); err != nil {
+			return fmt.Errorf("failed to roll up daily user agent counts: %w", err)
+		}
+	}
+	return nil
+}
+
+// Checkpoint copies the write-ahead log into the database file and truncates it.
+//
+// Parameters:
+//   - ctx: context for cancellation and timeout control
+//
+// Returns an error if the checkpoint fails.
+func (d *Database) Checkpoint(ctx context.Context) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if _, err := d.db.ExecContext(ctx, "PRAGMA wal_checkpoint(TRUNCATE)"); err != nil {
+		return fmt.Errorf("failed to checkpoint WAL: %w", err)
+	}
+	return nil
+}
+
+// Vacuum rebuilds the database file to release space freed by pruning.
+//
+// Writes are blocked while the vacuum runs.
+//
+// Parameters:
+//   - ctx: context for cancellation and timeout control
+//
+// Returns an error if the vacuum fails.
+func (d *Database) Vacuum(ctx context.Context) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if _, err := d.db.ExecContext(ctx, "VACUUM"); err != nil {
+		return fmt.Errorf("failed to vacuum database: %w", err)
+	}
+	return nil
+}
+
+// GetStorageInfo reports the database size and the extent of the request log.
+//
+// Parameters:
+//   - ctx: context for cancellation and timeout control
+//
+// Returns the storage info or an error if a query fails.
+func (d *Database) GetStorageInfo(ctx context.Context) (StorageInfo, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	var info StorageInfo
+	var pageCount, pageSize int64
+	if err := d.db.QueryRowContext(ctx, "PRAGMA page_count").Scan(&pageCount); err != nil {
+		return info, fmt.Errorf("failed to read page count: %w", err)
+	}
+	if err := d.db.QueryRowContext(ctx, "PRAGMA page_size").Scan(&pageSize); err != nil {
+		return info, fmt.Errorf("failed to read page size: %w", err)
+	}
+	info.SizeBytes = pageCount * pageSize
+	if fi, err := os.Stat(d.path + "-wal"); err == nil {
+		info.SizeBytes += fi.Size()
+	}
+
+	err := d.db.QueryRowContext(ctx, `
+		SELECT (SELECT COUNT(*) FROM request_log), (SELECT COALESCE(SUM(requests), 0) FROM daily_counts)
+	`).Scan(&info.RequestLogRows, &info.RolledUp)
+	if err != nil {
+		return info, fmt.Errorf("failed to count request log: %w", err)
+	}
+
+	if info.RequestLogRows > 0 {
+		err := d.db.QueryRowContext(ctx, "SELECT timestamp FROM request_log ORDER BY timestamp_ms, id LIMIT 1").Scan(&info.OldestRecord)
+		if err != nil {
+			return info, fmt.Errorf("failed to read oldest request: %w", err)
+		}
+	}
+	return info, nil
+}
+
+// PrunerConfig holds settings for background database maintenance.
+type PrunerConfig struct {
+	Policy         RetentionPolicy // Request log retention
+	Interval       time.Duration   // How often to prune and checkpoint (0 disables)
+	VacuumInterval time.Duration   // How often to vacuum (0 disables)
+}
+
+// Pruner periodically applies a retention policy, checkpoints the WAL and
+// vacuums the database.
+type Pruner struct {
+	db         *Database
+	config     PrunerConfig
+	logger     *slog.Logger
+	lastVacuum time.Time
+	stopChan   chan struct{}
+	done       chan struct{}
+}
+
+// NewPruner creates a pruner. Call Start to begin periodic maintenance.
+//
+// Parameters:
+//   - db: the database to maintain
+//   - config: retention and maintenance intervals
+//   - logger: structured logger instance
+//
+// Returns a new Pruner.
+func NewPruner(db *Database, config PrunerConfig, logger *slog.Logger) *Pruner {
+	return &Pruner{
+		db:         db,
+		config:     config,
+		logger:     logger,
+		lastVacuum: time.Now(),
+		stopChan:   make(chan struct{}),
+	}
+}
+
+// Start runs maintenance immediately and then every config.Interval.
+// It does nothing if the interval is not positive.
+func (p *Pruner) Start() {
+	if p.config.Interval <= 0 {
+		return
+	}
+
+	p.done = make(chan struct{})
+	go func() {
+		defer close(p.done)
+
+		ticker := time.NewTicker(p.config.Interval)
+		defer ticker.Stop()
+
+		for {
+			p.RunOnce(context.Background())
+			select {
+			case <-ticker.C:
+			case <-p.stopChan:
+				return
+			}
+		}
+	}()
+}
+
+// RunOnce prunes the request log, deletes honeytokens, form submissions
+// and request captures older than the retention age, checkpoints the WAL,
+// and vacuums the database if the vacuum interval has elapsed. Errors are
+// logged.
+//
+// Parameters:
+//   - ctx: context for cancellation and timeout control
+func (p *Pruner) RunOnce(ctx context.Context) {
+	pruned, err := p.db.PruneRequestLog(ctx, p.config.Policy, time.Now())
+	if err != nil {
+		p.logger.Warn("Failed to prune request log", "pruned", pruned, "error", err)
+	} else if pruned > 0 {
+		p.logger.Info("Pruned request log", "rows", pruned)
+	}
+
+	if p.config.Policy.MaxAge > 0 {
+		cutoff := time.Now().Add(-p.config.Policy.MaxAge)
+		pruned, err := p.db.PruneFormData(ctx, cutoff)
+		if err != nil {
+			p.logger.Warn("Failed to prune form submissions", "pruned", pruned, "error", err)
+		} else if pruned > 0 {
+			p.logger.Info("Pruned honeytokens and form submissions", "rows", pruned)
+		}
+		pruned, err = p.db.PruneCaptures(ctx, cutoff)
+		if err != nil {
+			p.logger.Warn("Failed to prune request captures", "error", err)
+		} else if pruned > 0 {
+			p.logger.Info("Pruned request captures", "rows", pruned)
+		}
+	}
+
+	if p.config.VacuumInterval > 0 && time.Since(p.lastVacuum) >= p.config.VacuumInterval {
+		p.lastVacuum = time.Now()
+		if err := p.db.Vacuum(ctx); err != nil {
+			p.logger.Warn("Failed to vacuum database", "error", err)
+		} else {
+			p.logger.Debug("Vacuumed database")
+		}
+	}
+
+	if err := p.db.Checkpoint(ctx); err != nil {
+		p.logger.Warn("Failed to checkpoint database", "error", err)
+	}
+}
+
+// Stop stops the maintenance goroutine and waits for it to exit.
+func (p *Pruner) Stop() {
+	select {
+	case <-p.stopChan:
+		// Already closed
+	default:
+		close(p.stopChan)
+	}
+	if p.done != nil {
+		<-p.done
+	}
+}
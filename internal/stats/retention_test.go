@@ -0,0 +1,138 @@
+package stats
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestPruneRequestLog(t *testing.T) {
+	now := time.Date(2025, 6, 10, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name       string
+		policy     RetentionPolicy
+		wantPruned int
+	}{
+		{"disabled", RetentionPolicy{}, 0},
+		{"max age", RetentionPolicy{MaxAge: 36 * time.Hour, BatchSize: 2}, 6},
+		{"max rows", RetentionPolicy{MaxRows: 3, BatchSize: 4}, 7},
+		{"both", RetentionPolicy{MaxAge: 72 * time.Hour, MaxRows: 2}, 8},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newTestDatabase(t)
+			ctx := context.Background()
+
+			// Ten requests: three per day on June 7-9, one today
+			var reqs []RequestInfo
+			for day := 3; day >= 1; day-- {
+				for i := 0; i < 3; i++ {
+					reqs = append(reqs, RequestInfo{
+						IP:        "10.0.0.1",
+						UserAgent: "bot",
+						Path:      "/",
+						Timestamp: now.Add(-time.Duration(day) * 24 * time.Hour).Add(time.Duration(i) * time.Minute),
+					})
+				}
+			}
+			reqs = append(reqs, RequestInfo{IP: "10.0.0.2", UserAgent: "bot", Path: "/", Timestamp: now})
+			if _, err := db.RecordRequests(ctx, reqs); err != nil {
+				t.Fatalf("RecordRequests() error = %v", err)
+			}
+
+			pruned, err := db.PruneRequestLog(ctx, tt.policy, now)
+			if err != nil {
+				t.Fatalf("PruneRequestLog() error = %v", err)
+			}
+			if pruned != tt.wantPruned {
+				t.Errorf("PruneRequestLog() = %d, want %d", pruned, tt.wantPruned)
+			}
+
+			info, err := db.GetStorageInfo(ctx)
+			if err != nil {
+				t.Fatalf("GetStorageInfo() error = %v", err)
+			}
+			if info.RequestLogRows != len(reqs)-tt.wantPruned {
+				t.Errorf("RequestLogRows = %d, want %d", info.RequestLogRows, len(reqs)-tt.wantPruned)
+			}
+			if info.RolledUp != tt.wantPruned {
+				t.Errorf("RolledUp = %d, want %d", info.RolledUp, tt.wantPruned)
+			}
+			if want := reqs[tt.wantPruned].Timestamp; !info.OldestRecord.Equal(want) {
+				t.Errorf("OldestRecord = %v, want %v", info.OldestRecord, want)
+			}
+			if info.SizeBytes <= 0 {
+				t.Errorf("SizeBytes = %d, want > 0", info.SizeBytes)
+			}
+
+			// All-time totals are not affected by pruning
+			s, err := db.GetStats(ctx)
+			if err != nil {
+				t.Fatalf("GetStats() error = %v", err)
+			}
+			if s.TotalRequests != len(reqs) || s.IPCounts["10.0.0.1"] != 9 {
+				t.Errorf("totals after pruning = %d requests, %d from 10.0.0.1, want 10, 9", s.TotalRequests, s.IPCounts["10.0.0.1"])
+			}
+
+			var ipRollup int
+			if err := db.db.QueryRow("SELECT COALESCE(SUM(requests), 0) FROM daily_ip_counts WHERE ip = '10.0.0.1'").Scan(&ipRollup); err != nil {
+				t.Fatalf("query daily_ip_counts: %v", err)
+			}
+			if want := min(tt.wantPruned, 9); ipRollup != want {
+				t.Errorf("daily_ip_counts for 10.0.0.1 = %d, want %d", ipRollup, want)
+			}
+
+			if err := db.Checkpoint(ctx); err != nil {
+				t.Errorf("Checkpoint() error = %v", err)
+			}
+			if err := db.Vacuum(ctx); err != nil {
+				t.Errorf("Vacuum() error = %v", err)
+			}
+		})
+	}
+}
+
+func TestPruneRequestLogKeepsUnreplicated(t *testing.T) {
+	db := newTestDatabase(t)
+	ctx := context.Background()
+	now := time.Now()
+
+	var reqs []RequestInfo
+	for i := 0; i < 6; i++ {
+		reqs = append(reqs, RequestInfo{IP: "10.0.0.1", UserAgent: "bot", Path: "/", Timestamp: now.Add(-48 * time.Hour)})
+	}
+	seedRequests(t, db, reqs)
+
+	// Two collectors have acknowledged different amounts
+	if err := db.SetReplicationCursor(ctx, "https://a", 4); err != nil {
+		t.Fatalf("SetReplicationCursor() error = %v", err)
+	}
+	if err := db.SetReplicationCursor(ctx, "https://b", 2); err != nil {
+		t.Fatalf("SetReplicationCursor() error = %v", err)
+	}
+
+	policy := RetentionPolicy{MaxAge: time.Hour}
+	pruned, err := db.PruneRequestLog(ctx, policy, now)
+	if err != nil {
+		t.Fatalf("PruneRequestLog() error = %v", err)
+	}
+	if pruned != 2 {
+		t.Errorf("PruneRequestLog() = %d, want the 2 rows every collector has", pruned)
+	}
+
+	// Dropping the slower collector releases the rows only it was waiting for
+	if err := db.ClearReplicationCursors(ctx, "https://a"); err != nil {
+		t.Fatalf("ClearReplicationCursors() error = %v", err)
+	}
+	pruned, err = db.PruneRequestLog(ctx, policy, now)
+	if err != nil {
+		t.Fatalf("PruneRequestLog() error = %v", err)
+	}
+	if pruned != 2 {
+		t.Errorf("PruneRequestLog() after clearing = %d, want 2", pruned)
+	}
+	if cursor, _ := db.ReplicationCursor(ctx, "https://b"); cursor != 0 {
+		t.Errorf("cleared cursor = %d, want 0", cursor)
+	}
+}
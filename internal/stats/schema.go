@@ -0,0 +1,194 @@
+package stats
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// migrationFiles holds the schema migrations for each SQL dialect, named
+// migrations/DIALECT/NNNN_description.sql. Migrations are applied in version
+// order and must never be edited once released; change the schema by adding
+// a new file.
+//
+//go:embed migrations
+var migrationFiles embed.FS
+
+// dialect holds the SQL differences between backends that the migration
+// runner needs to know about.
+type dialect struct {
+	migrationsDir   string // Directory of the dialect's migrations in migrationFiles
+	recordMigration string // Statement inserting a schema_migrations row (version, name, applied_at)
+}
+
+var (
+	sqliteDialect = dialect{
+		migrationsDir:   "migrations/sqlite",
+		recordMigration: "INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)",
+	}
+	postgresDialect = dialect{
+		migrationsDir:   "migrations/postgres",
+		recordMigration: "INSERT INTO schema_migrations (version, name, applied_at) VALUES ($1, $2, $3)",
+	}
+)
+
+// migrationConn is the subset of *sql.DB and *sql.Conn used to migrate.
+type migrationConn interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+	BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error)
+}
+
+// ErrSchemaTooNew is returned when the database was migrated by a newer
+// version of gospidertrap than the running binary.
+var ErrSchemaTooNew = errors.New("database schema is newer than this version of gospidertrap supports")
+
+// schemaMigration is a single versioned schema change.
+type schemaMigration struct {
+	version int
+	name    string
+	sql     string
+}
+
+// loadMigrations parses the migration files in a directory.
+//
+// Returns the migrations sorted by version, or an error if a file name is
+// malformed or two files share a version.
+func loadMigrations(fsys fs.FS, dir string) ([]schemaMigration, error) {
+	names, err := fs.Glob(fsys, path.Join(dir, "*.sql"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list migrations: %w", err)
+	}
+
+	migrations := make([]schemaMigration, 0, len(names))
+	seen := make(map[int]string)
+	for _, name := range names {
+		base := strings.TrimSuffix(path.Base(name), ".sql")
+		prefix, _, ok := strings.Cut(base, "_")
+		version, err := strconv.Atoi(prefix)
+		if !ok || err != nil || version <= 0 {
+			return nil, fmt.Errorf("invalid migration file name: %s (expected NNNN_description.sql)", name)
+		}
+		if other, exists := seen[version]; exists {
+			return nil, fmt.Errorf("duplicate migration version %d: %s and %s", version, other, name)
+		}
+		seen[version] = name
+
+		data, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read migration %s: %w", name, err)
+		}
+		migrations = append(migrations, schemaMigration{version: version, name: base, sql: string(data)})
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].version < migrations[j].version
+	})
+	return migrations, nil
+}
+
+// migrate brings the database schema up to date.
+//
+// Each pending migration runs in its own transaction together with its
+// schema_migrations row, so a failed migration leaves the database at the
+// previous version.
+//
+// Parameters:
+//   - ctx: context for cancellation and timeout control
+//   - db: the database connection
+//   - dialect: SQL dialect of the database
+//   - migrations: all known migrations, sorted by version
+//   - logger: structured logger instance
+//
+// Returns ErrSchemaTooNew if the database has a migration this binary does
+// not know, or an error if a migration fails.
+func migrate(ctx context.Context, db migrationConn, dialect dialect, migrations []schemaMigration, logger *slog.Logger) error {
+	_, err := db.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+		    version INTEGER PRIMARY KEY,
+		    name TEXT NOT NULL,
+		    applied_at TIMESTAMP NOT NULL
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create schema_migrations table: %w", err)
+	}
+
+	current, err := schemaVersion(ctx, db)
+	if err != nil {
+		return err
+	}
+
+	latest := 0
+	if len(migrations) > 0 {
+		latest = migrations[len(migrations)-1].version
+	}
+	if current > latest {
+		return fmt.Errorf("%w: database is at version %d, latest known is %d", ErrSchemaTooNew, current, latest)
+	}
+
+	for _, m := range migrations {
+		if m.version <= current {
+			continue
+		}
+		if err := applyMigration(ctx, db, dialect, m); err != nil {
+			return err
+		}
+		logger.Debug("Applied schema migration", "version", m.version, "name", m.name)
+	}
+	return nil
+}
+
+// applyMigration runs one migration and records it in schema_migrations.
+func applyMigration(ctx context.Context, db migrationConn, dialect dialect, m schemaMigration) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin migration %s: %w", m.name, err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, m.sql); err != nil {
+		return fmt.Errorf("failed to apply migration %s: %w", m.name, err)
+	}
+	_, err = tx.ExecContext(ctx, dialect.recordMigration, m.version, m.name, time.Now().UTC().Format(time.RFC3339))
+	if err != nil {
+		return fmt.Errorf("failed to record migration %s: %w", m.name, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit migration %s: %w", m.name, err)
+	}
+	return nil
+}
+
+// schemaVersion returns the highest applied migration version, or 0 if
+// none has been applied.
+func schemaVersion(ctx context.Context, db migrationConn) (int, error) {
+	var version int
+	err := db.QueryRowContext(ctx, "SELECT COALESCE(MAX(version), 0) FROM schema_migrations").Scan(&version)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read schema version: %w", err)
+	}
+	return version, nil
+}
+
+// SchemaVersion returns the database's current schema version.
+//
+// Parameters:
+//   - ctx: context for cancellation and timeout control
+//
+// Returns the version, or an error if it cannot be read.
+func (d *Database) SchemaVersion(ctx context.Context) (int, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return schemaVersion(ctx, d.db)
+}
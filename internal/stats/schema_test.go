@@ -0,0 +1,216 @@
+package stats
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+// createHistoricalDatabase creates a database file from a schema in
+// testdata and inserts one request, as an older release would have.
+func createHistoricalDatabase(t *testing.T, schemaFile string) string {
+	t.Helper()
+	schema, err := os.ReadFile(filepath.Join("testdata", schemaFile))
+	if err != nil {
+		t.Fatalf("read %s: %v", schemaFile, err)
+	}
+
+	path := filepath.Join(t.TempDir(), "stats.db")
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatalf("sql.Open() error = %v", err)
+	}
+	defer db.Close()
+
+	now := time.Now()
+	for _, stmt := range []string{
+		string(schema),
+		"INSERT INTO stats (id, start_time, total_requests) VALUES (1, ?, 1)",
+		"INSERT INTO ip_counts (ip, count, first_seen, last_seen) VALUES ('10.0.0.1', 1, ?, ?)",
+		"INSERT INTO request_log (ip, user_agent, path, timestamp) VALUES ('10.0.0.1', 'bot', '/', ?)",
+	} {
+		var args []any
+		for i := 0; i < strings.Count(stmt, "?"); i++ {
+			args = append(args, now)
+		}
+		if _, err := db.Exec(stmt, args...); err != nil {
+			t.Fatalf("create %s database: %v", schemaFile, err)
+		}
+	}
+	return path
+}
+
+func TestMigrateHistoricalSchemas(t *testing.T) {
+	migrations, err := loadMigrations(migrationFiles, sqliteDialect.migrationsDir)
+	if err != nil {
+		t.Fatalf("loadMigrations() error = %v", err)
+	}
+	latest := migrations[len(migrations)-1].version
+
+	for _, schemaFile := range []string{"schema_legacy.sql", "schema_rollups.sql"} {
+		t.Run(schemaFile, func(t *testing.T) {
+			path := createHistoricalDatabase(t, schemaFile)
+			logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+			// Opening twice checks that migrations are not reapplied
+			for i := 0; i < 2; i++ {
+				db, err := NewDatabase(path, logger)
+				if err != nil {
+					t.Fatalf("NewDatabase() error = %v", err)
+				}
+				ctx := context.Background()
+
+				version, err := db.SchemaVersion(ctx)
+				if err != nil {
+					t.Fatalf("SchemaVersion() error = %v", err)
+				}
+				if version != latest {
+					t.Errorf("SchemaVersion() = %d, want %d", version, latest)
+				}
+
+				s, err := db.GetStats(ctx)
+				if err != nil {
+					t.Fatalf("GetStats() error = %v", err)
+				}
+				if s.TotalRequests != 1+i || s.IPCounts["10.0.0.1"] != 1+i {
+					t.Errorf("existing data not preserved: %d requests, %d from 10.0.0.1", s.TotalRequests, s.IPCounts["10.0.0.1"])
+				}
+
+				// The database is usable at the latest schema
+				if _, err := db.RecordRequest(ctx, RequestInfo{IP: "10.0.0.1", UserAgent: "bot", Path: "/", Timestamp: time.Now()}); err != nil {
+					t.Fatalf("RecordRequest() error = %v", err)
+				}
+				if _, err := db.PruneRequestLog(ctx, RetentionPolicy{MaxRows: 1}, time.Now()); err != nil {
+					t.Fatalf("PruneRequestLog() error = %v", err)
+				}
+				db.Close()
+			}
+		})
+	}
+}
+
+func TestMigrateSortableTimestamps(t *testing.T) {
+	migrations, err := loadMigrations(migrationFiles, sqliteDialect.migrationsDir)
+	if err != nil {
+		t.Fatalf("loadMigrations() error = %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "stats.db")
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatalf("sql.Open() error = %v", err)
+	}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	if err := migrate(context.Background(), db, sqliteDialect, migrations[:9], logger); err != nil {
+		t.Fatalf("migrate() error = %v", err)
+	}
+
+	// The driver writes UTC times as RFC 3339 and others in Go's layout,
+	// with the monotonic clock reading of times from time.Now
+	times := []time.Time{
+		time.Date(2025, 6, 10, 12, 0, 0, 123456789, time.UTC),
+		time.Date(2025, 6, 10, 12, 0, 0, 0, time.UTC),
+		time.Date(2025, 6, 10, 17, 30, 0, 987654321, time.FixedZone("IST", 5*3600+1800)),
+		time.Date(2025, 6, 10, 5, 0, 0, 0, time.FixedZone("", -7*3600)),
+		time.Date(2025, 6, 10, 12, 0, 0, 5e8, time.UTC),
+	}
+	for i, ts := range times {
+		var stored any = ts
+		if i == len(times)-1 {
+			stored = "2025-06-10 12:00:00.5 +0000 UTC m=+2.384664753"
+		}
+		ip := "10.0.0." + strconv.Itoa(i)
+		if _, err := db.Exec("INSERT INTO request_log (ip, timestamp) VALUES (?, ?)", ip, stored); err != nil {
+			t.Fatalf("insert request: %v", err)
+		}
+		if _, err := db.Exec("INSERT INTO ip_counts (ip, count, first_seen, last_seen) VALUES (?, 1, ?, ?)", ip, stored, stored); err != nil {
+			t.Fatalf("insert IP count: %v", err)
+		}
+	}
+	db.Close()
+
+	d, err := NewDatabase(path, logger)
+	if err != nil {
+		t.Fatalf("NewDatabase() error = %v", err)
+	}
+	defer d.Close()
+	for i, ts := range times {
+		var requestMs, lastSeenMs int64
+		err := d.db.QueryRow(`
+			SELECT r.timestamp_ms, c.last_seen_ms FROM request_log r JOIN ip_counts c ON c.ip = r.ip WHERE r.ip = ?
+		`, "10.0.0."+strconv.Itoa(i)).Scan(&requestMs, &lastSeenMs)
+		if err != nil {
+			t.Fatalf("query backfilled columns: %v", err)
+		}
+		// SQLite's date functions round to the millisecond
+		if want := ts.Round(time.Millisecond).UnixMilli(); requestMs != want || lastSeenMs != want {
+			t.Errorf("%v backfilled as %d and %d, want %d", ts, requestMs, lastSeenMs, want)
+		}
+	}
+}
+
+func TestMigrateRefusesNewerSchema(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "stats.db")
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	db, err := NewDatabase(path, logger)
+	if err != nil {
+		t.Fatalf("NewDatabase() error = %v", err)
+	}
+	if _, err := db.db.Exec("INSERT INTO schema_migrations (version, name, applied_at) VALUES (9999, '9999_future', '')"); err != nil {
+		t.Fatalf("insert future migration: %v", err)
+	}
+	db.Close()
+
+	if _, err := NewDatabase(path, logger); !errors.Is(err, ErrSchemaTooNew) {
+		t.Errorf("NewDatabase() error = %v, want ErrSchemaTooNew", err)
+	}
+}
+
+func TestMigrateRollsBackFailedMigration(t *testing.T) {
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "stats.db"))
+	if err != nil {
+		t.Fatalf("sql.Open() error = %v", err)
+	}
+	defer db.Close()
+
+	migrations, err := loadMigrations(fstest.MapFS{
+		"migrations/0001_good.sql": {Data: []byte("CREATE TABLE a (id INTEGER);")},
+		"migrations/0002_bad.sql":  {Data: []byte("CREATE TABLE b (id INTEGER); INSERT INTO missing VALUES (1);")},
+	}, "migrations")
+	if err != nil {
+		t.Fatalf("loadMigrations() error = %v", err)
+	}
+
+	ctx := context.Background()
+	if err := migrate(ctx, db, sqliteDialect, migrations, slog.New(slog.NewTextHandler(io.Discard, nil))); err == nil {
+		t.Fatal("migrate() error = nil, want failure from 0002_bad")
+	}
+	if version, _ := schemaVersion(ctx, db); version != 1 {
+		t.Errorf("schemaVersion() = %d, want 1", version)
+	}
+	var n int
+	if err := db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE name = 'b'").Scan(&n); err != nil || n != 0 {
+		t.Errorf("table from failed migration exists (count %d, err %v)", n, err)
+	}
+}
+
+func TestLoadMigrationsRejectsBadNames(t *testing.T) {
+	tests := map[string]fstest.MapFS{
+		"no version": {"migrations/initial.sql": {}},
+		"duplicate":  {"migrations/0001_a.sql": {}, "migrations/0001_b.sql": {}},
+	}
+	for name, fsys := range tests {
+		if _, err := loadMigrations(fsys, "migrations"); err == nil {
+			t.Errorf("%s: loadMigrations() error = nil, want error", name)
+		}
+	}
+}
@@ -0,0 +1,444 @@
+package stats
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/netip"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultSearchLimit is the page size used when a RequestQuery has no limit.
+const DefaultSearchLimit = 50
+
+const (
+	// searchChunkSize is the number of rows SearchRequests reads per query.
+	// The database lock is released between chunks so recording can proceed.
+	searchChunkSize = 1000
+
+	// searchMaxScan is the number of rows SearchRequests reads before
+	// returning a partial page, so a filter that matches few rows, such as
+	// a large network, cannot walk the whole request log in one call.
+	searchMaxScan = 50000
+)
+
+// ErrInvalidCursor is returned when a RequestQuery's cursor cannot be decoded.
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// RequestSort is a column requests can be sorted by.
+type RequestSort string
+
+// Request sort columns
+const (
+	SortTime      RequestSort = "time" // Recording order
+	SortIP        RequestSort = "ip"
+	SortPath      RequestSort = "path"
+	SortUserAgent RequestSort = "ua"
+)
+
+// ParseRequestSort parses a sort column name, defaulting to SortTime for an
+// empty name.
+//
+// Parameters:
+//   - s: the column name, e.g. "ip"
+//
+// Returns the sort column, or an error if the name is unknown.
+func ParseRequestSort(s string) (RequestSort, error) {
+	switch col := RequestSort(s); col {
+	case "":
+		return SortTime, nil
+	case SortTime, SortIP, SortPath, SortUserAgent:
+		return col, nil
+	default:
+		return "", fmt.Errorf("unknown sort column %q", s)
+	}
+}
+
+// RequestFilter selects requests from the request log. Zero fields match
+// every request.
+type RequestFilter struct {
+	IP         netip.Prefix // Address or network the request came from
+	UserAgent  string       // Case-insensitive user agent substring
+	PathPrefix string       // Case-sensitive path prefix
+	Since      time.Time    // Requests at or after this time
+	Until      time.Time    // Requests before this time
+	Bait       string       // Name of the bait that answered, BaitAny or BaitNone
+}
+
+// ParseIPFilter parses an IP address or CIDR network for RequestFilter.IP.
+// A single address is returned as a prefix covering only that address.
+//
+// Parameters:
+//   - s: an address such as "192.0.2.1" or a network such as "192.0.2.0/24"
+//
+// Returns the prefix, or an error if s is neither.
+func ParseIPFilter(s string) (netip.Prefix, error) {
+	if strings.Contains(s, "/") {
+		prefix, err := netip.ParsePrefix(s)
+		if err != nil {
+			return netip.Prefix{}, fmt.Errorf("invalid network %q: %w", s, err)
+		}
+		return prefix.Masked(), nil
+	}
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		return netip.Prefix{}, fmt.Errorf("invalid IP address %q: %w", s, err)
+	}
+	return netip.PrefixFrom(addr, addr.BitLen()), nil
+}
+
+// Match reports whether req passes the filter.
+func (f RequestFilter) Match(req RequestInfo) bool {
+	if f.IP.IsValid() {
+		addr, err := netip.ParseAddr(req.IP)
+		if err != nil || !(f.IP.Contains(addr) || f.IP.Contains(addr.Unmap())) {
+			return false
+		}
+	}
+	if f.UserAgent != "" && !strings.Contains(strings.ToLower(req.UserAgent), strings.ToLower(f.UserAgent)) {
+		return false
+	}
+	if !strings.HasPrefix(req.Path, f.PathPrefix) {
+		return false
+	}
+	if !f.Since.IsZero() && req.Timestamp.Before(f.Since) {
+		return false
+	}
+	if !f.Until.IsZero() && !req.Timestamp.Before(f.Until) {
+		return false
+	}
+	switch f.Bait {
+	case "":
+	case BaitAny:
+		return req.Bait != ""
+	case BaitNone:
+		return req.Bait == ""
+	default:
+		return req.Bait == f.Bait
+	}
+	return true
+}
+
+// RequestQuery is a search of the request log.
+type RequestQuery struct {
+	RequestFilter
+	Sort      RequestSort // Column to sort by (empty sorts by time)
+	Ascending bool        // Sort ascending instead of descending
+	After     string      // Cursor from the previous page's Next, empty for the first page
+	Limit     int         // Page size (0 uses DefaultSearchLimit)
+}
+
+// RequestPage is one page of search results.
+type RequestPage struct {
+	Requests []RequestInfo
+	Next     string // Cursor for the following page, empty on the last page
+}
+
+// RequestSearcher is implemented by stores that can search their request
+// log with keyset pagination.
+type RequestSearcher interface {
+	SearchRequests(ctx context.Context, q RequestQuery) (RequestPage, error)
+}
+
+// searchCursor is the position of the last request on a page: its sort key
+// and its position in recording order, which breaks ties.
+type searchCursor struct {
+	id  int64
+	key string
+}
+
+// encode returns the cursor as an opaque URL-safe string.
+func (c searchCursor) encode() string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatInt(c.id, 10) + ":" + c.key))
+}
+
+// decodeCursor parses a cursor returned by searchCursor.encode.
+func decodeCursor(s string) (searchCursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return searchCursor{}, ErrInvalidCursor
+	}
+	idText, key, ok := strings.Cut(string(b), ":")
+	if !ok {
+		return searchCursor{}, ErrInvalidCursor
+	}
+	id, err := strconv.ParseInt(idText, 10, 64)
+	if err != nil {
+		return searchCursor{}, ErrInvalidCursor
+	}
+	return searchCursor{id: id, key: key}, nil
+}
+
+// sortKey returns the value of req's sort column. Time sorts by recording
+// order alone, so it has no key.
+func sortKey(req RequestInfo, s RequestSort) string {
+	switch s {
+	case SortIP:
+		return req.IP
+	case SortPath:
+		return req.Path
+	case SortUserAgent:
+		return req.UserAgent
+	default:
+		return ""
+	}
+}
+
+// normalize validates q and fills in defaults.
+func (q *RequestQuery) normalize() (*searchCursor, error) {
+	if q.Sort == "" {
+		q.Sort = SortTime
+	}
+	if _, err := ParseRequestSort(string(q.Sort)); err != nil {
+		return nil, err
+	}
+	if q.Limit <= 0 {
+		q.Limit = DefaultSearchLimit
+	}
+	if q.After == "" {
+		return nil, nil
+	}
+	cursor, err := decodeCursor(q.After)
+	if err != nil {
+		return nil, err
+	}
+	return &cursor, nil
+}
+
+// SearchRequests returns a page of requests from the request log matching
+// q.
+//
+// Sorting by time follows recording order, which matches time order for
+// locally recorded requests. Filters on exact IP addresses, user agents,
+// paths, baits and time ranges are applied in SQL; networks are applied to
+// the rows read.
+//
+// Rows are read in chunks of searchChunkSize. Once searchMaxScan rows have
+// been read, the page is returned with the matches found so far and a Next
+// cursor that continues the scan, so a page can hold fewer than q.Limit
+// requests without being the last.
+//
+// Parameters:
+//   - ctx: context for cancellation and timeout control
+//   - q: the filter, sort order, cursor and page size
+//
+// Returns the page, or ErrInvalidCursor if q.After cannot be decoded.
+func (d *Database) SearchRequests(ctx context.Context, q RequestQuery) (RequestPage, error) {
+	cursor, err := q.normalize()
+	if err != nil {
+		return RequestPage{}, err
+	}
+
+	var where []string
+	var args []any
+	if q.IP.IsValid() && q.IP.IsSingleIP() {
+		where = append(where, "ip = ?")
+		args = append(args, q.IP.Addr().String())
+	}
+	if q.UserAgent != "" {
+		where = append(where, `COALESCE(user_agent, '') LIKE ? ESCAPE '\'`)
+		args = append(args, "%"+escapeLike(q.UserAgent)+"%")
+	}
+	if q.PathPrefix != "" {
+		where = append(where, "instr(COALESCE(path, ''), ?) = 1")
+		args = append(args, q.PathPrefix)
+	}
+	if !q.Since.IsZero() {
+		where = append(where, "timestamp_ms >= ?")
+		args = append(args, q.Since.UnixMilli())
+	}
+	if !q.Until.IsZero() {
+		where = append(where, "timestamp_ms < ?")
+		args = append(args, q.Until.UnixMilli())
+	}
+	switch q.Bait {
+	case "":
+	case BaitAny:
+		where = append(where, "bait != ''")
+	case BaitNone:
+		where = append(where, "bait = ''")
+	default:
+		where = append(where, "bait = ?")
+		args = append(args, q.Bait)
+	}
+
+	dir, cmp := "DESC", "<"
+	if q.Ascending {
+		dir, cmp = "ASC", ">"
+	}
+	column := map[RequestSort]string{
+		SortIP:        "ip",
+		SortPath:      "COALESCE(path, '')",
+		SortUserAgent: "COALESCE(user_agent, '')",
+	}[q.Sort]
+	order := "id " + dir
+	if column != "" {
+		order = column + " " + dir + ", " + order
+	}
+	var page RequestPage
+	var lastMatch searchCursor
+	for scanned := 0; ; {
+		chunk, err := d.searchChunk(ctx, where, args, column, order, cmp, cursor)
+		if err != nil {
+			return RequestPage{}, err
+		}
+		for _, row := range chunk {
+			scanned++
+			cursor = &searchCursor{id: row.id, key: sortKey(row.req, q.Sort)}
+			if !q.Match(row.req) {
+				continue
+			}
+			if len(page.Requests) == q.Limit {
+				// There is at least one more match, so link to it
+				page.Next = lastMatch.encode()
+				return page, nil
+			}
+			page.Requests = append(page.Requests, row.req)
+			lastMatch = *cursor
+		}
+		if len(chunk) < searchChunkSize {
+			return page, nil
+		}
+		if scanned >= searchMaxScan {
+			page.Next = cursor.encode()
+			return page, nil
+		}
+	}
+}
+
+// searchRow is a request read by searchChunk, with its request_log id.
+type searchRow struct {
+	id  int64
+	req RequestInfo
+}
+
+// searchChunk reads up to searchChunkSize rows after cursor for
+// SearchRequests.
+//
+// Parameters:
+//   - ctx: context for cancellation and timeout control
+//   - where, args: the filter conditions and their arguments
+//   - column: the sort column, empty to sort by id alone
+//   - order: the ORDER BY clause
+//   - cmp: "<" for a descending sort, ">" for an ascending one
+//   - cursor: the last row already read, nil to start from the first
+//
+// Returns the rows in sort order.
+func (d *Database) searchChunk(ctx context.Context, where []string, args []any, column, order, cmp string, cursor *searchCursor) ([]searchRow, error) {
+	if cursor != nil {
+		if column == "" {
+			where = append(where[:len(where):len(where)], "id "+cmp+" ?")
+			args = append(args[:len(args):len(args)], cursor.id)
+		} else {
+			where = append(where[:len(where):len(where)], "("+column+" "+cmp+" ? OR ("+column+" = ? AND id "+cmp+" ?))")
+			args = append(args[:len(args):len(args)], cursor.key, cursor.key, cursor.id)
+		}
+	}
+
+	query := "SELECT id, ip, COALESCE(user_agent, ''), COALESCE(path, ''), timestamp, source, bait FROM request_log"
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	query += " ORDER BY " + order + " LIMIT ?"
+	args = append(args[:len(args):len(args)], searchChunkSize)
+
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	rows, err := d.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search request log: %w", err)
+	}
+	defer rows.Close()
+
+	var chunk []searchRow
+	for rows.Next() {
+		var row searchRow
+		req := &row.req
+		if err := rows.Scan(&row.id, &req.IP, &req.UserAgent, &req.Path, &req.Timestamp, &req.Source, &req.Bait); err != nil {
+			return nil, fmt.Errorf("failed to scan request: %w", err)
+		}
+		chunk = append(chunk, row)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating request log: %w", err)
+	}
+	return chunk, nil
+}
+
+// escapeLike escapes the LIKE wildcards in s, using backslash as the
+// escape character.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+// SearchRequests returns a page of the retained recent requests matching q.
+//
+// Requests are numbered in recording order, counting from the first
+// request ever recorded, so cursors stay valid as new requests arrive and
+// old ones are dropped.
+//
+// Parameters:
+//   - ctx: context for cancellation and timeout control
+//   - q: the filter, sort order, cursor and page size
+//
+// Returns the page, or ErrInvalidCursor if q.After cannot be decoded.
+func (s *MemoryStore) SearchRequests(ctx context.Context, q RequestQuery) (RequestPage, error) {
+	cursor, err := q.normalize()
+	if err != nil {
+		return RequestPage{}, err
+	}
+
+	type numbered struct {
+		id  int64
+		key string
+		req RequestInfo
+	}
+
+	s.stats.Mu.RLock()
+	first := int64(s.stats.TotalRequests - len(s.stats.RecentRequests) + 1)
+	var matches []numbered
+	for i, req := range s.stats.RecentRequests {
+		if q.Match(req) {
+			matches = append(matches, numbered{id: first + int64(i), key: sortKey(req, q.Sort), req: req})
+		}
+	}
+	s.stats.Mu.RUnlock()
+
+	// before reports whether a sorts before b in the requested order
+	before := func(aKey string, aID int64, bKey string, bID int64) bool {
+		less := aKey < bKey
+		if aKey == bKey {
+			less = aID < bID
+		}
+		if q.Ascending {
+			return less
+		}
+		return !less && (aKey != bKey || aID != bID)
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		return before(matches[i].key, matches[i].id, matches[j].key, matches[j].id)
+	})
+
+	start := 0
+	if cursor != nil {
+		start = sort.Search(len(matches), func(i int) bool {
+			return before(cursor.key, cursor.id, matches[i].key, matches[i].id)
+		})
+	}
+	end := min(start+q.Limit, len(matches))
+
+	var page RequestPage
+	for _, m := range matches[start:end] {
+		page.Requests = append(page.Requests, m.req)
+	}
+	if end < len(matches) {
+		last := matches[end-1]
+		page.Next = searchCursor{id: last.id, key: last.key}.encode()
+	}
+	return page, nil
+}
@@ -0,0 +1,217 @@
+package stats
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/netip"
+	"slices"
+	"testing"
+	"time"
+)
+
+// searchBackends returns a SQLite and an in-memory store holding the same
+// requests.
+func searchBackends(t *testing.T, reqs []RequestInfo) map[string]RequestSearcher {
+	t.Helper()
+	db := newTestDatabase(t)
+	seedRequests(t, db, reqs)
+	memory := NewMemoryStore(NewStats())
+	if _, err := memory.RecordRequests(context.Background(), reqs); err != nil {
+		t.Fatalf("RecordRequests() error = %v", err)
+	}
+	return map[string]RequestSearcher{"sqlite": db, "memory": memory}
+}
+
+// searchAll follows a search's cursors to the end and returns the paths of
+// every request found, checking that no page exceeds the limit.
+func searchAll(t *testing.T, s RequestSearcher, q RequestQuery) []string {
+	t.Helper()
+	var paths []string
+	for pages := 0; ; pages++ {
+		if pages > 100 {
+			t.Fatal("search did not end")
+		}
+		page, err := s.SearchRequests(context.Background(), q)
+		if err != nil {
+			t.Fatalf("SearchRequests() error = %v", err)
+		}
+		if len(page.Requests) > q.Limit {
+			t.Fatalf("page has %d requests, want at most %d", len(page.Requests), q.Limit)
+		}
+		for _, req := range page.Requests {
+			paths = append(paths, req.Path)
+		}
+		if page.Next == "" {
+			return paths
+		}
+		q.After = page.Next
+	}
+}
+
+func TestSearchRequests(t *testing.T) {
+	start := time.Date(2025, 6, 10, 12, 0, 0, 0, time.UTC)
+	ips := []string{"10.0.0.2", "10.0.1.1", "192.168.1.1", "10.0.0.1"}
+	agents := []string{"Googlebot/2.1", "curl/8.0", "Mozilla/5.0 (compatible; bingbot)", "curl/8.0"}
+	var reqs []RequestInfo
+	for i := range 20 {
+		reqs = append(reqs, RequestInfo{
+			IP:        ips[i%len(ips)],
+			UserAgent: agents[i%len(agents)],
+			Path:      fmt.Sprintf("/%s/%02d", []string{"admin", "wp"}[i%2], i),
+			Timestamp: start.Add(time.Duration(i) * time.Minute),
+		})
+	}
+	paths := func(indexes ...int) []string {
+		var out []string
+		for _, i := range indexes {
+			out = append(out, reqs[i].Path)
+		}
+		return out
+	}
+
+	tests := []struct {
+		name  string
+		query RequestQuery
+		want  []string
+	}{
+		{"newest first", RequestQuery{}, paths(19, 18, 17, 16, 15, 14, 13, 12, 11, 10, 9, 8, 7, 6, 5, 4, 3, 2, 1, 0)},
+		{"oldest first", RequestQuery{Ascending: true, RequestFilter: RequestFilter{Until: start.Add(4 * time.Minute)}}, paths(0, 1, 2, 3)},
+		{"exact IP", RequestQuery{RequestFilter: RequestFilter{IP: netip.MustParsePrefix("10.0.0.1/32")}}, paths(19, 15, 11, 7, 3)},
+		{"network", RequestQuery{RequestFilter: RequestFilter{IP: netip.MustParsePrefix("10.0.0.0/24")}}, paths(19, 16, 15, 12, 11, 8, 7, 4, 3, 0)},
+		{"user agent substring", RequestQuery{RequestFilter: RequestFilter{UserAgent: "BOT"}}, paths(18, 16, 14, 12, 10, 8, 6, 4, 2, 0)},
+		{"path prefix", RequestQuery{RequestFilter: RequestFilter{PathPrefix: "/wp/1"}}, paths(19, 17, 15, 13, 11)},
+		{"path prefix is case-sensitive", RequestQuery{RequestFilter: RequestFilter{PathPrefix: "/WP"}}, nil},
+		{"time range", RequestQuery{RequestFilter: RequestFilter{Since: start.Add(5 * time.Minute), Until: start.Add(8 * time.Minute)}}, paths(7, 6, 5)},
+		{"combined", RequestQuery{RequestFilter: RequestFilter{IP: netip.MustParsePrefix("10.0.0.0/16"), UserAgent: "curl", Since: start.Add(10 * time.Minute)}}, paths(19, 17, 15, 13, 11)},
+		{"by IP ascending", RequestQuery{Sort: SortIP, Ascending: true, RequestFilter: RequestFilter{PathPrefix: "/admin/0"}}, paths(0, 4, 8, 2, 6)},
+		{"by IP descending", RequestQuery{Sort: SortIP, RequestFilter: RequestFilter{PathPrefix: "/admin/0"}}, paths(6, 2, 8, 4, 0)},
+		{"by user agent", RequestQuery{Sort: SortUserAgent, Ascending: true, RequestFilter: RequestFilter{Since: start.Add(16 * time.Minute)}}, paths(16, 18, 17, 19)},
+		{"by path", RequestQuery{Sort: SortPath, Ascending: true, RequestFilter: RequestFilter{Since: start.Add(16 * time.Minute)}}, paths(16, 18, 17, 19)},
+	}
+	for backend, searcher := range searchBackends(t, reqs) {
+		for _, tt := range tests {
+			t.Run(backend+"/"+tt.name, func(t *testing.T) {
+				// Small pages exercise the cursors, including ties on the sort key
+				for _, limit := range []int{1, 3, 50} {
+					q := tt.query
+					q.Limit = limit
+					if got := searchAll(t, searcher, q); !slices.Equal(got, tt.want) {
+						t.Errorf("limit %d: got %v, want %v", limit, got, tt.want)
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestSearchRequestsCursorSurvivesNewRequests(t *testing.T) {
+	start := time.Date(2025, 6, 10, 12, 0, 0, 0, time.UTC)
+	var reqs []RequestInfo
+	for i := range 5 {
+		reqs = append(reqs, RequestInfo{IP: "10.0.0.1", UserAgent: "bot", Path: fmt.Sprintf("/%d", i), Timestamp: start.Add(time.Duration(i) * time.Minute)})
+	}
+	for backend, searcher := range searchBackends(t, reqs) {
+		t.Run(backend, func(t *testing.T) {
+			ctx := context.Background()
+			page, err := searcher.SearchRequests(ctx, RequestQuery{Limit: 2})
+			if err != nil {
+				t.Fatalf("SearchRequests() error = %v", err)
+			}
+
+			// A request recorded between pages does not shift the next page
+			store := searcher.(Store)
+			if _, err := store.RecordRequests(ctx, []RequestInfo{{IP: "10.0.0.1", UserAgent: "bot", Path: "/new", Timestamp: start.Add(time.Hour)}}); err != nil {
+				t.Fatalf("RecordRequests() error = %v", err)
+			}
+			page, err = searcher.SearchRequests(ctx, RequestQuery{Limit: 2, After: page.Next})
+			if err != nil {
+				t.Fatalf("SearchRequests() error = %v", err)
+			}
+			if len(page.Requests) != 2 || page.Requests[0].Path != "/2" || page.Requests[1].Path != "/1" {
+				t.Errorf("second page = %+v, want /2 and /1", page.Requests)
+			}
+		})
+	}
+}
+
+func TestSearchRequestsScanLimit(t *testing.T) {
+	db := newTestDatabase(t)
+	start := time.Date(2025, 6, 10, 12, 0, 0, 0, time.UTC)
+
+	// Only the two oldest requests are in the network, ahead of more rows
+	// than a single call scans
+	seedRequests(t, db, []RequestInfo{
+		{IP: "172.16.0.1", UserAgent: "bot", Path: "/first", Timestamp: start},
+		{IP: "172.16.0.2", UserAgent: "bot", Path: "/second", Timestamp: start.Add(time.Millisecond)},
+	})
+	later := start.Add(time.Second)
+	_, err := db.db.Exec(`
+		WITH RECURSIVE n(i) AS (SELECT 1 UNION ALL SELECT i + 1 FROM n WHERE i < ?)
+		INSERT INTO request_log (ip, user_agent, path, timestamp, timestamp_ms)
+		SELECT '10.0.0.1', 'bot', '/', ?, ? FROM n
+	`, searchMaxScan+searchChunkSize/2, later, later.UnixMilli())
+	if err != nil {
+		t.Fatalf("insert requests: %v", err)
+	}
+
+	q := RequestQuery{Limit: 10, RequestFilter: RequestFilter{IP: netip.MustParsePrefix("172.16.0.0/12")}}
+	page, err := db.SearchRequests(context.Background(), q)
+	if err != nil {
+		t.Fatalf("SearchRequests() error = %v", err)
+	}
+	if len(page.Requests) != 0 || page.Next == "" {
+		t.Fatalf("first page = %d requests, next %q, want a partial page that continues", len(page.Requests), page.Next)
+	}
+	if got := searchAll(t, db, q); !slices.Equal(got, []string{"/second", "/first"}) {
+		t.Errorf("got %v, want both requests in the network", got)
+	}
+
+	// Until is exclusive
+	q = RequestQuery{Limit: 10, RequestFilter: RequestFilter{Since: start, Until: start.Add(time.Millisecond)}}
+	if got := searchAll(t, db, q); !slices.Equal(got, []string{"/first"}) {
+		t.Errorf("time range got %v, want /first", got)
+	}
+}
+
+func TestSearchRequestsInvalid(t *testing.T) {
+	for backend, searcher := range searchBackends(t, nil) {
+		t.Run(backend, func(t *testing.T) {
+			ctx := context.Background()
+			for _, cursor := range []string{"!!!", "bm8tY29sb24", "eDox"} {
+				if _, err := searcher.SearchRequests(ctx, RequestQuery{After: cursor}); !errors.Is(err, ErrInvalidCursor) {
+					t.Errorf("SearchRequests(After: %q) error = %v, want ErrInvalidCursor", cursor, err)
+				}
+			}
+			if _, err := searcher.SearchRequests(ctx, RequestQuery{Sort: "size"}); err == nil {
+				t.Error("SearchRequests() with an unknown sort column succeeded")
+			}
+		})
+	}
+}
+
+func TestParseIPFilter(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    string
+		wantErr bool
+	}{
+		{"192.0.2.1", "192.0.2.1/32", false},
+		{"192.0.2.77/24", "192.0.2.0/24", false},
+		{"2001:db8::1", "2001:db8::1/128", false},
+		{"2001:db8::/32", "2001:db8::/32", false},
+		{"192.0.2", "", true},
+		{"192.0.2.0/33", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParseIPFilter(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseIPFilter() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && got.String() != tt.want {
+				t.Errorf("ParseIPFilter() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
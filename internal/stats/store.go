@@ -0,0 +1,60 @@
+package stats
+
+import (
+	"context"
+	"io"
+	"time"
+)
+
+// Store is a statistics storage backend.
+//
+// Implementations must be safe for concurrent use. Database (SQLite),
+// PostgresStore, FileStore and MemoryStore implement Store.
+type Store interface {
+	// Backend returns a short name for the backend, such as "sqlite".
+	Backend() string
+
+	// RecordRequests records a batch of requests, returning one result per
+	// request in order.
+	RecordRequests(ctx context.Context, reqs []RequestInfo) ([]RecordResult, error)
+
+	// GetSummary returns the overall request totals.
+	GetSummary(ctx context.Context) (Summary, error)
+
+	// GetTopIPs returns up to limit IP addresses by request count, highest first.
+	GetTopIPs(ctx context.Context, limit int) ([]CountEntry, error)
+
+	// GetTopUserAgents returns up to limit user agents by request count, highest first.
+	GetTopUserAgents(ctx context.Context, limit int) ([]CountEntry, error)
+
+	// GetRecentRequests returns up to limit requests, most recent first.
+	GetRecentRequests(ctx context.Context, limit int) ([]RequestInfo, error)
+
+	// GetIPActivity returns IPs with at least minHits requests that were
+	// seen at or after since (zero for no limit), most recently seen first.
+	GetIPActivity(ctx context.Context, minHits int, since time.Time) ([]IPActivity, error)
+
+	// Close releases the store's resources.
+	Close() error
+}
+
+// StorageReporter is implemented by stores that can report their size.
+type StorageReporter interface {
+	GetStorageInfo(ctx context.Context) (StorageInfo, error)
+}
+
+// Importer is implemented by stores that can merge requests recorded by
+// other instances.
+type Importer interface {
+	// Import merges an NDJSON request export, a gzipped NDJSON request log
+	// or a SQLite database read from r, tagging untagged requests with source.
+	Import(ctx context.Context, r io.Reader, source string) (ImportResult, error)
+}
+
+// Summary holds the overall request totals.
+type Summary struct {
+	StartTime        time.Time // When statistics collection started
+	TotalRequests    int       // Total number of requests
+	UniqueIPs        int       // Number of distinct IP addresses
+	UniqueUserAgents int       // Number of distinct user agents
+}
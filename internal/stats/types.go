@@ -4,6 +4,7 @@ package stats
 import (
 	"sync"
 	"time"
+	"unicode/utf8"
 )
 
 // RequestInfo holds information about a single request.
@@ -12,17 +13,51 @@ type RequestInfo struct {
 	UserAgent string    // Client User-Agent header
 	Path      string    // Requested path
 	Timestamp time.Time // Request timestamp
+	Source    string    `json:",omitempty"` // Instance that recorded an imported request (empty if recorded locally)
+	Bait      string    `json:",omitempty"` // Scanner bait that answered the request (empty for trap pages)
 }
 
+// Longest values the request_log columns accept.
+const (
+	maxIPLength        = 45
+	maxUserAgentLength = 512
+	maxPathLength      = 2048
+)
+
+// truncate shortens s to at most n bytes without splitting a UTF-8
+// sequence.
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
+
+// RecordResult reports the counts after a request was recorded.
+//
+// A count of 1 means the IP or user agent was seen for the first time.
+// A count of 0 means the value was not tracked (in-memory tracking limit reached).
+type RecordResult struct {
+	IPCount        int // Total requests from the request's IP, including this one
+	UserAgentCount int // Total requests with the request's user agent, including this one
+}
+
+// RecordHook is called after each request is recorded.
+type RecordHook func(req RequestInfo, result RecordResult)
+
 // Stats holds connection statistics and request information.
 type Stats struct {
-	Mu                sync.RWMutex              // Mutex for thread-safe access
-	StartTime         time.Time                 // Server start time
-	TotalRequests     int                       // Total number of requests
-	IPCounts          map[string]int            // Request count per IP address
-	UserAgents        map[string]int            // Request count per user agent
-	RecentRequests    []RequestInfo             // Recent request history (limited size)
-	MaxRecentRequests int                       // Maximum number of recent requests to keep
+	Mu                sync.RWMutex         // Mutex for thread-safe access
+	StartTime         time.Time            // Server start time
+	TotalRequests     int                  // Total number of requests
+	IPCounts          map[string]int       // Request count per IP address
+	IPLastSeen        map[string]time.Time // Most recent request time per IP address
+	UserAgents        map[string]int       // Request count per user agent
+	RecentRequests    []RequestInfo        // Recent request history (limited size)
+	MaxRecentRequests int                  // Maximum number of recent requests to keep
 }
 
 // NewStats creates and initializes a new Stats instance.
@@ -30,6 +65,7 @@ func NewStats() *Stats {
 	return &Stats{
 		StartTime:         time.Now(),
 		IPCounts:          make(map[string]int),
+		IPLastSeen:        make(map[string]time.Time),
 		UserAgents:        make(map[string]int),
 		RecentRequests:    make([]RequestInfo, 0),
 		MaxRecentRequests: 100, // Keep last 100 requests
@@ -38,11 +74,21 @@ func NewStats() *Stats {
 
 // PersistedStats holds the serializable form of Stats for JSON persistence.
 type PersistedStats struct {
-	StartTime      time.Time      `json:"startTime"`
-	TotalRequests  int            `json:"totalRequests"`
-	IPCounts       map[string]int `json:"ipCounts"`
-	UserAgents     map[string]int `json:"userAgents"`
-	RecentRequests []RequestInfo  `json:"recentRequests"`
+	StartTime      time.Time            `json:"startTime"`
+	TotalRequests  int                  `json:"totalRequests"`
+	IPCounts       map[string]int       `json:"ipCounts"`
+	IPLastSeen     map[string]time.Time `json:"ipLastSeen,omitempty"`
+	UserAgents     map[string]int       `json:"userAgents"`
+	RecentRequests []RequestInfo        `json:"recentRequests"`
+	LogOffset      *int64               `json:"logOffset,omitempty"` // Bytes of requests.ndjson included in the snapshot (nil in snapshots written before replay support)
+}
+
+// IPActivity summarizes the requests seen from a single IP address.
+type IPActivity struct {
+	IP        string    // Client IP address
+	Count     int       // Total number of requests
+	FirstSeen time.Time // Time of the first request (zero if unknown)
+	LastSeen  time.Time // Time of the most recent request (zero if unknown)
 }
 
 // ChartData holds data for rendering charts in the admin UI.
@@ -55,4 +101,12 @@ type ChartData struct {
 		Labels []string `json:"labels"`
 		Data   []int    `json:"data"`
 	} `json:"topUserAgents"`
+	TopCountries struct { // Empty without GeoIP databases
+		Labels []string `json:"labels"`
+		Data   []int    `json:"data"`
+	} `json:"topCountries"`
+	TopASNs struct { // Empty without GeoIP databases
+		Labels []string `json:"labels"`
+		Data   []int    `json:"data"`
+	} `json:"topASNs"`
 }
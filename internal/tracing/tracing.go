@@ -0,0 +1,197 @@
+// Package tracing configures OpenTelemetry tracing and provides helpers for
+// creating spans with gospidertrap's request attributes.
+//
+// Spans are created through the global tracer provider, so instrumented
+// code produces no-op spans until Setup (or a test) installs a provider.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"net/netip"
+	"os"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrumentationName is the instrumentation scope for every span.
+const instrumentationName = "github.com/rampantspark/gospidertrap"
+
+// Supported span exporters.
+const (
+	ExporterNone   = "none"   // Tracing disabled
+	ExporterStdout = "stdout" // Spans written to stdout as JSON
+	ExporterOTLP   = "otlp"   // Spans sent to an OTLP HTTP endpoint
+)
+
+// Config holds tracing settings.
+type Config struct {
+	Exporter    string  // Span exporter: none, stdout or otlp
+	Endpoint    string  // OTLP HTTP endpoint URL (empty uses OTEL_EXPORTER_OTLP_* or localhost:4318)
+	SampleRatio float64 // Fraction of requests to trace, from 0 to 1
+}
+
+// Validate checks that the configuration is usable.
+//
+// Returns an error if the exporter is unknown or the sample ratio is out of range.
+func (c Config) Validate() error {
+	switch c.Exporter {
+	case ExporterNone, ExporterStdout, ExporterOTLP:
+	default:
+		return fmt.Errorf("unknown trace exporter: %s (expected none, stdout or otlp)", c.Exporter)
+	}
+	if c.SampleRatio < 0 || c.SampleRatio > 1 {
+		return fmt.Errorf("trace sample ratio must be between 0 and 1: %g", c.SampleRatio)
+	}
+	return nil
+}
+
+// Setup installs a global tracer provider for the configured exporter.
+//
+// With the none exporter, the global provider is left as the no-op default.
+//
+// Parameters:
+//   - ctx: context for creating the exporter
+//   - config: tracing settings
+//
+// Returns a function that flushes and shuts down the provider, or an error
+// if the exporter cannot be created.
+func Setup(ctx context.Context, config Config) (func(context.Context) error, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	var exporter sdktrace.SpanExporter
+	var err error
+	switch config.Exporter {
+	case ExporterNone:
+		return func(context.Context) error { return nil }, nil
+	case ExporterStdout:
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+	case ExporterOTLP:
+		var opts []otlptracehttp.Option
+		if config.Endpoint != "" {
+			opts = append(opts, otlptracehttp.WithEndpointURL(config.Endpoint))
+		}
+		exporter, err = otlptracehttp.New(ctx, opts...)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s trace exporter: %w", config.Exporter, err)
+	}
+
+	provider := NewProvider(sdktrace.WithBatcher(exporter), config.SampleRatio)
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
+
+// NewProvider creates a tracer provider for gospidertrap.
+//
+// Tests can pass sdktrace.WithSyncer with an in-memory exporter to inspect
+// spans as soon as they end.
+//
+// Parameters:
+//   - processor: option registering the span processor, e.g. sdktrace.WithBatcher(exporter)
+//   - sampleRatio: fraction of root spans to sample, from 0 to 1
+//
+// Returns a new TracerProvider.
+func NewProvider(processor sdktrace.TracerProviderOption, sampleRatio float64) *sdktrace.TracerProvider {
+	res, err := resource.Merge(resource.Default(),
+		resource.NewSchemaless(attribute.String("service.name", "gospidertrap")))
+	if err != nil {
+		res = resource.Default()
+	}
+	return sdktrace.NewTracerProvider(
+		processor,
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(sampleRatio))),
+	)
+}
+
+// Start starts a span using the global tracer provider.
+//
+// Parameters:
+//   - ctx: parent context
+//   - name: span name
+//   - opts: span options such as trace.WithAttributes
+//
+// Returns the context carrying the span and the span, which the caller must end.
+func Start(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	return otel.Tracer(instrumentationName).Start(ctx, name, opts...)
+}
+
+// End records err on the span (if non-nil) and ends it.
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// IPClass classifies a client IP address without revealing it.
+//
+// Returns one of loopback, private, link_local, public or invalid.
+func IPClass(ip string) string {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return "invalid"
+	}
+	addr = addr.Unmap()
+	switch {
+	case addr.IsLoopback():
+		return "loopback"
+	case addr.IsPrivate():
+		return "private"
+	case addr.IsLinkLocalUnicast():
+		return "link_local"
+	default:
+		return "public"
+	}
+}
+
+// PathDepth returns the number of non-empty segments in a URL path, so
+// "/" has depth 0 and "/a/b/" has depth 2.
+func PathDepth(path string) int {
+	depth := 0
+	for _, segment := range strings.Split(path, "/") {
+		if segment != "" {
+			depth++
+		}
+	}
+	return depth
+}
+
+// Method returns the HTTP method, or "_OTHER" for non-standard methods, so
+// that clients cannot create unbounded span names.
+func Method(method string) string {
+	switch method {
+	case "GET", "HEAD", "POST", "PUT", "PATCH", "DELETE", "CONNECT", "OPTIONS", "TRACE":
+		return method
+	default:
+		return "_OTHER"
+	}
+}
+
+// RequestAttributes returns the span attributes describing a trapped request.
+//
+// Parameters:
+//   - ip: client IP address
+//   - method: HTTP method
+//   - path: URL path
+//
+// Returns the attributes.
+func RequestAttributes(ip, method, path string) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		attribute.String("http.request.method", Method(method)),
+		attribute.String("client.ip_class", IPClass(ip)),
+		attribute.Int("url.path_depth", PathDepth(path)),
+	}
+}
@@ -0,0 +1,151 @@
+package tracing_test
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"github.com/rampantspark/gospidertrap/internal/content"
+	"github.com/rampantspark/gospidertrap/internal/handler"
+	"github.com/rampantspark/gospidertrap/internal/middleware"
+	"github.com/rampantspark/gospidertrap/internal/random"
+	"github.com/rampantspark/gospidertrap/internal/stats"
+	"github.com/rampantspark/gospidertrap/internal/tracing"
+)
+
+func TestIPClass(t *testing.T) {
+	tests := map[string]string{
+		"127.0.0.1":          "loopback",
+		"::1":                "loopback",
+		"10.1.2.3":           "private",
+		"::ffff:192.168.1.1": "private",
+		"fe80::1":            "link_local",
+		"203.0.113.7":        "public",
+		"2001:db8::1":        "public",
+		"not-an-ip":          "invalid",
+	}
+	for ip, want := range tests {
+		if got := tracing.IPClass(ip); got != want {
+			t.Errorf("IPClass(%q) = %q, want %q", ip, got, want)
+		}
+	}
+}
+
+func TestPathDepth(t *testing.T) {
+	tests := map[string]int{"": 0, "/": 0, "/a": 1, "/a/b/": 2, "//a//b/c": 3}
+	for path, want := range tests {
+		if got := tracing.PathDepth(path); got != want {
+			t.Errorf("PathDepth(%q) = %d, want %d", path, got, want)
+		}
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	tests := []struct {
+		config  tracing.Config
+		wantErr bool
+	}{
+		{tracing.Config{Exporter: tracing.ExporterNone, SampleRatio: 1}, false},
+		{tracing.Config{Exporter: tracing.ExporterOTLP, SampleRatio: 0.1}, false},
+		{tracing.Config{Exporter: "jaeger", SampleRatio: 1}, true},
+		{tracing.Config{Exporter: tracing.ExporterStdout, SampleRatio: 2}, true},
+	}
+	for _, tt := range tests {
+		if err := tt.config.Validate(); (err != nil) != tt.wantErr {
+			t.Errorf("Validate(%+v) error = %v, wantErr %v", tt.config, err, tt.wantErr)
+		}
+	}
+}
+
+// TestRequestSpans checks the span tree produced by a trapped request using
+// an in-memory exporter.
+func TestRequestSpans(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	provider := tracing.NewProvider(sdktrace.WithSyncer(exporter), 1)
+	otel.SetTracerProvider(provider)
+	t.Cleanup(func() { provider.Shutdown(t.Context()) })
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	db, err := stats.NewDatabase(filepath.Join(t.TempDir(), "stats.db"), logger)
+	if err != nil {
+		t.Fatalf("NewDatabase() error = %v", err)
+	}
+	defer db.Close()
+
+	manager := stats.NewManager(db, false, logger)
+	gen := content.NewGenerator(nil, "", "", random.NewSource("abc", 1))
+	h := handler.New(gen, manager, logger, 0)
+	srv := middleware.Trace(manager.GetClientIP)(http.HandlerFunc(h.Handle))
+
+	req := httptest.NewRequest(http.MethodGet, "/a/b/c", nil)
+	req.RemoteAddr = "10.0.0.5:1234"
+	srv.ServeHTTP(httptest.NewRecorder(), req)
+
+	spans := exporter.GetSpans()
+	byName := make(map[string]tracetest.SpanStub)
+	for _, s := range spans {
+		byName[s.Name] = s
+	}
+
+	root, ok := byName["HTTP GET"]
+	if !ok {
+		t.Fatalf("missing server span, got %v", spanNames(spans))
+	}
+	wantAttrs := map[attribute.Key]attribute.Value{
+		"client.ip_class":           attribute.StringValue("private"),
+		"url.path_depth":            attribute.IntValue(3),
+		"http.response.status_code": attribute.IntValue(200),
+	}
+	for _, kv := range root.Attributes {
+		if want, ok := wantAttrs[kv.Key]; ok {
+			if kv.Value != want {
+				t.Errorf("attribute %s = %v, want %v", kv.Key, kv.Value.Emit(), want.Emit())
+			}
+			delete(wantAttrs, kv.Key)
+		}
+	}
+	if len(wantAttrs) > 0 {
+		t.Errorf("server span missing attributes %v", wantAttrs)
+	}
+
+	parents := map[string]string{
+		"stats.RecordRequest":             "HTTP GET",
+		"stats.Database.RecordRequests":   "stats.RecordRequest",
+		"stats.Database lock wait":        "stats.Database.RecordRequests",
+		"sqlite INSERT request_log":       "stats.Database.RecordRequests",
+		"sqlite UPSERT ip_counts":         "stats.Database.RecordRequests",
+		"sqlite UPSERT user_agent_counts": "stats.Database.RecordRequests",
+		"sqlite COMMIT":                   "stats.Database.RecordRequests",
+		"handler.delay":                   "HTTP GET",
+		"content.GeneratePage":            "HTTP GET",
+	}
+	for name, parent := range parents {
+		span, ok := byName[name]
+		if !ok {
+			t.Errorf("missing span %q, got %v", name, spanNames(spans))
+			continue
+		}
+		if span.Parent.SpanID() != byName[parent].SpanContext.SpanID() {
+			t.Errorf("span %q parent is not %q", name, parent)
+		}
+		if span.SpanContext.TraceID() != root.SpanContext.TraceID() {
+			t.Errorf("span %q is not in the request trace", name)
+		}
+	}
+}
+
+func spanNames(spans tracetest.SpanStubs) []string {
+	names := make([]string, len(spans))
+	for i, s := range spans {
+		names[i] = s.Name
+	}
+	return names
+}
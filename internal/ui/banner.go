@@ -2,6 +2,9 @@ package ui
 
 import (
 	"fmt"
+	"net/url"
+	"sort"
+	"strings"
 	"time"
 )
 
@@ -20,7 +23,22 @@ type StartupInfo struct {
 	AdminLoginURL string
 	AdminURL      string
 	PersistMode   string
+	StatsWrites   string
+	Retention     string
 	RateLimit     string
+	AutoBan       string
+	GeoIP         string
+	BotVerify     string
+	Forms         string
+	Capture       string
+	Bait          string
+	Defense       string
+	Compression   string
+	Webhooks      string
+	Syslog        string
+	Replication   string
+	Metrics       string
+	Tracing       string
 	Wordlist      string
 	Template      string
 }
@@ -41,6 +59,19 @@ func PrintStartupInfo(info StartupInfo) {
 	fmt.Println("  SERVER")
 	fmt.Printf("     Port:            %s\n", info.Port)
 	fmt.Printf("     Rate Limiting:   %s\n", info.RateLimit)
+	fmt.Printf("     Auto-Ban:        %s\n", info.AutoBan)
+	fmt.Printf("     GeoIP:           %s\n", info.GeoIP)
+	fmt.Printf("     Bot Verify:      %s\n", info.BotVerify)
+	fmt.Printf("     Forms:           %s\n", info.Forms)
+	fmt.Printf("     Capture:         %s\n", info.Capture)
+	fmt.Printf("     Bait:            %s\n", info.Bait)
+	fmt.Printf("     Defense:         %s\n", info.Defense)
+	fmt.Printf("     Compression:     %s\n", info.Compression)
+	fmt.Printf("     Webhooks:        %s\n", info.Webhooks)
+	fmt.Printf("     Syslog:          %s\n", info.Syslog)
+	fmt.Printf("     Replication:     %s\n", info.Replication)
+	fmt.Printf("     Metrics:         %s\n", info.Metrics)
+	fmt.Printf("     Tracing:         %s\n", info.Tracing)
 	fmt.Println()
 
 	// Content Configuration
@@ -60,6 +91,12 @@ func PrintStartupInfo(info StartupInfo) {
 	// Persistence
 	fmt.Println("   PERSISTENCE")
 	fmt.Printf("     Mode:            %s\n", info.PersistMode)
+	if info.StatsWrites != "" {
+		fmt.Printf("     Writes:          %s\n", info.StatsWrites)
+	}
+	if info.Retention != "" {
+		fmt.Printf("     Retention:       %s\n", info.Retention)
+	}
 	fmt.Println()
 
 	// Admin Access
@@ -136,17 +173,232 @@ func BuildTemplateSummary(filename string, size int) string {
 }
 
 // BuildRateLimitSummary creates a summary string for rate limiting
-func BuildRateLimitSummary(requestsPerSec, burst int) string {
-	return fmt.Sprintf("%d req/sec (burst: %d)", requestsPerSec, burst)
+func BuildRateLimitSummary(requestsPerSec, burst int, key string) string {
+	return fmt.Sprintf("%d req/sec (burst: %d) per %s", requestsPerSec, burst, strings.ToUpper(key))
+}
+
+// BuildAutoBanSummary creates a summary string for auto-ban rules
+func BuildAutoBanSummary(rules int, ttl time.Duration, key string) string {
+	if rules == 0 {
+		return "Disabled"
+	}
+	return fmt.Sprintf("%d rule(s) per %s, ban TTL %s", rules, strings.ToUpper(key), ttl)
+}
+
+// BuildGeoIPSummary creates a summary string for the GeoIP databases
+func BuildGeoIPSummary(cityPath, asnPath string) string {
+	var parts []string
+	if cityPath != "" {
+		parts = append(parts, "countries from "+cityPath)
+	}
+	if asnPath != "" {
+		parts = append(parts, "networks from "+asnPath)
+	}
+	if len(parts) == 0 {
+		return "Disabled"
+	}
+	return strings.Join(parts, ", ")
+}
+
+// BuildBotVerifySummary creates a summary string for search engine crawler
+// verification
+func BuildBotVerifySummary(enabled, supported bool, ttl time.Duration) string {
+	if !enabled {
+		return "Disabled"
+	}
+	if !supported {
+		return "Not supported by this backend"
+	}
+	return fmt.Sprintf("Reverse DNS, results cached for %s", ttl)
+}
+
+// BuildFormsSummary creates a summary string for trap forms and form
+// submission capture
+func BuildFormsSummary(endpoint, forms string, honeytokens, supported bool) string {
+	if !supported {
+		return "Submissions not recorded by this backend"
+	}
+	if endpoint == "" {
+		return "No forms (no -e endpoint), submissions recorded"
+	}
+	summary := fmt.Sprintf("%s submitting to %s", strings.ReplaceAll(forms, ",", ", "), endpoint)
+	if honeytokens {
+		summary += " with honeytokens"
+	}
+	return summary + ", submissions recorded"
+}
+
+// BuildCaptureSummary creates a summary string for request capture
+func BuildCaptureSummary(enabled, supported bool, maxSize, patterns int) string {
+	if !enabled {
+		return "Disabled"
+	}
+	if !supported {
+		return "Not supported by this backend"
+	}
+	if patterns == 0 {
+		return fmt.Sprintf("Body samples up to %d bytes, no redaction", maxSize)
+	}
+	return fmt.Sprintf("Body samples up to %d bytes, %d redaction pattern(s)", maxSize, patterns)
+}
+
+// BuildBaitSummary creates a summary string for scanner bait
+func BuildBaitSummary(enabled bool, catalog string, baits int, supported bool) string {
+	if !enabled {
+		return "Disabled"
+	}
+	source := "built-in catalog"
+	if catalog != "" {
+		source = catalog
+	}
+	summary := fmt.Sprintf("%d bait(s) from %s", baits, source)
+	if !supported {
+		summary += ", hits not tagged by this backend"
+	}
+	return summary
+}
+
+// BuildDefenseSummary creates a summary string for the defense responses
+// served to banned clients
+func BuildDefenseSummary(actions map[string]string, bandwidth, maxActive int) string {
+	if len(actions) == 0 {
+		return "Disabled"
+	}
+	pairs := make([]string, 0, len(actions))
+	for rule, action := range actions {
+		pairs = append(pairs, rule+"="+action)
+	}
+	sort.Strings(pairs)
+	return fmt.Sprintf("%s, %d KB/s shared by up to %d client(s)", strings.Join(pairs, ", "), bandwidth>>10, maxActive)
+}
+
+// BuildCompressionSummary creates a summary string for trap page
+// compression
+func BuildCompressionSummary(encodings []string) string {
+	if len(encodings) == 0 {
+		return "Disabled, pages sent uncompressed"
+	}
+	return strings.Join(encodings, ", ") + " negotiated from Accept-Encoding"
+}
+
+// BuildWebhookSummary creates a summary string for webhook notifications
+func BuildWebhookSummary(targets int, signed bool) string {
+	if targets == 0 {
+		return "Disabled"
+	}
+	if signed {
+		return fmt.Sprintf("%d target(s), HMAC signed", targets)
+	}
+	return fmt.Sprintf("%d target(s), unsigned", targets)
+}
+
+// BuildSyslogSummary creates a summary string for syslog forwarding
+func BuildSyslogSummary(targets int, facility string) string {
+	if targets == 0 {
+		return "Disabled"
+	}
+	return fmt.Sprintf("%d target(s), facility %s", targets, facility)
+}
+
+// BuildReplicationSummary creates a summary string for replication between
+// trap instances
+func BuildReplicationSummary(node, collectorURL string, collector bool) string {
+	var parts []string
+	if collectorURL != "" {
+		// Hide credentials if the URL contains any
+		if u, err := url.Parse(collectorURL); err == nil {
+			collectorURL = u.Redacted()
+		}
+		parts = append(parts, fmt.Sprintf("pushing to %s as %s", collectorURL, node))
+	}
+	if collector {
+		parts = append(parts, "collecting at /replicate")
+	}
+	if len(parts) == 0 {
+		return "Disabled"
+	}
+	return strings.Join(parts, ", ")
+}
+
+// BuildMetricsSummary creates a summary string for the metrics endpoint
+func BuildMetricsSummary(enabled bool, addr string, authenticated bool) string {
+	if !enabled {
+		return "Disabled"
+	}
+	location := "/metrics on main port"
+	if addr != "" {
+		location = fmt.Sprintf("http://%s/metrics", addr)
+	}
+	if authenticated {
+		return location + " (bearer token)"
+	}
+	return location
+}
+
+// BuildTracingSummary creates a summary string for OpenTelemetry tracing
+func BuildTracingSummary(exporter, endpoint string, sampleRatio float64) string {
+	switch exporter {
+	case "", "none":
+		return "Disabled"
+	case "otlp":
+		if endpoint == "" {
+			endpoint = "default endpoint"
+		}
+		return fmt.Sprintf("OTLP to %s (sampling %g%%)", endpoint, sampleRatio*100)
+	default:
+		return fmt.Sprintf("%s (sampling %g%%)", exporter, sampleRatio*100)
+	}
 }
 
 // BuildPersistModeSummary creates a summary string for persistence mode
-func BuildPersistModeSummary(useFiles bool, dbPath, dataDir string) string {
+func BuildPersistModeSummary(useFiles bool, dbPath, dbURL, dataDir string) string {
 	if useFiles {
 		return fmt.Sprintf("File-based (legacy) - %s", dataDir)
 	}
+	if dbURL != "" {
+		// Hide the password if the URL contains one
+		if u, err := url.Parse(dbURL); err == nil {
+			dbURL = u.Redacted()
+		}
+		return fmt.Sprintf("PostgreSQL - %s", dbURL)
+	}
 	if dbPath != "" {
 		return fmt.Sprintf("SQLite database - %s", dbPath)
 	}
 	return "Disabled"
 }
+
+// BuildStatsWritesSummary creates a summary string for how requests are written
+// to storage. It returns an empty string when persistence is disabled.
+func BuildStatsWritesSummary(persistent, async bool, queueSize, batchSize int, flushInterval time.Duration, overflow string) string {
+	if !persistent {
+		return ""
+	}
+	if !async {
+		return "Synchronous"
+	}
+	return fmt.Sprintf("Batched (queue %d, batch %d, every %s, %s when full)", queueSize, batchSize, flushInterval, overflow)
+}
+
+// BuildRetentionSummary creates a summary string for request log retention.
+// It returns an empty string when there is no database.
+func BuildRetentionSummary(database bool, maxAge time.Duration, maxRows int, vacuumInterval time.Duration) string {
+	if !database {
+		return ""
+	}
+	var limits []string
+	if maxAge > 0 {
+		limits = append(limits, "max age "+maxAge.String())
+	}
+	if maxRows > 0 {
+		limits = append(limits, fmt.Sprintf("max %d rows", maxRows))
+	}
+	summary := "Unlimited"
+	if len(limits) > 0 {
+		summary = strings.Join(limits, ", ")
+	}
+	if vacuumInterval > 0 {
+		summary += fmt.Sprintf(" (vacuum every %s)", vacuumInterval)
+	}
+	return summary
+}
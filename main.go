@@ -14,27 +14,45 @@ package main
 import (
 	"bufio"
 	"context"
-	"encoding/json"
+	"crypto/tls"
+	"crypto/x509"
 	"flag"
 	"fmt"
+	"io"
 	"log/slog"
 	"net"
 	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
-	"sync"
 	"time"
 
 	"github.com/rampantspark/gospidertrap/internal/admin"
+	"github.com/rampantspark/gospidertrap/internal/bait"
+	"github.com/rampantspark/gospidertrap/internal/ban"
+	"github.com/rampantspark/gospidertrap/internal/botverify"
+	"github.com/rampantspark/gospidertrap/internal/capture"
+	"github.com/rampantspark/gospidertrap/internal/cli"
 	"github.com/rampantspark/gospidertrap/internal/content"
+	"github.com/rampantspark/gospidertrap/internal/defense"
+	"github.com/rampantspark/gospidertrap/internal/export"
+	"github.com/rampantspark/gospidertrap/internal/geoip"
 	"github.com/rampantspark/gospidertrap/internal/handler"
+	"github.com/rampantspark/gospidertrap/internal/honeytoken"
 	"github.com/rampantspark/gospidertrap/internal/logging"
+	"github.com/rampantspark/gospidertrap/internal/metrics"
 	"github.com/rampantspark/gospidertrap/internal/middleware"
+	"github.com/rampantspark/gospidertrap/internal/notify"
 	"github.com/rampantspark/gospidertrap/internal/random"
 	"github.com/rampantspark/gospidertrap/internal/ratelimit"
+	"github.com/rampantspark/gospidertrap/internal/replication"
 	"github.com/rampantspark/gospidertrap/internal/server"
+	"github.com/rampantspark/gospidertrap/internal/siem"
 	"github.com/rampantspark/gospidertrap/internal/stats"
+	"github.com/rampantspark/gospidertrap/internal/tracing"
 	"github.com/rampantspark/gospidertrap/internal/ui"
 )
 
@@ -59,276 +77,355 @@ const (
 	maxHeaderBytes      = 1 << 20 // 1 MB max header size
 	maxRequestBodyBytes = 1 << 20 // 1 MB max request body size
 
-
 	// Input validation limits
 	maxHTMLTemplateSize = 10 * 1024 * 1024 // 10 MB
 	maxWordlistFileSize = 50 * 1024 * 1024 // 50 MB
 	maxWordlistEntries  = 100000           // Maximum number of wordlist entries
 
 	// Persistence settings
-	defaultDataDir        = "data"
-	statsSaveInterval     = 5 * time.Minute // Save stats every 5 minutes
-	requestsLogFileName   = "requests.ndjson"
-	statsFileName         = "stats.json"
+	defaultDataDir = "data"
+	exportDirName  = "exports"
 
 	// Admin UI display settings
-	maxRecentRequestsDisplay    = 50  // Maximum number of recent requests to display in admin UI
-	maxUserAgentDisplayLength   = 50  // Maximum length of user agent to display
-	maxUserAgentTruncateLength  = 47  // Length to truncate user agent to (with "...")
-	topItemsDisplayCount        = 10  // Number of top items to display in charts and tables
+	maxRecentRequestsDisplay   = 50 // Maximum number of recent requests to display in admin UI
+	maxUserAgentDisplayLength  = 50 // Maximum length of user agent to display
+	maxUserAgentTruncateLength = 47 // Length to truncate user agent to (with "...")
+	topItemsDisplayCount       = 10 // Number of top items to display in charts and tables
 
 )
 
 // Config holds the application configuration and state.
 // It manages wordlists, HTML templates, server settings, and random number generation.
 type Config struct {
-	port         string              // Port number for the HTTP server
-	contentGen   *content.Generator  // HTML page generator
-	statsManager *stats.Manager      // Unified stats manager (database or file-based)
-	statsBackend *stats.Stats        // In-memory stats (for file-based mode only)
-	db           *stats.Database     // Database instance (for database mode only)
-	adminHandler *admin.Handler      // Admin UI handler
-	rateLimitReq int                 // Rate limit: requests per second
-	rateLimitBurst int               // Rate limit: burst size
-	dataDir      string              // Directory for persisting data files
-	dbPath       string              // Path to SQLite database file
-	logFile      *os.File            // File handle for NDJSON request log
-	logFileMu    sync.Mutex          // Mutex for thread-safe log file writes
-	logger       *slog.Logger        // Structured logger instance
-	saveCtx      context.Context     // Context for periodic stats saving
-	saveCancel   context.CancelFunc  // Cancel function for periodic stats saving
-	useHTTPS     bool                // Whether HTTPS is being used (affects cookie Secure flag)
-	trustProxy   bool                // Whether to trust X-Forwarded-For and X-Real-IP headers
-	useFiles     bool                // Whether to use file-based persistence (vs SQLite)
+	port                string                // Port number for the HTTP server
+	contentGen          *content.Generator    // HTML page generator
+	statsManager        *stats.Manager        // Unified stats manager (database or file-based)
+	statsBackend        *stats.Stats          // In-memory stats (when persistence is disabled)
+	db                  *stats.Database       // SQLite database instance (for SQLite mode only)
+	store               stats.Store           // Storage backend used by the stats manager
+	dbURL               string                // PostgreSQL connection URL (empty to use SQLite or files)
+	adminHandler        *admin.Handler        // Admin UI handler
+	rateLimitReq        int                   // Rate limit: requests per second
+	rateLimitBurst      int                   // Rate limit: burst size
+	dataDir             string                // Directory for persisting data files
+	dbPath              string                // Path to SQLite database file
+	logger              *slog.Logger          // Structured logger instance
+	useHTTPS            bool                  // Whether HTTPS is being used (affects cookie Secure flag)
+	trustProxy          bool                  // Whether to trust X-Forwarded-For and X-Real-IP headers
+	useFiles            bool                  // Whether to use file-based persistence (vs SQLite)
+	banHits             int                   // Auto-ban: trap pages allowed per window (0 disables)
+	banHitsWindow       time.Duration         // Auto-ban: window for counting trap pages
+	banLimited          int                   // Auto-ban: rate-limit rejections allowed per window (0 disables)
+	banLimitedWindow    time.Duration         // Auto-ban: window for counting rate-limit rejections
+	banScrapers         bool                  // Auto-ban: ban known scraper user agents on first hit
+	banTTL              time.Duration         // Auto-ban: duration of issued bans
+	defenseActions      []string              // RULE=ACTION pairs choosing the defense response for bans issued by a rule
+	defenseBombSizeMB   int                   // Defense: decompressed size of the gzip bomb in MB
+	defenseStreamSizeMB int                   // Defense: most MB an endless HTML stream sends
+	defenseMaxDuration  time.Duration         // Defense: longest a defense response is held open
+	defenseBandwidthKB  int                   // Defense: KB per second shared by all defense responses
+	defenseMaxActive    int                   // Defense: most defense responses served at once
+	exportInterval      time.Duration         // Firewall export: file rewrite interval (0 disables files)
+	exportMinHits       int                   // Firewall export: minimum hits for trapped IPs
+	exportMaxAge        time.Duration         // Firewall export: maximum age of trapped IPs (0 for no limit)
+	webhooks            []notify.Target       // Webhook targets for event notifications
+	webhookSecret       string                // Webhook HMAC signing secret
+	webhookEvents       string                // Comma-separated webhook event types (empty for all)
+	webhookThresholds   string                // Comma-separated request counts for ip_threshold events
+	syslogTargets       []siem.Target         // Syslog collectors for SIEM forwarding
+	syslogFacility      string                // Syslog facility name
+	syslogSeverity      string                // Comma-separated EVENT=SEVERITY overrides
+	syslogTLSCA         string                // PEM CA bundle for verifying TLS syslog collectors
+	logFormat           string                // Log output format: human, text or json
+	logLevel            string                // Minimum log level
+	logPath             string                // Path to application log file (empty for stdout only)
+	logMaxSizeMB        int                   // Application log size in MB before rotation
+	logMaxBackups       int                   // Number of rotated application logs to keep
+	appLog              *logging.RotatingFile // Application log file (nil if logging to stdout only)
+	metricsEnabled      bool                  // Serve Prometheus metrics at /metrics on the main listener
+	metricsAddr         string                // Separate listen address for /metrics (empty to use the main listener)
+	metricsToken        string                // Bearer token required to scrape /metrics (empty for none)
+	tracing             tracing.Config        // OpenTelemetry tracing settings
+	statsQueue          int                   // Asynchronous stats queue size (0 records synchronously)
+	statsBatch          int                   // Maximum requests written per stats transaction
+	statsFlush          time.Duration         // Maximum delay before queued requests are written
+	statsOverflow       string                // What to do when the stats queue is full: block or drop
+	retention           stats.RetentionPolicy // Request log retention limits
+	pruneInterval       time.Duration         // How often to prune and checkpoint the database
+	vacuumInterval      time.Duration         // How often to vacuum the database (0 disables)
+	fileSnapshot        time.Duration         // File mode: how often to write the stats snapshot
+	fileLogMaxSizeMB    int                   // File mode: request log size in MB before rotation (0 disables)
+	fileLogDaily        bool                  // File mode: rotate the request log daily
+	fileLogKeep         int                   // File mode: compressed request logs to keep (0 keeps all)
+	importMaxSizeMB     int                   // Largest admin import upload in MB (0 disables the endpoint)
+	replicateTo         string                // Collector base URL to push recorded requests to (empty disables)
+	replicateToken      string                // Shared secret sent to the collector
+	replicateInterval   time.Duration         // How often to push to the collector
+	replicateBatch      int                   // Maximum requests per push
+	nodeName            string                // Name this node reports to the collector
+	collectorToken      string                // Shared secret nodes must send to push here (empty disables collecting)
+	nodeStaleAfter      time.Duration         // How long a node may go without pushing before it is shown as stale
+	geoipCity           string                // Path to a GeoLite2 City or Country database (empty disables)
+	geoipASN            string                // Path to a GeoLite2 ASN database (empty disables)
+	botVerifyTTL        time.Duration         // How long crawler verification results are cached (0 disables verification)
+	forms               string                // Comma-separated kinds of trap form generated pages pick from
+	honeytokens         bool                  // Prefill trap forms with honeytokens
+	honeytokenDomain    string                // Domain of honeytoken email addresses
+	capture             bool                  // Record the method, query string and body sample of trap requests
+	captureMaxSize      int                   // Largest body sample recorded, in bytes
+	captureRedact       []string              // Redaction patterns for captured query strings and bodies ("none" disables)
+	bait                bool                  // Answer vulnerability scanner probes with fake responses
+	baitCatalog         string                // JSON file replacing the built-in bait catalog (empty for the built-in one)
+	compress            string                // Comma-separated content codings offered for trap pages, or none
+	rateLimitKey        string                // What rate limits are keyed by: ip or asn
+	banKey              string                // What auto-bans are keyed by: ip or asn
 }
 
 // newConfig creates and initializes a new Config instance with default values.
-// It initializes the random number generator with a time-based seed and sets up structured logging.
+// It initializes the random number generator with a time-based seed and sets up
+// default human-readable logging, which setupLogging replaces once flags are parsed.
 func newConfig() *Config {
-	ctx, cancel := context.WithCancel(context.Background())
-	logger := slog.New(logging.NewHumanReadableHandler(os.Stdout, &slog.HandlerOptions{
-		Level: slog.LevelInfo,
-		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
-			// Remove time attribute
-			if a.Key == "time" {
-				return slog.Attr{}
-			}
-			// Replace level attribute with custom values
-			if a.Key == "level" {
-				level, ok := a.Value.Any().(slog.Level)
-				if !ok {
-					return a
-				}
-				var levelStr string
-				switch level {
-				case slog.LevelDebug:
-					levelStr = "[%]"
-				case slog.LevelInfo:
-					levelStr = "[*]"
-				case slog.LevelWarn:
-					levelStr = "[?]"
-				case slog.LevelError:
-					levelStr = "[!]"
-				default:
-					levelStr = level.String()
-				}
-				return slog.Attr{Key: "level", Value: slog.StringValue(levelStr)}
-			}
-			return a
-		},
-	}))
+	logger := slog.New(logging.NewHandler(os.Stdout, logging.FormatHuman, slog.LevelInfo))
 	return &Config{
 		statsBackend: stats.NewStats(),
 		dataDir:      defaultDataDir,
 		logger:       logger,
-		saveCtx:      ctx,
-		saveCancel:   cancel,
 	}
 }
 
-
-// ensureDataDir creates the data directory if it doesn't exist.
+// setupLogging replaces the default logger according to the -log-* flags.
 //
-// Returns an error if the directory cannot be created.
-func (cfg *Config) ensureDataDir() error {
-	if cfg.dataDir == "" {
-		return nil // Persistence disabled
-	}
-	return os.MkdirAll(cfg.dataDir, 0750)
-}
-
-// openLogFile opens or creates the NDJSON log file for appending requests.
+// Logs always go to stdout; if a log file is configured they are also
+// appended to it, rotating when it exceeds the configured size.
 //
-// Returns an error if the file cannot be opened.
-func (cfg *Config) openLogFile() error {
-	if cfg.dataDir == "" {
-		return nil // Persistence disabled
-	}
-
-	logPath := filepath.Join(cfg.dataDir, requestsLogFileName)
-
-	// Validate path is within data directory
-	if err := validateDataDirPath(cfg.dataDir, logPath); err != nil {
-		return fmt.Errorf("invalid log file path: %w", err)
+// Returns an error if the format or level is invalid or the log file cannot
+// be opened.
+func (cfg *Config) setupLogging() error {
+	format, err := logging.ParseFormat(cfg.logFormat)
+	if err != nil {
+		return err
 	}
-
-	// #nosec G304 -- path validated by validateDataDirPath to prevent traversal
-	file, err := os.OpenFile(logPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	level, err := logging.ParseLevel(cfg.logLevel)
 	if err != nil {
-		return fmt.Errorf("failed to open log file: %w", err)
+		return err
 	}
-	cfg.logFile = file
-	return nil
-}
-
-// closeLogFile closes the NDJSON log file.
-func (cfg *Config) closeLogFile() {
-	if cfg.logFile != nil {
-		cfg.logFile.Close()
-		cfg.logFile = nil
+	if cfg.logMaxSizeMB < 0 || cfg.logMaxBackups < 0 {
+		return fmt.Errorf("log rotation settings must not be negative: log-max-size=%d, log-max-backups=%d", cfg.logMaxSizeMB, cfg.logMaxBackups)
 	}
-}
 
-// appendRequestToLog appends a request to the NDJSON log file.
-//
-// Parameters:
-//   - reqInfo: the request information to log
-func (cfg *Config) appendRequestToLog(reqInfo stats.RequestInfo) {
-	if cfg.dataDir == "" || cfg.logFile == nil {
-		return // Persistence disabled
+	var w io.Writer = os.Stdout
+	if cfg.logPath != "" {
+		cfg.appLog, err = logging.NewRotatingFile(cfg.logPath, int64(cfg.logMaxSizeMB)<<20, cfg.logMaxBackups)
+		if err != nil {
+			return err
+		}
+		w = io.MultiWriter(os.Stdout, cfg.appLog)
 	}
 
-	cfg.logFileMu.Lock()
-	defer cfg.logFileMu.Unlock()
+	cfg.logger = slog.New(logging.NewHandler(w, format, level))
+	return nil
+}
 
-	encoder := json.NewEncoder(cfg.logFile)
-	if err := encoder.Encode(reqInfo); err != nil {
-		// Log error but don't fail the request
-		cfg.logger.Warn("Failed to write to log file", "error", err)
-	} else {
-		// Flush to ensure data is written to disk
-		cfg.logFile.Sync()
+// closeAppLog closes the application log file if one is open.
+func (cfg *Config) closeAppLog() {
+	if cfg.appLog != nil {
+		cfg.appLog.Close()
 	}
 }
 
-// saveStats saves the aggregated statistics to a JSON file.
+// ensureDataDir creates the data directory if it doesn't exist.
 //
-// Returns an error if the file cannot be written.
-func (cfg *Config) saveStats() error {
+// Returns an error if the directory cannot be created.
+func (cfg *Config) ensureDataDir() error {
 	if cfg.dataDir == "" {
 		return nil // Persistence disabled
 	}
+	return os.MkdirAll(cfg.dataDir, 0750)
+}
 
-	cfg.statsBackend.Mu.RLock()
-	persisted := stats.PersistedStats{
-		StartTime:      cfg.statsBackend.StartTime,
-		TotalRequests:   cfg.statsBackend.TotalRequests,
-		IPCounts:       make(map[string]int),
-		UserAgents:     make(map[string]int),
-		RecentRequests: make([]stats.RequestInfo, len(cfg.statsBackend.RecentRequests)),
+// banRules builds the auto-ban rules from the command-line configuration.
+//
+// Rules with a zero threshold are omitted, so the default configuration
+// returns no rules and auto-banning is disabled.
+func (cfg *Config) banRules() []ban.Rule {
+	var rules []ban.Rule
+	if cfg.banHits > 0 {
+		rules = append(rules, ban.Rule{
+			Name:      "trap-hits",
+			Kind:      ban.KindTrapHits,
+			Threshold: cfg.banHits,
+			Window:    cfg.banHitsWindow,
+			TTL:       cfg.banTTL,
+		})
 	}
-	// Copy maps to avoid holding lock during I/O
-	for k, v := range cfg.statsBackend.IPCounts {
-		persisted.IPCounts[k] = v
+	if cfg.banLimited > 0 {
+		rules = append(rules, ban.Rule{
+			Name:      "rate-limited",
+			Kind:      ban.KindRateLimited,
+			Threshold: cfg.banLimited,
+			Window:    cfg.banLimitedWindow,
+			TTL:       cfg.banTTL,
+		})
 	}
-	for k, v := range cfg.statsBackend.UserAgents {
-		persisted.UserAgents[k] = v
+	if cfg.banScrapers {
+		rules = append(rules, ban.Rule{
+			Name: "known-scraper",
+			Kind: ban.KindKnownScraper,
+			TTL:  cfg.banTTL,
+		})
 	}
-	// Copy recent requests slice
-	copy(persisted.RecentRequests, cfg.statsBackend.RecentRequests)
-	cfg.statsBackend.Mu.RUnlock()
+	return rules
+}
 
-	statsPath := filepath.Join(cfg.dataDir, statsFileName)
-	data, err := json.MarshalIndent(persisted, "", "  ")
-	if err != nil {
-		return fmt.Errorf("failed to marshal stats: %w", err)
+// defenseConfig builds the defense configuration from the command-line
+// configuration.
+//
+// Returns the config, or an error if a -defense pair is malformed or names a
+// rule that is not enabled, a bomb is requested without a data directory to
+// generate it into, or a cap is out of range.
+func (cfg *Config) defenseConfig() (defense.Config, error) {
+	config := defense.Config{
+		Actions:     make(map[string]string),
+		BombSize:    int64(cfg.defenseBombSizeMB) << 20,
+		StreamSize:  int64(cfg.defenseStreamSizeMB) << 20,
+		MaxDuration: cfg.defenseMaxDuration,
+		Bandwidth:   cfg.defenseBandwidthKB << 10,
+		MaxActive:   cfg.defenseMaxActive,
 	}
-
-	if err := os.WriteFile(statsPath, data, 0600); err != nil {
-		return fmt.Errorf("failed to write stats file: %w", err)
+	rules := []string{ban.RuleManual}
+	for _, rule := range cfg.banRules() {
+		rules = append(rules, rule.Name)
+	}
+	for _, spec := range cfg.defenseActions {
+		rule, action, err := defense.ParseAction(spec)
+		if err != nil {
+			return config, err
+		}
+		if !slices.Contains(rules, rule) {
+			return config, fmt.Errorf("-defense rule %q is not enabled (enabled: %s)", rule, strings.Join(rules, ", "))
+		}
+		if action == defense.ActionBomb && cfg.dataDir == "" {
+			return config, fmt.Errorf("-defense %s requires a data directory to generate the bomb into", spec)
+		}
+		config.Actions[rule] = action
 	}
 
-	return nil
+	switch {
+	case cfg.defenseBombSizeMB <= 0 || config.BombSize > defense.MaxBombSize:
+		return config, fmt.Errorf("-defense-bomb-size must be between 1 and %d MB, got %d", defense.MaxBombSize>>20, cfg.defenseBombSizeMB)
+	case cfg.defenseStreamSizeMB <= 0:
+		return config, fmt.Errorf("-defense-stream-size must be positive, got %d", cfg.defenseStreamSizeMB)
+	case cfg.defenseMaxDuration <= 0:
+		return config, fmt.Errorf("-defense-max-duration must be positive, got %s", cfg.defenseMaxDuration)
+	case cfg.defenseBandwidthKB <= 0:
+		return config, fmt.Errorf("-defense-bandwidth must be positive, got %d", cfg.defenseBandwidthKB)
+	case cfg.defenseMaxActive <= 0:
+		return config, fmt.Errorf("-defense-max-active must be positive, got %d", cfg.defenseMaxActive)
+	}
+	return config, nil
 }
 
-// loadStats loads aggregated statistics from a JSON file if it exists.
+// networkKey returns the function that maps client IPs to the key named by
+// a -rate-limit-key or -ban-key flag.
 //
-// If the file doesn't exist, it returns nil (not an error).
-// Returns an error if the file exists but cannot be read or parsed.
-func (cfg *Config) loadStats() error {
-	if cfg.dataDir == "" {
-		return nil // Persistence disabled
+// Parameters:
+//   - flagName: the flag, for error messages
+//   - value: the flag value, ip or asn
+//   - geo: the GeoIP resolver (nil if no database is loaded)
+//
+// Returns nil for per-IP keys, or an error if the value is unknown or asn
+// is requested without an ASN database.
+func networkKey(flagName, value string, geo *geoip.Resolver) (func(ip string) string, error) {
+	switch value {
+	case "ip":
+		return nil, nil
+	case "asn":
+		if !geo.HasASN() {
+			return nil, fmt.Errorf("-%s asn requires -geoip-asn", flagName)
+		}
+		return geo.NetworkKey, nil
+	default:
+		return nil, fmt.Errorf("unknown -%s %q, want ip or asn", flagName, value)
 	}
+}
 
-	statsPath := filepath.Join(cfg.dataDir, statsFileName)
-
-	// Validate path is within data directory
-	if err := validateDataDirPath(cfg.dataDir, statsPath); err != nil {
-		return fmt.Errorf("invalid stats file path: %w", err)
+// notifyConfig builds the webhook notifier configuration from the
+// command-line configuration.
+//
+// Returns the notifier config and the ip_threshold event thresholds, or an
+// error if an event type or threshold is invalid.
+func (cfg *Config) notifyConfig() (notify.Config, []int, error) {
+	config := notify.Config{
+		Targets:    cfg.webhooks,
+		Secret:     cfg.webhookSecret,
+		MaxRetries: notify.DefaultMaxRetries,
 	}
 
-	// #nosec G304 -- path validated by validateDataDirPath to prevent traversal
-	data, err := os.ReadFile(statsPath)
-	if err != nil {
-		if os.IsNotExist(err) {
-			return nil // File doesn't exist yet, that's okay
+	for _, name := range strings.Split(cfg.webhookEvents, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
 		}
-		return fmt.Errorf("failed to read stats file: %w", err)
+		eventType, ok := notify.ParseEventType(name)
+		if !ok {
+			return config, nil, fmt.Errorf("unknown webhook event type: %s", name)
+		}
+		config.Events = append(config.Events, eventType)
 	}
 
-	var persisted stats.PersistedStats
-	if err := json.Unmarshal(data, &persisted); err != nil {
-		return fmt.Errorf("failed to unmarshal stats: %w", err)
+	var thresholds []int
+	for _, value := range strings.Split(cfg.webhookThresholds, ",") {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			continue
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 1 {
+			return config, nil, fmt.Errorf("invalid webhook threshold: %s (must be an integer > 1)", value)
+		}
+		thresholds = append(thresholds, n)
 	}
 
-	cfg.statsBackend.Mu.Lock()
-	defer cfg.statsBackend.Mu.Unlock()
+	return config, thresholds, nil
+}
 
-	// Restore stats, but keep the current StartTime for this session
-	cfg.statsBackend.TotalRequests = persisted.TotalRequests
-	cfg.statsBackend.IPCounts = persisted.IPCounts
-	cfg.statsBackend.UserAgents = persisted.UserAgents
+// siemConfig builds the syslog forwarder configuration from the
+// command-line configuration.
+//
+// Returns the forwarder config or an error if the facility, severity
+// mapping or CA bundle is invalid.
+func (cfg *Config) siemConfig() (siem.Config, error) {
+	config := siem.Config{Targets: cfg.syslogTargets}
 
-	// Restore recent requests, but limit to MaxRecentRequests
-	if len(persisted.RecentRequests) > cfg.statsBackend.MaxRecentRequests {
-		// Keep only the most recent requests
-		startIdx := len(persisted.RecentRequests) - cfg.statsBackend.MaxRecentRequests
-		cfg.statsBackend.RecentRequests = persisted.RecentRequests[startIdx:]
-	} else {
-		cfg.statsBackend.RecentRequests = persisted.RecentRequests
+	facility, err := siem.ParseFacility(cfg.syslogFacility)
+	if err != nil {
+		return config, err
 	}
+	config.Facility = facility
 
-	return nil
-}
-
-// startPeriodicSave starts a goroutine that periodically saves stats to disk.
-func (cfg *Config) startPeriodicSave() {
-	if cfg.dataDir == "" {
-		return // Persistence disabled
+	severities, err := siem.ParseSeverities(cfg.syslogSeverity)
+	if err != nil {
+		return config, err
 	}
+	config.Severities = severities
 
-	go func() {
-		ticker := time.NewTicker(statsSaveInterval)
-		defer ticker.Stop()
-
-		for {
-			select {
-			case <-ticker.C:
-				if err := cfg.saveStats(); err != nil {
-					cfg.logger.Warn("Failed to save stats periodically", "error", err)
-				}
-			case <-cfg.saveCtx.Done():
-				return
-			}
+	if cfg.syslogTLSCA != "" {
+		if err := validateFilePath(cfg.syslogTLSCA); err != nil {
+			return config, err
 		}
-	}()
-}
-
-// stopPeriodicSave stops the periodic stats saving goroutine.
-func (cfg *Config) stopPeriodicSave() {
-	if cfg.saveCancel != nil {
-		cfg.saveCancel()
+		// #nosec G304 -- path validated by validateFilePath to prevent traversal
+		pem, err := os.ReadFile(cfg.syslogTLSCA)
+		if err != nil {
+			return config, fmt.Errorf("failed to read syslog CA bundle: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return config, fmt.Errorf("no certificates found in syslog CA bundle: %s", cfg.syslogTLSCA)
+		}
+		config.TLSConfig = &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}
 	}
+
+	return config, nil
 }
 
 // getClientIP extracts the client IP address from the request.
@@ -376,22 +473,103 @@ func getClientIP(r *http.Request, trustProxy bool) string {
 // printUsage prints the command-line usage information to stdout.
 // It displays the program name, available flags, and their descriptions.
 func printUsage() {
-	fmt.Println("Usage:", os.Args[0], "[-p PORT] -a HTML_FILE -w WORDLIST_FILE [-e ENDPOINT] [-d DATA_DIR] [-db-path DB_FILE] [-use-files] [-rate-limit N] [-rate-burst N] [-https] [-trust-proxy]")
+	fmt.Println("Usage:", os.Args[0], "[-p PORT] -a HTML_FILE -w WORDLIST_FILE [-e ENDPOINT] [-forms LIST] [-honeytokens] [-honeytoken-domain DOMAIN] [-capture] [-capture-max-size BYTES] [-capture-redact REGEX] [-bait] [-bait-catalog FILE] [-compress LIST] [-d DATA_DIR] [-db-path DB_FILE] [-use-files] [-file-snapshot-interval DURATION] [-file-log-max-size MB] [-file-log-daily] [-file-log-keep N] [-db-url URL] [-stats-queue N] [-stats-batch N] [-stats-flush-interval DURATION] [-stats-overflow POLICY] [-retention-age DURATION] [-retention-rows N] [-prune-interval DURATION] [-vacuum-interval DURATION] [-import-max-size MB] [-replicate-to URL] [-replicate-token TOKEN] [-replicate-interval DURATION] [-replicate-batch N] [-node-name NAME] [-collector-token TOKEN] [-node-stale-after DURATION] [-rate-limit N] [-rate-burst N] [-rate-limit-key KEY] [-ban-hits N] [-ban-429 N] [-ban-scrapers] [-ban-ttl DURATION] [-ban-key KEY] [-defense RULE=ACTION] [-defense-bomb-size MB] [-defense-stream-size MB] [-defense-max-duration DURATION] [-defense-bandwidth KB] [-defense-max-active N] [-geoip-city FILE] [-geoip-asn FILE] [-bot-verify-ttl DURATION] [-export-interval DURATION] [-export-min-hits N] [-export-max-age DURATION] [-webhook [FORMAT=]URL] [-webhook-secret SECRET] [-webhook-events LIST] [-webhook-thresholds LIST] [-syslog [FORMAT=]PROTO://HOST:PORT] [-syslog-facility NAME] [-syslog-severity LIST] [-syslog-tls-ca FILE] [-log-format FORMAT] [-log-level LEVEL] [-log-file FILE] [-log-max-size MB] [-log-max-backups N] [-metrics] [-metrics-addr ADDR] [-metrics-token TOKEN] [-trace-exporter EXPORTER] [-trace-endpoint URL] [-trace-sample RATIO] [-https] [-trust-proxy]")
+	fmt.Println("      ", os.Args[0], "stats COMMAND [flags]   (query the stats database; run 'stats help' for commands)")
+	fmt.Println("      ", os.Args[0], "import -source NAME FILE...   (merge data from another instance into the stats database)")
 	fmt.Println()
 	fmt.Println("-p            Port to run the server on (default: 8000)")
 	fmt.Println("-a            HTML file input, replace <a href> links")
-	fmt.Println("-e            Endpoint to point form GET requests to (optional)")
+	fmt.Println("-e            Endpoint trap forms submit to (optional; no forms without it)")
+	fmt.Println("-forms        Comma-separated trap forms pages pick from: login,search,contact,comment (default: all)")
+	fmt.Println("-honeytokens  Prefill trap forms with unique fake emails, passwords and API keys, and alert when another IP submits them")
+	fmt.Println("-honeytoken-domain  Domain of honeytoken email addresses (default: example.com)")
+	fmt.Println("-capture      Record the method, query string and a redacted body sample of trap requests")
+	fmt.Println("-capture-max-size Largest body sample recorded by -capture in bytes (default: 4096)")
+	fmt.Println("-capture-redact   Regular expression redacted from captured query strings and bodies; only its first group if it has one (repeatable, default: password, secret, token and API key values, none disables)")
+	fmt.Println("-bait         Answer scanner probes such as /.env, /.git/config and /wp-login.php with fake responses full of honeytokens")
+	fmt.Println("-bait-catalog JSON file of baits replacing the built-in catalog (implies -bait)")
+	fmt.Println("-compress     Content codings trap pages are compressed with, in order of preference, or none (default: zstd,br,gzip)")
 	fmt.Println("-w            Wordlist to use for links")
 	fmt.Println("-d            Data directory for persistence (default: data, empty to disable)")
 	fmt.Println("-db-path      Path to SQLite database file (default: data/stats.db)")
 	fmt.Println("-use-files    Use legacy file-based persistence instead of SQLite")
+	fmt.Println("-file-snapshot-interval  File mode: how often to write the stats snapshot (default: 5m)")
+	fmt.Println("-file-log-max-size  File mode: request log size in MB before rotation (default: 100, 0 disables)")
+	fmt.Println("-file-log-daily     File mode: also rotate the request log each day (UTC)")
+	fmt.Println("-file-log-keep      File mode: number of compressed request logs to keep (default: 0, keeps all)")
+	fmt.Println("-db-url       PostgreSQL connection URL; stores stats in PostgreSQL instead of SQLite (optional)")
+	fmt.Println("-stats-queue  Queue size for asynchronous batched stats writes (default: 4096, 0 records synchronously)")
+	fmt.Println("-stats-batch  Maximum requests written per batch (default: 256)")
+	fmt.Println("-stats-flush-interval  Maximum time a queued request waits before being written (default: 500ms)")
+	fmt.Println("-stats-overflow   When the stats queue is full: block or drop (default: block)")
+	fmt.Println("-retention-age    Prune request log entries older than this, keeping daily totals (default: 0, no limit)")
+	fmt.Println("-retention-rows   Keep at most this many request log entries, keeping daily totals (default: 0, no limit)")
+	fmt.Println("-prune-interval   How often to apply retention and checkpoint the database (default: 1h)")
+	fmt.Println("-vacuum-interval  How often to vacuum the database (default: 24h, 0 disables)")
+	fmt.Println("-import-max-size  Largest upload accepted by the admin import endpoint in MB (default: 1024, 0 disables the endpoint)")
+	fmt.Println("-replicate-to Push recorded requests to the collector at this base URL, e.g. https://collector:8000 (requires SQLite)")
+	fmt.Println("-replicate-token    Shared secret for pushing to the collector (required with -replicate-to)")
+	fmt.Println("-replicate-interval How often to push to the collector (default: 5s)")
+	fmt.Println("-replicate-batch    Maximum requests per push (default: 500)")
+	fmt.Println("-node-name    Name this node reports to the collector (default: hostname)")
+	fmt.Println("-collector-token    Accept pushes from trap nodes at /replicate with this shared secret (requires SQLite)")
+	fmt.Println("-node-stale-after   Show a node as stale after this long without a push (default: 1m)")
 	fmt.Println("-rate-limit   Rate limit: requests per second per IP (default: 10)")
 	fmt.Println("-rate-burst   Rate limit: burst size per IP (default: 20)")
+	fmt.Println("-rate-limit-key   What rate limits apply to: ip or asn, which shares one limit per autonomous system (default: ip)")
+	fmt.Println("-ban-hits     Auto-ban IPs requesting more than N trap pages per -ban-hits-window (default: 0, disabled)")
+	fmt.Println("-ban-hits-window  Window for -ban-hits (default: 1m)")
+	fmt.Println("-ban-429      Auto-ban IPs rate limited more than N times per -ban-429-window (default: 0, disabled)")
+	fmt.Println("-ban-429-window   Window for -ban-429 (default: 1m)")
+	fmt.Println("-ban-scrapers Auto-ban IPs whose user agent matches a known scraper")
+	fmt.Println("-ban-ttl      Duration of auto-bans (default: 1h)")
+	fmt.Println("-ban-key      What auto-bans apply to: ip or asn, which bans the whole autonomous system (default: ip)")
+	fmt.Println("-defense      Answer clients banned by RULE with ACTION: block, bomb (gzip decompression bomb) or stream (endless HTML), e.g. known-scraper=bomb (repeatable, default: block)")
+	fmt.Println("-defense-bomb-size     Decompressed size of the gzip bomb in MB, generated once into DATA_DIR (default: 1024)")
+	fmt.Println("-defense-stream-size   Most MB an endless HTML stream sends (default: 64)")
+	fmt.Println("-defense-max-duration  Longest a bomb or stream is held open (default: 5m)")
+	fmt.Println("-defense-bandwidth     KB per second shared by all bombs and streams (default: 1024)")
+	fmt.Println("-defense-max-active    Most bombs and streams served at once; further banned clients are blocked (default: 16)")
+	fmt.Println("-geoip-city   GeoLite2 City or Country .mmdb file for annotating IPs with their country (optional)")
+	fmt.Println("-geoip-asn    GeoLite2 ASN .mmdb file for annotating IPs with their autonomous system (optional)")
+	fmt.Println("-bot-verify-ttl   How long reverse DNS checks of IPs claiming to be search engine crawlers are cached (default: 24h, 0 disables)")
+	fmt.Println("-export-interval  How often firewall export files in DATA_DIR/exports are rewritten (default: 1m, 0 disables)")
+	fmt.Println("-export-min-hits  Minimum trap hits for an IP to be exported (default: 1)")
+	fmt.Println("-export-max-age   Only export IPs seen within this duration (default: 0, no limit)")
+	fmt.Println("-webhook      Webhook URL for event notifications, optionally prefixed with slack=, discord= or generic= (repeatable)")
+	fmt.Println("-webhook-secret   Secret for HMAC-SHA256 webhook signatures (optional)")
+	fmt.Println("-webhook-events   Comma-separated event types to send: new_ip,new_user_agent,ip_threshold,ban,admin_login,honeytoken (default: all)")
+	fmt.Println("-webhook-thresholds  Comma-separated request counts that trigger ip_threshold events (default: 100,1000)")
+	fmt.Println("-syslog       Syslog collector as udp://, tcp:// or tls://HOST:PORT, optionally prefixed with syslog=, cef= or leef= (repeatable)")
+	fmt.Println("-syslog-facility  Syslog facility (default: local0)")
+	fmt.Println("-syslog-severity  Comma-separated EVENT=SEVERITY mapping for request and ban events (default: request=info,ban=warning)")
+	fmt.Println("-syslog-tls-ca    PEM CA bundle for verifying TLS syslog collectors (default: system roots)")
+	fmt.Println("-metrics      Serve Prometheus metrics at /metrics")
+	fmt.Println("-metrics-addr     Serve /metrics on a separate listener, e.g. 127.0.0.1:9100 (implies -metrics)")
+	fmt.Println("-metrics-token    Bearer token required to scrape /metrics (optional)")
+	fmt.Println("-trace-exporter   Trace exporter: none, stdout or otlp (default: none)")
+	fmt.Println("-trace-endpoint   OTLP HTTP endpoint URL (default: OTEL_EXPORTER_OTLP_ENDPOINT or http://localhost:4318)")
+	fmt.Println("-trace-sample     Fraction of requests to trace, from 0 to 1 (default: 1)")
+	fmt.Println("-log-format   Log format: human, text or json (default: human)")
+	fmt.Println("-log-level    Minimum log level: debug, info, warn or error (default: info)")
+	fmt.Println("-log-file     Also write logs to this file, rotating it by size (optional)")
+	fmt.Println("-log-max-size     Log file size in MB before rotation (default: 100, 0 disables rotation)")
+	fmt.Println("-log-max-backups  Number of rotated log files to keep (default: 5)")
 	fmt.Println("-https        Enable HTTPS mode (sets Secure flag on cookies)")
 	fmt.Println("-trust-proxy  Trust X-Forwarded-For and X-Real-IP headers (use when behind reverse proxy)")
 }
 
 func main() {
+	// Offline subcommands run instead of the server
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "stats":
+			os.Exit(cli.RunStats(os.Args[2:], os.Stdout, os.Stderr))
+		case "import":
+			os.Exit(cli.RunImport(os.Args[2:], os.Stdin, os.Stdout, os.Stderr))
+		}
+	}
+
 	// Print banner
 	ui.PrintBanner()
 
@@ -403,17 +581,121 @@ func main() {
 	flag.StringVar(&cfg.port, "p", defaultPort, "Port to run the server on")
 	flag.StringVar(&htmlFile, "a", "", "HTML file containing links to be replaced")
 	flag.StringVar(&wordlistFile, "w", "", "Wordlist file to use for links")
-	flag.StringVar(&endpoint, "e", "", "Endpoint to point form GET requests to")
+	flag.StringVar(&endpoint, "e", "", "Endpoint trap forms submit to")
+	flag.StringVar(&cfg.forms, "forms", "login,search,contact,comment", "Comma-separated trap forms pages pick from")
+	flag.BoolVar(&cfg.honeytokens, "honeytokens", false, "Prefill trap forms with honeytokens and alert when another IP submits them")
+	flag.StringVar(&cfg.honeytokenDomain, "honeytoken-domain", honeytoken.DefaultDomain, "Domain of honeytoken email addresses")
+	flag.BoolVar(&cfg.capture, "capture", false, "Record the method, query string and a redacted body sample of trap requests")
+	flag.IntVar(&cfg.captureMaxSize, "capture-max-size", capture.DefaultMaxBodySize, "Largest body sample recorded by -capture in bytes")
+	flag.Func("capture-redact", "Regular expression redacted from captured query strings and bodies, or none (repeatable)", func(value string) error {
+		cfg.captureRedact = append(cfg.captureRedact, value)
+		return nil
+	})
+	flag.BoolVar(&cfg.bait, "bait", false, "Answer vulnerability scanner probes with fake responses")
+	flag.StringVar(&cfg.baitCatalog, "bait-catalog", "", "JSON file of baits replacing the built-in catalog (implies -bait)")
+	flag.StringVar(&cfg.compress, "compress", strings.Join(handler.Encodings, ","), "Content codings trap pages are compressed with, in order of preference, or none")
 	flag.StringVar(&cfg.dataDir, "d", defaultDataDir, "Data directory for persistence (empty to disable)")
 	flag.StringVar(&cfg.dbPath, "db-path", "", "Path to SQLite database file (default: data/stats.db, uses SQLite by default)")
 	flag.BoolVar(&cfg.useFiles, "use-files", false, "Use legacy file-based persistence instead of SQLite")
+	flag.DurationVar(&cfg.fileSnapshot, "file-snapshot-interval", stats.DefaultSnapshotInterval, "File mode: how often to write the stats snapshot")
+	flag.IntVar(&cfg.fileLogMaxSizeMB, "file-log-max-size", 100, "File mode: request log size in MB before rotation (0 disables)")
+	flag.BoolVar(&cfg.fileLogDaily, "file-log-daily", false, "File mode: also rotate the request log each day (UTC)")
+	flag.IntVar(&cfg.fileLogKeep, "file-log-keep", 0, "File mode: number of compressed request logs to keep (0 keeps all)")
+	flag.StringVar(&cfg.dbURL, "db-url", "", "PostgreSQL connection URL, e.g. postgres://user@host/gospidertrap (uses PostgreSQL instead of SQLite)")
+	flag.IntVar(&cfg.statsQueue, "stats-queue", stats.DefaultQueueSize, "Queue size for asynchronous batched stats writes (0 records synchronously)")
+	flag.IntVar(&cfg.statsBatch, "stats-batch", stats.DefaultBatchSize, "Maximum requests written per batch")
+	flag.DurationVar(&cfg.statsFlush, "stats-flush-interval", stats.DefaultFlushInterval, "Maximum time a queued request waits before being written")
+	flag.StringVar(&cfg.statsOverflow, "stats-overflow", string(stats.OverflowBlock), "When the stats queue is full: block or drop")
+	flag.DurationVar(&cfg.retention.MaxAge, "retention-age", 0, "Prune request log entries older than this, keeping daily totals (0 for no limit)")
+	flag.IntVar(&cfg.retention.MaxRows, "retention-rows", 0, "Keep at most this many request log entries, keeping daily totals (0 for no limit)")
+	flag.DurationVar(&cfg.pruneInterval, "prune-interval", time.Hour, "How often to apply retention and checkpoint the database")
+	flag.DurationVar(&cfg.vacuumInterval, "vacuum-interval", 24*time.Hour, "How often to vacuum the database (0 disables)")
+	flag.IntVar(&cfg.importMaxSizeMB, "import-max-size", 1024, "Largest upload accepted by the admin import endpoint in MB (0 disables the endpoint)")
+	hostname, _ := os.Hostname()
+	flag.StringVar(&cfg.replicateTo, "replicate-to", "", "Push recorded requests to the collector at this base URL, e.g. https://collector:8000 (requires SQLite)")
+	flag.StringVar(&cfg.replicateToken, "replicate-token", "", "Shared secret for pushing to the collector")
+	flag.DurationVar(&cfg.replicateInterval, "replicate-interval", replication.DefaultInterval, "How often to push to the collector")
+	flag.IntVar(&cfg.replicateBatch, "replicate-batch", replication.DefaultBatchSize, "Maximum requests per push")
+	flag.StringVar(&cfg.nodeName, "node-name", hostname, "Name this node reports to the collector")
+	flag.StringVar(&cfg.collectorToken, "collector-token", "", "Accept pushes from trap nodes at /replicate with this shared secret (requires SQLite)")
+	flag.DurationVar(&cfg.nodeStaleAfter, "node-stale-after", replication.DefaultStaleAfter, "Show a node as stale after this long without a push")
 	flag.IntVar(&cfg.rateLimitReq, "rate-limit", 10, "Rate limit: requests per second per IP")
 	flag.IntVar(&cfg.rateLimitBurst, "rate-burst", 20, "Rate limit: burst size per IP")
+	flag.StringVar(&cfg.rateLimitKey, "rate-limit-key", "ip", "What rate limits apply to: ip or asn (asn requires -geoip-asn)")
+	flag.IntVar(&cfg.banHits, "ban-hits", 0, "Auto-ban IPs requesting more than N trap pages per window (0 disables)")
+	flag.DurationVar(&cfg.banHitsWindow, "ban-hits-window", time.Minute, "Window for -ban-hits")
+	flag.IntVar(&cfg.banLimited, "ban-429", 0, "Auto-ban IPs rate limited more than N times per window (0 disables)")
+	flag.DurationVar(&cfg.banLimitedWindow, "ban-429-window", time.Minute, "Window for -ban-429")
+	flag.BoolVar(&cfg.banScrapers, "ban-scrapers", false, "Auto-ban IPs whose user agent matches a known scraper")
+	flag.DurationVar(&cfg.banTTL, "ban-ttl", time.Hour, "Duration of auto-bans")
+	flag.StringVar(&cfg.banKey, "ban-key", "ip", "What auto-bans apply to: ip or asn (asn requires -geoip-asn)")
+	flag.Func("defense", "Answer clients banned by RULE with ACTION: block, bomb or stream (repeatable)", func(value string) error {
+		cfg.defenseActions = append(cfg.defenseActions, value)
+		return nil
+	})
+	flag.IntVar(&cfg.defenseBombSizeMB, "defense-bomb-size", defense.DefaultBombSize>>20, "Decompressed size of the gzip bomb in MB")
+	flag.IntVar(&cfg.defenseStreamSizeMB, "defense-stream-size", defense.DefaultStreamSize>>20, "Most MB an endless HTML stream sends")
+	flag.DurationVar(&cfg.defenseMaxDuration, "defense-max-duration", defense.DefaultMaxDuration, "Longest a bomb or stream is held open")
+	flag.IntVar(&cfg.defenseBandwidthKB, "defense-bandwidth", defense.DefaultBandwidth>>10, "KB per second shared by all bombs and streams")
+	flag.IntVar(&cfg.defenseMaxActive, "defense-max-active", defense.DefaultMaxActive, "Most bombs and streams served at once")
+	flag.StringVar(&cfg.geoipCity, "geoip-city", "", "GeoLite2 City or Country .mmdb file for annotating IPs with their country")
+	flag.StringVar(&cfg.geoipASN, "geoip-asn", "", "GeoLite2 ASN .mmdb file for annotating IPs with their autonomous system")
+	flag.DurationVar(&cfg.botVerifyTTL, "bot-verify-ttl", botverify.DefaultTTL, "How long reverse DNS checks of claimed search engine crawlers are cached (0 disables)")
+	flag.DurationVar(&cfg.exportInterval, "export-interval", time.Minute, "How often firewall export files are rewritten (0 disables)")
+	flag.IntVar(&cfg.exportMinHits, "export-min-hits", 1, "Minimum trap hits for an IP to be exported")
+	flag.DurationVar(&cfg.exportMaxAge, "export-max-age", 0, "Only export IPs seen within this duration (0 for no limit)")
+	flag.Func("webhook", "Webhook URL for event notifications, optionally prefixed with slack=, discord= or generic= (repeatable)", func(value string) error {
+		target, err := notify.ParseTarget(value)
+		if err != nil {
+			return err
+		}
+		cfg.webhooks = append(cfg.webhooks, target)
+		return nil
+	})
+	flag.StringVar(&cfg.webhookSecret, "webhook-secret", "", "Secret for HMAC-SHA256 webhook signatures")
+	flag.StringVar(&cfg.webhookEvents, "webhook-events", "", "Comma-separated event types to send (default: all)")
+	flag.StringVar(&cfg.webhookThresholds, "webhook-thresholds", "100,1000", "Comma-separated request counts that trigger ip_threshold events")
+	flag.Func("syslog", "Syslog collector as udp://, tcp:// or tls://HOST:PORT, optionally prefixed with syslog=, cef= or leef= (repeatable)", func(value string) error {
+		target, err := siem.ParseTarget(value)
+		if err != nil {
+			return err
+		}
+		cfg.syslogTargets = append(cfg.syslogTargets, target)
+		return nil
+	})
+	flag.StringVar(&cfg.syslogFacility, "syslog-facility", "local0", "Syslog facility")
+	flag.StringVar(&cfg.syslogSeverity, "syslog-severity", "request=info,ban=warning", "Comma-separated EVENT=SEVERITY mapping for request and ban events")
+	flag.StringVar(&cfg.syslogTLSCA, "syslog-tls-ca", "", "PEM CA bundle for verifying TLS syslog collectors (default: system roots)")
+	flag.BoolVar(&cfg.metricsEnabled, "metrics", false, "Serve Prometheus metrics at /metrics")
+	flag.StringVar(&cfg.metricsAddr, "metrics-addr", "", "Serve /metrics on a separate listener, e.g. 127.0.0.1:9100 (implies -metrics)")
+	flag.StringVar(&cfg.metricsToken, "metrics-token", "", "Bearer token required to scrape /metrics (optional)")
+	flag.StringVar(&cfg.tracing.Exporter, "trace-exporter", tracing.ExporterNone, "Trace exporter: none, stdout or otlp")
+	flag.StringVar(&cfg.tracing.Endpoint, "trace-endpoint", "", "OTLP HTTP endpoint URL for -trace-exporter otlp (default: OTEL_EXPORTER_OTLP_ENDPOINT or http://localhost:4318)")
+	flag.Float64Var(&cfg.tracing.SampleRatio, "trace-sample", 1, "Fraction of requests to trace, from 0 to 1")
+	flag.StringVar(&cfg.logFormat, "log-format", string(logging.FormatHuman), "Log format: human, text or json")
+	flag.StringVar(&cfg.logLevel, "log-level", "info", "Minimum log level: debug, info, warn or error")
+	flag.StringVar(&cfg.logPath, "log-file", "", "Also write logs to this file, rotating it by size")
+	flag.IntVar(&cfg.logMaxSizeMB, "log-max-size", 100, "Log file size in MB before rotation (0 disables rotation)")
+	flag.IntVar(&cfg.logMaxBackups, "log-max-backups", 5, "Number of rotated log files to keep")
 	flag.BoolVar(&cfg.useHTTPS, "https", false, "Enable HTTPS mode (sets Secure flag on cookies)")
 	flag.BoolVar(&cfg.trustProxy, "trust-proxy", false, "Trust X-Forwarded-For and X-Real-IP headers")
 	flag.Usage = printUsage
 	flag.Parse()
 
+	// Configure logging before anything else logs
+	if err := cfg.setupLogging(); err != nil {
+		ui.PrintError("Invalid logging configuration", err)
+		os.Exit(1)
+	}
+	defer cfg.closeAppLog()
+
+	// Configure tracing
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.tracing)
+	if err != nil {
+		ui.PrintError("Invalid tracing configuration", err)
+		os.Exit(1)
+	}
+
 	// Validate rate limiting parameters
 	if cfg.rateLimitReq <= 0 {
 		ui.PrintError("Rate limit must be positive", fmt.Errorf("rate-limit=%d", cfg.rateLimitReq))
@@ -429,8 +711,155 @@ func main() {
 		os.Exit(1)
 	}
 
-	// Set default database path if not specified and not using files
-	if cfg.dbPath == "" && !cfg.useFiles && cfg.dataDir != "" {
+	// Validate auto-ban parameters
+	if cfg.banHits < 0 || cfg.banLimited < 0 {
+		ui.PrintError("Auto-ban thresholds must not be negative",
+			fmt.Errorf("ban-hits=%d, ban-429=%d", cfg.banHits, cfg.banLimited))
+		os.Exit(1)
+	}
+	if cfg.banHitsWindow <= 0 || cfg.banLimitedWindow <= 0 || cfg.banTTL <= 0 {
+		ui.PrintError("Auto-ban windows and TTL must be positive",
+			fmt.Errorf("ban-hits-window=%s, ban-429-window=%s, ban-ttl=%s", cfg.banHitsWindow, cfg.banLimitedWindow, cfg.banTTL))
+		os.Exit(1)
+	}
+
+	// Validate defense responses; each must name a ban rule that can fire
+	defenseConfig, err := cfg.defenseConfig()
+	if err != nil {
+		ui.PrintError("Invalid defense configuration", err)
+		os.Exit(1)
+	}
+
+	// Validate asynchronous stats parameters
+	statsOverflow, err := stats.ParseOverflowPolicy(cfg.statsOverflow)
+	if err != nil {
+		ui.PrintError("Invalid stats overflow policy", err)
+		os.Exit(1)
+	}
+	if cfg.statsQueue < 0 || cfg.statsBatch <= 0 || cfg.statsFlush <= 0 {
+		ui.PrintError("Stats queue must not be negative and batch size and flush interval must be positive",
+			fmt.Errorf("stats-queue=%d, stats-batch=%d, stats-flush-interval=%s", cfg.statsQueue, cfg.statsBatch, cfg.statsFlush))
+		os.Exit(1)
+	}
+
+	// Validate retention parameters
+	if cfg.retention.MaxAge < 0 || cfg.retention.MaxRows < 0 || cfg.pruneInterval <= 0 || cfg.vacuumInterval < 0 {
+		ui.PrintError("Retention limits must not be negative and the prune interval must be positive",
+			fmt.Errorf("retention-age=%s, retention-rows=%d, prune-interval=%s, vacuum-interval=%s",
+				cfg.retention.MaxAge, cfg.retention.MaxRows, cfg.pruneInterval, cfg.vacuumInterval))
+		os.Exit(1)
+	}
+
+	// Validate import parameters
+	if cfg.importMaxSizeMB < 0 {
+		ui.PrintError("Import size limit must not be negative", fmt.Errorf("import-max-size=%d", cfg.importMaxSizeMB))
+		os.Exit(1)
+	}
+
+	// Validate replication parameters
+	if cfg.replicateTo != "" {
+		u, err := url.Parse(cfg.replicateTo)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			ui.PrintError("Collector URL must be an http or https URL", fmt.Errorf("replicate-to=%s", cfg.replicateTo))
+			os.Exit(1)
+		}
+		if cfg.replicateToken == "" {
+			ui.PrintError("Replication requires a shared secret", fmt.Errorf("-replicate-token is not set"))
+			os.Exit(1)
+		}
+		if !replication.ValidNodeName(cfg.nodeName) {
+			ui.PrintError("Node name must be 1-64 letters, digits, dots, dashes or underscores", fmt.Errorf("node-name=%q", cfg.nodeName))
+			os.Exit(1)
+		}
+		if cfg.replicateInterval <= 0 || cfg.replicateBatch <= 0 {
+			ui.PrintError("Replication interval and batch size must be positive",
+				fmt.Errorf("replicate-interval=%s, replicate-batch=%d", cfg.replicateInterval, cfg.replicateBatch))
+			os.Exit(1)
+		}
+	}
+	if cfg.nodeStaleAfter <= 0 {
+		ui.PrintError("Node stale threshold must be positive", fmt.Errorf("node-stale-after=%s", cfg.nodeStaleAfter))
+		os.Exit(1)
+	}
+
+	// Validate firewall export parameters
+	if cfg.exportInterval < 0 || cfg.exportMinHits < 0 || cfg.exportMaxAge < 0 {
+		ui.PrintError("Firewall export settings must not be negative",
+			fmt.Errorf("export-interval=%s, export-min-hits=%d, export-max-age=%s", cfg.exportInterval, cfg.exportMinHits, cfg.exportMaxAge))
+		os.Exit(1)
+	}
+
+	// Validate webhook parameters
+	notifyConfig, notifyThresholds, err := cfg.notifyConfig()
+	if err != nil {
+		ui.PrintError("Invalid webhook configuration", err)
+		os.Exit(1)
+	}
+
+	// Compile the request capture redaction patterns
+	captureConfig := capture.Config{MaxBodySize: cfg.captureMaxSize}
+	if cfg.capture {
+		patterns := cfg.captureRedact
+		if len(patterns) == 0 {
+			patterns = []string{capture.DefaultRedactPattern}
+		} else if len(patterns) == 1 && patterns[0] == "none" {
+			patterns = nil
+		}
+		captureConfig.Redact, err = capture.ParseRedactPatterns(patterns)
+		if err != nil {
+			ui.PrintError("Invalid -capture-redact", err)
+			os.Exit(1)
+		}
+		if cfg.captureMaxSize <= 0 {
+			ui.PrintError("Invalid -capture-max-size", fmt.Errorf("must be positive, got %d", cfg.captureMaxSize))
+			os.Exit(1)
+		}
+	}
+
+	// Load the bait catalog; a catalog file implies bait is enabled
+	baitEntries := bait.DefaultEntries
+	if cfg.baitCatalog != "" {
+		cfg.bait = true
+		baitEntries, err = bait.LoadEntries(cfg.baitCatalog)
+		if err != nil {
+			ui.PrintError("Invalid -bait-catalog", err)
+			os.Exit(1)
+		}
+	}
+
+	// Validate the content codings offered for trap pages
+	encodings, err := handler.ParseEncodings(cfg.compress)
+	if err != nil {
+		ui.PrintError("Invalid -compress", err)
+		os.Exit(1)
+	}
+
+	// A separate metrics listener implies metrics are enabled
+	if cfg.metricsAddr != "" {
+		cfg.metricsEnabled = true
+	}
+
+	// Validate syslog parameters
+	siemConfig, err := cfg.siemConfig()
+	if err != nil {
+		ui.PrintError("Invalid syslog configuration", err)
+		os.Exit(1)
+	}
+
+	// Validate file mode parameters
+	if cfg.fileSnapshot <= 0 || cfg.fileLogMaxSizeMB < 0 || cfg.fileLogKeep < 0 {
+		ui.PrintError("File snapshot interval must be positive and request log rotation settings must not be negative",
+			fmt.Errorf("file-snapshot-interval=%s, file-log-max-size=%d, file-log-keep=%d", cfg.fileSnapshot, cfg.fileLogMaxSizeMB, cfg.fileLogKeep))
+		os.Exit(1)
+	}
+
+	if cfg.dbURL != "" && (cfg.useFiles || cfg.dbPath != "") {
+		ui.PrintError("-db-url cannot be combined with -use-files or -db-path", nil)
+		os.Exit(1)
+	}
+
+	// Set default database path if not specified and not using files or PostgreSQL
+	if cfg.dbPath == "" && !cfg.useFiles && cfg.dbURL == "" && cfg.dataDir != "" {
 		cfg.dbPath = filepath.Join(cfg.dataDir, "stats.db")
 	}
 
@@ -472,6 +901,12 @@ func main() {
 	// Initialize content generator
 	randomSrc := random.NewSource(charSpace, time.Now().UnixNano())
 	cfg.contentGen = content.NewGenerator(wordlist, htmlTemplate, endpoint, randomSrc)
+	formKinds, err := content.ParseFormKinds(cfg.forms)
+	if err != nil {
+		ui.PrintError("Invalid -forms", err)
+		os.Exit(1)
+	}
+	cfg.contentGen.SetForms(formKinds)
 
 	// Setup persistence
 	if cfg.dataDir != "" {
@@ -481,25 +916,45 @@ func main() {
 		}
 	}
 
+	// In-memory stats are used in file mode and when persistence is disabled
+	cfg.store = stats.NewMemoryStore(cfg.statsBackend)
+
+	// Replication sender, only created for a SQLite node with -replicate-to
+	var sender *replication.Sender
+
 	if cfg.useFiles {
 		// Legacy file-based persistence
 		if cfg.dataDir != "" {
-			// Load existing stats if available
-			if err := cfg.loadStats(); err != nil {
-				// Not a critical error, just log it
-				cfg.logger.Debug("Failed to load existing stats", "error", err)
-			}
-
-			// Open log file for appending requests
-			if err := cfg.openLogFile(); err != nil {
-				ui.PrintError("Failed to open log file", err)
+			fileStore, err := stats.NewFileStore(stats.FileStoreConfig{
+				Dir:              cfg.dataDir,
+				SnapshotInterval: cfg.fileSnapshot,
+				RotateSize:       int64(cfg.fileLogMaxSizeMB) * 1024 * 1024,
+				RotateDaily:      cfg.fileLogDaily,
+				MaxArchives:      cfg.fileLogKeep,
+			}, cfg.logger)
+			if err != nil {
+				ui.PrintError("Failed to open file persistence", err)
 				os.Exit(1)
 			}
-			defer cfg.closeLogFile()
+			cfg.store = fileStore
+			defer func() {
+				if err := fileStore.Close(); err != nil {
+					cfg.logger.Warn("Failed to save final stats", "error", err)
+				}
+			}()
+		}
+	} else if cfg.dbURL != "" {
+		// PostgreSQL persistence, shareable between trap instances
+		pgStore, err := stats.NewPostgresStore(context.Background(), cfg.dbURL, cfg.logger)
+		if err != nil {
+			ui.PrintError("Failed to initialize PostgreSQL database", err)
+			os.Exit(1)
+		}
+		cfg.store = pgStore
+		defer pgStore.Close()
 
-			// Start periodic stats saving
-			cfg.startPeriodicSave()
-			defer cfg.stopPeriodicSave()
+		if cfg.retention.Enabled() {
+			cfg.logger.Warn("Request log retention is only supported with SQLite; ignoring -retention-age and -retention-rows")
 		}
 	} else if cfg.dbPath != "" {
 		// SQLite database persistence (default)
@@ -509,6 +964,7 @@ func main() {
 			os.Exit(1)
 		}
 		cfg.db = db
+		cfg.store = db
 		defer cfg.db.Close()
 
 		// Run migration from files if they exist
@@ -519,14 +975,134 @@ func main() {
 				cfg.logger.Debug("Migration from files failed", "error", err)
 			}
 		}
+
+		// Forget collectors that are no longer replicated to so they stop holding back retention
+		if err := db.ClearReplicationCursors(context.Background(), cfg.replicateTo); err != nil {
+			cfg.logger.Warn("Failed to clear replication cursors", "error", err)
+		}
+
+		// Push recorded requests to the collector, buffering them while it is unreachable
+		if cfg.replicateTo != "" {
+			sender = replication.NewSender(db, replication.SenderConfig{
+				URL:       cfg.replicateTo,
+				Token:     cfg.replicateToken,
+				Node:      cfg.nodeName,
+				Interval:  cfg.replicateInterval,
+				BatchSize: cfg.replicateBatch,
+			}, cfg.logger)
+			sender.Start()
+			defer sender.Stop()
+		}
+
+		// Start retention pruning and database maintenance
+		pruner := stats.NewPruner(db, stats.PrunerConfig{
+			Policy:         cfg.retention,
+			Interval:       cfg.pruneInterval,
+			VacuumInterval: cfg.vacuumInterval,
+		}, cfg.logger)
+		pruner.Start()
+		defer pruner.Stop()
+	}
+
+	if cfg.replicateTo != "" && sender == nil {
+		ui.PrintError("Replication requires the SQLite backend", fmt.Errorf("-replicate-to is set without a SQLite database"))
+		os.Exit(1)
 	}
 
 	// Create stats manager with appropriate backend
-	cfg.statsManager = stats.NewManager(cfg.db, cfg.statsBackend, cfg.trustProxy, cfg.logger)
+	cfg.statsManager = stats.NewManager(cfg.store, cfg.trustProxy, cfg.logger)
+
+	// Load the GeoIP databases and annotate IPs recorded without them
+	var geo *geoip.Resolver
+	if cfg.geoipCity != "" || cfg.geoipASN != "" {
+		geo, err = geoip.NewResolver(cfg.geoipCity, cfg.geoipASN)
+		if err != nil {
+			ui.PrintError("Failed to load GeoIP databases", err)
+			os.Exit(1)
+		}
+		defer geo.Close()
+
+		if !cfg.statsManager.SetGeoIP(geo) {
+			cfg.logger.Warn("GeoIP annotation is not supported by the PostgreSQL backend; only -rate-limit-key and -ban-key use the databases")
+		}
+		if cfg.db != nil {
+			annotated, err := cfg.db.AnnotateIPs(context.Background())
+			if err != nil {
+				cfg.logger.Warn("Failed to annotate recorded IPs", "error", err)
+			} else if annotated > 0 {
+				cfg.logger.Info("Annotated recorded IPs with GeoIP data", "ips", annotated)
+			}
+		}
+	}
+	rateLimitKey, err := networkKey("rate-limit-key", cfg.rateLimitKey, geo)
+	if err != nil {
+		ui.PrintError("Invalid rate limit configuration", err)
+		os.Exit(1)
+	}
+	banKey, err := networkKey("ban-key", cfg.banKey, geo)
+	if err != nil {
+		ui.PrintError("Invalid auto-ban configuration", err)
+		os.Exit(1)
+	}
 
 	// Create rate limiter
 	rateLimiter := ratelimit.NewLimiter(cfg.rateLimitReq, cfg.rateLimitBurst)
 	defer rateLimiter.Stop()
+	if rateLimitKey != nil {
+		rateLimiter.SetKeyFunc(rateLimitKey)
+	}
+
+	// Create metrics; series are always updated but only served with -metrics
+	appMetrics := metrics.New()
+	appMetrics.Registry.NewGaugeFunc("gospidertrap_rate_limiter_tracked_ips",
+		"IP addresses currently tracked by the rate limiter.",
+		func() float64 { return float64(rateLimiter.Stats()) })
+	appMetrics.Registry.NewGaugeFunc("gospidertrap_unique_ips",
+		"Distinct client IP addresses recorded.",
+		func() float64 {
+			ips, _ := cfg.statsManager.GetUniqueCounts(context.Background())
+			return float64(ips)
+		})
+	appMetrics.Registry.NewGaugeFunc("gospidertrap_unique_user_agents",
+		"Distinct user agents recorded.",
+		func() float64 {
+			_, userAgents := cfg.statsManager.GetUniqueCounts(context.Background())
+			return float64(userAgents)
+		})
+	switch store := cfg.store.(type) {
+	case *stats.Database:
+		store.Instrument(appMetrics.DBWriteSeconds, appMetrics.DBWriteErrors)
+	case *stats.PostgresStore:
+		store.Instrument(appMetrics.DBWriteSeconds, appMetrics.DBWriteErrors)
+	}
+
+	// Create ban list and auto-ban rules engine
+	banList := ban.NewList()
+	defer banList.Stop()
+	banEngine := ban.NewEngine(banList, cfg.banRules(), cfg.logger)
+	defer banEngine.Stop()
+	if banKey != nil {
+		banEngine.SetKeyFunc(banKey)
+	}
+
+	// Network bans, issued by -ban-key asn or from the admin UI, also block
+	// the addresses in the network
+	var networkOf func(ip string) string
+	if geo.HasASN() {
+		networkOf = geo.NetworkKey
+	}
+
+	// Answer clients banned by rules with a defense action with bombs or
+	// endless streams instead of rejecting them
+	var defender *defense.Defender
+	if len(defenseConfig.Actions) > 0 {
+		defender, err = defense.NewDefender(defenseConfig, cfg.dataDir, banList, networkOf, cfg.logger)
+		if err != nil {
+			ui.PrintError("Failed to set up defense responses", err)
+			os.Exit(1)
+		}
+		defender.Instrument(appMetrics.DefenseResponses, appMetrics.DefenseActive)
+	}
 
 	// Create and validate server configuration
 	serverConfig := &server.Config{
@@ -547,7 +1123,88 @@ func main() {
 		ui.PrintError("Failed to create admin authenticator", err)
 		os.Exit(1)
 	}
-	cfg.adminHandler = admin.NewHandler(auth, cfg.statsManager, cfg.logger)
+	cfg.adminHandler = admin.NewHandler(auth, cfg.statsManager, banList, cfg.logger)
+	cfg.adminHandler.AddLoginHook(func(r *http.Request, success bool) {
+		if !success {
+			appMetrics.LoginFailures.Inc()
+		}
+	})
+
+	// Accept pushes from trap nodes and show their health in the admin UI
+	var collector *replication.Collector
+	if cfg.collectorToken != "" {
+		importer, ok := cfg.store.(stats.Importer)
+		if !ok {
+			ui.PrintError("Collecting from trap nodes requires the SQLite backend", fmt.Errorf("-collector-token is set without a SQLite database"))
+			os.Exit(1)
+		}
+		collector = replication.NewCollector(importer, cfg.collectorToken, cfg.nodeStaleAfter, cfg.logger)
+		cfg.adminHandler.SetNodeStatus(collector.Nodes)
+	}
+
+	// Create firewall exporter (files are only written when persistence is enabled)
+	var exportDir string
+	if cfg.dataDir != "" && cfg.exportInterval > 0 {
+		exportDir = filepath.Join(cfg.dataDir, exportDirName)
+		if err := os.MkdirAll(exportDir, 0750); err != nil {
+			ui.PrintError("Failed to create export directory", err)
+			os.Exit(1)
+		}
+	}
+	exporter := export.NewExporter(banList, cfg.statsManager, exportDir,
+		export.Filter{MinHits: cfg.exportMinHits, MaxAge: cfg.exportMaxAge},
+		cfg.exportInterval, cfg.logger)
+	if geo.HasASN() {
+		exporter.SetNetworkPrefixes(geo.Prefixes)
+	}
+	exporter.Start()
+	defer exporter.Stop()
+
+	// Create webhook notifier and subscribe it to stats, bans and admin logins
+	notifier := notify.New(notifyConfig, cfg.logger)
+	defer notifier.Stop()
+	if len(notifyConfig.Targets) > 0 {
+		cfg.statsManager.AddRecordHook(notifier.RecordHook(notifyThresholds))
+		banList.AddBanHook(notifier.BanHook())
+		cfg.adminHandler.AddLoginHook(notifier.LoginHook(cfg.statsManager.GetClientIP))
+	}
+
+	// Create syslog forwarder and subscribe it to trapped requests and bans
+	forwarder := siem.New(siemConfig, cfg.logger)
+	defer forwarder.Stop()
+	if forwarder.Enabled() {
+		cfg.statsManager.AddRecordHook(forwarder.RecordHook())
+		banList.AddBanHook(forwarder.BanHook())
+	}
+
+	// Verify IPs claiming to be search engine crawlers with reverse DNS
+	botStore, botVerifySupported := cfg.store.(stats.BotVerificationStore)
+	if cfg.botVerifyTTL > 0 && botVerifySupported {
+		verifier := botverify.NewVerifier(botverify.Config{TTL: cfg.botVerifyTTL}, net.DefaultResolver, botStore, cfg.logger)
+		defer verifier.Stop()
+		cfg.statsManager.AddRecordHook(verifier.RecordHook())
+	} else if cfg.botVerifyTTL > 0 {
+		cfg.logger.Warn("Search engine crawler verification is not supported by the PostgreSQL backend")
+	}
+
+	// Start asynchronous stats recording once every record hook is registered
+	var recorder *stats.Recorder
+	_, inMemory := cfg.store.(*stats.MemoryStore)
+	if cfg.statsQueue > 0 && !inMemory {
+		recorder = cfg.statsManager.StartRecorder(stats.RecorderConfig{
+			QueueSize:     cfg.statsQueue,
+			BatchSize:     cfg.statsBatch,
+			FlushInterval: cfg.statsFlush,
+			Overflow:      statsOverflow,
+		})
+	}
+	if recorder != nil {
+		defer recorder.Stop()
+		recorder.Instrument(appMetrics.StatsDropped)
+		appMetrics.Registry.NewGaugeFunc("gospidertrap_stats_queue_length",
+			"Requests waiting to be written to the database.",
+			func() float64 { return float64(recorder.QueueLength()) })
+	}
 
 	// Create request handler
 	requestHandler := handler.New(
@@ -556,24 +1213,54 @@ func main() {
 		cfg.logger,
 		time.Duration(delayMilliseconds)*time.Millisecond,
 	)
+	requestHandler.Instrument(appMetrics.GenerationSeconds, appMetrics.TarpitActive)
+	requestHandler.SetCompression(encodings)
+
+	// Record methods, query strings and body samples of trap requests
+	_, captureSupported := cfg.store.(stats.CaptureStore)
+	if cfg.capture && captureSupported {
+		requestHandler.SetCapture(capture.NewCapturer(captureConfig, cfg.store.(stats.CaptureStore), cfg.logger))
+	} else if cfg.capture {
+		cfg.logger.Warn("Request capture is not supported by the PostgreSQL backend")
+	}
 
-	// Create wrapper for NDJSON logging (file mode only)
-	handleRequest := func(w http.ResponseWriter, r *http.Request) {
-		// Append to NDJSON log file if using file-based persistence
-		if cfg.useFiles && cfg.logFile != nil {
-			ip := cfg.statsManager.GetClientIP(r)
-			userAgent := r.Header.Get("User-Agent")
-			if userAgent == "" {
-				userAgent = "Unknown"
-			}
-			reqInfo := stats.RequestInfo{
-				IP:        ip,
-				UserAgent: userAgent,
-				Path:      r.URL.Path,
-				Timestamp: time.Now(),
-			}
-			cfg.appendRequestToLog(reqInfo)
+	// Capture form submissions and issue honeytokens
+	formStore, formsSupported := cfg.store.(stats.FormStore)
+	var issuer bait.Issuer
+	if formsSupported {
+		tracker := honeytoken.NewTracker(honeytoken.Config{Issue: cfg.honeytokens, Domain: cfg.honeytokenDomain}, formStore, cfg.logger)
+		if len(notifyConfig.Targets) > 0 {
+			tracker.AddUseHook(notifier.HoneytokenHook())
 		}
+		requestHandler.SetForms(tracker)
+		issuer = tracker
+	} else if cfg.honeytokens {
+		cfg.logger.Warn("Honeytokens and form capture are not supported by the PostgreSQL backend")
+	}
+
+	// Answer vulnerability scanner probes with fake responses
+	_, baitSupported := cfg.store.(stats.BaitReporter)
+	if cfg.bait {
+		catalog, err := bait.NewCatalog(baitEntries, bait.Config{Domain: cfg.honeytokenDomain}, issuer, cfg.logger)
+		if err != nil {
+			ui.PrintError("Invalid bait catalog", err)
+			os.Exit(1)
+		}
+		requestHandler.SetBait(catalog)
+		cfg.adminHandler.SetBaits(catalog.Entries())
+		if !baitSupported {
+			cfg.logger.Warn("Bait hits are not tagged and bait honeytokens are not recorded by the PostgreSQL backend")
+		}
+	}
+
+	if defender != nil {
+		requestHandler.SetDefense(defender)
+	}
+
+	// Create wrapper for auto-ban observation
+	handleRequest := func(w http.ResponseWriter, r *http.Request) {
+		banEngine.ObserveHit(cfg.statsManager.GetClientIP(r), r.Header.Get("User-Agent"))
+
 		// Handle the request
 		requestHandler.Handle(w, r)
 	}
@@ -583,19 +1270,120 @@ func main() {
 	adminPath := cfg.adminHandler.GetPath()
 	mux.HandleFunc(adminPath+"/login", cfg.adminHandler.HandleLogin)
 	mux.HandleFunc(adminPath+"/data", cfg.adminHandler.HandleChartData)
+	mux.HandleFunc(adminPath+"/events", cfg.adminHandler.HandleEvents)
+	mux.HandleFunc(adminPath+"/assets/", cfg.adminHandler.HandleAsset)
+	mux.HandleFunc(adminPath+"/bans/revoke", cfg.adminHandler.HandleRevokeBan)
+	mux.HandleFunc(adminPath+"/bans/add", cfg.adminHandler.HandleBan)
+	mux.HandleFunc(adminPath+"/bans/allow", cfg.adminHandler.HandleAllow)
+	mux.HandleFunc(adminPath+"/bans/disallow", cfg.adminHandler.HandleDisallow)
+	mux.HandleFunc(adminPath+"/logout", cfg.adminHandler.HandleLogout)
+	mux.HandleFunc(adminPath+"/requests", cfg.adminHandler.HandleRequests)
+	mux.HandleFunc(adminPath+"/ips", cfg.adminHandler.HandleIPs)
+	mux.HandleFunc(adminPath+"/ips/", cfg.adminHandler.HandleIPDetail)
+	mux.HandleFunc(adminPath+"/useragents", cfg.adminHandler.HandleUserAgents)
+	mux.HandleFunc(adminPath+"/useragents/view", cfg.adminHandler.HandleUserAgentDetail)
+	mux.HandleFunc(adminPath+"/sessions", cfg.adminHandler.HandleSessions)
+	mux.HandleFunc(adminPath+"/bots", cfg.adminHandler.HandleBots)
+	mux.HandleFunc(adminPath+"/forms", cfg.adminHandler.HandleForms)
+	mux.HandleFunc(adminPath+"/captures", cfg.adminHandler.HandleCaptures)
+	mux.HandleFunc(adminPath+"/captures/", cfg.adminHandler.HandleCaptureDetail)
+	mux.HandleFunc(adminPath+"/bait", cfg.adminHandler.HandleBait)
+	mux.HandleFunc(adminPath+"/api/bots", cfg.adminHandler.HandleBotsAPI)
+	mux.HandleFunc(adminPath+"/settings", cfg.adminHandler.HandleSettings)
+	mux.Handle(adminPath+"/export/", cfg.adminHandler.RequireAuth(exporter))
+	if cfg.importMaxSizeMB > 0 {
+		mux.HandleFunc(adminPath+"/import", cfg.adminHandler.HandleImport)
+	}
 	mux.HandleFunc(adminPath, cfg.adminHandler.HandleUI)
+	if cfg.metricsEnabled && cfg.metricsAddr == "" {
+		mux.Handle("/metrics", appMetrics.Registry.Handler(cfg.metricsToken))
+	}
+	if collector != nil {
+		mux.Handle(replication.PushPath, collector)
+	}
 	mux.HandleFunc("/", handleRequest)
 
+	// Classify requests for the requests counter
+	classifyRoute := func(r *http.Request) string {
+		switch {
+		case strings.HasPrefix(r.URL.Path, adminPath):
+			return metrics.RouteAdmin
+		case r.URL.Path == replication.PushPath && collector != nil:
+			return metrics.RouteReplication
+		case r.URL.Path == "/metrics" && cfg.metricsEnabled && cfg.metricsAddr == "":
+			return metrics.RouteMetrics
+		default:
+			return metrics.RouteTrap
+		}
+	}
+	// Imports may upload whole databases and pushes carry request batches;
+	// everything else gets the default limit
+	bodyLimit := func(r *http.Request) int64 {
+		if cfg.importMaxSizeMB > 0 && r.URL.Path == adminPath+"/import" {
+			return int64(cfg.importMaxSizeMB) << 20
+		}
+		if collector != nil && r.URL.Path == replication.PushPath {
+			return replication.MaxPushBytes
+		}
+		return maxRequestBodyBytes
+	}
+	// Only trap pages answer diverted clients; the admin UI, metrics and
+	// replication endpoints reject them like any other ban
+	var divert func(*http.Request, ban.Ban) bool
+	if defender != nil {
+		divert = func(r *http.Request, b ban.Ban) bool {
+			return classifyRoute(r) == metrics.RouteTrap && defender.Diverts(b)
+		}
+	}
+	// An authenticated admin is never locked out by a ban, such as one the
+	// known-scraper rule issued for the operator's curl
+	exemptBan := func(r *http.Request) bool {
+		return strings.HasPrefix(r.URL.Path, adminPath) && cfg.adminHandler.IsAuthenticated(r)
+	}
+	onRateLimited := func(ip string) {
+		appMetrics.RateLimited.Inc()
+		banEngine.ObserveRateLimited(ip)
+	}
+
 	// Apply middleware stack (order matters: outermost first)
-	// 1. Panic recovery - catch all panics
-	// 2. Request body size limit - prevent memory exhaustion
-	// 3. Rate limiting - prevent abuse (applies to ALL routes including admin)
-	httpHandler := middleware.RecoverPanic(cfg.logger)(
-		middleware.LimitRequestBody(maxRequestBodyBytes)(
-			middleware.RateLimit(rateLimiter, cfg.statsManager.GetClientIP)(mux),
+	// 1. Request counting - count every response, including rejections and panics
+	// 2. Tracing - start a server span covering the rest of the stack
+	// 3. Panic recovery - catch all panics
+	// 4. Request body size limit - prevent memory exhaustion
+	// 5. Ban list - reject banned IPs before they consume rate limit tokens,
+	//    except for authenticated admin requests
+	// 6. Rate limiting - prevent abuse (applies to ALL routes including admin)
+	httpHandler := middleware.CountRequests(appMetrics.Requests, classifyRoute)(
+		middleware.Trace(cfg.statsManager.GetClientIP)(
+			middleware.RecoverPanic(cfg.logger)(
+				middleware.LimitRequestBodyFunc(bodyLimit)(
+					middleware.BlockBanned(banList, cfg.statsManager.GetClientIP, networkOf, divert, exemptBan)(
+						middleware.RateLimit(rateLimiter, cfg.statsManager.GetClientIP, onRateLimited)(mux),
+					),
+				),
+			),
 		),
 	)
 
+	// Serve metrics on a separate listener if requested
+	var metricsServer *http.Server
+	if cfg.metricsAddr != "" {
+		metricsMux := http.NewServeMux()
+		metricsMux.Handle("/metrics", appMetrics.Registry.Handler(cfg.metricsToken))
+		metricsServer = &http.Server{
+			Addr:              cfg.metricsAddr,
+			Handler:           metricsMux,
+			ReadHeaderTimeout: readTimeoutSeconds * time.Second,
+			WriteTimeout:      writeTimeoutSeconds * time.Second,
+			IdleTimeout:       idleTimeoutSeconds * time.Second,
+		}
+		go func() {
+			if err := metricsServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				cfg.logger.Error("Metrics server error", "addr", cfg.metricsAddr, "error", err)
+			}
+		}()
+	}
+
 	// Create and configure server
 	srv := server.New(serverConfig, cfg.logger)
 	srv.RegisterHandler(httpHandler)
@@ -609,27 +1397,68 @@ func main() {
 		Port:          cfg.port,
 		AdminLoginURL: adminLoginURL,
 		AdminURL:      adminURL,
-		PersistMode:   ui.BuildPersistModeSummary(cfg.useFiles, cfg.dbPath, cfg.dataDir),
-		RateLimit:     ui.BuildRateLimitSummary(cfg.rateLimitReq, cfg.rateLimitBurst),
+		PersistMode:   ui.BuildPersistModeSummary(cfg.useFiles, cfg.dbPath, cfg.dbURL, cfg.dataDir),
+		Retention:     ui.BuildRetentionSummary(cfg.db != nil, cfg.retention.MaxAge, cfg.retention.MaxRows, cfg.vacuumInterval),
+		StatsWrites:   ui.BuildStatsWritesSummary(!inMemory, recorder != nil, cfg.statsQueue, cfg.statsBatch, cfg.statsFlush, cfg.statsOverflow),
+		RateLimit:     ui.BuildRateLimitSummary(cfg.rateLimitReq, cfg.rateLimitBurst, cfg.rateLimitKey),
+		AutoBan:       ui.BuildAutoBanSummary(len(banEngine.Rules()), cfg.banTTL, cfg.banKey),
+		GeoIP:         ui.BuildGeoIPSummary(cfg.geoipCity, cfg.geoipASN),
+		BotVerify:     ui.BuildBotVerifySummary(cfg.botVerifyTTL > 0, botVerifySupported, cfg.botVerifyTTL),
+		Forms:         ui.BuildFormsSummary(endpoint, cfg.forms, cfg.honeytokens, formsSupported),
+		Capture:       ui.BuildCaptureSummary(cfg.capture, captureSupported, captureConfig.MaxBodySize, len(captureConfig.Redact)),
+		Bait:          ui.BuildBaitSummary(cfg.bait, cfg.baitCatalog, len(baitEntries), baitSupported),
+		Defense:       ui.BuildDefenseSummary(defenseConfig.Actions, defenseConfig.Bandwidth, defenseConfig.MaxActive),
+		Compression:   ui.BuildCompressionSummary(encodings),
+		Webhooks:      ui.BuildWebhookSummary(len(notifyConfig.Targets), notifyConfig.Secret != ""),
+		Syslog:        ui.BuildSyslogSummary(len(siemConfig.Targets), cfg.syslogFacility),
+		Replication:   ui.BuildReplicationSummary(cfg.nodeName, cfg.replicateTo, collector != nil),
+		Metrics:       ui.BuildMetricsSummary(cfg.metricsEnabled, cfg.metricsAddr, cfg.metricsToken != ""),
+		Tracing:       ui.BuildTracingSummary(cfg.tracing.Exporter, cfg.tracing.Endpoint, cfg.tracing.SampleRatio),
 		Wordlist:      ui.BuildWordlistSummary(wordlistFile, len(wordlist)),
 		Template:      ui.BuildTemplateSummary(htmlFile, htmlTemplateSize),
 	}
 	ui.PrintStartupInfo(startupInfo)
+	cfg.adminHandler.SetSettings(adminSettings(startupInfo))
 
 	// Define cleanup function for graceful shutdown
 	cleanup := func() {
 		ui.PrintShutdown()
 
-		// Stop periodic saving
-		cfg.stopPeriodicSave()
+		// Stop the metrics listener
+		if metricsServer != nil {
+			metricsServer.Close()
+		}
 
-		// Save final stats snapshot if using file-based persistence
-		if cfg.useFiles && cfg.dataDir != "" {
-			if err := cfg.saveStats(); err != nil {
-				cfg.logger.Debug("Failed to save final stats", "error", err)
-			}
+		// Write queued requests while webhook and syslog hooks can still deliver
+		if recorder != nil {
+			recorder.Stop()
+		}
+
+		// End live dashboard streams so the server can shut down
+		cfg.statsManager.Hub().Close()
+
+		// Push requests recorded since the last push
+		if sender != nil {
+			sender.Stop()
 		}
 
+		// Stop webhook delivery and syslog forwarding
+		notifier.Stop()
+		forwarder.Stop()
+
+		// Write final firewall exports
+		exporter.Stop()
+		if err := exporter.WriteFiles(context.Background()); err != nil {
+			cfg.logger.Debug("Failed to write final firewall exports", "error", err)
+		}
+
+		// Flush buffered spans
+		traceCtx, traceCancel := context.WithTimeout(context.Background(), shutdownTimeoutSeconds*time.Second)
+		if err := shutdownTracing(traceCtx); err != nil {
+			cfg.logger.Debug("Failed to flush traces", "error", err)
+		}
+		traceCancel()
+
 		ui.PrintShutdownComplete()
 	}
 
@@ -637,6 +1466,51 @@ func main() {
 	srv.GracefulShutdown(shutdownTimeoutSeconds*time.Second, cleanup)
 }
 
+// adminSettings groups the startup summary into the sections shown on the
+// admin settings page. The login URL is left out, since it contains the
+// admin token.
+func adminSettings(info ui.StartupInfo) []admin.SettingsSection {
+	wordlist, template := info.Wordlist, info.Template
+	if wordlist == "" {
+		wordlist = "Random links"
+	}
+	if template == "" {
+		template = "Generated pages"
+	}
+	persistence := []admin.Setting{{Name: "Mode", Value: info.PersistMode}}
+	if info.StatsWrites != "" {
+		persistence = append(persistence, admin.Setting{Name: "Writes", Value: info.StatsWrites})
+	}
+	if info.Retention != "" {
+		persistence = append(persistence, admin.Setting{Name: "Retention", Value: info.Retention})
+	}
+
+	return []admin.SettingsSection{
+		{Title: "Server", Settings: []admin.Setting{
+			{Name: "Port", Value: info.Port},
+			{Name: "Rate Limiting", Value: info.RateLimit},
+			{Name: "Auto-Ban", Value: info.AutoBan},
+			{Name: "GeoIP", Value: info.GeoIP},
+			{Name: "Bot Verification", Value: info.BotVerify},
+			{Name: "Forms", Value: info.Forms},
+			{Name: "Request Capture", Value: info.Capture},
+			{Name: "Scanner Bait", Value: info.Bait},
+			{Name: "Defense Responses", Value: info.Defense},
+			{Name: "Compression", Value: info.Compression},
+			{Name: "Webhooks", Value: info.Webhooks},
+			{Name: "Syslog", Value: info.Syslog},
+			{Name: "Replication", Value: info.Replication},
+			{Name: "Metrics", Value: info.Metrics},
+			{Name: "Tracing", Value: info.Tracing},
+		}},
+		{Title: "Content", Settings: []admin.Setting{
+			{Name: "Wordlist", Value: wordlist},
+			{Name: "Template", Value: template},
+		}},
+		{Title: "Persistence", Settings: persistence},
+	}
+}
+
 // validateFilePath checks if a file path is safe to access.
 // It prevents directory traversal attacks by rejecting paths containing ".."
 // and ensures the path is absolute or relative to current directory.
@@ -657,33 +1531,6 @@ func validateFilePath(path string) error {
 	return nil
 }
 
-// validateDataDirPath checks if a path is within the data directory.
-// This prevents directory traversal for programmatically constructed paths.
-func validateDataDirPath(dataDir, filePath string) error {
-	// Convert both to absolute paths
-	absDataDir, err := filepath.Abs(dataDir)
-	if err != nil {
-		return fmt.Errorf("cannot resolve data directory: %w", err)
-	}
-	absFilePath, err := filepath.Abs(filePath)
-	if err != nil {
-		return fmt.Errorf("cannot resolve file path: %w", err)
-	}
-
-	// Check if file path is within data directory
-	relPath, err := filepath.Rel(absDataDir, absFilePath)
-	if err != nil {
-		return fmt.Errorf("cannot determine relative path: %w", err)
-	}
-
-	// If relative path starts with "..", it's outside the data directory
-	if strings.HasPrefix(relPath, "..") {
-		return fmt.Errorf("file path is outside data directory: %s", filePath)
-	}
-
-	return nil
-}
-
 // loadWordlist loads wordlist entries from a file, one entry per line.
 //
 // Empty lines and lines containing only whitespace are ignored.
@@ -734,4 +1581,3 @@ func loadWordlist(filename string) ([]string, error) {
 
 	return wordlist, nil
 }
-